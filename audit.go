@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aerionblue/pizzafest/audit"
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+// auditStats records a bid assignment in the audit log, if it assigned
+// anything.
+func (b *bot) auditStats(action audit.Action, donor string, stats bidwar.UpdateStats, by string) {
+	if stats.Count == 0 {
+		return
+	}
+	b.audit(audit.Entry{
+		Action: action,
+		Donor:  donor,
+		Option: stats.Choice.Option.ShortCode,
+		Count:  stats.Count,
+		Cents:  stats.TotalValue.Cents(),
+		Reason: stats.Choice.Reason,
+		By:     by,
+	})
+}
+
+// audit records an entry in the audit log, if there is one. The donor's
+// Twitch user ID is filled in if it's known.
+func (b *bot) audit(e audit.Entry) {
+	if b.auditLog != nil && e.Donor != "" && e.DonorID == "" {
+		e.DonorID = b.users.KnownUserID(b.identities.TwitchUser(e.Donor))
+	}
+	if err := b.auditLog.Record(e); err != nil {
+		log.Printf("ERROR writing audit log: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Package audit keeps a history of bid assignments in a local file,
+// independent of the donation table, so that disputes ("why is my money on
+// DMC2?") can be settled even after the table has been edited.
+//
+// The file has one JSON object per line.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Action is the kind of change that an Entry records.
+type Action string
+
+const (
+	// A donation was recorded with a bid war choice.
+	Donation Action = "donation"
+	// A !bid assigned the donor's unassigned donations.
+	Bid Action = "bid"
+	// A split or partial !bid assigned part of the donor's donations.
+	Split Action = "split"
+	// A !bid moved the donations that an earlier !bid assigned.
+	Reassign Action = "reassign"
+	// A mod distributed a contest's unassigned donations.
+	AutoAssign Action = "autoassign"
+	// A mod accepted a bid that arrived after its contest closed.
+	LateBid Action = "late_bid"
+	// A mod renamed an option, moving its donations to the new short code.
+	Rename Action = "rename"
+	// The contest's donor cap flagged the donor's bids past it, moving them
+	// off their options.
+	OverCap Action = "over_cap"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action Action    `json:"action"`
+	// The donor whose donations were assigned. Empty if the change affected
+	// many donors (e.g., AutoAssign).
+	Donor string `json:"donor,omitempty"`
+	// The donor's Twitch user ID, if known.
+	DonorID string `json:"donorId,omitempty"`
+	// The short code of the option that the donations went to.
+	Option string `json:"option,omitempty"`
+	// The short code of the option that the donations were taken from, for
+	// Reassign and Rename.
+	From string `json:"from,omitempty"`
+	// The contest, for AutoAssign and OverCap.
+	Contest string `json:"contest,omitempty"`
+	// How many donation table rows were assigned, and their total value.
+	Count int `json:"count,omitempty"`
+	Cents int `json:"cents"`
+	// The bid war reason written to the donation table.
+	Reason string `json:"reason,omitempty"`
+	// Who made the change happen: the donor, the mod who ran a command,
+	// "schedule" for a contest that closed at its scheduled time, or "cap"
+	// for OverCap.
+	By string `json:"by"`
+}
+
+// Log is an append-only audit log file. A nil *Log records nothing.
+type Log struct {
+	path string
+	now  func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewLog creates a Log that appends to the given file.
+func NewLog(path string) *Log {
+	return &Log{path: path, now: time.Now}
+}
+
+// Record appends an entry to the log. If the entry's Time is zero, it is set
+// to now.
+func (l *Log) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open audit log: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write to audit log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	now := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+	l := NewLog(path)
+	l.now = func() time.Time { return now }
+
+	entries := []Entry{
+		{Action: Bid, Donor: "aerionblue", Option: "DMC3", Count: 2, Cents: 1100, Reason: "[chat] !bid dmc3", By: "aerionblue"},
+		{Time: now.Add(time.Minute), Action: Reassign, Donor: "aerionblue", Option: "DMC2", From: "DMC3", Count: 2, Cents: 1100, By: "aerionblue"},
+	}
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening the log: %v", err)
+	}
+	defer f.Close()
+	var got []Entry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("malformed line %q: %v", s.Text(), err)
+		}
+		got = append(got, e)
+	}
+	entries[0].Time = now
+	if diff := deep.Equal(got, entries); diff != nil {
+		t.Error(diff)
+	}
+
+	var nilLog *Log
+	if err := nilLog.Record(entries[0]); err != nil {
+		t.Errorf("nil Log: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+// Package backup saves snapshots of the spreadsheet to a local directory, so
+// that the data can be recovered if somebody wrecks the shared sheet.
+//
+// Each snapshot is a directory named after the time it was taken (e.g.,
+// "20220701-201500"), with one CSV file per sheet. If more than one snapshot
+// is taken in the same second, the later ones get a sequence number (e.g.,
+// "20220701-201500-2").
+package backup
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+const timeFormat = "20060102-150405"
+
+var /* const */ unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Dir is a directory of snapshots.
+type Dir struct {
+	path string
+	// How many snapshots to keep. If 0, all of them are kept.
+	keep int
+}
+
+// NewDir returns a Dir that keeps the given number of snapshots (or all of
+// them, if keep is 0). The directory is created if it doesn't exist.
+func NewDir(path string, keep int) (*Dir, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("could not create backup directory: %v", err)
+	}
+	return &Dir{path: path, keep: keep}, nil
+}
+
+// Save writes a snapshot taken at the given time, then deletes the oldest
+// snapshots beyond the number to keep. Returns the snapshot's directory.
+func (d *Dir) Save(sheets []googlesheets.SheetValues, now time.Time) (string, error) {
+	name := now.UTC().Format(timeFormat)
+	// Write to a temp directory first, so that a partial snapshot never
+	// looks complete.
+	tmp, err := ioutil.TempDir(d.path, "."+name+".tmp")
+	if err != nil {
+		return "", fmt.Errorf("could not create snapshot directory: %v", err)
+	}
+	// TempDir makes the directory private.
+	if err := os.Chmod(tmp, 0755); err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("could not create snapshot directory: %v", err)
+	}
+	for i, sh := range sheets {
+		file := filepath.Join(tmp, fileName(i, sh.Title))
+		if err := writeCSV(file, sh.Rows); err != nil {
+			os.RemoveAll(tmp)
+			return "", err
+		}
+	}
+	final, err := d.claim(tmp, name)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("could not save snapshot: %v", err)
+	}
+	return final, d.prune()
+}
+
+// claim renames the temp directory to the snapshot's name, adding a sequence
+// number if a snapshot by that name already exists. Returns the new path.
+func (d *Dir) claim(tmp string, name string) (string, error) {
+	for seq := 1; ; seq++ {
+		final := filepath.Join(d.path, snapshotName(name, seq))
+		if _, err := os.Lstat(final); err == nil {
+			continue
+		}
+		err := os.Rename(tmp, final)
+		if os.IsExist(err) {
+			continue
+		}
+		return final, err
+	}
+}
+
+// snapshotName returns the name of the seq'th snapshot taken in the second
+// named by name.
+func snapshotName(name string, seq int) string {
+	if seq <= 1 {
+		return name
+	}
+	return fmt.Sprintf("%s-%d", name, seq)
+}
+
+// parseSnapshotName returns the time and sequence number in a snapshot's
+// name, or false if it isn't the name of a snapshot.
+func parseSnapshotName(name string) (time.Time, int, bool) {
+	seq := 1
+	if len(name) > len(timeFormat) {
+		n, err := strconv.Atoi(strings.TrimPrefix(name[len(timeFormat):], "-"))
+		if err != nil || name[len(timeFormat)] != '-' || n < 2 {
+			return time.Time{}, 0, false
+		}
+		seq = n
+		name = name[:len(timeFormat)]
+	}
+	t, err := time.Parse(timeFormat, name)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// prune deletes the oldest snapshots beyond the number to keep.
+func (d *Dir) prune() error {
+	if d.keep <= 0 {
+		return nil
+	}
+	snaps, err := d.snapshots()
+	if err != nil {
+		return err
+	}
+	for len(snaps) > d.keep {
+		if err := os.RemoveAll(filepath.Join(d.path, snaps[0])); err != nil {
+			return fmt.Errorf("could not delete old snapshot: %v", err)
+		}
+		snaps = snaps[1:]
+	}
+	return nil
+}
+
+// snapshots lists the names of the snapshots in the directory, oldest first.
+func (d *Dir) snapshots() ([]string, error) {
+	infos, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not list backups: %v", err)
+	}
+	type snapshot struct {
+		name string
+		time time.Time
+		seq  int
+	}
+	var snaps []snapshot
+	for _, fi := range infos {
+		if !fi.IsDir() {
+			continue
+		}
+		if t, seq, ok := parseSnapshotName(fi.Name()); ok {
+			snaps = append(snaps, snapshot{fi.Name(), t, seq})
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		if !snaps[i].time.Equal(snaps[j].time) {
+			return snaps[i].time.Before(snaps[j].time)
+		}
+		return snaps[i].seq < snaps[j].seq
+	})
+	names := make([]string, len(snaps))
+	for i, s := range snaps {
+		names[i] = s.name
+	}
+	return names, nil
+}
+
+// fileName returns the CSV file name for a sheet. The sheet's position is
+// included, so that two sheets whose titles clean up the same way don't
+// collide.
+func fileName(i int, title string) string {
+	clean := strings.Trim(unsafeFileChars.ReplaceAllString(title, "_"), "_")
+	return fmt.Sprintf("%02d-%s.csv", i+1, clean)
+}
+
+func writeCSV(path string, rows [][]interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", path, err)
+	}
+	w := csv.NewWriter(f)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = fmt.Sprint(v)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+func TestSave(t *testing.T) {
+	root := t.TempDir()
+	d, err := NewDir(filepath.Join(root, "backups"), 2)
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	sheets := []googlesheets.SheetValues{
+		{Title: "Tracker", Rows: [][]interface{}{{"Contributor", "What", "Points"}, {"aerionblue", "resub, tier 1", 5.0}}},
+		{Title: "Bid wars / totals", Rows: [][]interface{}{{"=SUM(A:A)"}}},
+	}
+	start := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+	var last string
+	for i := 0; i < 3; i++ {
+		if last, err = d.Save(sheets, start.Add(time.Duration(i)*15*time.Minute)); err != nil {
+			t.Fatalf("Save #%d: %v", i, err)
+		}
+	}
+
+	if got, want := filepath.Base(last), "20220701-203000"; got != want {
+		t.Errorf("snapshot is named %q, want %q", got, want)
+	}
+	snaps, err := d.snapshots()
+	if err != nil {
+		t.Fatalf("snapshots: %v", err)
+	}
+	if diff := deep.Equal(snaps, []string{"20220701-201500", "20220701-203000"}); diff != nil {
+		t.Errorf("kept snapshots: %v", diff)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(last, "01-Tracker.csv"))
+	if err != nil {
+		t.Fatalf("reading the tracker CSV: %v", err)
+	}
+	if got, want := string(data), "Contributor,What,Points\naerionblue,\"resub, tier 1\",5\n"; got != want {
+		t.Errorf("got CSV %q, want %q", got, want)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(last, "02-Bid_wars_totals.csv")); err != nil {
+		t.Errorf("reading the totals CSV: %v", err)
+	}
+}
+
+func TestSaveSameSecond(t *testing.T) {
+	d, err := NewDir(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	sheets := []googlesheets.SheetValues{{Title: "Tracker", Rows: [][]interface{}{{"Contributor"}}}}
+	now := time.Date(2022, 7, 1, 20, 15, 0, 0, time.UTC)
+	var got []string
+	for i := 0; i < 11; i++ {
+		path, err := d.Save(sheets, now)
+		if err != nil {
+			t.Fatalf("Save #%d: %v", i, err)
+		}
+		got = append(got, filepath.Base(path))
+	}
+	if got[0] != "20220701-201500" || got[1] != "20220701-201500-2" || got[10] != "20220701-201500-11" {
+		t.Errorf("got snapshot names %v", got)
+	}
+	snaps, err := d.snapshots()
+	if err != nil {
+		t.Fatalf("snapshots: %v", err)
+	}
+	if diff := deep.Equal(snaps, got); diff != nil {
+		t.Errorf("snapshots are out of order: %v", diff)
+	}
+}
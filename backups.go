@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/backup"
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+// runBackups saves a snapshot of the whole spreadsheet every interval. It
+// never returns.
+func runBackups(srv *sheets.Service, spreadsheetID string, dir *backup.Dir, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := saveBackup(srv, spreadsheetID, dir); err != nil {
+			log.Printf("ERROR backing up the spreadsheet: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+func saveBackup(srv *sheets.Service, spreadsheetID string, dir *backup.Dir) error {
+	snap, err := googlesheets.Snapshot(srv, spreadsheetID)
+	if err != nil {
+		return err
+	}
+	path, err := dir.Save(snap, time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("backed up %d sheets to %s", len(snap), path)
+	return nil
+}
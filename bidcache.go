@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+// bidCache remembers each user's last !bid command and the replies to it.
+// Users often send the same !bid several times in a row, and each one would
+// otherwise read the whole donation table. A different !bid always goes
+// through. A nil bidCache remembers nothing.
+type bidCache struct {
+	window time.Duration
+
+	mu sync.Mutex
+	// Keyed by lowercase username.
+	entries map[string]cachedBid
+}
+
+type cachedBid struct {
+	message string
+	replies []string
+	at      time.Time
+}
+
+func newBidCache(window time.Duration) *bidCache {
+	return &bidCache{window: window, entries: make(map[string]cachedBid)}
+}
+
+// lookup returns the replies to the user's last !bid, if it's the same as
+// this one and was recent enough.
+func (c *bidCache) lookup(user string, message string, now time.Time) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[strings.ToLower(user)]
+	if !ok || e.message != normalizeBid(message) || now.Sub(e.at) >= c.window {
+		return nil, false
+	}
+	return e.replies, true
+}
+
+// store remembers the replies to a !bid. Commands that got no reply (e.g.,
+// because of an error) aren't remembered, so that they can be retried.
+func (c *bidCache) store(user string, message string, replies []string, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.ToLower(user)
+	if len(replies) == 0 {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = cachedBid{message: normalizeBid(message), replies: replies, at: now}
+	for k, e := range c.entries {
+		if now.Sub(e.at) >= c.window {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// forget drops the user's cached !bid, e.g. because they just donated and
+// the same !bid would now assign something.
+func (c *bidCache) forget(user string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, strings.ToLower(user))
+}
+
+// normalizeBid ignores differences in case and spacing between two !bids.
+func normalizeBid(message string) string {
+	return strings.ToLower(strings.Join(strings.Fields(message), " "))
+}
+
+// bidReplier sends the replies to one !bid command, and keeps track of them
+// for the bidCache.
+type bidReplier struct {
+	b       *bot
+	channel string
+	// The ID of the !bid message, which the replies are threaded under.
+	parentID string
+	sent     []string
+}
+
+func (r *bidReplier) say(msg string) {
+	r.sent = append(r.sent, msg)
+	r.b.replyPersonal(chatBid, r.channel, r.parentID, msg)
+}
+
+func (r *bidReplier) sayWithTotals(opt bidwar.Option, msgPrefix string) {
+	if msg := r.b.replyWithTotals(chatBid, r.channel, r.parentID, opt, msgPrefix); msg != "" {
+		r.sent = append(r.sent, msg)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBidCache(t *testing.T) {
+	now := time.Now()
+	c := newBidCache(10 * time.Second)
+	replies := []string{"@aerionblue: +5.00 for Moo Moo Meadows"}
+	c.store("aerionblue", "!bid moo", replies, now)
+
+	if got, ok := c.lookup("AerionBlue", "!BID  moo ", now.Add(time.Second)); !ok || !reflect.DeepEqual(got, replies) {
+		t.Errorf("repeated bid: got (%q, %v), want (%q, true)", got, ok, replies)
+	}
+	if _, ok := c.lookup("aerionblue", "!bid nbc", now.Add(time.Second)); ok {
+		t.Error("a different bid should not be cached")
+	}
+	if _, ok := c.lookup("usedpizza", "!bid moo", now.Add(time.Second)); ok {
+		t.Error("another user's bid should not be cached")
+	}
+	if _, ok := c.lookup("aerionblue", "!bid moo", now.Add(10*time.Second)); ok {
+		t.Error("the cached bid should expire after the window")
+	}
+
+	c.forget("aerionblue")
+	if _, ok := c.lookup("aerionblue", "!bid moo", now.Add(time.Second)); ok {
+		t.Error("a forgotten bid should not be cached")
+	}
+
+	c.store("aerionblue", "!bid moo", replies, now)
+	c.store("aerionblue", "!bid moo", nil, now)
+	if _, ok := c.lookup("aerionblue", "!bid moo", now.Add(time.Second)); ok {
+		t.Error("a bid with no replies should not be cached")
+	}
+}
+
+func TestNilBidCache(t *testing.T) {
+	var c *bidCache
+	c.store("aerionblue", "!bid moo", []string{"hi"}, time.Now())
+	if _, ok := c.lookup("aerionblue", "!bid moo", time.Now()); ok {
+		t.Error("a nil cache should not remember anything")
+	}
+}
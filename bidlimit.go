@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// bidLimiter throttles each user's !bid commands, so that one viewer spamming
+// !bid can't make the bot reread the donation table and reply over and over.
+// It is separate from the chatLimiter, which limits what the bot says.
+// A nil bidLimiter allows everything.
+type bidLimiter struct {
+	interval time.Duration
+
+	mu sync.Mutex
+	// Keyed by lowercase username.
+	last map[string]limitedBid
+}
+
+type limitedBid struct {
+	// The time of the user's last allowed !bid.
+	at time.Time
+	// Whether the user was told that they're bidding too often since then.
+	told bool
+}
+
+func newBidLimiter(interval time.Duration) *bidLimiter {
+	return &bidLimiter{interval: interval, last: make(map[string]limitedBid)}
+}
+
+// allow reports whether the user may run a !bid now, and if so, starts their
+// next interval. If not, it returns how long until they may, and whether to
+// tell them so. They're only told once per interval, so that the replies
+// can't be spammed either.
+func (l *bidLimiter) allow(user string, now time.Time) (ok bool, wait time.Duration, tell bool) {
+	if l == nil {
+		return true, 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := strings.ToLower(user)
+	if e, found := l.last[key]; found && now.Sub(e.at) < l.interval {
+		tell = !e.told
+		e.told = true
+		l.last[key] = e
+		return false, l.interval - now.Sub(e.at), tell
+	}
+	l.last[key] = limitedBid{at: now}
+	for k, e := range l.last {
+		if now.Sub(e.at) >= l.interval {
+			delete(l.last, k)
+		}
+	}
+	return true, 0, false
+}
+
+// forget lets the user run a !bid right away, e.g. because they just donated
+// and their next !bid would assign it.
+func (l *bidLimiter) forget(user string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.last, strings.ToLower(user))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBidLimiter(t *testing.T) {
+	now := time.Now()
+	l := newBidLimiter(15 * time.Second)
+	if ok, _, _ := l.allow("aerionblue", now); !ok {
+		t.Error("first bid should be allowed")
+	}
+	ok, wait, tell := l.allow("AerionBlue", now.Add(5*time.Second))
+	if ok || wait != 10*time.Second || !tell {
+		t.Errorf("second bid within the interval: got (%v, %v, %v), want throttled for 10s with a reply", ok, wait, tell)
+	}
+	if ok, _, tell := l.allow("aerionblue", now.Add(6*time.Second)); ok || tell {
+		t.Errorf("third bid within the interval: got (%v, %v), want throttled without a reply", ok, tell)
+	}
+	if ok, _, _ := l.allow("usedpizza", now.Add(5*time.Second)); !ok {
+		t.Error("another user's bid should be allowed")
+	}
+	if ok, _, _ := l.allow("aerionblue", now.Add(15*time.Second)); !ok {
+		t.Error("bid after the interval should be allowed")
+	}
+	if _, _, tell := l.allow("aerionblue", now.Add(16*time.Second)); !tell {
+		t.Error("the first throttled bid of a new interval should get a reply")
+	}
+
+	l.forget("aerionblue")
+	if ok, _, _ := l.allow("aerionblue", now.Add(16*time.Second)); !ok {
+		t.Error("bid after forget should be allowed")
+	}
+
+	var nilLimiter *bidLimiter
+	ok1, _, _ := nilLimiter.allow("aerionblue", now)
+	ok2, _, _ := nilLimiter.allow("aerionblue", now)
+	if !ok1 || !ok2 {
+		t.Error("nil bidLimiter should allow everything")
+	}
+}
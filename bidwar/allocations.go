@@ -0,0 +1,71 @@
+package bidwar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Allocation is how much of a donor's money is on one Option.
+type Allocation struct {
+	// The zero Option for money that hasn't been assigned yet.
+	Option Option
+	Value  donation.CentsValue
+}
+
+// Allocations returns how the donor's donations (including those under their
+// linked tip names) are currently allocated, biggest first, with unassigned
+// money last.
+func (t Tallier) Allocations(donor string) ([]Allocation, error) {
+	valueRange, err := t.table.GetTable()
+	if err != nil {
+		return nil, fmt.Errorf("error reading donation table: %v", err)
+	}
+	return allocationsFromTable(valueRange, t.donorNames(donor, valueRange), t.bidwars()), nil
+}
+
+func allocationsFromTable(vr *sheets.ValueRange, donorNames []string, c Collection) []Allocation {
+	var allocs []Allocation
+	index := make(map[string]int)
+	for i, row := range vr.Values {
+		dr := donationRow(row)
+		if i == 0 || !isDonor(dr, donorNames) || dr.Cents() == 0 {
+			continue
+		}
+		code := strings.TrimSpace(dr.Choice())
+		key := strings.ToLower(code)
+		j, ok := index[key]
+		if !ok {
+			j = len(allocs)
+			index[key] = j
+			var opt Option
+			if code != "" {
+				base, overCap := isOverCap(code)
+				if !overCap {
+					base = code
+				}
+				if opt, ok = c.OptionByShortCode(base); !ok {
+					// An option that has since been removed.
+					opt = Option{DisplayName: base, ShortCode: base}
+				}
+				if overCap {
+					opt.DisplayName += overCapSuffix
+					opt.ShortCode = code
+				}
+			}
+			allocs = append(allocs, Allocation{Option: opt})
+		}
+		allocs[j].Value += donation.CentsValue(dr.Cents())
+	}
+	sort.SliceStable(allocs, func(i, j int) bool {
+		if allocs[i].Option.IsZero() != allocs[j].Option.IsZero() {
+			return allocs[j].Option.IsZero()
+		}
+		return allocs[i].Value > allocs[j].Value
+	})
+	return allocs
+}
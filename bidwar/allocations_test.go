@@ -0,0 +1,45 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestAllocationsFromTable(t *testing.T) {
+	c, err := Parse([]byte(overflowJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	moo, _ := c.OptionByShortCode("Moo")
+	nbc, _ := c.OptionByShortCode("NBC")
+	vr := &sheets.ValueRange{
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message"},
+			{"aerionblue", "resub", "5.00", "Moo", "[chat] moo"},
+			{"aerionblue", "$3.00 donation", 3.0},
+			{"AEWC20XX", "donation", "20.00", "NBC", "nbc"},
+			{"AerionBlue", "$7.00 donation", "7.00", "moo"},
+			{"aerion tips", "$2.00 donation", "2.00"},
+			{"aerionblue", "$10.00 donation", "10.00", "NBC"},
+			{"aerionblue", "$1.00 donation", "1.00", "Gone"},
+			{"aerionblue", "note", ""},
+		},
+	}
+	want := []Allocation{
+		{Option: moo, Value: donation.CentsValue(1200)},
+		{Option: nbc, Value: donation.CentsValue(1000)},
+		{Option: Option{DisplayName: "Gone", ShortCode: "Gone"}, Value: donation.CentsValue(100)},
+		{Value: donation.CentsValue(500)},
+	}
+	got := allocationsFromTable(vr, []string{"aerionblue", "aerion tips"}, c)
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+	if got := allocationsFromTable(vr, []string{"usedpizza"}, c); len(got) != 0 {
+		t.Errorf("got %+v for a donor with no donations", got)
+	}
+}
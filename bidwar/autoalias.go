@@ -0,0 +1,110 @@
+package bidwar
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Words that are too common to identify an option by themselves.
+var aliasStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "for": true, "in": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "vs": true,
+	"with": true,
+}
+
+var apostrophes = strings.NewReplacer("'", "", "’", "")
+
+// Words shorter than this aren't used as aliases by themselves.
+const minAliasWordLength = 4
+
+// aliasCandidates suggests aliases for an Option, based on its display name
+// and short code: the short code, the full name, the name's acronym, and each
+// word of the name that isn't too short or too common. All are lowercase.
+func aliasCandidates(opt Option) []string {
+	var cands []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			cands = append(cands, s)
+		}
+	}
+	add(strings.ToLower(opt.ShortCode))
+	add(strings.ToLower(strings.TrimSpace(opt.DisplayName)))
+	// "Luigi's" is one word, not "luigi" and "s".
+	var words []string
+	for _, w := range splitWords(apostrophes.Replace(opt.DisplayName)) {
+		words = append(words, w.text)
+	}
+	var acronym strings.Builder
+	var significant []string
+	for _, w := range words {
+		if !aliasStopWords[w] {
+			significant = append(significant, w)
+			acronym.WriteString(w[:1])
+		}
+	}
+	if len(significant) > 1 {
+		add(acronym.String())
+	}
+	for _, w := range significant {
+		if len(w) >= minAliasWordLength {
+			add(w)
+		}
+	}
+	return cands
+}
+
+// autoAliases generates aliases for an Option that has none. Candidates that
+// could refer to another Option in the Collection are dropped: those that
+// another Option's aliases already match, and those that are also candidates
+// for another Option (e.g., a word that two names share).
+func (c Collection) autoAliases(opt Option) []alias {
+	taken := make(map[string]bool)
+	var others []Option
+	for _, con := range c.Contests {
+		for _, o := range con.Options {
+			if strings.EqualFold(o.ShortCode, opt.ShortCode) {
+				continue
+			}
+			others = append(others, o)
+			for _, cand := range aliasCandidates(o) {
+				taken[cand] = true
+			}
+		}
+	}
+	var aliases []alias
+	for _, cand := range aliasCandidates(opt) {
+		if taken[cand] || matchesAnyAlias(others, cand) {
+			continue
+		}
+		a, err := newAlias(regexp.QuoteMeta(cand))
+		if err != nil {
+			continue
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases
+}
+
+func matchesAnyAlias(opts []Option, s string) bool {
+	for _, o := range opts {
+		for _, a := range o.Aliases {
+			if a.Regexp != nil && a.MatchString(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fillAliases generates aliases for every Option that has none.
+func (c Collection) fillAliases() {
+	for i := range c.Contests {
+		for j := range c.Contests[i].Options {
+			if opt := &c.Contests[i].Options[j]; len(opt.Aliases) == 0 {
+				opt.Aliases = c.autoAliases(*opt)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAutoAliases(t *testing.T) {
+	c, err := Parse([]byte(`{"contests": [{"name": "Tracks", "options": [
+		{"displayName": "Moo Moo Meadows", "shortCode": "MMM"},
+		{"displayName": "Mario Circuit", "shortCode": "MC"},
+		{"displayName": "Luigi Circuit", "shortCode": "LC", "aliases": ["luigi"]},
+		{"displayName": "Rainbow Road", "shortCode": "Rainbow"}
+	]}]}`))
+	if err != nil {
+		t.Fatalf("error parsing: %v", err)
+	}
+	for _, tc := range []struct {
+		code string
+		want []string
+	}{
+		// The acronym is the same as the short code.
+		{"MMM", []string{"mmm", "moo moo meadows", "meadows"}},
+		// "circuit" is in two names.
+		{"MC", []string{"mc", "mario circuit", "mario"}},
+		{"LC", []string{"luigi"}},
+		// "rainbow" is the short code, so it only appears once.
+		{"Rainbow", []string{"rainbow", "rainbow road", "rr", "road"}},
+	} {
+		opt, _ := c.OptionByShortCode(tc.code)
+		if diff := cmp.Diff(tc.want, opt.AliasNames()); diff != "" {
+			t.Errorf("%s aliases (-want +got):\n%s", tc.code, diff)
+		}
+	}
+
+	// A runtime addition can't take aliases that another option uses.
+	opt, _ := NewOption("Luigi's Mansion", "LM", nil)
+	c, err = c.AddOption("Tracks", opt)
+	if err != nil {
+		t.Fatalf("error adding option: %v", err)
+	}
+	opt, _ = c.OptionByShortCode("LM")
+	if diff := cmp.Diff([]string{"lm", "luigis", "mansion"}, opt.AliasNames()); diff != "" {
+		t.Errorf("LM aliases (-want +got):\n%s", diff)
+	}
+}
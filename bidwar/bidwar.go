@@ -5,17 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math"
-	"math/rand"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
 
 	"google.golang.org/api/sheets/v4"
 
 	"github.com/aerionblue/pizzafest/donation"
 	"github.com/aerionblue/pizzafest/googlesheets"
+	"github.com/aerionblue/pizzafest/identity"
+	"github.com/aerionblue/pizzafest/money"
+	"github.com/aerionblue/pizzafest/sanitize"
 )
 
 // Google Sheets developer metadata keys. The target spreadsheet must contain
@@ -26,7 +30,7 @@ const metadataBidWarNames = "bidWarNames"
 const metadataBidWarTotals = "bidWarTotals"
 
 // Special directives users can use when selecting a bid war option.
-var randomDirective = regexp.MustCompile("(?i)random")
+var randomDirective = regexp.MustCompile(`(?i)random|dealer'?s choice`)
 
 // Collection is a set of bid wars.
 type Collection struct {
@@ -34,6 +38,19 @@ type Collection struct {
 	// Whether to ONLY accept bids via explicit chat command. Defaults to
 	// false, i.e., bids will be inferred from resub messages, etc.
 	RequireExplicitBid bool
+	// Whether to accept misspelled option names and aliases (e.g., "moomoo
+	// medows") when nothing in the message matches exactly. Only plain-text
+	// aliases are used; aliases with regexp syntax must match exactly.
+	FuzzyMatch bool
+	// How similar a misspelling must be to count, from 0 to 1, where 1 means
+	// identical apart from case and spacing. Defaults to 0.8.
+	FuzzyThreshold float64
+	// How to clean up donor messages before they're used in a Choice's Reason.
+	// This comes from the bot config, not the bid war data file.
+	ReasonOptions sanitize.Options `json:"-"`
+	// The source of random numbers. If nil, the global source is used. This
+	// also comes from the bot config.
+	Random *Random `json:"-"`
 }
 
 // Contest is a single bid war between several options. The option that
@@ -41,34 +58,132 @@ type Collection struct {
 type Contest struct {
 	// Display name for the contest.
 	Name string
-	// How to summarize the totals. This doesn't affect bid tallying behavior.
-	// It only changes how the current status of the bid war is reported to users.
-	// The default is "ALL": all options are reported, in descending order (i.e.,
-	// winning option first).
-	// TODO(aerion): Enum-ify this.
-	SummaryStyle string
+	// How to summarize the totals. Defaults to AllSummary.
+	SummaryStyle SummaryStyle
+	// A text/template for the summary (e.g., "{{.Leader}} leads by
+	// {{.Margin}}"), which overrides the SummaryStyle. See summaryData for
+	// the available fields.
+	SummaryTemplate string
 	// How many of the options will win. Only used if the summary style
 	// is "WINNERS".
 	NumberOfWinners int
+	// How a "WINNERS" summary presents a tie for the last winning spot.
+	TieDisplay TieDisplay
+	// Emotes to use when reporting the status of this contest.
+	Emotes Emotes
 	// The options on which donors can bid money.
 	Options []Option
 	// Whether this contest is accepting new bids.
 	Closed bool
+	// Phrases (e.g., "all of it on") that force a donation to go to this
+	// contest. When a message contains one of these phrases, only this
+	// contest's options are considered, even if another contest's alias
+	// appears earlier in the message.
+	PriorityPhrases []alias
+	// How to distribute unassigned donations when this contest closes or is
+	// locked (or when a mod runs !autoassign). If empty, unassigned donations
+	// are left alone.
+	UnassignedPolicy UnassignedPolicy
+	// If true, the UnassignedPolicy applies to every unassigned donation.
+	// Otherwise, it only applies to donations whose message asked for a
+	// random choice.
+	AssignAllUnassigned bool
+	// When bidding on this contest opens and closes, if known. These are
+	// published in the schedule feed; the zero time means "not scheduled".
+	// The bot counts down to ClosesAt in chat, and closes the contest then.
+	OpensAt  time.Time
+	ClosesAt time.Time
+	// For this many seconds after ClosesAt, bids for this contest are held
+	// for a mod to accept or reject, instead of being ignored.
+	LateBidGraceSeconds int
+	// When an option gains at least SurgeCents within SurgeMinutes, we
+	// announce that it's surging. If either is zero, surges aren't announced.
+	// For a contest with a Metric other than points, SurgeCents is in the
+	// metric's units.
+	SurgeCents   int
+	SurgeMinutes int
+	// How to pick the winner if the contest ends in a tie. If empty, the tie
+	// is announced and left to the streamers.
+	TieBreaker TieBreaker
+	// What the options are ranked by. Defaults to points (i.e., money).
+	Metric Metric
+	// The short code of an option (usually a stretch goal) that absorbs bids
+	// for this contest once it's Locked. If empty, bids for a locked contest
+	// still count as usual.
+	OverflowOption string
+	// Whether the winner is locked in, so that bids go to the OverflowOption.
+	// Set by a mod, or automatically if AutoLock is set.
+	Locked bool
+	// Whether to lock the contest as soon as the leader has more than all
+	// the other options put together.
+	AutoLock bool
+	// The most points that one donor's bids can add to this contest. Bids
+	// past the cap are still recorded, but flagged as over the cap, so that
+	// they don't count. If zero, there is no cap.
+	DonorCapCents int
+	// Whether to run a Twitch Prediction on the winner while the contest is
+	// open.
+	Prediction bool
+}
+
+// InGracePeriod reports whether the contest has closed recently enough that
+// late bids should be held for review.
+func (con Contest) InGracePeriod(now time.Time) bool {
+	if con.ClosesAt.IsZero() || con.LateBidGraceSeconds <= 0 || now.Before(con.ClosesAt) {
+		return false
+	}
+	return now.Before(con.ClosesAt.Add(time.Duration(con.LateBidGraceSeconds) * time.Second))
 }
 
 func (c *Contest) UnmarshalJSON(data []byte) error {
 	type withDefaults Contest
 	newC := &withDefaults{
-		SummaryStyle:    "ALL",
+		SummaryStyle:    AllSummary,
 		NumberOfWinners: 1,
 	}
 	if err := json.Unmarshal(data, newC); err != nil {
 		return err
 	}
+	if !newC.UnassignedPolicy.valid() {
+		return fmt.Errorf("contest %q has unknown unassignedPolicy %q", newC.Name, newC.UnassignedPolicy)
+	}
+	if !newC.TieBreaker.valid() {
+		return fmt.Errorf("contest %q has unknown tieBreaker %q", newC.Name, newC.TieBreaker)
+	}
+	if !newC.Metric.valid() {
+		return fmt.Errorf("contest %q has unknown metric %q", newC.Name, newC.Metric)
+	}
+	if !newC.SummaryStyle.valid() {
+		return fmt.Errorf("contest %q has unknown summaryStyle %q", newC.Name, newC.SummaryStyle)
+	}
+	if err := validateSummaryTemplate(Contest(*newC)); err != nil {
+		return err
+	}
+	if !newC.TieDisplay.valid() {
+		return fmt.Errorf("contest %q has unknown tieDisplay %q", newC.Name, newC.TieDisplay)
+	}
+	if newC.DonorCapCents < 0 {
+		return fmt.Errorf("contest %q has negative donorCapCents %d", newC.Name, newC.DonorCapCents)
+	}
+	for _, opt := range newC.Options {
+		if opt.Target < 0 {
+			return fmt.Errorf("option %q has negative target %d", opt.ShortCode, opt.Target)
+		}
+	}
 	*c = Contest(*newC)
 	return nil
 }
 
+// Emotes are channel-specific emotes that are added to bid war summaries.
+// Each one is optional.
+type Emotes struct {
+	// Used when the last bid was for the option in last place, and it's
+	// still alone in last place.
+	StillLastPlace string
+	// Used when the last bid was for the option that is alone in first place.
+	FirstPlace string
+}
+
 // Option is a contestant in a bid war. Donors can allocate money to an option
 // to help it win its bid war.
 type Option struct {
@@ -77,11 +192,40 @@ type Option struct {
 	// The short code used for bid war tracking. Must be unique in any Collection.
 	ShortCode string
 	// All the aliases by which this choice is known. Matching any of these
-	// aliases in a donation message designates the money to this choice.
+	// aliases in a donation message designates the money to this choice. If
+	// none are given, they are generated from the display name and short
+	// code.
 	Aliases []alias
 	// Whether this option is closed to new bids. Bids for closed options will
 	// be ignored.
 	Closed bool
+	// A total that unlocks this option on its own, regardless of how its
+	// rivals are doing, in the units of the contest's Metric (cents for
+	// points). Summaries show the option's progress towards it. If zero,
+	// the option has no target.
+	Target donation.CentsValue
+}
+
+// NewOption creates an open Option with the given aliases.
+func NewOption(displayName string, shortCode string, aliases []string) (Option, error) {
+	opt := Option{DisplayName: displayName, ShortCode: shortCode}
+	for _, s := range aliases {
+		a, err := newAlias(s)
+		if err != nil {
+			return Option{}, err
+		}
+		opt.Aliases = append(opt.Aliases, a)
+	}
+	return opt, nil
+}
+
+// AliasNames returns the Option's aliases as written.
+func (o Option) AliasNames() []string {
+	var names []string
+	for _, a := range o.Aliases {
+		names = append(names, a.src)
+	}
+	return names
 }
 
 func (o Option) IsZero() bool {
@@ -92,6 +236,31 @@ func (o Option) IsZero() bool {
 type Choice struct {
 	Option Option // The donor's chosen Option.
 	Reason string // The reason we allocated the donation to the Option.
+	// If no open Option was chosen, but the message mentioned a closed one,
+	// this is the closed Option (so that we can tell the donor why their
+	// choice didn't count).
+	ClosedOption Option
+	// How the Option was matched, if it was read from a message.
+	Match MatchDetails
+}
+
+// MatchDetails describes how ChoiceFromMessage arrived at its Choice.
+type MatchDetails struct {
+	// The text in the message that matched one of the Option's aliases. Empty
+	// if the Option was chosen at random or not at all.
+	Alias string
+	// The byte offset of Alias within the message. Only meaningful if Alias is
+	// not empty.
+	Index int
+	// Whether the Option was chosen at random.
+	Random bool
+	// Whether the message mentioned any closed Options, which were ignored.
+	SkippedClosed bool
+	// Whether Alias is a misspelling of the Option's name or alias.
+	Fuzzy bool
+	// If the message named an Option in a locked contest, that Option's short
+	// code. The Choice's Option is then the contest's overflow option.
+	OverflowFrom string
 }
 
 type ChoiceReason int
@@ -105,54 +274,310 @@ const (
 	FromSubMessage
 	// The bid choice was read from an explicit !bid command.
 	FromBidCommand
+	// The bid choice was read from the donor's name (e.g., "Bob4Moo").
+	FromDonorName
 )
 
 // AllOpenOptions returns a list of all open Options in all open Contests.
 func (c Collection) AllOpenOptions() []Option {
 	var opts []Option
 	for _, con := range c.Contests {
-		if con.Closed {
+		opts = append(opts, con.openOptions()...)
+	}
+	return opts
+}
+
+// OpenContests returns the Contests that are open and have open Options. Each
+// Contest only has its open Options.
+func (c Collection) OpenContests() []Contest {
+	var cons []Contest
+	for _, con := range c.Contests {
+		if opts := con.openOptions(); len(opts) > 0 {
+			con.Options = opts
+			cons = append(cons, con)
+		}
+	}
+	return cons
+}
+
+// openOptions returns the open Options in this Contest. If the Contest itself
+// is closed, returns nothing.
+func (con Contest) openOptions() []Option {
+	if con.Closed {
+		return nil
+	}
+	var opts []Option
+	for _, opt := range con.Options {
+		if opt.Closed {
 			continue
 		}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+// closedOptions returns all the Options that are closed, or that belong to a
+// closed Contest.
+func (c Collection) closedOptions() []Option {
+	var opts []Option
+	for _, con := range c.Contests {
 		for _, opt := range con.Options {
-			if opt.Closed {
-				continue
+			if con.Closed || opt.Closed {
+				opts = append(opts, opt)
 			}
-			opts = append(opts, opt)
 		}
 	}
 	return opts
 }
 
+// priorityContest returns the open Contest whose priority phrase appears
+// earliest in the message, if any.
+func (c Collection) priorityContest(msg string) (Contest, bool) {
+	minIndex := -1
+	var minCon Contest
+	for _, con := range c.Contests {
+		if con.Closed {
+			continue
+		}
+		for _, p := range con.PriorityPhrases {
+			if loc := p.FindStringIndex(msg); loc != nil {
+				if minIndex > loc[0] || minIndex < 0 {
+					minIndex = loc[0]
+					minCon = con
+				}
+			}
+		}
+	}
+	return minCon, minIndex >= 0
+}
+
 // ChoiceFromMessage determines whether the given donation message or chat
 // message mentioned one of the bid war options in this Collection, and
 // returns a Choice representing that Option. If no bid war option was found,
 // returns a Choice with the zero Option (but possibly non-zero Reason). If
 // more than one Option matches, returns the match that occurs earliest
-// (leftmost) in the message.
+// (leftmost) in the message. If the message contains a contest's priority
+// phrase, only that contest's Options are considered.
 func (c Collection) ChoiceFromMessage(msg string, reason ChoiceReason) Choice {
 	if c.RequireExplicitBid && reason != FromBidCommand {
-		return Choice{}
+		return Choice{Reason: c.reasonString(reason, msg)}
 	}
-	minIndex := -1
-	minOpt := Option{}
 	openOptions := c.AllOpenOptions()
-	for _, opt := range openOptions {
+	if con, ok := c.priorityContest(msg); ok {
+		openOptions = con.openOptions()
+	}
+	minOpt, match := leftmostMatch(openOptions, msg)
+	closedOpt, _ := leftmostMatch(c.closedOptions(), msg)
+	if minOpt.IsZero() && closedOpt.IsZero() && c.FuzzyMatch && reason != FromDonorName {
+		minOpt, match = fuzzyMatch(openOptions, msg, c.fuzzyThreshold())
+	}
+	// Don't let somebody named "RandomGuy" make random bids by accident.
+	if minOpt.IsZero() && reason != FromDonorName && len(openOptions) > 0 && randomDirective.MatchString(msg) {
+		randIdx := c.Random.Intn(len(openOptions))
+		minOpt = openOptions[randIdx]
+		match.Random = true
+	}
+	choice := c.withOverflow(Choice{Option: minOpt, Reason: c.reasonString(reason, msg), Match: match})
+	choice.Match.SkippedClosed = !closedOpt.IsZero()
+	if minOpt.IsZero() {
+		choice.ClosedOption = closedOpt
+	}
+	return choice
+}
+
+// leftmostMatch returns the Option with an alias that appears earliest in the
+// message, and where it appears. If no Option matches, returns the zero
+// Option.
+func leftmostMatch(opts []Option, msg string) (Option, MatchDetails) {
+	var minLoc []int
+	minOpt := Option{}
+	for _, opt := range opts {
 		for _, a := range opt.Aliases {
 			if loc := a.FindStringIndex(msg); loc != nil {
-				idx := loc[0]
-				if minIndex > idx || minIndex < 0 {
-					minIndex = idx
+				if minLoc == nil || minLoc[0] > loc[0] {
+					minLoc = loc
 					minOpt = opt
 				}
 			}
 		}
 	}
-	if minIndex < 0 && randomDirective.MatchString(msg) {
-		randIdx := rand.Intn(len(openOptions))
-		minOpt = openOptions[randIdx]
+	if minLoc == nil {
+		return Option{}, MatchDetails{}
 	}
-	return Choice{Option: minOpt, Reason: reasonString(reason, msg)}
+	return minOpt, MatchDetails{Alias: msg[minLoc[0]:minLoc[1]], Index: minLoc[0]}
+}
+
+// ChoiceFromDonorName looks for a bid war option in a donor's name. Some tip
+// pages only have a name field, so donors write their choice there (e.g.,
+// "Bob4Moo"). Returns the zero Choice if no Option was found.
+func (c Collection) ChoiceFromDonorName(name string) Choice {
+	choice := c.ChoiceFromMessage(splitDonorName(name), FromDonorName)
+	if choice.Option.IsZero() {
+		return Choice{}
+	}
+	choice.Reason = c.reasonString(FromDonorName, name)
+	return choice
+}
+
+// splitDonorName breaks up a name into words, so that aliases can match
+// pieces of it. "Bob4Moo" becomes "Bob4 Moo", and "neo_bowser_fan" becomes
+// "neo bowser fan".
+func splitDonorName(name string) string {
+	var sb strings.Builder
+	var prev rune
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '-' || r == '.':
+			r = ' '
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			sb.WriteRune(' ')
+		}
+		sb.WriteRune(r)
+		prev = r
+	}
+	return sb.String()
+}
+
+// ContestByName returns the Contest with the given name (case-insensitive),
+// whether or not it is open.
+func (c Collection) ContestByName(name string) (Contest, bool) {
+	for _, con := range c.Contests {
+		if strings.EqualFold(con.Name, strings.TrimSpace(name)) {
+			return con, true
+		}
+	}
+	return Contest{}, false
+}
+
+// FindOption returns the Option with an alias that appears earliest in the
+// message, whether or not it is open.
+func (c Collection) FindOption(msg string) (Option, bool) {
+	var opts []Option
+	for _, con := range c.Contests {
+		opts = append(opts, con.Options...)
+	}
+	opt, _ := leftmostMatch(opts, msg)
+	return opt, !opt.IsZero()
+}
+
+// OptionByShortCode returns the Option with the given short code
+// (case-insensitive), whether or not it is open.
+func (c Collection) OptionByShortCode(code string) (Option, bool) {
+	for _, con := range c.Contests {
+		for _, opt := range con.Options {
+			if strings.EqualFold(opt.ShortCode, strings.TrimSpace(code)) {
+				return opt, true
+			}
+		}
+	}
+	return Option{}, false
+}
+
+// SetContestClosed returns a copy of the Collection in which the named
+// Contest is closed (or opened). When a contest is closed, its ClosesAt is
+// set to now, unless it was scheduled to close earlier. When a contest is
+// reopened after its ClosesAt, the ClosesAt is cleared, so that it isn't
+// closed again automatically. Returns false if there is no such Contest.
+func (c Collection) SetContestClosed(name string, closed bool, now time.Time) (Collection, bool) {
+	c = c.clone()
+	for i := range c.Contests {
+		con := &c.Contests[i]
+		if !strings.EqualFold(con.Name, strings.TrimSpace(name)) {
+			continue
+		}
+		con.Closed = closed
+		if closed && (con.ClosesAt.IsZero() || con.ClosesAt.After(now)) {
+			con.ClosesAt = now
+		}
+		if !closed && !con.ClosesAt.After(now) {
+			con.ClosesAt = time.Time{}
+		}
+		return c, true
+	}
+	return c, false
+}
+
+// SetOptionClosed returns a copy of the Collection in which the Option with
+// the given short code is closed (or opened). Returns false if there is no
+// such Option.
+func (c Collection) SetOptionClosed(code string, closed bool) (Collection, bool) {
+	c = c.clone()
+	for i := range c.Contests {
+		for j := range c.Contests[i].Options {
+			opt := &c.Contests[i].Options[j]
+			if strings.EqualFold(opt.ShortCode, strings.TrimSpace(code)) {
+				opt.Closed = closed
+				return c, true
+			}
+		}
+	}
+	return c, false
+}
+
+// AddOption returns a copy of the Collection with a new Option added to the
+// named Contest. The Option's short code must not already be in use. If the
+// Option has no aliases, they are generated from its name. Note that the
+// spreadsheet won't report a total for the Option until it is added there
+// too.
+func (c Collection) AddOption(contestName string, opt Option) (Collection, error) {
+	if opt.IsZero() {
+		return c, errors.New("option must have a short code")
+	}
+	if _, ok := c.OptionByShortCode(opt.ShortCode); ok {
+		return c, fmt.Errorf("there is already an option called %q", opt.ShortCode)
+	}
+	if len(opt.Aliases) == 0 {
+		opt.Aliases = c.autoAliases(opt)
+	}
+	c = c.clone()
+	for i := range c.Contests {
+		if strings.EqualFold(c.Contests[i].Name, strings.TrimSpace(contestName)) {
+			c.Contests[i].Options = append(c.Contests[i].Options, opt)
+			return c, nil
+		}
+	}
+	return c, fmt.Errorf("there is no contest called %q", contestName)
+}
+
+// RemoveOption returns a copy of the Collection without the Option that has
+// the given short code. Returns false if there is no such Option.
+func (c Collection) RemoveOption(code string) (Collection, bool) {
+	c = c.clone()
+	for i := range c.Contests {
+		opts := c.Contests[i].Options
+		for j := range opts {
+			if strings.EqualFold(opts[j].ShortCode, strings.TrimSpace(code)) {
+				c.Contests[i].Options = append(opts[:j], opts[j+1:]...)
+				return c, true
+			}
+		}
+	}
+	return c, false
+}
+
+// clone returns a copy of the Collection whose Contests and Options can be
+// modified without affecting the original.
+func (c Collection) clone() Collection {
+	contests := make([]Contest, len(c.Contests))
+	for i, con := range c.Contests {
+		con.Options = append([]Option(nil), con.Options...)
+		contests[i] = con
+	}
+	c.Contests = contests
+	return c
+}
+
+// ContestForOption returns the Contest that contains the given Option,
+// whether or not it is open.
+func (c Collection) ContestForOption(o Option) (Contest, bool) {
+	for _, con := range c.Contests {
+		for _, opt := range con.Options {
+			if opt.ShortCode == o.ShortCode {
+				return con, true
+			}
+		}
+	}
+	return Contest{}, false
 }
 
 // FindContest returns the open Contest that contains the given Option. If no
@@ -172,7 +597,8 @@ func (c Collection) FindContest(o Option) Contest {
 	return Contest{}
 }
 
-func reasonString(reason ChoiceReason, msg string) string {
+func (c Collection) reasonString(reason ChoiceReason, msg string) string {
+	msg = c.ReasonOptions.Clean(msg)
 	if msg == "" {
 		return ""
 	}
@@ -183,12 +609,25 @@ func reasonString(reason ChoiceReason, msg string) string {
 		return "[donation msg] " + msg
 	case FromSubMessage:
 		return "[sub msg] " + msg
+	case FromDonorName:
+		return "[donor name] " + msg
 	}
 	return ""
 }
 
 type alias struct {
 	*regexp.Regexp
+	// The alias as written in the bid war data, before it was compiled.
+	src string
+}
+
+func newAlias(s string) (alias, error) {
+	// (?i) = case-insensitive; \b = ASCII word boundary
+	r, err := regexp.Compile(fmt.Sprintf(`(?i)\b%s\b`, s))
+	if err != nil {
+		return alias{}, fmt.Errorf("alias %v not suitable for regexp: %v", s, err)
+	}
+	return alias{Regexp: r, src: s}, nil
 }
 
 func (a *alias) UnmarshalJSON(b []byte) error {
@@ -196,27 +635,39 @@ func (a *alias) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
-	// (?i) = case-insensitive; \b = ASCII word boundary
-	r, err := regexp.Compile(fmt.Sprintf(`(?i)\b%s\b`, s))
+	na, err := newAlias(s)
 	if err != nil {
-		return fmt.Errorf("alias %v not suitable for regexp: %v", s, err)
+		return err
 	}
-	a.Regexp = r
+	*a = na
 	return nil
 }
 
+func (a alias) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.src)
+}
+
 func Parse(rawJson []byte) (Collection, error) {
 	var c Collection
 	if err := json.Unmarshal(rawJson, &c); err != nil {
 		return Collection{}, err
 	}
+	if c.FuzzyThreshold < 0 || c.FuzzyThreshold > 1 {
+		return Collection{}, fmt.Errorf("fuzzyThreshold must be between 0 and 1, got %v", c.FuzzyThreshold)
+	}
+	if err := c.validateOverflow(); err != nil {
+		return Collection{}, err
+	}
+	c.fillAliases()
 	return c, nil
 }
 
 // Total is the total money contributed towards the given bid war Option.
 type Total struct {
 	Option Option
-	Value  donation.CentsValue
+	// In the units of the contest's Metric: a number of cents for points, or
+	// a number of subs or bits otherwise.
+	Value donation.CentsValue
 }
 
 type byCents []Total
@@ -227,30 +678,120 @@ func (b byCents) Less(i, j int) bool { return b[i].Value.Cents() < b[j].Value.Ce
 
 // Totals is a series of bid war Totals.
 type Totals struct {
-	totals          []Total
-	summaryStyle    string
+	totals       []Total
+	summaryStyle SummaryStyle
+	// The contest's name and SummaryTemplate, if it has one.
+	contest         string
+	template        string
 	numberOfWinners int
+	tieDisplay      TieDisplay
+	emotes          Emotes
+	metric          Metric
 }
 
 // Describe returns a human-readable summary of the bid war. The description
 // will always mention the lastBid option, but may omit others for the sake
 // of brevity.
 func (tt Totals) Describe(lastBid Option) string {
+	if tt.template != "" {
+		if desc, ok := tt.describeTemplate(lastBid); ok {
+			return desc
+		}
+	}
+	var parts []string
+	if desc := tt.describeStyle(lastBid); desc != "" {
+		parts = append(parts, desc)
+	}
+	if !tt.showsTotal(lastBid) {
+		if note := tt.targetNote(lastBid); note != "" {
+			parts = append(parts, note)
+		}
+	}
+	return strings.Join(parts, ". ")
+}
+
+// showsTotal reports whether the summary style lists the option's own total,
+// which already includes its progress towards its target.
+func (tt Totals) showsTotal(opt Option) bool {
+	open := tt.openTotals()
+	var listed bool
+	for _, t := range open {
+		if t.Option.ShortCode == opt.ShortCode {
+			listed = true
+		}
+	}
+	if !listed {
+		return false
+	}
+	switch tt.summaryStyle {
+	case LastPlaceSummary, FirstPlaceSummary, WinnersSummary:
+		// These only list a total when one option is alone in the contest.
+		return len(open) == 1
+	case PercentageSummary:
+		return false
+	}
+	return true
+}
+
+// describeStyle describes the totals in the contest's summary style.
+func (tt Totals) describeStyle(lastBid Option) string {
 	switch tt.summaryStyle {
-	case "LAST_PLACE":
+	case LastPlaceSummary:
 		return tt.describeLastPlace(lastBid)
-	case "FIRST_PLACE":
+	case FirstPlaceSummary:
 		return tt.describeFirstPlace(lastBid)
-	case "WINNERS":
+	case WinnersSummary:
 		if tt.numberOfWinners == 1 {
 			return tt.describeFirstPlace(lastBid)
 		}
 		return tt.describeWinners(lastBid)
-	case "ALL":
+	case PercentageSummary:
+		return tt.describePercentage()
+	case AllSummary:
 	}
 	return tt.describeAll()
 }
 
+// Standings lists every Option in the Contest with its total, winning Option
+// first. Options that nobody has bid on are included with a zero total.
+func (tt Totals) Standings(contest Contest) []Total {
+	return contestTotals(contest, withZeroTotals(contest, tt.totals)).totals
+}
+
+// Format formats a total in the units of the contest's Metric.
+func (tt Totals) Format(v donation.CentsValue) string {
+	return tt.metric.Format(v)
+}
+
+// formatTotal formats an option's total, with its progress towards its
+// target if it has one (e.g., "62.00 / 100.00").
+func (tt Totals) formatTotal(opt Option, v donation.CentsValue) string {
+	if opt.Target <= 0 {
+		return tt.metric.Format(v)
+	}
+	s := fmt.Sprintf("%s / %s", tt.metric.Format(v), tt.metric.Format(opt.Target))
+	if v >= opt.Target {
+		s += " (reached!)"
+	}
+	return s
+}
+
+// targetNote describes the option's progress towards its target, e.g.
+// "Moo Moo Meadows: 62.00 / 100.00". Returns "" if the option has no
+// target.
+func (tt Totals) targetNote(opt Option) string {
+	if opt.IsZero() || opt.Target <= 0 {
+		return ""
+	}
+	var v donation.CentsValue
+	for _, t := range tt.totals {
+		if t.Option.ShortCode == opt.ShortCode {
+			v = t.Value
+		}
+	}
+	return fmt.Sprintf("%s: %s", opt.DisplayName, tt.formatTotal(opt, v))
+}
+
 func (tt Totals) openTotals() []Total {
 	var o []Total
 	for _, t := range tt.totals {
@@ -270,9 +811,9 @@ func (tt Totals) describeAll() string {
 	}
 	var totalStrs []string
 	for _, t := range tt.openTotals() {
-		s := fmt.Sprintf("%s: %s", t.Option.DisplayName, t.Value)
+		s := fmt.Sprintf("%s: %s", t.Option.DisplayName, tt.formatTotal(t.Option, t.Value))
 		if t.Value < maxValue {
-			s += fmt.Sprintf(" (down by %s)", maxValue-t.Value)
+			s += fmt.Sprintf(" (down by %s)", tt.metric.Format(maxValue-t.Value))
 		}
 		totalStrs = append(totalStrs, s)
 	}
@@ -318,7 +859,7 @@ func (tt Totals) describeLastPlace(lastBid Option) string {
 		return ""
 	} else if len(ranks) == 1 {
 		if opts := ranks[0].options; len(opts) == 1 {
-			return fmt.Sprintf("%s: %s", opts[0].DisplayName, ranks[0].value)
+			return fmt.Sprintf("%s: %s", opts[0].DisplayName, tt.formatTotal(opts[0], ranks[0].value))
 		}
 	}
 
@@ -336,7 +877,7 @@ func (tt Totals) describeLastPlace(lastBid Option) string {
 	for _, opt := range lastPlaceRank.options {
 		lastPlaceOptNames = append(lastPlaceOptNames, opt.DisplayName)
 	}
-	desc += fmt.Sprintf("%s (down by %s)", strings.Join(lastPlaceOptNames, ", "), diff)
+	desc += fmt.Sprintf("%s (down by %s)", strings.Join(lastPlaceOptNames, ", "), tt.metric.Format(diff))
 	if lastBid.IsZero() {
 		return desc
 	}
@@ -349,7 +890,7 @@ func (tt Totals) describeLastPlace(lastBid Option) string {
 	// A special message for when the bidder's choice was in last place, and
 	// remains alone in last place despite their efforts.
 	if len(lastPlaceRank.options) == 1 && lastBidIsLastPlace {
-		return fmt.Sprintf("%s is still in last place (down by %s) usedShame", lastBid.DisplayName, diff)
+		return WithEmote(fmt.Sprintf("%s is still in last place (down by %s)", lastBid.DisplayName, tt.metric.Format(diff)), tt.emotes.StillLastPlace)
 	}
 	if lastBidIsLastPlace {
 		return desc
@@ -363,7 +904,7 @@ func (tt Totals) describeFirstPlace(lastBid Option) string {
 		return ""
 	} else if len(ranks) == 1 {
 		if opts := ranks[0].options; len(opts) == 1 {
-			return fmt.Sprintf("%s: %s", opts[0].DisplayName, ranks[0].value)
+			return fmt.Sprintf("%s: %s", opts[0].DisplayName, tt.formatTotal(opts[0], ranks[0].value))
 		}
 	}
 
@@ -381,7 +922,7 @@ func (tt Totals) describeFirstPlace(lastBid Option) string {
 	for _, opt := range firstPlaceRank.options {
 		firstPlaceOptNames = append(firstPlaceOptNames, opt.DisplayName)
 	}
-	desc += fmt.Sprintf("%s (up by %s)", strings.Join(firstPlaceOptNames, ", "), diff)
+	desc += fmt.Sprintf("%s (up by %s)", strings.Join(firstPlaceOptNames, ", "), tt.metric.Format(diff))
 	if lastBid.IsZero() {
 		return desc
 	}
@@ -393,7 +934,7 @@ func (tt Totals) describeFirstPlace(lastBid Option) string {
 	lastBidIsFirstPlace := lastBidRank.rank == firstPlaceRank.rank
 	// A special message for when the bidder's choice is alone in first place.
 	if len(firstPlaceRank.options) == 1 && lastBidIsFirstPlace {
-		return fmt.Sprintf("%s is in first place (up by %s) usedU", lastBid.DisplayName, diff)
+		return WithEmote(fmt.Sprintf("%s is in first place (up by %s)", lastBid.DisplayName, tt.metric.Format(diff)), tt.emotes.FirstPlace)
 	}
 	if lastBidIsFirstPlace {
 		return desc
@@ -407,21 +948,28 @@ func (tt Totals) describeWinners(lastBid Option) string {
 		return ""
 	} else if len(ranks) == 1 {
 		if opts := ranks[0].options; len(opts) == 1 {
-			return fmt.Sprintf("%s: %s", opts[0].DisplayName, ranks[0].value)
+			return fmt.Sprintf("%s: %s", opts[0].DisplayName, tt.formatTotal(opts[0], ranks[0].value))
 		}
 	}
 
-	var leadingOptNames []string
+	var leadingOptNames, tiedOptNames []string
 	for _, r := range ranks {
+		var names []string
 		for _, opt := range r.options {
-			leadingOptNames = append(leadingOptNames, opt.DisplayName)
+			names = append(names, opt.DisplayName)
+		}
+		if len(leadingOptNames)+len(names) > tt.numberOfWinners && len(names) > 1 {
+			// These options are tied for the last winning spot(s).
+			tiedOptNames = names
+			break
 		}
+		leadingOptNames = append(leadingOptNames, names...)
 		if len(leadingOptNames) >= tt.numberOfWinners {
 			break
 		}
 	}
 
-	desc := fmt.Sprintf("Current top %d: %s", tt.numberOfWinners, strings.Join(leadingOptNames, ", "))
+	desc := tt.tieDisplay.describeTopN(tt.numberOfWinners, leadingOptNames, tiedOptNames)
 	if lastBid.IsZero() {
 		return desc
 	}
@@ -432,6 +980,24 @@ func (tt Totals) describeWinners(lastBid Option) string {
 	return fmt.Sprintf("%s is currently #%d. %s", lastBid.DisplayName, lastBidRank.rank, desc)
 }
 
+// WithEmote appends an emote to a message, if the emote is set.
+func WithEmote(msg string, emote string) string {
+	if emote == "" {
+		return msg
+	}
+	return msg + " " + emote
+}
+
+// Leaders returns the open Options that are currently in first place. More
+// than one Option is returned if there is a tie.
+func (tt Totals) Leaders() []Option {
+	ranks := tt.computeRanks()
+	if len(ranks) == 0 {
+		return nil
+	}
+	return ranks[0].options
+}
+
 func findRankForBid(ranks []*optionRank, bid Option) *optionRank {
 	for _, r := range ranks {
 		for _, opt := range r.options {
@@ -443,11 +1009,23 @@ func findRankForBid(ranks []*optionRank, bid Option) *optionRank {
 	return nil
 }
 
+// Contribution is the total amount that one donor gave to an Option.
+type Contribution struct {
+	Contributor string
+	Value       donation.CentsValue
+}
+
 // UpdateStats summarizes the changes made to a bid war.
 type UpdateStats struct {
 	Choice     Choice
 	Count      int
 	TotalValue donation.CentsValue
+	// How much of TotalValue went over the contest's donor cap, and so
+	// doesn't count.
+	OverCap donation.CentsValue
+	// The indexes in the donation table of the rows that were assigned. Only
+	// set by AssignToChoice and AssignFromMessage; see Tallier.Reassign.
+	Rows []int
 }
 
 // Tallier assigns donations to bid war options and reports bid totals.
@@ -455,22 +1033,118 @@ type Tallier struct {
 	sheetsSrv     *sheets.Service
 	table         *googlesheets.DonationTable
 	spreadsheetID string
-	collection    Collection
+	// Holds the current Collection. It can be replaced while the bot is
+	// running; see SetCollection.
+	collection *atomic.Value
+	// Links tip names to Twitch usernames. May be nil.
+	identities *identity.Map
+	// Looks up Twitch user IDs, to find the donations that a user made under
+	// an old username. May be nil.
+	userIDs UserIDs
+	// Caches the results of GetTotals. May be nil.
+	totalsCache *totalsCache
+	// Called when ApplyCap flags a donor's bids. May be nil.
+	capCallback func(donor string, con Contest, rows int, over donation.CentsValue)
 }
 
 // NewTallier creates a Tallier.
 func NewTallier(srv *sheets.Service, table *googlesheets.DonationTable, spreadsheetID string, collection Collection) *Tallier {
-	return &Tallier{
+	t := &Tallier{
 		sheetsSrv:     srv,
 		table:         table,
 		spreadsheetID: spreadsheetID,
-		collection:    collection,
+		collection:    &atomic.Value{},
+	}
+	t.SetCollection(collection)
+	return t
+}
+
+// SetCollection replaces the bid wars that the Tallier knows about.
+func (t Tallier) SetCollection(c Collection) {
+	t.collection.Store(c)
+	t.totalsCache.invalidate()
+}
+
+// SetTotalsTTL makes GetTotals reuse the totals it read within the last ttl,
+// unless the donation table has been written since. It must be called before
+// the Tallier is used. A ttl of 0 turns off caching.
+func (t *Tallier) SetTotalsTTL(ttl time.Duration) {
+	t.totalsCache = nil
+	if ttl > 0 {
+		t.totalsCache = newTotalsCache(ttl)
+	}
+}
+
+// SetIdentities makes the Tallier credit a Twitch user with the donations made
+// under their linked tip names. It must be called before the Tallier is used.
+func (t *Tallier) SetIdentities(m *identity.Map) {
+	t.identities = m
+}
+
+// UserIDs looks up the Twitch user ID for a username. KnownUserID must not
+// wait on Twitch, since it's called while a !bid is being handled; it returns
+// "" if the ID isn't known yet.
+type UserIDs interface {
+	KnownUserID(login string) string
+}
+
+// SetUserIDs makes the Tallier credit a Twitch user with the donations that
+// were recorded with their user ID under a different name (e.g., before they
+// renamed themselves). This only works if the donation table records owner
+// IDs. It must be called before the Tallier is used.
+func (t *Tallier) SetUserIDs(ids UserIDs) {
+	t.userIDs = ids
+}
+
+// donorNames returns all the names that a donor's donations in the table
+// are under: their linked tip names (see SetIdentities), and any other
+// usernames recorded with the same Twitch user ID.
+func (t Tallier) donorNames(donor string, vr *sheets.ValueRange) []string {
+	names := t.identities.Names(donor)
+	if t.userIDs == nil {
+		return names
+	}
+	id := t.userIDs.KnownUserID(donor)
+	if id == "" {
+		return names
+	}
+	seen := make(map[string]bool)
+	for _, n := range names {
+		seen[strings.ToLower(n)] = true
 	}
+	for _, row := range vr.Values {
+		dr := donationRow(row)
+		if dr.OwnerID() != id || seen[strings.ToLower(dr.Contributor())] {
+			continue
+		}
+		seen[strings.ToLower(dr.Contributor())] = true
+		names = append(names, dr.Contributor())
+	}
+	return names
+}
+
+func (t Tallier) bidwars() Collection {
+	return t.collection.Load().(Collection)
 }
 
 // GetTotals looks up the current total for each bid war Option. The totals
-// are returned in arbitrary order.
+// are returned in arbitrary order. They may come from the cache (see
+// SetTotalsTTL).
 func (t Tallier) GetTotals() ([]Total, error) {
+	writes := t.table.Writes()
+	if totals, ok := t.totalsCache.lookup(writes, time.Now()); ok {
+		return totals, nil
+	}
+	totals, err := t.fetchTotals()
+	if err != nil {
+		return nil, err
+	}
+	t.totalsCache.store(totals, writes, time.Now())
+	return totals, nil
+}
+
+// fetchTotals reads the total for each bid war Option from the spreadsheet.
+func (t Tallier) fetchTotals() ([]Total, error) {
 	getReq := &sheets.BatchGetValuesByDataFilterRequest{
 		DataFilters: []*sheets.DataFilter{
 			{
@@ -506,7 +1180,7 @@ func (t Tallier) GetTotals() ([]Total, error) {
 	}
 
 	optsMap := make(map[string]Option)
-	for _, contest := range t.collection.Contests {
+	for _, contest := range t.bidwars().Contests {
 		for _, option := range contest.Options {
 			optsMap[option.ShortCode] = option
 		}
@@ -527,13 +1201,13 @@ func (t Tallier) GetTotals() ([]Total, error) {
 			if opt, ok = optsMap[n]; !ok {
 				continue
 			}
-			dollars, err := strconv.ParseFloat(v, 64)
+			cents, err := money.ParseCents(v)
 			if err != nil {
 				return nil, fmt.Errorf("invalid total for %v: %v", n, v)
 			}
 			totals = append(totals, Total{
 				Option: opt,
-				Value:  donation.CentsValue(int(math.Round(dollars * 100))),
+				Value:  donation.CentsValue(cents),
 			})
 		}
 	}
@@ -542,21 +1216,32 @@ func (t Tallier) GetTotals() ([]Total, error) {
 
 // AssignFromMessage detects a donor's choice from a chat message and assigns
 // the donor's previous bids to the chosen Option. If the message does not
-// correspond to a known Option, returns the zero value (but no error).
+// correspond to a known open Option, returns UpdateStats with a zero Option
+// (but no error).
 func (t Tallier) AssignFromMessage(donor string, message string) (UpdateStats, error) {
 	if donor == "" {
 		return UpdateStats{}, errors.New("donor must not be empty")
 	}
-	choice := t.collection.ChoiceFromMessage(message, FromBidCommand)
+	choice := t.bidwars().ChoiceFromMessage(message, FromBidCommand)
 	if choice.Option.IsZero() {
-		return UpdateStats{}, nil
+		return UpdateStats{Choice: choice}, nil
+	}
+	return t.AssignToChoice(donor, choice)
+}
+
+// AssignToChoice assigns the donor's unassigned bids to the given Choice,
+// whether or not its Option is open. This is for when a mod accepts bids that
+// arrived after their contest closed.
+func (t Tallier) AssignToChoice(donor string, choice Choice) (UpdateStats, error) {
+	if donor == "" {
+		return UpdateStats{}, errors.New("donor must not be empty")
 	}
 	valueRange, err := t.table.GetTable()
 	if err != nil {
 		return UpdateStats{}, fmt.Errorf("error reading donation table: %v", err)
 	}
 
-	vrToWrite, matchedRows := makeChoice(valueRange, donor, choice)
+	vrToWrite, matchedRows := makeChoice(valueRange, t.donorNames(donor, valueRange), choice)
 
 	if len(matchedRows) > 0 {
 		rowCount, err := t.table.WriteTable(vrToWrite)
@@ -574,6 +1259,12 @@ func (t Tallier) AssignFromMessage(donor string, message string) (UpdateStats, e
 		Choice:     choice,
 		Count:      len(matchedRows),
 		TotalValue: donation.CentsValue(totalCents),
+		Rows:       editedRows(vrToWrite),
+	}
+	if len(matchedRows) > 0 {
+		if updateStats.OverCap, err = t.ApplyCap(donor, choice.Option); err != nil {
+			return updateStats, err
+		}
 	}
 
 	return updateStats, nil
@@ -582,10 +1273,99 @@ func (t Tallier) AssignFromMessage(donor string, message string) (UpdateStats, e
 // TotalsForContest returns the current bid war total for each Option in a
 // Contest, in descending order by value (i.e., the winning Option first).
 func (t Tallier) TotalsForContest(contest Contest) (Totals, error) {
+	if contest.Metric != PointsMetric {
+		vr, err := t.table.GetTable()
+		if err != nil {
+			return Totals{}, fmt.Errorf("error reading donation table: %v", err)
+		}
+		return contestTotals(contest, metricTotals(contest, vr.Values)), nil
+	}
 	totals, err := t.GetTotals()
 	if err != nil {
 		return Totals{}, err
 	}
+	return contestTotals(contest, totals), nil
+}
+
+// Refresh re-reads the bid war totals from the spreadsheet, for use after
+// the spreadsheet has been edited by hand. It returns the current Totals for
+// each open Contest, keyed by contest name.
+func (t Tallier) Refresh() (map[string]Totals, error) {
+	t.totalsCache.invalidate()
+	totals, err := t.GetTotals()
+	if err != nil {
+		return nil, err
+	}
+	byContest := make(map[string]Totals)
+	for _, contest := range t.bidwars().Contests {
+		if contest.Closed {
+			continue
+		}
+		if contest.Metric != PointsMetric {
+			if byContest[contest.Name], err = t.TotalsForContest(contest); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		byContest[contest.Name] = contestTotals(contest, totals)
+	}
+	return byContest, nil
+}
+
+// Contributors lists everyone who contributed to the given Option, and how
+// much they gave, in descending order by value.
+func (t Tallier) Contributors(opt Option) ([]Contribution, error) {
+	valueRange, err := t.table.GetTable()
+	if err != nil {
+		return nil, fmt.Errorf("error reading donation table: %v", err)
+	}
+	return contributorsFromTable(valueRange, opt.ShortCode), nil
+}
+
+// TopContributors returns the n donors who gave the most to the given Option,
+// biggest first. Donors who tie keep the order of their first donations.
+func (t Tallier) TopContributors(opt Option, n int) ([]Contribution, error) {
+	contribs, err := t.Contributors(opt)
+	if err != nil {
+		return nil, err
+	}
+	return topContributions(contribs, n), nil
+}
+
+func topContributions(contribs []Contribution, n int) []Contribution {
+	if len(contribs) > n {
+		contribs = contribs[:n]
+	}
+	return contribs
+}
+
+// contributorsFromTable adds up each donor's rows that were assigned to the
+// given short code. Donor names are compared case-insensitively; the first
+// spelling we see is the one that is returned.
+func contributorsFromTable(vr *sheets.ValueRange, shortCode string) []Contribution {
+	var contribs []Contribution
+	index := make(map[string]int)
+	for _, row := range vr.Values {
+		dr := donationRow(row)
+		if dr.Contributor() == "" || !strings.EqualFold(dr.Choice(), shortCode) {
+			continue
+		}
+		key := strings.ToLower(dr.Contributor())
+		i, ok := index[key]
+		if !ok {
+			i = len(contribs)
+			index[key] = i
+			contribs = append(contribs, Contribution{Contributor: dr.Contributor()})
+		}
+		contribs[i].Value += donation.CentsValue(dr.Cents())
+	}
+	sort.SliceStable(contribs, func(i, j int) bool { return contribs[i].Value > contribs[j].Value })
+	return contribs
+}
+
+// contestTotals picks out the Totals for the given Contest from a list of
+// all Totals.
+func contestTotals(contest Contest, totals []Total) Totals {
 	optsByName := make(map[string]Option)
 	for _, opt := range contest.Options {
 		optsByName[opt.ShortCode] = opt
@@ -596,27 +1376,32 @@ func (t Tallier) TotalsForContest(contest Contest) (Totals, error) {
 			totalsForContest = append(totalsForContest, tot)
 		}
 	}
-	sort.Sort(sort.Reverse(byCents(totalsForContest)))
+	sort.Stable(sort.Reverse(byCents(totalsForContest)))
 	return Totals{
 		totals:          totalsForContest,
 		summaryStyle:    contest.SummaryStyle,
+		contest:         contest.Name,
+		template:        contest.SummaryTemplate,
 		numberOfWinners: contest.NumberOfWinners,
-	}, nil
+		tieDisplay:      contest.TieDisplay,
+		emotes:          contest.Emotes,
+		metric:          contest.Metric,
+	}
 }
 
 // makeChoice decides which rows in the given ValueRange need to be edited in
 // order to implement the requested choice. It returns two values: a new
 // ValueRange describing how to update the spreadsheet, and a list of the
 // original values of the spreadsheet rows to be updated. We update each row
-// where the "Contributor" column matches the donor and the "Choice" column is
-// not already set.
-func makeChoice(vr *sheets.ValueRange, donor string, choice Choice) (*sheets.ValueRange, []donationRow) {
+// where the "Contributor" column matches one of the donor's names and the
+// "Choice" column is not already set.
+func makeChoice(vr *sheets.ValueRange, donorNames []string, choice Choice) (*sheets.ValueRange, []donationRow) {
 	newValues := make([][]interface{}, len(vr.Values))
 	var updatedRows []donationRow
 	for i, row := range vr.Values {
 		var newRow []interface{}
 		dr := donationRow(row)
-		if strings.EqualFold(dr.Contributor(), donor) && dr.Choice() == "" {
+		if isUnassignedFor(dr, donorNames) {
 			newRow = rowForChoice(choice)
 			updatedRows = append(updatedRows, dr)
 		} else {
@@ -633,6 +1418,18 @@ func makeChoice(vr *sheets.ValueRange, donor string, choice Choice) (*sheets.Val
 	return newVR, updatedRows
 }
 
+// editedRows returns the indexes of the rows that an edit from makeChoice (or
+// the like) changes.
+func editedRows(edit *sheets.ValueRange) []int {
+	var rows []int
+	for i, row := range edit.Values {
+		if len(row) > 0 {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
 // TODO(aerion): This is a little hacky for now. We could make this more
 // structured in the future if it needs to be more resistant to changes in
 // spreadsheet layout.
@@ -650,21 +1447,103 @@ func (d donationRow) Cents() int {
 	var cents int
 	switch v := d[2].(type) {
 	case string:
-		f, err := strconv.ParseFloat(v, 64)
+		var err error
+		cents, err = money.ParseCents(v)
 		if err != nil {
 			return 0
 		}
-		cents = int(math.Round(f * 100))
 	case float64:
-		cents = int(math.Round(v * 100))
+		cents = money.FromFloat(v)
 	}
 	return cents
 }
 
+func (d donationRow) What() string {
+	return d.column(1)
+}
+
 func (d donationRow) Choice() string {
 	return d.column(3)
 }
 
+func (d donationRow) Reason() string {
+	return d.column(4)
+}
+
+// OwnerID returns the donor's Twitch user ID, if the table records it (see
+// googlesheets.DonationTable.RecordOwnerID).
+func (d donationRow) OwnerID() string {
+	if len(d) < 8 {
+		return ""
+	}
+	// The sheet may have turned the ID into a number.
+	if f, ok := d[7].(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return d.column(7)
+}
+
+// Columns of the donation table past the reason: the first of the extra
+// columns (see googlesheets.DonationTable), and the net cash (see
+// googlesheets.DonationTable.RecordNet).
+const (
+	firstExtraColumn = 5
+	netColumn        = 9
+)
+
+// Net returns the row's net cash, in cents, if the table records it.
+func (d donationRow) Net() (int, bool) {
+	if len(d) <= netColumn {
+		return 0, false
+	}
+	switch v := d[netColumn].(type) {
+	case string:
+		cents, err := money.ParseCents(v)
+		return cents, err == nil
+	case float64:
+		return money.FromFloat(v), true
+	}
+	return 0, false
+}
+
+// partRow fills out a new row that carries partCents of this row's value.
+// The new row gets a copy of this row's extra columns (e.g., the source,
+// campaign, and owner ID), except that its net cash, if any, is its share of
+// this row's. Returns the new row and its net cash.
+func (d donationRow) partRow(newRow []interface{}, partCents int) ([]interface{}, int) {
+	if len(d) <= firstExtraColumn {
+		return newRow, 0
+	}
+	for len(newRow) < firstExtraColumn {
+		newRow = append(newRow, "")
+	}
+	newRow = append(newRow, d[firstExtraColumn:]...)
+	net, ok := d.Net()
+	if !ok {
+		return newRow, 0
+	}
+	partNet := 0
+	if total := d.Cents(); total > 0 {
+		partNet = net * partCents / total
+	}
+	newRow[netColumn] = float64(partNet) / 100
+	return newRow, partNet
+}
+
+// withNet adds an edit to the row's net cash to an edit of the row (as
+// written by WriteTable, where nil leaves a cell alone), if the table records
+// the net.
+func (d donationRow) withNet(edit []interface{}, net int) []interface{} {
+	if _, ok := d.Net(); !ok {
+		return edit
+	}
+	for len(edit) <= netColumn {
+		edit = append(edit, nil)
+	}
+	edit[netColumn] = float64(net) / 100
+	return edit
+}
+
 func (d donationRow) column(n int) string {
 	if n >= len(d) {
 		return ""
@@ -1,12 +1,16 @@
 package bidwar
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/sanitize"
 	"github.com/go-test/deep"
 	"google.golang.org/api/sheets/v4"
 )
@@ -32,6 +36,8 @@ const testJSON = `{
 }
 `
 
+var testEmotes = Emotes{StillLastPlace: "usedShame", FirstPlace: "usedU"}
+
 func TestChoiceFromMessage(t *testing.T) {
 	bidwars, err := Parse([]byte(testJSON))
 	if err != nil {
@@ -111,35 +117,42 @@ func TestMakeChoice(t *testing.T) {
 
 	for _, tc := range []struct {
 		desc       string
-		donor      string
+		donors     []string
 		choice     Choice
 		wantValues [][]interface{}
 		wantRows   []donationRow
 	}{
 		{
 			"updates one row",
-			"AEWC20XX",
+			[]string{"AEWC20XX"},
 			choice,
 			[][]interface{}{{}, {}, {nil, nil, nil, "Moo", "usedMoo"}, {}, {}},
 			[]donationRow{vr.Values[2]},
 		},
 		{
 			"updates all empty rows for donor",
-			"aerionblue",
+			[]string{"aerionblue"},
 			choice,
 			[][]interface{}{{}, {nil, nil, nil, "Moo", "usedMoo"}, {}, {nil, nil, nil, "Moo", "usedMoo"}, {}},
 			[]donationRow{vr.Values[1], vr.Values[3]},
 		},
 		{
 			"does not update header row",
-			"Contributor",
+			[]string{"Contributor"},
 			choice,
 			[][]interface{}{{}, {}, {}, {}, {}},
 			nil,
 		},
+		{
+			"updates rows for linked tip names",
+			[]string{"aewc20xx", "AerionBlue"},
+			choice,
+			[][]interface{}{{}, {nil, nil, nil, "Moo", "usedMoo"}, {nil, nil, nil, "Moo", "usedMoo"}, {nil, nil, nil, "Moo", "usedMoo"}, {}},
+			[]donationRow{vr.Values[1], vr.Values[2], vr.Values[3]},
+		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
-			gotVR, gotRows := makeChoice(vr, tc.donor, tc.choice)
+			gotVR, gotRows := makeChoice(vr, tc.donors, tc.choice)
 			if gotVR.Range != vr.Range {
 				t.Errorf("Range should be same as input: got %v, want %v", gotVR.Range, vr.Range)
 			}
@@ -185,6 +198,48 @@ func TestTotalsToString_AllStyle(t *testing.T) {
 	}
 }
 
+func TestTotalsDescribe_Target(t *testing.T) {
+	moo := Option{DisplayName: "Moo", ShortCode: "Moo", Target: 10000}
+	nbc := Option{DisplayName: "NBC", ShortCode: "NBC"}
+	for _, tc := range []struct {
+		desc    string
+		style   SummaryStyle
+		mooCent int
+		lastBid Option
+		want    string
+	}{
+		{"all style", AllSummary, 6200, moo, "Moo: 62.00 / 100.00 (down by 8.00), NBC: 70.00"},
+		{"target reached", AllSummary, 10000, Option{}, "Moo: 100.00 / 100.00 (reached!), NBC: 70.00 (down by 30.00)"},
+		{"last place style, bid on target option", LastPlaceSummary, 6200, moo, "Moo is still in last place (down by 8.00). Moo: 62.00 / 100.00"},
+		{"last place style, target reached", LastPlaceSummary, 10000, moo, "Moo is currently #1. Last place: NBC (down by 30.00). Moo: 100.00 / 100.00 (reached!)"},
+		{"percentage style", PercentageSummary, 3000, moo, "Moo 30%, NBC 70%. Moo: 30.00 / 100.00"},
+		{"last place style, bid on other option", LastPlaceSummary, 6200, nbc, "NBC is currently #1. Last place: Moo (down by 8.00)"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			totals := Totals{
+				totals:       []Total{{Option: moo, Value: donation.CentsValue(tc.mooCent)}, {Option: nbc, Value: 7000}},
+				summaryStyle: tc.style,
+			}
+			if got := totals.Describe(tc.lastBid); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// A lone option's total already shows its progress.
+	totals := Totals{totals: []Total{{Option: moo, Value: 6200}}, summaryStyle: FirstPlaceSummary}
+	if got, want := totals.Describe(moo), "Moo: 62.00 / 100.00"; got != want {
+		t.Errorf("only option: got %q, want %q", got, want)
+	}
+}
+
+func TestParse_NegativeTarget(t *testing.T) {
+	_, err := Parse([]byte(`{"contests": [{"name": "Mario Kart track", "options": [{"displayName": "Moo Moo Meadows", "shortCode": "Moo", "target": -100}]}]}`))
+	if err == nil {
+		t.Error("expected an error for a negative target")
+	}
+}
+
 func TestTotalsToString_LastPlaceStyle(t *testing.T) {
 	for _, tc := range []struct {
 		desc        string
@@ -225,7 +280,7 @@ func TestTotalsToString_LastPlaceStyle(t *testing.T) {
 			})
 		}
 		t.Run(tc.desc, func(t *testing.T) {
-			got := Totals{totals: totals, summaryStyle: "LAST_PLACE"}.Describe(lastBidOption)
+			got := Totals{totals: totals, summaryStyle: "LAST_PLACE", emotes: testEmotes}.Describe(lastBidOption)
 			if got != tc.want {
 				t.Errorf("got %q, want %q", got, tc.want)
 			}
@@ -272,7 +327,7 @@ func TestTotalsToString_FirstPlaceStyle(t *testing.T) {
 			})
 		}
 		t.Run(tc.desc, func(t *testing.T) {
-			got := Totals{totals: totals, summaryStyle: "FIRST_PLACE"}.Describe(lastBidOption)
+			got := Totals{totals: totals, summaryStyle: "FIRST_PLACE", emotes: testEmotes}.Describe(lastBidOption)
 			if got != tc.want {
 				t.Errorf("got %q, want %q", got, tc.want)
 			}
@@ -327,6 +382,44 @@ func TestTotalsToString_WinnersStyle(t *testing.T) {
 	}
 }
 
+func TestTotalsToString_WinnersTieDisplay(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		display     TieDisplay
+		winners     int
+		centsTotals []int
+		want        string
+	}{
+		{"list", ListTieDisplay, 2, []int{200, 200, 300}, "Current top 2: C, A, B"},
+		{"label", LabelTieDisplay, 2, []int{200, 200, 300}, "Current top 2: C; tie for the last spot: A, B"},
+		{"count", CountTieDisplay, 2, []int{200, 200, 300}, "Current top 2: C (2-way tie for the last spot)"},
+		{"label, 2 spots left", LabelTieDisplay, 3, []int{200, 200, 300, 200}, "Current top 3: C; tie for the last 2 spots: A, B, D"},
+		{"label, tie for every spot", LabelTieDisplay, 2, []int{300, 300, 300}, "Current top 2: tie for all spots: A, B, C"},
+		{"count, tie for every spot", CountTieDisplay, 2, []int{300, 300, 300}, "Current top 2: 3-way tie for all spots"},
+		{"label, tie inside the cutoff", LabelTieDisplay, 2, []int{200, 300, 300}, "Current top 2: B, C"},
+	} {
+		var totals []Total
+		for n, cents := range tc.centsTotals {
+			name := string(rune(int('A') + n))
+			totals = append(totals, Total{
+				Option: Option{DisplayName: name, ShortCode: name},
+				Value:  donation.CentsValue(cents),
+			})
+		}
+		t.Run(tc.desc, func(t *testing.T) {
+			got := Totals{
+				totals:          totals,
+				summaryStyle:    "WINNERS",
+				numberOfWinners: tc.winners,
+				tieDisplay:      tc.display,
+			}.Describe(Option{})
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestParseJSONConfig_DefaultValues(t *testing.T) {
 	bidwars, err := Parse([]byte(`{
 	    "contests": [
@@ -365,3 +458,498 @@ func TestParseJSONConfig_DefaultValues(t *testing.T) {
 		t.Errorf("wrong parsed value of NumberOfWinners: got %d, want 5", got)
 	}
 }
+
+func TestTotalsLeaders(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		centsTotals []int
+		want        []string
+	}{
+		{"one leader", []int{200, 100, 300}, []string{"C"}},
+		{"tie for first", []int{300, 100, 300}, []string{"A", "C"}},
+		{"zero options", []int{}, nil},
+	} {
+		var totals []Total
+		for n, cents := range tc.centsTotals {
+			name := string(rune(int('A') + n))
+			totals = append(totals, Total{
+				Option: Option{DisplayName: name, ShortCode: name},
+				Value:  donation.CentsValue(cents),
+			})
+		}
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []string
+			for _, opt := range (Totals{totals: totals}).Leaders() {
+				got = append(got, opt.ShortCode)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChoiceFromDonorName(t *testing.T) {
+	bidwars, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		name       string
+		want       string // The ShortCode of the wanted Option
+		wantReason string
+	}{
+		{"camel case", "Bob4Moo", "Moo", "[donor name] Bob4Moo"},
+		{"underscores", "team_nbc_forever", "NBC", "[donor name] team_nbc_forever"},
+		{"acronym after lowercase", "iLoveDMC3", "DMC3", "[donor name] iLoveDMC3"},
+		{"no match", "ShartyMcFly", "", ""},
+		{"random is not a directive in names", "RandomGuy", "", ""},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := bidwars.ChoiceFromDonorName(tc.name)
+			if got.Option.ShortCode != tc.want {
+				t.Errorf("got %q, want %q", got.Option.ShortCode, tc.want)
+			}
+			if got.Reason != tc.wantReason {
+				t.Errorf("wrong reason: got %q, want %q", got.Reason, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestChoiceFromMessage_ReasonSanitized(t *testing.T) {
+	bidwars, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	bidwars.ReasonOptions = sanitize.Options{MaxLength: 20, StripURLs: true}
+	got := bidwars.ChoiceFromMessage("moo!!\nsee https://example.com for more info", FromChatMessage)
+	if want := "[chat] moo!! see [link] fo…"; got.Reason != want {
+		t.Errorf("got reason %q, want %q", got.Reason, want)
+	}
+}
+
+func TestChoiceFromMessage_PriorityPhrases(t *testing.T) {
+	bidwars, err := Parse([]byte(`{
+	    "contests": [
+	        {
+	            "name": "Mario Kart track",
+	            "options": [
+	                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo"]},
+	                {"displayName": "Neo Bowser City", "shortCode": "NBC", "aliases": ["nbc"]}
+	            ]
+	        },
+	        {
+	            "name": "Devil May Cry",
+	            "priorityPhrases": ["all of it on", "everything on"],
+	            "options": [
+	                {"displayName": "Devil May Cry", "shortCode": "DMC1", "aliases": ["dmc", "dmc1"]},
+	                {"displayName": "Devil May Cry 3", "shortCode": "DMC3", "aliases": ["dmc3"]}
+	            ]
+	        }
+	    ]
+	}`))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		msg   string
+		wants []string // The ShortCodes of all acceptable Options
+	}{
+		{"no priority phrase uses leftmost match", "moo, or maybe dmc3", []string{"Moo"}},
+		{"priority phrase overrides leftmost match", "moo is fine but all of it on dmc3", []string{"DMC3"}},
+		{"priority phrase is case-insensitive", "nbc! EVERYTHING ON dmc", []string{"DMC1"}},
+		{"priority phrase without a matching option", "nbc, all of it on whatever", []string{""}},
+		{"random within priority contest", "moo. all of it on random", []string{"DMC1", "DMC3"}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := bidwars.ChoiceFromMessage(tc.msg, FromChatMessage)
+			for _, want := range tc.wants {
+				if got.Option.ShortCode == want {
+					return
+				}
+			}
+			t.Errorf("got %q, want one of %q", got.Option.ShortCode, tc.wants)
+		})
+	}
+}
+
+func TestChoiceFromMessage_ClosedOptions(t *testing.T) {
+	bidwars, err := Parse([]byte(`{
+	    "contests": [
+	        {
+	            "name": "Mario Kart track",
+	            "options": [
+	                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo"], "closed": true},
+	                {"displayName": "Neo Bowser City", "shortCode": "NBC", "aliases": ["nbc"]}
+	            ]
+	        },
+	        {
+	            "name": "Devil May Cry",
+	            "closed": true,
+	            "options": [
+	                {"displayName": "Devil May Cry", "shortCode": "DMC1", "aliases": ["dmc", "dmc1"]}
+	            ]
+	        }
+	    ]
+	}`))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		msg        string
+		want       string // The ShortCode of the wanted Option
+		wantClosed string // The ShortCode of the wanted ClosedOption
+	}{
+		{"closed option", "moo please", "", "Moo"},
+		{"option in closed contest", "dmc!", "", "DMC1"},
+		{"open option wins over closed option", "moo, or else nbc", "NBC", ""},
+		{"leftmost closed option", "dmc or moo", "", "DMC1"},
+		{"no match", "hello", "", ""},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := bidwars.ChoiceFromMessage(tc.msg, FromChatMessage)
+			if got.Option.ShortCode != tc.want {
+				t.Errorf("got %q, want %q", got.Option.ShortCode, tc.want)
+			}
+			if got.ClosedOption.ShortCode != tc.wantClosed {
+				t.Errorf("got closed option %q, want %q", got.ClosedOption.ShortCode, tc.wantClosed)
+			}
+		})
+	}
+}
+
+func TestOpenContests(t *testing.T) {
+	bidwars, err := Parse([]byte(`{
+	    "contests": [
+	        {
+	            "name": "Mario Kart track",
+	            "options": [
+	                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo"], "closed": true},
+	                {"displayName": "Neo Bowser City", "shortCode": "NBC", "aliases": ["nbc"]}
+	            ]
+	        },
+	        {
+	            "name": "Devil May Cry",
+	            "closed": true,
+	            "options": [
+	                {"displayName": "Devil May Cry", "shortCode": "DMC1", "aliases": ["dmc", "dmc1"]}
+	            ]
+	        },
+	        {
+	            "name": "Hat",
+	            "options": [
+	                {"displayName": "Red hat", "shortCode": "Red", "aliases": ["red"], "closed": true}
+	            ]
+	        }
+	    ]
+	}`))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	got := bidwars.OpenContests()
+	if len(got) != 1 || got[0].Name != "Mario Kart track" || len(got[0].Options) != 1 || got[0].Options[0].ShortCode != "NBC" {
+		t.Errorf("got %+v, want only Mario Kart track with NBC", got)
+	}
+	if len(bidwars.Contests[0].Options) != 2 {
+		t.Error("OpenContests should not change the Collection")
+	}
+}
+
+func TestChoiceFromMessage_MatchDetails(t *testing.T) {
+	bidwars, err := Parse([]byte(`{
+	    "contests": [
+	        {
+	            "name": "Mario Kart track",
+	            "options": [
+	                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo", "moomoo"]},
+	                {"displayName": "Neo Bowser City", "shortCode": "NBC", "aliases": ["neo", "nbc"]},
+	                {"displayName": "Rainbow Road", "shortCode": "RR", "aliases": ["rainbow"], "closed": true}
+	            ]
+	        }
+	    ]
+	}`))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+
+	for _, tc := range []struct {
+		desc string
+		msg  string
+		want MatchDetails
+	}{
+		{"no match", "hello", MatchDetails{}},
+		{"alias text keeps the message's case", "go MooMoo go", MatchDetails{Alias: "MooMoo", Index: 3}},
+		{"leftmost alias", "nbc or moo", MatchDetails{Alias: "nbc", Index: 0}},
+		{"random", "dealer's choice", MatchDetails{Random: true}},
+		{"closed option skipped", "rainbow road, or neo", MatchDetails{Alias: "neo", Index: 17, SkippedClosed: true}},
+		{"only closed option", "rainbow", MatchDetails{SkippedClosed: true}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := bidwars.ChoiceFromMessage(tc.msg, FromChatMessage)
+			if got.Match != tc.want {
+				t.Errorf("got %+v, want %+v", got.Match, tc.want)
+			}
+		})
+	}
+}
+
+func TestTotalsDescribe_NoEmotes(t *testing.T) {
+	totals := []Total{
+		{Option: Option{DisplayName: "A", ShortCode: "A"}, Value: donation.CentsValue(1000)},
+		{Option: Option{DisplayName: "B", ShortCode: "B"}, Value: donation.CentsValue(994)},
+	}
+	for _, tc := range []struct {
+		style   SummaryStyle
+		lastBid Option
+		want    string
+	}{
+		{"LAST_PLACE", totals[1].Option, "B is still in last place (down by 0.06)"},
+		{"FIRST_PLACE", totals[0].Option, "A is in first place (up by 0.06)"},
+	} {
+		got := Totals{totals: totals, summaryStyle: tc.style}.Describe(tc.lastBid)
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.style, got, tc.want)
+		}
+	}
+}
+
+func TestTotalsDescribe_Percentage(t *testing.T) {
+	for _, tc := range []struct {
+		centsTotals []int
+		want        string
+	}{
+		{[]int{620, 380}, "A 62%, B 38%"},
+		{[]int{100, 100, 100}, "A 33%, B 33%, C 33%"},
+		{[]int{0, 0}, "A 0%, B 0%"},
+		{[]int{}, ""},
+	} {
+		var totals []Total
+		for n, cents := range tc.centsTotals {
+			name := string(rune(int('A') + n))
+			totals = append(totals, Total{Option: Option{DisplayName: name, ShortCode: name}, Value: donation.CentsValue(cents)})
+		}
+		got := Totals{totals: totals, summaryStyle: PercentageSummary}.Describe(Option{})
+		if got != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.centsTotals, got, tc.want)
+		}
+	}
+}
+
+func TestParse_UnknownSummaryStyle(t *testing.T) {
+	_, err := Parse([]byte(`{"contests": [{"name": "Mario Kart track", "summaryStyle": "LOUD", "options": []}]}`))
+	if err == nil {
+		t.Error("expected an error for an unknown summary style")
+	}
+}
+
+func BenchmarkChoiceFromMessage(b *testing.B) {
+	bidwars, err := Parse([]byte(testJSON))
+	if err != nil {
+		b.Fatalf("error parsing test data: %v", err)
+	}
+	msg := "this is a long donation message, and somewhere near the end it mentions dmc3"
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bidwars.ChoiceFromMessage(msg, FromDonationMessage)
+	}
+}
+
+func TestContestInGracePeriod(t *testing.T) {
+	closesAt := time.Date(2022, 7, 1, 21, 0, 0, 0, time.UTC)
+	con := Contest{ClosesAt: closesAt, LateBidGraceSeconds: 60}
+	for _, tc := range []struct {
+		desc string
+		con  Contest
+		now  time.Time
+		want bool
+	}{
+		{"before close", con, closesAt.Add(-time.Second), false},
+		{"at close", con, closesAt, true},
+		{"during grace period", con, closesAt.Add(59 * time.Second), true},
+		{"after grace period", con, closesAt.Add(60 * time.Second), false},
+		{"no grace period", Contest{ClosesAt: closesAt}, closesAt, false},
+		{"no close time", Contest{LateBidGraceSeconds: 60}, closesAt, false},
+	} {
+		if got := tc.con.InGracePeriod(tc.now); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestContributorsFromTable(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message"},
+			{"aerionblue", "resub", "5.00", "Moo", "[chat] moo"},
+			{"AEWC20XX", "donation", "20.00", "Moo", "moo moo"},
+			{"AerionBlue", "200 bits", "2.00", "moo", "[chat] moo"},
+			{"aerionblue", "donation", "5.01", "Leon", "put this towards Leon"},
+			{"sharty", "resub", "5.00"},
+		},
+	}
+	got := contributorsFromTable(vr, "Moo")
+	want := []Contribution{
+		{Contributor: "AEWC20XX", Value: donation.CentsValue(2000)},
+		{Contributor: "aerionblue", Value: donation.CentsValue(700)},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(topContributions(got, 1), want[:1]); diff != nil {
+		t.Errorf("top 1: %v", diff)
+	}
+	if diff := deep.Equal(topContributions(got, 3), want); diff != nil {
+		t.Errorf("top 3: %v", diff)
+	}
+}
+
+func TestSetClosed(t *testing.T) {
+	orig, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	now := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+
+	c, ok := orig.SetContestClosed("mario kart track", true, now)
+	if !ok {
+		t.Fatalf("SetContestClosed didn't find the contest")
+	}
+	con, _ := c.ContestByName("Mario Kart track")
+	if !con.Closed || !con.ClosesAt.Equal(now) {
+		t.Errorf("got closed=%v closesAt=%v, want closed at %v", con.Closed, con.ClosesAt, now)
+	}
+	if origCon, _ := orig.ContestByName("Mario Kart track"); origCon.Closed {
+		t.Errorf("closing a contest modified the original Collection")
+	}
+	if _, ok := orig.SetContestClosed("Tetris", true, now); ok {
+		t.Errorf("SetContestClosed found a contest that doesn't exist")
+	}
+
+	c, ok = orig.SetOptionClosed("nbc", true)
+	if !ok {
+		t.Fatalf("SetOptionClosed didn't find the option")
+	}
+	if opt, _ := c.OptionByShortCode("NBC"); !opt.Closed {
+		t.Errorf("NBC should be closed")
+	}
+	if opt, _ := orig.OptionByShortCode("NBC"); opt.Closed {
+		t.Errorf("closing an option modified the original Collection")
+	}
+}
+
+func TestCollectionJSONRoundTrip(t *testing.T) {
+	orig, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	orig, _ = orig.SetContestClosed("Mario Kart track", true, time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC))
+	data, err := json.Marshal(orig)
+	if err != nil {
+		t.Fatalf("error marshaling: %v", err)
+	}
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatalf("error parsing marshaled data: %v\n%s", err, data)
+	}
+	if len(got.Contests) != len(orig.Contests) {
+		t.Fatalf("got %d contests, want %d", len(got.Contests), len(orig.Contests))
+	}
+	for i, con := range got.Contests {
+		want := orig.Contests[i]
+		if con.Name != want.Name || con.Closed != want.Closed || !con.ClosesAt.Equal(want.ClosesAt) || con.SummaryStyle != want.SummaryStyle {
+			t.Errorf("contest %d: got %+v, want %+v", i, con, want)
+		}
+		for j, opt := range con.Options {
+			wantOpt := want.Options[j]
+			if opt.ShortCode != wantOpt.ShortCode || opt.Closed != wantOpt.Closed || len(opt.Aliases) != len(wantOpt.Aliases) {
+				t.Errorf("option %d/%d: got %+v, want %+v", i, j, opt, wantOpt)
+			}
+		}
+	}
+	// The aliases should match the same text as before.
+	if opt, _ := got.FindOption("nbc please"); opt.ShortCode != "NBC" {
+		t.Errorf("got %q for \"nbc please\" after round trip, want NBC", opt.ShortCode)
+	}
+}
+
+func TestAddRemoveOption(t *testing.T) {
+	orig, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	opt, err := NewOption("Rainbow Road", "RR", []string{"rainbow road", "rr"})
+	if err != nil {
+		t.Fatalf("error creating option: %v", err)
+	}
+
+	c, err := orig.AddOption("mario kart track", opt)
+	if err != nil {
+		t.Fatalf("error adding option: %v", err)
+	}
+	if got := c.ChoiceFromMessage("all on rainbow road", FromChatMessage); got.Option.ShortCode != "RR" {
+		t.Errorf("got %q after adding RR, want RR", got.Option.ShortCode)
+	}
+	if got := orig.ChoiceFromMessage("all on rainbow road", FromChatMessage); !got.Option.IsZero() {
+		t.Errorf("adding an option modified the original Collection")
+	}
+	if _, err := c.AddOption("Mario Kart track", opt); err == nil {
+		t.Errorf("got no error adding a duplicate short code")
+	}
+	if _, err := orig.AddOption("Tetris", opt); err == nil {
+		t.Errorf("got no error adding to a contest that doesn't exist")
+	}
+	if _, err := NewOption("Bad", "Bad", []string{"(unclosed"}); err == nil {
+		t.Errorf("got no error for a malformed alias")
+	}
+
+	removed, ok := c.RemoveOption("rr")
+	if !ok {
+		t.Fatalf("RemoveOption didn't find RR")
+	}
+	if _, ok := removed.OptionByShortCode("RR"); ok {
+		t.Errorf("RR is still there after removing it")
+	}
+	if _, ok := c.OptionByShortCode("RR"); !ok {
+		t.Errorf("removing an option modified the original Collection")
+	}
+	if _, ok := removed.OptionByShortCode("Moo"); !ok {
+		t.Errorf("removing RR also removed Moo")
+	}
+}
+
+type fakeUserIDs map[string]string
+
+func (f fakeUserIDs) KnownUserID(login string) string {
+	return f[strings.ToLower(login)]
+}
+
+func TestDonorNames(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message", "Source", "Campaign", "Owner ID"},
+			{"OldName", "resub", "5.00", "", "", "twitch_sub", "", "1234"},
+			{"NewName", "100 bits", "1.00", "", "", "twitch_bits", "", float64(1234)},
+			{"usedpizza", "resub", "5.00", "", "", "twitch_sub", "", "5678"},
+			{"CoolDude99", "$5.00 donation", "5.00"},
+		},
+	}
+	tl := Tallier{}
+	if got, want := tl.donorNames("NewName", vr), []string{"NewName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without IDs: got %v, want %v", got, want)
+	}
+	tl.SetUserIDs(fakeUserIDs{"newname": "1234"})
+	if got, want := tl.donorNames("NewName", vr), []string{"NewName", "OldName"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with IDs: got %v, want %v", got, want)
+	}
+	if got, want := tl.donorNames("nobody", vr), []string{"nobody"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unknown user: got %v, want %v", got, want)
+	}
+}
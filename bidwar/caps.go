@@ -0,0 +1,116 @@
+package bidwar
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/money"
+)
+
+// Appended to the short code of a row that went over its contest's donor cap.
+// The spreadsheet only totals rows whose choice is exactly a short code, so
+// flagged rows don't count.
+const overCapSuffix = " (over cap)"
+
+// isOverCap reports whether a row's choice was flagged as over the donor cap,
+// and if so, returns the short code it was bid on.
+func isOverCap(choice string) (string, bool) {
+	if !strings.HasSuffix(choice, overCapSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(choice, overCapSuffix), true
+}
+
+// ApplyCap enforces the donor cap of opt's contest on the donor's bids (under
+// any of their linked names). Bids past the cap are flagged, splitting a row
+// if only part of it goes over. Returns how much was newly flagged.
+func (t Tallier) ApplyCap(donor string, opt Option) (donation.CentsValue, error) {
+	con, ok := t.bidwars().ContestForOption(opt)
+	if !ok || con.DonorCapCents <= 0 {
+		return 0, nil
+	}
+	valueRange, err := t.table.GetTable()
+	if err != nil {
+		return 0, fmt.Errorf("error reading donation table: %v", err)
+	}
+	names := t.donorNames(t.identities.TwitchUser(donor), valueRange)
+	vrToWrite, newRows, over := makeCap(valueRange, names, con)
+	if over == 0 {
+		return 0, nil
+	}
+	if _, err := t.table.WriteTable(vrToWrite); err != nil {
+		return 0, fmt.Errorf("error updating spreadsheet: %v", err)
+	}
+	if len(newRows) > 0 {
+		if err := t.table.AppendRows(newRows); err != nil {
+			return 0, fmt.Errorf("error appending over-cap rows; the cap for %s must be fixed by hand: %v", donor, err)
+		}
+	}
+	log.Printf("flagged %s from %s as over the %s cap", donation.CentsValue(over), donor, con.Name)
+	if t.capCallback != nil {
+		t.capCallback(donor, con, len(editedRows(vrToWrite)), donation.CentsValue(over))
+	}
+	return donation.CentsValue(over), nil
+}
+
+// OnCap sets a callback for each time ApplyCap flags a donor's bids, with
+// the contest, how many rows were flagged (each of which may have been cut
+// down, with the flagged part in a new row), and how much. It must be called
+// before the Tallier is used.
+func (t *Tallier) OnCap(cb func(donor string, con Contest, rows int, over donation.CentsValue)) {
+	t.capCallback = cb
+}
+
+// makeCap works out how to edit the donation table to enforce a contest's
+// donor cap. The donor's bids on the contest count in order until they reach
+// the cap; the rest are flagged. When a row straddles the cap, the row is cut
+// down to what counts, and the rest goes into a new, flagged row. It returns
+// a ValueRange with the edits to existing rows, the new rows to append, and
+// how much was flagged.
+func makeCap(vr *sheets.ValueRange, donorNames []string, con Contest) (*sheets.ValueRange, [][]interface{}, int) {
+	codes := make(map[string]bool)
+	for _, opt := range con.Options {
+		codes[strings.ToLower(opt.ShortCode)] = true
+	}
+	newValues := make([][]interface{}, len(vr.Values))
+	var newRows [][]interface{}
+	counted, over := 0, 0
+	for i, row := range vr.Values {
+		newValues[i] = []interface{}{}
+		dr := donationRow(row)
+		if !isDonor(dr, donorNames) || !codes[strings.ToLower(dr.Choice())] {
+			continue
+		}
+		cents := dr.Cents()
+		keep := con.DonorCapCents - counted
+		if keep >= cents {
+			counted += cents
+			continue
+		}
+		if keep < 0 {
+			keep = 0
+		}
+		flagged := dr.Choice() + overCapSuffix
+		if keep == 0 {
+			newValues[i] = []interface{}{nil, nil, nil, flagged}
+		} else {
+			newRow, overNet := dr.partRow([]interface{}{dr.Contributor(), dr.What() + " (over cap)", money.FormatCents(cents - keep), flagged, dr.Reason()}, cents-keep)
+			newRows = append(newRows, newRow)
+			newValues[i] = []interface{}{nil, nil, float64(keep) / 100}
+			if net, ok := dr.Net(); ok {
+				newValues[i] = dr.withNet(newValues[i], net-overNet)
+			}
+		}
+		counted += keep
+		over += cents - keep
+	}
+	return &sheets.ValueRange{
+		MajorDimension: vr.MajorDimension,
+		Range:          vr.Range,
+		Values:         newValues,
+	}, newRows, over
+}
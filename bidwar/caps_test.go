@@ -0,0 +1,83 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestMakeCap(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Range:          "Tracker!A:E",
+		MajorDimension: "ROWS",
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message"},
+			{"aerionblue", "resub", "5.00", "Moo", "moo"},
+			{"usedpizza", "donation", "50.00", "Moo", "moo"},
+			{"aerionblue", "donation", "4.00", "NBC (over cap)", "nbc"},
+			{"aerionblue", "donation", "10.00", "nbc", "nbc"},
+			{"aerionblue", "donation", "3.00", "Leon", "leon"},
+			{"aerionblue", "donation", "2.00", "Moo", "moo"},
+		},
+	}
+	con := Contest{
+		Name:          "Pizza",
+		Options:       []Option{{ShortCode: "Moo"}, {ShortCode: "NBC"}},
+		DonorCapCents: 1200,
+	}
+	gotVR, gotRows, gotOver := makeCap(vr, []string{"aerionblue"}, con)
+
+	wantValues := [][]interface{}{
+		{},
+		{},
+		{},
+		{},
+		{nil, nil, 7.0},
+		{},
+		{nil, nil, nil, "Moo (over cap)"},
+	}
+	if diff := deep.Equal(gotVR.Values, wantValues); diff != nil {
+		t.Errorf("edited rows: %v", diff)
+	}
+	wantRows := [][]interface{}{
+		{"aerionblue", "donation (over cap)", "3.00", "nbc (over cap)", "nbc"},
+	}
+	if diff := deep.Equal(gotRows, wantRows); diff != nil {
+		t.Errorf("new rows: %v", diff)
+	}
+	if gotOver != 500 {
+		t.Errorf("flagged %d cents, want 500", gotOver)
+	}
+}
+
+func TestMakeCap_ExtraColumns(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Range:          "Tracker!A:J",
+		MajorDimension: "ROWS",
+		Values: [][]interface{}{
+			{"aerionblue", "donation", 10.0, "Moo", "moo", "streamlabs", "Pizza", "1234", "2021-10-16 12:00:00", 9.41},
+			{"aerionblue", "resub", 5.0, "Moo", "moo", "twitch_sub", "Pizza", "1234", "2021-10-16 12:05:00", ""},
+		},
+	}
+	con := Contest{
+		Name:          "Pizza",
+		Options:       []Option{{ShortCode: "Moo"}},
+		DonorCapCents: 400,
+	}
+	gotVR, gotRows, _ := makeCap(vr, []string{"aerionblue"}, con)
+
+	wantValues := [][]interface{}{
+		{nil, nil, 4.0, nil, nil, nil, nil, nil, nil, 3.77},
+		{nil, nil, nil, "Moo (over cap)"},
+	}
+	if diff := deep.Equal(gotVR.Values, wantValues); diff != nil {
+		t.Errorf("edited rows: %v", diff)
+	}
+	wantRows := [][]interface{}{
+		{"aerionblue", "donation (over cap)", "6.00", "Moo (over cap)", "moo", "streamlabs", "Pizza", "1234", "2021-10-16 12:00:00", 5.64},
+	}
+	if diff := deep.Equal(gotRows, wantRows); diff != nil {
+		t.Errorf("new rows: %v", diff)
+	}
+}
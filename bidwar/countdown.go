@@ -0,0 +1,37 @@
+package bidwar
+
+import (
+	"time"
+)
+
+// CountdownWarning is a heads-up that a Contest is about to close.
+type CountdownWarning struct {
+	Contest Contest
+	// How long until the contest closes.
+	Left time.Duration
+}
+
+// Countdowns checks the open Contests with a scheduled close time. It returns
+// the warnings that came due in the interval (since, now], and the Contests
+// whose close time has passed. Each warning is a duration before the close
+// time (e.g., 5 minutes).
+func (c Collection) Countdowns(since time.Time, now time.Time, warnings []time.Duration) ([]CountdownWarning, []Contest) {
+	var warns []CountdownWarning
+	var due []Contest
+	for _, con := range c.Contests {
+		if con.Closed || con.ClosesAt.IsZero() {
+			continue
+		}
+		if !now.Before(con.ClosesAt) {
+			due = append(due, con)
+			continue
+		}
+		for _, w := range warnings {
+			at := con.ClosesAt.Add(-w)
+			if at.After(since) && !at.After(now) {
+				warns = append(warns, CountdownWarning{Contest: con, Left: w})
+			}
+		}
+	}
+	return warns, due
+}
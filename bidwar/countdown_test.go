@@ -0,0 +1,52 @@
+package bidwar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountdowns(t *testing.T) {
+	closes := time.Date(2022, 7, 1, 21, 0, 0, 0, time.UTC)
+	c := Collection{Contests: []Contest{
+		{Name: "Scheduled", ClosesAt: closes},
+		{Name: "Unscheduled"},
+		{Name: "Already closed", Closed: true, ClosesAt: closes},
+	}}
+	warnings := []time.Duration{10 * time.Minute, 5 * time.Minute, time.Minute}
+
+	for _, tc := range []struct {
+		desc      string
+		since     time.Time
+		now       time.Time
+		wantWarns []time.Duration
+		wantDue   int
+	}{
+		{"too early", closes.Add(-time.Hour), closes.Add(-30 * time.Minute), nil, 0},
+		{"ten minutes left", closes.Add(-10*time.Minute - 15*time.Second), closes.Add(-10 * time.Minute), []time.Duration{10 * time.Minute}, 0},
+		{"between warnings", closes.Add(-9 * time.Minute), closes.Add(-8 * time.Minute), nil, 0},
+		{"missed two warnings", closes.Add(-6 * time.Minute), closes.Add(-30 * time.Second), []time.Duration{5 * time.Minute, time.Minute}, 0},
+		{"closing time", closes.Add(-15 * time.Second), closes, nil, 1},
+		{"long past closing time", closes.Add(time.Hour), closes.Add(time.Hour + 15*time.Second), nil, 1},
+	} {
+		warns, due := c.Countdowns(tc.since, tc.now, warnings)
+		var gotWarns []time.Duration
+		for _, w := range warns {
+			if w.Contest.Name != "Scheduled" {
+				t.Errorf("%s: got a warning for %q", tc.desc, w.Contest.Name)
+			}
+			gotWarns = append(gotWarns, w.Left)
+		}
+		if len(gotWarns) != len(tc.wantWarns) {
+			t.Errorf("%s: got warnings %v, want %v", tc.desc, gotWarns, tc.wantWarns)
+		} else {
+			for i := range gotWarns {
+				if gotWarns[i] != tc.wantWarns[i] {
+					t.Errorf("%s: got warnings %v, want %v", tc.desc, gotWarns, tc.wantWarns)
+				}
+			}
+		}
+		if len(due) != tc.wantDue {
+			t.Errorf("%s: got %d contests due to close, want %d", tc.desc, len(due), tc.wantDue)
+		}
+	}
+}
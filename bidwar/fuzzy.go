@@ -0,0 +1,208 @@
+package bidwar
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// The default FuzzyThreshold.
+const defaultFuzzyThreshold = 0.8
+
+// Phrases shorter than this (not counting spaces) are never matched fuzzily.
+// A typo in a short alias makes it look like a different word.
+const minFuzzyLength = 5
+
+// fuzzyThreshold returns the Collection's FuzzyThreshold, or the default if
+// it isn't set.
+func (c Collection) fuzzyThreshold() float64 {
+	if c.FuzzyThreshold > 0 {
+		return c.FuzzyThreshold
+	}
+	return defaultFuzzyThreshold
+}
+
+type msgWord struct {
+	text  string
+	start int
+	end   int
+}
+
+// splitWords splits a message into lowercase words of letters and digits,
+// keeping track of where each word is in the message.
+func splitWords(msg string) []msgWord {
+	var words []msgWord
+	start := -1
+	for i, r := range msg {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			words = append(words, msgWord{strings.ToLower(msg[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, msgWord{strings.ToLower(msg[start:]), start, len(msg)})
+	}
+	return words
+}
+
+// fuzzyPhrases returns the phrases to compare against a message for an
+// Option: its display name and its plain-text aliases. Aliases that use
+// regexp syntax are skipped. Each phrase is split into lowercase words.
+func fuzzyPhrases(opt Option) [][]string {
+	var phrases [][]string
+	add := func(s string) {
+		var words []string
+		for _, w := range splitWords(s) {
+			words = append(words, w.text)
+		}
+		if len(words) > 0 {
+			phrases = append(phrases, words)
+		}
+	}
+	add(opt.DisplayName)
+	for _, a := range opt.Aliases {
+		if a.src != "" && regexp.QuoteMeta(a.src) == a.src {
+			add(a.src)
+		}
+	}
+	return phrases
+}
+
+// fuzzyMatch finds the Option whose name or alias is most similar to some run
+// of words in the message. Similarity is based on the edit distance, ignoring
+// case, punctuation, and spacing, so "moomoo medows" is similar to "Moo Moo
+// Meadows". Returns the zero Option if nothing is at least as similar as the
+// threshold (between 0 and 1). Ties go to the leftmost match.
+func fuzzyMatch(opts []Option, msg string, threshold float64) (Option, MatchDetails) {
+	words := splitWords(msg)
+	var best Option
+	var bestDetails MatchDetails
+	bestScore := 0.0
+	for _, opt := range opts {
+		for _, phrase := range fuzzyPhrases(opt) {
+			target := strings.Join(phrase, "")
+			if len(target) < minFuzzyLength {
+				continue
+			}
+			// Donors might run words together or split them up, so try runs
+			// of words a little shorter and longer than the phrase.
+			for n := len(phrase) - 1; n <= len(phrase)+1; n++ {
+				if n < 1 {
+					continue
+				}
+				for i := 0; i+n <= len(words); i++ {
+					var sb strings.Builder
+					for _, w := range words[i : i+n] {
+						sb.WriteString(w.text)
+					}
+					score := similarity(sb.String(), target)
+					start, end := words[i].start, words[i+n-1].end
+					if score < threshold || score < bestScore {
+						continue
+					}
+					if score == bestScore && start >= bestDetails.Index {
+						continue
+					}
+					best, bestScore = opt, score
+					bestDetails = MatchDetails{Alias: msg[start:end], Index: start, Fuzzy: true}
+				}
+			}
+		}
+	}
+	return best, bestDetails
+}
+
+// similarity is 1 minus the edit distance between a and b, as a fraction of
+// the longer string's length.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(first int, rest ...int) int {
+	m := first
+	for _, n := range rest {
+		if n < m {
+			m = n
+		}
+	}
+	return m
+}
+
+// SearchContest looks up a contest by a name that a viewer typed, e.g.
+// "mario kart" for "Mario Kart track". It tries, in order: the exact name, a
+// unique contest whose name contains the query, the contest of an option
+// named in the query, and the contest whose name is most similar to the
+// query (within the FuzzyThreshold).
+func (c Collection) SearchContest(query string) (Contest, bool) {
+	if con, ok := c.ContestByName(query); ok {
+		return con, true
+	}
+	q := joinWords(query)
+	if q == "" {
+		return Contest{}, false
+	}
+	var found []Contest
+	for _, con := range c.Contests {
+		if strings.Contains(joinWords(con.Name), q) {
+			found = append(found, con)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], true
+	}
+	if len(found) == 0 {
+		if opt, ok := c.FindOption(query); ok {
+			return c.ContestForOption(opt)
+		}
+	}
+	var best Contest
+	bestScore := c.fuzzyThreshold()
+	for _, con := range c.Contests {
+		if score := similarity(q, joinWords(con.Name)); score >= bestScore {
+			best, bestScore = con, score
+		}
+	}
+	return best, best.Name != ""
+}
+
+// joinWords normalizes a string to its lowercase words, separated by single
+// spaces.
+func joinWords(s string) string {
+	var words []string
+	for _, w := range splitWords(s) {
+		words = append(words, w.text)
+	}
+	return strings.Join(words, " ")
+}
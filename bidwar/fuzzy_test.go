@@ -0,0 +1,113 @@
+package bidwar
+
+import (
+	"testing"
+)
+
+const fuzzyTestJSON = `{
+    "fuzzyMatch": true,
+    "contests": [
+        {
+            "name": "Mario Kart track",
+            "options": [
+                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo"]},
+                {"displayName": "Neo Bowser City", "shortCode": "NBC", "aliases": ["nbc"]}
+            ]
+        },
+        {
+            "name": "Game",
+            "options": [
+                {"displayName": "Devil May Cry 3", "shortCode": "DMC3", "aliases": ["dmc ?3"]},
+                {"displayName": "Okami", "shortCode": "Okami", "aliases": ["okami"]}
+            ]
+        }
+    ]
+}`
+
+func TestChoiceFromMessage_Fuzzy(t *testing.T) {
+	c, err := Parse([]byte(fuzzyTestJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	for _, tc := range []struct {
+		msg       string
+		want      string
+		wantAlias string
+	}{
+		{"moomoo medows please", "Moo", "moomoo medows"},
+		{"devil may cri 3!!", "DMC3", "devil may cri 3"},
+		{"Neo Bowzer Cty all the way", "NBC", "Neo Bowzer Cty"},
+		// Exact matches still win.
+		{"nbc, not moomoo medows", "NBC", "nbc"},
+		// Too different to be a match.
+		{"new bowling city", "", ""},
+		// Short names aren't matched fuzzily.
+		{"nbx", "", ""},
+	} {
+		choice := c.ChoiceFromMessage(tc.msg, FromChatMessage)
+		if choice.Option.ShortCode != tc.want || choice.Match.Alias != tc.wantAlias {
+			t.Errorf("%q: got %q (matched %q), want %q (matched %q)", tc.msg, choice.Option.ShortCode, choice.Match.Alias, tc.want, tc.wantAlias)
+		}
+	}
+
+	c.FuzzyMatch = false
+	if choice := c.ChoiceFromMessage("moomoo medows please", FromChatMessage); !choice.Option.IsZero() {
+		t.Errorf("got %q with fuzzy matching off, want no match", choice.Option.ShortCode)
+	}
+}
+
+func TestFuzzyThreshold(t *testing.T) {
+	c, err := Parse([]byte(fuzzyTestJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	c.FuzzyThreshold = 0.95
+	if choice := c.ChoiceFromMessage("moomoo medows", FromChatMessage); !choice.Option.IsZero() {
+		t.Errorf("got %q, want no match above the threshold", choice.Option.ShortCode)
+	}
+	if _, err := Parse([]byte(`{"fuzzyThreshold": 1.5}`)); err == nil {
+		t.Error("expected an error for a threshold above 1")
+	}
+}
+
+func TestSearchContest(t *testing.T) {
+	c, err := Parse([]byte(fuzzyTestJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"Mario Kart track", "Mario Kart track"},
+		{"mario kart", "Mario Kart track"},
+		{"  KART! ", "Mario Kart track"},
+		{"mario kort track", "Mario Kart track"},
+		{"game", "Game"},
+		{"okami", "Game"},
+		{"nbc", "Mario Kart track"},
+		{"zelda", ""},
+		{"", ""},
+	} {
+		con, ok := c.SearchContest(tc.query)
+		if ok != (tc.want != "") || con.Name != tc.want {
+			t.Errorf("%q: got (%q, %v), want %q", tc.query, con.Name, ok, tc.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"kitten", "sitting", 3},
+		{"moomoomedows", "moomoomeadows", 1},
+		{"abc", "", 3},
+	} {
+		if got := editDistance([]rune(tc.a), []rune(tc.b)); got != tc.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
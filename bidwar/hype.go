@@ -0,0 +1,76 @@
+package bidwar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Hype returns a longer, narrative summary of a contest for the hosts to read
+// aloud: who's leading and by how much, the biggest recent swing (if any),
+// and how long is left to bid.
+func (tt Totals) Hype(contest Contest, swing Swing, hasSwing bool, now time.Time) string {
+	ranks := tt.computeRanks()
+	var parts []string
+	switch {
+	case len(ranks) == 0:
+		parts = append(parts, fmt.Sprintf("Nobody has bid in %s yet. Be the first!", contest.Name))
+	case len(ranks[0].options) > 1:
+		parts = append(parts, fmt.Sprintf("It's a dead heat in %s! %s are tied at %s.", contest.Name, joinAnd(ranks[0].options), tt.metric.Format(ranks[0].value)))
+	case len(ranks) == 1:
+		parts = append(parts, fmt.Sprintf("In %s, %s is out in front with %s.", contest.Name, ranks[0].options[0].DisplayName, tt.metric.Format(ranks[0].value)))
+	default:
+		leader, gap := ranks[0], ranks[0].value-ranks[1].value
+		parts = append(parts, fmt.Sprintf("In %s, %s leads with %s, %s ahead of %s.", contest.Name, leader.options[0].DisplayName, tt.metric.Format(leader.value), tt.metric.Format(gap), joinAnd(ranks[1].options)))
+		if gap*10 <= leader.value {
+			parts = append(parts, "It's neck and neck!")
+		}
+	}
+	if hasSwing {
+		parts = append(parts, fmt.Sprintf("The biggest swing lately: %s picked up %s in the last %d minutes.", swing.Option.DisplayName, tt.metric.Format(swing.Gain), int(swing.Window.Minutes())))
+	}
+	switch {
+	case contest.Closed:
+		parts = append(parts, "Bidding is closed.")
+	case contest.Locked:
+		parts = append(parts, "The winner is locked in!")
+	case !contest.ClosesAt.IsZero() && now.Before(contest.ClosesAt):
+		parts = append(parts, fmt.Sprintf("There's %s left to bid, so get your donations in!", describeRemaining(contest.ClosesAt.Sub(now))))
+	}
+	return strings.Join(parts, " ")
+}
+
+// joinAnd lists the options' names, e.g. "A, B and C".
+func joinAnd(opts []Option) string {
+	var names []string
+	for _, opt := range opts {
+		names = append(names, opt.DisplayName)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// describeRemaining describes a time left to bid, e.g. "1 hour 5 minutes".
+func describeRemaining(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	mins := int(d.Minutes())
+	var parts []string
+	if h := mins / 60; h > 0 {
+		parts = append(parts, plural(h, "hour"))
+	}
+	if m := mins % 60; m > 0 {
+		parts = append(parts, plural(m, "minute"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
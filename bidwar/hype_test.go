@@ -0,0 +1,47 @@
+package bidwar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestHype(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	rr := Option{DisplayName: "Rainbow Road", ShortCode: "RR"}
+	now := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+	contest := Contest{Name: "Mario Kart track", Options: []Option{moo, nbc, rr}}
+	scheduled := contest
+	scheduled.ClosesAt = now.Add(65 * time.Minute)
+	totals := func(cents ...int) Totals {
+		var tt Totals
+		for i, c := range cents {
+			tt.totals = append(tt.totals, Total{Option: contest.Options[i], Value: donation.CentsValue(c)})
+		}
+		return tt
+	}
+	for _, tc := range []struct {
+		desc     string
+		contest  Contest
+		totals   Totals
+		swing    Swing
+		hasSwing bool
+		want     string
+	}{
+		{"no bids", contest, totals(), Swing{}, false, "Nobody has bid in Mario Kart track yet. Be the first!"},
+		{"clear leader", contest, totals(5000, 2000, 2000), Swing{}, false,
+			"In Mario Kart track, Moo Moo Meadows leads with 50.00, 30.00 ahead of Neo Bowser City and Rainbow Road."},
+		{"close race with a swing", scheduled, totals(5000, 4600), Swing{Option: nbc, Gain: 2500, Window: 15 * time.Minute}, true,
+			"In Mario Kart track, Moo Moo Meadows leads with 50.00, 4.00 ahead of Neo Bowser City. It's neck and neck! " +
+				"The biggest swing lately: Neo Bowser City picked up 25.00 in the last 15 minutes. " +
+				"There's 1 hour 5 minutes left to bid, so get your donations in!"},
+		{"tie", contest, totals(3000, 3000), Swing{}, false, "It's a dead heat in Mario Kart track! Moo Moo Meadows and Neo Bowser City are tied at 30.00."},
+		{"one option", contest, totals(3000), Swing{}, false, "In Mario Kart track, Moo Moo Meadows is out in front with 30.00."},
+	} {
+		if got := tc.totals.Hype(tc.contest, tc.swing, tc.hasSwing, now); got != tc.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tc.desc, got, tc.want)
+		}
+	}
+}
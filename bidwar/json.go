@@ -0,0 +1,112 @@
+package bidwar
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// collectionJSON is the format of the bid war data file. Parse reads it
+// directly into a Collection; these types are only needed to write it back
+// out in the same style.
+type collectionJSON struct {
+	Contests           []contestJSON `json:"contests"`
+	RequireExplicitBid bool          `json:"requireExplicitBid,omitempty"`
+	FuzzyMatch         bool          `json:"fuzzyMatch,omitempty"`
+	FuzzyThreshold     float64       `json:"fuzzyThreshold,omitempty"`
+}
+
+type contestJSON struct {
+	Name                string           `json:"name"`
+	SummaryStyle        SummaryStyle     `json:"summaryStyle,omitempty"`
+	SummaryTemplate     string           `json:"summaryTemplate,omitempty"`
+	NumberOfWinners     int              `json:"numberOfWinners,omitempty"`
+	TieDisplay          TieDisplay       `json:"tieDisplay,omitempty"`
+	Emotes              *emotesJSON      `json:"emotes,omitempty"`
+	Options             []optionJSON     `json:"options"`
+	Closed              bool             `json:"closed,omitempty"`
+	PriorityPhrases     []alias          `json:"priorityPhrases,omitempty"`
+	UnassignedPolicy    UnassignedPolicy `json:"unassignedPolicy,omitempty"`
+	AssignAllUnassigned bool             `json:"assignAllUnassigned,omitempty"`
+	OpensAt             *time.Time       `json:"opensAt,omitempty"`
+	ClosesAt            *time.Time       `json:"closesAt,omitempty"`
+	LateBidGraceSeconds int              `json:"lateBidGraceSeconds,omitempty"`
+	SurgeCents          int              `json:"surgeCents,omitempty"`
+	SurgeMinutes        int              `json:"surgeMinutes,omitempty"`
+	TieBreaker          TieBreaker       `json:"tieBreaker,omitempty"`
+	Metric              Metric           `json:"metric,omitempty"`
+	OverflowOption      string           `json:"overflowOption,omitempty"`
+	Locked              bool             `json:"locked,omitempty"`
+	AutoLock            bool             `json:"autoLock,omitempty"`
+	DonorCapCents       int              `json:"donorCapCents,omitempty"`
+	Prediction          bool             `json:"prediction,omitempty"`
+}
+
+type emotesJSON struct {
+	StillLastPlace string `json:"stillLastPlace,omitempty"`
+	FirstPlace     string `json:"firstPlace,omitempty"`
+}
+
+type optionJSON struct {
+	DisplayName string              `json:"displayName"`
+	ShortCode   string              `json:"shortCode"`
+	Closed      bool                `json:"closed"`
+	Aliases     []alias             `json:"aliases"`
+	Target      donation.CentsValue `json:"target,omitempty"`
+}
+
+// MarshalJSON writes the Collection in the same format that Parse reads.
+func (c Collection) MarshalJSON() ([]byte, error) {
+	j := collectionJSON{
+		RequireExplicitBid: c.RequireExplicitBid,
+		FuzzyMatch:         c.FuzzyMatch,
+		FuzzyThreshold:     c.FuzzyThreshold,
+	}
+	for _, con := range c.Contests {
+		cj := contestJSON{
+			Name:                con.Name,
+			SummaryStyle:        con.SummaryStyle,
+			SummaryTemplate:     con.SummaryTemplate,
+			NumberOfWinners:     con.NumberOfWinners,
+			TieDisplay:          con.TieDisplay,
+			Closed:              con.Closed,
+			PriorityPhrases:     con.PriorityPhrases,
+			UnassignedPolicy:    con.UnassignedPolicy,
+			AssignAllUnassigned: con.AssignAllUnassigned,
+			LateBidGraceSeconds: con.LateBidGraceSeconds,
+			SurgeCents:          con.SurgeCents,
+			SurgeMinutes:        con.SurgeMinutes,
+			TieBreaker:          con.TieBreaker,
+			Metric:              con.Metric,
+			OverflowOption:      con.OverflowOption,
+			Locked:              con.Locked,
+			AutoLock:            con.AutoLock,
+			DonorCapCents:       con.DonorCapCents,
+			Prediction:          con.Prediction,
+		}
+		if con.Emotes != (Emotes{}) {
+			cj.Emotes = &emotesJSON{StillLastPlace: con.Emotes.StillLastPlace, FirstPlace: con.Emotes.FirstPlace}
+		}
+		if !con.OpensAt.IsZero() {
+			t := con.OpensAt
+			cj.OpensAt = &t
+		}
+		if !con.ClosesAt.IsZero() {
+			t := con.ClosesAt
+			cj.ClosesAt = &t
+		}
+		cj.Options = make([]optionJSON, 0, len(con.Options))
+		for _, opt := range con.Options {
+			cj.Options = append(cj.Options, optionJSON{
+				DisplayName: opt.DisplayName,
+				ShortCode:   opt.ShortCode,
+				Closed:      opt.Closed,
+				Aliases:     opt.Aliases,
+				Target:      opt.Target,
+			})
+		}
+		j.Contests = append(j.Contests, cj)
+	}
+	return json.Marshal(j)
+}
@@ -0,0 +1,117 @@
+package bidwar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Metric is what a contest's options are ranked by.
+type Metric string
+
+const (
+	// The value of the donations, in points. Totals come from the tracker
+	// sheet.
+	PointsMetric Metric = ""
+	// The number of subs (including gift subs). Totals are counted from the
+	// donation table.
+	SubsMetric Metric = "SUBS"
+	// The number of bits. Totals are counted from the donation table.
+	BitsMetric Metric = "BITS"
+	// The number of channel point redemptions, whatever they're worth.
+	// Totals are counted from the donation table.
+	VotesMetric Metric = "VOTES"
+)
+
+func (m Metric) valid() bool {
+	switch m {
+	case PointsMetric, SubsMetric, BitsMetric, VotesMetric:
+		return true
+	}
+	return false
+}
+
+// Format formats a total (or a difference between totals) in the metric's
+// units.
+func (m Metric) Format(v donation.CentsValue) string {
+	n := int(v)
+	switch m {
+	case SubsMetric:
+		if n == 1 {
+			return "1 sub"
+		}
+		return fmt.Sprintf("%d subs", n)
+	case BitsMetric:
+		return fmt.Sprintf("%d bits", n)
+	case VotesMetric:
+		if n == 1 {
+			return "1 vote"
+		}
+		return fmt.Sprintf("%d votes", n)
+	}
+	return v.String()
+}
+
+// rowAmount returns how much a row of the donation table counts for under
+// the metric, reading the row's description (e.g., "2x tier 2 gift sub" or
+// "500 bits"). Rows made by splitting a donation don't count, since the
+// original row already counted the whole donation.
+func (m Metric) rowAmount(dr donationRow) int {
+	if m == PointsMetric {
+		return dr.Cents()
+	}
+	what := dr.What()
+	if strings.HasSuffix(what, splitSuffix) {
+		return 0
+	}
+	n := 0
+	for _, part := range strings.Split(what, " + ") {
+		switch {
+		case m == BitsMetric && strings.HasSuffix(part, " bits"):
+			bits, err := strconv.Atoi(strings.TrimSuffix(part, " bits"))
+			if err == nil {
+				n += bits
+			}
+		case m == SubsMetric && strings.HasSuffix(part, "sub"):
+			n += subCount(part)
+		case m == VotesMetric && part == donation.RedemptionDescription:
+			n++
+		}
+	}
+	return n
+}
+
+// subCount reads the number of subs from a description like "2x gift sub".
+func subCount(desc string) int {
+	fields := strings.Fields(desc)
+	if len(fields) > 0 && strings.HasSuffix(fields[0], "x") {
+		if n, err := strconv.Atoi(strings.TrimSuffix(fields[0], "x")); err == nil {
+			return n
+		}
+	}
+	return 1
+}
+
+// metricTotals adds up the donation table rows (including the header) that
+// were assigned to the contest's options, according to the contest's Metric.
+// Every option gets a Total, even if it's zero.
+func metricTotals(contest Contest, rows [][]interface{}) []Total {
+	sums := make(map[string]int)
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		dr := donationRow(row)
+		if dr.Choice() == "" {
+			continue
+		}
+		sums[dr.Choice()] += contest.Metric.rowAmount(dr)
+	}
+	var totals []Total
+	for _, opt := range contest.Options {
+		totals = append(totals, Total{Option: opt, Value: donation.CentsValue(sums[opt.ShortCode])})
+	}
+	return totals
+}
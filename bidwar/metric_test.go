@@ -0,0 +1,73 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestMetricTotals(t *testing.T) {
+	rows := [][]interface{}{
+		{"Contributor", "What", "Points", "Choice", "Message"},
+		{"aerionblue", "sub", "5.00", "Moo"},
+		{"AEWC20XX", "5x tier 2 gift sub", "50.00", "NBC"},
+		{"AEWC20XX", "5x tier 2 gift sub (split)", "10.00", "Moo"},
+		{"Mizalie", "444 bits", "4.44", "Moo"},
+		{"usedpizza", "$5.00 donation + 100 bits + 2x gift sub", "16.00", "Moo"},
+		{"ShartyMcFly", "tier 3 sub", "25.00"},
+		{"someone", "sub", "5.00", "RR"},
+		{"viewer1", "channel point redemption", "0.00", "Moo"},
+		{"viewer2", "channel point redemption", "1.00", "NBC"},
+		{"viewer3", "channel point redemption", "0.00", "NBC"},
+	}
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	for _, tc := range []struct {
+		metric Metric
+		want   []Total
+	}{
+		{SubsMetric, []Total{{Option: moo, Value: 3}, {Option: nbc, Value: 5}}},
+		{BitsMetric, []Total{{Option: moo, Value: 544}, {Option: nbc, Value: 0}}},
+		{PointsMetric, []Total{{Option: moo, Value: 3544}, {Option: nbc, Value: 5100}}},
+		{VotesMetric, []Total{{Option: moo, Value: 1}, {Option: nbc, Value: 2}}},
+	} {
+		contest := Contest{Options: []Option{moo, nbc}, Metric: tc.metric}
+		if diff := deep.Equal(metricTotals(contest, rows), tc.want); diff != nil {
+			t.Errorf("metric %q: %v", tc.metric, diff)
+		}
+	}
+}
+
+func TestDescribe_Metric(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	totals := []Total{{Option: nbc, Value: 12}, {Option: moo, Value: 11}}
+	for _, tc := range []struct {
+		metric Metric
+		style  SummaryStyle
+		want   string
+	}{
+		{SubsMetric, "ALL", "Neo Bowser City: 12 subs, Moo Moo Meadows: 11 subs (down by 1 sub)"},
+		{BitsMetric, "FIRST_PLACE", "Moo Moo Meadows is currently #2. First place: Neo Bowser City (up by 1 bits)"},
+		{PointsMetric, "ALL", "Neo Bowser City: 0.12, Moo Moo Meadows: 0.11 (down by 0.01)"},
+		{VotesMetric, "ALL", "Neo Bowser City: 12 votes, Moo Moo Meadows: 11 votes (down by 1 vote)"},
+	} {
+		got := Totals{totals: totals, summaryStyle: tc.style, metric: tc.metric}.Describe(moo)
+		if got != tc.want {
+			t.Errorf("metric %q: got %q, want %q", tc.metric, got, tc.want)
+		}
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	c, err := Parse([]byte(`{"contests": [{"name": "Most gift subs", "metric": "SUBS", "options": []}]}`))
+	if err != nil {
+		t.Fatalf("error parsing: %v", err)
+	}
+	if got := c.Contests[0].Metric; got != SubsMetric {
+		t.Errorf("got metric %q, want %q", got, SubsMetric)
+	}
+	if _, err := Parse([]byte(`{"contests": [{"name": "Most hugs", "metric": "HUGS", "options": []}]}`)); err == nil {
+		t.Error("expected an error for an unknown metric")
+	}
+}
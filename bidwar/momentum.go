@@ -0,0 +1,133 @@
+package bidwar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Surge is a big gain for one Option over a short time.
+type Surge struct {
+	Option Option
+	Gain   donation.CentsValue
+	Window time.Duration
+	// The units of Gain.
+	Metric Metric
+}
+
+// Describe returns a hype message about the surge.
+func (s Surge) Describe() string {
+	return fmt.Sprintf("%s is surging — %s in the last %d minutes!", s.Option.DisplayName, s.Metric.Format(s.Gain), int(s.Window.Minutes()))
+}
+
+// How long MomentumTracker keeps history for every contest, for
+// BiggestSwing. Contests with a longer surge window keep more.
+const RecentWindow = 15 * time.Minute
+
+type totalSample struct {
+	at    time.Time
+	value donation.CentsValue
+}
+
+// MomentumTracker keeps a short history of each Option's total, so that it
+// can tell when an Option is surging.
+type MomentumTracker struct {
+	mu sync.Mutex
+	// Maps an Option's short code to its recent totals, oldest first.
+	samples map[string][]totalSample
+	// The Options that have already been announced as surging. An Option is
+	// announced again only after its gain drops back below the threshold.
+	surging map[string]bool
+}
+
+// NewMomentumTracker creates a MomentumTracker with no history.
+func NewMomentumTracker() *MomentumTracker {
+	return &MomentumTracker{
+		samples: make(map[string][]totalSample),
+		surging: make(map[string]bool),
+	}
+}
+
+// Observe records the current totals for a Contest, and returns any Options
+// that just started surging. Surges are only reported for contests with surge
+// thresholds.
+func (m *MomentumTracker) Observe(contest Contest, tt Totals, now time.Time) []Surge {
+	checkSurges := contest.SurgeCents > 0 && contest.SurgeMinutes > 0
+	window := time.Duration(contest.SurgeMinutes) * time.Minute
+	keep := RecentWindow
+	if checkSurges && window > keep {
+		keep = window
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var surges []Surge
+	for _, t := range tt.openTotals() {
+		code := t.Option.ShortCode
+		samples := append(m.samples[code], totalSample{at: now, value: t.Value})
+		// Keep the newest sample from before the window as a baseline, and
+		// forget anything older.
+		start := 0
+		for i, s := range samples {
+			if !s.at.After(now.Add(-keep)) {
+				start = i
+			}
+		}
+		samples = samples[start:]
+		m.samples[code] = samples
+		if !checkSurges {
+			continue
+		}
+
+		gain := gainSince(samples, now.Add(-window))
+		if gain.Cents() < contest.SurgeCents {
+			m.surging[code] = false
+			continue
+		}
+		if !m.surging[code] {
+			m.surging[code] = true
+			surges = append(surges, Surge{Option: t.Option, Gain: gain, Window: window, Metric: contest.Metric})
+		}
+	}
+	return surges
+}
+
+// gainSince returns how much the total grew from the newest sample at or
+// before since (or the oldest sample, if none is that old) to the latest one.
+func gainSince(samples []totalSample, since time.Time) donation.CentsValue {
+	if len(samples) == 0 {
+		return 0
+	}
+	base := samples[0]
+	for _, s := range samples {
+		if !s.at.After(since) {
+			base = s
+		}
+	}
+	return samples[len(samples)-1].value - base.value
+}
+
+// Swing is how much an Option gained over a recent window.
+type Swing struct {
+	Option Option
+	Gain   donation.CentsValue
+	Window time.Duration
+}
+
+// BiggestSwing returns the open Option in the Contest that gained the most
+// over the RecentWindow, if any Option gained anything.
+func (m *MomentumTracker) BiggestSwing(contest Contest, now time.Time) (Swing, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var best Swing
+	for _, opt := range contest.Options {
+		if opt.Closed {
+			continue
+		}
+		if gain := gainSince(m.samples[opt.ShortCode], now.Add(-RecentWindow)); gain > best.Gain {
+			best = Swing{Option: opt, Gain: gain, Window: RecentWindow}
+		}
+	}
+	return best, best.Gain > 0
+}
@@ -0,0 +1,93 @@
+package bidwar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestMomentumTracker(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	contest := Contest{Name: "Mario Kart track", Options: []Option{moo, nbc}, SurgeCents: 4000, SurgeMinutes: 10}
+	totals := func(mooCents, nbcCents int) Totals {
+		return Totals{totals: []Total{
+			{Option: moo, Value: donation.CentsValue(mooCents)},
+			{Option: nbc, Value: donation.CentsValue(nbcCents)},
+		}}
+	}
+	start := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+	m := NewMomentumTracker()
+
+	for _, tc := range []struct {
+		desc      string
+		minutes   int
+		mooCents  int
+		nbcCents  int
+		wantCodes []string
+	}{
+		{"first sample", 0, 1000, 1000, nil},
+		{"not enough yet", 3, 2000, 1000, nil},
+		{"moo surges", 5, 5500, 1000, []string{"Moo"}},
+		{"only announced once", 6, 6000, 1000, nil},
+		{"slow gains don't count", 30, 6500, 2000, nil},
+		{"nbc surges", 35, 6500, 6500, []string{"NBC"}},
+		{"moo surges again", 39, 11000, 6500, []string{"Moo"}},
+	} {
+		got := m.Observe(contest, totals(tc.mooCents, tc.nbcCents), start.Add(time.Duration(tc.minutes)*time.Minute))
+		var gotCodes []string
+		for _, s := range got {
+			gotCodes = append(gotCodes, s.Option.ShortCode)
+		}
+		if len(gotCodes) != len(tc.wantCodes) || (len(gotCodes) > 0 && gotCodes[0] != tc.wantCodes[0]) {
+			t.Errorf("%s: got surges %v, want %v", tc.desc, gotCodes, tc.wantCodes)
+		}
+	}
+}
+
+func TestMomentumTracker_NoThreshold(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	m := NewMomentumTracker()
+	now := time.Now()
+	m.Observe(Contest{}, Totals{totals: []Total{{Option: moo, Value: 0}}}, now)
+	got := m.Observe(Contest{}, Totals{totals: []Total{{Option: moo, Value: 100000}}}, now.Add(time.Minute))
+	if len(got) != 0 {
+		t.Errorf("got surges %v for a contest with no threshold, want none", got)
+	}
+}
+
+func TestBiggestSwing(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	contest := Contest{Name: "Mario Kart track", Options: []Option{moo, nbc}}
+	totals := func(mooCents, nbcCents int) Totals {
+		return Totals{totals: []Total{
+			{Option: moo, Value: donation.CentsValue(mooCents)},
+			{Option: nbc, Value: donation.CentsValue(nbcCents)},
+		}}
+	}
+	start := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+	m := NewMomentumTracker()
+	if _, ok := m.BiggestSwing(contest, start); ok {
+		t.Error("got a swing with no history")
+	}
+	m.Observe(contest, totals(1000, 1000), start)
+	m.Observe(contest, totals(1500, 3000), start.Add(5*time.Minute))
+	if got, ok := m.BiggestSwing(contest, start.Add(5*time.Minute)); !ok || got.Option.ShortCode != "NBC" || got.Gain != 2000 {
+		t.Errorf("got %+v, %v; want NBC +20.00", got, ok)
+	}
+	// The early gains fall out of the window.
+	m.Observe(contest, totals(2000, 3000), start.Add(25*time.Minute))
+	if got, ok := m.BiggestSwing(contest, start.Add(25*time.Minute)); !ok || got.Option.ShortCode != "Moo" || got.Gain != 500 {
+		t.Errorf("got %+v, %v; want Moo +5.00", got, ok)
+	}
+}
+
+func TestSurgeDescribe(t *testing.T) {
+	s := Surge{Option: Option{DisplayName: "NBC"}, Gain: donation.CentsValue(4500), Window: 10 * time.Minute}
+	want := "NBC is surging — 45.00 in the last 10 minutes!"
+	if got := s.Describe(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package bidwar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// overflowFor returns the Option that bids for opt should go to instead,
+// because opt's contest is locked. Returns false if the bids should go to opt
+// as usual, including when the contest's overflow option isn't open.
+func (c Collection) overflowFor(opt Option) (Option, bool) {
+	con, ok := c.ContestForOption(opt)
+	if !ok || !con.Locked || con.OverflowOption == "" || strings.EqualFold(opt.ShortCode, con.OverflowOption) {
+		return Option{}, false
+	}
+	for _, o := range c.AllOpenOptions() {
+		if strings.EqualFold(o.ShortCode, con.OverflowOption) {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// withOverflow sends a Choice to its contest's overflow option, if the
+// contest is locked.
+func (c Collection) withOverflow(choice Choice) Choice {
+	if over, ok := c.overflowFor(choice.Option); ok {
+		choice.Match.OverflowFrom = choice.Option.ShortCode
+		choice.Option = over
+	}
+	return choice
+}
+
+// SetContestLocked returns a copy of the Collection in which the named
+// Contest is locked (or unlocked). Returns false if there is no such Contest.
+func (c Collection) SetContestLocked(name string, locked bool) (Collection, bool) {
+	c = c.clone()
+	for i := range c.Contests {
+		con := &c.Contests[i]
+		if strings.EqualFold(con.Name, strings.TrimSpace(name)) {
+			con.Locked = locked
+			return c, true
+		}
+	}
+	return c, false
+}
+
+// validateOverflow checks that each contest's overflow option exists.
+func (c Collection) validateOverflow() error {
+	for _, con := range c.Contests {
+		if con.OverflowOption == "" {
+			continue
+		}
+		if _, ok := c.OptionByShortCode(con.OverflowOption); !ok {
+			return fmt.Errorf("contest %q has unknown overflowOption %q", con.Name, con.OverflowOption)
+		}
+	}
+	return nil
+}
+
+// Decided reports whether the leader can no longer be caught: it has more
+// than all of the other options put together. A contest with a single bid
+// isn't decided yet, nor is one with more than one winner.
+func (tt Totals) Decided() (Option, bool) {
+	if tt.numberOfWinners > 1 || len(tt.totals) < 2 {
+		return Option{}, false
+	}
+	leader, sum := tt.totals[0], 0
+	for _, t := range tt.totals {
+		if t.Value.Cents() > leader.Value.Cents() {
+			leader = t
+		}
+		sum += t.Value.Cents()
+	}
+	rest := sum - leader.Value.Cents()
+	if rest == 0 || leader.Value.Cents() <= rest {
+		return Option{}, false
+	}
+	return leader.Option, true
+}
@@ -0,0 +1,97 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+const overflowJSON = `{
+    "contests": [
+        {
+            "name": "Mario Kart track",
+            "overflowOption": "Stretch",
+            "options": [
+                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo"]},
+                {"displayName": "Neo Bowser City", "shortCode": "NBC", "aliases": ["nbc"]},
+                {"displayName": "Rainbow Road stretch goal", "shortCode": "Stretch", "aliases": ["stretch"]}
+            ]
+        }
+    ]
+}`
+
+func TestOverflow(t *testing.T) {
+	c, err := Parse([]byte(overflowJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	if got := c.ChoiceFromMessage("moo", FromChatMessage); got.Option.ShortCode != "Moo" || got.Match.OverflowFrom != "" {
+		t.Errorf("unlocked: got %q (from %q), want Moo", got.Option.ShortCode, got.Match.OverflowFrom)
+	}
+
+	c, ok := c.SetContestLocked("mario kart track", true)
+	if !ok {
+		t.Fatalf("SetContestLocked didn't find the contest")
+	}
+	for _, tc := range []struct {
+		msg      string
+		want     string
+		wantFrom string
+	}{
+		{"moo", "Stretch", "Moo"},
+		{"stretch please", "Stretch", ""},
+		{"tetris", "", ""},
+	} {
+		got := c.ChoiceFromMessage(tc.msg, FromChatMessage)
+		if got.Option.ShortCode != tc.want || got.Match.OverflowFrom != tc.wantFrom {
+			t.Errorf("%q: got %q (from %q), want %q (from %q)", tc.msg, got.Option.ShortCode, got.Match.OverflowFrom, tc.want, tc.wantFrom)
+		}
+	}
+	split, err := c.SplitFromMessage("!bid 5 nbc")
+	if err != nil || split == nil || split.Parts[0].Option.ShortCode != "Stretch" || split.Parts[0].OverflowFrom != "NBC" {
+		t.Errorf("partial bid: got %+v, %v; want a bid for Stretch (from NBC)", split, err)
+	}
+	split, err = c.SplitFromMessage("!bid 50% moo 50% stretch")
+	if err != nil || split == nil || split.Parts[0].OverflowFrom != "Moo" || split.Parts[1].OverflowFrom != "" {
+		t.Errorf("split bid: got %+v, %v; want the first part from Moo", split, err)
+	}
+
+	// If the overflow option is closed, bids count as usual.
+	c, _ = c.SetOptionClosed("Stretch", true)
+	if got := c.ChoiceFromMessage("moo", FromChatMessage); got.Option.ShortCode != "Moo" {
+		t.Errorf("closed overflow: got %q, want Moo", got.Option.ShortCode)
+	}
+}
+
+func TestOverflowMustExist(t *testing.T) {
+	if _, err := Parse([]byte(`{"contests": [{"name": "x", "overflowOption": "Nope", "options": []}]}`)); err == nil {
+		t.Error("got no error for an unknown overflow option")
+	}
+}
+
+func TestDecided(t *testing.T) {
+	moo, nbc, dmc := Option{ShortCode: "Moo"}, Option{ShortCode: "NBC"}, Option{ShortCode: "DMC"}
+	for _, tc := range []struct {
+		desc   string
+		totals []Total
+		want   string
+	}{
+		{"more than the rest", []Total{{moo, 1000}, {nbc, 400}, {dmc, 500}}, "Moo"},
+		{"exactly the rest", []Total{{moo, 900}, {nbc, 400}, {dmc, 500}}, ""},
+		{"unsorted", []Total{{nbc, 100}, {moo, 1000}}, "Moo"},
+		{"only one bid", []Total{{moo, 1000}, {nbc, 0}}, ""},
+		{"one option", []Total{{moo, 1000}}, ""},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			tt := Totals{totals: tc.totals, numberOfWinners: 1}
+			got, ok := tt.Decided()
+			if got.ShortCode != tc.want || ok != (tc.want != "") {
+				t.Errorf("got %q, %v; want %q", got.ShortCode, ok, tc.want)
+			}
+		})
+	}
+	tt := Totals{totals: []Total{{moo, donation.CentsValue(1000)}, {nbc, 1}}, numberOfWinners: 2}
+	if _, ok := tt.Decided(); ok {
+		t.Error("a contest with two winners was decided")
+	}
+}
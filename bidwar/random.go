@@ -0,0 +1,46 @@
+package bidwar
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+)
+
+// Random is the source of random numbers for random bids, coin flip tie
+// breaks, and auto-assigned donations. Every draw is logged along with the
+// seed, so that the draws can be replayed later to check that they were fair.
+// A nil *Random uses the global source, and logs nothing.
+type Random struct {
+	seed int64
+
+	mu    sync.Mutex
+	src   *rand.Rand
+	draws int
+}
+
+// NewRandom creates a Random that draws from the given seed. Two Randoms with
+// the same seed make the same draws in the same order.
+func NewRandom(seed int64) *Random {
+	return &Random{seed: seed, src: rand.New(rand.NewSource(seed))}
+}
+
+// Seed returns the seed that the Random was created with.
+func (r *Random) Seed() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.seed
+}
+
+// Intn returns a random number in [0, n). It panics if n <= 0.
+func (r *Random) Intn(n int) int {
+	if r == nil {
+		return rand.Intn(n)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v := r.src.Intn(n)
+	r.draws++
+	log.Printf("random draw #%d (seed %d): %d of %d", r.draws, r.seed, v, n)
+	return v
+}
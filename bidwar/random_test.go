@@ -0,0 +1,50 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRandomIsRepeatable(t *testing.T) {
+	draw := func(r *Random) []int {
+		var got []int
+		for i := 0; i < 20; i++ {
+			got = append(got, r.Intn(5))
+		}
+		return got
+	}
+	first := draw(NewRandom(42))
+	second := draw(NewRandom(42))
+	if !cmp.Equal(first, second) {
+		t.Errorf("same seed made different draws:\n%v\n%v", first, second)
+	}
+	for _, v := range first {
+		if v < 0 || v >= 5 {
+			t.Errorf("draw %d is out of range", v)
+		}
+	}
+	var nilRandom *Random
+	if v := nilRandom.Intn(3); v < 0 || v >= 3 {
+		t.Errorf("draw %d from nil Random is out of range", v)
+	}
+}
+
+func TestChoiceFromMessageRandom_Seeded(t *testing.T) {
+	bidwars, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	picks := func(seed int64) []string {
+		bidwars.Random = NewRandom(seed)
+		var got []string
+		for i := 0; i < 10; i++ {
+			got = append(got, bidwars.ChoiceFromMessage("random", FromChatMessage).Option.ShortCode)
+		}
+		return got
+	}
+	first := picks(7)
+	if second := picks(7); !cmp.Equal(first, second) {
+		t.Errorf(cmp.Diff(first, second))
+	}
+}
@@ -0,0 +1,78 @@
+package bidwar
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Reassign moves the given rows, which were assigned to the donor by an
+// earlier Choice (see UpdateStats.Rows), to a new Choice. This lets a donor
+// change their mind shortly after a !bid. Rows that no longer belong to the
+// donor or no longer have the earlier Choice are left alone.
+func (t Tallier) Reassign(donor string, rows []int, from Choice, to Choice) (UpdateStats, error) {
+	if donor == "" {
+		return UpdateStats{}, errors.New("donor must not be empty")
+	}
+	valueRange, err := t.table.GetTable()
+	if err != nil {
+		return UpdateStats{}, fmt.Errorf("error reading donation table: %v", err)
+	}
+
+	vrToWrite, matchedRows := makeReassign(valueRange, t.donorNames(donor, valueRange), rows, from, to)
+
+	if len(matchedRows) > 0 {
+		rowCount, err := t.table.WriteTable(vrToWrite)
+		if err != nil {
+			return UpdateStats{}, fmt.Errorf("error updating spreadsheet: %v", err)
+		}
+		log.Printf("moved %d rows for %s from %s to %s", rowCount, donor, from.Option.ShortCode, to.Option.ShortCode)
+	}
+
+	totalCents := 0
+	for _, dr := range matchedRows {
+		totalCents += dr.Cents()
+	}
+	updateStats := UpdateStats{
+		Choice:     to,
+		Count:      len(matchedRows),
+		TotalValue: donation.CentsValue(totalCents),
+		Rows:       editedRows(vrToWrite),
+	}
+	if len(matchedRows) > 0 {
+		if updateStats.OverCap, err = t.ApplyCap(donor, to.Option); err != nil {
+			return updateStats, err
+		}
+	}
+	return updateStats, nil
+}
+
+// makeReassign is like makeChoice, but it picks the given rows, as long as
+// they're still the donor's and still assigned by the earlier Choice, instead
+// of the donor's unassigned rows.
+func makeReassign(vr *sheets.ValueRange, donorNames []string, rows []int, from Choice, to Choice) (*sheets.ValueRange, []donationRow) {
+	picked := make(map[int]bool)
+	for _, i := range rows {
+		picked[i] = true
+	}
+	newValues := make([][]interface{}, len(vr.Values))
+	var updatedRows []donationRow
+	for i, row := range vr.Values {
+		newValues[i] = []interface{}{}
+		dr := donationRow(row)
+		if !picked[i] || dr.Choice() != from.Option.ShortCode || dr.Reason() != from.Reason || !isDonor(dr, donorNames) {
+			continue
+		}
+		newValues[i] = rowForChoice(to)
+		updatedRows = append(updatedRows, dr)
+	}
+	return &sheets.ValueRange{
+		MajorDimension: vr.MajorDimension,
+		Range:          vr.Range,
+		Values:         newValues,
+	}, updatedRows
+}
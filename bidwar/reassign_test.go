@@ -0,0 +1,38 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestMakeReassign(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Range:          "Tracker!A:E",
+		MajorDimension: "ROWS",
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message"},
+			{"aerionblue", "sub", "5.00", "Moo", "[!bid] moo"},
+			{"aerionblue", "donation", "10.00", "Moo", "all on moo"},
+			{"AEWC20XX", "sub", "5.00", "Moo", "[!bid] moo"},
+			{"AerionBlue", "200 bits", "2.00", "Moo", "[!bid] moo"},
+			{"aerionblue", "sub", "5.00"},
+			// An earlier, identical !bid. It isn't moved.
+			{"aerionblue", "sub", "5.00", "Moo", "[!bid] moo"},
+		},
+	}
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	from := Choice{Option: moo, Reason: "[!bid] moo"}
+	to := Choice{Option: nbc, Reason: "[!bid] nbc"}
+
+	gotVR, gotRows := makeReassign(vr, []string{"aerionblue"}, []int{1, 2, 3, 4, 5}, from, to)
+	wantValues := [][]interface{}{{}, {nil, nil, nil, "NBC", "[!bid] nbc"}, {}, {}, {nil, nil, nil, "NBC", "[!bid] nbc"}, {}, {}}
+	if diff := deep.Equal(gotVR.Values, wantValues); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(gotRows, []donationRow{vr.Values[1], vr.Values[4]}); diff != nil {
+		t.Error(diff)
+	}
+}
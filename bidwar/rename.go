@@ -0,0 +1,90 @@
+package bidwar
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// RenameOption returns a copy of the Collection in which the Option with the
+// short code oldCode has a new short code and display name. If displayName is
+// empty, the display name is left alone. Contests whose overflow option was
+// the old code are updated too. The new short code must not belong to any
+// other Option.
+func (c Collection) RenameOption(oldCode string, newCode string, displayName string) (Collection, error) {
+	newCode = strings.TrimSpace(newCode)
+	if newCode == "" {
+		return c, errors.New("the new short code must not be empty")
+	}
+	if other, ok := c.OptionByShortCode(newCode); ok && !strings.EqualFold(other.ShortCode, oldCode) {
+		return c, fmt.Errorf("there is already an option called %q", other.ShortCode)
+	}
+	old, ok := c.OptionByShortCode(oldCode)
+	if !ok {
+		return c, fmt.Errorf("there is no option called %q", oldCode)
+	}
+	c = c.clone()
+	for i := range c.Contests {
+		con := &c.Contests[i]
+		if strings.EqualFold(con.OverflowOption, old.ShortCode) {
+			con.OverflowOption = newCode
+		}
+		for j := range con.Options {
+			opt := &con.Options[j]
+			if opt.ShortCode != old.ShortCode {
+				continue
+			}
+			opt.ShortCode = newCode
+			if displayName != "" {
+				opt.DisplayName = displayName
+			}
+		}
+	}
+	return c, nil
+}
+
+// RenameChoice rewrites the Choice column of the donation table, so that the
+// rows assigned to oldCode are assigned to newCode instead. Use it along with
+// RenameOption, so that the old rows still count for the renamed Option.
+// Returns the number of rows rewritten.
+func (t Tallier) RenameChoice(oldCode string, newCode string) (int, error) {
+	valueRange, err := t.table.GetTable()
+	if err != nil {
+		return 0, fmt.Errorf("error reading donation table: %v", err)
+	}
+	vrToWrite, count := makeRename(valueRange, oldCode, newCode)
+	if count == 0 {
+		return 0, nil
+	}
+	if _, err := t.table.WriteTable(vrToWrite); err != nil {
+		return 0, fmt.Errorf("error updating spreadsheet: %v", err)
+	}
+	log.Printf("renamed %s to %s in %d rows", oldCode, newCode, count)
+	return count, nil
+}
+
+// makeRename works out how to edit the donation table so that every row
+// assigned to oldCode (in any case) is assigned to newCode. It returns the
+// edits and the number of rows edited.
+func makeRename(vr *sheets.ValueRange, oldCode string, newCode string) (*sheets.ValueRange, int) {
+	newValues := make([][]interface{}, len(vr.Values))
+	count := 0
+	for i, row := range vr.Values {
+		newValues[i] = []interface{}{}
+		if i == 0 {
+			continue // The header
+		}
+		if dr := donationRow(row); dr.Choice() != "" && strings.EqualFold(dr.Choice(), oldCode) {
+			newValues[i] = []interface{}{nil, nil, nil, newCode}
+			count++
+		}
+	}
+	return &sheets.ValueRange{
+		MajorDimension: vr.MajorDimension,
+		Range:          vr.Range,
+		Values:         newValues,
+	}, count
+}
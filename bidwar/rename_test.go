@@ -0,0 +1,75 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"google.golang.org/api/sheets/v4"
+)
+
+func TestRenameOption(t *testing.T) {
+	orig, err := Parse([]byte(overflowJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	c, err := orig.RenameOption("stretch", "Rainbow", "Rainbow Road")
+	if err != nil {
+		t.Fatalf("RenameOption: %v", err)
+	}
+	opt, ok := c.OptionByShortCode("Rainbow")
+	if !ok || opt.DisplayName != "Rainbow Road" {
+		t.Errorf("got %+v, %v; want the renamed option", opt, ok)
+	}
+	if _, ok := c.OptionByShortCode("Stretch"); ok {
+		t.Error("the old short code is still there")
+	}
+	if con, _ := c.ContestByName("Mario Kart track"); con.OverflowOption != "Rainbow" {
+		t.Errorf("overflow option is %q, want Rainbow", con.OverflowOption)
+	}
+	if _, ok := orig.OptionByShortCode("Rainbow"); ok {
+		t.Error("renaming modified the original Collection")
+	}
+	if got := c.ChoiceFromMessage("stretch", FromChatMessage); got.Option.ShortCode != "Rainbow" {
+		t.Errorf("the old alias chose %q, want Rainbow", got.Option.ShortCode)
+	}
+
+	if c, err = c.RenameOption("Moo", "MOO", ""); err != nil {
+		t.Errorf("changing case: %v", err)
+	} else if opt, _ := c.OptionByShortCode("moo"); opt.ShortCode != "MOO" || opt.DisplayName != "Moo Moo Meadows" {
+		t.Errorf("changing case: got %+v", opt)
+	}
+	if _, err := c.RenameOption("MOO", "NBC", ""); err == nil {
+		t.Error("renamed an option to another option's short code")
+	}
+	if _, err := c.RenameOption("Tetris", "Tet", ""); err == nil {
+		t.Error("renamed an option that doesn't exist")
+	}
+}
+
+func TestMakeRename(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Range:          "Tracker!A:E",
+		MajorDimension: "ROWS",
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message"},
+			{"aerionblue", "resub", "5.00", "Moo", "[chat] moo"},
+			{"AEWC20XX", "donation", "20.00", "NBC", "nbc"},
+			{"sharty", "resub", "5.00"},
+			{"AerionBlue", "200 bits", "2.00", "moo", "[chat] moo"},
+		},
+	}
+	got, count := makeRename(vr, "Moo", "Meadows")
+	want := [][]interface{}{
+		{},
+		{nil, nil, nil, "Meadows"},
+		{},
+		{},
+		{nil, nil, nil, "Meadows"},
+	}
+	if diff := deep.Equal(got.Values, want); diff != nil {
+		t.Error(diff)
+	}
+	if count != 2 {
+		t.Errorf("got %d rows, want 2", count)
+	}
+}
@@ -0,0 +1,67 @@
+package bidwar
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+// ExportResults copies the donation table rows assigned to the contest's
+// options into their own sheet, so that there's a record of exactly which
+// donations decided the contest, whatever happens to the table later. The
+// sheet is created if it doesn't exist, and overwritten if it does. Returns
+// how many donations were copied.
+func (t Tallier) ExportResults(contest Contest, sheetName string) (int, error) {
+	vr, err := t.table.GetTable()
+	if err != nil {
+		return 0, fmt.Errorf("error reading donation table: %v", err)
+	}
+	rows := contestResultRows(vr.Values, contest)
+	if err := googlesheets.WriteResults(t.sheetsSrv, t.spreadsheetID, sheetName, rows); err != nil {
+		return 0, err
+	}
+	n := len(rows)
+	if n > 0 {
+		// Don't count the header.
+		n--
+	}
+	log.Printf("exported %d donations for %s to %q", n, contest.Name, sheetName)
+	return n, nil
+}
+
+// contestResultRows picks the rows of the donation table (including the
+// header, which is kept) that count towards the contest's options. They're
+// sorted by option, in the contest's order, and then by value, largest
+// first. Rows flagged as over the donor cap are left out, since they don't
+// count.
+func contestResultRows(table [][]interface{}, contest Contest) [][]interface{} {
+	if len(table) == 0 {
+		return nil
+	}
+	order := make(map[string]int)
+	for i, opt := range contest.Options {
+		order[strings.ToLower(opt.ShortCode)] = i
+	}
+	var rows []donationRow
+	for _, row := range table[1:] {
+		dr := donationRow(row)
+		if _, ok := order[strings.ToLower(dr.Choice())]; ok {
+			rows = append(rows, dr)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		oi, oj := order[strings.ToLower(rows[i].Choice())], order[strings.ToLower(rows[j].Choice())]
+		if oi != oj {
+			return oi < oj
+		}
+		return rows[i].Cents() > rows[j].Cents()
+	})
+	result := [][]interface{}{table[0]}
+	for _, dr := range rows {
+		result = append(result, dr)
+	}
+	return result
+}
@@ -0,0 +1,41 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestContestResultRows(t *testing.T) {
+	contest := Contest{
+		Name: "Final track",
+		Options: []Option{
+			{DisplayName: "Neo Bowser City", ShortCode: "NBC"},
+			{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"},
+		},
+	}
+	header := []interface{}{"Contributor", "What", "Points", "Choice", "Message"}
+	table := [][]interface{}{
+		header,
+		{"aerionblue", "resub", "5.00", "moo"},
+		{"AEWC20XX", "donation", "5.00"},
+		{"usedpizza", "donation", "20.00", "Moo", "[donation msg] moo"},
+		{"Mizalie", "444 bits", "4.44", "NBC", "[chat] nbc"},
+		{"Mizalie", "donation", "50.00", "NBC (over cap)"},
+		{"aerionblue", "donation", "1.00", "RR"},
+		{"Kappa", "donation", "10.00", "NBC"},
+	}
+	want := [][]interface{}{
+		header,
+		{"Kappa", "donation", "10.00", "NBC"},
+		{"Mizalie", "444 bits", "4.44", "NBC", "[chat] nbc"},
+		{"usedpizza", "donation", "20.00", "Moo", "[donation msg] moo"},
+		{"aerionblue", "resub", "5.00", "moo"},
+	}
+	if got := contestResultRows(table, contest); !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(want, got))
+	}
+	if got := contestResultRows(nil, contest); got != nil {
+		t.Errorf("got %v for an empty table, want nil", got)
+	}
+}
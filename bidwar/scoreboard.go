@@ -0,0 +1,45 @@
+package bidwar
+
+import (
+	"time"
+)
+
+// ScoreboardRows formats the standings of every Contest in the Collection as
+// spreadsheet rows, suitable for a public scoreboard. Each contest gets a
+// header row followed by one row per option, winning option first.
+func ScoreboardRows(c Collection, totals []Total, updated time.Time) [][]interface{} {
+	rows := [][]interface{}{
+		{"Last updated", updated.Format("Jan 2 3:04 PM MST")},
+	}
+	for _, con := range c.Contests {
+		status := "open"
+		if con.Closed {
+			status = "closed"
+		}
+		rows = append(rows, []interface{}{}, []interface{}{con.Name, "", status})
+		for _, t := range contestTotals(con, withZeroTotals(con, totals)).totals {
+			optStatus := ""
+			if t.Option.Closed {
+				optStatus = "closed"
+			}
+			rows = append(rows, []interface{}{t.Option.DisplayName, t.Value.String(), optStatus})
+		}
+	}
+	return rows
+}
+
+// withZeroTotals adds a zero Total for every option in the Contest that
+// isn't already in the list.
+func withZeroTotals(con Contest, totals []Total) []Total {
+	seen := make(map[string]bool)
+	for _, t := range totals {
+		seen[t.Option.ShortCode] = true
+	}
+	all := append([]Total(nil), totals...)
+	for _, opt := range con.Options {
+		if !seen[opt.ShortCode] {
+			all = append(all, Total{Option: opt})
+		}
+	}
+	return all
+}
@@ -0,0 +1,46 @@
+package bidwar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestScoreboardRows(t *testing.T) {
+	bidwars, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	bidwars.Contests[1].Closed = true
+	opts := make(map[string]Option)
+	for _, con := range bidwars.Contests {
+		for _, opt := range con.Options {
+			opts[opt.ShortCode] = opt
+		}
+	}
+	totals := []Total{
+		{Option: opts["Moo"], Value: donation.CentsValue(1000)},
+		{Option: opts["NBC"], Value: donation.CentsValue(2550)},
+		{Option: opts["DMC2"], Value: donation.CentsValue(500)},
+	}
+	updated := time.Date(2022, 7, 1, 20, 15, 0, 0, time.UTC)
+
+	want := [][]interface{}{
+		{"Last updated", "Jul 1 8:15 PM UTC"},
+		{},
+		{"Mario Kart track", "", "open"},
+		{"Neo Bowser City", "25.50", ""},
+		{"Moo Moo Meadows", "10.00", ""},
+		{},
+		{"Featuring Dante From The Devil May Cry Series", "", "closed"},
+		{"Devil May Cry 2", "5.00", ""},
+		{"Devil May Cry", "0.00", ""},
+		{"Devil May Cry 3", "0.00", ""},
+	}
+	if diff := deep.Equal(ScoreboardRows(bidwars, totals, updated), want); diff != nil {
+		t.Error(diff)
+	}
+}
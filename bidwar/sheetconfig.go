@@ -0,0 +1,175 @@
+package bidwar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Columns of the bid war sheet.
+const (
+	sheetColContest = iota
+	sheetColDisplayName
+	sheetColShortCode
+	sheetColAliases
+	sheetColClosed
+	sheetColSummaryStyle
+)
+
+// ParseSheetRows reads a Collection from the rows of a bid war sheet. The
+// first row is a header, and is ignored. Each following row is either an
+// option or a contest:
+//
+//	Contest          | Option          | Short Code | Aliases        | Closed | Summary Style
+//	Mario Kart track |                 |            |                |        | LAST_PLACE
+//	                 | Moo Moo Meadows | Moo        | moo, moomoo    |        |
+//	                 | Neo Bowser City | NBC        | nbc, neo       | TRUE   |
+//
+// An option row has a short code. Its contest is the one named in the row,
+// or the one in the nearest row above it if the Contest cell is blank. A row
+// with no short code sets the contest's Closed flag and summary style. Blank
+// rows are skipped.
+func ParseSheetRows(rows [][]interface{}) (Collection, error) {
+	var c Collection
+	index := make(map[string]int)
+	current := ""
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		r := donationRow(row)
+		if name := strings.TrimSpace(r.column(sheetColContest)); name != "" {
+			current = name
+		}
+		code := strings.TrimSpace(r.column(sheetColShortCode))
+		if current == "" {
+			if code != "" {
+				return Collection{}, fmt.Errorf("row %d: option %q has no contest", i+1, code)
+			}
+			continue
+		}
+		ci, ok := index[strings.ToLower(current)]
+		if !ok {
+			ci = len(c.Contests)
+			index[strings.ToLower(current)] = ci
+			c.Contests = append(c.Contests, Contest{Name: current, SummaryStyle: AllSummary, NumberOfWinners: 1})
+		}
+		con := &c.Contests[ci]
+
+		if code == "" {
+			if r.column(sheetColContest) == "" {
+				continue // A blank row
+			}
+			con.Closed = cellBool(row, sheetColClosed)
+			if style := strings.TrimSpace(r.column(sheetColSummaryStyle)); style != "" {
+				con.SummaryStyle = SummaryStyle(strings.ToUpper(style))
+				if !con.SummaryStyle.valid() {
+					return Collection{}, fmt.Errorf("row %d: unknown summary style %q", i+1, style)
+				}
+			}
+			continue
+		}
+		var aliases []string
+		for _, a := range strings.Split(r.column(sheetColAliases), ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				aliases = append(aliases, a)
+			}
+		}
+		displayName := strings.TrimSpace(r.column(sheetColDisplayName))
+		if displayName == "" {
+			displayName = code
+		}
+		opt, err := NewOption(displayName, code, aliases)
+		if err != nil {
+			return Collection{}, fmt.Errorf("row %d: %v", i+1, err)
+		}
+		if _, dup := c.OptionByShortCode(code); dup {
+			return Collection{}, fmt.Errorf("row %d: short code %q is used more than once", i+1, code)
+		}
+		opt.Closed = cellBool(row, sheetColClosed)
+		con.Options = append(con.Options, opt)
+	}
+	c.fillAliases()
+	return c, nil
+}
+
+// cellBool interprets a sheet cell as a checkbox. Checkboxes come back as
+// bools, but people also type TRUE, yes, or x.
+func cellBool(row []interface{}, n int) bool {
+	if n >= len(row) {
+		return false
+	}
+	switch v := row[n].(type) {
+	case bool:
+		return v
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "y", "x", "closed":
+			return true
+		}
+	}
+	return false
+}
+
+// SheetEdits works out how to make a bid war sheet (given as rows, like
+// ParseSheetRows) match the Collection, after contests and options have been
+// opened, closed, added, or removed. Other cells are left alone, so the
+// organizers' formatting and notes survive.
+//
+// It returns the edits to the existing rows, where nil cells are left
+// unchanged, and the new rows to append to the end of the sheet.
+func SheetEdits(rows [][]interface{}, c Collection) (edits [][]interface{}, newRows [][]interface{}) {
+	edits = make([][]interface{}, len(rows))
+	seenContests := make(map[string]bool)
+	seenOptions := make(map[string]bool)
+	current := ""
+	for i, row := range rows {
+		edits[i] = []interface{}{}
+		if i == 0 {
+			continue
+		}
+		r := donationRow(row)
+		named := strings.TrimSpace(r.column(sheetColContest))
+		if named != "" {
+			current = named
+		}
+		con, conOK := c.ContestByName(current)
+		code := strings.TrimSpace(r.column(sheetColShortCode))
+		if code == "" {
+			if named != "" && conOK {
+				seenContests[strings.ToLower(con.Name)] = true
+				edits[i] = []interface{}{nil, nil, nil, nil, con.Closed}
+			}
+			continue
+		}
+		if opt, ok := c.OptionByShortCode(code); ok {
+			seenOptions[strings.ToLower(opt.ShortCode)] = true
+			edits[i] = []interface{}{nil, nil, nil, nil, opt.Closed}
+			continue
+		}
+		// The option was removed. If this row also names the contest, it
+		// becomes the contest's row; otherwise, it becomes blank.
+		if named != "" && conOK {
+			seenContests[strings.ToLower(con.Name)] = true
+			edits[i] = []interface{}{nil, "", "", "", con.Closed}
+		} else {
+			edits[i] = []interface{}{nil, "", "", "", ""}
+		}
+	}
+
+	for _, con := range c.Contests {
+		if con.Closed && !seenContests[strings.ToLower(con.Name)] {
+			newRows = append(newRows, []interface{}{con.Name, "", "", "", true})
+		}
+		for _, opt := range con.Options {
+			if seenOptions[strings.ToLower(opt.ShortCode)] {
+				continue
+			}
+			var aliases []string
+			for _, a := range opt.Aliases {
+				aliases = append(aliases, a.src)
+			}
+			newRows = append(newRows, []interface{}{con.Name, opt.DisplayName, opt.ShortCode, strings.Join(aliases, ", "), opt.Closed})
+		}
+	}
+	return edits, newRows
+}
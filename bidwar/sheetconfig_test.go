@@ -0,0 +1,104 @@
+package bidwar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseSheetRows(t *testing.T) {
+	rows := [][]interface{}{
+		{"Contest", "Option", "Short Code", "Aliases", "Closed", "Summary Style"},
+		{"Mario Kart track", "", "", "", "", "last_place"},
+		{"", "Moo Moo Meadows", "Moo", "moo, moomoo", false},
+		{"", "Neo Bowser City", "NBC", "nbc,neo", true},
+		{},
+		{"Devil May Cry", "Devil May Cry 3", "DMC3", "dmc3, dmc 3"},
+		{"", "", "DMC1", "dmc1", "x"},
+		{"Retired contest", "", "", "", "TRUE"},
+	}
+	c, err := ParseSheetRows(rows)
+	if err != nil {
+		t.Fatalf("error parsing rows: %v", err)
+	}
+	if len(c.Contests) != 3 {
+		t.Fatalf("got %d contests, want 3: %+v", len(c.Contests), c.Contests)
+	}
+	mk := c.Contests[0]
+	if mk.Name != "Mario Kart track" || mk.SummaryStyle != "LAST_PLACE" || mk.Closed || len(mk.Options) != 2 {
+		t.Errorf("wrong Mario Kart contest: %+v", mk)
+	}
+	if nbc := mk.Options[1]; nbc.ShortCode != "NBC" || !nbc.Closed || nbc.DisplayName != "Neo Bowser City" {
+		t.Errorf("wrong NBC option: %+v", nbc)
+	}
+	dmc := c.Contests[1]
+	if dmc.SummaryStyle != "ALL" || dmc.NumberOfWinners != 1 || len(dmc.Options) != 2 {
+		t.Errorf("wrong DMC contest: %+v", dmc)
+	}
+	if dmc1 := dmc.Options[1]; dmc1.DisplayName != "DMC1" || !dmc1.Closed {
+		t.Errorf("wrong DMC1 option: %+v", dmc1)
+	}
+	if !c.Contests[2].Closed {
+		t.Errorf("retired contest should be closed")
+	}
+	if got := c.ChoiceFromMessage("put it on moomoo", FromChatMessage); got.Option.ShortCode != "Moo" {
+		t.Errorf("got %q for \"moomoo\", want Moo", got.Option.ShortCode)
+	}
+}
+
+func TestParseSheetRows_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		rows [][]interface{}
+	}{
+		{"option with no contest", [][]interface{}{{"header"}, {"", "Moo Moo Meadows", "Moo", "moo"}}},
+		{"duplicate short code", [][]interface{}{{"header"}, {"A", "", "Moo", "moo"}, {"B", "", "moo", "moo"}}},
+		{"bad alias", [][]interface{}{{"header"}, {"A", "", "Moo", "(moo"}}},
+	} {
+		if _, err := ParseSheetRows(tc.rows); err == nil {
+			t.Errorf("%s: got no error", tc.desc)
+		}
+	}
+}
+
+func TestSheetEdits(t *testing.T) {
+	rows := [][]interface{}{
+		{"Contest", "Option", "Short Code", "Aliases", "Closed"},
+		{"Mario Kart track", "Moo Moo Meadows", "Moo", "moo", false},
+		{"", "Neo Bowser City", "NBC", "nbc", false},
+		{"Devil May Cry", "Devil May Cry 3", "DMC3", "dmc3"},
+	}
+	c, err := ParseSheetRows(rows)
+	if err != nil {
+		t.Fatalf("error parsing rows: %v", err)
+	}
+	c, _ = c.SetOptionClosed("NBC", true)
+	c, _ = c.SetContestClosed("Devil May Cry", true, time.Now())
+	c, _ = c.RemoveOption("Moo")
+	rr, err := NewOption("Rainbow Road", "RR", []string{"rainbow road", "rr"})
+	if err != nil {
+		t.Fatalf("error creating option: %v", err)
+	}
+	if c, err = c.AddOption("Mario Kart track", rr); err != nil {
+		t.Fatalf("error adding option: %v", err)
+	}
+
+	edits, newRows := SheetEdits(rows, c)
+	wantEdits := [][]interface{}{
+		{},
+		{nil, "", "", "", false},
+		{nil, nil, nil, nil, true},
+		{nil, nil, nil, nil, false},
+	}
+	if diff := deep.Equal(edits, wantEdits); diff != nil {
+		t.Errorf("edits: %v", diff)
+	}
+	wantNewRows := [][]interface{}{
+		{"Mario Kart track", "Rainbow Road", "RR", "rainbow road, rr", false},
+		{"Devil May Cry", "", "", "", true},
+	}
+	if diff := deep.Equal(newRows, wantNewRows); diff != nil {
+		t.Errorf("new rows: %v", diff)
+	}
+}
@@ -0,0 +1,320 @@
+package bidwar
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/money"
+)
+
+// Matches an amount in a split bid: either a dollar amount ("$3", "$2.50") or
+// a percentage ("50%", "33.3 %").
+var splitAmount = regexp.MustCompile(`\$\s*(\d+(?:\.\d{1,2})?)|(\d+(?:\.\d+)?)\s*%`)
+
+// Matches a partial bid: a command followed by a dollar amount, with or
+// without the "$" ("!bid 5 moo", "!bid $2.50 nbc").
+var partialAmount = regexp.MustCompile(`^\s*!\S+\s+\$?\s*(\d+(?:\.\d{1,2})?)\s+`)
+
+// ErrSplitTooBig means that a split bid asked for more points than the donor
+// has.
+var ErrSplitTooBig = errors.New("split adds up to more than the donor's points")
+
+// SplitPart is one share of a split bid. Exactly one of Percent and Cents is
+// set.
+type SplitPart struct {
+	Option  Option
+	Percent float64
+	Cents   donation.CentsValue
+	// If the donor bid on an option in a locked contest, and the share went
+	// to the contest's overflow option instead, the short code they bid on.
+	OverflowFrom string
+}
+
+// Split is a bid that divides a donor's points among several Options. A
+// partial bid is a Split with a single part.
+type Split struct {
+	Parts  []SplitPart
+	Reason string
+}
+
+// SplitFromMessage looks for a split bid in a message, like "50% moo 50% nbc"
+// or "$3 moo, $2 dmc1". Each amount applies to the first open Option named
+// after it. Returns a nil Split (and no error) if the message isn't a split
+// bid, i.e., if it doesn't give amounts for at least two Options, and isn't a
+// partial bid either (see partialFromMessage).
+func (c Collection) SplitFromMessage(msg string) (*Split, error) {
+	locs := splitAmount.FindAllStringSubmatchIndex(msg, -1)
+	if len(locs) < 2 {
+		return c.partialFromMessage(msg)
+	}
+	openOptions := c.AllOpenOptions()
+	var parts []SplitPart
+	totalPercent := 0.0
+	for i, loc := range locs {
+		end := len(msg)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		opt, _ := leftmostMatch(openOptions, msg[loc[1]:end])
+		if opt.IsZero() {
+			continue
+		}
+		part := SplitPart{Option: opt}
+		if over, ok := c.overflowFor(opt); ok {
+			part = SplitPart{Option: over, OverflowFrom: opt.ShortCode}
+		}
+		if loc[2] >= 0 {
+			cents, err := money.ParseCents(msg[loc[2]:loc[3]])
+			if err != nil {
+				return nil, err
+			}
+			part.Cents = donation.CentsValue(cents)
+		} else {
+			pct, err := strconv.ParseFloat(msg[loc[4]:loc[5]], 64)
+			if err != nil {
+				return nil, err
+			}
+			part.Percent = pct
+			totalPercent += pct
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) < 2 {
+		return nil, nil
+	}
+	for _, p := range parts[1:] {
+		if (p.Percent > 0) != (parts[0].Percent > 0) {
+			return nil, errors.New("use either percentages or dollar amounts, not both")
+		}
+	}
+	if totalPercent > 100.001 {
+		return nil, fmt.Errorf("that adds up to %g%%", totalPercent)
+	}
+	return &Split{Parts: parts, Reason: c.reasonString(FromChatMessage, strings.TrimSpace(msg))}, nil
+}
+
+// partialFromMessage looks for a partial bid, like "!bid 5 moo", which puts
+// only $5 of the donor's points towards an Option and leaves the rest
+// unassigned. Returns nil if the message doesn't start with an amount, or if
+// the amount is really part of an Option's name (e.g., "!bid 64 mario" when
+// "64 mario" is an alias).
+func (c Collection) partialFromMessage(msg string) (*Split, error) {
+	loc := partialAmount.FindStringSubmatchIndex(msg)
+	if loc == nil {
+		return nil, nil
+	}
+	openOptions := c.AllOpenOptions()
+	if _, md := leftmostMatch(openOptions, msg); md.Alias != "" && md.Index < loc[1] {
+		return nil, nil
+	}
+	opt, _ := leftmostMatch(openOptions, msg[loc[1]:])
+	if opt.IsZero() {
+		return nil, nil
+	}
+	part := SplitPart{Option: opt}
+	if over, ok := c.overflowFor(opt); ok {
+		part = SplitPart{Option: over, OverflowFrom: opt.ShortCode}
+	}
+	cents, err := money.ParseCents(msg[loc[2]:loc[3]])
+	if err != nil {
+		return nil, err
+	}
+	if cents <= 0 {
+		return nil, errors.New("the amount must be more than $0")
+	}
+	part.Cents = donation.CentsValue(cents)
+	return &Split{Parts: []SplitPart{part}, Reason: c.reasonString(FromChatMessage, strings.TrimSpace(msg))}, nil
+}
+
+// shares works out how many cents of the total go to each part. Percentages
+// are rounded down, except that if they add up to 100%, the last part gets
+// whatever is left over.
+func (s Split) shares(total int) ([]int, error) {
+	shares := make([]int, len(s.Parts))
+	sum := 0
+	percent := 0.0
+	for i, p := range s.Parts {
+		if p.Percent > 0 {
+			shares[i] = int(float64(total) * p.Percent / 100)
+			percent += p.Percent
+		} else {
+			shares[i] = p.Cents.Cents()
+		}
+		sum += shares[i]
+	}
+	if percent > 99.999 {
+		shares[len(shares)-1] += total - sum
+		sum = total
+	}
+	if sum > total {
+		return nil, ErrSplitTooBig
+	}
+	return shares, nil
+}
+
+// AssignSplit divides the donor's unassigned donations among the Options in
+// the Split. Donations are split into partial rows where necessary. Anything
+// that isn't covered by the Split stays unassigned. Returns the UpdateStats
+// for each part, in order.
+func (t Tallier) AssignSplit(donor string, split Split) ([]UpdateStats, error) {
+	if donor == "" {
+		return nil, errors.New("donor must not be empty")
+	}
+	valueRange, err := t.table.GetTable()
+	if err != nil {
+		return nil, fmt.Errorf("error reading donation table: %v", err)
+	}
+	names := t.donorNames(donor, valueRange)
+	total := 0
+	for _, row := range valueRange.Values {
+		if dr := donationRow(row); isUnassignedFor(dr, names) {
+			total += dr.Cents()
+		}
+	}
+	shares, err := split.shares(total)
+	if err != nil {
+		return nil, err
+	}
+
+	vrToWrite, newRows, assigned := makeSplit(valueRange, names, split, shares)
+	if total > 0 {
+		if len(newRows) > 0 {
+			err = t.table.WriteTableAndAppend(vrToWrite, newRows)
+		} else {
+			_, err = t.table.WriteTable(vrToWrite)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error updating spreadsheet: %v", err)
+		}
+		log.Printf("split %s among %d options for %s", donation.CentsValue(total), len(split.Parts), donor)
+	}
+
+	stats := make([]UpdateStats, len(split.Parts))
+	for i, p := range split.Parts {
+		stats[i] = UpdateStats{
+			Choice:     Choice{Option: p.Option, Reason: split.Reason, Match: MatchDetails{OverflowFrom: p.OverflowFrom}},
+			Count:      assigned[i].count,
+			TotalValue: donation.CentsValue(assigned[i].cents),
+		}
+		if assigned[i].cents > 0 {
+			if stats[i].OverCap, err = t.ApplyCap(donor, p.Option); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// isUnassignedFor reports whether a row is an unassigned donation made under
+// any of the given names.
+func isUnassignedFor(dr donationRow, donorNames []string) bool {
+	return dr.Choice() == "" && isDonor(dr, donorNames)
+}
+
+// isDonor reports whether a row is a donation made under any of the given
+// names.
+func isDonor(dr donationRow, donorNames []string) bool {
+	for _, name := range donorNames {
+		if strings.EqualFold(dr.Contributor(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Appended to the description of a row that was split off from another row.
+const splitSuffix = " (split)"
+
+// IsSplitOff reports whether a row of the donation table, going by its
+// description, was split off from another row (by a split bid or a donor
+// cap) instead of being a donation of its own.
+func IsSplitOff(what string) bool {
+	return strings.HasSuffix(what, splitSuffix) || strings.HasSuffix(what, overCapSuffix)
+}
+
+type splitResult struct {
+	count int
+	cents int
+}
+
+// makeSplit works out how to edit the donation table to implement a split.
+// The donor's unassigned rows are used in order, filling each part's share
+// before moving on to the next. When a row straddles two shares, the row is
+// cut down to the first share, and the rest goes into new rows, which keep
+// the row's extra columns (with the net cash divided among them). It returns a
+// ValueRange with the edits to existing rows, the new rows to append, and how
+// much went to each part.
+func makeSplit(vr *sheets.ValueRange, donorNames []string, split Split, shares []int) (*sheets.ValueRange, [][]interface{}, []splitResult) {
+	newValues := make([][]interface{}, len(vr.Values))
+	var newRows [][]interface{}
+	results := make([]splitResult, len(shares))
+	part := 0
+	remaining := 0
+	if len(shares) > 0 {
+		remaining = shares[0]
+	}
+	for i, row := range vr.Values {
+		newValues[i] = []interface{}{}
+		dr := donationRow(row)
+		if !isUnassignedFor(dr, donorNames) {
+			continue
+		}
+		rowCents := dr.Cents()
+		first := true
+		splitNet := 0
+		for rowCents > 0 {
+			for part < len(shares) && remaining == 0 {
+				part++
+				if part < len(shares) {
+					remaining = shares[part]
+				}
+			}
+			take, code := rowCents, ""
+			if part < len(shares) {
+				if remaining < take {
+					take = remaining
+				}
+				code = split.Parts[part].Option.ShortCode
+				remaining -= take
+				results[part].count++
+				results[part].cents += take
+			}
+			if first {
+				var points interface{}
+				if take != dr.Cents() {
+					points = float64(take) / 100
+				}
+				if code == "" {
+					newValues[i] = []interface{}{nil, nil, points}
+				} else {
+					newValues[i] = []interface{}{nil, nil, points, code, split.Reason}
+				}
+				first = false
+			} else {
+				newRow := []interface{}{dr.Contributor(), dr.column(1) + splitSuffix, float64(take) / 100}
+				if code != "" {
+					newRow = append(newRow, code, split.Reason)
+				}
+				newRow, net := dr.partRow(newRow, take)
+				newRows = append(newRows, newRow)
+				splitNet += net
+			}
+			rowCents -= take
+		}
+		if net, ok := dr.Net(); ok && splitNet != 0 {
+			newValues[i] = dr.withNet(newValues[i], net-splitNet)
+		}
+	}
+	return &sheets.ValueRange{
+		MajorDimension: vr.MajorDimension,
+		Range:          vr.Range,
+		Values:         newValues,
+	}, newRows, results
+}
@@ -0,0 +1,149 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestSplitFromMessage(t *testing.T) {
+	bidwars, err := Parse([]byte(testJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	for _, tc := range []struct {
+		desc      string
+		msg       string
+		wantCodes []string
+		wantErr   bool
+	}{
+		{"percentages", "!bid 50% moo 50% nbc", []string{"Moo", "NBC"}, false},
+		{"dollars", "!bid $3 moo, $2 dmc1", []string{"Moo", "DMC1"}, false},
+		{"not a split", "!bid moo", nil, false},
+		{"only one option", "!bid 100% moo", nil, false},
+		{"partial", "!bid 5 moo", []string{"Moo"}, false},
+		{"partial with dollar sign", "!bid $2.50 nbc", []string{"NBC"}, false},
+		{"partial without an option", "!bid 5 tetris", nil, false},
+		{"number in the middle", "!bid moo 5 times", nil, false},
+		{"zero partial", "!bid 0 moo", nil, true},
+		{"amount without an option", "!bid 50% moo 50% tetris", nil, false},
+		{"mixed", "!bid 50% moo $2 nbc", nil, true},
+		{"too much", "!bid 80% moo 80% nbc", nil, true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := bidwars.SplitFromMessage(tc.msg)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tc.wantErr)
+			}
+			var gotCodes []string
+			if got != nil {
+				for _, p := range got.Parts {
+					gotCodes = append(gotCodes, p.Option.ShortCode)
+				}
+			}
+			if diff := deep.Equal(gotCodes, tc.wantCodes); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSplitShares(t *testing.T) {
+	moo, nbc := Option{ShortCode: "Moo"}, Option{ShortCode: "NBC"}
+	for _, tc := range []struct {
+		desc    string
+		parts   []SplitPart
+		total   int
+		want    []int
+		wantErr bool
+	}{
+		{"even split", []SplitPart{{Option: moo, Percent: 50}, {Option: nbc, Percent: 50}}, 1001, []int{500, 501}, false},
+		{"partial percent", []SplitPart{{Option: moo, Percent: 25}, {Option: nbc, Percent: 25}}, 1000, []int{250, 250}, false},
+		{"dollars", []SplitPart{{Option: moo, Cents: 300}, {Option: nbc, Cents: 200}}, 1000, []int{300, 200}, false},
+		{"not enough points", []SplitPart{{Option: moo, Cents: 800}, {Option: nbc, Cents: 800}}, 1000, nil, true},
+	} {
+		got, err := Split{Parts: tc.parts}.shares(tc.total)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error: %v", tc.desc, err, tc.wantErr)
+		}
+		if diff := deep.Equal(got, tc.want); diff != nil {
+			t.Errorf("%s: %v", tc.desc, diff)
+		}
+	}
+}
+
+func TestMakeSplit(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Range:          "Tracker!A:E",
+		MajorDimension: "ROWS",
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message"},
+			{"aerionblue", "resub", "5.00"},
+			{"AEWC20XX", "resub", "5.00"},
+			{"aerionblue", "donation", "10.00", "", ""},
+			{"aerionblue", "donation", "5.01", "Leon", "put this towards Leon"},
+		},
+	}
+	split := Split{
+		Parts:  []SplitPart{{Option: Option{ShortCode: "Moo"}, Cents: 700}, {Option: Option{ShortCode: "NBC"}, Cents: 600}},
+		Reason: "[chat] $7 moo $6 nbc",
+	}
+	gotVR, gotRows, gotResults := makeSplit(vr, []string{"aerionblue"}, split, []int{700, 600})
+
+	wantValues := [][]interface{}{
+		{},
+		{nil, nil, nil, "Moo", split.Reason},
+		{},
+		{nil, nil, 2.0, "Moo", split.Reason},
+		{},
+	}
+	if diff := deep.Equal(gotVR.Values, wantValues); diff != nil {
+		t.Errorf("edited rows: %v", diff)
+	}
+	wantRows := [][]interface{}{
+		{"aerionblue", "donation (split)", 6.0, "NBC", split.Reason},
+		{"aerionblue", "donation (split)", 2.0},
+	}
+	if diff := deep.Equal(gotRows, wantRows); diff != nil {
+		t.Errorf("new rows: %v", diff)
+	}
+	wantResults := []splitResult{{count: 2, cents: 700}, {count: 1, cents: 600}}
+	if diff := deep.Equal(gotResults, wantResults); diff != nil {
+		t.Errorf("results: %v", diff)
+	}
+	if got := donation.CentsValue(gotResults[0].cents + gotResults[1].cents); got != donation.CentsValue(1300) {
+		t.Errorf("assigned %s in total, want 13.00", got)
+	}
+}
+
+func TestMakeSplit_ExtraColumns(t *testing.T) {
+	vr := &sheets.ValueRange{
+		Range:          "Tracker!A:J",
+		MajorDimension: "ROWS",
+		Values: [][]interface{}{
+			{"aerionblue", "donation", 10.0, "", "", "streamlabs", "Pizza", "1234", "2021-10-16 12:00:00", 9.41},
+		},
+	}
+	split := Split{
+		Parts:  []SplitPart{{Option: Option{ShortCode: "Moo"}, Cents: 300}, {Option: Option{ShortCode: "NBC"}, Cents: 300}},
+		Reason: "[chat] $3 moo $3 nbc",
+	}
+	gotVR, gotRows, _ := makeSplit(vr, []string{"aerionblue"}, split, []int{300, 300})
+
+	wantValues := [][]interface{}{
+		{nil, nil, 3.0, "Moo", split.Reason, nil, nil, nil, nil, 2.83},
+	}
+	if diff := deep.Equal(gotVR.Values, wantValues); diff != nil {
+		t.Errorf("edited rows: %v", diff)
+	}
+	wantRows := [][]interface{}{
+		{"aerionblue", "donation (split)", 3.0, "NBC", split.Reason, "streamlabs", "Pizza", "1234", "2021-10-16 12:00:00", 2.82},
+		{"aerionblue", "donation (split)", 4.0, "", "", "streamlabs", "Pizza", "1234", "2021-10-16 12:00:00", 3.76},
+	}
+	if diff := deep.Equal(gotRows, wantRows); diff != nil {
+		t.Errorf("new rows: %v", diff)
+	}
+}
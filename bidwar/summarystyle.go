@@ -0,0 +1,51 @@
+package bidwar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// SummaryStyle is how the current status of a contest is reported to users.
+// It doesn't affect how bids are tallied.
+type SummaryStyle string
+
+const (
+	// All options are reported, in descending order (i.e., winning option
+	// first).
+	AllSummary SummaryStyle = "ALL"
+	// The first place option, and where the last bid's option stands.
+	FirstPlaceSummary SummaryStyle = "FIRST_PLACE"
+	// The last place option, and where the last bid's option stands.
+	LastPlaceSummary SummaryStyle = "LAST_PLACE"
+	// The contest's NumberOfWinners leading options.
+	WinnersSummary SummaryStyle = "WINNERS"
+	// Each option's share of the contest's total, e.g. "Moo 62%, NBC 38%".
+	PercentageSummary SummaryStyle = "PERCENTAGE"
+)
+
+func (s SummaryStyle) valid() bool {
+	switch s {
+	case AllSummary, FirstPlaceSummary, LastPlaceSummary, WinnersSummary, PercentageSummary:
+		return true
+	}
+	return false
+}
+
+func (tt Totals) describePercentage() string {
+	open := tt.openTotals()
+	var sum donation.CentsValue
+	for _, t := range open {
+		sum += t.Value
+	}
+	var strs []string
+	for _, t := range open {
+		pct := 0
+		if sum > 0 {
+			pct = int((t.Value*100 + sum/2) / sum)
+		}
+		strs = append(strs, fmt.Sprintf("%s %d%%", t.Option.DisplayName, pct))
+	}
+	return strings.Join(strs, ", ")
+}
@@ -0,0 +1,93 @@
+package bidwar
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+)
+
+// summaryData is what a contest's SummaryTemplate can refer to.
+type summaryData struct {
+	// The contest's name.
+	Contest string
+	// The option in first place. Tied options are joined with " and ".
+	Leader string
+	// How far first place is ahead of second place.
+	Margin string
+	// The option in last place. Tied options are joined with " and ".
+	Last string
+	// Every open option and its total, winning option first, as in the ALL
+	// summary style.
+	Totals string
+	// The option that was just bid on, and its rank (1 for first place).
+	// Empty (and 0) if there was no bid, e.g. for !refresh.
+	LastBid     string
+	LastBidRank int
+}
+
+// parseSummaryTemplate parses a contest's SummaryTemplate.
+func parseSummaryTemplate(text string) (*template.Template, error) {
+	return template.New("summary").Parse(text)
+}
+
+// describeTemplate fills in the contest's SummaryTemplate. It returns false
+// if the template couldn't be used, so that the caller can fall back to the
+// summary style.
+func (tt Totals) describeTemplate(lastBid Option) (string, bool) {
+	tmpl, err := parseSummaryTemplate(tt.template)
+	if err != nil {
+		log.Printf("ERROR parsing summary template for %s: %v", tt.contest, err)
+		return "", false
+	}
+	ranks := tt.computeRanks()
+	if len(ranks) == 0 {
+		return "", true
+	}
+	d := summaryData{
+		Contest: tt.contest,
+		Leader:  rankNames(ranks[0]),
+		Last:    rankNames(ranks[len(ranks)-1]),
+		Totals:  tt.describeAll(),
+		Margin:  tt.metric.Format(0),
+	}
+	if len(ranks) > 1 {
+		d.Margin = tt.metric.Format(ranks[0].value - ranks[1].value)
+	}
+	if !lastBid.IsZero() {
+		if r := findRankForBid(ranks, lastBid); r != nil {
+			d.LastBid = lastBid.DisplayName
+			d.LastBidRank = r.rank
+		}
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, d); err != nil {
+		log.Printf("ERROR filling in summary template for %s: %v", tt.contest, err)
+		return "", false
+	}
+	return strings.TrimSpace(b.String()), true
+}
+
+func rankNames(r *optionRank) string {
+	var names []string
+	for _, opt := range r.options {
+		names = append(names, opt.DisplayName)
+	}
+	return strings.Join(names, " and ")
+}
+
+// validateSummaryTemplate checks that a contest's SummaryTemplate parses and
+// only refers to fields that exist.
+func validateSummaryTemplate(con Contest) error {
+	if con.SummaryTemplate == "" {
+		return nil
+	}
+	tmpl, err := parseSummaryTemplate(con.SummaryTemplate)
+	if err != nil {
+		return fmt.Errorf("contest %q has an invalid summaryTemplate: %v", con.Name, err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, summaryData{}); err != nil {
+		return fmt.Errorf("contest %q has an invalid summaryTemplate: %v", con.Name, err)
+	}
+	return nil
+}
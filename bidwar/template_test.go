@@ -0,0 +1,45 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestDescribe_SummaryTemplate(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	totals := []Total{{Option: nbc, Value: donation.CentsValue(1200)}, {Option: moo, Value: donation.CentsValue(1100)}}
+	for _, tc := range []struct {
+		template string
+		lastBid  Option
+		want     string
+	}{
+		{"{{.Leader}} leads by {{.Margin}}!", Option{}, "Neo Bowser City leads by 1.00!"},
+		{"{{if .LastBid}}{{.LastBid}} is #{{.LastBidRank}}. {{end}}{{.Last}} is losing", moo, "Moo Moo Meadows is #2. Moo Moo Meadows is losing"},
+		{"{{.Contest}}: {{.Totals}}", Option{}, "Mario Kart track: Neo Bowser City: 12.00, Moo Moo Meadows: 11.00 (down by 1.00)"},
+		// A template that fails falls back to the summary style.
+		{"{{.Leader.Nope}}", Option{}, "Neo Bowser City: 12.00, Moo Moo Meadows: 11.00 (down by 1.00)"},
+	} {
+		tt := Totals{totals: totals, summaryStyle: AllSummary, contest: "Mario Kart track", template: tc.template}
+		if got := tt.Describe(tc.lastBid); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.template, got, tc.want)
+		}
+	}
+}
+
+func TestParse_SummaryTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		wantErr  bool
+	}{
+		{"{{.Leader}} by {{.Margin}}", false},
+		{"{{.Leader", true},
+		{"{{.Winner}}", true},
+	} {
+		_, err := Parse([]byte(`{"contests": [{"name": "Mario Kart track", "summaryTemplate": "` + tc.template + `", "options": []}]}`))
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%q: got error %v, want error: %v", tc.template, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package bidwar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// TieBreaker decides the winner when a contest ends in a tie.
+type TieBreaker string
+
+const (
+	// Announce the tie and leave it to the streamers.
+	NoTieBreaker TieBreaker = ""
+	// The tied option that reached its total first wins.
+	EarliestTieBreaker TieBreaker = "EARLIEST"
+	// A random tied option wins, and chat is told that a coin was flipped.
+	CoinFlipTieBreaker TieBreaker = "COIN_FLIP"
+	// All the tied options win.
+	BothWinTieBreaker TieBreaker = "BOTH_WIN"
+)
+
+func (tb TieBreaker) valid() bool {
+	switch tb {
+	case NoTieBreaker, EarliestTieBreaker, CoinFlipTieBreaker, BothWinTieBreaker:
+		return true
+	}
+	return false
+}
+
+// Resolution is the final result of a contest.
+type Resolution struct {
+	Contest Contest
+	// The winning options. If the tie couldn't be broken, this only has the
+	// options that won outright.
+	Winners []Option
+	// The options that were tied for the last winning spot, if any.
+	Tied []Option
+	// The total that the tied options were tied at.
+	TiedValue donation.CentsValue
+	// The rule that was used to break the tie, if there was one.
+	Rule TieBreaker
+}
+
+// Describe announces the result, for chat.
+func (r Resolution) Describe() string {
+	if len(r.Winners) == 0 && len(r.Tied) == 0 {
+		return fmt.Sprintf("%s has no bids.", r.Contest.Name)
+	}
+	winners := strings.Join(optionNames(r.Winners), ", ")
+	if len(r.Tied) == 0 {
+		return fmt.Sprintf("%s is over! Winner: %s", r.Contest.Name, winners)
+	}
+	tie := fmt.Sprintf("%s is over! %s tied at %s.", r.Contest.Name, strings.Join(optionNames(r.Tied), ", "), r.Contest.Metric.Format(r.TiedValue))
+	switch r.Rule {
+	case EarliestTieBreaker:
+		return fmt.Sprintf("%s The one that got there first wins. Winner: %s", tie, winners)
+	case CoinFlipTieBreaker:
+		return fmt.Sprintf("%s I flipped a coin... Winner: %s", tie, winners)
+	case BothWinTieBreaker:
+		return fmt.Sprintf("%s They all win! Winner: %s", tie, winners)
+	}
+	if len(r.Winners) > 0 {
+		return fmt.Sprintf("%s Winner so far: %s. The streamers will break the tie.", tie, winners)
+	}
+	return tie + " The streamers will break the tie."
+}
+
+func optionNames(opts []Option) []string {
+	var names []string
+	for _, o := range opts {
+		names = append(names, o.DisplayName)
+	}
+	return names
+}
+
+// Resolve works out the winners of a contest, breaking any tie according to
+// the contest's TieBreaker.
+func (t Tallier) Resolve(contest Contest) (Resolution, error) {
+	totals, err := t.TotalsForContest(contest)
+	if err != nil {
+		return Resolution{}, err
+	}
+	var rows [][]interface{}
+	if contest.TieBreaker == EarliestTieBreaker {
+		vr, err := t.table.GetTable()
+		if err != nil {
+			return Resolution{}, fmt.Errorf("error reading donation table: %v", err)
+		}
+		rows = vr.Values
+	}
+	return resolve(contest, totals, rows, t.bidwars().Random.Intn), nil
+}
+
+// resolve picks the contest's NumberOfWinners winners from its totals. rows
+// is the donation table, which is only needed by the EARLIEST rule. intn is a
+// source of random numbers, like Random.Intn.
+func resolve(contest Contest, totals Totals, rows [][]interface{}, intn func(int) int) Resolution {
+	res := Resolution{Contest: contest}
+	ranks := totals.computeRanks()
+	n := contest.NumberOfWinners
+	if n < 1 {
+		n = 1
+	}
+	for _, r := range ranks {
+		if r.value <= 0 {
+			break
+		}
+		if len(res.Winners)+len(r.options) <= n {
+			res.Winners = append(res.Winners, r.options...)
+			if len(res.Winners) == n {
+				break
+			}
+			continue
+		}
+		// This rank straddles the cutoff, so it's a tie.
+		res.Tied = r.options
+		res.TiedValue = r.value
+		res.Rule = contest.TieBreaker
+		res.Winners = append(res.Winners, breakTie(contest, r, n-len(res.Winners), rows, intn)...)
+		break
+	}
+	return res
+}
+
+// breakTie picks which of the tied options in r win the remaining spots.
+func breakTie(contest Contest, r *optionRank, spots int, rows [][]interface{}, intn func(int) int) []Option {
+	tied := append([]Option(nil), r.options...)
+	switch contest.TieBreaker {
+	case EarliestTieBreaker:
+		reached := reachedAt(rows, contest.Metric, r.value)
+		rowFor := func(o Option) int {
+			if i, ok := reached[o.ShortCode]; ok {
+				return i
+			}
+			return len(rows)
+		}
+		sort.SliceStable(tied, func(i, j int) bool { return rowFor(tied[i]) < rowFor(tied[j]) })
+		return tied[:spots]
+	case CoinFlipTieBreaker:
+		for i := len(tied) - 1; i > 0; i-- {
+			j := intn(i + 1)
+			tied[i], tied[j] = tied[j], tied[i]
+		}
+		return tied[:spots]
+	case BothWinTieBreaker:
+		return tied
+	}
+	return nil
+}
+
+// reachedAt finds the row of the donation table (including the header) at
+// which each option's running total (according to the metric) first reached
+// value. Options that never got there aren't in the map.
+func reachedAt(rows [][]interface{}, metric Metric, value donation.CentsValue) map[string]int {
+	running := make(map[string]int)
+	reached := make(map[string]int)
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		dr := donationRow(row)
+		code := dr.Choice()
+		if code == "" {
+			continue
+		}
+		running[code] += metric.rowAmount(dr)
+		if _, ok := reached[code]; !ok && running[code] >= value.Cents() {
+			reached[code] = i
+		}
+	}
+	return reached
+}
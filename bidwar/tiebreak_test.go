@@ -0,0 +1,130 @@
+package bidwar
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestResolve(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	rr := Option{DisplayName: "Rainbow Road", ShortCode: "RR"}
+	tied := Totals{totals: []Total{{Option: moo, Value: 3000}, {Option: nbc, Value: 3000}, {Option: rr, Value: 1000}}}
+	// NBC reaches 30.00 on row 3, before Moo does on row 4.
+	rows := [][]interface{}{
+		{"Contributor", "What", "Points", "Choice", "Message"},
+		{"aerionblue", "sub", "20.00", "Moo"},
+		{"AEWC20XX", "donation", "10.00", "RR"},
+		{"Mizalie", "donation", "30.00", "NBC"},
+		{"usedpizza", "donation", "10.00", "Moo"},
+	}
+	// Always picks the first element when shuffling, which moves Moo to the
+	// end.
+	intn := func(int) int { return 0 }
+
+	for _, tc := range []struct {
+		desc    string
+		rule    TieBreaker
+		winners int
+		totals  Totals
+		want    Resolution
+	}{
+		{
+			"no tie",
+			EarliestTieBreaker,
+			1,
+			Totals{totals: []Total{{Option: moo, Value: 3000}, {Option: nbc, Value: 2000}}},
+			Resolution{Winners: []Option{moo}},
+		},
+		{
+			"tie left to the streamers",
+			NoTieBreaker,
+			1,
+			tied,
+			Resolution{Tied: []Option{moo, nbc}, TiedValue: 3000},
+		},
+		{
+			"earliest",
+			EarliestTieBreaker,
+			1,
+			tied,
+			Resolution{Winners: []Option{nbc}, Tied: []Option{moo, nbc}, TiedValue: 3000, Rule: EarliestTieBreaker},
+		},
+		{
+			"coin flip",
+			CoinFlipTieBreaker,
+			1,
+			tied,
+			Resolution{Winners: []Option{nbc}, Tied: []Option{moo, nbc}, TiedValue: 3000, Rule: CoinFlipTieBreaker},
+		},
+		{
+			"both win",
+			BothWinTieBreaker,
+			1,
+			tied,
+			Resolution{Winners: []Option{moo, nbc}, Tied: []Option{moo, nbc}, TiedValue: 3000, Rule: BothWinTieBreaker},
+		},
+		{
+			"two winners, no tie at the cutoff",
+			NoTieBreaker,
+			2,
+			tied,
+			Resolution{Winners: []Option{moo, nbc}},
+		},
+		{
+			"two winners, tie for second",
+			EarliestTieBreaker,
+			2,
+			Totals{totals: []Total{{Option: rr, Value: 5000}, {Option: moo, Value: 3000}, {Option: nbc, Value: 3000}}},
+			Resolution{Winners: []Option{rr, nbc}, Tied: []Option{moo, nbc}, TiedValue: 3000, Rule: EarliestTieBreaker},
+		},
+		{
+			"no bids",
+			CoinFlipTieBreaker,
+			1,
+			Totals{totals: []Total{{Option: moo}, {Option: nbc}}},
+			Resolution{},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			contest := Contest{Name: "Mario Kart track", NumberOfWinners: tc.winners, TieBreaker: tc.rule}
+			tc.want.Contest = contest
+			got := resolve(contest, tc.totals, rows, intn)
+			if diff := deep.Equal(got, tc.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestResolutionDescribe(t *testing.T) {
+	moo := Option{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}
+	nbc := Option{DisplayName: "Neo Bowser City", ShortCode: "NBC"}
+	contest := Contest{Name: "Mario Kart track"}
+	for _, tc := range []struct {
+		res  Resolution
+		want string
+	}{
+		{
+			Resolution{Contest: contest, Winners: []Option{moo}},
+			"Mario Kart track is over! Winner: Moo Moo Meadows",
+		},
+		{
+			Resolution{Contest: contest, Winners: []Option{nbc}, Tied: []Option{moo, nbc}, TiedValue: 3000, Rule: CoinFlipTieBreaker},
+			"Mario Kart track is over! Moo Moo Meadows, Neo Bowser City tied at 30.00. I flipped a coin... Winner: Neo Bowser City",
+		},
+		{
+			Resolution{Contest: contest, Tied: []Option{moo, nbc}, TiedValue: 3000},
+			"Mario Kart track is over! Moo Moo Meadows, Neo Bowser City tied at 30.00. The streamers will break the tie.",
+		},
+		{
+			Resolution{Contest: contest},
+			"Mario Kart track has no bids.",
+		},
+	} {
+		if got := tc.res.Describe(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
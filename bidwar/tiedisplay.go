@@ -0,0 +1,56 @@
+package bidwar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TieDisplay is how a WINNERS summary presents options that are tied for the
+// last winning spot, when there are more of them than spots left.
+type TieDisplay string
+
+const (
+	// List the tied options along with the winners, with no explanation.
+	ListTieDisplay TieDisplay = ""
+	// List the tied options separately: "Current top 2: C; tie for the last
+	// spot: A, B".
+	LabelTieDisplay TieDisplay = "LABEL"
+	// Only count the tied options: "Current top 2: C (2-way tie for the last
+	// spot)".
+	CountTieDisplay TieDisplay = "COUNT"
+)
+
+func (td TieDisplay) valid() bool {
+	switch td {
+	case ListTieDisplay, LabelTieDisplay, CountTieDisplay:
+		return true
+	}
+	return false
+}
+
+// describeTopN describes the options in the top n ranks. tied is the
+// rank that straddles the cutoff, if any; its options are not in winners.
+func (td TieDisplay) describeTopN(n int, winners []string, tied []string) string {
+	prefix := fmt.Sprintf("Current top %d: ", n)
+	if len(tied) == 0 || td == ListTieDisplay {
+		return prefix + strings.Join(append(winners, tied...), ", ")
+	}
+	spots := "the last spot"
+	if left := n - len(winners); len(winners) == 0 {
+		spots = "all spots"
+	} else if left > 1 {
+		spots = fmt.Sprintf("the last %d spots", left)
+	}
+	if td == CountTieDisplay {
+		tie := fmt.Sprintf("%d-way tie for %s", len(tied), spots)
+		if len(winners) == 0 {
+			return prefix + tie
+		}
+		return fmt.Sprintf("%s%s (%s)", prefix, strings.Join(winners, ", "), tie)
+	}
+	tie := fmt.Sprintf("tie for %s: %s", spots, strings.Join(tied, ", "))
+	if len(winners) == 0 {
+		return prefix + tie
+	}
+	return fmt.Sprintf("%s%s; %s", prefix, strings.Join(winners, ", "), tie)
+}
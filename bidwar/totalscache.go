@@ -0,0 +1,66 @@
+package bidwar
+
+import (
+	"sync"
+	"time"
+)
+
+// totalsCache remembers the last totals read from the spreadsheet for a short
+// time, so that a burst of donations doesn't use up the Sheets quota. The
+// cached totals are dropped as soon as the bot writes to the donation table,
+// which it detects by the table's write count. A nil *totalsCache caches
+// nothing.
+type totalsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	totals  []Total
+	valid   bool
+	fetched time.Time
+	// The donation table's write count when the totals were fetched.
+	writes uint64
+}
+
+func newTotalsCache(ttl time.Duration) *totalsCache {
+	return &totalsCache{ttl: ttl}
+}
+
+// lookup returns the cached totals, if they were fetched less than the TTL
+// ago and the table hasn't been written since.
+func (c *totalsCache) lookup(writes uint64, now time.Time) ([]Total, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.valid || writes != c.writes || now.Sub(c.fetched) >= c.ttl {
+		return nil, false
+	}
+	return append([]Total(nil), c.totals...), true
+}
+
+// store caches totals that were fetched when the table's write count was
+// writes. Callers should read the write count before fetching, so that a
+// write that races with the fetch invalidates the result.
+func (c *totalsCache) store(totals []Total, writes uint64, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.totals = append([]Total(nil), totals...)
+	c.valid = true
+	c.fetched = now
+	c.writes = writes
+}
+
+// invalidate drops the cached totals.
+func (c *totalsCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.totals = nil
+	c.valid = false
+}
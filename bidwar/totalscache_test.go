@@ -0,0 +1,36 @@
+package bidwar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTotalsCache(t *testing.T) {
+	now := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+	moo := Total{Option: Option{ShortCode: "Moo"}, Value: 500}
+	c := newTotalsCache(5 * time.Second)
+
+	if _, ok := c.lookup(0, now); ok {
+		t.Error("empty cache had totals")
+	}
+	c.store([]Total{moo}, 3, now)
+	if got, ok := c.lookup(3, now.Add(4*time.Second)); !ok || len(got) != 1 || got[0].Value != 500 {
+		t.Errorf("got %v, %v; want the stored totals", got, ok)
+	}
+	if _, ok := c.lookup(3, now.Add(5*time.Second)); ok {
+		t.Error("totals outlived the TTL")
+	}
+	if _, ok := c.lookup(4, now); ok {
+		t.Error("totals survived a write to the table")
+	}
+	c.invalidate()
+	if _, ok := c.lookup(3, now); ok {
+		t.Error("totals survived invalidation")
+	}
+
+	var nilCache *totalsCache
+	nilCache.store([]Total{moo}, 0, now)
+	if _, ok := nilCache.lookup(0, now); ok {
+		t.Error("nil cache had totals")
+	}
+}
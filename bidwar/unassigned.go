@@ -0,0 +1,169 @@
+package bidwar
+
+import (
+	"fmt"
+	"log"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// UnassignedPolicy decides how leftover unassigned donations are distributed
+// among a contest's options when the contest closes.
+type UnassignedPolicy string
+
+const (
+	// Leave unassigned donations alone.
+	NoPolicy UnassignedPolicy = ""
+	// Each donation goes to a random option, with equal odds.
+	UniformPolicy UnassignedPolicy = "UNIFORM"
+	// Each donation goes to a random option, weighted by the option's
+	// current total.
+	ProportionalPolicy UnassignedPolicy = "PROPORTIONAL"
+	// Every donation goes to the option in last place.
+	TrailingPolicy UnassignedPolicy = "TRAILING"
+)
+
+func (p UnassignedPolicy) valid() bool {
+	switch p {
+	case NoPolicy, UniformPolicy, ProportionalPolicy, TrailingPolicy:
+		return true
+	}
+	return false
+}
+
+// AssignUnassigned distributes the unassigned donations among the open
+// options of the given Contest, according to the Contest's UnassignedPolicy.
+// This is meant to be run when the contest closes.
+func (t Tallier) AssignUnassigned(contest Contest) (UpdateStats, error) {
+	if contest.UnassignedPolicy == NoPolicy {
+		return UpdateStats{}, nil
+	}
+	totals, err := t.TotalsForContest(contest)
+	if err != nil {
+		return UpdateStats{}, err
+	}
+	valueRange, err := t.table.GetTable()
+	if err != nil {
+		return UpdateStats{}, fmt.Errorf("error reading donation table: %v", err)
+	}
+
+	vrToWrite, matchedRows := assignUnassigned(valueRange, contest, totals, t.bidwars().Random.Intn)
+
+	if len(matchedRows) > 0 {
+		rowCount, err := t.table.WriteTable(vrToWrite)
+		if err != nil {
+			return UpdateStats{}, fmt.Errorf("error updating spreadsheet: %v", err)
+		}
+		log.Printf("auto-assigned %d rows to %s", rowCount, contest.Name)
+	}
+
+	totalCents := 0
+	for _, dr := range matchedRows {
+		totalCents += dr.Cents()
+	}
+	updateStats := UpdateStats{Count: len(matchedRows), TotalValue: donation.CentsValue(totalCents)}
+	if contest.DonorCapCents <= 0 {
+		return updateStats, nil
+	}
+	// The cap covers all of the contest's options, so each donor only needs
+	// to be checked once.
+	capped := make(map[string]bool)
+	for _, dr := range matchedRows {
+		donor := dr.Contributor()
+		if capped[donor] {
+			continue
+		}
+		capped[donor] = true
+		over, err := t.ApplyCap(donor, contest.Options[0])
+		if err != nil {
+			return updateStats, err
+		}
+		updateStats.OverCap += over
+	}
+	return updateStats, nil
+}
+
+// assignUnassigned decides which options the unassigned rows in the given
+// ValueRange should go to. Like makeChoice, it returns a ValueRange describing
+// how to update the spreadsheet, and the original values of the updated rows.
+func assignUnassigned(vr *sheets.ValueRange, contest Contest, totals Totals, intn func(int) int) (*sheets.ValueRange, []donationRow) {
+	pick := optionPicker(contest, totals, intn)
+	newValues := make([][]interface{}, len(vr.Values))
+	var updatedRows []donationRow
+	for i, row := range vr.Values {
+		dr := donationRow(row)
+		newValues[i] = []interface{}{}
+		if dr.Contributor() == "" || dr.Choice() != "" {
+			continue
+		}
+		if !contest.AssignAllUnassigned && !randomDirective.MatchString(dr.Reason()) {
+			continue
+		}
+		opt := pick()
+		if opt.IsZero() {
+			continue
+		}
+		reason := fmt.Sprintf("[auto-assigned: %s]", contest.UnassignedPolicy)
+		if dr.Reason() != "" {
+			reason += " " + dr.Reason()
+		}
+		newValues[i] = rowForChoice(Choice{Option: opt, Reason: reason})
+		updatedRows = append(updatedRows, dr)
+	}
+	return &sheets.ValueRange{
+		MajorDimension: vr.MajorDimension,
+		Range:          vr.Range,
+		Values:         newValues,
+	}, updatedRows
+}
+
+// optionPicker returns a func that picks an open option from the contest
+// according to its UnassignedPolicy. The contest itself may be closed. The
+// func returns the zero Option if the contest has no open options. intn is a
+// source of random numbers, like Random.Intn.
+func optionPicker(contest Contest, totals Totals, intn func(int) int) func() Option {
+	var opts []Option
+	for _, opt := range contest.Options {
+		if !opt.Closed {
+			opts = append(opts, opt)
+		}
+	}
+	totals = Totals{totals: withZeroTotals(contest, totals.totals)}
+	if len(opts) == 0 {
+		return func() Option { return Option{} }
+	}
+	uniform := func() Option { return opts[intn(len(opts))] }
+
+	switch contest.UnassignedPolicy {
+	case ProportionalPolicy:
+		weights := make(map[string]int)
+		sum := 0
+		for _, t := range totals.openTotals() {
+			weights[t.Option.ShortCode] = t.Value.Cents()
+			sum += t.Value.Cents()
+		}
+		if sum <= 0 {
+			return uniform
+		}
+		return func() Option {
+			n := intn(sum)
+			for _, opt := range opts {
+				n -= weights[opt.ShortCode]
+				if n < 0 {
+					return opt
+				}
+			}
+			return opts[len(opts)-1]
+		}
+	case TrailingPolicy:
+		ranks := totals.computeRanks()
+		if len(ranks) == 0 {
+			return uniform
+		}
+		last := ranks[len(ranks)-1].options
+		return func() Option { return last[intn(len(last))] }
+	}
+	return uniform
+}
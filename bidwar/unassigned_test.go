@@ -0,0 +1,78 @@
+package bidwar
+
+import (
+	"math/rand"
+	"testing"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestAssignUnassigned(t *testing.T) {
+	opts := []Option{
+		{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"},
+		{DisplayName: "Neo Bowser City", ShortCode: "NBC"},
+		{DisplayName: "Rainbow Road", ShortCode: "RR", Closed: true},
+	}
+	totals := Totals{totals: []Total{
+		{Option: opts[0], Value: donation.CentsValue(1000)},
+		{Option: opts[1], Value: donation.CentsValue(500)},
+		{Option: opts[2], Value: donation.CentsValue(0)},
+	}}
+	vr := &sheets.ValueRange{
+		Values: [][]interface{}{
+			{"Contributor", "What", "Points", "Choice", "Message"},
+			{"aerionblue", "resub", "5.00"},
+			{"AEWC20XX", "donation", "5.00", "", "[donation msg] dealer's choice"},
+			{"usedpizza", "donation", "2.00", "Moo", "[donation msg] moo"},
+			{"Mizalie", "444 bits", "4.44", "", "[chat] RANDOM please"},
+		},
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		policy   UnassignedPolicy
+		all      bool
+		wantRows []int          // Indexes of rows that should be assigned
+		wantOpts map[string]int // Acceptable ShortCodes for the assigned rows
+	}{
+		{"trailing, random requests only", TrailingPolicy, false, []int{2, 4}, map[string]int{"NBC": 1}},
+		{"trailing, all unassigned", TrailingPolicy, true, []int{1, 2, 4}, map[string]int{"NBC": 1}},
+		{"uniform skips closed options", UniformPolicy, true, []int{1, 2, 4}, map[string]int{"Moo": 1, "NBC": 1}},
+		{"proportional skips closed options", ProportionalPolicy, false, []int{2, 4}, map[string]int{"Moo": 1, "NBC": 1}},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			contest := Contest{Name: "Mario Kart", Options: opts, UnassignedPolicy: tc.policy, AssignAllUnassigned: tc.all}
+			for i := 0; i < 20; i++ {
+				gotVR, gotRows := assignUnassigned(vr, contest, totals, rand.Intn)
+				if len(gotRows) != len(tc.wantRows) {
+					t.Fatalf("got %d rows, want %d", len(gotRows), len(tc.wantRows))
+				}
+				for _, idx := range tc.wantRows {
+					row := donationRow(gotVR.Values[idx])
+					if _, ok := tc.wantOpts[row.Choice()]; !ok {
+						t.Errorf("row %d assigned to %q, want one of %v", idx, row.Choice(), tc.wantOpts)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAssignUnassigned_Reason(t *testing.T) {
+	contest := Contest{
+		Options:          []Option{{DisplayName: "Moo Moo Meadows", ShortCode: "Moo"}},
+		UnassignedPolicy: UniformPolicy,
+	}
+	vr := &sheets.ValueRange{
+		Values: [][]interface{}{
+			{"AEWC20XX", "donation", "5.00", "", "[donation msg] random"},
+		},
+	}
+	gotVR, _ := assignUnassigned(vr, contest, Totals{}, rand.Intn)
+	want := "[auto-assigned: UNIFORM] [donation msg] random"
+	if got := donationRow(gotVR.Values[0]).Reason(); got != want {
+		t.Errorf("got reason %q, want %q", got, want)
+	}
+}
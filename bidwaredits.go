@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/audit"
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+const closeContestCommand = "!closecontest"
+const openContestCommand = "!opencontest"
+const closeOptionCommand = "!closeoption"
+const openOptionCommand = "!openoption"
+const addOptionCommand = "!addoption"
+const removeOptionCommand = "!removeoption"
+const renameOptionCommand = "!renameoption"
+
+// dispatchSetClosedCommand opens or closes a contest or option, and saves the
+// change to the bid war data file.
+func (b *bot) dispatchSetClosedCommand(m twitch.PrivateMessage, cmd string) {
+	arg := commandArgs(m.Message)
+	if arg == "" {
+		what := "<contest name>"
+		if cmd == closeOptionCommand || cmd == openOptionCommand {
+			what = "<shortCode>"
+		}
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s %s", m.User.Name, cmd, what))
+		return
+	}
+	go func() {
+		closed := cmd == closeContestCommand || cmd == closeOptionCommand
+		var name string
+		var ok bool
+		var err error
+		// Anything the contest's unassigned policy did when it closed.
+		var assigned string
+		switch cmd {
+		case closeContestCommand, openContestCommand:
+			var con bidwar.Contest
+			if con, ok = b.collection().ContestByName(arg); ok {
+				name = con.Name
+				ok, err = b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+					return c.SetContestClosed(con.Name, closed, time.Now())
+				})
+				if ok && closed && !con.Closed {
+					assigned = b.assignAtClose(con, m.User.Name)
+				}
+			}
+		case closeOptionCommand, openOptionCommand:
+			var opt bidwar.Option
+			if opt, ok = b.collection().OptionByShortCode(arg); ok {
+				name = opt.DisplayName
+				ok, err = b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+					return c.SetOptionClosed(opt.ShortCode, closed)
+				})
+			}
+		}
+		if !ok {
+			b.say(m.Channel, fmt.Sprintf("@%s: I don't know anything called %q", m.User.Name, arg))
+			return
+		}
+		verb := "Reopened"
+		if closed {
+			verb = "Closed"
+		}
+		msg := fmt.Sprintf("@%s: %s bidding for %s.", m.User.Name, verb, name) + assigned
+		if err != nil {
+			log.Printf("ERROR saving bid war data: %v", err)
+			msg += " (I couldn't save the change, so it will be lost if I restart.)"
+		}
+		b.say(m.Channel, msg)
+	}()
+}
+
+// dispatchAddOptionCommand adds a write-in option to a contest. The usage is
+//
+//	!addoption <contest> <shortCode> <displayName> alias1,alias2,...
+//
+// The contest, short code, and display name can be in double quotes if they
+// contain spaces.
+func (b *bot) dispatchAddOptionCommand(m twitch.PrivateMessage) {
+	fields, rest := splitQuoted(commandArgs(m.Message), 3)
+	var aliases []string
+	for _, a := range strings.Split(rest, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			aliases = append(aliases, a)
+		}
+	}
+	if len(fields) < 3 {
+		b.say(m.Channel, fmt.Sprintf(`@%s: Usage: %s "<contest>" <shortCode> "<display name>" [alias1,alias2]`, m.User.Name, addOptionCommand))
+		return
+	}
+	opt, err := bidwar.NewOption(fields[2], fields[1], aliases)
+	if err != nil {
+		b.say(m.Channel, fmt.Sprintf("@%s: %v", m.User.Name, err))
+		return
+	}
+	go func() {
+		var addErr error
+		var added bidwar.Option
+		_, err := b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+			c, addErr = c.AddOption(fields[0], opt)
+			added, _ = c.OptionByShortCode(opt.ShortCode)
+			return c, addErr == nil
+		})
+		if addErr != nil {
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't add %s: %v", m.User.Name, opt.ShortCode, addErr))
+			return
+		}
+		msg := fmt.Sprintf("@%s: Added %s to %s.", m.User.Name, opt.DisplayName, fields[0])
+		if len(aliases) == 0 {
+			if names := added.AliasNames(); len(names) > 0 {
+				msg = fmt.Sprintf("@%s: Added %s to %s, with aliases %s.", m.User.Name, opt.DisplayName, fields[0], strings.Join(names, ", "))
+			} else {
+				msg = fmt.Sprintf("@%s: Added %s to %s, but it has no aliases, since its name is too much like the other options.", m.User.Name, opt.DisplayName, fields[0])
+			}
+		}
+		if err != nil {
+			log.Printf("ERROR saving bid war data: %v", err)
+			msg += " (I couldn't save the change, so it will be lost if I restart.)"
+		}
+		b.say(m.Channel, msg)
+	}()
+}
+
+// dispatchRemoveOptionCommand removes an option from its contest. Any bids
+// already assigned to it stay in the spreadsheet.
+func (b *bot) dispatchRemoveOptionCommand(m twitch.PrivateMessage) {
+	code := commandArgs(m.Message)
+	if code == "" {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <shortCode>", m.User.Name, removeOptionCommand))
+		return
+	}
+	go func() {
+		ok, err := b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+			return c.RemoveOption(code)
+		})
+		if !ok {
+			b.say(m.Channel, fmt.Sprintf("@%s: I don't know an option called %q", m.User.Name, code))
+			return
+		}
+		msg := fmt.Sprintf("@%s: Removed %s.", m.User.Name, code)
+		if err != nil {
+			log.Printf("ERROR saving bid war data: %v", err)
+			msg += " (I couldn't save the change, so it will be lost if I restart.)"
+		}
+		b.say(m.Channel, msg)
+	}()
+}
+
+// dispatchRenameOptionCommand changes an option's short code and, optionally,
+// its display name, and moves the bids already assigned to the old short code
+// over to the new one. The usage is
+//
+//	!renameoption <oldCode> <newCode> [display name]
+//
+// If moving the bids fails, the same command can be run again to retry.
+func (b *bot) dispatchRenameOptionCommand(m twitch.PrivateMessage) {
+	fields, rest := splitQuoted(commandArgs(m.Message), 2)
+	if len(fields) < 2 {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <oldCode> <newCode> [display name]", m.User.Name, renameOptionCommand))
+		return
+	}
+	oldCode, newCode, displayName := fields[0], fields[1], strings.Trim(rest, `"`)
+	go func() {
+		var renameErr error
+		var saveErr error
+		if _, ok := b.collection().OptionByShortCode(oldCode); ok {
+			_, saveErr = b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+				c, renameErr = c.RenameOption(oldCode, newCode, displayName)
+				return c, renameErr == nil
+			})
+		} else if _, ok := b.collection().OptionByShortCode(newCode); !ok {
+			renameErr = fmt.Errorf("there is no option called %q", oldCode)
+		}
+		if renameErr != nil {
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't rename %s: %v", m.User.Name, oldCode, renameErr))
+			return
+		}
+		if saveErr != nil {
+			log.Printf("ERROR saving bid war data: %v", saveErr)
+		}
+		if b.bidwarTallier == nil {
+			b.say(m.Channel, fmt.Sprintf("@%s: Renamed %s to %s.", m.User.Name, oldCode, newCode))
+			return
+		}
+		count, err := b.bidwarTallier.RenameChoice(oldCode, newCode)
+		if err != nil {
+			log.Printf("ERROR moving bids from %s to %s: %v", oldCode, newCode, err)
+			b.say(m.Channel, fmt.Sprintf("@%s: Renamed %s to %s, but I couldn't move its bids. Run the command again to retry.", m.User.Name, oldCode, newCode))
+			return
+		}
+		if count > 0 {
+			b.audit(audit.Entry{Action: audit.Rename, Option: newCode, From: oldCode, Count: count, By: m.User.Name})
+		}
+		msg := fmt.Sprintf("@%s: Renamed %s to %s and moved %d bids. If the totals sheet lists %s, change it to %s there too.", m.User.Name, oldCode, newCode, count, oldCode, newCode)
+		if saveErr != nil {
+			msg += " (I couldn't save the change, so it will be lost if I restart.)"
+		}
+		b.say(m.Channel, msg)
+	}()
+}
+
+// splitQuoted splits off the first n whitespace-separated fields of s, and
+// returns them along with the rest of s. A field may be wrapped in double
+// quotes to include spaces. Returns fewer than n fields if s runs out.
+func splitQuoted(s string, n int) ([]string, string) {
+	var fields []string
+	s = strings.TrimSpace(s)
+	for len(fields) < n && s != "" {
+		var field string
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return fields, s
+			}
+			field, s = s[1:end+1], s[end+2:]
+		} else if end := strings.IndexAny(s, " \t"); end >= 0 {
+			field, s = s[:end], s[end:]
+		} else {
+			field, s = s, ""
+		}
+		fields = append(fields, field)
+		s = strings.TrimSpace(s)
+	}
+	return fields, s
+}
+
+// editBidWars applies a change to the current bid wars, and saves the result
+// to the bid war sheet or data file (if there is one). The edit func returns false if
+// there was nothing to change. Returns whether the change was made; a save
+// error doesn't undo the change. In read-only mode, nothing is changed.
+func (b *bot) editBidWars(edit func(bidwar.Collection) (bidwar.Collection, bool)) (bool, error) {
+	if b.readOnly {
+		log.Print("WARNING: read-only; not editing the bid wars")
+		return false, nil
+	}
+	b.editMu.Lock()
+	defer b.editMu.Unlock()
+	c, ok := edit(b.collection())
+	if !ok {
+		return false, nil
+	}
+	b.setCollection(c)
+	if b.bidwarSheet != nil {
+		return true, saveBidWarSheet(b.bidwarSheet, c)
+	}
+	if b.bidwarDataPath == "" {
+		return true, nil
+	}
+	return true, saveBidWars(b.bidwarDataPath, c)
+}
+
+// saveBidWarSheet updates the bid war sheet to match the Collection.
+func saveBidWarSheet(s *googlesheets.BidWarSheet, c bidwar.Collection) error {
+	rows, err := s.Read()
+	if err != nil {
+		return err
+	}
+	edits, newRows := bidwar.SheetEdits(rows, c)
+	return s.Write(edits, newRows)
+}
+
+// saveBidWars writes the bid wars to a data file.
+func saveBidWars(path string, c bidwar.Collection) error {
+	data, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+	// Write to a temp file first, so we don't lose the bid wars if we crash.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write bid war data file: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+func TestSplitQuoted(t *testing.T) {
+	for _, tc := range []struct {
+		in         string
+		n          int
+		wantFields []string
+		wantRest   string
+	}{
+		{`"Mario Kart track" RR "Rainbow Road" rainbow road, rr`, 3, []string{"Mario Kart track", "RR", "Rainbow Road"}, "rainbow road, rr"},
+		{`Tracks RR Rainbow rr`, 3, []string{"Tracks", "RR", "Rainbow"}, "rr"},
+		{`Tracks RR`, 3, []string{"Tracks", "RR"}, ""},
+		{`"unclosed quote RR`, 3, nil, `"unclosed quote RR`},
+		{``, 3, nil, ""},
+	} {
+		gotFields, gotRest := splitQuoted(tc.in, tc.n)
+		if !reflect.DeepEqual(gotFields, tc.wantFields) || gotRest != tc.wantRest {
+			t.Errorf("splitQuoted(%q, %d): got (%q, %q), want (%q, %q)", tc.in, tc.n, gotFields, gotRest, tc.wantFields, tc.wantRest)
+		}
+	}
+}
+
+func TestEditBidWarsReadOnly(t *testing.T) {
+	b := &bot{readOnly: true}
+	called := false
+	ok, err := b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+		called = true
+		return c, true
+	})
+	if ok || err != nil {
+		t.Errorf("editBidWars: got (%v, %v), want (false, nil)", ok, err)
+	}
+	if called {
+		t.Errorf("editBidWars: edit func was called in read-only mode")
+	}
+}
+
+// assigningTallier records the contests whose unassigned donations were
+// distributed.
+type assigningTallier struct {
+	*fakeBackend
+	assigned []string
+}
+
+func (a *assigningTallier) AssignUnassigned(contest bidwar.Contest) (bidwar.UpdateStats, error) {
+	a.assigned = append(a.assigned, contest.Name)
+	return bidwar.UpdateStats{Count: 2, TotalValue: 500}, nil
+}
+
+func TestCloseAssignsUnassigned(t *testing.T) {
+	defer quietLogs()()
+	bidwars, err := bidwar.Parse([]byte(`{
+    "contests": [
+        {
+            "name": "Mario Kart track",
+            "unassignedPolicy": "UNIFORM",
+            "options": [
+                {"displayName": "Moo Moo Meadows", "shortCode": "Moo"},
+                {"displayName": "Neo Bowser City", "shortCode": "NBC"}
+            ]
+        },
+        {
+            "name": "Final boss",
+            "options": [
+                {"displayName": "Bowser", "shortCode": "Bowser"},
+                {"displayName": "Ganon", "shortCode": "Ganon"}
+            ]
+        }
+    ]
+}`))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	tallier := &assigningTallier{fakeBackend: newFakeBackend(0)}
+	b := newLoadTestBot(t, tallier.fakeBackend)
+	b.bidwars = bidwars
+	b.bidwarTallier = tallier
+
+	for _, con := range bidwars.Contests {
+		con.ClosesAt = time.Now()
+		b.autoClose("testing", con)
+		if got, _ := b.collection().ContestByName(con.Name); !got.Closed {
+			t.Errorf("%s should be closed", con.Name)
+		}
+	}
+	if want := []string{"Mario Kart track"}; !reflect.DeepEqual(tallier.assigned, want) {
+		t.Errorf("got unassigned donations assigned for %v, want %v", tallier.assigned, want)
+	}
+
+	con, _ := bidwars.ContestByName("Mario Kart track")
+	if got, want := b.assignAtClose(con, "aerionblue"), " Assigned 2 unassigned donations (5.00 points)."; got != want {
+		t.Errorf("assignAtClose: got %q, want %q", got, want)
+	}
+}
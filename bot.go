@@ -2,31 +2,156 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	twitch "github.com/gempir/go-twitch-irc/v2"
 
 	"golang.org/x/time/rate"
+	"google.golang.org/api/sheets/v4"
 
+	"github.com/aerionblue/pizzafest/audit"
+	"github.com/aerionblue/pizzafest/backup"
 	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/changefeed"
+	"github.com/aerionblue/pizzafest/control"
 	"github.com/aerionblue/pizzafest/db"
 	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/eventqueue"
 	"github.com/aerionblue/pizzafest/googlesheets"
-	"github.com/aerionblue/pizzafest/streamelements"
-	"github.com/aerionblue/pizzafest/streamlabs"
-	"github.com/aerionblue/pizzafest/tipfile"
+	"github.com/aerionblue/pizzafest/helix"
+	"github.com/aerionblue/pizzafest/hooks"
+	"github.com/aerionblue/pizzafest/httpapi"
+	"github.com/aerionblue/pizzafest/identity"
+	"github.com/aerionblue/pizzafest/notes"
+	"github.com/aerionblue/pizzafest/predictions"
+	"github.com/aerionblue/pizzafest/report"
 	"github.com/aerionblue/pizzafest/twitchchat"
 )
 
 const testIRCAddress = "irc.fdgt.dev:6667"
 
 const bidCommand = "!bid"
+const autoAssignCommand = "!autoassign"
+const pollCommand = "!poll"
+const refreshCommand = "!refresh"
+const quietCommand = "!quiet"
+const acceptCommand = "!accept"
+const rejectCommand = "!reject"
+const contributorsCommand = "!contributors"
+const reloadBidsCommand = "!reloadbids"
+const sourcesCommand = "!sources"
+const reportCommand = "!report"
+const resolveCommand = "!resolve"
+const topDonorsCommand = "!topdonors"
+const standingsCommand = "!standings"
+const myBidsCommand = "!mybids"
+const hypeCommand = "!hype"
+const helpCommand = "!help"
+const commandsCommand = "!commands"
+
+// command is a chat command that the bot responds to. Every command is listed
+// in chatCommands, which is also what !help describes.
+type command struct {
+	name string
+	// Other names that run the same command.
+	aliases []string
+	// What goes after the command name, e.g. "<contest>". Empty if the
+	// command takes no arguments.
+	args string
+	// A one-line description, for !help.
+	help string
+	// Who can use the command. Privileged commands can be restricted further
+	// in the config; see permissions.
+	role role
+	// Whether the command changes the bids or the spreadsheet, and so is
+	// disabled in read-only mode.
+	writes bool
+	run    func(b *bot, m twitch.PrivateMessage)
+}
+
+// matches reports whether the (lowercase) message is a use of the command.
+func (c command) matches(message string) bool {
+	if firstTokenIs(message, c.name) {
+		return true
+	}
+	for _, a := range c.aliases {
+		if firstTokenIs(message, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// usage returns the command name followed by its arguments.
+func (c command) usage() string {
+	if c.args == "" {
+		return c.name
+	}
+	return c.name + " " + c.args
+}
+
+// chatCommands returns every chat command, in the order they are matched.
+func chatCommands() []command {
+	lateBids := func(accept bool) func(b *bot, m twitch.PrivateMessage) {
+		return func(b *bot, m twitch.PrivateMessage) { b.dispatchLateBidsCommand(m, accept) }
+	}
+	setClosed := func(cmd string) func(b *bot, m twitch.PrivateMessage) {
+		return func(b *bot, m twitch.PrivateMessage) { b.dispatchSetClosedCommand(m, cmd) }
+	}
+	lock := func(cmd string) func(b *bot, m twitch.PrivateMessage) {
+		return func(b *bot, m twitch.PrivateMessage) { b.dispatchLockCommand(m, cmd) }
+	}
+	return []command{
+		{name: bidCommand, args: "<option>", help: "Puts your unassigned donations towards an option.", writes: true, run: (*bot).dispatchBidCommand},
+		{name: autoAssignCommand, args: "<contest>", help: "Distributes the unassigned donations according to the contest's policy.", role: moderatorRole, writes: true, run: (*bot).dispatchAutoAssignCommand},
+		{name: pollCommand, help: "Polls the donation providers right away.", role: moderatorRole, writes: true, run: (*bot).dispatchPollCommand},
+		{name: refreshCommand, help: "Re-reads the totals from the spreadsheet.", role: moderatorRole, run: (*bot).dispatchRefreshCommand},
+		{name: quietCommand, args: "on|off", help: "Stops (or resumes) announcing donations.", role: moderatorRole, run: (*bot).dispatchQuietCommand},
+		{name: acceptCommand, args: "late", help: "Assigns the held late bids.", role: moderatorRole, writes: true, run: lateBids(true)},
+		{name: rejectCommand, args: "late", help: "Leaves the held late bids unassigned.", role: moderatorRole, writes: true, run: lateBids(false)},
+		{name: contributorsCommand, args: "<option>", help: "Lists everyone who contributed to an option.", role: moderatorRole, run: (*bot).dispatchContributorsCommand},
+		{name: hypeCommand, args: "<contest>", help: "Sums up a contest for the hosts to read aloud.", role: moderatorRole, run: (*bot).dispatchHypeCommand},
+		{name: myBidsCommand, help: "Shows where your points are.", run: (*bot).dispatchMyBidsCommand},
+		{name: contestsCommand, help: "Lists the open contests and their options.", run: (*bot).dispatchContestsCommand},
+		{name: standingsCommand, args: "<contest>", help: "Shows a contest's totals.", run: (*bot).dispatchStandingsCommand},
+		{name: topDonorsCommand, args: "<option>", help: "Shows the biggest contributors to an option.", run: (*bot).dispatchTopDonorsCommand},
+		{name: reloadBidsCommand, help: "Re-reads the bid war data file.", role: moderatorRole, run: (*bot).dispatchReloadBidsCommand},
+		{name: closeContestCommand, args: "<contest>", help: "Closes a contest to new bids.", role: moderatorRole, writes: true, run: setClosed(closeContestCommand)},
+		{name: openContestCommand, args: "<contest>", help: "Reopens a closed contest.", role: moderatorRole, writes: true, run: setClosed(openContestCommand)},
+		{name: closeOptionCommand, args: "<shortCode>", help: "Closes an option to new bids.", role: moderatorRole, writes: true, run: setClosed(closeOptionCommand)},
+		{name: openOptionCommand, args: "<shortCode>", help: "Reopens a closed option.", role: moderatorRole, writes: true, run: setClosed(openOptionCommand)},
+		{name: addOptionCommand, args: `"<contest>" <shortCode> "<display name>" [alias1,alias2]`, help: "Adds an option to a contest.", role: moderatorRole, writes: true, run: (*bot).dispatchAddOptionCommand},
+		{name: removeOptionCommand, args: "<shortCode>", help: "Removes an option.", role: moderatorRole, writes: true, run: (*bot).dispatchRemoveOptionCommand},
+		{name: renameOptionCommand, args: "<oldCode> <newCode> [display name]", help: "Renames an option.", role: moderatorRole, writes: true, run: (*bot).dispatchRenameOptionCommand},
+		{name: sourcesCommand, args: "[campaign]", help: "Shows how much came from each donation source.", role: moderatorRole, run: (*bot).dispatchSourcesCommand},
+		{name: reportCommand, args: "[campaign]", help: "Posts the end-of-event report to the notes.", role: moderatorRole, run: (*bot).dispatchReportCommand},
+		{name: campaignCommand, args: "start <name>|end", help: "Starts or ends a campaign.", role: moderatorRole, writes: true, run: (*bot).dispatchCampaignCommand},
+		{name: resolveCommand, args: "<contest>", help: "Announces a contest's winners, breaking any tie.", role: moderatorRole, writes: true, run: (*bot).dispatchResolveCommand},
+		{name: lockCommand, args: "<contest>", help: "Locks in a contest's winner.", role: moderatorRole, writes: true, run: lock(lockCommand)},
+		{name: unlockCommand, args: "<contest>", help: "Unlocks a locked contest.", role: moderatorRole, writes: true, run: lock(unlockCommand)},
+		{name: timerCommand, args: "start <duration> <name>|stop <name>", help: "Starts or stops a countdown for an incentive.", role: moderatorRole, run: (*bot).dispatchTimerCommand},
+		{name: timeLeftCommand, help: "Shows how long the running timers have left.", run: (*bot).dispatchTimeLeftCommand},
+		{name: linkCommand, args: "<tip name> <Twitch user>", help: "Lets a Twitch user !bid with tips made under another name.", role: moderatorRole, writes: true, run: (*bot).dispatchLinkCommand},
+		{name: recapCommand, args: "<minutes>", help: "Thanks everyone who donated in the last few minutes, e.g. after the stream went down.", role: moderatorRole, run: (*bot).dispatchRecapCommand},
+		{name: botStatusCommand, help: "Shows how long I've been up, what I'm connected to, and when I last saw donations.", role: moderatorRole, run: (*bot).dispatchBotStatusCommand},
+		{name: droppedCommand, help: "Counts the events that I ignored, and why.", role: moderatorRole, run: (*bot).dispatchDroppedCommand},
+		{name: helpCommand, aliases: []string{commandsCommand}, args: "[command]", help: "Lists the commands, or describes one.", run: (*bot).dispatchHelpCommand},
+	}
+}
 
 // Rate limit parameters for outgoing chat messages.
 const chatCooldown = 1 * time.Second
@@ -38,19 +163,146 @@ const bidPrefTTL = 3 * time.Minute
 // How long we ignore individual gift sub events after a community gift.
 const massGiftCooldown = 10 * time.Second
 
+// Twitch rejects chat messages longer than 500 characters. Leave some room for
+// the @mention.
+const maxChatLength = 450
+
+// How many donors !topdonors announces.
+const topDonorsCount = 3
+
 // The minimum value that we will acknowledge. Donations below this value are
 // still logged, and still count towards the grand total. We just won't
 // allocate them to bid wars or reply to them.
 const minimumDonation = donation.CentsValue(100)
 
 type bot struct {
+	// How many chat messages we've dropped because of the rate limit. Accessed
+	// atomically, so it's first in the struct to keep it 64-bit aligned.
+	droppedChat int64
+
 	ircClient         *twitch.Client
 	ircRepliesEnabled bool
 	dbRecorder        db.Recorder
-	bidwars           bidwar.Collection
-	bidwarTallier     *bidwar.Tallier
+	bidwars           bidwar.Collection // Guarded by mu; use collection() to read it.
+	bidwarTallier     bidTallier
 	minimumDonation   donation.CentsValue
-	chatLimiter       *rate.Limiter
+	// The prefixes of the channel's custom cheermotes, which are stripped
+	// from bits messages (along with Twitch's global ones) before looking
+	// for a bid.
+	cheermotes []string
+	// Limits everything the bot says apart from donation acks. Shared by
+	// all the channels' bots.
+	chatLimiter *rate.Limiter
+	// Limits donation acks, so that a burst of command replies can't crowd
+	// them out. Shared by all the channels' bots. If nil, acks use the
+	// chatLimiter.
+	ackLimiter *rate.Limiter
+	// Queues that delay some kinds of chat messages to match the stream
+	// delay. Kinds with no queue are sent right away.
+	chatQueues map[chatKind]*chatQueue
+	// Who can use which chat commands.
+	perms permissions
+	// How often viewers can use each chat command. May be nil.
+	cooldowns *commandCooldowns
+	// Threads replies under the messages they answer, instead of only
+	// @mentioning the user. May be nil.
+	replier threadedReplier
+	// Runs Twitch Predictions on contests. May be nil.
+	predictions *predictionTracker
+	// Looks up donors' Twitch user IDs. May be nil.
+	users *helix.Client
+	// The donations that !recap can re-announce, if the donation table
+	// doesn't record times.
+	recent *recentDonations
+	// 1 while a !recap is being posted. Accessed atomically.
+	recapping int32
+	// If set, each contest's donations are copied to a sheet named this plus
+	// the contest's name when the contest is resolved.
+	resultsSheetPrefix string
+	// Notices IRC outages and remembers what to catch up on. May be nil.
+	outage *outageTracker
+	// Repeated !bid commands get the same reply. May be nil.
+	bidCache *bidCache
+	// Throttles each user's !bid commands. May be nil.
+	bidLimiter *bidLimiter
+	// Donors known to have no unassigned donations. May be nil.
+	settled *settledDonors
+	// How long after a !bid the donor can move it with another !bid. If 0,
+	// donors can't move their bids.
+	bidChangeWindow time.Duration
+	// Replies held back by the rate limiter, to send when chat frees up. May
+	// be nil.
+	deferred *deferredReplies
+	// Keeps whispers within Twitch's limits. Shared by all the channels' bots.
+	whispers *whisperLimiter
+	// Sends whispers through the Twitch API. If nil, the bot doesn't whisper.
+	whisperer whisperer
+	// Whether to whisper personal acknowledgements instead of saying them in
+	// chat.
+	whisperAcks bool
+	// Where to post producer-facing notes. May be nil.
+	notes notes.Poster
+	// The donation providers that we poll, keyed by display name.
+	pollers map[string]donationPoller
+	// Donations of at least this value get a producer note.
+	bigDonation donation.CentsValue
+	emotes      EmotesConfig
+	// Whether to reply to donations that couldn't be recorded.
+	ackFailedDonations bool
+	// Where to keep donations that couldn't be recorded. May be nil.
+	journal *db.Journal
+	// The file that the bid wars were read from, if any.
+	bidwarDataPath string
+	// The sheet that the bid wars were read from, if any. Takes precedence
+	// over bidwarDataPath.
+	bidwarSheet *googlesheets.BidWarSheet
+	// The donation table, if donations are recorded in Google Sheets.
+	donationTable *googlesheets.DonationTable
+	// Watches for options that are surging. May be nil.
+	momentum *bidwar.MomentumTracker
+	// Custom code to run after each donation is recorded.
+	hooks []hooks.Hook
+	// Links tip names to Twitch usernames. May be nil.
+	identities *identity.Map
+	// Times each donation, for the end-of-event report. May be nil.
+	latency *report.LatencyTracker
+	// The history of bid assignments. May be nil.
+	auditLog *audit.Log
+	// Whether the donation table has a campaign column.
+	recordCampaigns bool
+	// Recognizes donors who keep donating. May be nil.
+	streaks *streakTracker
+	// The running incentive timers.
+	timers timerTracker
+	// Counts the events that we ignored. May be nil.
+	dropped *droppedEvents
+	// Announces raids and viewer milestones. May be nil.
+	notices *noticeTracker
+	// Follows the Hype Train. May be nil.
+	hypeTrain *hypeTrainTracker
+	// What !botstatus reports. May be nil.
+	status *botStatus
+	// The channel point rewards that count towards the bid wars.
+	channelPoints ChannelPointsConfig
+	// How EventSub is used, if at all.
+	eventSubMode string
+	// In replace mode, whether subs that users buy for themselves come from
+	// EventSub too. See EventSubConfig.SelfSubs.
+	eventSubSelfSubs bool
+	// Compares chat with EventSub. Only set in cross-check mode.
+	crossCheck *crossCheck
+	// Whether to announce in chat when the bot goes live and shuts down.
+	announceStatus bool
+	// Credited for each level of a Hype Train when it ends.
+	hypeTrainBonus donation.CentsValue
+	// Whether the Hype Train bonus is money rather than bid war credit.
+	hypeTrainBonusIsMoney bool
+	// Whether the event is over and nothing should be written, so that the
+	// final numbers can be browsed but not changed.
+	readOnly bool
+
+	// Serializes reloads and live edits of the bid wars.
+	editMu sync.Mutex
 
 	mu sync.RWMutex
 	// Maps a Twitch username to the last time they gave a community gift sub.
@@ -59,47 +311,163 @@ type bot struct {
 	// has no donations to assign, we keep track of it for a few minutes just in
 	// case the donation data was slow in getting to us.
 	pendingBids map[string]*bidPreference
+	// Maps a contest name to the names of the options that were in first
+	// place the last time we reported its totals.
+	leaders map[string]string
+	// Whether a mod has paused all chat output except command replies.
+	quiet bool
+	// Bids that arrived shortly after their contest closed, waiting for a mod
+	// to accept or reject them.
+	lateBids []lateBid
+	// Maps a Twitch username to their last !bid that assigned something, so
+	// that they can move it with another !bid within bidChangeWindow.
+	recentBids map[string]*recentBid
+	// The campaign that new donations are tagged with, if any.
+	campaign string
+	// The file that the campaign is kept in, if any.
+	campaignPath string
 }
 
+// A donationTask records a donation and then announces it. It returns an
+// error only if the donation couldn't be recorded or saved in the journal,
+// i.e. if it would otherwise be lost. The dispatch funcs run their tasks in
+// the background; the event queue runs them one at a time, so that it only
+// moves past an event once it's safe.
+type donationTask func() error
+
 func (b *bot) dispatchSubEvent(ev donation.Event) {
+	if task := b.subTask(ev); task != nil {
+		go task()
+	}
+}
+
+func (b *bot) subTask(ev donation.Event) donationTask {
 	if ev.Type == donation.CommunityGift {
 		b.updateCommunityGift(ev)
 	}
 	if ev.Type == donation.GiftSubscription && b.shouldIgnoreSubGift(ev) {
-		return
+		b.dropped.add(dropDuplicateGift, ev)
+		return nil
 	}
+	received := time.Now()
+	b.status.sawDonation(ev.Source, received)
 	log.Printf("new subscription by %v worth $%s (tier: %d, months: %d, count: %d)", ev.Owner, ev.Value(), ev.SubTier, ev.SubMonths, ev.SubCount)
-	bid := b.getChoice(ev, bidwar.FromSubMessage)
-	go func() {
-		if err := b.dbRecorder.RecordDonation(ev, bid); err != nil {
-			log.Printf("ERROR writing donation to db: %v", err)
-			return
+	bid := b.getChoice(ev, bidwar.FromSubMessage, false)
+	return func() error {
+		if ok, err := b.tryRecordDonation(ev, bid); !ok {
+			return err
 		}
-		b.sayWithTotals(
+		recorded := time.Now()
+		b.noteDonation(ev, bid)
+		b.handleClosedChoice(ev.Channel, ev.Owner, fmt.Sprintf("@%s:", ev.Owner), bid)
+		b.queueCatchUp(ev, bid.Option)
+		replied := b.sayWithTotals(
+			chatDonation,
 			ev.Channel,
 			bid.Option,
-			fmt.Sprintf("@%s: I put your sub towards %s.", ev.Owner, bid.Option.DisplayName))
-	}()
+			fmt.Sprintf("@%s: I put your sub towards %s.%s%s", ev.Owner, bid.Option.DisplayName, b.overflowNote(bid), b.capNote(ev, bid.Option))) != ""
+		b.recognizeStreak(ev)
+		b.trackHypeTrain(ev)
+		b.observeLatency(ev, received, recorded, replied)
+		return nil
+	}
 }
 
 func (b *bot) dispatchBitsEvent(ev donation.Event) {
+	go b.bitsTask(ev)()
+}
+
+func (b *bot) bitsTask(ev donation.Event) donationTask {
+	received := time.Now()
+	b.status.sawDonation(ev.Source, received)
 	log.Printf("new bits donation by %v worth $%s (bits: %d)", ev.Owner, ev.Value(), ev.Bits)
-	bid := b.getChoice(ev, bidwar.FromChatMessage)
-	go func() {
-		if err := b.dbRecorder.RecordDonation(ev, bid); err != nil {
-			log.Printf("ERROR writing donation to db: %v", err)
-			return
+	// Look for the bid without the cheermotes, which could be mistaken for
+	// options. The message is recorded as it was sent.
+	forMatching := ev
+	forMatching.Message = donation.StripCheermotes(ev.Message, b.cheermotes)
+	bid := b.getChoice(forMatching, bidwar.FromChatMessage, false)
+	return func() error {
+		if ok, err := b.tryRecordDonation(ev, bid); !ok {
+			return err
 		}
-		b.sayWithTotals(
-			ev.Channel,
-			bid.Option,
-			fmt.Sprintf("@%s: I put your bits towards %s.", ev.Owner, bid.Option.DisplayName))
-	}()
+		recorded := time.Now()
+		b.noteDonation(ev, bid)
+		b.handleClosedChoice(ev.Channel, ev.Owner, fmt.Sprintf("@%s:", ev.Owner), bid)
+		b.queueCatchUp(ev, bid.Option)
+		var msg string
+		if earlier := b.assignEarlierDonations(ev, bid); earlier.Count > 0 {
+			msg = fmt.Sprintf("@%s: I put your bits and %s towards %s (+%s in all).%s%s",
+				ev.Owner, describeEarlierDonations(earlier.Count), bid.Option.DisplayName, ev.Value()+earlier.TotalValue,
+				b.overflowNote(bid), b.describeOverCap(bid.Option, ev.Value()+earlier.TotalValue, earlier.OverCap))
+		} else {
+			msg = fmt.Sprintf("@%s: I put your bits towards %s.%s%s", ev.Owner, bid.Option.DisplayName, b.overflowNote(bid), b.capNote(ev, bid.Option))
+		}
+		replied := b.replyWithTotals(chatDonation, ev.Channel, ev.MessageID, bid.Option, msg) != ""
+		b.recognizeStreak(ev)
+		b.trackHypeTrain(ev)
+		b.observeLatency(ev, received, recorded, replied)
+		return nil
+	}
+}
+
+// assignEarlierDonations puts a cheerer's unassigned donations towards the
+// option their bits went to, so that they don't need a separate !bid. The
+// tallier enforces the donor cap on the bits and the earlier donations
+// together. Donors known to have no unassigned donations are skipped, since
+// looking costs a read of the whole donation table.
+func (b *bot) assignEarlierDonations(ev donation.Event, bid bidwar.Choice) bidwar.UpdateStats {
+	user := b.identities.TwitchUser(ev.Owner)
+	if bid.Option.IsZero() || b.bidwarTallier == nil || b.settled.isSettled(user) {
+		return bidwar.UpdateStats{}
+	}
+	gen := b.settled.generation()
+	stats, err := b.bidwarTallier.AssignToChoice(ev.Owner, bid)
+	if err != nil {
+		log.Printf("ERROR assigning earlier donations from %s: %v", ev.Owner, err)
+		return bidwar.UpdateStats{}
+	}
+	b.settled.settle(user, gen)
+	b.auditStats(audit.Bid, ev.Owner, stats, ev.Owner)
+	return stats
+}
+
+func describeEarlierDonations(n int) string {
+	if n == 1 {
+		return "your earlier donation"
+	}
+	return fmt.Sprintf("your %d earlier donations", n)
 }
 
 func (b *bot) dispatchBidCommand(m twitch.PrivateMessage) {
+	donor := m.User.Name
+	// Repeats are throttled too, since each one still sends replies.
+	if ok, wait, tell := b.bidLimiter.allow(donor, time.Now()); !ok {
+		log.Printf("%s sent !bid too often; ignoring %q", donor, m.Message)
+		if tell {
+			b.replyPersonal(chatBid, m.Channel, m.ID, fmt.Sprintf("@%s: Please wait %d more seconds before your next %s.", donor, int(math.Ceil(wait.Seconds())), bidCommand))
+		}
+		return
+	}
+	if replies, ok := b.bidCache.lookup(donor, m.Message, time.Now()); ok {
+		log.Printf("%s repeated %q; sending the same reply", donor, m.Message)
+		for _, msg := range replies {
+			b.replyPersonal(chatBid, m.Channel, m.ID, msg)
+		}
+		return
+	}
 	go func() {
-		donor := m.User.Name
+		r := &bidReplier{b: b, channel: m.Channel, parentID: m.ID}
+		defer func() { b.bidCache.store(donor, m.Message, r.sent, time.Now()) }()
+		split, err := b.collection().SplitFromMessage(m.Message)
+		if err != nil {
+			r.say(fmt.Sprintf("@%s: I couldn't split your bid: %v", donor, err))
+			return
+		}
+		if split != nil {
+			b.assignSplit(m, *split, r)
+			return
+		}
+		gen := b.settled.generation()
 		updateStats, err := b.bidwarTallier.AssignFromMessage(donor, m.Message)
 		if err != nil {
 			log.Printf("ERROR assigning bid command for %s", donor)
@@ -107,63 +475,858 @@ func (b *bot) dispatchBidCommand(m twitch.PrivateMessage) {
 		}
 		opt := updateStats.Choice.Option
 		if opt.IsZero() {
-			opts := b.bidwars.AllOpenOptions()
-			if len(opts) > 0 {
-				shortCodes := make([]string, len(opts))
-				for i, o := range opts {
-					shortCodes[i] = o.ShortCode
-				}
-				b.say(m.Channel, fmt.Sprintf("@%s: These are the options: %s", donor, strings.Join(shortCodes, ", ")))
+			if closed := updateStats.Choice.ClosedOption; !closed.IsZero() && b.holdLateBid(donor, closed) {
+				r.say(fmt.Sprintf("@%s: %s", donor, lateBidMessage(closed)))
+			} else if !closed.IsZero() {
+				r.say(fmt.Sprintf("@%s: %s", donor, b.closedOptionMessage(closed)))
+			} else if codes := b.openOptionCodes(); len(codes) > 0 {
+				r.say(fmt.Sprintf("@%s: These are the options: %s (Say %s to see them by contest.)", donor, strings.Join(codes, ", "), contestsCommand))
 			}
 			return
 		}
+		b.auditStats(audit.Bid, donor, updateStats, donor)
+		b.settled.settle(b.identities.TwitchUser(donor), gen)
+		if updateStats.Count == 0 {
+			if moved, from, ok := b.moveRecentBid(donor, updateStats.Choice); ok {
+				r.sayWithTotals(opt, fmt.Sprintf("@%s: Moved %s from %s to %s.", donor, moved.TotalValue, from.DisplayName, opt.DisplayName))
+				return
+			}
+		}
 		var msg string
 		if updateStats.TotalValue.Points() > 0 {
-			msg = fmt.Sprintf("@%s: +%s for %s usedNice", donor, updateStats.TotalValue, opt.DisplayName)
+			b.rememberRecentBid(donor, updateStats)
+			msg = bidwar.WithEmote(fmt.Sprintf("@%s: +%s for %s", donor, updateStats.TotalValue, opt.DisplayName), b.emotes.BidAssigned) + b.overflowNote(updateStats.Choice) + b.overCapNote(updateStats)
 		} else {
 			b.rememberPref(donor, updateStats.Choice)
-			msg = fmt.Sprintf("@%s: You had no points used7 but I'll remember your choice for a few minutes.", donor)
+			msg = fmt.Sprintf("@%s: %s but I'll remember your choice for a few minutes.", donor, bidwar.WithEmote("You had no points", b.emotes.NoPoints))
 		}
-		b.sayWithTotals(m.Channel, opt, msg)
+		r.sayWithTotals(opt, msg)
 	}()
 }
 
-func (b *bot) dispatchMoneyDonation(ev donation.Event) {
-	log.Printf("new dolla donation by %v worth $%s (cash: %s)", ev.Owner, ev.Value(), ev.Cash)
-	bid := b.getChoice(ev, bidwar.FromDonationMessage)
+// assignSplit divides a donor's points among several options, or puts part of
+// them towards one option.
+func (b *bot) assignSplit(m twitch.PrivateMessage, split bidwar.Split, r *bidReplier) {
+	donor := m.User.Name
+	stats, err := b.bidwarTallier.AssignSplit(donor, split)
+	if err == bidwar.ErrSplitTooBig && len(split.Parts) == 1 {
+		r.say(fmt.Sprintf("@%s: You don't have that many unassigned points.", donor))
+		return
+	} else if err == bidwar.ErrSplitTooBig {
+		r.say(fmt.Sprintf("@%s: That adds up to more points than you have.", donor))
+		return
+	} else if err != nil {
+		log.Printf("ERROR assigning split bid for %s: %v", donor, err)
+		return
+	}
+	var parts []string
+	var notes string
+	var total donation.CentsValue
+	for _, s := range stats {
+		b.auditStats(audit.Split, donor, s, donor)
+		part := fmt.Sprintf("+%s for %s", s.TotalValue, s.Choice.Option.DisplayName)
+		if s.OverCap > 0 {
+			part += fmt.Sprintf(" (%s over the cap)", s.OverCap)
+		}
+		parts = append(parts, part)
+		if s.TotalValue > 0 {
+			notes += b.overflowNote(s.Choice)
+		}
+		total += s.TotalValue
+	}
+	if total == 0 {
+		r.say(fmt.Sprintf("@%s: %s", donor, bidwar.WithEmote("You had no points", b.emotes.NoPoints)))
+		return
+	}
+	msg := bidwar.WithEmote(fmt.Sprintf("@%s: %s%s", donor, strings.Join(parts, ", "), notes), b.emotes.BidAssigned)
+	// Report the totals once for each contest.
+	reported := make(map[string]bool)
+	for _, s := range stats {
+		contest := b.collection().FindContest(s.Choice.Option)
+		if reported[contest.Name] {
+			continue
+		}
+		reported[contest.Name] = true
+		r.sayWithTotals(s.Choice.Option, msg)
+		msg = ""
+	}
+}
+
+// dispatchAutoAssignCommand distributes the unassigned donations among the
+// options of a contest, according to the contest's unassigned policy.
+func (b *bot) dispatchAutoAssignCommand(m twitch.PrivateMessage) {
+	go func() {
+		name := commandArgs(m.Message)
+		contest, ok := b.collection().ContestByName(name)
+		if !ok {
+			b.say(m.Channel, fmt.Sprintf("@%s: I don't know a contest called %q", m.User.Name, name))
+			return
+		}
+		if contest.UnassignedPolicy == bidwar.NoPolicy {
+			b.say(m.Channel, fmt.Sprintf("@%s: %s doesn't have an unassigned donation policy", m.User.Name, contest.Name))
+			return
+		}
+		if b.bidwarTallier == nil {
+			return
+		}
+		stats, err := b.assignUnassigned(contest, m.User.Name)
+		if err != nil {
+			log.Printf("ERROR auto-assigning donations for %s: %v", contest.Name, err)
+			return
+		}
+		b.say(m.Channel, fmt.Sprintf("Assigned %d unassigned donations (%s points) to %s.", stats.Count, stats.TotalValue, contest.Name))
+	}()
+}
+
+// assignUnassigned distributes the unassigned donations among the options of
+// a contest, according to the contest's unassigned policy, and audits it.
+func (b *bot) assignUnassigned(contest bidwar.Contest, by string) (bidwar.UpdateStats, error) {
+	stats, err := b.bidwarTallier.AssignUnassigned(contest)
+	if err != nil {
+		return stats, err
+	}
+	if stats.Count > 0 {
+		b.audit(audit.Entry{Action: audit.AutoAssign, Contest: contest.Name, Count: stats.Count, Cents: stats.TotalValue.Cents(), By: by})
+	}
+	return stats, nil
+}
+
+// assignAtClose runs a contest's unassigned policy, now that the contest has
+// been closed or locked. Returns a note about it to add to the reply, or "" if
+// nothing was assigned.
+func (b *bot) assignAtClose(contest bidwar.Contest, by string) string {
+	if contest.UnassignedPolicy == bidwar.NoPolicy || b.bidwarTallier == nil {
+		return ""
+	}
+	stats, err := b.assignUnassigned(contest, by)
+	if err != nil {
+		log.Printf("ERROR auto-assigning donations for %s: %v", contest.Name, err)
+		return " (I couldn't assign the unassigned donations; try " + autoAssignCommand + ".)"
+	}
+	if stats.Count == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" Assigned %d unassigned donations (%s points).", stats.Count, stats.TotalValue)
+}
+
+// dispatchResolveCommand announces the winners of a contest, breaking any tie
+// according to the contest's tie-breaker.
+func (b *bot) dispatchResolveCommand(m twitch.PrivateMessage) {
+	go func() {
+		name := commandArgs(m.Message)
+		contest, ok := b.collection().ContestByName(name)
+		if !ok {
+			b.say(m.Channel, fmt.Sprintf("@%s: I don't know a contest called %q", m.User.Name, name))
+			return
+		}
+		if b.bidwarTallier == nil {
+			return
+		}
+		res, err := b.bidwarTallier.Resolve(contest)
+		if err != nil {
+			log.Printf("ERROR resolving %s: %v", contest.Name, err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the totals.", m.User.Name))
+			return
+		}
+		msg := res.Describe()
+		log.Printf("resolved %s: %s", contest.Name, msg)
+		b.predictions.resolve(res)
+		b.note(msg)
+		b.say(m.Channel, msg)
+		b.exportResults(contest)
+	}()
+}
+
+// exportResults saves a copy of the contest's donations to their own sheet,
+// if that's enabled, and tells the mods how it went.
+func (b *bot) exportResults(contest bidwar.Contest) {
+	if b.resultsSheetPrefix == "" {
+		return
+	}
+	sheetName := b.resultsSheetPrefix + contest.Name
+	n, err := b.bidwarTallier.ExportResults(contest, sheetName)
+	if err != nil {
+		log.Printf("ERROR exporting results for %s: %v", contest.Name, err)
+		b.note(fmt.Sprintf("Failed to save the donations for %s to %q: %v", contest.Name, sheetName, err))
+		return
+	}
+	b.note(fmt.Sprintf("Saved the %d donations for %s to %q.", n, contest.Name, sheetName))
+}
+
+// dispatchPollCommand polls all the donation providers right away, instead of
+// waiting for their next scheduled poll.
+func (b *bot) dispatchPollCommand(m twitch.PrivateMessage) {
+	if !strings.EqualFold(commandArgs(m.Message), "now") {
+		return
+	}
+	go func() {
+		if len(b.pollers) == 0 {
+			b.say(m.Channel, fmt.Sprintf("@%s: No donation providers are configured.", m.User.Name))
+			return
+		}
+		var names []string
+		for name := range b.pollers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var results []string
+		for _, name := range names {
+			n, err := b.pollers[name].PollNow()
+			if err != nil {
+				log.Printf("ERROR polling %s: %v", name, err)
+				results = append(results, fmt.Sprintf("%s: error", name))
+				continue
+			}
+			results = append(results, fmt.Sprintf("%s: %d new", name, n))
+		}
+		b.say(m.Channel, fmt.Sprintf("@%s: Polled for donations. %s", m.User.Name, strings.Join(results, ", ")))
+	}()
+}
+
+// dispatchRefreshCommand re-reads the bid war totals from the spreadsheet
+// (e.g., after someone edits it by hand) and announces the current standings.
+func (b *bot) dispatchRefreshCommand(m twitch.PrivateMessage) {
+	go func() {
+		if b.bidwarTallier == nil {
+			return
+		}
+		byContest, err := b.bidwarTallier.Refresh()
+		if err != nil {
+			log.Printf("ERROR refreshing bid war totals: %v", err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the spreadsheet.", m.User.Name))
+			return
+		}
+		b.settled.reset()
+		b.say(m.Channel, fmt.Sprintf("@%s: Refreshed the bid war totals.", m.User.Name))
+		for _, contest := range b.collection().Contests {
+			totals, ok := byContest[contest.Name]
+			if !ok {
+				continue
+			}
+			b.noteLeadChange(contest, totals)
+			if desc := totals.Describe(bidwar.Option{}); desc != "" {
+				b.say(m.Channel, fmt.Sprintf("%s: %s", contest.Name, desc))
+			}
+			b.announceSurges(chatCommand, m.Channel, contest, totals)
+		}
+	}()
+}
+
+// dispatchQuietCommand turns quiet mode on or off. In quiet mode, the bot
+// still records donations and assigns bids, but doesn't announce them.
+func (b *bot) dispatchQuietCommand(m twitch.PrivateMessage) {
+	switch strings.ToLower(commandArgs(m.Message)) {
+	case "on":
+		b.setQuiet(true)
+		b.say(m.Channel, fmt.Sprintf("@%s: Quiet mode is on. I'll keep recording donations, but I won't announce them.", m.User.Name))
+	case "off":
+		b.setQuiet(false)
+		b.say(m.Channel, fmt.Sprintf("@%s: Quiet mode is off.", m.User.Name))
+	default:
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s on|off", m.User.Name, quietCommand))
+	}
+}
+
+// dispatchContributorsCommand lists everyone who contributed to an option, so
+// that the streamers can thank them. The full list is logged; chat gets as
+// much as fits in one message.
+func (b *bot) dispatchContributorsCommand(m twitch.PrivateMessage) {
+	opt, ok := b.collection().FindOption(commandArgs(m.Message))
+	if !ok {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <option>", m.User.Name, contributorsCommand))
+		return
+	}
+	go func() {
+		if b.bidwarTallier == nil {
+			return
+		}
+		contribs, err := b.bidwarTallier.Contributors(opt)
+		if err != nil {
+			log.Printf("ERROR listing contributors to %s: %v", opt.ShortCode, err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the spreadsheet.", m.User.Name))
+			return
+		}
+		var names []string
+		for _, c := range contribs {
+			names = append(names, fmt.Sprintf("%s (%s)", c.Contributor, c.Value))
+		}
+		log.Printf("contributors to %s: %s", opt.ShortCode, strings.Join(names, ", "))
+		b.say(m.Channel, fmt.Sprintf("@%s: %s", m.User.Name, describeContributors(opt, names)))
+	}()
+}
+
+// describeContributors lists as many contributors as fit in a chat message.
+func describeContributors(opt bidwar.Option, names []string) string {
+	if len(names) == 0 {
+		return fmt.Sprintf("Nobody has bid for %s yet.", opt.DisplayName)
+	}
+	msg := fmt.Sprintf("%d contributors to %s:", len(names), opt.DisplayName)
+	for i, name := range names {
+		more := ""
+		if i < len(names)-1 {
+			more = fmt.Sprintf(" ...and %d more", len(names)-i-1)
+		}
+		if len(msg)+len(name)+len(more)+2 > maxChatLength {
+			return msg + fmt.Sprintf(" ...and %d more", len(names)-i)
+		}
+		if i > 0 {
+			msg += ","
+		}
+		msg += " " + name
+	}
+	return msg
+}
+
+// dispatchTopDonorsCommand announces the biggest supporters of an option.
+// Anyone can use it, so that donors can see who they need to beat.
+func (b *bot) dispatchTopDonorsCommand(m twitch.PrivateMessage) {
+	opt, ok := b.collection().FindOption(commandArgs(m.Message))
+	if !ok {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <option>", m.User.Name, topDonorsCommand))
+		return
+	}
+	go func() {
+		if b.bidwarTallier == nil {
+			return
+		}
+		top, err := b.bidwarTallier.TopContributors(opt, topDonorsCount)
+		if err != nil {
+			log.Printf("ERROR listing top donors to %s: %v", opt.ShortCode, err)
+			return
+		}
+		b.say(m.Channel, describeTopDonors(opt, top))
+	}()
+}
+
+// dispatchStandingsCommand posts the current totals of a contest, so that
+// viewers can check them between donations. Anyone can use it.
+func (b *bot) dispatchStandingsCommand(m twitch.PrivateMessage) {
+	con, ok := b.collection().SearchContest(commandArgs(m.Message))
+	if !ok {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <contest>", m.User.Name, standingsCommand))
+		return
+	}
 	go func() {
-		if err := b.dbRecorder.RecordDonation(ev, bid); err != nil {
-			log.Printf("ERROR writing donation to db: %v", err)
+		if b.bidwarTallier == nil {
+			return
+		}
+		totals, err := b.bidwarTallier.TotalsForContest(con)
+		if err != nil {
+			log.Printf("ERROR reading totals for %s: %v", con.Name, err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the spreadsheet.", m.User.Name))
+			return
+		}
+		desc := totals.Describe(bidwar.Option{})
+		if desc == "" {
+			desc = "No bids yet."
+		}
+		b.say(m.Channel, fmt.Sprintf("@%s: %s: %s", m.User.Name, con.Name, desc))
+	}()
+}
+
+// dispatchHypeCommand posts a longer summary of a contest, for the hosts to
+// read aloud.
+func (b *bot) dispatchHypeCommand(m twitch.PrivateMessage) {
+	con, ok := b.collection().SearchContest(commandArgs(m.Message))
+	if !ok {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <contest>", m.User.Name, hypeCommand))
+		return
+	}
+	go func() {
+		if b.bidwarTallier == nil {
+			return
+		}
+		totals, err := b.bidwarTallier.TotalsForContest(con)
+		if err != nil {
+			log.Printf("ERROR reading totals for %s: %v", con.Name, err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the spreadsheet.", m.User.Name))
+			return
+		}
+		now := time.Now()
+		var swing bidwar.Swing
+		var hasSwing bool
+		if b.momentum != nil {
+			swing, hasSwing = b.momentum.BiggestSwing(con, now)
+		}
+		b.say(m.Channel, totals.Hype(con, swing, hasSwing, now))
+	}()
+}
+
+// dispatchMyBidsCommand tells a user how their donations are allocated.
+// Anyone can use it.
+func (b *bot) dispatchMyBidsCommand(m twitch.PrivateMessage) {
+	go func() {
+		if b.bidwarTallier == nil {
+			return
+		}
+		allocs, err := b.bidwarTallier.Allocations(m.User.Name)
+		if err != nil {
+			log.Printf("ERROR reading allocations for %s: %v", m.User.Name, err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the spreadsheet.", m.User.Name))
+			return
+		}
+		b.say(m.Channel, fmt.Sprintf("@%s: %s", m.User.Name, describeAllocations(allocs)))
+	}()
+}
+
+// describeAllocations summarizes a donor's allocations, e.g., "You have 12.00
+// on Moo Moo Meadows, 5.00 unassigned."
+func describeAllocations(allocs []bidwar.Allocation) string {
+	if len(allocs) == 0 {
+		return "I don't see any donations from you yet."
+	}
+	var parts []string
+	for _, a := range allocs {
+		if a.Option.IsZero() {
+			parts = append(parts, fmt.Sprintf("%s unassigned", a.Value))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s on %s", a.Value, a.Option.DisplayName))
+		}
+	}
+	return fmt.Sprintf("You have %s.", strings.Join(parts, ", "))
+}
+
+// describeTopDonors announces the top donors to an option, e.g., "Top
+// supporters of Moo Moo Meadows: 1. Bob (20.00), 2. Alice (7.00)".
+func describeTopDonors(opt bidwar.Option, top []bidwar.Contribution) string {
+	if len(top) == 0 {
+		return fmt.Sprintf("Nobody has bid for %s yet.", opt.DisplayName)
+	}
+	var names []string
+	for i, c := range top {
+		names = append(names, fmt.Sprintf("%d. %s (%s)", i+1, c.Contributor, c.Value))
+	}
+	return fmt.Sprintf("Top supporters of %s: %s", opt.DisplayName, strings.Join(names, ", "))
+}
+
+// dispatchReloadBidsCommand re-reads the bid war data file.
+func (b *bot) dispatchReloadBidsCommand(m twitch.PrivateMessage) {
+	go func() {
+		c, err := b.reloadBidWars()
+		if err != nil {
+			log.Printf("ERROR reloading bid wars: %v", err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't reload the bid wars: %v", m.User.Name, err))
 			return
 		}
-		b.sayWithTotals(
+		b.say(m.Channel, fmt.Sprintf("@%s: Reloaded %d bid wars. Open options: %s", m.User.Name, len(c.Contests), strings.Join(b.openOptionCodes(), ", ")))
+	}()
+}
+
+// reloadBidWars re-reads the bid war sheet or data file and swaps in the new
+// bid wars. Pending !bid preferences and other state are kept. If the bid
+// wars can't be read, the old ones stay in place.
+func (b *bot) reloadBidWars() (bidwar.Collection, error) {
+	b.editMu.Lock()
+	defer b.editMu.Unlock()
+	var c bidwar.Collection
+	var err error
+	if b.bidwarSheet != nil {
+		c, err = readBidWarSheet(b.bidwarSheet)
+	} else if b.bidwarDataPath != "" {
+		c, err = readBidWars(b.bidwarDataPath)
+	} else {
+		err = errors.New("no bid war data file or sheet was given")
+	}
+	if err != nil {
+		return bidwar.Collection{}, err
+	}
+	c.ReasonOptions = b.collection().ReasonOptions
+	c.Random = b.collection().Random
+	b.setCollection(c)
+	log.Printf("reloaded %d bid wars", len(c.Contests))
+	return c, nil
+}
+
+func readBidWarSheet(s *googlesheets.BidWarSheet) (bidwar.Collection, error) {
+	rows, err := s.Read()
+	if err != nil {
+		return bidwar.Collection{}, err
+	}
+	c, err := bidwar.ParseSheetRows(rows)
+	if err != nil {
+		return bidwar.Collection{}, fmt.Errorf("malformed bid war sheet: %v", err)
+	}
+	return c, nil
+}
+
+func readBidWars(path string) (bidwar.Collection, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return bidwar.Collection{}, fmt.Errorf("could not read bid war data file: %v", err)
+	}
+	c, err := bidwar.Parse(data)
+	if err != nil {
+		return bidwar.Collection{}, fmt.Errorf("malformed bid war data file: %v", err)
+	}
+	return c, nil
+}
+
+// collection returns the current bid wars.
+func (b *bot) collection() bidwar.Collection {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.bidwars
+}
+
+// setCollection replaces the bid wars, both here and in the tallier.
+func (b *bot) setCollection(c bidwar.Collection) {
+	b.mu.Lock()
+	b.bidwars = c
+	b.mu.Unlock()
+	if b.bidwarTallier != nil {
+		b.bidwarTallier.SetCollection(c)
+	}
+}
+
+// dispatchSourcesCommand reports how much came in from each donation source,
+// optionally only during the given campaign.
+func (b *bot) dispatchSourcesCommand(m twitch.PrivateMessage) {
+	campaign := commandArgs(m.Message)
+	go func() {
+		totals, err := b.sourceTotals(campaign)
+		if err != nil {
+			log.Printf("ERROR computing donation sources: %v", err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the spreadsheet.", m.User.Name))
+			return
+		}
+		b.say(m.Channel, fmt.Sprintf("@%s: %s", m.User.Name, report.DescribeSources(totals)))
+	}()
+}
+
+// dispatchReportCommand posts the end-of-event report to the producer notes
+// (and the log). If a campaign is given, the donations in the report are
+// limited to that campaign.
+func (b *bot) dispatchReportCommand(m twitch.PrivateMessage) {
+	campaign := commandArgs(m.Message)
+	go func() {
+		r := report.Report{Campaign: campaign}
+		if rows, err := b.donationRows(campaign); err != nil {
+			log.Printf("ERROR computing donation sources: %v", err)
+		} else {
+			r.Sources = report.SourceTotals(rows)
+			r.Credits = report.CreditTotals(rows)
+		}
+		r.Latency = b.latency.Summary()
+		log.Printf("end-of-event report:\n%s", r)
+		if b.notes == nil {
+			b.say(m.Channel, fmt.Sprintf("@%s: The report is in the log.", m.User.Name))
+			return
+		}
+		b.note("End-of-event report:\n" + r.String())
+		b.say(m.Channel, fmt.Sprintf("@%s: The report is in the notes and the log.", m.User.Name))
+	}()
+}
+
+// sourceTotals adds up the money in the donation table by source. If
+// campaign isn't empty, only that campaign's donations are counted.
+func (b *bot) sourceTotals(campaign string) ([]report.SourceTotal, error) {
+	rows, err := b.donationRows(campaign)
+	if err != nil {
+		return nil, err
+	}
+	return report.SourceTotals(rows), nil
+}
+
+// donationRows reads the donation table, including its header. If campaign
+// isn't empty, only that campaign's donations are returned.
+func (b *bot) donationRows(campaign string) ([][]interface{}, error) {
+	if b.donationTable == nil {
+		return nil, errors.New("donations aren't recorded in Google Sheets")
+	}
+	vr, err := b.donationTable.GetTable()
+	if err != nil {
+		return nil, err
+	}
+	rows := vr.Values
+	if campaign != "" {
+		rows = report.FilterCampaign(rows, campaign)
+	}
+	return rows, nil
+}
+
+// grandTotal adds up the money in the donation table, from all campaigns.
+// Bid war credit that isn't money (e.g., channel points) is left out.
+func (b *bot) grandTotal() (donation.CentsValue, error) {
+	totals, err := b.sourceTotals("")
+	if err != nil {
+		return 0, err
+	}
+	var sum donation.CentsValue
+	for _, t := range totals {
+		sum += t.Value
+	}
+	return sum, nil
+}
+
+// dispatchLateBidsCommand accepts or rejects all the held late bids. Accepted
+// bids are assigned to the options they asked for; rejected bids stay
+// unassigned.
+func (b *bot) dispatchLateBidsCommand(m twitch.PrivateMessage, accept bool) {
+	if !strings.EqualFold(commandArgs(m.Message), "late") {
+		return
+	}
+	go func() {
+		bids := b.takeLateBids()
+		if !accept {
+			b.say(m.Channel, fmt.Sprintf("@%s: Rejected %d late bids. Those donations stay unassigned.", m.User.Name, len(bids)))
+			return
+		}
+		if b.bidwarTallier == nil {
+			return
+		}
+		count := 0
+		var total donation.CentsValue
+		for _, lb := range bids {
+			stats, err := b.bidwarTallier.AssignToChoice(lb.Donor, lb.Choice)
+			if err != nil {
+				log.Printf("ERROR accepting late bid from %s for %s: %v", lb.Donor, lb.Choice.Option.ShortCode, err)
+				continue
+			}
+			b.auditStats(audit.LateBid, lb.Donor, stats, m.User.Name)
+			count += stats.Count
+			total += stats.TotalValue
+		}
+		b.say(m.Channel, fmt.Sprintf("@%s: Accepted %d late bids (%d donations, %s points).", m.User.Name, len(bids), count, total))
+	}()
+}
+
+// dispatchMoneyDonation handles a tip from one of the donation providers. If
+// matchDonorName is true, and the donation message doesn't mention a bid war
+// option, we also look for one in the donor's name.
+func (b *bot) dispatchMoneyDonation(ev donation.Event, matchDonorName bool) {
+	go b.moneyTask(ev, matchDonorName)()
+}
+
+func (b *bot) moneyTask(ev donation.Event, matchDonorName bool) donationTask {
+	received := time.Now()
+	b.status.sawDonation(ev.Source, received)
+	log.Printf("new dolla donation by %v worth $%s (cash: %s)", ev.Owner, ev.Value(), ev.Cash)
+	bid := b.getChoice(ev, bidwar.FromDonationMessage, matchDonorName)
+	return func() error {
+		if ok, err := b.tryRecordDonation(ev, bid); !ok {
+			return err
+		}
+		recorded := time.Now()
+		b.noteDonation(ev, bid)
+		b.handleClosedChoice(ev.Channel, ev.Owner, fmt.Sprintf("$%s donation from %s:", ev.Value(), ev.Owner), bid)
+		b.queueCatchUp(ev, bid.Option)
+		replied := b.sayWithTotals(
+			chatDonation,
 			ev.Channel,
 			bid.Option,
-			fmt.Sprintf("$%s donation from %s put towards %s.",
-				ev.Value(), ev.Owner, bid.Option.DisplayName))
-	}()
+			fmt.Sprintf("$%s donation from %s put towards %s.%s%s",
+				ev.Value(), ev.Owner, bid.Option.DisplayName, b.overflowNote(bid), b.capNote(ev, bid.Option))) != ""
+		b.recognizeStreak(ev)
+		b.observeLatency(ev, received, recorded, replied)
+		return nil
+	}
+}
+
+// recordDonation writes a donation to the database. If that fails, it alerts
+// the mods, saves the donation in the journal to retry later, and (depending
+// on the DB failure policy) tells the donor that the totals are delayed.
+// Returns whether the donation was recorded.
+func (b *bot) recordDonation(ev donation.Event, bid bidwar.Choice) bool {
+	ok, _ := b.tryRecordDonation(ev, bid)
+	return ok
+}
+
+// tryRecordDonation is like recordDonation, but also returns an error if the
+// donation wasn't recorded and couldn't be saved in the journal either. A
+// donation that's skipped on purpose (e.g., a duplicate) isn't an error.
+func (b *bot) tryRecordDonation(ev donation.Event, bid bidwar.Choice) (bool, error) {
+	if b.readOnly {
+		log.Printf("WARNING: read-only; not recording %s's $%s donation", ev.Owner, ev.Value())
+		return false, nil
+	}
+	if ev.Campaign == "" {
+		ev.Campaign = b.currentCampaign()
+	}
+	// Don't hold up the donation for a Twitch lookup. If the ID isn't known
+	// yet, it'll be known for the donor's next donation.
+	if ev.OwnerID == "" {
+		ev.OwnerID = b.users.KnownUserID(b.identities.TwitchUser(ev.Owner))
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	err := b.dbRecorder.RecordDonation(ev, bid)
+	if err == nil {
+		b.bidCache.forget(b.identities.TwitchUser(ev.Owner))
+		b.bidLimiter.forget(b.identities.TwitchUser(ev.Owner))
+		if bid.Option.IsZero() {
+			b.settled.unsettle(b.identities.TwitchUser(ev.Owner))
+		}
+		b.rememberForRecap(ev)
+		if !bid.Option.IsZero() {
+			b.audit(audit.Entry{Action: audit.Donation, Donor: ev.Owner, DonorID: ev.OwnerID, Option: bid.Option.ShortCode, Count: 1, Cents: ev.Value().Cents(), Reason: bid.Reason, By: ev.Owner})
+		}
+		if len(b.hooks) > 0 {
+			go b.runHooks(ev, bid)
+		}
+		return true, nil
+	}
+	if errors.Is(err, db.ErrDuplicate) {
+		log.Printf("already recorded %s from %s (ID %s); skipping it", ev.Description(), ev.Owner, ev.ID)
+		b.dropped.add(dropDuplicate, ev)
+		return false, nil
+	}
+	log.Printf("ERROR writing donation to db: %v", err)
+	b.note(fmt.Sprintf("Failed to record %s from %s: %v", ev.Description(), ev.Owner, err))
+	lost := err
+	if b.journal != nil {
+		if jerr := b.journal.Add(ev, bid, err); jerr != nil {
+			log.Printf("ERROR saving donation to journal: %v", jerr)
+		} else {
+			lost = nil
+		}
+	}
+	if b.ackFailedDonations && ev.Value() >= b.minimumDonation {
+		b.sayAs(chatDonation, ev.Channel, fmt.Sprintf("Thanks for the %s, %s! It's recorded pending, so the totals are delayed.", ev.Description(), ev.Owner))
+	}
+	return false, lost
+}
+
+// observeLatency logs how long a donation took to be recorded and (if the bot
+// replied with the totals) to be answered in chat, counting from when the bot
+// received it. The latencies go into the end-of-event report.
+func (b *bot) observeLatency(ev donation.Event, received time.Time, recorded time.Time, replied bool) {
+	recordedAfter := recorded.Sub(received)
+	b.latency.Observe(report.StageRecorded, recordedAfter)
+	if !replied {
+		log.Printf("latency for %s from %s: recorded after %v", ev.Description(), ev.Owner, recordedAfter)
+		return
+	}
+	repliedAfter := time.Since(received)
+	b.latency.Observe(report.StageReplied, repliedAfter)
+	log.Printf("latency for %s from %s: recorded after %v, replied after %v", ev.Description(), ev.Owner, recordedAfter, repliedAfter)
+}
+
+// runHooks calls each hook for a donation that was just recorded.
+func (b *bot) runHooks(ev donation.Event, bid bidwar.Choice) {
+	for _, h := range b.hooks {
+		if err := h.OnDonation(ev, bid); err != nil {
+			log.Printf("ERROR running donation hook: %v", err)
+		}
+	}
 }
 
-func (b *bot) getChoice(ev donation.Event, reason bidwar.ChoiceReason) bidwar.Choice {
+func (b *bot) getChoice(ev donation.Event, reason bidwar.ChoiceReason, matchDonorName bool) bidwar.Choice {
 	if ev.Value() < b.minimumDonation {
+		b.dropped.add(dropBelowMinimum, ev)
 		return bidwar.Choice{}
 	}
-	choice := b.bidwars.ChoiceFromMessage(ev.Message, reason)
+	choice := b.collection().ChoiceFromMessage(ev.Message, reason)
 	if !choice.Option.IsZero() {
 		return choice
 	}
+	if pref, ok := b.popPref(b.identities.TwitchUser(ev.Owner)); ok {
+		return pref
+	}
+	if matchDonorName {
+		if nameChoice := b.collection().ChoiceFromDonorName(ev.Owner); !nameChoice.Option.IsZero() {
+			return nameChoice
+		}
+	}
+	// A late bid is held for a mod, and its reason is only written if the bid
+	// is accepted, so that a rejected bid doesn't leave one behind.
+	if b.inGracePeriod(choice.ClosedOption) {
+		return bidwar.Choice{ClosedOption: choice.ClosedOption}
+	}
+	// Keep the reason (i.e., the message) even though there's no Option, in
+	// case the donation gets auto-assigned when a contest closes.
+	return bidwar.Choice{Reason: choice.Reason, ClosedOption: choice.ClosedOption}
+}
+
+// handleClosedChoice deals with a donation that wasn't assigned because the
+// donor chose a closed option. If the option's contest closed very recently,
+// the bid is held for a mod to accept or reject; otherwise, we just tell the
+// donor that the option is closed.
+func (b *bot) handleClosedChoice(channel string, donor string, msgPrefix string, bid bidwar.Choice) {
+	if !bid.Option.IsZero() || bid.ClosedOption.IsZero() {
+		return
+	}
+	if b.holdLateBid(donor, bid.ClosedOption) {
+		b.sayAs(chatDonation, channel, msgPrefix+" "+lateBidMessage(bid.ClosedOption))
+		return
+	}
+	b.sayAs(chatDonation, channel, msgPrefix+" "+b.closedOptionMessage(bid.ClosedOption))
+}
+
+// holdLateBid saves a bid for a closed option, if the option's contest is in
+// its grace period. Returns whether the bid was saved.
+func (b *bot) holdLateBid(donor string, opt bidwar.Option) bool {
+	if !b.inGracePeriod(opt) {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lateBids = append(b.lateBids, lateBid{
+		Donor:  donor,
+		Choice: bidwar.Choice{Option: opt, Reason: "[late bid accepted]"},
+	})
+	return true
+}
+
+// inGracePeriod reports whether a bid for opt would be held as a late bid.
+func (b *bot) inGracePeriod(opt bidwar.Option) bool {
+	if opt.IsZero() {
+		return false
+	}
+	con, ok := b.collection().ContestForOption(opt)
+	return ok && con.InGracePeriod(time.Now())
+}
+
+// takeLateBids returns (and forgets) all the held late bids.
+func (b *bot) takeLateBids() []lateBid {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bids := b.lateBids
+	b.lateBids = nil
+	return bids
+}
+
+func lateBidMessage(opt bidwar.Option) string {
+	return fmt.Sprintf("Bidding for %s just closed, so a mod will decide whether your late bid counts.", opt.DisplayName)
+}
+
+// closedOptionMessage explains that an option (or its whole contest) is
+// closed, and lists the options that are still open.
+func (b *bot) closedOptionMessage(closed bidwar.Option) string {
+	msg := fmt.Sprintf("%s is closed, so I didn't assign your bid.", closed.DisplayName)
+	if con, ok := b.collection().ContestForOption(closed); ok && con.Closed {
+		msg = fmt.Sprintf("Bidding for %s is closed, so I didn't assign your bid.", con.Name)
+	}
+	if codes := b.openOptionCodes(); len(codes) > 0 {
+		msg += fmt.Sprintf(" Still open: %s", strings.Join(codes, ", "))
+	}
+	return msg
+}
+
+// openOptionCodes returns the short codes of all the open options.
+func (b *bot) openOptionCodes() []string {
+	var codes []string
+	for _, o := range b.collection().AllOpenOptions() {
+		codes = append(codes, o.ShortCode)
+	}
+	return codes
+}
+
+// popPref returns (and forgets) the unexpired bid preference for a user, if
+// there is one.
+func (b *bot) popPref(username string) (bidwar.Choice, bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	donor := strings.ToLower(ev.Owner)
+	donor := strings.ToLower(username)
 	pref, ok := b.pendingBids[donor]
 	delete(b.pendingBids, donor)
 	if !ok {
-		return bidwar.Choice{}
+		return bidwar.Choice{}, false
 	}
 	if time.Now().After(pref.Expiration) {
-		return bidwar.Choice{}
+		return bidwar.Choice{}, false
 	}
-	return pref.Choice
+	return pref.Choice, true
 }
 
 func (b *bot) rememberPref(username string, choice bidwar.Choice) {
@@ -172,6 +1335,51 @@ func (b *bot) rememberPref(username string, choice bidwar.Choice) {
 	b.pendingBids[strings.ToLower(username)] = &bidPreference{Choice: choice, Expiration: time.Now().Add(bidPrefTTL)}
 }
 
+// rememberRecentBid notes which rows a !bid just assigned, so that the donor
+// can move them with another !bid.
+func (b *bot) rememberRecentBid(username string, stats bidwar.UpdateStats) {
+	if b.bidChangeWindow <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recentBids[strings.ToLower(username)] = &recentBid{Choice: stats.Choice, Rows: stats.Rows, At: time.Now()}
+}
+
+// moveRecentBid moves the rows assigned by the donor's last !bid to a new
+// choice, if that !bid was recent enough and its option is still open.
+// Returns how much was moved and the option that it came from.
+func (b *bot) moveRecentBid(username string, to bidwar.Choice) (bidwar.UpdateStats, bidwar.Option, bool) {
+	b.mu.Lock()
+	recent, ok := b.recentBids[strings.ToLower(username)]
+	b.mu.Unlock()
+	if !ok || time.Since(recent.At) > b.bidChangeWindow || recent.Choice.Option.ShortCode == to.Option.ShortCode {
+		return bidwar.UpdateStats{}, bidwar.Option{}, false
+	}
+	from := recent.Choice.Option
+	if opt, ok := b.collection().OptionByShortCode(from.ShortCode); !ok || opt.Closed {
+		return bidwar.UpdateStats{}, bidwar.Option{}, false
+	}
+	if con, ok := b.collection().ContestForOption(from); !ok || con.Closed {
+		return bidwar.UpdateStats{}, bidwar.Option{}, false
+	}
+	stats, err := b.bidwarTallier.Reassign(username, recent.Rows, recent.Choice, to)
+	if err != nil {
+		log.Printf("ERROR moving %s's bid from %s to %s: %v", username, from.ShortCode, to.Option.ShortCode, err)
+		return bidwar.UpdateStats{}, bidwar.Option{}, false
+	}
+	if stats.Count == 0 {
+		return bidwar.UpdateStats{}, bidwar.Option{}, false
+	}
+	b.audit(audit.Entry{Action: audit.Reassign, Donor: username, Option: to.Option.ShortCode, From: from.ShortCode, Count: stats.Count, Cents: stats.TotalValue.Cents(), Reason: to.Reason, By: username})
+	b.mu.Lock()
+	// Keep the original time, so that the window isn't extended by each move.
+	recent.Choice = to
+	recent.Rows = stats.Rows
+	b.mu.Unlock()
+	return stats, from, true
+}
+
 func (b *bot) updateCommunityGift(ev donation.Event) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -188,7 +1396,7 @@ func (b *bot) shouldIgnoreSubGift(ev donation.Event) bool {
 }
 
 func (b *bot) getNewTotals(opt bidwar.Option) (bidwar.Totals, error) {
-	contest := b.bidwars.FindContest(opt)
+	contest := b.collection().FindContest(opt)
 	if contest.Name == "" {
 		return bidwar.Totals{}, fmt.Errorf("could not find bid war for option %q", opt.ShortCode)
 	}
@@ -200,30 +1408,197 @@ func (b *bot) getNewTotals(opt bidwar.Option) (bidwar.Totals, error) {
 }
 
 func (b *bot) say(channel string, msg string) {
-	if !b.chatLimiter.Allow() {
+	b.reply(channel, "", msg)
+}
+
+// reply sends a chat message in reply to the chat message with the given ID
+// ("" if it isn't a reply).
+func (b *bot) reply(channel string, parentID string, msg string) {
+	b.replyLimited(b.chatLimiter, false, channel, parentID, msg)
+}
+
+// replyLimited is like reply, but draws from the given rate limiter. If
+// quietable is true, the message is one that quiet mode holds back, which
+// matters if it has to be deferred.
+func (b *bot) replyLimited(limiter *rate.Limiter, quietable bool, channel string, parentID string, msg string) {
+	if !limiter.Allow() {
+		if b.deferReply(queuedChat{channel: channel, parentID: parentID, msg: msg, quietable: quietable}) {
+			log.Printf("[delayed by cooldown for #%v] %v", channel, msg)
+			return
+		}
+		atomic.AddInt64(&b.droppedChat, 1)
 		log.Printf("[on cooldown for #%v] %v", channel, msg)
 		return
 	}
+	b.sendChat(channel, parentID, msg)
+}
+
+// sendChat sends a chat message, ignoring the rate limiter.
+func (b *bot) sendChat(channel string, parentID string, msg string) {
+	if b.outage.down(time.Now()) {
+		log.Printf("[IRC down; not sent to #%v] %v", channel, msg)
+		return
+	}
 	log.Printf("[-> #%v] %v", channel, msg)
-	if b.ircRepliesEnabled {
-		b.ircClient.Say(channel, msg)
+	if !b.ircRepliesEnabled {
+		return
+	}
+	if b.replier != nil && parentID != "" {
+		err := b.replier.Reply(channel, parentID, withoutMention(msg))
+		if err == nil {
+			return
+		}
+		// The @mention still says who the reply is for.
+		log.Printf("WARNING: %v; sending an @mention instead", err)
 	}
+	b.ircClient.Say(channel, msg)
+}
+
+// threadedReplier threads a reply under the chat message it answers. (The IRC
+// client can't; see twitchchat.Replier.)
+type threadedReplier interface {
+	Reply(channel string, parentMsgID string, text string) error
+}
+
+// sayWithTotals announces the new totals for an option's contest, prefixed
+// with msgPrefix. Returns the message, or "" if nothing was said.
+func (b *bot) sayWithTotals(kind chatKind, channel string, opt bidwar.Option, msgPrefix string) string {
+	return b.replyWithTotals(kind, channel, "", opt, msgPrefix)
 }
 
-func (b *bot) sayWithTotals(channel string, opt bidwar.Option, msgPrefix string) {
+// replyWithTotals is like sayWithTotals, but the announcement is a reply to
+// the chat message with the given ID.
+func (b *bot) replyWithTotals(kind chatKind, channel string, parentID string, opt bidwar.Option, msgPrefix string) string {
 	if opt.IsZero() {
-		return
+		return ""
 	}
 	totals, err := b.getNewTotals(opt)
 	if err != nil {
 		log.Printf("ERROR reading new bid war totals: %v", err)
-		return
+		return ""
 	}
+	contest := b.collection().FindContest(opt)
+	b.noteLeadChange(contest, totals)
+	b.checkAutoLock(kind, channel, contest, totals)
 	msg := totals.Describe(opt)
+	if b.whisperAck(kind, channel, msgPrefix) {
+		// The totals stay public.
+		msgPrefix = ""
+	}
 	if msgPrefix != "" {
 		msg = msgPrefix + " " + msg
 	}
-	b.say(channel, msg)
+	b.replyAs(kind, channel, parentID, msg)
+	b.announceSurges(kind, channel, contest, totals)
+	return msg
+}
+
+// announceSurges hypes up any options in the contest that just started
+// surging.
+func (b *bot) announceSurges(kind chatKind, channel string, contest bidwar.Contest, totals bidwar.Totals) {
+	if b.momentum == nil {
+		return
+	}
+	for _, s := range b.momentum.Observe(contest, totals, time.Now()) {
+		b.sayAs(kind, channel, s.Describe())
+	}
+}
+
+// note posts a producer-facing note, if notes are enabled.
+func (b *bot) note(msg string) {
+	if b.notes == nil {
+		return
+	}
+	log.Printf("[note] %v", msg)
+	if err := b.notes.Post(msg); err != nil {
+		log.Printf("ERROR posting note: %v", err)
+	}
+}
+
+func (b *bot) noteDonation(ev donation.Event, bid bidwar.Choice) {
+	if b.notes == nil || ev.Value() < b.bigDonation {
+		return
+	}
+	dest := "unassigned"
+	if !bid.Option.IsZero() {
+		dest = bid.Option.DisplayName
+	}
+	msg := fmt.Sprintf("Big donation: %s from %s (%s points) -> %s", ev.Description(), ev.Owner, ev.Value(), dest)
+	if ev.Message != "" {
+		msg += fmt.Sprintf(" | message: %q", ev.Message)
+	}
+	b.note(msg)
+}
+
+// noteLeadChange posts a note if the leader of the contest is different from
+// the last time we looked.
+func (b *bot) noteLeadChange(contest bidwar.Contest, totals bidwar.Totals) {
+	if b.notes == nil || contest.Name == "" {
+		return
+	}
+	leaders := totals.Leaders()
+	var names []string
+	for _, opt := range leaders {
+		names = append(names, opt.DisplayName)
+	}
+	sort.Strings(names)
+	current := strings.Join(names, ", ")
+
+	b.mu.Lock()
+	prev, seen := b.leaders[contest.Name]
+	b.leaders[contest.Name] = current
+	b.mu.Unlock()
+	if !seen || prev == current || current == "" {
+		return
+	}
+	desc := "took the lead"
+	if len(leaders) > 1 {
+		desc = "are tied for the lead"
+	}
+	b.note(fmt.Sprintf("Lead change in %s: %s %s (previously %s). %s", contest.Name, current, desc, prev, totals.Describe(bidwar.Option{})))
+}
+
+// chatNotePoster posts notes to a Twitch channel.
+type chatNotePoster struct {
+	client  *twitch.Client
+	channel string
+}
+
+func (p chatNotePoster) Post(msg string) error {
+	p.client.Say(p.channel, msg)
+	return nil
+}
+
+// bidTallier is the subset of *bidwar.Tallier's methods that the bot uses.
+// The load tests replace it with a fake.
+type bidTallier interface {
+	GetTotals() ([]bidwar.Total, error)
+	AssignFromMessage(donor string, message string) (bidwar.UpdateStats, error)
+	AssignUnassigned(contest bidwar.Contest) (bidwar.UpdateStats, error)
+	TotalsForContest(contest bidwar.Contest) (bidwar.Totals, error)
+	Refresh() (map[string]bidwar.Totals, error)
+	AssignToChoice(donor string, choice bidwar.Choice) (bidwar.UpdateStats, error)
+	Contributors(opt bidwar.Option) ([]bidwar.Contribution, error)
+	TopContributors(opt bidwar.Option, n int) ([]bidwar.Contribution, error)
+	Allocations(donor string) ([]bidwar.Allocation, error)
+	RenameChoice(oldCode string, newCode string) (int, error)
+	AssignSplit(donor string, split bidwar.Split) ([]bidwar.UpdateStats, error)
+	Reassign(donor string, rows []int, from bidwar.Choice, to bidwar.Choice) (bidwar.UpdateStats, error)
+	Resolve(contest bidwar.Contest) (bidwar.Resolution, error)
+	ExportResults(contest bidwar.Contest, sheetName string) (int, error)
+	SetCollection(c bidwar.Collection)
+	ApplyCap(donor string, opt bidwar.Option) (donation.CentsValue, error)
+}
+
+// donationPoller is a donation provider that can be polled on demand.
+type donationPoller interface {
+	PollNow() (int, error)
+}
+
+// lateBid is a bid for a contest that closed moments earlier.
+type lateBid struct {
+	Donor  string
+	Choice bidwar.Choice
 }
 
 // bidPreference represents a bid war choice that somebody expressed in the past.
@@ -232,11 +1607,112 @@ type bidPreference struct {
 	Expiration time.Time
 }
 
+type recentBid struct {
+	Choice bidwar.Choice
+	// The donation table rows that the !bid assigned.
+	Rows []int
+	At   time.Time
+}
+
 func firstTokenIs(message, target string) bool {
 	tokens := strings.Split(message, " ")
 	return len(tokens) > 0 && tokens[0] == target
 }
 
+// commandArgs returns everything after the first token of a chat message.
+func commandArgs(message string) string {
+	tokens := strings.SplitN(strings.TrimSpace(message), " ", 2)
+	if len(tokens) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(tokens[1])
+}
+
+// dispatchCommand runs the chat command that the message matches, if any and
+// if the user is allowed to use it.
+func (b *bot) dispatchCommand(m twitch.PrivateMessage) {
+	msg := strings.ToLower(m.Message)
+	for _, c := range chatCommands() {
+		if !c.matches(msg) {
+			continue
+		}
+		if !b.perms.allowed(m.User, c) {
+			return
+		}
+		if b.readOnly && c.writes {
+			b.say(m.Channel, fmt.Sprintf("@%s: The event is over, so %s is turned off.", m.User.Name, c.name))
+			return
+		}
+		if b.perms.roleOf(m.User) < moderatorRole && !b.cooldowns.allow(c.name, time.Now()) {
+			log.Printf("%s is on cooldown; ignoring %s's %q", c.name, m.User.Name, m.Message)
+			return
+		}
+		c.run(b, m)
+		return
+	}
+}
+
+// dispatchHelpCommand lists the commands that the user can use, or describes
+// one of them.
+func (b *bot) dispatchHelpCommand(m twitch.PrivateMessage) {
+	b.say(m.Channel, fmt.Sprintf("@%s: %s", m.User.Name, b.perms.helpText(commandArgs(m.Message), b.perms.roleOf(m.User))))
+}
+
+// helpText describes the named command, or lists all the commands if name is
+// empty. Commands that need a higher role than r are left out.
+func (p permissions) helpText(name string, r role) string {
+	var names []string
+	for _, c := range chatCommands() {
+		if p.required(c) > r {
+			continue
+		}
+		if name == "" {
+			names = append(names, c.name)
+			continue
+		}
+		query := strings.ToLower(name)
+		if !strings.HasPrefix(query, "!") {
+			query = "!" + query
+		}
+		if c.matches(query) {
+			return fmt.Sprintf("%s - %s", c.usage(), c.help)
+		}
+	}
+	if name != "" {
+		return fmt.Sprintf("I don't know a command called %s.", name)
+	}
+	msg := "Commands: "
+	more := fmt.Sprintf(". Say %s <command> for details.", helpCommand)
+	for i, n := range names {
+		sep := ", "
+		if i == 0 {
+			sep = ""
+		}
+		if len(msg)+len(sep)+len(n)+len(more)+30 > maxChatLength {
+			msg += ", ..."
+			break
+		}
+		msg += sep + n
+	}
+	return msg + more
+}
+
+// retryJournal periodically retries the donations that we couldn't record.
+func (b *bot) retryJournal(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := b.journal.Replay(unsettlingRecorder{rec: b.dbRecorder, b: b})
+		if err != nil {
+			log.Printf("ERROR replaying journal: %v", err)
+			continue
+		}
+		if n > 0 {
+			b.note(fmt.Sprintf("Recorded %d donations from the retry journal.", n))
+		}
+	}
+}
+
 func doLocalTest(b *bot, channel string, ircClient *twitch.Client, tallier *bidwar.Tallier) {
 	<-time.After(2 * time.Second)
 	ircClient.Say(channel, "subgift --tier 2 --months 6 --username aerionblue --username2 AEWC20XX")
@@ -257,8 +1733,13 @@ func doLocalTest(b *bot, channel string, ircClient *twitch.Client, tallier *bidw
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == importSubcommand {
+		runImport(os.Args[2:])
+		return
+	}
 	prod := flag.Bool("prod", false, "Whether to use real twitch.tv IRC. If false, connects to fdgt instead.")
-	targetChannel := flag.String("channel", "aerionblue", "The IRC channel to listen to")
+	var channelFlags channelFlag
+	flag.Var(&channelFlags, "channel", "The IRC channel to listen to. Repeat it to join several channels, each with its own bid wars, donation records, and donation providers (see the channels config); the donation provider and Twitch API flags only cover the first channel. Defaults to the channels in the config, or else "+defaultChannel)
 	configPath := flag.String("config_json", "", "Path to the bot config JSON file. Required.")
 	twitchChatCredsPath := flag.String("twitch_chat_creds", "", "Path to the Twitch chat credentials file")
 	twitchChatRepliesEnabled := flag.Bool("chat_replies_enabled", true, "Whether Twitch chat replies are enabled")
@@ -269,6 +1750,14 @@ func main() {
 	streamlabsCredsPath := flag.String("streamlabs_creds", "", "Path to a Streamlabs OAuth token. If absent, Streamlabs donation checking will be disabled")
 	tipLogPath := flag.String("tip_log_path", "", "Path to a text file where some other process is logging incoming donations")
 	bidWarDataPath := flag.String("bidwar_data", "", "Path to a JSON file describing the current bid wars")
+	controlPath := flag.String("control_file", "", "Path to a file where a service manager can write commands (pause, resume, reload-config, or shutdown). If absent, control commands are disabled")
+	twitchAPICredsPath := flag.String("twitch_api_creds", "", "Path to a Twitch API credentials file, for running Predictions on contests and looking up donors' Twitch user IDs. If absent, Predictions and ID lookups are disabled")
+	whisperCredsPath := flag.String("twitch_whisper_creds", "", "Path to a Twitch API credentials file for the bot's own account, with the user:manage:whispers scope. Required if the bot whispers users (see the whispers and cooldown configs)")
+	eventSubCredsPath := flag.String("twitch_eventsub_creds", "", "Path to a Twitch API credentials file, for reading subs and cheers from EventSub. Required if the EventSub mode is set")
+	httpAddr := flag.String("http_addr", "", "Address on which to serve the HTTP API (e.g. \":8080\"). The first channel's API is at the root, and each channel's is under /channels/<channel>/. If absent, the HTTP API is disabled")
+	role := flag.String("role", roleAll, "Which part of the bot to run: all, ingest (read donations into the event queue), or record (record and announce the donations in the event queue, and handle commands)")
+	queuePath := flag.String("queue_file", "", "Path to the event queue file that the ingest and record processes share. Required unless --role=all")
+	readOnly := flag.Bool("readonly", false, "If true, the event is over: donations aren't read or recorded and the bids can't be changed, but the totals can still be queried in chat and over the HTTP API. Only works with --role=all")
 	flag.Parse()
 
 	if *configPath == "" {
@@ -278,18 +1767,40 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	donation.SetDisplayPrecision(cfg.Display.precision())
+	rates := cfg.rates()
+
+	fromFlags := ChannelConfig{
+		BidWarDataPath:      *bidWarDataPath,
+		StreamElementsCreds: *streamelementsCredsPath,
+		StreamlabsCreds:     *streamlabsCredsPath,
+		TipLogPath:          *tipLogPath,
+		EventSubCreds:       *eventSubCredsPath,
+		TwitchAPICreds:      *twitchAPICredsPath,
+	}
+	channelCfgs, err := resolveChannels(channelFlags, cfg, fromFlags, *sheetsCredsPath != "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The ingest role and the local test only cover the first channel.
+	targetChannel := channelCfgs[0].Name
 
 	var ircClient *twitch.Client
+	// Sends threaded replies, if they're turned on. Nil otherwise.
+	var replier *twitchchat.Replier
 	ircRepliesEnabled := *twitchChatRepliesEnabled
 	if *prod {
-		log.Printf("*** CONNECTING TO PROD #%s ***", *targetChannel)
+		log.Printf("*** CONNECTING TO PROD #%s ***", targetChannel)
 		chatCreds, err := twitchchat.ParseCreds(*twitchChatCredsPath)
 		if err != nil {
 			log.Fatal(err)
 		}
 		ircClient = twitch.NewClient(chatCreds.Username, chatCreds.OAuthToken)
+		if cfg.Replies.Threaded {
+			replier = twitchchat.NewReplier(chatCreds)
+		}
 	} else {
-		log.Printf("--- connecting to fdgt #%s ---", *targetChannel)
+		log.Printf("--- connecting to fdgt #%s ---", targetChannel)
 		ircClient = twitch.NewAnonymousClient()
 		ircClient.IrcAddress = testIRCAddress
 		ircClient.TLS = false
@@ -297,136 +1808,543 @@ func main() {
 	}
 	ircClient.Capabilities = []string{twitch.CommandsCapability, twitch.TagsCapability}
 
-	var bidwars bidwar.Collection
-	if *bidWarDataPath != "" {
-		var err error
-		data, err := ioutil.ReadFile(*bidWarDataPath)
+	if err := validateRole(*role, *queuePath, cfg.EventSub.Mode); err != nil {
+		log.Fatal(err)
+	}
+	if *readOnly && *role != roleAll {
+		log.Fatal("--readonly only works with --role=all")
+	}
+	if len(channelCfgs) > 1 && *role != roleAll {
+		log.Fatal("multiple channels only work with --role=all")
+	}
+	if *role == roleAll && !*readOnly {
+		if err := checkDonationSources(channelCfgs); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *role == roleIngest {
+		httpClient, err := newHTTPClient(cfg.HTTP)
 		if err != nil {
-			log.Fatalf("could not read bid war data file: %v", err)
+			log.Fatalf("invalid HTTP config: %v", err)
+		}
+		p := newProviders(channelCfgs[0].providerFlags(), targetChannel, true, cfg, httpClient)
+		if p.tipWatcher != nil {
+			defer p.tipWatcher.Close()
 		}
-		bidwars, err = bidwar.Parse(data)
+		runIngest(ircClient, targetChannel, cfg, p, eventqueue.NewWriter(*queuePath))
+		return
+	}
+
+	random := cfg.Random.source()
+
+	var identities *identity.Map
+	if cfg.DonorLinks.FilePath != "" {
+		var err error
+		identities, err = identity.Load(cfg.DonorLinks.FilePath)
 		if err != nil {
-			log.Fatalf("malformed bid war data file: %v", err)
+			log.Fatal(err)
 		}
 	}
 
-	var dbRecorder db.Recorder
-	var seDonationPoller *streamelements.DonationPoller
-	var slDonationPoller *streamlabs.DonationPoller
-	var tipWatcher *tipfile.Watcher
-	var bidwarTallier *bidwar.Tallier
+	// The channels share these clients, and keep their donations apart.
+	var sheetsSrv *sheets.Service
+	var firestoreClient interface {
+		db.Recorder
+		InCollection(name string) db.Recorder
+	}
 	if *sheetsCredsPath != "" {
 		var err error
-		sheetsSrv, err := googlesheets.NewService(context.Background(), *sheetsCredsPath, *sheetsTokenPath)
+		sheetsSrv, err = googlesheets.NewService(context.Background(), *sheetsCredsPath, *sheetsTokenPath)
 		if err != nil {
 			log.Fatalf("error initializing Google Sheets API: %v", err)
 		}
-		donationTable := googlesheets.NewDonationTable(sheetsSrv, cfg.Spreadsheet.ID, cfg.Spreadsheet.SheetName)
-		dbRecorder = db.NewGoogleSheetsClient(donationTable)
-		bidwarTallier = bidwar.NewTallier(sheetsSrv, donationTable, cfg.Spreadsheet.ID, bidwars)
-		bidTotals, err := bidwarTallier.GetTotals()
-		if err != nil {
-			log.Fatalf("error reading current bid war totals: %v", err)
-		}
-		log.Printf("found %d bid war options in the database", len(bidTotals))
-		for _, bt := range bidTotals {
-			log.Printf("Current total for %q is %s", bt.Option.DisplayName, bt.Value)
-		}
 	} else if *firestoreCredsPath != "" {
 		var err error
-		dbRecorder, err = db.NewFirestoreClient(context.Background(), *firestoreCredsPath)
+		firestoreClient, err = db.NewFirestoreClient(context.Background(), *firestoreCredsPath)
 		if err != nil {
 			log.Fatalf("error connecting to Firestore: %v", err)
 		}
 	} else {
 		log.Fatal("no DB config specified; you must provide either Firestore or Google Sheets flags")
 	}
-	if *streamelementsCredsPath != "" {
+	providerClient, err := newHTTPClient(cfg.HTTP)
+	if err != nil {
+		log.Fatalf("invalid HTTP config: %v", err)
+	}
+	readProviders := true
+	if *readOnly {
+		log.Print("*** READ-ONLY: not reading or recording donations ***")
+		readProviders = false
+	} else if *role == roleRecord {
+		log.Print("donations come from the event queue; the donation providers are left to the ingest process")
+		readProviders = false
+	}
+	// A poller that restarts reads its provider's recent donations again, and
+	// so does the ingest process that feeds the queue, so the IDs that the
+	// recorder has seen have to outlive it.
+	if cfg.Dedup.FilePath == "" && (*role == roleRecord || (readProviders && pollsDonations(channelCfgs))) {
+		log.Fatal("the dedup file must be set when recording donations from StreamElements, Streamlabs, or the event queue")
+	}
+	var users *helix.Client
+	if *twitchAPICredsPath != "" {
 		var err error
-		seDonationPoller, err = streamelements.NewDonationPoller(context.Background(), *streamelementsCredsPath, *targetChannel)
+		users, err = helix.NewClient(*twitchAPICredsPath, providerClient)
 		if err != nil {
-			log.Printf("(non-fatal) error initializing StreamElements polling: %v", err)
+			log.Printf("(non-fatal) error initializing Twitch user ID lookups: %v", err)
 		}
-	} else {
-		log.Print("no StreamElements token provided")
 	}
-	if *streamlabsCredsPath != "" {
-		var err error
-		slDonationPoller, err = streamlabs.NewDonationPoller(context.Background(), *streamlabsCredsPath, *targetChannel)
+
+	var notePosters []notes.Poster
+	if cfg.Notes.FilePath != "" {
+		notePosters = append(notePosters, notes.NewFilePoster(cfg.Notes.FilePath))
+	}
+	if cfg.Notes.DiscordWebhookURL != "" {
+		notePosters = append(notePosters, notes.NewDiscordPoster(cfg.Notes.DiscordWebhookURL))
+	}
+	if cfg.Notes.TwitchChannel != "" && ircRepliesEnabled {
+		notePosters = append(notePosters, chatNotePoster{client: ircClient, channel: cfg.Notes.TwitchChannel})
+	}
+	var notePoster notes.Poster
+	if len(notePosters) > 0 {
+		notePoster = notes.Multi(notePosters...)
+	}
+
+	// Twitch limits what the account says across all of its channels, so the
+	// channels share one budget. Acks get their own, and the two together
+	// are still well under what Twitch allows a moderator.
+	chatLimiter := rate.NewLimiter(rate.Every(chatCooldown), chatBucketSize)
+	ackLimiter := rate.NewLimiter(rate.Every(chatCooldown), chatBucketSize)
+	whispers := newWhisperLimiter(cfg.Whispers.MaxRecipientsPerDay)
+	var whisperClient whisperer
+	if cfg.Whispers.Acknowledgements || cfg.Cooldown.Feedback == cooldownWhisper {
+		if *whisperCredsPath == "" {
+			log.Fatalf("--twitch_whisper_creds flag is required to whisper users")
+		}
+		c, err := helix.NewClient(*whisperCredsPath, providerClient)
 		if err != nil {
-			log.Printf("(non-fatal) error initializing Streamlabs polling: %v", err)
+			log.Fatalf("error initializing whispers: %v", err)
 		}
-	} else {
-		log.Print("no Streamlabs token provided")
+		whisperClient = c
 	}
-	if *tipLogPath != "" {
-		tipWatcher, err = tipfile.NewWatcher(*tipLogPath, *targetChannel)
+
+	// setUpChannel creates the bot for one channel, with its own bid wars and
+	// donation records. The first channel is the primary one.
+	setUpChannel := func(ch ChannelConfig, primary bool) *channel {
+		var bidwars bidwar.Collection
+		if ch.BidWarDataPath != "" {
+			var err error
+			bidwars, err = readBidWars(ch.BidWarDataPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		bidwars.ReasonOptions = cfg.Reasons.options()
+		bidwars.Random = random
+
+		var dbRecorder db.Recorder
+		var bidwarTallier *bidwar.Tallier
+		var scoreboard *googlesheets.Scoreboard
+		var bidwarSheet *googlesheets.BidWarSheet
+		var sheetsTable *googlesheets.DonationTable
+		// Mirrors the writes to sheetsTable. May be nil.
+		var changes *changefeed.Feed
+		if sheetsSrv != nil {
+			if cfg.Spreadsheet.BidWarSheetName != "" {
+				var err error
+				bidwarSheet = googlesheets.NewBidWarSheet(sheetsSrv, ch.SpreadsheetID, cfg.Spreadsheet.BidWarSheetName)
+				bidwars, err = readBidWarSheet(bidwarSheet)
+				if err != nil {
+					log.Fatal(err)
+				}
+				bidwars.ReasonOptions = cfg.Reasons.options()
+				bidwars.Random = random
+				log.Printf("read %d bid wars for #%s from the %q sheet", len(bidwars.Contests), ch.Name, cfg.Spreadsheet.BidWarSheetName)
+			}
+			donationTable := googlesheets.NewDonationTable(sheetsSrv, ch.SpreadsheetID, cfg.Spreadsheet.SheetName)
+			donationTable.SetReasonOptions(cfg.Reasons.options())
+			if cfg.Spreadsheet.RecordSource {
+				donationTable.RecordSource()
+			}
+			if cfg.Display.RoundSheetValues {
+				donationTable.RoundValues()
+			}
+			if cfg.Spreadsheet.RecordCampaign {
+				donationTable.RecordCampaign()
+			}
+			if cfg.Spreadsheet.RecordOwnerID {
+				donationTable.RecordOwnerID()
+			}
+			if cfg.Spreadsheet.RecordTime {
+				donationTable.RecordTime()
+			}
+			if cfg.Spreadsheet.RecordNet {
+				donationTable.RecordNet()
+			}
+			changeCfg := cfg.ChangeFeed
+			changeCfg.FilePath = channelPath(changeCfg.FilePath, ch.Name, primary)
+			changes = mirrorChanges(donationTable, changeCfg)
+			sheetsTable = donationTable
+			dbRecorder = db.NewGoogleSheetsClient(donationTable)
+			bidwarTallier = bidwar.NewTallier(sheetsSrv, donationTable, ch.SpreadsheetID, bidwars)
+			bidwarTallier.SetIdentities(identities)
+			bidwarTallier.SetTotalsTTL(time.Duration(cfg.Spreadsheet.TotalsTTLSeconds) * time.Second)
+			if users != nil {
+				bidwarTallier.SetUserIDs(users)
+			}
+			bidTotals, err := bidwarTallier.GetTotals()
+			if err != nil {
+				log.Fatalf("error reading current bid war totals for #%s: %v", ch.Name, err)
+			}
+			log.Printf("found %d bid war options for #%s in the database", len(bidTotals), ch.Name)
+			for _, bt := range bidTotals {
+				log.Printf("Current total for %q is %s", bt.Option.DisplayName, bt.Value)
+			}
+			if cfg.Scoreboard.SheetName != "" {
+				scoreboard = googlesheets.NewScoreboard(sheetsSrv, ch.SpreadsheetID, cfg.Scoreboard.SheetName)
+			}
+			if cfg.Backup.Directory != "" && primary {
+				dir, err := backup.NewDir(cfg.Backup.Directory, cfg.Backup.Keep)
+				if err != nil {
+					log.Fatal(err)
+				}
+				go runBackups(sheetsSrv, ch.SpreadsheetID, dir, time.Duration(cfg.Backup.IntervalMinutes)*time.Minute)
+			}
+		} else if ch.FirestoreCollection != "" {
+			dbRecorder = firestoreClient.InCollection(ch.FirestoreCollection)
+		} else {
+			dbRecorder = firestoreClient
+		}
+		dedup, err := db.NewDeduplicator(dbRecorder, channelPath(cfg.Dedup.FilePath, ch.Name, primary))
 		if err != nil {
-			log.Fatalf("error creating tip file watcher: %v", err)
+			log.Fatal(err)
+		}
+		dbRecorder = dedup
+
+		b := &bot{
+			ircClient:         ircClient,
+			ircRepliesEnabled: ircRepliesEnabled,
+			dbRecorder:        dbRecorder,
+			bidwars:           bidwars,
+			minimumDonation:   minimumDonation,
+			cheermotes:        cfg.Cheermotes.Prefixes,
+			chatLimiter:       chatLimiter,
+			ackLimiter:        ackLimiter,
+			notes:             notePoster,
+			bigDonation:       donation.CentsValue(cfg.Notes.BigDonationCents),
+			emotes:            cfg.Emotes,
+			pollers:           make(map[string]donationPoller),
+			status:            newBotStatus(time.Now()),
+			announceStatus:    cfg.Status.Announce,
+			channelPoints:     cfg.ChannelPoints,
+			readOnly:          *readOnly,
+			communityGifts:    make(map[string]time.Time),
+			pendingBids:       make(map[string]*bidPreference),
+			recentBids:        make(map[string]*recentBid),
+			recent:            &recentDonations{},
+			leaders:           make(map[string]string),
+		}
+		// Careful not to store a typed nil in the interface.
+		if bidwarTallier != nil {
+			b.bidwarTallier = bidwarTallier
+			bidwarTallier.OnCap(b.auditCap)
+		}
+		b.ackFailedDonations = cfg.DBFailure.Policy == dbFailureAcknowledge
+		b.bidwarDataPath = ch.BidWarDataPath
+		if bidwarSheet != nil {
+			// The sheet is the source of truth, so don't write the data file.
+			b.bidwarSheet = bidwarSheet
+			b.bidwarDataPath = ""
+		}
+		b.momentum = bidwar.NewMomentumTracker()
+		b.identities = identities
+		b.latency = report.NewLatencyTracker()
+		if cfg.Audit.FilePath != "" {
+			b.auditLog = audit.NewLog(channelPath(cfg.Audit.FilePath, ch.Name, primary))
+		}
+		b.donationTable = sheetsTable
+		b.recordCampaigns = sheetsTable != nil && cfg.Spreadsheet.RecordCampaign
+		if b.recordCampaigns {
+			b.campaignPath = channelPath(cfg.Spreadsheet.CampaignFilePath, ch.Name, primary)
+			if b.campaign, err = loadCampaign(b.campaignPath); err != nil {
+				log.Fatal(err)
+			} else if b.campaign != "" {
+				log.Printf("resuming campaign %q", b.campaign)
+			}
+		}
+		b.hooks = hooks.Registered()
+		if len(cfg.Hooks.Command) > 0 {
+			b.hooks = append(b.hooks, hooks.NewScript(cfg.Hooks.Command, time.Duration(cfg.Hooks.TimeoutSeconds)*time.Second))
+		}
+		b.chatQueues = newChatQueues(cfg.ChatDelay, b.sayUnlessQuiet)
+		// Careful not to store a typed nil in the interface.
+		if replier != nil {
+			b.replier = replier
+		}
+		b.bidChangeWindow = time.Duration(cfg.BidChange.WindowMinutes) * time.Minute
+		b.whispers = whispers
+		b.whisperer = whisperClient
+		b.whisperAcks = cfg.Whispers.Acknowledgements
+		if b.streaks, err = newStreakTracker(cfg.Streaks, channelPath(cfg.Streaks.FilePath, ch.Name, primary)); err != nil {
+			log.Fatal(err)
+		}
+		b.outage = newOutageTracker(cfg.Outage, ircClient.PongTimeout)
+		b.perms = newPermissions(cfg.Permissions)
+		b.dropped = newDroppedEvents(channelPath(cfg.Dropped.ReviewFilePath, ch.Name, primary))
+		b.notices = newNoticeTracker(cfg.Notices)
+		b.hypeTrain = newHypeTrainTracker(cfg.HypeTrain)
+		b.hypeTrainBonus = donation.CentsValue(cfg.HypeTrain.BonusCentsPerLevel)
+		b.hypeTrainBonusIsMoney = cfg.HypeTrain.BonusIsMoney
+		if len(cfg.Commands.CooldownSeconds) > 0 {
+			b.cooldowns = newCommandCooldowns(cfg.Commands)
+		}
+		if ch.TwitchAPICreds != "" {
+			predictionClient, err := predictions.NewClient(ch.TwitchAPICreds, providerClient)
+			if err != nil {
+				log.Printf("(non-fatal) error initializing Twitch Predictions for #%s: %v", ch.Name, err)
+			} else {
+				b.predictions = newPredictionTracker(predictionClient, cfg.Predictions)
+			}
+		}
+		b.users = users
+		if cfg.Results.Export {
+			if sheetsTable == nil {
+				log.Print("WARNING: results export needs the donation spreadsheet; it's disabled")
+			} else {
+				b.resultsSheetPrefix = cfg.Results.SheetNamePrefix
+			}
+		}
+		if cfg.DuplicateBids.WindowSeconds > 0 {
+			b.bidCache = newBidCache(time.Duration(cfg.DuplicateBids.WindowSeconds) * time.Second)
+		}
+		if cfg.BidLimit.Seconds > 0 {
+			b.bidLimiter = newBidLimiter(time.Duration(cfg.BidLimit.Seconds) * time.Second)
+		}
+		b.settled = newSettledDonors()
+		if cfg.Cooldown.Feedback != cooldownDrop {
+			b.deferred = newDeferredReplies(cfg.Cooldown.Feedback, time.Duration(cfg.Cooldown.FeedbackWindowSeconds)*time.Second)
+			go b.sendDeferredReplies()
+		}
+		if cfg.DBFailure.JournalPath != "" && !*readOnly {
+			b.journal = db.NewJournal(channelPath(cfg.DBFailure.JournalPath, ch.Name, primary))
+			go b.retryJournal(time.Duration(cfg.DBFailure.RetryIntervalSeconds) * time.Second)
+		}
+
+		if ircRepliesEnabled {
+			b.status.addIntegration("Twitch chat")
+		}
+		if sheetsTable != nil {
+			b.status.addIntegration("Google Sheets")
+		} else if firestoreClient != nil {
+			b.status.addIntegration("Firestore")
 		}
-		defer tipWatcher.Close()
+		if b.predictions != nil {
+			b.status.addIntegration("Twitch Predictions")
+		}
+		if *httpAddr != "" {
+			b.status.addIntegration("HTTP API")
+		}
+		return &channel{name: ch.Name, bot: b, scoreboard: scoreboard, changes: changes}
 	}
 
-	b := &bot{
-		ircClient:         ircClient,
-		ircRepliesEnabled: ircRepliesEnabled,
-		dbRecorder:        dbRecorder,
-		bidwars:           bidwars,
-		bidwarTallier:     bidwarTallier,
-		minimumDonation:   minimumDonation,
-		chatLimiter:       rate.NewLimiter(rate.Every(chatCooldown), chatBucketSize),
-		communityGifts:    make(map[string]time.Time),
-		pendingBids:       make(map[string]*bidPreference),
+	var channels []*channel
+	byName := make(map[string]*channel)
+	for i, ch := range channelCfgs {
+		c := setUpChannel(ch, i == 0)
+		channels = append(channels, c)
+		byName[c.name] = c
 	}
+	// The bot for the first channel, which also gets the event queue.
+	b := channels[0].bot
 
+	ingestChat := *role == roleAll && !*readOnly
 	ircClient.OnUserNoticeMessage(func(m twitch.UserNoticeMessage) {
-		if ev, ok := donation.ParseSubEvent(m); ok {
-			b.dispatchSubEvent(ev)
+		c, ok := byName[normalizeChannel(m.Channel)]
+		if !ok {
+			return
+		}
+		b := c.bot
+		b.users.Remember(m.User.Name, m.User.ID)
+		if ev, ok := donation.ParseSubEvent(m, rates); ok {
+			// In the record role, subs come from the event queue instead.
+			if ingestChat && !(coveredByEventSub(m.MsgID) && b.leaveToEventSub(ev)) {
+				b.dispatchSubEvent(ev)
+			}
+		} else if n, ok := donation.ParseNotice(m); ok {
+			b.dispatchNotice(n)
+			if ev, ok := donation.ParseRaidEvent(m); ok && ingestChat {
+				b.dispatchRaid(ev)
+			}
+		} else {
+			b.dropped.add(dropUnknownNotice, m.Raw)
 		}
 	})
 	ircClient.OnPrivateMessage(func(m twitch.PrivateMessage) {
+		c, ok := byName[normalizeChannel(m.Channel)]
+		if !ok {
+			// Probably the notes channel.
+			return
+		}
+		b := c.bot
+		b.users.Remember(m.User.Name, m.User.ID)
 		if ev, ok := donation.ParseBitsEvent(m); ok {
-			b.dispatchBitsEvent(ev)
-		} else if firstTokenIs(strings.ToLower(m.Message), bidCommand) {
-			b.dispatchBidCommand(m)
+			if ingestChat && !b.leaveToEventSub(ev) {
+				b.dispatchBitsEvent(ev)
+			}
+		} else if _, ok := b.channelPointReward(donation.RewardID(m)); !ok {
+			// Redemptions of the configured rewards come from EventSub.
+			b.dispatchCommand(m)
 		}
 	})
-	ircClient.Join(*targetChannel)
-
-	if seDonationPoller != nil {
-		seDonationPoller.OnDonation(func(ev donation.Event) {
-			b.dispatchMoneyDonation(ev)
+	if *role == roleRecord {
+		b.status.addIntegration("event queue")
+	}
+	if b.outage != nil {
+		ircClient.OnPingSent(func() {
+			for _, c := range channels {
+				c.bot.outage.pinged(time.Now())
+			}
+		})
+		ircClient.OnPongMessage(func(twitch.PongMessage) {
+			for _, c := range channels {
+				c.bot.outage.ponged()
+			}
+		})
+		ircClient.OnReconnectMessage(func(twitch.ReconnectMessage) {
+			for _, c := range channels {
+				c.bot.outage.disconnect()
+			}
 		})
-		if err := seDonationPoller.Start(); err != nil {
-			log.Fatalf("StreamElements polling error: %v", err)
+	}
+	ircClient.OnConnect(func() {
+		for _, c := range channels {
+			if c.bot.outage != nil {
+				c.bot.catchUp(c.name)
+			}
+			c.bot.announceLive(c.name)
 		}
+	})
+	for _, c := range channels {
+		ircClient.Join(c.name)
 	}
-	if slDonationPoller != nil {
-		slDonationPoller.OnDonation(func(ev donation.Event) {
-			b.dispatchMoneyDonation(ev)
-		})
-		if err := slDonationPoller.Start(); err != nil {
-			log.Fatalf("Streamlabs polling error: %v", err)
+	if cfg.Notes.TwitchChannel != "" {
+		ircClient.Join(cfg.Notes.TwitchChannel)
+	}
+
+	if readProviders {
+		for i, c := range channels {
+			p := newProviders(channelCfgs[i].providerFlags(), c.name, i == 0, cfg, providerClient)
+			if p.tipWatcher != nil {
+				defer p.tipWatcher.Close()
+			}
+			c.bot.startProviders(p, cfg)
 		}
 	}
+	if *role == roleRecord {
+		reader, err := eventqueue.NewReader(*queuePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go reader.Watch(queuePollInterval, b.dispatchQueued)
+	}
 
-	if tipWatcher != nil {
-		go func() {
-			for {
-				select {
-				case ev := <-tipWatcher.C:
-					b.dispatchMoneyDonation(ev)
+	// Reload the bid wars on SIGHUP, just like the !reloadbids command.
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			for _, c := range channels {
+				if _, err := c.bot.reloadBidWars(); err != nil {
+					log.Printf("ERROR reloading bid wars for #%s: %v", c.name, err)
 				}
 			}
+		}
+	}()
+
+	// Service managers stop the bot with SIGTERM, so shut down cleanly, just
+	// like the shutdown control command.
+	controlCmds := make(chan control.Command, 10)
+	stops := make(chan os.Signal, 1)
+	signal.Notify(stops, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-stops
+		controlCmds <- control.Shutdown
+	}()
+	if *controlPath != "" {
+		watcher := control.Watch(*controlPath, 2*time.Second)
+		go func() {
+			for cmd := range watcher.C {
+				controlCmds <- cmd
+			}
+		}()
+	}
+	go handleControlCommands(channels, controlCmds)
+
+	if *httpAddr != "" {
+		adminToken := cfg.APIAuth.AdminToken
+		if *readOnly {
+			// Nothing the admin endpoints do is allowed.
+			adminToken = ""
+		}
+		if cfg.ChangeFeed.Stream && adminToken == "" {
+			log.Printf("WARNING: /changes needs the admin token, so the change feed won't be streamed")
+		}
+		handler := channelAPIs(channels, func(c *channel) *httpapi.Server {
+			srv := httpapi.NewServer(c.bot.collection)
+			srv.SetTokens(cfg.APIAuth.ReadToken, adminToken)
+			if c.changes != nil && cfg.ChangeFeed.Stream {
+				srv.SetChanges(c.changes.Subscribe)
+			}
+			if c.bot.bidwarTallier != nil {
+				srv.SetTotals(c.bot.bidwarTallier.TotalsForContest)
+			}
+			if c.bot.donationTable != nil {
+				srv.SetGrandTotal(c.bot.grandTotal)
+			}
+			srv.SetTimers(c.bot.apiTimers)
+			return srv
+		})
+		go func() {
+			log.Printf("serving HTTP on %s", *httpAddr)
+			log.Fatalf("HTTP server error: %v", http.ListenAndServe(*httpAddr, handler))
 		}()
 	}
 
+	for _, c := range channels {
+		if !*readOnly {
+			go c.bot.runCountdowns(c.name, cfg.Countdown.warnings())
+		}
+		if c.bot.predictions != nil && !*readOnly {
+			go c.bot.runPredictions()
+		}
+		if c.scoreboard != nil && !*readOnly {
+			go publishScoreboard(c.bot, c.scoreboard, time.Duration(cfg.Scoreboard.IntervalMinutes)*time.Minute)
+		}
+	}
+
 	if !*prod {
-		go doLocalTest(b, *targetChannel, ircClient, bidwarTallier)
+		var tallier *bidwar.Tallier
+		if t, ok := b.bidwarTallier.(*bidwar.Tallier); ok {
+			tallier = t
+		}
+		go doLocalTest(b, targetChannel, ircClient, tallier)
 	}
 
 	log.Print("connecting to IRC...")
-	if err := ircClient.Connect(); err != nil {
-		panic(err)
+	for {
+		err := ircClient.Connect()
+		if err == nil || err == twitch.ErrClientDisconnected {
+			break
+		}
+		if b.outage == nil || err == twitch.ErrLoginAuthenticationFailed {
+			panic(err)
+		}
+		// Keep polling for donations while we wait, and catch up in chat
+		// once we're back.
+		for _, c := range channels {
+			c.bot.outage.disconnect()
+		}
+		log.Printf("ERROR connecting to IRC (retrying in %v): %v", ircRetryDelay, err)
+		time.Sleep(ircRetryDelay)
 	}
+	log.Print("shut down")
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestHelpText(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		name    string
+		role    role
+		want    []string
+		notWant []string
+	}{
+		{
+			desc:    "viewer list",
+			want:    []string{"!bid", "!mybids", "!help"},
+			notWant: []string{"!refresh", "!lock"},
+		},
+		{
+			desc: "mod list",
+			role: moderatorRole,
+			want: []string{"!bid", "!refresh", "!lock", "!help"},
+		},
+		{
+			desc: "one command",
+			name: "standings",
+			want: []string{"!standings <contest> - "},
+		},
+		{
+			desc: "alias",
+			name: "!commands",
+			want: []string{"!help [command] - "},
+		},
+		{
+			desc: "mod command for viewer",
+			name: "!refresh",
+			want: []string{"I don't know a command called !refresh."},
+		},
+	} {
+		got := permissions{}.helpText(tc.name, tc.role)
+		if len(got) > maxChatLength {
+			t.Errorf("%s: help text is %d characters long", tc.desc, len(got))
+		}
+		for _, w := range tc.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s: got %q, want it to contain %q", tc.desc, got, w)
+			}
+		}
+		for _, w := range tc.notWant {
+			if strings.Contains(got, w) {
+				t.Errorf("%s: got %q, want it not to contain %q", tc.desc, got, w)
+			}
+		}
+	}
+}
+
+func TestChatCommandsAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, c := range chatCommands() {
+		for _, name := range append([]string{c.name}, c.aliases...) {
+			if seen[name] {
+				t.Errorf("command %s is registered twice", name)
+			}
+			seen[name] = true
+		}
+		if c.help == "" {
+			t.Errorf("command %s has no help text", c.name)
+		}
+	}
+}
+
+func TestLateBidReasonWaits(t *testing.T) {
+	for _, tc := range []struct {
+		desc       string
+		grace      int
+		wantReason bool
+	}{
+		{"in the grace period", 60, false},
+		{"no grace period", 0, true},
+	} {
+		b := newLoadTestBot(t, newFakeBackend(0))
+		con := &b.bidwars.Contests[0]
+		con.Closed = true
+		con.ClosesAt = time.Now().Add(-10 * time.Second)
+		con.LateBidGraceSeconds = tc.grace
+		ev := donation.Event{Owner: "aerionblue", Cash: donation.CentsValue(500), Message: "nbc please"}
+		got := b.getChoice(ev, bidwar.FromDonationMessage, false)
+		if got.ClosedOption.ShortCode != "NBC" {
+			t.Errorf("%s: got closed option %q, want NBC", tc.desc, got.ClosedOption.ShortCode)
+		}
+		if hasReason := got.Reason != ""; hasReason != tc.wantReason {
+			t.Errorf("%s: got reason %q, want a reason: %v", tc.desc, got.Reason, tc.wantReason)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+const botStatusCommand = "!botstatus"
+
+// How long to wait after announcing a shutdown before disconnecting.
+const shutdownAnnouncementDelay = 2 * time.Second
+
+// botStatus keeps track of what !botstatus reports: how long the bot has been
+// up, what it's connected to, and when it last saw a donation from each
+// source. A nil botStatus tracks nothing.
+type botStatus struct {
+	started time.Time
+	// The integrations that were set up at startup (e.g., "StreamElements"),
+	// in order. Only written before the bot connects to chat.
+	integrations []string
+
+	mu sync.Mutex
+	// When the bot last received a donation from each source.
+	lastSeen map[donation.Source]time.Time
+	// Whether the bot has announced that it's live.
+	announced bool
+}
+
+func newBotStatus(started time.Time) *botStatus {
+	return &botStatus{started: started, lastSeen: make(map[donation.Source]time.Time)}
+}
+
+// addIntegration lists something that the bot is connected to.
+func (s *botStatus) addIntegration(name string) {
+	s.integrations = append(s.integrations, name)
+}
+
+// sawDonation records that a donation from the given source just arrived.
+func (s *botStatus) sawDonation(src donation.Source, now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSeen[src] = now
+}
+
+// firstConnect returns true the first time it's called, and false after that.
+func (s *botStatus) firstConnect() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	first := !s.announced
+	s.announced = true
+	return first
+}
+
+// describe summarizes the status. lastWrite is when the donation table was
+// last written to; hasTable is whether there is a donation table at all.
+func (s *botStatus) describe(now time.Time, lastWrite time.Time, hasTable bool) string {
+	parts := []string{fmt.Sprintf("Up for %s.", describeDuration(now.Sub(s.started)))}
+	if len(s.integrations) > 0 {
+		parts = append(parts, fmt.Sprintf("Connected: %s.", strings.Join(s.integrations, ", ")))
+	}
+	if hasTable {
+		if lastWrite.IsZero() {
+			parts = append(parts, "No sheet writes yet.")
+		} else {
+			parts = append(parts, fmt.Sprintf("Last sheet write %s ago.", describeDuration(now.Sub(lastWrite))))
+		}
+	}
+	s.mu.Lock()
+	var seen []string
+	for src, t := range s.lastSeen {
+		seen = append(seen, fmt.Sprintf("%s %s ago", src.DisplayName(), describeDuration(now.Sub(t))))
+	}
+	s.mu.Unlock()
+	if len(seen) == 0 {
+		parts = append(parts, "No donations yet.")
+	} else {
+		sort.Strings(seen)
+		parts = append(parts, fmt.Sprintf("Last donations: %s.", strings.Join(seen, ", ")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeDuration formats d to the minute (or to the second, if it's under
+// a minute), like "2h05m" or "40s".
+func describeDuration(d time.Duration) string {
+	secs := int(d / time.Second)
+	if secs < 0 {
+		secs = 0
+	}
+	h, m := secs/3600, secs/60%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%ds", secs)
+}
+
+func (b *bot) dispatchBotStatusCommand(m twitch.PrivateMessage) {
+	var lastWrite time.Time
+	if b.donationTable != nil {
+		lastWrite = b.donationTable.LastWrite()
+	}
+	b.say(m.Channel, fmt.Sprintf("@%s: %s", m.User.Name, b.status.describe(time.Now(), lastWrite, b.donationTable != nil)))
+}
+
+// announceLive tells chat that donation tracking is live, the first time the
+// bot connects, if that's enabled.
+func (b *bot) announceLive(channel string) {
+	if !b.announceStatus || !b.status.firstConnect() {
+		return
+	}
+	if b.readOnly {
+		b.say(channel, "I'm online! The event is over, but you can still look up the final results.")
+		return
+	}
+	b.say(channel, "I'm online! Donations and bids are being tracked.")
+}
+
+// announceShutdown tells chat that the bot is going offline, if that's
+// enabled.
+func (b *bot) announceShutdown(channel string) {
+	if b.announceStatus && !b.readOnly {
+		b.say(channel, "I'm going offline, so donations and bids aren't being tracked until I'm back.")
+		// Give the message a chance to go out before we disconnect.
+		time.Sleep(shutdownAnnouncementDelay)
+	}
+}
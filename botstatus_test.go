@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestBotStatusDescribe(t *testing.T) {
+	started := time.Date(2024, 7, 31, 8, 0, 0, 0, time.UTC)
+	now := started.Add(2*time.Hour + 5*time.Minute)
+	s := newBotStatus(started)
+	s.addIntegration("Twitch chat")
+	s.addIntegration("StreamElements")
+
+	if got, want := s.describe(now, time.Time{}, true), "Up for 2h05m. Connected: Twitch chat, StreamElements. No sheet writes yet. No donations yet."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	s.sawDonation(donation.SourceTwitchBits, now.Add(-40*time.Second))
+	s.sawDonation(donation.SourceStreamElements, now.Add(-90*time.Minute))
+	s.sawDonation(donation.SourceStreamElements, now.Add(-3*time.Minute))
+	want := "Up for 2h05m. Connected: Twitch chat, StreamElements. Last sheet write 1m ago. Last donations: StreamElements 3m ago, Twitch bits 40s ago."
+	if got := s.describe(now, now.Add(-time.Minute), true); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var nilStatus *botStatus
+	nilStatus.sawDonation(donation.SourceTwitchBits, now)
+}
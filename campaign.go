@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+)
+
+const campaignCommand = "!campaign"
+
+// dispatchCampaignCommand starts or ends a campaign (e.g., "Friday night
+// block"). Donations recorded while a campaign is running are tagged with it,
+// so that !sources and !report can be filtered by campaign.
+func (b *bot) dispatchCampaignCommand(m twitch.PrivateMessage) {
+	args := commandArgs(m.Message)
+	verb := strings.ToLower(strings.SplitN(args, " ", 2)[0])
+	name := strings.TrimSpace(commandArgs(args))
+	switch {
+	case args == "":
+		if c := b.currentCampaign(); c != "" {
+			b.say(m.Channel, fmt.Sprintf("@%s: The current campaign is %s.", m.User.Name, c))
+		} else {
+			b.say(m.Channel, fmt.Sprintf("@%s: No campaign is running.", m.User.Name))
+		}
+	case !b.recordCampaigns:
+		b.say(m.Channel, fmt.Sprintf("@%s: Campaigns aren't recorded in the donation table.", m.User.Name))
+	case verb == "start" && name != "":
+		prev := b.setCampaign(name)
+		log.Printf("%s started campaign %q (was %q)", m.User.Name, name, prev)
+		msg := fmt.Sprintf("@%s: Started the %s campaign.", m.User.Name, name)
+		if prev != "" {
+			msg = fmt.Sprintf("@%s: Ended the %s campaign and started %s.", m.User.Name, prev, name)
+		}
+		b.say(m.Channel, msg)
+	case verb == "end":
+		prev := b.setCampaign("")
+		if prev == "" {
+			b.say(m.Channel, fmt.Sprintf("@%s: No campaign is running.", m.User.Name))
+			return
+		}
+		log.Printf("%s ended campaign %q", m.User.Name, prev)
+		b.say(m.Channel, fmt.Sprintf("@%s: Ended the %s campaign.", m.User.Name, prev))
+	default:
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s start <name>|end", m.User.Name, campaignCommand))
+	}
+}
+
+// currentCampaign returns the campaign that's running, or "" if none is.
+func (b *bot) currentCampaign() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.campaign
+}
+
+// setCampaign sets the running campaign ("" for none) and returns the
+// previous one. The campaign is saved to the campaign file, if there is one.
+func (b *bot) setCampaign(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	prev := b.campaign
+	b.campaign = name
+	if err := saveCampaign(b.campaignPath, name); err != nil {
+		log.Printf("ERROR saving the campaign: %v", err)
+	}
+	return prev
+}
+
+// loadCampaign reads the running campaign from a file, or returns "" if the
+// path is empty or the file doesn't exist.
+func loadCampaign(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("could not read campaign file: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// saveCampaign replaces the contents of the campaign file, if there is one,
+// with the running campaign ("" for none).
+func saveCampaign(path string, name string) error {
+	if path == "" {
+		return nil
+	}
+	// Write to a temp file first, so we don't lose the campaign if we crash.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("could not write campaign file: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
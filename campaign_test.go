@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCampaignSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "campaign")
+	b := &bot{campaignPath: path}
+	b.setCampaign("Friday night block")
+
+	got, err := loadCampaign(path)
+	if err != nil {
+		t.Fatalf("loadCampaign: %v", err)
+	}
+	if got != "Friday night block" {
+		t.Errorf("got campaign %q after a restart, want %q", got, "Friday night block")
+	}
+
+	b.setCampaign("")
+	if got, err := loadCampaign(path); err != nil || got != "" {
+		t.Errorf("got (%q, %v) after the campaign ended, want no campaign", got, err)
+	}
+	if got, err := loadCampaign(filepath.Join(t.TempDir(), "missing")); err != nil || got != "" {
+		t.Errorf("got (%q, %v) with no campaign file, want no campaign", got, err)
+	}
+}
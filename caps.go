@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aerionblue/pizzafest/audit"
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// capNote enforces the donor cap of opt's contest after a donation was put
+// towards opt, and tells the donor how much of it counted. Returns "" if all
+// of it counted.
+func (b *bot) capNote(ev donation.Event, opt bidwar.Option) string {
+	if opt.IsZero() || b.bidwarTallier == nil {
+		return ""
+	}
+	if con, ok := b.collection().ContestForOption(opt); !ok || con.DonorCapCents <= 0 {
+		return ""
+	}
+	over, err := b.bidwarTallier.ApplyCap(ev.Owner, opt)
+	if err != nil {
+		log.Printf("ERROR applying donor cap for %s: %v", ev.Owner, err)
+		return ""
+	}
+	return b.describeOverCap(opt, ev.Value(), over)
+}
+
+// auditCap records the rows that a donor cap flagged.
+func (b *bot) auditCap(donor string, con bidwar.Contest, rows int, over donation.CentsValue) {
+	b.audit(audit.Entry{Action: audit.OverCap, Donor: donor, Contest: con.Name, Count: rows, Cents: over.Cents(), By: "cap"})
+}
+
+// overCapNote tells a donor how much of a !bid counted, if some of it went
+// over the contest's donor cap.
+func (b *bot) overCapNote(stats bidwar.UpdateStats) string {
+	return b.describeOverCap(stats.Choice.Option, stats.TotalValue, stats.OverCap)
+}
+
+func (b *bot) describeOverCap(opt bidwar.Option, value donation.CentsValue, over donation.CentsValue) string {
+	if over <= 0 {
+		return ""
+	}
+	con, _ := b.collection().ContestForOption(opt)
+	limit := donation.CentsValue(con.DonorCapCents)
+	if counted := value - over; counted > 0 {
+		return fmt.Sprintf(" Only %s of it counts towards %s; the cap is %s points per person.", counted, con.Name, limit)
+	}
+	return fmt.Sprintf(" It doesn't count towards %s, since you've reached the cap of %s points per person.", con.Name, limit)
+}
@@ -0,0 +1,97 @@
+// Package changefeed mirrors the bot's writes to the donation table as a
+// stream of JSON lines, so that other tools can follow along without access
+// to the Sheets API. The stream is appended to a file, and can also be
+// followed live over HTTP.
+package changefeed
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+// How many lines a live subscriber can fall behind before it's dropped.
+const subscriberBuffer = 64
+
+// Entry is one line of the feed.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// "append" for new rows, or "update" for edits to existing rows.
+	Op string `json:"op"`
+	// The A1 range that was written, if known.
+	Range string `json:"range,omitempty"`
+	// The values written. In an update, null cells were left unchanged.
+	Rows [][]interface{} `json:"rows"`
+}
+
+// Feed is an append-only change feed. A nil *Feed records nothing.
+type Feed struct {
+	// The feed file. If empty, changes are only sent to live subscribers.
+	path string
+	now  func() time.Time
+
+	mu   sync.Mutex
+	subs map[chan []byte]bool
+}
+
+// NewFeed creates a Feed that appends to the given file.
+func NewFeed(path string) *Feed {
+	return &Feed{path: path, now: time.Now, subs: make(map[chan []byte]bool)}
+}
+
+// Record adds a write to the feed.
+func (f *Feed) Record(w googlesheets.Write) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	line, err := json.Marshal(Entry{Time: f.now(), Op: w.Op, Range: w.Range, Rows: w.Rows})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	for ch := range f.subs {
+		select {
+		case ch <- line:
+		default:
+			// Don't let a slow reader hold up the bot.
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+	if f.path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open change feed: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("could not write to change feed: %v", err)
+	}
+	return nil
+}
+
+// Subscribe returns a channel that receives each new line of the feed, and a
+// func to call when done with it. The channel is closed if the subscriber
+// falls too far behind.
+func (f *Feed) Subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBuffer)
+	f.mu.Lock()
+	f.subs[ch] = true
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.subs[ch] {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
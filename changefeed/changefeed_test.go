@@ -0,0 +1,72 @@
+package changefeed
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.jsonl")
+	f := NewFeed(path)
+	f.now = func() time.Time { return time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC) }
+	ch, done := f.Subscribe()
+
+	writes := []googlesheets.Write{
+		{Op: "append", Range: "Donations!A10:E10", Rows: [][]interface{}{{"aerionblue", "$5.00 donation", "5.00", "DMC3", ""}}},
+		{Op: "update", Range: "Donations!A:E", Rows: [][]interface{}{{nil, nil, nil, "DMC2"}}},
+	}
+	for _, w := range writes {
+		if err := f.Record(w); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	want := `{"time":"2022-07-01T20:00:00Z","op":"append","range":"Donations!A10:E10","rows":[["aerionblue","$5.00 donation","5.00","DMC3",""]]}
+{"time":"2022-07-01T20:00:00Z","op":"update","range":"Donations!A:E","rows":[[null,null,null,"DMC2"]]}
+`
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the feed: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("feed file: got\n%s\nwant\n%s", got, want)
+	}
+	var live string
+	for i := 0; i < len(writes); i++ {
+		live += string(<-ch)
+	}
+	if live != want {
+		t.Errorf("subscriber: got\n%s\nwant\n%s", live, want)
+	}
+	done()
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed")
+	}
+	done()
+
+	var nilFeed *Feed
+	if err := nilFeed.Record(writes[0]); err != nil {
+		t.Errorf("nil Feed: %v", err)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	f := NewFeed("")
+	ch, done := f.Subscribe()
+	defer done()
+	for i := 0; i < subscriberBuffer+1; i++ {
+		if err := f.Record(googlesheets.Write{Op: "append"}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("got %d lines before the channel was closed, want %d", n, subscriberBuffer)
+	}
+}
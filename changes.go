@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aerionblue/pizzafest/changefeed"
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+// mirrorChanges sends every write to the donation table to a change feed, if
+// one is configured. Returns nil if there's no feed.
+func mirrorChanges(table *googlesheets.DonationTable, cfg ChangeFeedConfig) *changefeed.Feed {
+	if cfg.FilePath == "" && !cfg.Stream {
+		return nil
+	}
+	feed := changefeed.NewFeed(cfg.FilePath)
+	table.OnWrite(func(w googlesheets.Write) {
+		if err := feed.Record(w); err != nil {
+			log.Printf("ERROR writing change feed: %v", err)
+		}
+	})
+	return feed
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aerionblue/pizzafest/changefeed"
+	"github.com/aerionblue/pizzafest/googlesheets"
+	"github.com/aerionblue/pizzafest/httpapi"
+)
+
+// The channel to join if none are given.
+const defaultChannel = "aerionblue"
+
+// channelFlag is a flag that can be repeated to join several channels.
+type channelFlag []string
+
+func (f *channelFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *channelFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// channel is one of the channels that the bot is in. Each has its own bot,
+// with its own bid wars and donation records.
+type channel struct {
+	name string
+	bot  *bot
+	// Where the standings are published. May be nil.
+	scoreboard *googlesheets.Scoreboard
+	// Mirrors the writes to the donation table. May be nil.
+	changes *changefeed.Feed
+}
+
+// normalizeChannel lowercases a channel name and strips any "#", to match
+// the channel names in IRC messages.
+func normalizeChannel(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+}
+
+// resolveChannels works out which channels to join and where each one's bid
+// wars and donations are kept. The channels come from the --channel flags
+// (flagged), or else from the config. The first channel falls back on the
+// Spreadsheet config, and on the flags in fromFlags for everything else.
+// sheets says whether donations are recorded in Google Sheets rather than
+// Firestore.
+func resolveChannels(flagged []string, cfg BotConfig, fromFlags ChannelConfig, sheets bool) ([]ChannelConfig, error) {
+	configured := make(map[string]ChannelConfig)
+	for _, c := range cfg.Channels {
+		c.Name = normalizeChannel(c.Name)
+		if c.Name == "" {
+			return nil, errors.New("every channel in the config needs a name")
+		}
+		if _, ok := configured[c.Name]; ok {
+			return nil, fmt.Errorf("channel %q is in the config more than once", c.Name)
+		}
+		configured[c.Name] = c
+	}
+	var names []string
+	if len(flagged) > 0 {
+		names = flagged
+	} else {
+		for _, c := range cfg.Channels {
+			names = append(names, c.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{defaultChannel}
+	}
+
+	var channels []ChannelConfig
+	seen := make(map[string]bool)
+	spreadsheets := make(map[string]string)
+	collections := make(map[string]string)
+	for i, name := range names {
+		name = normalizeChannel(name)
+		if name == "" {
+			return nil, errors.New("channel names can't be empty")
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("channel %q is listed more than once", name)
+		}
+		seen[name] = true
+		c := configured[name]
+		c.Name = name
+		if i == 0 {
+			c = c.withDefaults(fromFlags)
+			if c.SpreadsheetID == "" {
+				c.SpreadsheetID = cfg.Spreadsheet.ID
+			}
+		}
+		// Channels that share a place to record donations would mix up
+		// their totals.
+		if sheets {
+			if c.SpreadsheetID == "" {
+				return nil, fmt.Errorf("channel %q needs its own spreadsheetId", name)
+			}
+			if other, ok := spreadsheets[c.SpreadsheetID]; ok {
+				return nil, fmt.Errorf("channels %q and %q use the same spreadsheet", other, name)
+			}
+			spreadsheets[c.SpreadsheetID] = name
+		} else if i > 0 {
+			if c.FirestoreCollection == "" {
+				return nil, fmt.Errorf("channel %q needs its own firestoreCollection", name)
+			}
+			if other, ok := collections[c.FirestoreCollection]; ok {
+				return nil, fmt.Errorf("channels %q and %q use the same Firestore collection", other, name)
+			}
+			collections[c.FirestoreCollection] = name
+		}
+		channels = append(channels, c)
+	}
+	return channels, nil
+}
+
+// withDefaults fills in the channel's unset files from d.
+func (c ChannelConfig) withDefaults(d ChannelConfig) ChannelConfig {
+	for _, f := range []struct{ field, def *string }{
+		{&c.BidWarDataPath, &d.BidWarDataPath},
+		{&c.StreamElementsCreds, &d.StreamElementsCreds},
+		{&c.StreamlabsCreds, &d.StreamlabsCreds},
+		{&c.TipLogPath, &d.TipLogPath},
+		{&c.EventSubCreds, &d.EventSubCreds},
+		{&c.TwitchAPICreds, &d.TwitchAPICreds},
+	} {
+		if *f.field == "" {
+			*f.field = *f.def
+		}
+	}
+	return c
+}
+
+// providerFlags returns the files that set up the channel's donation
+// providers.
+func (c ChannelConfig) providerFlags() providerFlags {
+	return providerFlags{
+		streamElementsCreds: c.StreamElementsCreds,
+		streamlabsCreds:     c.StreamlabsCreds,
+		tipLogPath:          c.TipLogPath,
+		eventSubCreds:       c.EventSubCreds,
+	}
+}
+
+// hasTipSource reports whether the channel gets tips from anywhere.
+func (c ChannelConfig) hasTipSource() bool {
+	return c.StreamElementsCreds != "" || c.StreamlabsCreds != "" || c.TipLogPath != ""
+}
+
+// pollsDonations reports whether any of the channels polls StreamElements or
+// Streamlabs.
+func pollsDonations(channels []ChannelConfig) bool {
+	for _, c := range channels {
+		if c.StreamElementsCreds != "" || c.StreamlabsCreds != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDonationSources makes sure that no two channels read the same
+// donation source, and that, if any channel gets tips, every channel gets
+// its own, since a channel without a source would never hear about its tips.
+func checkDonationSources(channels []ChannelConfig) error {
+	used := make(map[string]string)
+	withTips := ""
+	for _, c := range channels {
+		for _, path := range []string{c.StreamElementsCreds, c.StreamlabsCreds, c.TipLogPath, c.EventSubCreds} {
+			if path == "" {
+				continue
+			}
+			if other, ok := used[path]; ok {
+				return fmt.Errorf("channels %q and %q both read %s, so they'd record the same donations", other, c.Name, path)
+			}
+			used[path] = c.Name
+		}
+		if c.hasTipSource() && withTips == "" {
+			withTips = c.Name
+		}
+	}
+	if withTips == "" {
+		return nil
+	}
+	for _, c := range channels {
+		if !c.hasTipSource() {
+			return fmt.Errorf("channel %q has no streamElementsCreds, streamlabsCreds, or tipLogPath, so it would never get tips (%q does)", c.Name, withTips)
+		}
+	}
+	return nil
+}
+
+// channelAPIs serves each channel's HTTP API under /channels/<channel>/,
+// and the first channel's at the root as well.
+func channelAPIs(channels []*channel, newServer func(*channel) *httpapi.Server) http.Handler {
+	mux := http.NewServeMux()
+	for i, c := range channels {
+		srv := newServer(c)
+		if i == 0 {
+			mux.Handle("/", srv)
+		}
+		prefix := "/channels/" + c.name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, srv))
+	}
+	return mux
+}
+
+// channelPath gives each channel other than the first its own copy of a file
+// that the bot writes, so that the channels' records don't mix.
+func channelPath(path string, name string, primary bool) string {
+	if path == "" || primary {
+		return path
+	}
+	return path + "." + name
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/httpapi"
+)
+
+func TestResolveChannels(t *testing.T) {
+	cfg := BotConfig{
+		Spreadsheet: SpreadsheetConfig{ID: "main-sheet"},
+		Channels: []ChannelConfig{
+			{Name: "UsedPizza", BidWarDataPath: "pizza.json", SpreadsheetID: "pizza-sheet", FirestoreCollection: "pizza"},
+			{Name: "#aerionblue"},
+		},
+	}
+	for _, tc := range []struct {
+		desc    string
+		flagged []string
+		cfg     BotConfig
+		sheets  bool
+		want    []ChannelConfig
+		wantErr bool
+	}{
+		{
+			desc:   "default",
+			sheets: true,
+			cfg:    BotConfig{Spreadsheet: SpreadsheetConfig{ID: "main-sheet"}},
+			want:   []ChannelConfig{{Name: "aerionblue", BidWarDataPath: "bids.json", SpreadsheetID: "main-sheet"}},
+		},
+		{
+			desc:    "from flags",
+			flagged: []string{"#AerionBlue", "usedpizza"},
+			cfg:     cfg,
+			sheets:  true,
+			want: []ChannelConfig{
+				{Name: "aerionblue", BidWarDataPath: "bids.json", SpreadsheetID: "main-sheet"},
+				{Name: "usedpizza", BidWarDataPath: "pizza.json", SpreadsheetID: "pizza-sheet", FirestoreCollection: "pizza"},
+			},
+		},
+		{
+			desc: "from config",
+			cfg: BotConfig{
+				Spreadsheet: SpreadsheetConfig{ID: "main-sheet"},
+				Channels:    []ChannelConfig{{Name: "UsedPizza"}, {Name: "aerionblue", SpreadsheetID: "blue-sheet"}},
+			},
+			sheets: true,
+			want: []ChannelConfig{
+				{Name: "usedpizza", BidWarDataPath: "bids.json", SpreadsheetID: "main-sheet"},
+				{Name: "aerionblue", SpreadsheetID: "blue-sheet"},
+			},
+		},
+		{
+			desc:    "second channel without a Firestore collection",
+			cfg:     cfg,
+			sheets:  false,
+			wantErr: true,
+		},
+		{
+			desc:    "second channel without a spreadsheet",
+			flagged: []string{"usedpizza", "aerionblue"},
+			cfg:     cfg,
+			sheets:  true,
+			wantErr: true,
+		},
+		{
+			desc:    "shared spreadsheet",
+			flagged: []string{"aerionblue", "usedpizza"},
+			cfg: BotConfig{
+				Spreadsheet: SpreadsheetConfig{ID: "main-sheet"},
+				Channels:    []ChannelConfig{{Name: "usedpizza", SpreadsheetID: "main-sheet"}},
+			},
+			sheets:  true,
+			wantErr: true,
+		},
+		{
+			desc:    "Firestore",
+			flagged: []string{"aerionblue", "usedpizza"},
+			cfg:     cfg,
+			sheets:  false,
+			want: []ChannelConfig{
+				{Name: "aerionblue", BidWarDataPath: "bids.json", SpreadsheetID: "main-sheet"},
+				{Name: "usedpizza", BidWarDataPath: "pizza.json", SpreadsheetID: "pizza-sheet", FirestoreCollection: "pizza"},
+			},
+		},
+		{
+			desc:    "repeated",
+			flagged: []string{"aerionblue", "AerionBlue"},
+			cfg:     cfg,
+			sheets:  true,
+			wantErr: true,
+		},
+	} {
+		got, err := resolveChannels(tc.flagged, tc.cfg, ChannelConfig{BidWarDataPath: "bids.json"}, tc.sheets)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error: %v", tc.desc, err, tc.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestResolveChannels_ProviderFlags(t *testing.T) {
+	cfg := BotConfig{
+		Spreadsheet: SpreadsheetConfig{ID: "main-sheet"},
+		Channels:    []ChannelConfig{{Name: "usedpizza", SpreadsheetID: "pizza-sheet", StreamlabsCreds: "pizza-labs.json"}},
+	}
+	fromFlags := ChannelConfig{StreamlabsCreds: "labs.json", TipLogPath: "tips.txt", EventSubCreds: "eventsub.json"}
+	got, err := resolveChannels([]string{"aerionblue", "usedpizza"}, cfg, fromFlags, true)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	// Only the first channel falls back on the flags.
+	want := []ChannelConfig{
+		{Name: "aerionblue", SpreadsheetID: "main-sheet", StreamlabsCreds: "labs.json", TipLogPath: "tips.txt", EventSubCreds: "eventsub.json"},
+		{Name: "usedpizza", SpreadsheetID: "pizza-sheet", StreamlabsCreds: "pizza-labs.json"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckDonationSources(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		channels []ChannelConfig
+		wantErr  bool
+	}{
+		{"no tips anywhere", []ChannelConfig{{Name: "aerionblue"}, {Name: "usedpizza"}}, false},
+		{"tips everywhere", []ChannelConfig{{Name: "aerionblue", StreamlabsCreds: "labs.json"}, {Name: "usedpizza", TipLogPath: "tips.txt"}}, false},
+		{"second channel without tips", []ChannelConfig{{Name: "aerionblue", StreamlabsCreds: "labs.json"}, {Name: "usedpizza"}}, true},
+		{"shared tip source", []ChannelConfig{{Name: "aerionblue", StreamlabsCreds: "labs.json"}, {Name: "usedpizza", StreamlabsCreds: "labs.json"}}, true},
+		{"shared EventSub", []ChannelConfig{{Name: "aerionblue", EventSubCreds: "eventsub.json"}, {Name: "usedpizza", EventSubCreds: "eventsub.json"}}, true},
+	} {
+		if err := checkDonationSources(tc.channels); (err != nil) != tc.wantErr {
+			t.Errorf("%s: got error %v, want error: %v", tc.desc, err, tc.wantErr)
+		}
+	}
+}
+
+func TestChannelAPIs(t *testing.T) {
+	channels := []*channel{
+		{name: "aerionblue", bot: &bot{bidwars: bidwar.Collection{Contests: []bidwar.Contest{{Name: "Mario Kart track"}}}}},
+		{name: "usedpizza", bot: &bot{bidwars: bidwar.Collection{Contests: []bidwar.Contest{{Name: "Final boss"}}}}},
+	}
+	h := channelAPIs(channels, func(c *channel) *httpapi.Server {
+		return httpapi.NewServer(c.bot.collection)
+	})
+	for path, want := range map[string]string{
+		"/schedule.json":                     "Mario Kart track",
+		"/channels/aerionblue/schedule.json": "Mario Kart track",
+		"/channels/usedpizza/schedule.json":  "Final boss",
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: got %d %q, want %q", path, rec.Code, rec.Body.String(), want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/channels/nobody/schedule.json", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown channel: got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// chatKind categorizes chat messages, so that they can be delayed separately.
+type chatKind int
+
+const (
+	// Replies to mod commands and anything else that isn't listed below.
+	chatCommand chatKind = iota
+	// Replies to subs, bits, and tips.
+	chatDonation
+	// Replies to !bid commands.
+	chatBid
+)
+
+// How many delayed messages a chatQueue can hold before it starts dropping
+// them.
+const chatQueueSize = 1000
+
+type queuedChat struct {
+	sendAt  time.Time
+	channel string
+	// The ID of the chat message that this is a reply to, if any.
+	parentID string
+	msg      string
+	// Whether quiet mode holds this message back (i.e., it isn't a command
+	// reply).
+	quietable bool
+}
+
+// chatQueue delays chat messages by a fixed amount of time. Since every
+// message is delayed by the same amount, they are sent in the order they were
+// added.
+type chatQueue struct {
+	delay time.Duration
+	msgs  chan queuedChat
+}
+
+func newChatQueue(delay time.Duration, say func(channel string, parentID string, msg string)) *chatQueue {
+	q := &chatQueue{delay: delay, msgs: make(chan queuedChat, chatQueueSize)}
+	go func() {
+		for m := range q.msgs {
+			time.Sleep(time.Until(m.sendAt))
+			say(m.channel, m.parentID, m.msg)
+		}
+	}()
+	return q
+}
+
+func (q *chatQueue) add(channel string, parentID string, msg string) {
+	select {
+	case q.msgs <- queuedChat{sendAt: time.Now().Add(q.delay), channel: channel, parentID: parentID, msg: msg}:
+	default:
+		log.Printf("ERROR chat delay queue is full; dropping message: %v", msg)
+	}
+}
+
+// newChatQueues creates a chatQueue for each kind of message with a nonzero
+// delay.
+func newChatQueues(cfg ChatDelayConfig, say func(kind chatKind, channel string, parentID string, msg string)) map[chatKind]*chatQueue {
+	queues := make(map[chatKind]*chatQueue)
+	for kind, secs := range map[chatKind]int{
+		chatDonation: cfg.DonationSeconds,
+		chatBid:      cfg.BidSeconds,
+	} {
+		if secs > 0 {
+			kind := kind
+			queues[kind] = newChatQueue(time.Duration(secs)*time.Second, func(channel string, parentID string, msg string) {
+				say(kind, channel, parentID, msg)
+			})
+		}
+	}
+	return queues
+}
+
+// sayAs sends a chat message of the given kind, after the configured delay
+// for that kind. Only command replies are sent during quiet mode.
+func (b *bot) sayAs(kind chatKind, channel string, msg string) {
+	b.replyAs(kind, channel, "", msg)
+}
+
+// replyAs is like sayAs, but the message is a reply to the chat message with
+// the given ID.
+func (b *bot) replyAs(kind chatKind, channel string, parentID string, msg string) {
+	if kind == chatCommand {
+		b.reply(channel, parentID, msg)
+		return
+	}
+	if q := b.chatQueues[kind]; q != nil {
+		q.add(channel, parentID, msg)
+		return
+	}
+	b.sayUnlessQuiet(kind, channel, parentID, msg)
+}
+
+// sayUnlessQuiet sends a chat message of the given kind, unless a mod has
+// turned on quiet mode.
+func (b *bot) sayUnlessQuiet(kind chatKind, channel string, parentID string, msg string) {
+	if b.isQuiet() {
+		log.Printf("[quiet in #%v] %v", channel, msg)
+		return
+	}
+	b.replyLimited(b.limiterFor(kind), true, channel, parentID, msg)
+}
+
+// limiterFor returns the rate limiter for a kind of chat message.
+func (b *bot) limiterFor(kind chatKind) *rate.Limiter {
+	if kind == chatDonation && b.ackLimiter != nil {
+		return b.ackLimiter
+	}
+	return b.chatLimiter
+}
+
+func (b *bot) isQuiet() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.quiet
+}
+
+func (b *bot) setQuiet(quiet bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.quiet = quiet
+}
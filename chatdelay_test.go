@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestChatQueue(t *testing.T) {
+	sent := make(chan string, 3)
+	delay := 50 * time.Millisecond
+	q := newChatQueue(delay, func(channel string, parentID string, msg string) { sent <- msg })
+	start := time.Now()
+	for _, msg := range []string{"one", "two", "three"} {
+		q.add("testing", "", msg)
+	}
+	for _, want := range []string{"one", "two", "three"} {
+		if got := <-sent; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("messages were sent after %v, want at least %v", elapsed, delay)
+	}
+}
+
+func TestQuietMode(t *testing.T) {
+	b := newLoadTestBot(t, newFakeBackend(0))
+	defer quietLogs()()
+	b.setQuiet(true)
+	b.sayAs(chatDonation, "testing", "thanks for the sub")
+	b.sayAs(chatCommand, "testing", "quiet mode is on")
+	// Only the command reply should have used up a token from the limiter.
+	if got := b.chatLimiter.Tokens(); got < chatBucketSize-1.5 || got > chatBucketSize-0.5 {
+		t.Errorf("got %v tokens left, want about %v", got, chatBucketSize-1)
+	}
+}
+
+func TestAcksHaveTheirOwnLimiter(t *testing.T) {
+	b := newLoadTestBot(t, newFakeBackend(0))
+	defer quietLogs()()
+	b.ackLimiter = rate.NewLimiter(rate.Every(chatCooldown), chatBucketSize)
+	for i := 0; i < chatBucketSize; i++ {
+		b.sayAs(chatCommand, "testing", "a command reply")
+	}
+	b.sayAs(chatDonation, "testing", "thanks for the sub")
+	if got := atomic.LoadInt64(&b.droppedChat); got != 0 {
+		t.Errorf("after a burst of command replies: dropped %d acks, want 0", got)
+	}
+	b.sayAs(chatCommand, "testing", "one too many")
+	if got := atomic.LoadInt64(&b.droppedChat); got != 1 {
+		t.Errorf("got %d dropped messages, want 1", got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// commandCooldowns keeps chat commands from being used more often than their
+// configured cooldowns, across all users, so that viewers can't flood chat
+// with queries. Moderators aren't held to the cooldowns. A nil
+// commandCooldowns allows everything.
+type commandCooldowns struct {
+	// Keyed by command name.
+	cooldowns map[string]time.Duration
+
+	mu sync.Mutex
+	// Maps a command name to the last time it was allowed.
+	lastUsed map[string]time.Time
+}
+
+func newCommandCooldowns(cfg CommandsConfig) *commandCooldowns {
+	c := &commandCooldowns{
+		cooldowns: make(map[string]time.Duration),
+		lastUsed:  make(map[string]time.Time),
+	}
+	for name, secs := range cfg.CooldownSeconds {
+		if cmd, ok := findCommand(commandName(name)); ok && secs > 0 {
+			c.cooldowns[cmd.name] = time.Duration(secs) * time.Second
+		}
+	}
+	return c
+}
+
+// allow reports whether the command may be used now, and if so, starts its
+// cooldown.
+func (c *commandCooldowns) allow(name string, now time.Time) bool {
+	if c == nil {
+		return true
+	}
+	cooldown, ok := c.cooldowns[name]
+	if !ok {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.lastUsed[name]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	c.lastUsed[name] = now
+	return true
+}
+
+// validateCommandCooldowns checks that every command in the config exists
+// and has a cooldown that isn't negative.
+func validateCommandCooldowns(cfg CommandsConfig) error {
+	for name, secs := range cfg.CooldownSeconds {
+		if _, ok := findCommand(commandName(name)); !ok {
+			return fmt.Errorf("unknown command %q in command cooldowns", name)
+		}
+		if secs < 0 {
+			return fmt.Errorf("cooldown for %s must not be negative, got %d", name, secs)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommandCooldowns(t *testing.T) {
+	now := time.Now()
+	c := newCommandCooldowns(CommandsConfig{CooldownSeconds: map[string]int{"Standings": 30, "!mybids": 0}})
+	if !c.allow(standingsCommand, now) {
+		t.Error("first !standings should be allowed")
+	}
+	if c.allow(standingsCommand, now.Add(29*time.Second)) {
+		t.Error("!standings within the cooldown should not be allowed")
+	}
+	if !c.allow(standingsCommand, now.Add(30*time.Second)) {
+		t.Error("!standings after the cooldown should be allowed")
+	}
+	for i := 0; i < 2; i++ {
+		if !c.allow(myBidsCommand, now) {
+			t.Error("commands without a cooldown should always be allowed")
+		}
+	}
+}
+
+func TestValidateCommandCooldowns(t *testing.T) {
+	for _, tc := range []struct {
+		cooldowns map[string]int
+		wantErr   bool
+	}{
+		{map[string]int{"!standings": 30, "topdonors": 60}, false},
+		{map[string]int{"!nope": 30}, true},
+		{map[string]int{"!standings": -1}, true},
+	} {
+		err := validateCommandCooldowns(CommandsConfig{CooldownSeconds: tc.cooldowns})
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("validateCommandCooldowns(%v): got error %v, want error: %v", tc.cooldowns, err, tc.wantErr)
+		}
+	}
+}
@@ -4,15 +4,707 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/money"
+	"github.com/aerionblue/pizzafest/sanitize"
 )
 
 type BotConfig struct {
 	Spreadsheet SpreadsheetConfig
+	Notes       NotesConfig
+	// Which donation providers should look for bids in the donor's name.
+	DonorNameBids DonorNameBidsConfig
+	Scoreboard    ScoreboardConfig
+	Emotes        EmotesConfig
+	DBFailure     DBFailureConfig
+	HTTP          HTTPConfig
+	ChatDelay     ChatDelayConfig
+	Reasons       ReasonsConfig
+	Hooks         HooksConfig
+	Countdown     CountdownConfig
+	DonorLinks    DonorLinksConfig
+	Cooldown      CooldownConfig
+	DuplicateBids DuplicateBidsConfig
+	BidLimit      BidLimitConfig
+	BidChange     BidChangeConfig
+	Backup        BackupConfig
+	Audit         AuditConfig
+	APIAuth       APIAuthConfig
+	Streaks       StreaksConfig
+	ChangeFeed    ChangeFeedConfig
+	Display       DisplayConfig
+	Outage        OutageConfig
+	Permissions   PermissionsConfig
+	Predictions   PredictionsConfig
+	Replies       RepliesConfig
+	Whispers      WhispersConfig
+	Commands      CommandsConfig
+	Dropped       DroppedConfig
+	Notices       NoticesConfig
+	Upgrades      UpgradesConfig
+	SubValues     SubValuesConfig
+	Results       ResultsConfig
+	HypeTrain     HypeTrainConfig
+	Status        StatusConfig
+	ChannelPoints ChannelPointsConfig
+	EventSub      EventSubConfig
+	Random        RandomConfig
+	Cheermotes    CheermotesConfig
+	Currency      CurrencyConfig
+	Fees          FeesConfig
+	Dedup         DedupConfig
+	// The channels to join, if there's no --channel flag. See ChannelConfig.
+	Channels []ChannelConfig
+}
+
+// ChannelConfig gives one of the bot's channels its own bid wars and
+// donation records, for when one bot runs in several channels (e.g., for a
+// co-stream). The first channel uses the --bidwar_data file, the Spreadsheet
+// config, and the donation provider and Twitch API flags unless they're
+// overridden here; each other channel needs its own place to record
+// donations and its own donation providers, and writes its journal, audit
+// log, change feed, dropped events, campaign, and streaks to the configured
+// files with ".<channel>" appended. The first channel's HTTP API is served at the root,
+// and every channel's is served under /channels/<channel>/.
+type ChannelConfig struct {
+	// The channel's name, without the "#".
+	Name string
+	// A JSON file describing the channel's bid wars, like --bidwar_data.
+	BidWarDataPath string
+	// The spreadsheet where the channel's donations and bid wars are kept,
+	// when recording in Google Sheets. The sheet names come from the
+	// Spreadsheet config.
+	SpreadsheetID string
+	// The Firestore collection where the channel's donations are recorded,
+	// when recording in Firestore.
+	FirestoreCollection string
+	// Where the channel's tips come from, like --streamelements_creds,
+	// --streamlabs_creds, and --tip_log_path. Each channel needs its own.
+	StreamElementsCreds string
+	StreamlabsCreds     string
+	TipLogPath          string
+	// The broadcaster's Twitch API credentials files for the channel's
+	// EventSub events and Predictions, like --twitch_eventsub_creds and
+	// --twitch_api_creds. Without EventSub credentials, the channel's subs
+	// and cheers come from chat, and its channel point redemptions aren't
+	// counted.
+	EventSubCreds  string
+	TwitchAPICreds string
+}
+
+// CurrencyConfig sets the exchange rates for donations in currencies other
+// than US dollars. Donations in a currency with no rate are ignored.
+type CurrencyConfig struct {
+	// How much one unit of each currency is worth in US dollars, keyed by its
+	// ISO 4217 code, e.g. {"CAD": 0.73, "EUR": 1.08}.
+	Rates map[string]float64
+}
+
+// exchangeRates returns the exchange rates, keyed by uppercase currency
+// code.
+func (c CurrencyConfig) exchangeRates() map[string]float64 {
+	rates := make(map[string]float64)
+	for cur, usd := range c.Rates {
+		rates[strings.ToUpper(cur)] = usd
+	}
+	return rates
+}
+
+// FeesConfig sets the payment processors' fees for the donation sources
+// that don't report them, so that the net amount of each donation can be
+// recorded (see SpreadsheetConfig.RecordNet). Chat and the bid wars always
+// use the full amount.
+type FeesConfig struct {
+	// The fee schedule for each source, keyed by its name ("streamlabs" or
+	// "streamelements").
+	Schedules map[string]FeeScheduleConfig
+}
+
+// FeeScheduleConfig is what a processor takes out of each donation.
+type FeeScheduleConfig struct {
+	// The percentage of each donation that the processor takes, e.g. 2.9.
+	Percent float64
+	// A fixed fee on each donation, in cents.
+	FixedCents int
+}
+
+// The sources that can have a fee schedule.
+var feeSources = map[string]donation.Source{
+	string(donation.SourceStreamlabs):     donation.SourceStreamlabs,
+	string(donation.SourceStreamElements): donation.SourceStreamElements,
+}
+
+// schedules returns the fee schedules, by source.
+func (c FeesConfig) schedules() map[donation.Source]donation.FeeSchedule {
+	schedules := make(map[donation.Source]donation.FeeSchedule)
+	for name, s := range c.Schedules {
+		schedules[feeSources[name]] = donation.FeeSchedule{Percent: s.Percent, Fixed: donation.CentsValue(s.FixedCents)}
+	}
+	return schedules
+}
+
+// CheermotesConfig lists the channel's custom cheermotes, which are stripped
+// from bits messages (along with Twitch's global ones) before looking for a
+// bid.
+type CheermotesConfig struct {
+	// The prefix of each custom cheermote, e.g. "pizza" for "pizza100".
+	Prefixes []string
+}
+
+// RandomConfig controls the random numbers used for random bids, coin flip
+// tie breaks, and auto-assigned donations. The seed and every draw are
+// logged, so a disputed draw can be checked by replaying it.
+type RandomConfig struct {
+	// If nonzero, the draws start from this seed, so they're the same on
+	// every run. Otherwise a new seed is picked at startup.
+	Seed int64
+}
+
+// source creates the source of random numbers, and logs its seed.
+func (c RandomConfig) source() *bidwar.Random {
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	log.Printf("random seed: %d", seed)
+	return bidwar.NewRandom(seed)
+}
+
+// EventSubConfig controls whether subs, gift subs, and cheers are read from
+// Twitch EventSub (see --twitch_eventsub_creds) as well as from chat.
+type EventSubConfig struct {
+	// "replace" to take them from EventSub instead of chat, or "crosscheck"
+	// to keep taking them from chat and tell the mods about any that only
+	// one side reported. If empty, EventSub isn't used. Resubs and upgrades
+	// always come from chat. Only the channels with EventSub credentials (see
+	// ChannelConfig) use EventSub; the others always take them from chat.
+	Mode string
+	// In replace mode, also take the subs that users buy for themselves from
+	// EventSub. EventSub reports Prime subs as tier 1 subs, so with this on,
+	// a Prime sub is worth as much as a tier 1 sub. If false, those subs keep
+	// coming from chat, which can tell Prime subs apart.
+	SelfSubs bool
+}
+
+// ChannelPointsConfig lists the channel point rewards that count towards the
+// bid wars. The redemptions come from EventSub (see --twitch_eventsub_creds),
+// even if the EventSub mode is empty, so only the redemptions in channels
+// with EventSub credentials (see ChannelConfig) count.
+// The option comes from the viewer's text, so the reward should ask which
+// option to vote for.
+type ChannelPointsConfig struct {
+	Rewards []ChannelPointReward
+}
+
+// ChannelPointReward is a channel point reward that counts towards the bid
+// wars.
+type ChannelPointReward struct {
+	// The reward's ID, as EventSub reports it. (It's also the
+	// custom-reward-id tag of the chat messages that it sends.)
+	ID string
+	// How many bid war points (in cents) each redemption is worth. It's
+	// credit, not money, so it doesn't count towards the amount raised. If 0,
+	// it only counts as a vote, which matters for contests ranked by votes.
+	Cents int
+}
+
+// StatusConfig controls the bot's own status announcements (see also
+// !botstatus).
+type StatusConfig struct {
+	// Whether to say in chat when the bot first connects and when it shuts
+	// down, so that the mods know when donations are being tracked.
+	Announce bool
+}
+
+// HypeTrainConfig controls the bot's Hype Train announcements. The bot can't
+// see Twitch's own Hype Train, so it approximates one from the subs and bits
+// in chat, counting points the same way as the bid wars.
+type HypeTrainConfig struct {
+	// The points needed to reach each level, counted from the start of the
+	// train (e.g., [1600, 3400, 5500]). If empty, Hype Trains aren't tracked.
+	LevelPoints []int
+	// How many different users must sub or cheer within the window to start
+	// a train. Defaults to 3.
+	StartContributors int
+	// How long a train keeps going after the last sub or cheer, which is also
+	// the window for starting one. Defaults to 300.
+	WindowSeconds int
+	// How many bid war points (in cents) to credit for each level that a
+	// train reached, when it ends. The bonus is recorded as unassigned
+	// credit, not money, so it doesn't count towards the amount raised,
+	// unless BonusIsMoney is set. If 0, nothing is credited.
+	BonusCentsPerLevel int
+	// Whether the bonus is money (e.g., a sponsor matches each level), so
+	// that it's added to the amount raised.
+	BonusIsMoney bool
+}
+
+// ResultsConfig controls the copy of a contest's donations that is saved to
+// its own sheet when the contest is resolved (see !resolve).
+type ResultsConfig struct {
+	// Whether to save the copy. It needs the donation spreadsheet.
+	Export bool
+	// The name of each contest's sheet is this followed by the contest's
+	// name. Defaults to "Results: ".
+	SheetNamePrefix string
+}
+
+// SubValuesConfig sets how much a one-month sub of each tier is worth, in
+// cents. The defaults are 500 for Prime, 600 for tier 1, 1200 for tier 2, and
+// 2500 for tier 3. A value can be 0 (e.g., for events that don't count Prime
+// subs).
+type SubValuesConfig struct {
+	PrimeCents int
+	Tier1Cents int
+	Tier2Cents int
+	Tier3Cents int
+}
+
+// tiers returns the sub values, by tier.
+func (c SubValuesConfig) tiers() map[donation.SubTier]donation.CentsValue {
+	return map[donation.SubTier]donation.CentsValue{
+		donation.SubTierPrime: donation.CentsValue(c.PrimeCents),
+		donation.SubTier1:     donation.CentsValue(c.Tier1Cents),
+		donation.SubTier2:     donation.CentsValue(c.Tier2Cents),
+		donation.SubTier3:     donation.CentsValue(c.Tier3Cents),
+	}
+}
+
+// UpgradesConfig controls how sub upgrades are credited. Each value is in
+// cents, for a tier 1 upgrade or one whose tier Twitch doesn't say, and
+// defaults to 600, the same as a tier 1 sub. Upgrades to tiers 2 and 3 are
+// worth the same as those subs.
+type UpgradesConfig struct {
+	// A user continuing a gifted sub at their own expense.
+	GiftCents int
+	// A user switching from a Prime sub to a paid one.
+	PrimeCents int
+	// Any other upgrade to a paid sub.
+	StandardCents int
+}
+
+// values returns the upgrade values, by kind of upgrade.
+func (c UpgradesConfig) values() map[donation.SubEventType]donation.CentsValue {
+	return map[donation.SubEventType]donation.CentsValue{
+		donation.GiftUpgrade:     donation.CentsValue(c.GiftCents),
+		donation.PrimeUpgrade:    donation.CentsValue(c.PrimeCents),
+		donation.StandardUpgrade: donation.CentsValue(c.StandardCents),
+	}
+}
+
+// rates collects what the config says donations are worth, for the parsers.
+func (c BotConfig) rates() donation.Rates {
+	return donation.Rates{
+		Tiers:         c.SubValues.tiers(),
+		Upgrades:      c.Upgrades.values(),
+		ExchangeRates: c.Currency.exchangeRates(),
+		Fees:          c.Fees.schedules(),
+	}
+}
+
+// DisplayConfig controls how point values are shown in chat and on the
+// scoreboard, for events that don't track cents.
+type DisplayConfig struct {
+	// How many decimal places to show: 0, 1, or 2. Defaults to 2.
+	Decimals int
+	// How to round to that many places: "HALF_UP", "DOWN", or "UP". Defaults
+	// to "HALF_UP".
+	Rounding string
+	// Whether the values written to the donation table are rounded too. If
+	// false, they are always written to the cent.
+	RoundSheetValues bool
+}
+
+func (c DisplayConfig) precision() money.Precision {
+	return money.Precision{Decimals: c.Decimals, Rounding: money.Rounding(c.Rounding)}
+}
+
+// ChangeFeedConfig describes the change feed, which mirrors each of the bot's
+// writes to the donation table as a line of JSON, for tools that don't have
+// Sheets API access of their own.
+type ChangeFeedConfig struct {
+	// A file to append the feed to. Optional.
+	FilePath string
+	// Whether to stream the feed at /changes on the HTTP API (see
+	// --http_addr). The stream needs the admin token.
+	Stream bool
+}
+
+// RepliesConfig controls how the bot replies to chat messages.
+type RepliesConfig struct {
+	// Whether to send replies to !bid and bits messages as threaded replies,
+	// so that chat shows them attached to the message they answer. The bot
+	// opens a second chat connection to send them, and falls back to
+	// @mentions if that fails. Only in prod.
+	Threaded bool
+}
+
+// PredictionsConfig controls the Twitch Predictions for contests that ask for
+// one (see --twitch_api_creds).
+type PredictionsConfig struct {
+	// How many minutes before a contest closes to lock its prediction. Twitch
+	// locks a prediction at most 30 minutes after it opens regardless.
+	LockMinutesBeforeClose int
+}
+
+// PermissionsConfig controls who can use the privileged chat commands. The
+// broadcaster and the channel moderators can use all of them by default.
+type PermissionsConfig struct {
+	// Twitch users who can use the moderator commands without being channel
+	// moderators (e.g., producers).
+	Moderators []string
+	// Twitch users who can use every command, like the broadcaster.
+	Admins []string
+	// Commands (e.g., "!closecontest") that only the broadcaster and the
+	// Admins can use.
+	AdminOnly []string
+}
+
+// NoticesConfig controls what the bot does about raids and viewer milestones
+// (e.g., watch streaks), which aren't worth any money unless RaidCents is
+// set.
+type NoticesConfig struct {
+	// Whether to thank raiders in chat.
+	AnnounceRaids bool
+	// Whether to record each raid in the donation table, e.g. for the
+	// post-event thank-you list.
+	RecordRaids bool
+	// How many bid war points (in cents) each recorded raid is worth. It's
+	// credit, not money, so it doesn't count towards the amount raised. If 0,
+	// raids are recorded but don't count for anything.
+	RaidCents int
+	// Whether to congratulate viewers who reach a milestone.
+	AnnounceMilestones bool
+	// Incentives that raids or milestones unlock. Each one is unlocked at
+	// most once.
+	Incentives []NoticeIncentive
+}
+
+// NoticeIncentive is something that a big enough raid or milestone unlocks.
+type NoticeIncentive struct {
+	// "raid" or "viewermilestone".
+	Type string
+	// The smallest count that unlocks it: the number of raiders, or the
+	// milestone's value (e.g., 10 for a 10-stream watch streak).
+	MinCount int
+	// What it unlocks, e.g. "the blindfold run".
+	Unlocks string
+}
+
+// DroppedConfig controls the record of events that the bot ignored on
+// purpose (see !dropped).
+type DroppedConfig struct {
+	// A file to write each ignored event to, with its payload, for review
+	// after the event. If empty, ignored events are only counted.
+	ReviewFilePath string
+}
+
+// CommandsConfig controls how often viewers can use the chat commands.
+type CommandsConfig struct {
+	// Maps a command (e.g., "!standings") to how many seconds must pass
+	// between uses of it, by anyone. Uses during the cooldown are ignored.
+	// Moderators aren't held to the cooldowns.
+	CooldownSeconds map[string]int
+}
+
+// OutageConfig controls what happens to donation acknowledgments while the
+// bot is disconnected from chat.
+type OutageConfig struct {
+	// Whether to hold back acknowledgments while disconnected, and post one
+	// catch-up message listing them on reconnect. If false, they're sent to
+	// the IRC client as usual, which may drop them.
+	CatchUp bool
+	// How many donations the catch-up message lists by name; the rest are
+	// counted. Defaults to 10.
+	MaxDonations int
+}
+
+// StreaksConfig controls the chat shout-outs for donors who keep donating.
+type StreaksConfig struct {
+	// Thank a donor when their donations within WindowHours reach one of
+	// these counts (e.g., [3, 5, 10]). If empty, repeat donations aren't
+	// recognized.
+	RepeatCounts []int
+	// How far back to count a donor's donations. Defaults to 12.
+	WindowHours int
+	// Thank a donor when they have donated in at least this many consecutive
+	// clock hours. If 0, hourly streaks aren't recognized.
+	ConsecutiveHours int
+	// A local file in which to keep the recent donation times, so that
+	// streaks carry on after a restart. If empty, a restart starts every
+	// streak over.
+	FilePath string
+}
+
+// APIAuthConfig holds the tokens for the HTTP API (see --http_addr). Clients
+// present a token as an "Authorization: Bearer" header or a "token" query
+// parameter.
+type APIAuthConfig struct {
+	// Grants access to the read-only endpoints, such as the overlay widgets.
+	// If empty, those endpoints are public.
+	ReadToken string
+	// Grants access to every endpoint, including the ones that change the
+	// bot's state. If empty, those endpoints are disabled.
+	AdminToken string
+}
+
+// AuditConfig describes the audit log, a history of every bid assignment,
+// kept apart from the donation table for settling disputes.
+type AuditConfig struct {
+	// The audit log file. The audit log is disabled if this is empty.
+	FilePath string
+}
+
+// BackupConfig controls the periodic snapshots of the spreadsheet, which are
+// saved as CSV files in case the shared sheet gets damaged.
+type BackupConfig struct {
+	// The directory to save snapshots in. Backups are disabled if this is
+	// empty.
+	Directory string
+	// How often to take a snapshot. Defaults to 15 minutes.
+	IntervalMinutes int
+	// How many snapshots to keep. If 0, all of them are kept.
+	Keep int
+}
+
+// BidChangeConfig lets donors change their minds shortly after a !bid.
+type BidChangeConfig struct {
+	// Within this many minutes of a !bid, a !bid for another option moves the
+	// donations that the first !bid assigned. If 0, donations stay where the
+	// first !bid put them.
+	WindowMinutes int
+}
+
+// DuplicateBidsConfig controls how repeats of the same !bid are handled.
+type DuplicateBidsConfig struct {
+	// When a user repeats their last !bid within this many seconds, they get
+	// the same reply, without rereading the spreadsheet. (A donation from the
+	// user resets this.) If 0, every !bid is handled in full.
+	WindowSeconds int
+}
+
+// WhispersConfig controls whispers to users.
+type WhispersConfig struct {
+	// Whether to whisper personal acknowledgements (e.g., "I put your sub
+	// towards Moo Moo Meadows") instead of saying them in chat. The new
+	// totals are still said in chat. Acknowledgements are said in chat as
+	// usual when the whisper limits are reached, or when Twitch won't
+	// deliver the whisper. Needs --twitch_whisper_creds.
+	Acknowledgements bool
+	// The most different users to whisper per day. Defaults to 40, Twitch's
+	// limit for accounts that aren't verified bots. If negative, there's no
+	// limit.
+	MaxRecipientsPerDay int
+}
+
+// BidLimitConfig throttles each user's !bid commands.
+type BidLimitConfig struct {
+	// Each user can run one !bid per this many seconds; extra ones are
+	// ignored, except that the first one is told how long to wait. A !bid
+	// that repeats the last one isn't throttled, since it gets the cached
+	// reply (see DuplicateBidsConfig). A donation from the user resets
+	// this. If 0, !bid isn't throttled.
+	Seconds int
+}
+
+// CooldownConfig controls replies to users that the chat rate limiter would
+// otherwise drop, so that the bot doesn't seem to ignore them.
+type CooldownConfig struct {
+	// "reply" sends the user's latest reply once chat frees up. "whisper"
+	// also whispers the user right away that the reply is coming (which
+	// needs --twitch_whisper_creds). If empty, the replies are dropped.
+	Feedback string
+	// Each user gets at most one delayed reply per window. Defaults to 30.
+	FeedbackWindowSeconds int
+}
+
+// DonorLinksConfig describes the file that links donors' tip names to their
+// Twitch usernames, so that they can use !bid on their tips. Mods add links
+// with !link.
+type DonorLinksConfig struct {
+	// The links file. Donor links are disabled if this is empty.
+	FilePath string
+}
+
+// ReasonsConfig controls how donor messages are cleaned up before they are
+// written to the spreadsheet as bid war reasons.
+type ReasonsConfig struct {
+	// The maximum length of a reason, in characters. Defaults to 250. If
+	// negative, reasons aren't capped.
+	MaxLength int
+	// Whether to replace URLs with "[link]".
+	StripURLs bool
+}
+
+func (c ReasonsConfig) options() sanitize.Options {
+	return sanitize.Options{MaxLength: c.MaxLength, StripURLs: c.StripURLs}
+}
+
+// HooksConfig describes an external program to run after each donation is
+// recorded. The program receives the donation as JSON on stdin.
+type HooksConfig struct {
+	// The program and its arguments. Hooks are disabled if this is empty.
+	Command []string
+	// How long to let the program run before killing it. Defaults to 10
+	// seconds.
+	TimeoutSeconds int
+}
+
+// CountdownConfig controls the chat warnings before a contest's scheduled
+// close time (its closesAt), and before each !timer runs out.
+type CountdownConfig struct {
+	// How many minutes before the close time to warn chat. Defaults to 10, 5,
+	// and 1 minutes.
+	WarningMinutes []int
+}
+
+func (c CountdownConfig) warnings() []time.Duration {
+	var ws []time.Duration
+	for _, m := range c.WarningMinutes {
+		ws = append(ws, time.Duration(m)*time.Minute)
+	}
+	return ws
 }
 
 type SpreadsheetConfig struct {
 	ID        string
 	SheetName string
+	// A sheet that defines the bid wars. If set, the bid wars are read from
+	// this sheet instead of the --bidwar_data file.
+	BidWarSheetName string
+	// Whether to record each donation's source (e.g., "streamlabs") in column
+	// F of the donation table. Only turn this on if column F is free.
+	RecordSource bool
+	// Whether to record the campaign (see !campaign) that each donation was
+	// made during in column G of the donation table. Only turn this on if
+	// column G is free.
+	RecordCampaign bool
+	// A local file in which to keep the running campaign, so that it's still
+	// running after a restart. If empty, a restart ends the campaign.
+	CampaignFilePath string
+	// Whether to record each donor's Twitch user ID in column H of the
+	// donation table, so that their donations still count as theirs after
+	// they rename themselves. IDs that don't come with the donation are
+	// looked up with the --twitch_api_creds credentials. Only turn this on if
+	// column H is free.
+	RecordOwnerID bool
+	// Whether to record the time of each donation in column I of the
+	// donation table, in the bot's local time zone. Only turn this on if
+	// column I is free.
+	RecordTime bool
+	// Whether to record the cash that's left from each donation after the
+	// payment processor's fee (see Fees) in column J of the donation table.
+	// Only turn this on if column J is free.
+	RecordNet bool
+	// How long to reuse the bid war totals read from the spreadsheet, to save
+	// Sheets quota during bursts of donations. The bot's own writes always
+	// make it reread the totals. If 0, the totals are read every time.
+	TotalsTTLSeconds int
+}
+
+// ScoreboardConfig describes a public sheet (in the same spreadsheet as the
+// donation table) where the bot periodically publishes the standings.
+type ScoreboardConfig struct {
+	// The name of the sheet. The scoreboard is disabled if this is empty.
+	SheetName string
+	// How often to update the scoreboard. Defaults to 5 minutes.
+	IntervalMinutes int
+}
+
+// NotesConfig describes where to post producer-facing notes. Every
+// destination is optional; notes are disabled if none are set.
+type NotesConfig struct {
+	// A Twitch channel (other than the main channel) to post notes in.
+	TwitchChannel string
+	// A Discord webhook URL to post notes to.
+	DiscordWebhookURL string
+	// A local file to append notes to.
+	FilePath string
+	// Donations worth at least this many cents get a note. Defaults to
+	// $50.00.
+	BigDonationCents int
+}
+
+// EmotesConfig holds the channel-specific emotes used in chat replies. Each
+// one is optional. (Emotes in bid war summaries are configured per contest.)
+type EmotesConfig struct {
+	// Used when a !bid command assigns some points.
+	BidAssigned string
+	// Used when a !bid command has no points to assign.
+	NoPoints string
+}
+
+// ChatDelayConfig delays chat announcements to match the stream delay, so
+// that totals don't spoil what viewers haven't seen yet. Donations are still
+// recorded right away. Each delay defaults to 0 (no delay).
+type ChatDelayConfig struct {
+	// Replies to subs, bits, and tips, including the updated totals.
+	DonationSeconds int
+	// Replies to !bid commands.
+	BidSeconds int
+}
+
+// HTTPConfig controls the HTTP client used to talk to the donation provider
+// APIs, for deployments behind restrictive networks.
+type HTTPConfig struct {
+	// The timeout for each request. Defaults to 30 seconds, so that a hung
+	// request can't stall polling forever.
+	TimeoutSeconds int
+	// The URL of an HTTP proxy (e.g., "http://proxy.example:3128"). If empty,
+	// the usual HTTP_PROXY/HTTPS_PROXY environment variables are honored.
+	ProxyURL string
+	// Restricts connections to IPv4 ("tcp4") or IPv6 ("tcp6"). Defaults to
+	// "tcp", which allows either.
+	Network string
+	// The maximum number of idle connections to keep per host. Defaults to
+	// the Go default.
+	MaxIdleConnsPerHost int
+}
+
+// Legal values for DBFailureConfig.Policy.
+const (
+	// Don't reply to the donation at all.
+	dbFailureSilent = "SILENT"
+	// Thank the donor anyway, and say that the totals are delayed.
+	dbFailureAcknowledge = "ACKNOWLEDGE"
+)
+
+// DBFailureConfig controls what happens when a donation can't be written to
+// the database. Mods are always alerted via the producer notes.
+type DBFailureConfig struct {
+	// Whether to reply in chat anyway. Defaults to "SILENT".
+	Policy string
+	// A local file in which to keep donations that couldn't be recorded, so
+	// that they can be retried. Optional.
+	JournalPath string
+	// How often to retry the donations in the journal. Defaults to 60.
+	RetryIntervalSeconds int
+}
+
+// DedupConfig controls how the bot avoids recording a donation twice when
+// its source delivers it again (e.g., after a reconnect). Donations are
+// matched by their IDs from the source.
+type DedupConfig struct {
+	// A local file in which to keep the IDs of the recorded donations, so
+	// that they're remembered across restarts. If empty, they're only
+	// remembered while the bot is running. Required if StreamElements or
+	// Streamlabs is polled, or with --role=record, since the pollers (and the
+	// ingest process that runs them) read their providers' recent donations
+	// again when they restart.
+	FilePath string
+}
+
+// DonorNameBidsConfig controls, per provider, whether we look for a bid war
+// choice in the donor's name when the donation message doesn't contain one.
+type DonorNameBidsConfig struct {
+	Streamlabs     bool
+	StreamElements bool
+	TipFile        bool
 }
 
 func ParseBotConfig(path string) (BotConfig, error) {
@@ -21,9 +713,159 @@ func ParseBotConfig(path string) (BotConfig, error) {
 		return BotConfig{}, fmt.Errorf("could not read bot config file: %v", err)
 	}
 
-	var cfg BotConfig
+	cfg := BotConfig{
+		Notes:      NotesConfig{BigDonationCents: 5000},
+		Scoreboard: ScoreboardConfig{IntervalMinutes: 5},
+		DBFailure:  DBFailureConfig{Policy: dbFailureSilent, RetryIntervalSeconds: 60},
+		HTTP:       HTTPConfig{TimeoutSeconds: 30, Network: "tcp"},
+		Reasons:    ReasonsConfig{MaxLength: sanitize.DefaultMaxLength},
+		Hooks:      HooksConfig{TimeoutSeconds: 10},
+		Countdown:  CountdownConfig{WarningMinutes: []int{10, 5, 1}},
+		Cooldown:   CooldownConfig{FeedbackWindowSeconds: 30},
+		Backup:     BackupConfig{IntervalMinutes: 15},
+		Streaks:    StreaksConfig{WindowHours: 12},
+		Outage:     OutageConfig{MaxDonations: 10},
+		Whispers:   WhispersConfig{MaxRecipientsPerDay: 40},
+		Upgrades:   UpgradesConfig{GiftCents: 600, PrimeCents: 600, StandardCents: 600},
+		SubValues:  SubValuesConfig{PrimeCents: 500, Tier1Cents: 600, Tier2Cents: 1200, Tier3Cents: 2500},
+		Results:    ResultsConfig{SheetNamePrefix: "Results: "},
+		HypeTrain:  HypeTrainConfig{StartContributors: 3, WindowSeconds: 300},
+		Display:    DisplayConfig{Decimals: 2, Rounding: string(money.RoundHalfUp)},
+	}
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return BotConfig{}, fmt.Errorf("error parsing bot config file: %v", err)
 	}
+	if cfg.Scoreboard.IntervalMinutes <= 0 {
+		return BotConfig{}, fmt.Errorf("scoreboard interval must be positive, got %d", cfg.Scoreboard.IntervalMinutes)
+	}
+	if cfg.DBFailure.Policy != dbFailureSilent && cfg.DBFailure.Policy != dbFailureAcknowledge {
+		return BotConfig{}, fmt.Errorf("unknown DB failure policy %q", cfg.DBFailure.Policy)
+	}
+	if cfg.DBFailure.RetryIntervalSeconds <= 0 {
+		return BotConfig{}, fmt.Errorf("DB retry interval must be positive, got %d", cfg.DBFailure.RetryIntervalSeconds)
+	}
+	if cfg.ChatDelay.DonationSeconds < 0 || cfg.ChatDelay.BidSeconds < 0 {
+		return BotConfig{}, fmt.Errorf("chat delays must not be negative")
+	}
+	if cfg.HTTP.TimeoutSeconds <= 0 {
+		return BotConfig{}, fmt.Errorf("HTTP timeout must be positive, got %d", cfg.HTTP.TimeoutSeconds)
+	}
+	if cfg.Hooks.TimeoutSeconds <= 0 {
+		return BotConfig{}, fmt.Errorf("hook timeout must be positive, got %d", cfg.Hooks.TimeoutSeconds)
+	}
+	for _, m := range cfg.Countdown.WarningMinutes {
+		if m <= 0 {
+			return BotConfig{}, fmt.Errorf("countdown warnings must be positive, got %d", m)
+		}
+	}
+	switch cfg.Cooldown.Feedback {
+	case cooldownDrop, cooldownReply, cooldownWhisper:
+	default:
+		return BotConfig{}, fmt.Errorf("cooldown feedback must be reply or whisper, got %q", cfg.Cooldown.Feedback)
+	}
+	if cfg.Backup.IntervalMinutes <= 0 {
+		return BotConfig{}, fmt.Errorf("backup interval must be positive, got %d", cfg.Backup.IntervalMinutes)
+	}
+	if cfg.Backup.Keep < 0 {
+		return BotConfig{}, fmt.Errorf("number of backups to keep must not be negative, got %d", cfg.Backup.Keep)
+	}
+	if cfg.BidChange.WindowMinutes < 0 {
+		return BotConfig{}, fmt.Errorf("bid change window must not be negative, got %d", cfg.BidChange.WindowMinutes)
+	}
+	if cfg.DuplicateBids.WindowSeconds < 0 {
+		return BotConfig{}, fmt.Errorf("duplicate bid window must not be negative, got %d", cfg.DuplicateBids.WindowSeconds)
+	}
+	if cfg.BidLimit.Seconds < 0 {
+		return BotConfig{}, fmt.Errorf("bid limit must not be negative, got %d", cfg.BidLimit.Seconds)
+	}
+	for _, n := range cfg.Streaks.RepeatCounts {
+		if n < 2 {
+			return BotConfig{}, fmt.Errorf("streak repeat counts must be at least 2, got %d", n)
+		}
+	}
+	if cfg.Streaks.WindowHours <= 0 {
+		return BotConfig{}, fmt.Errorf("streak window must be positive, got %d", cfg.Streaks.WindowHours)
+	}
+	if cfg.Streaks.ConsecutiveHours < 0 {
+		return BotConfig{}, fmt.Errorf("streak consecutive hours must not be negative, got %d", cfg.Streaks.ConsecutiveHours)
+	}
+	if cfg.Predictions.LockMinutesBeforeClose < 0 {
+		return BotConfig{}, fmt.Errorf("prediction lock time must not be negative, got %d", cfg.Predictions.LockMinutesBeforeClose)
+	}
+	if err := validateAdminOnly(cfg.Permissions.AdminOnly); err != nil {
+		return BotConfig{}, err
+	}
+	if err := validateCommandCooldowns(cfg.Commands); err != nil {
+		return BotConfig{}, err
+	}
+	for _, inc := range cfg.Notices.Incentives {
+		switch donation.NoticeType(inc.Type) {
+		case donation.Raid, donation.ViewerMilestone:
+		default:
+			return BotConfig{}, fmt.Errorf("notice incentive type must be raid or viewermilestone, got %q", inc.Type)
+		}
+		if inc.Unlocks == "" {
+			return BotConfig{}, fmt.Errorf("%s incentive for %d must say what it unlocks", inc.Type, inc.MinCount)
+		}
+	}
+	if cfg.Outage.MaxDonations < 1 {
+		return BotConfig{}, fmt.Errorf("outage max donations must be positive, got %d", cfg.Outage.MaxDonations)
+	}
+	if cfg.Spreadsheet.TotalsTTLSeconds < 0 {
+		return BotConfig{}, fmt.Errorf("totals TTL must not be negative, got %d", cfg.Spreadsheet.TotalsTTLSeconds)
+	}
+	if cfg.Cooldown.FeedbackWindowSeconds < 0 {
+		return BotConfig{}, fmt.Errorf("cooldown feedback window must not be negative, got %d", cfg.Cooldown.FeedbackWindowSeconds)
+	}
+	if cfg.Upgrades.GiftCents < 0 {
+		return BotConfig{}, fmt.Errorf("gift upgrade value must not be negative, got %d", cfg.Upgrades.GiftCents)
+	}
+	if cfg.Upgrades.PrimeCents < 0 {
+		return BotConfig{}, fmt.Errorf("Prime upgrade value must not be negative, got %d", cfg.Upgrades.PrimeCents)
+	}
+	if cfg.Upgrades.StandardCents < 0 {
+		return BotConfig{}, fmt.Errorf("standard upgrade value must not be negative, got %d", cfg.Upgrades.StandardCents)
+	}
+	if cfg.SubValues.PrimeCents < 0 || cfg.SubValues.Tier1Cents < 0 || cfg.SubValues.Tier2Cents < 0 || cfg.SubValues.Tier3Cents < 0 {
+		return BotConfig{}, fmt.Errorf("sub values must not be negative")
+	}
+	if cfg.Results.Export && cfg.Results.SheetNamePrefix == "" {
+		return BotConfig{}, fmt.Errorf("results sheet name prefix must not be empty")
+	}
+	switch cfg.EventSub.Mode {
+	case eventSubOff, eventSubReplace, eventSubCrossCheck:
+	default:
+		return BotConfig{}, fmt.Errorf("unknown EventSub mode %q", cfg.EventSub.Mode)
+	}
+	for cur, usd := range cfg.Currency.Rates {
+		if usd <= 0 {
+			return BotConfig{}, fmt.Errorf("exchange rate for %s must be positive, got %v", cur, usd)
+		}
+	}
+	for name, s := range cfg.Fees.Schedules {
+		if _, ok := feeSources[name]; !ok {
+			return BotConfig{}, fmt.Errorf("can't set a fee schedule for %q; only streamlabs and streamelements have them", name)
+		}
+		if s.Percent < 0 || s.Percent >= 100 || s.FixedCents < 0 {
+			return BotConfig{}, fmt.Errorf("bad fee schedule for %s: %v%% + %d cents", name, s.Percent, s.FixedCents)
+		}
+	}
+	if cfg.Notices.RaidCents < 0 {
+		return BotConfig{}, fmt.Errorf("raids must not be worth less than 0, got %d", cfg.Notices.RaidCents)
+	}
+	if err := validateChannelPoints(cfg.ChannelPoints); err != nil {
+		return BotConfig{}, err
+	}
+	if err := validateHypeTrain(cfg.HypeTrain); err != nil {
+		return BotConfig{}, err
+	}
+	if err := cfg.Display.precision().Validate(); err != nil {
+		return BotConfig{}, fmt.Errorf("invalid display precision: %v", err)
+	}
+	switch cfg.HTTP.Network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return BotConfig{}, fmt.Errorf("HTTP network must be tcp, tcp4, or tcp6, got %q", cfg.HTTP.Network)
+	}
 	return cfg, nil
 }
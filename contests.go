@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+const contestsCommand = "!contests"
+
+// dispatchContestsCommand lists the open contests, with the short codes of
+// their open options, so that viewers can see what they can bid on.
+func (b *bot) dispatchContestsCommand(m twitch.PrivateMessage) {
+	cons := b.collection().OpenContests()
+	if len(cons) == 0 {
+		b.say(m.Channel, fmt.Sprintf("@%s: No contests are open right now.", m.User.Name))
+		return
+	}
+	prefix := fmt.Sprintf("@%s: ", m.User.Name)
+	for _, msg := range describeOpenContests(cons, maxChatLength-len(prefix)) {
+		b.say(m.Channel, prefix+msg)
+	}
+}
+
+// describeOpenContests lists each contest with its options' short codes, e.g.
+// "Mario Kart track: Moo, NBC | Hat: Red, Blue". Contests are packed into as
+// few messages as fit in maxLen each. A contest too long for one message has
+// its list cut short.
+func describeOpenContests(cons []bidwar.Contest, maxLen int) []string {
+	const sep = " | "
+	var msgs []string
+	msg := ""
+	for _, con := range cons {
+		var codes []string
+		for _, opt := range con.Options {
+			codes = append(codes, opt.ShortCode)
+		}
+		group := fmt.Sprintf("%s: %s", con.Name, strings.Join(codes, ", "))
+		if len(group) > maxLen {
+			group = truncate(group, maxLen-len("..."))
+			if i := strings.LastIndex(group, ", "); i > len(con.Name) {
+				group = group[:i]
+			}
+			group += "..."
+		}
+		switch {
+		case msg == "":
+			msg = group
+		case len(msg)+len(sep)+len(group) <= maxLen:
+			msg += sep + group
+		default:
+			msgs = append(msgs, msg)
+			msg = group
+		}
+	}
+	if msg != "" {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
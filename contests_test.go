@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+func TestDescribeOpenContests(t *testing.T) {
+	mk := func(name string, codes ...string) bidwar.Contest {
+		con := bidwar.Contest{Name: name}
+		for _, c := range codes {
+			con.Options = append(con.Options, bidwar.Option{ShortCode: c})
+		}
+		return con
+	}
+	cons := []bidwar.Contest{
+		mk("Mario Kart track", "Moo", "NBC"),
+		mk("Hat", "Red", "Blue"),
+		mk("Game", "SMW", "DKC", "Zelda"),
+	}
+	got := describeOpenContests(cons, 45)
+	want := []string{
+		"Mario Kart track: Moo, NBC | Hat: Red, Blue",
+		"Game: SMW, DKC, Zelda",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	long := mk("Long", strings.Split(strings.Repeat("ABC,", 20), ",")[:20]...)
+	got = describeOpenContests([]bidwar.Contest{long}, 30)
+	if len(got) != 1 || len(got[0]) > 30 || !strings.HasPrefix(got[0], "Long: ABC, ABC") || !strings.HasSuffix(got[0], "ABC...") {
+		t.Errorf("long contest: got %q, want one cut-short message", got)
+	}
+
+	// A cut never splits a character.
+	got = describeOpenContests([]bidwar.Contest{mk("Pokémon", "Red")}, 7)
+	if want := []string{"Pok..."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("multi-byte name: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+// Package control lets a service manager (e.g., systemd or a Windows service
+// wrapper) control the bot through a file, without access to its stdin or to
+// chat.
+//
+// To send commands, write them to the control file, one per line. The bot
+// checks the file every few seconds, and deletes it after reading it. For
+// example:
+//
+//	echo pause > /run/pizzafest/control
+package control
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Command is something that the bot can be told to do.
+type Command string
+
+const (
+	// Stop posting in chat. Donations are still recorded.
+	Pause Command = "pause"
+	// Start posting in chat again.
+	Resume Command = "resume"
+	// Reread the bid wars.
+	ReloadConfig Command = "reload-config"
+	// Disconnect from chat and exit.
+	Shutdown Command = "shutdown"
+)
+
+// ParseCommands reads the commands from the contents of a control file.
+// Blank lines are ignored. Returns an error for any unknown command, along
+// with all the commands that were understood.
+func ParseCommands(data string) ([]Command, error) {
+	var cmds []Command
+	var unknown []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" {
+			continue
+		}
+		switch c := Command(line); c {
+		case Pause, Resume, ReloadConfig, Shutdown:
+			cmds = append(cmds, c)
+		default:
+			unknown = append(unknown, line)
+		}
+	}
+	if len(unknown) > 0 {
+		return cmds, fmt.Errorf("unknown control commands: %q", unknown)
+	}
+	return cmds, nil
+}
+
+// Watcher reports the commands written to a control file.
+type Watcher struct {
+	// Channel on which commands are reported.
+	C <-chan Command
+}
+
+// Watch starts checking the control file for commands at the given interval.
+// Any file that's already there when the bot starts is stale, so it's
+// deleted without being read.
+func Watch(path string, interval time.Duration) *Watcher {
+	if err := os.Remove(path); err == nil {
+		log.Printf("deleted a stale control file at %s", path)
+	}
+	c := make(chan Command, 10)
+	go func() {
+		for range time.Tick(interval) {
+			cmds, err := readCommands(path)
+			if err != nil {
+				log.Printf("ERROR reading control file: %v", err)
+			}
+			for _, cmd := range cmds {
+				c <- cmd
+			}
+		}
+	}()
+	return &Watcher{C: c}
+}
+
+// readCommands reads and deletes the control file, if there is one.
+func readCommands(path string) ([]Command, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("could not delete control file, so ignoring it: %v", err)
+	}
+	return ParseCommands(string(data))
+}
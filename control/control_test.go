@@ -0,0 +1,44 @@
+package control
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	cmds, err := ParseCommands("pause\n\n  Reload-Config \r\nshutdown\n")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if want := []Command{Pause, ReloadConfig, Shutdown}; !reflect.DeepEqual(cmds, want) {
+		t.Errorf("got %q, want %q", cmds, want)
+	}
+
+	cmds, err = ParseCommands("resume\nexplode\n")
+	if err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+	if want := []Command{Resume}; !reflect.DeepEqual(cmds, want) {
+		t.Errorf("got %q, want %q", cmds, want)
+	}
+}
+
+func TestReadCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control")
+	if cmds, err := readCommands(path); cmds != nil || err != nil {
+		t.Errorf("missing file: got (%q, %v), want nothing", cmds, err)
+	}
+	if err := ioutil.WriteFile(path, []byte("pause\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmds, err := readCommands(path)
+	if err != nil || !reflect.DeepEqual(cmds, []Command{Pause}) {
+		t.Errorf("got (%q, %v), want [pause]", cmds, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("control file should have been deleted, but stat returned %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ways to handle a reply that the chat rate limiter would drop.
+const (
+	// Drop the reply.
+	cooldownDrop = ""
+	// Send the reply once chat frees up.
+	cooldownReply = "reply"
+	// Whisper the user right away that their reply is coming, and send the
+	// reply once chat frees up.
+	cooldownWhisper = "whisper"
+)
+
+// deferredReplies keeps the replies that were held back by the chat rate
+// limiter, at most one per user. Each user gets at most one delayed reply (and
+// one whisper) per window, so that spamming a command doesn't flood chat once
+// the cooldown ends.
+type deferredReplies struct {
+	mode   string
+	window time.Duration
+
+	mu sync.Mutex
+	// Users with a pending reply, oldest first.
+	order []string
+	// Maps a lowercase username to their latest pending reply.
+	pending map[string]queuedChat
+	// Maps a lowercase username to the last time they got a delayed reply.
+	lastSent map[string]time.Time
+}
+
+func newDeferredReplies(mode string, window time.Duration) *deferredReplies {
+	return &deferredReplies{
+		mode:     mode,
+		window:   window,
+		pending:  make(map[string]queuedChat),
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// add holds a reply to a user. A newer reply replaces the user's pending one.
+// Returns whether the user should be whispered about the delay.
+func (d *deferredReplies) add(user string, m queuedChat, now time.Time) (held bool, whisper bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := strings.ToLower(user)
+	if _, ok := d.pending[key]; ok {
+		d.pending[key] = m
+		return true, false
+	}
+	if now.Sub(d.lastSent[key]) < d.window {
+		return false, false
+	}
+	d.order = append(d.order, key)
+	d.pending[key] = m
+	return true, d.mode == cooldownWhisper
+}
+
+func (d *deferredReplies) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.order)
+}
+
+// next returns (and forgets) the oldest pending reply.
+func (d *deferredReplies) next(now time.Time) (queuedChat, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.order) == 0 {
+		return queuedChat{}, false
+	}
+	key := d.order[0]
+	d.order = d.order[1:]
+	m := d.pending[key]
+	delete(d.pending, key)
+	d.lastSent[key] = now
+	return m, true
+}
+
+// mentionedUser returns the user that a chat message is addressed to, if it
+// starts with an @mention (e.g., "@aerionblue: +5.00 for Moo").
+func mentionedUser(msg string) (string, bool) {
+	if !strings.HasPrefix(msg, "@") {
+		return "", false
+	}
+	name := msg[1:]
+	if i := strings.IndexAny(name, ": ,"); i >= 0 {
+		name = name[:i]
+	}
+	return name, name != ""
+}
+
+// withoutMention removes the @mention from the start of a reply, since a
+// threaded reply already shows who it's for.
+func withoutMention(msg string) string {
+	user, ok := mentionedUser(msg)
+	if !ok {
+		return msg
+	}
+	rest := strings.TrimPrefix(msg[1+len(user):], ":")
+	return strings.TrimSpace(rest)
+}
+
+// deferReply holds back a reply that the rate limiter dropped, if cooldown
+// feedback is on and the reply is addressed to a user. Returns whether the
+// reply was held.
+func (b *bot) deferReply(m queuedChat) bool {
+	if b.deferred == nil {
+		return false
+	}
+	user, ok := mentionedUser(m.msg)
+	if !ok {
+		return false
+	}
+	held, whisper := b.deferred.add(user, m, time.Now())
+	if whisper {
+		b.whisper(user, fmt.Sprintf("Chat is busy, so my reply to you in #%s will be a little late.", m.channel), nil)
+	}
+	return held
+}
+
+// sendDeferredReplies sends the held replies as the rate limiter allows. It
+// runs until the program exits.
+func (b *bot) sendDeferredReplies() {
+	for range time.Tick(chatCooldown) {
+		b.flushDeferredReplies()
+	}
+}
+
+// flushDeferredReplies sends as many held replies as the rate limiter allows
+// right now. Replies that quiet mode holds back are dropped if a mod has
+// turned it on since they were held.
+func (b *bot) flushDeferredReplies() {
+	for b.deferred.len() > 0 && b.chatLimiter.Allow() {
+		m, ok := b.deferred.next(time.Now())
+		if !ok {
+			break
+		}
+		if m.quietable && b.isQuiet() {
+			log.Printf("[quiet in #%v] %v", m.channel, m.msg)
+			continue
+		}
+		b.sendChat(m.channel, m.parentID, m.msg)
+	}
+}
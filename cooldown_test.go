@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeferredReplies(t *testing.T) {
+	now := time.Now()
+	d := newDeferredReplies(cooldownWhisper, 30*time.Second)
+	if held, whisper := d.add("aerionblue", queuedChat{channel: "testing", msg: "@aerionblue: first"}, now); !held || !whisper {
+		t.Errorf("first reply: got held %v, whisper %v; want both true", held, whisper)
+	}
+	// A second reply replaces the first, without another whisper.
+	if held, whisper := d.add("AerionBlue", queuedChat{channel: "testing", msg: "@AerionBlue: second"}, now); !held || whisper {
+		t.Errorf("second reply: got held %v, whisper %v; want true, false", held, whisper)
+	}
+	d.add("usedpizza", queuedChat{channel: "testing", msg: "@usedpizza: hi"}, now)
+
+	for _, want := range []string{"@AerionBlue: second", "@usedpizza: hi"} {
+		m, ok := d.next(now)
+		if !ok || m.msg != want {
+			t.Errorf("got (%q, %v), want %q", m.msg, ok, want)
+		}
+	}
+	if _, ok := d.next(now); ok {
+		t.Error("expected no more replies")
+	}
+
+	// aerionblue already got a delayed reply in this window.
+	if held, _ := d.add("aerionblue", queuedChat{channel: "testing", msg: "@aerionblue: third"}, now.Add(10*time.Second)); held {
+		t.Error("reply within the window should not be held")
+	}
+	if held, _ := d.add("aerionblue", queuedChat{channel: "testing", msg: "@aerionblue: fourth"}, now.Add(31*time.Second)); !held {
+		t.Error("reply after the window should be held")
+	}
+}
+
+func TestDeferredRepliesRespectQuiet(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	b := newLoadTestBot(t, newFakeBackend(0))
+	b.deferred = newDeferredReplies(cooldownReply, 30*time.Second)
+	now := time.Now()
+	b.deferred.add("aerionblue", queuedChat{channel: "testing", msg: "@aerionblue: +5.00 for Moo", quietable: true}, now)
+	b.deferred.add("usedpizza", queuedChat{channel: "testing", msg: "@usedpizza: These are the options"}, now)
+	b.setQuiet(true)
+	b.flushDeferredReplies()
+
+	if got := logs.String(); !strings.Contains(got, "[quiet in #testing] @aerionblue") {
+		t.Errorf("quietable reply was not held back; logs:\n%s", got)
+	}
+	if got := logs.String(); !strings.Contains(got, "[-> #testing] @usedpizza") {
+		t.Errorf("command reply was not sent; logs:\n%s", got)
+	}
+}
+
+func TestMentionedUser(t *testing.T) {
+	for _, tc := range []struct {
+		msg    string
+		want   string
+		wantOK bool
+	}{
+		{"@aerionblue: +5.00 for Moo", "aerionblue", true},
+		{"@usedpizza These are the options", "usedpizza", true},
+		{"$5.00 donation from Bob put towards Moo.", "", false},
+		{"@: hi", "", false},
+	} {
+		got, ok := mentionedUser(tc.msg)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("mentionedUser(%q): got (%q, %v), want (%q, %v)", tc.msg, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestWithoutMention(t *testing.T) {
+	for _, tc := range []struct {
+		msg  string
+		want string
+	}{
+		{"@aerionblue: +5.00 for Moo", "+5.00 for Moo"},
+		{"@usedpizza These are the options", "These are the options"},
+		{"$5.00 donation from Bob put towards Moo.", "$5.00 donation from Bob put towards Moo."},
+	} {
+		if got := withoutMention(tc.msg); got != tc.want {
+			t.Errorf("withoutMention(%q): got %q, want %q", tc.msg, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+// How often to check for contests that are about to close.
+const countdownInterval = 15 * time.Second
+
+// runCountdowns warns chat before each scheduled contest closes, and closes
+// the contest when its time comes. It also announces the incentive timers. It
+// never returns.
+func (b *bot) runCountdowns(channel string, warnings []time.Duration) {
+	ticker := time.NewTicker(countdownInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for now := range ticker.C {
+		warns, due := b.collection().Countdowns(last, now, warnings)
+		b.announceTimers(channel, last, now, warnings)
+		b.announceHypeTrains(channel, now)
+		b.reportCrossCheck(now)
+		last = now
+		for _, w := range warns {
+			b.say(channel, fmt.Sprintf("%s left to bid on %s!", describeMinutes(w.Left), w.Contest.Name))
+		}
+		for _, con := range due {
+			b.autoClose(channel, con)
+		}
+	}
+}
+
+// autoClose closes a contest whose scheduled close time has passed.
+func (b *bot) autoClose(channel string, con bidwar.Contest) {
+	ok, err := b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+		return c.SetContestClosed(con.Name, true, con.ClosesAt)
+	})
+	if !ok {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR saving bid war data: %v", err)
+	}
+	log.Printf("closed %s at its scheduled time", con.Name)
+	msg := fmt.Sprintf("Bidding on %s is now closed!", con.Name) + b.assignAtClose(con, "schedule")
+	if b.bidwarTallier != nil {
+		if totals, err := b.bidwarTallier.TotalsForContest(con); err != nil {
+			log.Printf("ERROR reading final totals for %s: %v", con.Name, err)
+		} else if desc := totals.Describe(bidwar.Option{}); desc != "" {
+			msg += " Final totals: " + desc
+		}
+	}
+	b.say(channel, msg)
+}
+
+func describeMinutes(d time.Duration) string {
+	if m := int(d.Minutes()); m != 1 {
+		return fmt.Sprintf("%d minutes", m)
+	}
+	return "1 minute"
+}
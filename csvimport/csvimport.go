@@ -0,0 +1,232 @@
+// Package csvimport reads donations from the CSV files that Streamlabs and
+// StreamElements export, so that donations made before the bot was running
+// can be added to the donation table.
+//
+// The columns are found by their headers, so the order doesn't matter. The
+// donor's name, the amount, and the date are required. The currency, the
+// message, and the processing fee are optional.
+package csvimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/money"
+)
+
+// Accepted headers for each column, after normalizeHeader.
+var (
+	nameHeaders     = []string{"name", "username", "donator", "donor", "from"}
+	amountHeaders   = []string{"amount"}
+	currencyHeaders = []string{"currency"}
+	messageHeaders  = []string{"message", "comment", "note"}
+	feeHeaders      = []string{"fee", "fees", "processingfee"}
+	dateHeaders     = []string{"date", "createdat", "time", "timestamp"}
+)
+
+// The date formats used by the exports, tried in order. Dates without a time
+// zone are taken to be in UTC.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"01/02/2006 15:04:05",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 15:04",
+	"2006-01-02",
+}
+
+type columns struct {
+	name, amount, currency, message, date, fee int
+}
+
+// Parse reads a CSV export and returns its donations, oldest first. Each
+// donation is credited to the given source and Twitch channel. Rows in
+// other currencies are converted to US dollars with the given Rates, or
+// skipped with a warning if there's no exchange rate; other malformed rows
+// are errors. Donations without a fee in the file get one from the source's
+// fee schedule, if it has one.
+func Parse(r io.Reader, source donation.Source, channel string, rates donation.Rates) ([]donation.Event, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("the CSV file is empty")
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %v", err)
+	}
+	cols, err := findColumns(header)
+	if err != nil {
+		return nil, err
+	}
+	var evs []donation.Event
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %v", err)
+		}
+		if isBlank(record) {
+			continue
+		}
+		ev, err := parseRecord(record, cols)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if cur := field(record, cols.currency); !ev.SetAmount(ev.Cash.Cents(), cur, rates) {
+			log.Printf("WARNING: skipping line %d, a donation of %s %s with no exchange rate", line, field(record, cols.amount), strings.ToUpper(cur))
+			continue
+		}
+		if s := field(record, cols.fee); s != "" {
+			fee, err := money.ParseCents(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad fee: %v", line, err)
+			}
+			if err := ev.SetFee(fee, rates); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		ev.Source = source
+		ev.Channel = channel
+		ev.ApplyFeeSchedule(rates)
+		evs = append(evs, ev)
+	}
+	sort.SliceStable(evs, func(i, j int) bool { return evs[i].Time.Before(evs[j].Time) })
+	return evs, nil
+}
+
+func parseRecord(record []string, cols columns) (donation.Event, error) {
+	name := field(record, cols.name)
+	if name == "" {
+		return donation.Event{}, errors.New("missing donor name")
+	}
+	cents, err := money.ParseCents(field(record, cols.amount))
+	if err != nil {
+		return donation.Event{}, fmt.Errorf("bad amount: %v", err)
+	}
+	if cents <= 0 {
+		return donation.Event{}, fmt.Errorf("amount must be positive, got %q", field(record, cols.amount))
+	}
+	t, err := parseDate(field(record, cols.date))
+	if err != nil {
+		return donation.Event{}, err
+	}
+	return donation.Event{
+		Time:    t,
+		Owner:   name,
+		Cash:    donation.CentsValue(cents),
+		Message: field(record, cols.message),
+	}, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
+
+func findColumns(header []string) (columns, error) {
+	cols := columns{
+		name:     findColumn(header, nameHeaders),
+		amount:   findColumn(header, amountHeaders),
+		currency: findColumn(header, currencyHeaders),
+		message:  findColumn(header, messageHeaders),
+		date:     findColumn(header, dateHeaders),
+		fee:      findColumn(header, feeHeaders),
+	}
+	var missing []string
+	if cols.name < 0 {
+		missing = append(missing, "name")
+	}
+	if cols.amount < 0 {
+		missing = append(missing, "amount")
+	}
+	if cols.date < 0 {
+		missing = append(missing, "date")
+	}
+	if len(missing) > 0 {
+		return columns{}, fmt.Errorf("CSV header %q has no %s column", strings.Join(header, ","), strings.Join(missing, " or "))
+	}
+	return cols, nil
+}
+
+// findColumn returns the index of the first header that is one of the
+// accepted names, or -1.
+func findColumn(header []string, names []string) int {
+	for _, name := range names {
+		for i, h := range header {
+			if normalizeHeader(h) == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// normalizeHeader lowercases a header and drops spaces, underscores, and any
+// byte order mark, so that "Created At" and "created_at" are the same.
+func normalizeHeader(h string) string {
+	h = strings.TrimPrefix(h, "\ufeff")
+	return strings.ToLower(strings.NewReplacer(" ", "", "_", "").Replace(h))
+}
+
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func isBlank(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// SkipRecorded returns the donations that aren't already in the donation
+// table, given the table's rows (header included). A donation matches a row
+// with the same donor, description, and value. Since a donor can give the same
+// amount more than once, each row only accounts for one donation, so importing
+// the same file twice adds nothing the second time.
+func SkipRecorded(evs []donation.Event, rows [][]interface{}) []donation.Event {
+	recorded := make(map[string]int)
+	for _, row := range rows {
+		if len(row) < 3 {
+			continue
+		}
+		cents, err := money.ParseCents(fmt.Sprint(row[2]))
+		if err != nil {
+			continue
+		}
+		recorded[recordKey(fmt.Sprint(row[0]), fmt.Sprint(row[1]), cents)]++
+	}
+	var fresh []donation.Event
+	for _, ev := range evs {
+		key := recordKey(ev.Owner, ev.Description(), ev.Value().Cents())
+		if recorded[key] > 0 {
+			recorded[key]--
+			continue
+		}
+		fresh = append(fresh, ev)
+	}
+	return fresh
+}
+
+func recordKey(owner string, what string, cents int) string {
+	return fmt.Sprintf("%s\x00%s\x00%d", strings.ToLower(owner), what, cents)
+}
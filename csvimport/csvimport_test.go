@@ -0,0 +1,91 @@
+package csvimport
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		csv     string
+		want    []donation.Event
+		wantErr bool
+	}{
+		{
+			"streamlabs style",
+			"Name,Amount,Currency,Message,Date\n" +
+				"ShartyMcFly,11.00,USD,team mid,2022-07-01 20:15:00\n" +
+				"NutDealer,$5,USD,,2022-07-01 19:00:00\n",
+			[]donation.Event{
+				{Source: donation.SourceStreamlabs, Time: time.Date(2022, 7, 1, 19, 0, 0, 0, time.UTC), Owner: "NutDealer", Channel: "usedpizza", Cash: 500},
+				{Source: donation.SourceStreamlabs, Time: time.Date(2022, 7, 1, 20, 15, 0, 0, time.UTC), Owner: "ShartyMcFly", Channel: "usedpizza", Cash: 1100, Message: "team mid"},
+			},
+			false,
+		},
+		{
+			"streamelements style",
+			"\ufeffcreated_at,username,amount,message\n" +
+				"2022-07-01T20:15:00Z,ShartyMcFly,\"1,100\",team mid\n",
+			[]donation.Event{
+				{Source: donation.SourceStreamlabs, Time: time.Date(2022, 7, 1, 20, 15, 0, 0, time.UTC), Owner: "ShartyMcFly", Channel: "usedpizza", Cash: 110000, Message: "team mid"},
+			},
+			false,
+		},
+		{
+			"skips other currencies and blank lines",
+			"Name,Amount,Currency,Date\nAnon,5,CAD,2022-07-01\n,,,\nBob,5,usd,2022-07-01\n",
+			[]donation.Event{
+				{Source: donation.SourceStreamlabs, Time: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), Owner: "Bob", Channel: "usedpizza", Cash: 500},
+			},
+			false,
+		},
+		{
+			"fees",
+			"Name,Amount,Fee,Date\nBob,10.00,0.59,2022-07-01\nAnon,5,,2022-07-01\n",
+			[]donation.Event{
+				{Source: donation.SourceStreamlabs, Time: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), Owner: "Bob", Channel: "usedpizza", Cash: 1000, Fee: 59},
+				{Source: donation.SourceStreamlabs, Time: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), Owner: "Anon", Channel: "usedpizza", Cash: 500},
+			},
+			false,
+		},
+		{"bad fee", "Name,Amount,Fee,Date\nBob,5,lots,2022-07-01\n", nil, true},
+		{"missing column", "Name,Message,Date\nBob,hi,2022-07-01\n", nil, true},
+		{"bad amount", "Name,Amount,Date\nBob,five,2022-07-01\n", nil, true},
+		{"bad date", "Name,Amount,Date\nBob,5,yesterday\n", nil, true},
+		{"empty", "", nil, true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tc.csv), donation.SourceStreamlabs, "usedpizza", donation.Rates{})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := deep.Equal(got, tc.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSkipRecorded(t *testing.T) {
+	rows := [][]interface{}{
+		{"Contributor", "What", "Points", "Choice", "Message"},
+		{"nutdealer", "$5.00 donation", 5.0, "Moo", "[tip] moo"},
+		{"aerionblue", "resub", 6.0},
+	}
+	evs := []donation.Event{
+		{Owner: "NutDealer", Cash: 500},
+		{Owner: "NutDealer", Cash: 500},
+		{Owner: "NutDealer", Cash: 1000},
+	}
+	got := SkipRecorded(evs, rows)
+	want := []donation.Event{{Owner: "NutDealer", Cash: 500}, {Owner: "NutDealer", Cash: 1000}}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+}
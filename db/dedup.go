@@ -0,0 +1,114 @@
+package db
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// ErrDuplicate is returned by a Deduplicator for a donation that was already
+// recorded.
+var ErrDuplicate = errors.New("donation was already recorded")
+
+// Deduplicator wraps a Recorder so that a donation with an ID is only
+// recorded once, even if its source delivers it again (e.g., after a
+// reconnect or a poller restart). Donations without an ID are always
+// recorded. If a file is given, the IDs of the recorded donations are kept
+// there, so that they're remembered across restarts.
+type Deduplicator struct {
+	rec  Recorder
+	path string
+
+	mu sync.Mutex
+	// The keys of the donations that were recorded, or are being recorded.
+	seen map[string]bool
+}
+
+// NewDeduplicator creates a Deduplicator that records donations with rec. If
+// path isn't empty, the IDs that were recorded before are read from it.
+func NewDeduplicator(rec Recorder, path string) (*Deduplicator, error) {
+	d := &Deduplicator{rec: rec, path: path, seen: make(map[string]bool)}
+	if path == "" {
+		return d, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error opening recorded IDs: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key := scanner.Text(); key != "" {
+			d.seen[key] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading recorded IDs: %v", err)
+	}
+	log.Printf("%d donations were already recorded", len(d.seen))
+	return d, nil
+}
+
+// RecordDonation records the donation, unless it was already recorded, in
+// which case it returns ErrDuplicate.
+func (d *Deduplicator) RecordDonation(ev donation.Event, bid bidwar.Choice) error {
+	key := eventKey(ev)
+	if key == "" {
+		return d.rec.RecordDonation(ev, bid)
+	}
+	d.mu.Lock()
+	if d.seen[key] {
+		d.mu.Unlock()
+		return ErrDuplicate
+	}
+	// Claim the key before recording, so that a redelivery that arrives in
+	// the meantime isn't recorded too.
+	d.seen[key] = true
+	d.mu.Unlock()
+
+	if err := d.rec.RecordDonation(ev, bid); err != nil {
+		d.mu.Lock()
+		delete(d.seen, key)
+		d.mu.Unlock()
+		return err
+	}
+	if err := d.save(key); err != nil {
+		log.Printf("ERROR saving the ID of %s's donation: %v", ev.Owner, err)
+	}
+	return nil
+}
+
+// save appends a key to the file of recorded IDs, if there is one.
+func (d *Deduplicator) save(key string) error {
+	if d.path == "" {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, key); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// eventKey identifies a donation across sources, or returns "" if the
+// donation has no ID.
+func eventKey(ev donation.Event) string {
+	if ev.ID == "" {
+		return ""
+	}
+	return string(ev.Source) + ":" + ev.ID
+}
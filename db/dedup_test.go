@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestDeduplicator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.txt")
+	rec := &flakyRecorder{failFor: map[string]bool{"bob": true}}
+	d, err := NewDeduplicator(rec, path)
+	if err != nil {
+		t.Fatalf("error creating deduplicator: %v", err)
+	}
+	alice := donation.Event{Source: donation.SourceStreamlabs, ID: "1000", Owner: "alice"}
+	bob := donation.Event{Source: donation.SourceStreamlabs, ID: "2000", Owner: "bob"}
+	anon := donation.Event{Source: donation.SourceTwitchSub, Owner: "anon"}
+
+	if err := d.RecordDonation(alice, bidwar.Choice{}); err != nil {
+		t.Errorf("recording alice's donation: got error %v", err)
+	}
+	if err := d.RecordDonation(alice, bidwar.Choice{}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("recording alice's donation again: got error %v, want ErrDuplicate", err)
+	}
+	// The same ID from another source is a different donation.
+	other := alice
+	other.Source = donation.SourceStreamElements
+	if err := d.RecordDonation(other, bidwar.Choice{}); err != nil {
+		t.Errorf("recording a StreamElements donation with the same ID: got error %v", err)
+	}
+	// A donation that failed to record can be retried.
+	if err := d.RecordDonation(bob, bidwar.Choice{}); err == nil || errors.Is(err, ErrDuplicate) {
+		t.Errorf("recording bob's donation: got error %v, want the recorder's error", err)
+	}
+	delete(rec.failFor, "bob")
+	if err := d.RecordDonation(bob, bidwar.Choice{}); err != nil {
+		t.Errorf("retrying bob's donation: got error %v", err)
+	}
+	// Donations without IDs are always recorded.
+	for i := 0; i < 2; i++ {
+		if err := d.RecordDonation(anon, bidwar.Choice{}); err != nil {
+			t.Errorf("recording a donation without an ID: got error %v", err)
+		}
+	}
+	want := []string{"alice:", "alice:", "bob:", "anon:", "anon:"}
+	if !reflect.DeepEqual(rec.recorded, want) {
+		t.Errorf("got recorded donations %v, want %v", rec.recorded, want)
+	}
+
+	// The IDs are remembered across restarts.
+	rec = &flakyRecorder{}
+	d, err = NewDeduplicator(rec, path)
+	if err != nil {
+		t.Fatalf("error reloading deduplicator: %v", err)
+	}
+	for _, ev := range []donation.Event{alice, other, bob} {
+		if err := d.RecordDonation(ev, bidwar.Choice{}); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("after restart, recording %s's donation from %s: got error %v, want ErrDuplicate", ev.Owner, ev.Source, err)
+		}
+	}
+	if len(rec.recorded) != 0 {
+		t.Errorf("after restart, got recorded donations %v, want none", rec.recorded)
+	}
+}
@@ -11,9 +11,13 @@ import (
 	"github.com/aerionblue/pizzafest/donation"
 )
 
+// The collection that donations are recorded in by default.
+const defaultCollection = "donations"
+
 type firestoreClient struct {
-	client *firestore.Client
-	now    func() time.Time
+	client     *firestore.Client
+	collection string
+	now        func() time.Time
 }
 
 func NewFirestoreClient(ctx context.Context, credsPath string) (*firestoreClient, error) {
@@ -25,20 +29,33 @@ func NewFirestoreClient(ctx context.Context, credsPath string) (*firestoreClient
 	if err != nil {
 		return nil, err
 	}
-	return &firestoreClient{client: client, now: time.Now}, nil
+	return &firestoreClient{client: client, collection: defaultCollection, now: time.Now}, nil
+}
+
+// InCollection returns a client that records donations in the named
+// collection instead, sharing the same connection.
+func (c *firestoreClient) InCollection(name string) Recorder {
+	return &firestoreClient{client: c.client, collection: name, now: c.now}
 }
 
 func (c *firestoreClient) RecordDonation(ev donation.Event, bid bidwar.Choice) error {
-	donations := c.client.Collection("donations")
+	donations := c.client.Collection(c.collection)
 	doc := donationDoc{
 		ISOTimestamp: c.now().UTC().Format(time.RFC3339Nano),
 		Owner:        ev.Owner,
+		OwnerID:      ev.OwnerID,
+		DonationTime: isoTime(ev.Time),
 		Value:        ev.Value().Cents(),
 		SubCount:     ev.SubCount,
 		SubTier:      ev.SubTier.Marshal(),
 		SubMonths:    ev.SubMonths,
+		SubValue:     ev.SubValue.Cents(),
 		Cents:        ev.Cash.Cents(),
+		Currency:     ev.Currency,
+		Original:     ev.OriginalCents,
+		Fee:          ev.Fee.Cents(),
 		Bits:         ev.Bits,
+		Raiders:      ev.Raiders,
 		BidwarChoice: bid.Option.ShortCode,
 	}
 	// TODO(aerion): Plumb through a context from the IRC bot.
@@ -48,14 +65,31 @@ func (c *firestoreClient) RecordDonation(ev donation.Event, bid bidwar.Choice) e
 
 // donationDoc is a Firestore document representing a donation.Event.
 type donationDoc struct {
+	// When the donation was recorded.
 	ISOTimestamp string `firestore:"timestamp"`
+	// When the donation was made, if known.
+	DonationTime string `firestore:"donationTime,omitempty"`
 	Owner        string `firestore:"owner"`
+	OwnerID      string `firestore:"ownerId,omitempty"`
 	Value        int    `firestore:"value"`
 	SubCount     int    `firestore:"subCount,omitempty"`
 	SubTier      int    `firestore:"subTier,omitempty"`
 	SubMonths    int    `firestore:"subMonths,omitempty"`
+	SubValue     int    `firestore:"subValue,omitempty"`
 	Cents        int    `firestore:"cents,omitempty"`
+	Currency     string `firestore:"currency,omitempty"`
+	Original     int    `firestore:"originalCents,omitempty"`
+	Fee          int    `firestore:"feeCents,omitempty"`
 	Bits         int    `firestore:"bits,omitempty"`
+	Raiders      int    `firestore:"raiders,omitempty"`
 	BidwarChoice string `firestore:"bidwarChoice,omitempty"`
 	Message      string `firestore:"message,omitempty"`
 }
+
+// isoTime formats t like the document timestamps, or returns "" if t is zero.
+func isoTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Journal is a local file of donations that couldn't be written to the
+// database. The entries can be retried later with Replay.
+type Journal struct {
+	path string
+	now  func() time.Time
+
+	mu sync.Mutex
+}
+
+// journalEntry is one line of the journal file.
+type journalEntry struct {
+	Time   time.Time      `json:"time"`
+	Event  donation.Event `json:"event"`
+	Choice string         `json:"choice,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// NewJournal creates a Journal that stores its entries in the given file.
+func NewJournal(path string) *Journal {
+	return &Journal{path: path, now: time.Now}
+}
+
+// Add appends a donation to the journal. cause is the error that prevented
+// the donation from being recorded.
+func (j *Journal) Add(ev donation.Event, bid bidwar.Choice, cause error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e := journalEntry{
+		Time:   j.now(),
+		Event:  ev,
+		Choice: bid.Option.ShortCode,
+		Reason: bid.Reason,
+	}
+	if cause != nil {
+		e.Error = cause.Error()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open journal: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write to journal: %v", err)
+	}
+	return nil
+}
+
+// Replay tries to record every donation in the journal again. Donations that
+// are recorded successfully are removed from the journal; the rest are kept
+// for next time. Returns the number of donations that were recorded.
+func (j *Journal) Replay(rec Recorder) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries, err := j.read()
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	var remaining []journalEntry
+	for _, e := range entries {
+		bid := bidwar.Choice{Option: bidwar.Option{ShortCode: e.Choice}, Reason: e.Reason}
+		// A duplicate was recorded after all, so it's done.
+		if err := rec.RecordDonation(e.Event, bid); err != nil && !errors.Is(err, ErrDuplicate) {
+			e.Error = err.Error()
+			remaining = append(remaining, e)
+		}
+	}
+	if err := j.write(remaining); err != nil {
+		return 0, err
+	}
+	return len(entries) - len(remaining), nil
+}
+
+func (j *Journal) read() ([]journalEntry, error) {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not open journal: %v", err)
+	}
+	defer f.Close()
+	var entries []journalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Printf("ERROR skipping malformed journal entry %q: %v", scanner.Text(), err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// write replaces the contents of the journal with the given entries.
+func (j *Journal) write(entries []journalEntry) error {
+	var data []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+	// Write to a temp file first, so we don't lose the journal if we crash.
+	tmp := j.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("could not write journal: %v", err)
+	}
+	return os.Rename(tmp, j.path)
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// flakyRecorder fails to record donations from the owners in failFor.
+type flakyRecorder struct {
+	failFor  map[string]bool
+	recorded []string
+}
+
+func (r *flakyRecorder) RecordDonation(ev donation.Event, bid bidwar.Choice) error {
+	if r.failFor[ev.Owner] {
+		return errors.New("sheet is down")
+	}
+	r.recorded = append(r.recorded, ev.Owner+":"+bid.Option.ShortCode)
+	return nil
+}
+
+func TestJournalReplay(t *testing.T) {
+	j := NewJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	cause := errors.New("sheet is down")
+	for _, owner := range []string{"alice", "bob", "carol"} {
+		ev := donation.Event{Owner: owner, Cash: donation.CentsValue(500)}
+		bid := bidwar.Choice{Option: bidwar.Option{ShortCode: "Moo"}, Reason: "[chat] moo"}
+		if err := j.Add(ev, bid, cause); err != nil {
+			t.Fatalf("error adding to journal: %v", err)
+		}
+	}
+
+	rec := &flakyRecorder{failFor: map[string]bool{"bob": true}}
+	n, err := j.Replay(rec)
+	if err != nil {
+		t.Fatalf("error replaying journal: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d replayed donations, want 2", n)
+	}
+
+	// Only bob's donation should be left.
+	rec = &flakyRecorder{}
+	n, err = j.Replay(rec)
+	if err != nil {
+		t.Fatalf("error replaying journal: %v", err)
+	}
+	if n != 1 || len(rec.recorded) != 1 || rec.recorded[0] != "bob:Moo" {
+		t.Errorf("second replay: got %d (%v), want only bob's donation", n, rec.recorded)
+	}
+
+	n, err = j.Replay(rec)
+	if err != nil || n != 0 {
+		t.Errorf("replaying an empty journal: got (%d, %v), want (0, nil)", n, err)
+	}
+}
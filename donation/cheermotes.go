@@ -0,0 +1,56 @@
+package donation
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The prefixes of Twitch's global cheermotes, lowercased. A cheermote is its
+// prefix followed by the number of bits, e.g. "Cheer100" or "Pride100".
+var cheermotePrefixes = map[string]bool{
+	"cheer": true, "doodlecheer": true, "biblethump": true, "cheerwhal": true,
+	"corgo": true, "uni": true, "showlove": true, "party": true,
+	"seemsgood": true, "pride": true, "kappa": true, "frankerz": true,
+	"heyguys": true, "dansgame": true, "elegiggle": true, "trihard": true,
+	"kreygasm": true, "4head": true, "swiftrage": true, "notlikethis": true,
+	"failfish": true, "vohiyo": true, "pjsalt": true, "mrdestructoid": true,
+	"bday": true, "ripcheer": true, "shamrock": true, "bitboss": true,
+	"streamlabs": true, "muxy": true, "holidaycheer": true, "goal": true,
+	"anon": true, "charity": true,
+}
+
+var (
+	wordRE      = regexp.MustCompile(`\S+`)
+	cheermoteRE = regexp.MustCompile(`^(\S+?)([0-9]+)$`)
+	spacesRE    = regexp.MustCompile(`[ \t]{2,}`)
+)
+
+// StripCheermotes removes the cheermotes from a bits message, so that they
+// aren't mistaken for bids (e.g., "Pride100" for an option called "pride").
+// Besides Twitch's global cheermotes, it removes the channel's custom ones,
+// given by their prefixes (e.g., "pizza" for "pizza100").
+func StripCheermotes(msg string, customPrefixes []string) string {
+	stripped := wordRE.ReplaceAllStringFunc(msg, func(word string) string {
+		if isCheermote(word, customPrefixes) {
+			return ""
+		}
+		return word
+	})
+	return strings.TrimSpace(spacesRE.ReplaceAllString(stripped, " "))
+}
+
+func isCheermote(word string, customPrefixes []string) bool {
+	m := cheermoteRE.FindStringSubmatch(word)
+	if m == nil {
+		return false
+	}
+	if cheermotePrefixes[strings.ToLower(m[1])] {
+		return true
+	}
+	for _, p := range customPrefixes {
+		if strings.EqualFold(m[1], p) {
+			return true
+		}
+	}
+	return false
+}
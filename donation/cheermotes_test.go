@@ -0,0 +1,27 @@
+package donation
+
+import "testing"
+
+func TestStripCheermotes(t *testing.T) {
+	for _, tc := range []struct {
+		msg, want string
+	}{
+		{"Cheer100 dmc3", "dmc3"},
+		{"pride Pride100", "pride"},
+		{"Cheer50 Cheer50 team mid cheer100", "team mid"},
+		{"pizza500 moo", "moo"},
+		{"4Head10 rr", "rr"},
+		{"cheerio100 rr", "cheerio100 rr"},
+		{"Cheer rr", "Cheer rr"},
+		{"ShowLove100\nall on rr", "all on rr"},
+		{"", ""},
+	} {
+		if got := StripCheermotes(tc.msg, []string{"Pizza"}); got != tc.want {
+			t.Errorf("StripCheermotes(%q): got %q, want %q", tc.msg, got, tc.want)
+		}
+	}
+	// Without the custom prefix, pizza500 is just a word.
+	if got := StripCheermotes("pizza500 moo", nil); got != "pizza500 moo" {
+		t.Errorf("StripCheermotes without custom prefixes: got %q", got)
+	}
+}
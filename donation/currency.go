@@ -0,0 +1,41 @@
+package donation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/aerionblue/pizzafest/money"
+)
+
+// SetAmount sets the donation's Cash from an amount in hundredths of the
+// given currency (e.g., 1250 for 12.50 EUR), converted to US cents. An empty
+// currency means US dollars. The original amount is kept in Currency and
+// OriginalCents. Returns false, leaving the event alone, if the Rates have
+// no exchange rate for the currency.
+func (e *Event) SetAmount(hundredths int, currency string, rates Rates) bool {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == "USD" {
+		e.Cash = CentsValue(hundredths)
+		e.Currency = ""
+		e.OriginalCents = 0
+		return true
+	}
+	rate, ok := rates.exchangeRate(currency)
+	if !ok {
+		return false
+	}
+	e.Cash = CentsValue(math.Round(float64(hundredths) * rate))
+	e.Currency = currency
+	e.OriginalCents = hundredths
+	return true
+}
+
+// originalAmount describes the amount that the donor paid, e.g. "12.50 EUR",
+// or "" if they paid in US dollars.
+func (e Event) originalAmount() string {
+	if e.Currency == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s %s", money.FormatCents(e.OriginalCents), e.Currency)
+}
@@ -0,0 +1,30 @@
+package donation
+
+import "testing"
+
+func TestSetAmount(t *testing.T) {
+	rates := Rates{ExchangeRates: map[string]float64{"EUR": 1.08, "JPY": 0.0067}}
+	for _, tc := range []struct {
+		hundredths   int
+		currency     string
+		wantOK       bool
+		wantCash     CentsValue
+		wantCurrency string
+		wantDesc     string
+	}{
+		{1250, "USD", true, 1250, "", "$12.50 donation"},
+		{1250, "", true, 1250, "", "$12.50 donation"},
+		{1250, "EUR", true, 1350, "EUR", "$13.50 donation (12.50 EUR)"},
+		{50000, "jpy", true, 335, "JPY", "$3.35 donation (500.00 JPY)"},
+		{1250, "XYZ", false, 0, "", ""},
+	} {
+		var ev Event
+		ok := ev.SetAmount(tc.hundredths, tc.currency, rates)
+		if ok != tc.wantOK || ev.Cash != tc.wantCash || ev.Currency != tc.wantCurrency || ev.Description() != tc.wantDesc {
+			t.Errorf("SetAmount(%d, %q): got (%v, %v, %q, %q), want (%v, %v, %q, %q)", tc.hundredths, tc.currency, ok, ev.Cash, ev.Currency, ev.Description(), tc.wantOK, tc.wantCash, tc.wantCurrency, tc.wantDesc)
+		}
+		if ok && tc.wantCurrency != "" && ev.OriginalCents != tc.hundredths {
+			t.Errorf("SetAmount(%d, %q): got original amount %d, want %d", tc.hundredths, tc.currency, ev.OriginalCents, tc.hundredths)
+		}
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/money"
 )
 
 // USERNOTICE message param tag names. See https://dev.twitch.tv/docs/irc/tags for param descriptions.
@@ -38,8 +41,20 @@ const (
 	Subscription
 	GiftSubscription
 	CommunityGift
+	// A user started paying for a sub that they were gifted.
+	GiftUpgrade
+	// A user started paying for a sub that they had through Prime.
+	PrimeUpgrade
+	// A user upgraded to a paid sub some other way.
+	StandardUpgrade
 )
 
+// isUpgrade reports whether t is one of the upgrade types, which are worth a
+// configured amount rather than the usual tier 1 value.
+func (t SubEventType) isUpgrade() bool {
+	return t == GiftUpgrade || t == PrimeUpgrade || t == StandardUpgrade
+}
+
 type SubTier int
 
 const (
@@ -98,9 +113,82 @@ func parseSubTier(s string) SubTier {
 	return unknownTier
 }
 
+// Source identifies where a donation came from.
+type Source string
+
+const (
+	SourceUnknown        Source = ""
+	SourceTwitchSub      Source = "twitch_sub"
+	SourceTwitchBits     Source = "twitch_bits"
+	SourceStreamlabs     Source = "streamlabs"
+	SourceStreamElements Source = "streamelements"
+	SourceTipFile        Source = "tipfile"
+	// Entered by hand (e.g., by a mod).
+	SourceManual Source = "manual"
+	// A bonus credited when a Hype Train ends.
+	SourceHypeTrain Source = "hype_train"
+	// A bonus for a Hype Train that counts as money, e.g., because a sponsor
+	// matches it.
+	SourceHypeTrainMatch Source = "hype_train_match"
+	// A Twitch channel point reward redemption.
+	SourceChannelPoints Source = "channel_points"
+	// Another channel raided this one.
+	SourceTwitchRaid Source = "twitch_raid"
+)
+
+// IsCredit reports whether the Source's donations are bid war credit rather
+// than money (e.g., channel points), so that they don't count towards the
+// amount raised.
+func (s Source) IsCredit() bool {
+	switch s {
+	case SourceHypeTrain, SourceChannelPoints, SourceTwitchRaid:
+		return true
+	}
+	return false
+}
+
+// DisplayName returns a human-readable name for the Source.
+func (s Source) DisplayName() string {
+	switch s {
+	case SourceTwitchSub:
+		return "Twitch subs"
+	case SourceTwitchBits:
+		return "Twitch bits"
+	case SourceStreamlabs:
+		return "Streamlabs"
+	case SourceStreamElements:
+		return "StreamElements"
+	case SourceTipFile:
+		return "Tip file"
+	case SourceManual:
+		return "Manual"
+	case SourceHypeTrain:
+		return "Hype Train bonus"
+	case SourceHypeTrainMatch:
+		return "Hype Train match"
+	case SourceChannelPoints:
+		return "Channel points"
+	case SourceTwitchRaid:
+		return "Twitch raids"
+	}
+	return "Unknown"
+}
+
 type Event struct {
+	// Where this donation came from.
+	Source Source
+	// The donation's ID from its source (e.g., the StreamElements activity
+	// ID or the chat message ID), so that it isn't recorded twice if the
+	// source delivers it again. Empty if the source doesn't have one.
+	ID string
+	// When the donation was made, according to the source. If the source
+	// doesn't say, this is when we received it.
+	Time time.Time
 	// Twitch username of the user who gets credit for this donation.
 	Owner string
+	// The owner's Twitch user ID, if known. Unlike the username, it stays the
+	// same when the user renames themselves.
+	OwnerID string
 	// Twitch channel to which this donation was given.
 	Channel string
 	// The type of subscription (if this event is a sub event).
@@ -113,34 +201,65 @@ type Event struct {
 	// How many months were purchased at once. Used for multi-month gifts. Equal
 	// to 1 for non-gifted subs.
 	SubMonths int
+	// How much one month of one of the subs is worth, in cents. Set by the
+	// parsers from their Rates (see Rates.SubValue and SetSubValue). If it's 0
+	// and wasn't set (e.g., for an event saved before sub values were
+	// recorded), the subs are worth the default for their tier.
+	SubValue CentsValue
+	// Whether the subs are worth nothing because the Rates say so, as
+	// opposed to SubValue being unknown.
+	FreeSubs bool
 	// The number of bits donated.
 	Bits int
-	// The number of US cents donated.
+	// The number of US cents donated. For a donation in another currency,
+	// this is the converted value; see SetAmount.
 	Cash CentsValue
+	// The currency that the donor paid in (e.g., "EUR"), if it wasn't US
+	// dollars.
+	Currency string
+	// The amount that the donor paid, in hundredths of Currency. Only set if
+	// Currency is.
+	OriginalCents int
+	// The payment processor's fee, in US cents, if known. It's part of Cash;
+	// see NetCash.
+	Fee CentsValue
+	// For a channel point redemption, the ID of the reward that was redeemed.
+	Reward string
+	// For a channel point redemption or a raid, how much it's worth towards
+	// the bid wars. 0 if it only counts as a vote, or (for a raid) if it's
+	// only recorded for the thank-you list.
+	Credit CentsValue
+	// For a raid, the number of raiders.
+	Raiders int
 	// The chat message included with the event.
 	Message string
+	// The ID of the chat message that carried the donation (for bits), so
+	// that the bot can reply to it. Empty for other sources.
+	MessageID string
+	// The campaign (e.g., "Friday night block") that was running when the
+	// donation was recorded, if any.
+	Campaign string
 }
 
 // CentsValue returns the value that this event should contribute to a bid war,
 // in US cents.
 func (e Event) Value() CentsValue {
-	return CentsValue(e.SubCentsValue() + e.Bits + e.Cash.Cents())
+	return CentsValue(e.SubCentsValue() + e.Bits + e.Cash.Cents() + e.Credit.Cents())
 }
 
 // SubCentsValue returns this event's equivalent value in cents.
 func (e Event) SubCentsValue() int {
-	baseValue := 0
-	switch e.SubTier {
-	case SubTierPrime:
-		baseValue = 500
-	case SubTier1:
-		baseValue = 600
-	case SubTier2:
-		baseValue = 1200
-	case SubTier3:
-		baseValue = 2500
+	v := e.SubValue
+	if v == 0 && !e.FreeSubs {
+		v = Rates{}.SubValue(e)
 	}
-	return baseValue * e.SubMonths * e.SubCount
+	return v.Cents() * e.SubMonths * e.SubCount
+}
+
+// SetSubValue sets SubValue, and FreeSubs if the subs are worth nothing.
+func (e *Event) SetSubValue(v CentsValue) {
+	e.SubValue = v
+	e.FreeSubs = v == 0
 }
 
 // Description returns a human-readable description of the event.
@@ -148,18 +267,34 @@ func (e Event) Description() string {
 	// In practice, it's not possible for more than one of bits/dollars/subs
 	// to occur in the same Event, but we still handle it.
 	var parts []string
-	if e.Cash.Cents() > 0 {
-		parts = append(parts, fmt.Sprintf("$%s donation", e.Cash))
+	if e.Cash.Cents() > 0 && e.Source != SourceHypeTrainMatch {
+		if orig := e.originalAmount(); orig != "" {
+			parts = append(parts, fmt.Sprintf("$%s donation (%s)", e.Cash.Exact(), orig))
+		} else {
+			parts = append(parts, fmt.Sprintf("$%s donation", e.Cash.Exact()))
+		}
 	}
 	if e.Bits > 0 {
 		parts = append(parts, fmt.Sprintf("%d bits", e.Bits))
 	}
+	if e.Reward != "" {
+		parts = append(parts, RedemptionDescription)
+	}
+	if e.Source == SourceTwitchRaid {
+		parts = append(parts, fmt.Sprintf("raid with %d viewers", e.Raiders))
+	}
+	if e.Source == SourceHypeTrain {
+		parts = append(parts, fmt.Sprintf("%s-point Hype Train bonus", e.Credit.Exact()))
+	}
+	if e.Source == SourceHypeTrainMatch {
+		parts = append(parts, fmt.Sprintf("$%s Hype Train bonus", e.Cash.Exact()))
+	}
 	if e.SubCount > 0 {
 		var subParts []string
 		if e.SubCount > 1 {
 			subParts = append(subParts, fmt.Sprintf("%dx", e.SubCount))
 		}
-		if e.SubTier != SubTier1 {
+		if e.SubTier != SubTier1 && !(e.Type.isUpgrade() && e.SubTier == unknownTier) {
 			subParts = append(subParts, e.SubTier.description())
 		}
 		switch e.Type {
@@ -167,21 +302,28 @@ func (e Event) Description() string {
 			subParts = append(subParts, "sub")
 		case GiftSubscription, CommunityGift:
 			subParts = append(subParts, "gift sub")
+		case GiftUpgrade:
+			subParts = append(subParts, "continued gift sub")
+		case PrimeUpgrade, StandardUpgrade:
+			subParts = append(subParts, "upgraded sub")
 		}
 		parts = append(parts, strings.Join(subParts, " "))
 	}
 	return strings.Join(parts, " + ")
 }
 
-// ParseSubEvent parses a USERNOTICE message into an Event. Returns (Event{}, false) if the message does not represent a subscription.
-func ParseSubEvent(m twitch.UserNoticeMessage) (Event, bool) {
+// ParseSubEvent parses a USERNOTICE message into an Event, whose subs are
+// valued by the given Rates. Returns (Event{}, false) if the message does not
+// represent a subscription.
+func ParseSubEvent(m twitch.UserNoticeMessage, rates Rates) (Event, bool) {
 	eventType := toSubEventType(m.MsgID)
 	if eventType == unknown {
 		return Event{}, false
 	}
 
 	ev := Event{
-		Owner: m.User.Name, Channel: m.Channel,
+		Source: SourceTwitchSub, ID: m.ID, Time: m.Time,
+		Owner: m.User.Name, OwnerID: m.User.ID, Channel: m.Channel,
 		Type: eventType, SubCount: 1, SubMonths: 1,
 		Message: m.Message,
 	}
@@ -218,6 +360,7 @@ func ParseSubEvent(m twitch.UserNoticeMessage) (Event, bool) {
 		// carry the original gift month count. Each event should only count for 1 month.
 		ev.SubMonths = 1
 	}
+	ev.SetSubValue(rates.SubValue(ev))
 	return ev, true
 }
 
@@ -230,8 +373,13 @@ func toSubEventType(msgID string) SubEventType {
 		return GiftSubscription
 	case "submysterygift":
 		return CommunityGift
+	case "giftpaidupgrade", "anongiftpaidupgrade":
+		return GiftUpgrade
+	case "primepaidupgrade":
+		return PrimeUpgrade
+	case "standardpaidupgrade":
+		return StandardUpgrade
 	}
-	// TODO(aerion): Maybe handle "giftpaidupgrade", "anongiftpaidupgrade" if they actually happen.
 	return unknown
 }
 
@@ -239,15 +387,57 @@ func ParseBitsEvent(m twitch.PrivateMessage) (Event, bool) {
 	if m.Bits <= 0 {
 		return Event{}, false
 	}
-	return Event{Owner: m.User.Name, Channel: m.Channel, Bits: m.Bits, Message: m.Message}, true
+	return Event{Source: SourceTwitchBits, ID: m.ID, Time: m.Time, Owner: m.User.Name, OwnerID: m.User.ID, Channel: m.Channel, Bits: m.Bits, Message: m.Message, MessageID: m.ID}, true
+}
+
+// The tag on a chat message that was sent by redeeming a channel point reward
+// (one that asks the viewer for text).
+const customRewardIDTag = "custom-reward-id"
+
+// How a channel point redemption is described in the donation table.
+const RedemptionDescription = "channel point redemption"
+
+// RewardID returns the ID of the channel point reward whose redemption sent a
+// chat message, or "" if the message wasn't sent by a redemption. Only
+// rewards that ask for text show up in chat. The redemptions themselves come
+// from EventSub, which reports all of them.
+func RewardID(m twitch.PrivateMessage) string {
+	return m.Tags[customRewardIDTag]
+}
+
+// ParseRaidEvent parses a raid USERNOTICE into an Event, so that the raid can
+// be recorded like a donation. The Event is worth nothing until its Credit is
+// set. Returns (Event{}, false) if the message isn't a raid.
+func ParseRaidEvent(m twitch.UserNoticeMessage) (Event, bool) {
+	n, ok := ParseNotice(m)
+	if !ok || n.Type != Raid {
+		return Event{}, false
+	}
+	return Event{Source: SourceTwitchRaid, ID: m.ID, Time: m.Time, Owner: m.User.Name, OwnerID: m.User.ID, Channel: m.Channel, Raiders: n.Count}, true
 }
 
 // Value is the value of a donation.
 type CentsValue int
 
-// String expresses the value in points, with 2 decimal places.
+// How CentsValue.String formats values. Only set at startup.
+var displayPrecision = money.DefaultPrecision
+
+// SetDisplayPrecision sets how values are formatted for display. It must be
+// called before any donations are handled.
+func SetDisplayPrecision(p money.Precision) {
+	displayPrecision = p
+}
+
+// String expresses the value in points, rounded to the display precision (2
+// decimal places by default).
 func (v CentsValue) String() string {
-	return fmt.Sprintf("%0.2f", v.Points())
+	return displayPrecision.Format(int(v))
+}
+
+// Exact expresses the value in points, with 2 decimal places, regardless of
+// the display precision.
+func (v CentsValue) Exact() string {
+	return money.FormatCents(int(v))
 }
 
 // Points converts the value to Pizza Fest points (one point per dollar).
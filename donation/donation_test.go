@@ -1,7 +1,14 @@
 package donation
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/money"
 )
 
 func TestValue(t *testing.T) {
@@ -9,15 +16,12 @@ func TestValue(t *testing.T) {
 		ev   Event
 		want CentsValue
 	}{
-		{Event{SubTier: SubTierPrime, SubCount: 1, SubMonths: 1}, 500},
-		{Event{SubTier: SubTier1, SubCount: 1, SubMonths: 1}, 600},
-		{Event{SubTier: SubTier1, SubCount: 5, SubMonths: 1}, 3000},
-		{Event{SubTier: SubTier1, SubCount: 1, SubMonths: 6}, 3600},
-		{Event{SubTier: SubTier1, SubCount: 5, SubMonths: 6}, 18000},
+		{Event{SubTier: SubTier1, SubValue: 600, SubCount: 1, SubMonths: 1}, 600},
+		{Event{SubTier: SubTier1, SubValue: 600, SubCount: 5, SubMonths: 6}, 18000},
+		{Event{SubTier: SubTierPrime, SubValue: 0, FreeSubs: true, SubCount: 1, SubMonths: 1}, 0},
+		// Events saved before sub values were recorded get the defaults.
 		{Event{SubTier: SubTier2, SubCount: 1, SubMonths: 1}, 1200},
-		{Event{SubTier: SubTier2, SubCount: 1, SubMonths: 6}, 7200},
-		{Event{SubTier: SubTier3, SubCount: 1, SubMonths: 1}, 2500},
-		{Event{SubTier: SubTier3, SubCount: 12, SubMonths: 1}, 30000},
+		{Event{Type: GiftUpgrade, SubCount: 1, SubMonths: 1}, 600},
 		{Event{Bits: 420}, 420},
 		{Event{Cash: CentsValue(501)}, 501},
 	} {
@@ -26,3 +30,239 @@ func TestValue(t *testing.T) {
 		}
 	}
 }
+
+func TestSubValue(t *testing.T) {
+	configured := Rates{
+		Tiers:    map[SubTier]CentsValue{SubTierPrime: 0, SubTier2: 1000},
+		Upgrades: map[SubEventType]CentsValue{PrimeUpgrade: 100},
+	}
+	for _, tc := range []struct {
+		rates Rates
+		ev    Event
+		want  CentsValue
+	}{
+		{Rates{}, Event{SubTier: SubTierPrime}, 500},
+		{Rates{}, Event{SubTier: SubTier1}, 600},
+		{Rates{}, Event{SubTier: SubTier2}, 1200},
+		{Rates{}, Event{SubTier: SubTier3}, 2500},
+		{Rates{}, Event{Type: GiftUpgrade}, 600},
+		{Rates{}, Event{Type: GiftUpgrade, SubTier: SubTier2}, 1200},
+		{Rates{}, Event{Type: PrimeUpgrade, SubTier: SubTier1}, 600},
+		{Rates{}, Event{Type: StandardUpgrade, SubTier: SubTier3}, 2500},
+		{configured, Event{SubTier: SubTierPrime}, 0},
+		{configured, Event{SubTier: SubTier1}, 600},
+		{configured, Event{SubTier: SubTier2}, 1000},
+		{configured, Event{Type: StandardUpgrade, SubTier: SubTier2}, 1000},
+		{configured, Event{Type: PrimeUpgrade, SubTier: SubTier1}, 100},
+		{configured, Event{Type: StandardUpgrade}, 600},
+	} {
+		if got := tc.rates.SubValue(tc.ev); got != tc.want {
+			t.Errorf("SubValue(%+v) with %+v: got %v, want %v", tc.ev, tc.rates, got, tc.want)
+		}
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		ev   Event
+		json string
+	}{
+		{
+			"tip",
+			Event{Source: SourceStreamlabs, Time: time.Date(2022, 7, 1, 20, 15, 0, 0, time.UTC), Owner: "ShartyMcFly", Channel: "usedpizza", Cash: CentsValue(1100), Message: "team mid"},
+			`{"source":"streamlabs","time":"2022-07-01T20:15:00Z","owner":"ShartyMcFly","channel":"usedpizza","cents":1100,"message":"team mid"}`,
+		},
+		{
+			"tip with a fee",
+			Event{Source: SourceStreamElements, ID: "d1", Owner: "usedpizza", Cash: CentsValue(1000), Fee: CentsValue(59)},
+			`{"source":"streamelements","id":"d1","owner":"usedpizza","cents":1000,"feeCents":59}`,
+		},
+		{
+			"community gift",
+			Event{Source: SourceTwitchSub, Owner: "usedpizza", Type: CommunityGift, SubTier: SubTier2, SubCount: 5, SubMonths: 1, SubValue: 1200},
+			`{"source":"twitch_sub","owner":"usedpizza","subType":"community_gift","subTier":2,"subCount":5,"subMonths":1,"subValue":1200}`,
+		},
+		{
+			"sub worth nothing",
+			Event{Source: SourceTwitchSub, Owner: "usedpizza", Type: Subscription, SubTier: SubTierPrime, SubCount: 1, SubMonths: 1, FreeSubs: true},
+			`{"source":"twitch_sub","owner":"usedpizza","subType":"sub","subTier":101,"subCount":1,"subMonths":1,"subValue":0}`,
+		},
+		{
+			"bits",
+			Event{Source: SourceTwitchBits, Owner: "Mizalie", OwnerID: "1234", Bits: 444},
+			`{"source":"twitch_bits","owner":"Mizalie","ownerId":"1234","bits":444}`,
+		},
+		{
+			"channel points",
+			Event{Source: SourceChannelPoints, Owner: "Mizalie", Reward: "abc-123", Credit: CentsValue(50), Message: "moo"},
+			`{"source":"channel_points","owner":"Mizalie","reward":"abc-123","credit":50,"message":"moo"}`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := json.Marshal(tc.ev)
+			if err != nil {
+				t.Fatalf("error marshaling: %v", err)
+			}
+			if string(got) != tc.json {
+				t.Errorf("got %s, want %s", got, tc.json)
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(tc.json), &ev); err != nil {
+				t.Fatalf("error unmarshaling: %v", err)
+			}
+			if !reflect.DeepEqual(ev, tc.ev) {
+				t.Errorf("round trip: got %+v, want %+v", ev, tc.ev)
+			}
+		})
+	}
+}
+
+func TestParseSubEvent_GiftUpgrade(t *testing.T) {
+	for _, msgID := range []string{"giftpaidupgrade", "anongiftpaidupgrade"} {
+		ev, ok := ParseSubEvent(twitch.UserNoticeMessage{
+			MsgID:     msgID,
+			User:      twitch.User{Name: "aerionblue"},
+			Channel:   "testing",
+			MsgParams: map[string]string{"msg-param-sender-login": "usedpizza"},
+		}, Rates{})
+		if !ok {
+			t.Fatalf("%s: not parsed as a sub event", msgID)
+		}
+		if ev.Type != GiftUpgrade || ev.Owner != "aerionblue" || ev.Value() != 600 {
+			t.Errorf("%s: got %+v (worth %v), want a GiftUpgrade by aerionblue worth 600", msgID, ev, ev.Value())
+		}
+		if got, want := ev.Description(), "continued gift sub"; got != want {
+			t.Errorf("%s: got description %q, want %q", msgID, got, want)
+		}
+	}
+}
+
+func TestParseSubEvent_PaidUpgrade(t *testing.T) {
+	rates := Rates{Upgrades: map[SubEventType]CentsValue{PrimeUpgrade: 100}}
+	for _, tc := range []struct {
+		msgID    string
+		wantType SubEventType
+		want     CentsValue
+	}{
+		{"primepaidupgrade", PrimeUpgrade, 100},
+		{"standardpaidupgrade", StandardUpgrade, 600},
+	} {
+		ev, ok := ParseSubEvent(twitch.UserNoticeMessage{
+			MsgID:     tc.msgID,
+			User:      twitch.User{Name: "aerionblue"},
+			Channel:   "testing",
+			MsgParams: map[string]string{"msg-param-sub-plan": "1000"},
+		}, rates)
+		if !ok {
+			t.Fatalf("%s: not parsed as a sub event", tc.msgID)
+		}
+		if ev.Type != tc.wantType || ev.Value() != tc.want {
+			t.Errorf("%s: got %+v (worth %v), want type %v worth %v", tc.msgID, ev, ev.Value(), tc.wantType, tc.want)
+		}
+		if got, want := ev.Description(), "upgraded sub"; got != want {
+			t.Errorf("%s: got description %q, want %q", tc.msgID, got, want)
+		}
+	}
+}
+
+func TestRewardID(t *testing.T) {
+	m := twitch.PrivateMessage{
+		User:    twitch.User{ID: "1234", Name: "aerionblue"},
+		Channel: "testing",
+		Message: "moo please",
+		Tags:    map[string]string{"custom-reward-id": "abc-123"},
+	}
+	if got := RewardID(m); got != "abc-123" {
+		t.Errorf("got reward ID %q, want abc-123", got)
+	}
+	if got := RewardID(twitch.PrivateMessage{Message: "!bid moo"}); got != "" {
+		t.Errorf("got reward ID %q for a plain chat message", got)
+	}
+	ev := Event{Source: SourceChannelPoints, Owner: "aerionblue", Reward: "abc-123", Message: "moo please"}
+	if ev.Value() != 0 || ev.Description() != "channel point redemption" {
+		t.Errorf("got value %v and description %q, want a vote worth nothing", ev.Value(), ev.Description())
+	}
+}
+
+func TestEventJSON_UnknownSubType(t *testing.T) {
+	var ev Event
+	if err := json.Unmarshal([]byte(`{"owner":"x","subType":"mystery"}`), &ev); err == nil {
+		t.Errorf("got nil error for unknown subType")
+	}
+}
+
+func TestParseNotice(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		m      twitch.UserNoticeMessage
+		want   Notice
+		wantOK bool
+	}{
+		{
+			"raid",
+			twitch.UserNoticeMessage{MsgID: "raid", User: twitch.User{Name: "usedpizza"}, Channel: "testing", MsgParams: map[string]string{"msg-param-viewerCount": "512"}},
+			Notice{Type: Raid, User: "usedpizza", Channel: "testing", Count: 512},
+			true,
+		},
+		{
+			"watch streak",
+			twitch.UserNoticeMessage{MsgID: "viewermilestone", User: twitch.User{Name: "aerionblue"}, Channel: "testing", MsgParams: map[string]string{"msg-param-category": "watch-streak", "msg-param-value": "10"}},
+			Notice{Type: ViewerMilestone, User: "aerionblue", Channel: "testing", Count: 10, Category: "watch-streak"},
+			true,
+		},
+		{
+			"sub",
+			twitch.UserNoticeMessage{MsgID: "sub", User: twitch.User{Name: "aerionblue"}, Channel: "testing"},
+			Notice{},
+			false,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, ok := ParseNotice(tc.m)
+			if ok != tc.wantOK || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got (%+v, %v), want (%+v, %v)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseRaidEvent(t *testing.T) {
+	m := twitch.UserNoticeMessage{MsgID: "raid", User: twitch.User{ID: "5678", Name: "usedpizza"}, Channel: "testing", MsgParams: map[string]string{"msg-param-viewerCount": "512"}}
+	ev, ok := ParseRaidEvent(m)
+	if !ok {
+		t.Fatal("not parsed as a raid")
+	}
+	want := Event{Source: SourceTwitchRaid, Owner: "usedpizza", OwnerID: "5678", Channel: "testing", Raiders: 512}
+	if !reflect.DeepEqual(ev, want) {
+		t.Errorf("got %+v, want %+v", ev, want)
+	}
+	if ev.Value() != 0 || ev.Description() != "raid with 512 viewers" {
+		t.Errorf("got value %v and description %q, want a raid worth nothing", ev.Value(), ev.Description())
+	}
+	ev.Credit = 300
+	if ev.Value() != 300 {
+		t.Errorf("got value %v with credit, want 300", ev.Value())
+	}
+	if _, ok := ParseRaidEvent(twitch.UserNoticeMessage{MsgID: "viewermilestone"}); ok {
+		t.Error("a viewer milestone was parsed as a raid")
+	}
+}
+
+func TestDescriptionIsExact(t *testing.T) {
+	SetDisplayPrecision(money.Precision{Decimals: 0, Rounding: money.RoundHalfUp})
+	defer SetDisplayPrecision(money.DefaultPrecision)
+	for _, tc := range []struct {
+		ev   Event
+		want string
+	}{
+		{Event{Cash: 1249}, "$12.49 donation"},
+		{Event{Cash: 1249, Currency: "CAD", OriginalCents: 1700}, "$12.49 donation (17.00 CAD)"},
+		{Event{Source: SourceHypeTrain, Credit: 250}, "2.50-point Hype Train bonus"},
+		{Event{Source: SourceHypeTrainMatch, Cash: 250}, "$2.50 Hype Train bonus"},
+	} {
+		if got := tc.ev.Description(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package donation
+
+import (
+	"fmt"
+	"math"
+)
+
+// FeeSchedule describes what a payment processor takes out of each
+// donation: a percentage of the amount plus a fixed fee.
+type FeeSchedule struct {
+	// The percentage of the amount, e.g. 2.9 for 2.9%.
+	Percent float64
+	Fixed   CentsValue
+}
+
+// SetFee sets the donation's processing fee from an amount in hundredths of
+// the currency that the donor paid in, converted to US cents like Cash (see
+// SetAmount). Call it after SetAmount, with the same Rates. Returns an
+// error, leaving the event alone, if the Rates have no exchange rate for the
+// currency.
+func (e *Event) SetFee(hundredths int, rates Rates) error {
+	rate := 1.0
+	if e.Currency != "" {
+		var ok bool
+		if rate, ok = rates.exchangeRate(e.Currency); !ok {
+			return fmt.Errorf("no exchange rate for the fee's currency, %s", e.Currency)
+		}
+	}
+	e.setFee(CentsValue(math.Round(float64(hundredths) * rate)))
+	return nil
+}
+
+// ApplyFeeSchedule sets the donation's processing fee from its source's fee
+// schedule in the Rates, unless the fee is already known or the source has
+// no schedule.
+func (e *Event) ApplyFeeSchedule(rates Rates) {
+	s, ok := rates.Fees[e.Source]
+	if !ok || e.Fee != 0 || e.Cash <= 0 {
+		return
+	}
+	e.setFee(CentsValue(math.Round(float64(e.Cash)*s.Percent/100)) + s.Fixed)
+}
+
+func (e *Event) setFee(fee CentsValue) {
+	if fee > e.Cash {
+		fee = e.Cash
+	}
+	if fee < 0 {
+		fee = 0
+	}
+	e.Fee = fee
+}
+
+// NetCash returns the cash that's left after the processing fee, i.e. what
+// actually reaches the charity. Cash is the gross amount, which is what
+// counts towards the bid wars.
+func (e Event) NetCash() CentsValue {
+	return e.Cash - e.Fee
+}
@@ -0,0 +1,46 @@
+package donation
+
+import "testing"
+
+func TestApplyFeeSchedule(t *testing.T) {
+	rates := Rates{Fees: map[Source]FeeSchedule{SourceStreamElements: {Percent: 2.9, Fixed: 30}}}
+	for _, tc := range []struct {
+		desc    string
+		ev      Event
+		wantFee CentsValue
+		wantNet CentsValue
+	}{
+		{"scheduled", Event{Source: SourceStreamElements, Cash: 1000}, 59, 941},
+		{"rounded", Event{Source: SourceStreamElements, Cash: 1234}, 66, 1168},
+		{"fee is the whole amount", Event{Source: SourceStreamElements, Cash: 25}, 25, 0},
+		{"fee already known", Event{Source: SourceStreamElements, Cash: 1000, Fee: 40}, 40, 960},
+		{"no schedule", Event{Source: SourceStreamlabs, Cash: 1000}, 0, 1000},
+		{"not cash", Event{Source: SourceStreamElements, Bits: 100}, 0, 0},
+	} {
+		ev := tc.ev
+		ev.ApplyFeeSchedule(rates)
+		if ev.Fee != tc.wantFee || ev.NetCash() != tc.wantNet {
+			t.Errorf("%s: got fee %v and net %v, want %v and %v", tc.desc, ev.Fee, ev.NetCash(), tc.wantFee, tc.wantNet)
+		}
+	}
+}
+
+func TestSetFee(t *testing.T) {
+	rates := Rates{ExchangeRates: map[string]float64{"GBP": 1.25}}
+	var ev Event
+	ev.SetAmount(800, "GBP", rates)
+	if err := ev.SetFee(40, rates); err != nil {
+		t.Fatalf("8.00 GBP with a 0.40 GBP fee: got error %v", err)
+	}
+	if ev.Fee != 50 || ev.NetCash() != 950 {
+		t.Errorf("8.00 GBP with a 0.40 GBP fee: got fee %v and net %v, want 50 and 950", ev.Fee, ev.NetCash())
+	}
+
+	// The fee can't be converted without the donation's exchange rate.
+	if err := ev.SetFee(60, Rates{}); err == nil {
+		t.Error("setting a fee in GBP without an exchange rate: got no error")
+	}
+	if ev.Fee != 50 {
+		t.Errorf("after a failed SetFee, got fee %v, want it unchanged at 50", ev.Fee)
+	}
+}
@@ -0,0 +1,144 @@
+package donation
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// eventJSON is the wire format for an Event, shared by everything that
+// exchanges donations with other programs. For example:
+//
+//	{
+//	  "source": "streamlabs",
+//	  "id": "81234567",
+//	  "time": "2022-07-01T20:15:00Z",
+//	  "owner": "ShartyMcFly",
+//	  "ownerId": "123456789",
+//	  "channel": "usedpizza",
+//	  "cents": 1100,
+//	  "message": "team mid"
+//	}
+//
+// A sub event has "subType" ("sub", "gift", "community_gift",
+// "gift_upgrade", "prime_upgrade", or "standard_upgrade"), "subTier" (1, 2,
+// 3, or 101 for Prime), "subCount", "subMonths", and "subValue" (what one
+// month of one sub is worth, in cents) instead of "cents". A bits event has
+// "bits". A channel point redemption has "reward" (the reward's ID) and
+// "credit" (its value in cents, if any). A raid has "raiders" and "credit".
+// A donation in another currency also has "currency" (e.g., "EUR") and
+// "originalCents" (the amount paid, in hundredths of that currency), and
+// "cents" is its value in US cents. A donation whose processing fee is known
+// has "feeCents", which is part of "cents".
+// Zero-valued fields are omitted, except for a "subValue" of subs that are
+// worth nothing. Without "subValue" (e.g., in files written before it was
+// recorded), the subs are worth the default for their tier. "time" is in
+// RFC 3339 format.
+type eventJSON struct {
+	Source        Source     `json:"source,omitempty"`
+	ID            string     `json:"id,omitempty"`
+	Time          *time.Time `json:"time,omitempty"`
+	Owner         string     `json:"owner"`
+	OwnerID       string     `json:"ownerId,omitempty"`
+	Channel       string     `json:"channel,omitempty"`
+	SubType       string     `json:"subType,omitempty"`
+	SubTier       int        `json:"subTier,omitempty"`
+	SubCount      int        `json:"subCount,omitempty"`
+	SubMonths     int        `json:"subMonths,omitempty"`
+	SubValue      *int       `json:"subValue,omitempty"`
+	Bits          int        `json:"bits,omitempty"`
+	Cents         int        `json:"cents,omitempty"`
+	Currency      string     `json:"currency,omitempty"`
+	OriginalCents int        `json:"originalCents,omitempty"`
+	FeeCents      int        `json:"feeCents,omitempty"`
+	Reward        string     `json:"reward,omitempty"`
+	Credit        int        `json:"credit,omitempty"`
+	Raiders       int        `json:"raiders,omitempty"`
+	Message       string     `json:"message,omitempty"`
+}
+
+// Names of the SubEventTypes in the wire format.
+var subTypeNames = map[SubEventType]string{
+	Subscription:     "sub",
+	GiftSubscription: "gift",
+	CommunityGift:    "community_gift",
+	GiftUpgrade:      "gift_upgrade",
+	PrimeUpgrade:     "prime_upgrade",
+	StandardUpgrade:  "standard_upgrade",
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	j := eventJSON{
+		Source:        e.Source,
+		ID:            e.ID,
+		Owner:         e.Owner,
+		OwnerID:       e.OwnerID,
+		Channel:       e.Channel,
+		SubType:       subTypeNames[e.Type],
+		SubTier:       e.SubTier.Marshal(),
+		SubCount:      e.SubCount,
+		SubMonths:     e.SubMonths,
+		Bits:          e.Bits,
+		Cents:         e.Cash.Cents(),
+		Currency:      e.Currency,
+		OriginalCents: e.OriginalCents,
+		FeeCents:      e.Fee.Cents(),
+		Reward:        e.Reward,
+		Credit:        e.Credit.Cents(),
+		Raiders:       e.Raiders,
+		Message:       e.Message,
+	}
+	if !e.Time.IsZero() {
+		t := e.Time
+		j.Time = &t
+	}
+	if e.SubValue != 0 || e.FreeSubs {
+		v := e.SubValue.Cents()
+		j.SubValue = &v
+	}
+	return json.Marshal(j)
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var j eventJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	ev := Event{
+		Source:        j.Source,
+		ID:            j.ID,
+		Owner:         j.Owner,
+		OwnerID:       j.OwnerID,
+		Channel:       j.Channel,
+		SubTier:       UnmarshalSubTier(j.SubTier),
+		SubCount:      j.SubCount,
+		SubMonths:     j.SubMonths,
+		Bits:          j.Bits,
+		Cash:          CentsValue(j.Cents),
+		Currency:      j.Currency,
+		OriginalCents: j.OriginalCents,
+		Fee:           CentsValue(j.FeeCents),
+		Reward:        j.Reward,
+		Credit:        CentsValue(j.Credit),
+		Raiders:       j.Raiders,
+		Message:       j.Message,
+	}
+	if j.Time != nil {
+		ev.Time = *j.Time
+	}
+	if j.SubValue != nil {
+		ev.SetSubValue(CentsValue(*j.SubValue))
+	}
+	if j.SubType != "" {
+		for t, name := range subTypeNames {
+			if name == j.SubType {
+				ev.Type = t
+			}
+		}
+		if ev.Type == unknown {
+			return fmt.Errorf("unknown subType %q", j.SubType)
+		}
+	}
+	*e = ev
+	return nil
+}
@@ -0,0 +1,66 @@
+package donation
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+)
+
+// USERNOTICE param tag names for raids and viewer milestones.
+const (
+	msgParamViewerCount = "msg-param-viewerCount"
+	msgParamCategory    = "msg-param-category"
+	msgParamValue       = "msg-param-value"
+)
+
+// NoticeType is a kind of channel event that isn't a donation.
+type NoticeType string
+
+const (
+	// Another channel raided this one.
+	Raid NoticeType = "raid"
+	// A viewer reached a milestone, e.g. a watch streak.
+	ViewerMilestone NoticeType = "viewermilestone"
+)
+
+// Notice is a channel event that isn't worth any money, but that the bot can
+// announce or count towards an incentive (e.g., "a raid with 500 viewers
+// unlocks the blindfold run").
+type Notice struct {
+	Type NoticeType
+	Time time.Time
+	// The user who raided, or who reached the milestone.
+	User    string
+	Channel string
+	// For a raid, the number of raiders. For a milestone, its value (e.g.,
+	// 10 for a 10-stream watch streak).
+	Count int
+	// For a milestone, what kind it is (e.g., "watch-streak").
+	Category string
+}
+
+// ParseNotice parses a raid or viewer milestone USERNOTICE into a Notice.
+// Returns (Notice{}, false) for any other message.
+func ParseNotice(m twitch.UserNoticeMessage) (Notice, bool) {
+	n := Notice{Type: NoticeType(m.MsgID), Time: m.Time, User: m.User.Name, Channel: m.Channel}
+	var countParam string
+	switch n.Type {
+	case Raid:
+		countParam = msgParamViewerCount
+	case ViewerMilestone:
+		countParam = msgParamValue
+		n.Category = m.MsgParams[msgParamCategory]
+	default:
+		return Notice{}, false
+	}
+	if s, ok := m.MsgParams[countParam]; ok {
+		count, err := strconv.Atoi(s)
+		if err != nil {
+			log.Printf("unexpected value for %s param: %v", countParam, err)
+		}
+		n.Count = count
+	}
+	return n, true
+}
@@ -0,0 +1,56 @@
+package donation
+
+import "strings"
+
+// Rates say what donations are worth, as set by the bot's config: what subs
+// count for towards the bid wars, what other currencies are worth in US
+// dollars, and what payment processors take out of each donation. The
+// parsers record what an event is worth on the Event, so Rates aren't needed
+// after that. The zero Rates uses the default sub values, and has no
+// exchange rates or fee schedules.
+type Rates struct {
+	// How much a one-month sub of each tier is worth. Tiers that aren't
+	// listed are worth the default: 500 for Prime, 600 for tier 1, 1200 for
+	// tier 2, and 2500 for tier 3.
+	Tiers map[SubTier]CentsValue
+	// How much each kind of upgrade is worth at tier 1, or when Twitch
+	// doesn't say which tier it is (which is usual for gift upgrades). Kinds
+	// that aren't listed are worth 600.
+	Upgrades map[SubEventType]CentsValue
+	// How much one unit of each currency is worth in US dollars, keyed by
+	// uppercase ISO 4217 code (e.g., "EUR").
+	ExchangeRates map[string]float64
+	// The fee schedule for each source whose processor doesn't report its
+	// fees.
+	Fees map[Source]FeeSchedule
+}
+
+var defaultTierValues = map[SubTier]CentsValue{
+	SubTierPrime: 500,
+	SubTier1:     600,
+	SubTier2:     1200,
+	SubTier3:     2500,
+}
+
+const defaultUpgradeValue CentsValue = 600
+
+// SubValue returns how much one month of one of the event's subs is worth.
+func (r Rates) SubValue(e Event) CentsValue {
+	if e.Type.isUpgrade() && (e.SubTier == unknownTier || e.SubTier == SubTier1) {
+		if v, ok := r.Upgrades[e.Type]; ok {
+			return v
+		}
+		return defaultUpgradeValue
+	}
+	if v, ok := r.Tiers[e.SubTier]; ok {
+		return v
+	}
+	return defaultTierValues[e.SubTier]
+}
+
+// exchangeRate returns how much one unit of the currency is worth in US
+// dollars.
+func (r Rates) exchangeRate(currency string) (float64, bool) {
+	rate, ok := r.ExchangeRates[strings.ToUpper(currency)]
+	return rate, ok
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+)
+
+const linkCommand = "!link"
+
+// dispatchLinkCommand links a tip name to a Twitch user, so that the user can
+// use !bid to assign the tips they made under that name. Either name can be
+// quoted if it has spaces in it.
+func (b *bot) dispatchLinkCommand(m twitch.PrivateMessage) {
+	args, rest := splitQuoted(commandArgs(m.Message), 2)
+	if len(args) != 2 || rest != "" {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <tip name> <Twitch user>", m.User.Name, linkCommand))
+		return
+	}
+	if b.identities == nil {
+		b.say(m.Channel, fmt.Sprintf("@%s: Donor links aren't set up.", m.User.Name))
+		return
+	}
+	tipName, user := args[0], args[1]
+	go func() {
+		if err := b.identities.Link(tipName, user); err != nil {
+			log.Printf("ERROR linking %q to %s: %v", tipName, user, err)
+			b.say(m.Channel, fmt.Sprintf("@%s: I couldn't save that link.", m.User.Name))
+			return
+		}
+		b.settled.unsettle(user)
+		log.Printf("%s linked tip name %q to %s", m.User.Name, tipName, user)
+		b.say(m.Channel, fmt.Sprintf("@%s: Tips from %q now count for %s.", m.User.Name, tipName, user))
+	}()
+}
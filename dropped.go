@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+)
+
+const droppedCommand = "!dropped"
+
+// Why the bot ignored an event.
+const (
+	// The donation was recorded, but it's too small to acknowledge or put
+	// towards a bid war.
+	dropBelowMinimum = "below minimum"
+	// A gift sub that was already counted as part of a community gift.
+	dropDuplicateGift = "duplicate gift sub"
+	// A donation that its source delivered again after it was recorded.
+	dropDuplicate = "already recorded"
+	// A USERNOTICE that isn't a sub, raid, or milestone (e.g., an
+	// announcement).
+	dropUnknownNotice = "unknown notice"
+)
+
+// droppedEvents counts the events that the bot ignored on purpose, by reason,
+// so that nothing is lost without a trace. If a review file is set, each
+// event is also written there with its payload, one JSON object per line. A
+// nil droppedEvents records nothing.
+type droppedEvents struct {
+	reviewPath string
+
+	mu     sync.Mutex
+	counts map[string]int
+	// The reasons, in the order they were first seen.
+	reasons []string
+}
+
+// droppedEntry is one line of the review file.
+type droppedEntry struct {
+	Time    time.Time   `json:"time"`
+	Reason  string      `json:"reason"`
+	Payload interface{} `json:"payload"`
+}
+
+func newDroppedEvents(reviewPath string) *droppedEvents {
+	return &droppedEvents{reviewPath: reviewPath, counts: make(map[string]int)}
+}
+
+// add counts an ignored event, and writes it to the review file. The payload
+// is written as JSON.
+func (d *droppedEvents) add(reason string, payload interface{}) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.counts[reason] == 0 {
+		d.reasons = append(d.reasons, reason)
+	}
+	d.counts[reason]++
+	if d.reviewPath == "" {
+		return
+	}
+	if err := d.writeEntry(droppedEntry{Time: time.Now(), Reason: reason, Payload: payload}); err != nil {
+		log.Printf("ERROR writing dropped event to the review file: %v", err)
+	}
+}
+
+// writeEntry appends an entry to the review file. d.mu must be held.
+func (d *droppedEvents) writeEntry(e droppedEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(d.reviewPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open review file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write to review file: %v", err)
+	}
+	return nil
+}
+
+// summary describes the counts, e.g. "3 below minimum, 1 duplicate gift
+// sub". Returns "" if nothing was dropped.
+func (d *droppedEvents) summary() string {
+	if d == nil {
+		return ""
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var parts []string
+	for _, r := range d.reasons {
+		parts = append(parts, fmt.Sprintf("%d %s", d.counts[r], r))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// dispatchDroppedCommand tells the mods how many events the bot has ignored,
+// and why.
+func (b *bot) dispatchDroppedCommand(m twitch.PrivateMessage) {
+	s := b.dropped.summary()
+	if s == "" {
+		b.say(m.Channel, fmt.Sprintf("@%s: I haven't ignored any events.", m.User.Name))
+		return
+	}
+	b.say(m.Channel, fmt.Sprintf("@%s: Ignored events: %s.", m.User.Name, s))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestDroppedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dropped.jsonl")
+	d := newDroppedEvents(path)
+	if got := d.summary(); got != "" {
+		t.Errorf("empty summary: got %q, want \"\"", got)
+	}
+	d.add(dropBelowMinimum, donation.Event{Owner: "aerionblue", Bits: 50})
+	d.add(dropUnknownNotice, "@msg-id=raid :tmi.twitch.tv USERNOTICE #testing")
+	d.add(dropBelowMinimum, donation.Event{Owner: "usedpizza", Bits: 10})
+	if got, want := d.summary(), "2 below minimum, 1 unknown notice"; got != want {
+		t.Errorf("summary: got %q, want %q", got, want)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading review file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines in the review file, want 3:\n%s", len(lines), data)
+	}
+	var e struct {
+		Reason  string
+		Payload struct {
+			Owner string
+			Bits  int
+		}
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatalf("error parsing review line: %v", err)
+	}
+	if e.Reason != dropBelowMinimum || e.Payload.Owner != "aerionblue" || e.Payload.Bits != 50 {
+		t.Errorf("first review line: got %s", lines[0])
+	}
+
+	var nilDropped *droppedEvents
+	nilDropped.add(dropBelowMinimum, nil)
+	if got := nilDropped.summary(); got != "" {
+		t.Errorf("nil summary: got %q, want \"\"", got)
+	}
+}
@@ -0,0 +1,269 @@
+// Package eventqueue is a durable queue of donation events in a local file,
+// so that one bot process can ingest donations (from chat and the donation
+// providers) while another records and announces them. If either process
+// crashes, the events are still in the file, and the recording process
+// picks up where it left off.
+//
+// The queue file has one JSON object per line. The reader's position is
+// kept in a second file next to it, and only moves past an event once its
+// handler returns without an error, so events are delivered at least once:
+// an event that was being handled when the reader crashed, or that its
+// handler couldn't handle, is handled again. An event that keeps failing is
+// moved to a dead-letter file next to the queue (with the same format, so
+// its lines can be appended to the queue again once the problem is fixed),
+// so that it doesn't hold up the events behind it. Once every event in the
+// queue has been handled, the file is emptied.
+package eventqueue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Entry is one event in the queue.
+type Entry struct {
+	// Which dispatcher should handle the event (e.g., "bits"). The queue
+	// doesn't interpret it.
+	Kind  string         `json:"kind"`
+	Event donation.Event `json:"event"`
+	// The chat message that carried the event, so that the recording
+	// process can reply to it. The Event's wire format leaves it out.
+	MessageID string `json:"messageId,omitempty"`
+	// Whether to look for a bid in the donor's name, for tips.
+	MatchDonorName bool `json:"matchDonorName,omitempty"`
+}
+
+// Writer appends entries to a queue file.
+type Writer struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewWriter creates a Writer for the given queue file. The file is created
+// when the first entry is added.
+func NewWriter(path string) *Writer {
+	return &Writer{path: path}
+}
+
+// Add appends an entry to the queue, and waits until it's on disk.
+func (w *Writer) Add(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open event queue: %v", err)
+	}
+	defer f.Close()
+	// The Reader empties the file under the same lock.
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("could not lock event queue: %v", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write to event queue: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("could not sync event queue: %v", err)
+	}
+	return nil
+}
+
+// How many times in a row an entry's handler can fail before the entry is
+// moved to the dead-letter file. With Watch's backoff, that's about five
+// minutes of retries.
+const defaultMaxAttempts = 10
+
+// Reader reads the entries in a queue file, remembering how far it has read.
+type Reader struct {
+	path       string
+	offsetPath string
+	deadPath   string
+	// The byte offset of the first entry that hasn't been handled.
+	offset int64
+	// How many times in a row the entry at offset has failed.
+	failures    int
+	maxAttempts int
+}
+
+// NewReader creates a Reader for the given queue file, starting after the
+// last entry that a previous Reader handled. Entries that can't be handled
+// go to the file with the same path plus ".dead".
+func NewReader(path string) (*Reader, error) {
+	r := &Reader{path: path, offsetPath: path + ".offset", deadPath: path + ".dead", maxAttempts: defaultMaxAttempts}
+	data, err := ioutil.ReadFile(r.offsetPath)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read event queue offset: %v", err)
+	}
+	r.offset, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed event queue offset: %v", err)
+	}
+	return r, nil
+}
+
+// Read hands each new entry to handle, in order, and saves the Reader's
+// position after each one. If handle returns an error, Read stops and
+// returns it, and the entry is handed over again next time, until it has
+// failed maxAttempts times in a row; then it's moved to the dead-letter file
+// and Read goes on. An entry that's still being written (i.e., the last line
+// has no newline yet) is left for next time. Once every entry has been
+// handled, the queue file is emptied. Returns how many entries were handled.
+func (r *Reader) Read(handle func(Entry) error) (int, error) {
+	f, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("could not open event queue: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil {
+		return 0, fmt.Errorf("could not read event queue: %v", err)
+	} else if info.Size() < r.offset {
+		// Someone else emptied it.
+		log.Printf("WARNING: the event queue is shorter than the saved offset %d; reading it from the start", r.offset)
+		if err := r.saveOffset(0); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := f.Seek(r.offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("could not seek in event queue: %v", err)
+	}
+	br := bufio.NewReader(f)
+	n := 0
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if r.offset > 0 {
+				if err := r.compact(); err != nil {
+					log.Printf("ERROR %v", err)
+				}
+			}
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("could not read event queue: %v", err)
+		}
+		next := r.offset + int64(len(line))
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var e Entry
+			if err := json.Unmarshal(line, &e); err != nil {
+				log.Printf("ERROR skipping malformed event queue entry %q: %v", line, err)
+			} else {
+				if err := handle(e); err != nil {
+					if r.failures++; r.failures < r.maxAttempts {
+						return n, fmt.Errorf("could not handle %s from %s: %v", e.Event.Description(), e.Event.Owner, err)
+					}
+					log.Printf("ERROR giving up on %s from %s after %d attempts; moving it to %s: %v", e.Event.Description(), e.Event.Owner, r.failures, r.deadPath, err)
+					if err := appendDeadLetter(r.deadPath, line); err != nil {
+						return n, err
+					}
+				} else {
+					n++
+				}
+			}
+		}
+		if err := r.saveOffset(next); err != nil {
+			return n, err
+		}
+	}
+}
+
+// The longest that Watch waits before retrying an entry that failed.
+const maxRetryDelay = time.Minute
+
+// Watch calls Read every interval, forever. After an error, it waits twice
+// as long each time (up to maxRetryDelay) before trying again. An entry that
+// keeps failing is eventually moved aside (see Read).
+func (r *Reader) Watch(interval time.Duration, handle func(Entry) error) {
+	delay := interval
+	for {
+		if _, err := r.Read(handle); err != nil {
+			log.Printf("ERROR reading event queue (retrying in %v): %v", delay, err)
+			time.Sleep(delay)
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+			continue
+		}
+		delay = interval
+		time.Sleep(interval)
+	}
+}
+
+func (r *Reader) saveOffset(offset int64) error {
+	tmp := r.offsetPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)+"\n"), 0644); err != nil {
+		return fmt.Errorf("could not write event queue offset: %v", err)
+	}
+	if err := os.Rename(tmp, r.offsetPath); err != nil {
+		return fmt.Errorf("could not write event queue offset: %v", err)
+	}
+	r.offset = offset
+	r.failures = 0
+	return nil
+}
+
+// compact empties the queue file if every entry in it has been handled. It
+// holds the Writer's lock, so that no entry can be added in between.
+func (r *Reader) compact() error {
+	f, err := os.OpenFile(r.path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("could not open event queue to compact it: %v", err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("could not lock event queue: %v", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not compact event queue: %v", err)
+	} else if info.Size() != r.offset {
+		// More entries arrived.
+		return nil
+	}
+	// Save the offset first: if the bot crashes before the file is
+	// emptied, its entries are handled again rather than new ones skipped.
+	if err := r.saveOffset(0); err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("could not compact event queue: %v", err)
+	}
+	return nil
+}
+
+// appendDeadLetter adds an entry's line to the dead-letter file.
+func appendDeadLetter(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open dead-letter file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write to dead-letter file: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("could not sync dead-letter file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package eventqueue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	w := NewWriter(path)
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	handle := func(e Entry) error {
+		got = append(got, e.Kind+":"+e.Event.Owner+":"+e.MessageID)
+		return nil
+	}
+	if n, err := r.Read(handle); err != nil || n != 0 {
+		t.Errorf("reading a missing queue: got (%d, %v), want (0, nil)", n, err)
+	}
+
+	for _, e := range []Entry{
+		{Kind: "bits", Event: donation.Event{Owner: "aerionblue", Bits: 100}, MessageID: "m1"},
+		{Kind: "money", Event: donation.Event{Owner: "usedpizza", Cash: donation.CentsValue(500)}, MatchDonorName: true},
+	} {
+		if err := w.Add(e); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	// A half-written entry is left for later.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"kind":"sub","event":{"owner":"Miz`)
+	f.Close()
+
+	if _, err := r.Read(handle); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if want := []string{"bits:aerionblue:m1", "money:usedpizza:"}; !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+
+	f, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`alie"}}` + "\n")
+	f.Close()
+
+	// A new Reader picks up where the last one left off.
+	got = nil
+	r, err = NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(handle); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if want := []string{"sub:Mizalie:"}; !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+}
+
+func TestQueueRetriesFailedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	w := NewWriter(path)
+	for _, owner := range []string{"aerionblue", "usedpizza"} {
+		if err := w.Add(Entry{Kind: "money", Event: donation.Event{Owner: owner, Cash: donation.CentsValue(500)}}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	down := true
+	handle := func(e Entry) error {
+		if down && e.Event.Owner == "usedpizza" {
+			return errors.New("sheet is down")
+		}
+		got = append(got, e.Event.Owner)
+		return nil
+	}
+	if n, err := r.Read(handle); err == nil || n != 1 {
+		t.Errorf("while the sheet is down: got (%d, %v), want (1, an error)", n, err)
+	}
+
+	// The failed entry is handed over again, even by a new Reader.
+	down = false
+	r, err = NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(handle); err != nil || n != 1 {
+		t.Errorf("after the sheet is back: got (%d, %v), want (1, nil)", n, err)
+	}
+	if want := []string{"aerionblue", "usedpizza"}; !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+}
+
+func TestQueueDeadLetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	w := NewWriter(path)
+	for _, owner := range []string{"aerionblue", "usedpizza"} {
+		if err := w.Add(Entry{Kind: "money", Event: donation.Event{Owner: owner, Cash: donation.CentsValue(500)}}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.maxAttempts = 3
+	var got []string
+	handle := func(e Entry) error {
+		if e.Event.Owner == "aerionblue" {
+			return errors.New("bad entry")
+		}
+		got = append(got, e.Event.Owner)
+		return nil
+	}
+	for i := 1; i < r.maxAttempts; i++ {
+		if n, err := r.Read(handle); err == nil || n != 0 {
+			t.Errorf("attempt %d: got (%d, %v), want (0, an error)", i, n, err)
+		}
+	}
+	// The last attempt gives up on the bad entry, and moves on.
+	if n, err := r.Read(handle); err != nil || n != 1 {
+		t.Errorf("last attempt: got (%d, %v), want (1, nil)", n, err)
+	}
+	if want := []string{"usedpizza"}; !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+
+	// The bad entry can be read back from the dead-letter file.
+	dead, err := NewReader(path + ".dead")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	if _, err := dead.Read(func(e Entry) error {
+		got = append(got, e.Event.Owner)
+		return nil
+	}); err != nil {
+		t.Fatalf("reading the dead letters: %v", err)
+	}
+	if want := []string{"aerionblue"}; !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+}
+
+func TestQueueCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	w := NewWriter(path)
+	r, err := NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	handle := func(e Entry) error {
+		got = append(got, e.Event.Owner)
+		return nil
+	}
+	for _, owner := range []string{"aerionblue", "usedpizza"} {
+		if err := w.Add(Entry{Kind: "money", Event: donation.Event{Owner: owner, Cash: donation.CentsValue(500)}}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if _, err := r.Read(handle); err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+			t.Errorf("after reading everything: got queue file %v, %v; want it empty", info, err)
+		}
+	}
+	if want := []string{"aerionblue", "usedpizza"}; !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+
+	// A new Reader doesn't skip what's added after the compaction.
+	if err := w.Add(Entry{Kind: "money", Event: donation.Event{Owner: "Mizalie", Cash: donation.CentsValue(500)}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	r, err = NewReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	if _, err := r.Read(handle); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if want := []string{"Mizalie"}; !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// How the bot uses EventSub (see EventSubConfig).
+const (
+	// EventSub isn't used.
+	eventSubOff = ""
+	// Subs, gift subs, and cheers come from EventSub instead of chat.
+	eventSubReplace = "replace"
+	// Subs, gift subs, and cheers still come from chat, but they're checked
+	// against EventSub.
+	eventSubCrossCheck = "crosscheck"
+)
+
+// How long a cross-check waits for the other side to report an event before
+// it calls the event missing.
+const crossCheckWindow = 2 * time.Minute
+
+// coveredByEventSub reports whether EventSub also reports the USERNOTICE
+// with the given msg-id. Resubs and upgrades only come from chat.
+func coveredByEventSub(msgID string) bool {
+	switch msgID {
+	case "sub", "subgift", "submysterygift":
+		return true
+	}
+	return false
+}
+
+// crossCheck compares the subs and cheers from chat with the ones from
+// EventSub, so that we notice when either one misses something.
+type crossCheck struct {
+	mu sync.Mutex
+	// Events that only one side has reported so far. Oldest first.
+	pending []crossCheckEvent
+}
+
+type crossCheckEvent struct {
+	key          string
+	fromEventSub bool
+	desc         string
+	owner        string
+	at           time.Time
+}
+
+// crossCheckKey identifies an event in a way that both sides agree on.
+// EventSub doesn't tell Prime subs apart from tier 1 subs, so the tier is
+// left out.
+func crossCheckKey(ev donation.Event) string {
+	return fmt.Sprintf("%s/%d/%d", strings.ToLower(ev.Owner), ev.Bits, ev.SubCount)
+}
+
+// add records an event from one side, and matches it with the same event
+// from the other side, if that has arrived.
+func (c *crossCheck) add(ev donation.Event, fromEventSub bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := crossCheckKey(ev)
+	for i, p := range c.pending {
+		if p.key == key && p.fromEventSub != fromEventSub {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return
+		}
+	}
+	c.pending = append(c.pending, crossCheckEvent{key: key, fromEventSub: fromEventSub, desc: ev.Description(), owner: ev.Owner, at: now})
+}
+
+// missing returns a description of each event that only one side reported
+// within the window, and forgets them.
+func (c *crossCheck) missing(now time.Time) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var msgs []string
+	var pending []crossCheckEvent
+	for _, p := range c.pending {
+		if now.Sub(p.at) < crossCheckWindow {
+			pending = append(pending, p)
+			continue
+		}
+		if p.fromEventSub {
+			msgs = append(msgs, fmt.Sprintf("EventSub reported %s from %s, but chat didn't", p.desc, p.owner))
+		} else {
+			msgs = append(msgs, fmt.Sprintf("Chat reported %s from %s, but EventSub didn't", p.desc, p.owner))
+		}
+	}
+	c.pending = pending
+	return msgs
+}
+
+// useEventSub sets how a channel's bot uses EventSub. Only the channels with
+// their own EventSub credentials use it; the others keep getting their subs
+// and cheers from chat.
+func (b *bot) useEventSub(cfg EventSubConfig) {
+	b.eventSubMode = cfg.Mode
+	b.eventSubSelfSubs = cfg.SelfSubs
+	if cfg.Mode == eventSubCrossCheck {
+		b.crossCheck = &crossCheck{}
+	}
+}
+
+// takenFromEventSub reports whether, in replace mode, a sub or cheer is taken
+// from EventSub instead of chat. EventSub reports Prime subs as tier 1 subs,
+// so the subs that users buy for themselves stay with chat, which can tell
+// them apart, unless selfSubs is set.
+func takenFromEventSub(ev donation.Event, selfSubs bool) bool {
+	return ev.Type != donation.Subscription || selfSubs
+}
+
+// leaveToEventSub reports whether a sub or cheer from chat should be ignored,
+// because it comes from EventSub instead. In cross-check mode, the event is
+// recorded for the check.
+func (b *bot) leaveToEventSub(ev donation.Event) bool {
+	switch b.eventSubMode {
+	case eventSubReplace:
+		return takenFromEventSub(ev, b.eventSubSelfSubs)
+	case eventSubCrossCheck:
+		// The individual gift subs that follow a community gift are only
+		// reported once by EventSub.
+		if ev.Type != donation.GiftSubscription || !b.shouldIgnoreSubGift(ev) {
+			b.crossCheck.add(ev, false, time.Now())
+		}
+	}
+	return false
+}
+
+// dispatchEventSubDonation handles a sub, gift sub, or cheer from EventSub.
+func (b *bot) dispatchEventSubDonation(ev donation.Event) {
+	if task := b.eventSubTask(ev); task != nil {
+		go task()
+	}
+}
+
+func (b *bot) eventSubTask(ev donation.Event) donationTask {
+	if b.eventSubMode == eventSubCrossCheck {
+		b.crossCheck.add(ev, true, time.Now())
+		return nil
+	}
+	if b.eventSubMode == eventSubReplace && !takenFromEventSub(ev, b.eventSubSelfSubs) {
+		// Chat reports this one.
+		return nil
+	}
+	if ev.Bits > 0 {
+		return b.bitsTask(ev)
+	}
+	return b.subTask(ev)
+}
+
+// reportCrossCheck tells the mods about the events that only one side
+// reported.
+func (b *bot) reportCrossCheck(now time.Time) {
+	if b.crossCheck == nil {
+		return
+	}
+	for _, msg := range b.crossCheck.missing(now) {
+		log.Printf("WARNING: %s", msg)
+		b.note(msg)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestCrossCheck(t *testing.T) {
+	now := time.Now()
+	c := &crossCheck{}
+	cheer := donation.Event{Owner: "AerionBlue", Bits: 500}
+	gift := donation.Event{Owner: "usedpizza", SubCount: 5, Type: donation.CommunityGift, SubTier: donation.SubTier1}
+	sub := donation.Event{Owner: "mmmkay", SubCount: 1, Type: donation.Subscription, SubTier: donation.SubTier1}
+
+	c.add(cheer, false, now)
+	c.add(gift, true, now)
+	c.add(sub, false, now)
+	c.add(donation.Event{Owner: "aerionblue", Bits: 500}, true, now.Add(10*time.Second))
+	c.add(gift, false, now.Add(10*time.Second))
+
+	if got := c.missing(now.Add(time.Minute)); len(got) != 0 {
+		t.Errorf("got %q before the window ended, want nothing", got)
+	}
+	want := []string{"Chat reported " + sub.Description() + " from mmmkay, but EventSub didn't"}
+	if got := c.missing(now.Add(3 * time.Minute)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := c.missing(now.Add(4 * time.Minute)); len(got) != 0 {
+		t.Errorf("got %q, want missing events to be reported only once", got)
+	}
+}
+
+func TestCoveredByEventSub(t *testing.T) {
+	for msgID, want := range map[string]bool{
+		"sub":              true,
+		"subgift":          true,
+		"submysterygift":   true,
+		"resub":            false,
+		"primepaidupgrade": false,
+	} {
+		if got := coveredByEventSub(msgID); got != want {
+			t.Errorf("coveredByEventSub(%q): got %v, want %v", msgID, got, want)
+		}
+	}
+}
+
+func TestUseEventSubTwoChannels(t *testing.T) {
+	cheer := donation.Event{Owner: "AerionBlue", Bits: 500}
+	for _, mode := range []string{eventSubReplace, eventSubCrossCheck} {
+		channels := []*channel{{name: "aerionblue", bot: &bot{}}, {name: "usedpizza", bot: &bot{}}}
+		// Only the first channel has EventSub credentials.
+		channels[0].bot.useEventSub(EventSubConfig{Mode: mode})
+		if got, want := channels[0].bot.leaveToEventSub(cheer), mode == eventSubReplace; got != want {
+			t.Errorf("%s: first channel: got %v, want %v", mode, got, want)
+		}
+		// EventSub doesn't report the other channel's cheers, so they must
+		// still come from chat.
+		if channels[1].bot.leaveToEventSub(cheer) {
+			t.Errorf("%s: second channel: left a cheer to EventSub", mode)
+		}
+		if channels[1].bot.crossCheck != nil {
+			t.Errorf("%s: second channel: got a cross-check", mode)
+		}
+	}
+}
+
+func TestReplaceKeepsSelfSubsInChat(t *testing.T) {
+	sub := donation.Event{Owner: "AerionBlue", Type: donation.Subscription, SubTier: donation.SubTierPrime, SubCount: 1, SubMonths: 1}
+	gift := donation.Event{Owner: "AerionBlue", Type: donation.GiftSubscription, SubTier: donation.SubTier1, SubCount: 1, SubMonths: 1}
+	for _, tc := range []struct {
+		selfSubs bool
+		ev       donation.Event
+		want     bool
+	}{
+		{false, sub, false},
+		{false, gift, true},
+		{true, sub, true},
+		{true, gift, true},
+	} {
+		b := &bot{}
+		b.useEventSub(EventSubConfig{Mode: eventSubReplace, SelfSubs: tc.selfSubs})
+		if got := b.leaveToEventSub(tc.ev); got != tc.want {
+			t.Errorf("selfSubs %v, %s: chat left it to EventSub: got %v, want %v", tc.selfSubs, tc.ev.Description(), got, tc.want)
+		}
+		// EventSub's copy is used exactly when chat's isn't.
+		if got := b.eventSubTask(tc.ev) != nil; got != tc.want {
+			t.Errorf("selfSubs %v, %s: EventSub's copy used: got %v, want %v", tc.selfSubs, tc.ev.Description(), got, tc.want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ require (
 	github.com/gempir/go-twitch-irc/v2 v2.5.0
 	github.com/go-test/deep v1.0.7
 	github.com/google/go-cmp v0.5.4
+	golang.org/x/net v0.0.0-20201224014010-6772e930b67b
 	golang.org/x/oauth2 v0.0.0-20210218202405-ba52d332ba99
 	golang.org/x/time v0.3.0
 	google.golang.org/api v0.40.0
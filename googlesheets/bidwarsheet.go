@@ -0,0 +1,66 @@
+package googlesheets
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// BidWarSheet is a sheet that defines the bid wars, for organizers who would
+// rather edit a spreadsheet than a JSON file. See bidwar.ParseSheetRows for
+// the layout.
+type BidWarSheet struct {
+	spreadsheetID string
+	sheetRange    string
+
+	// mu must be held when performing any modification to the sheet.
+	mu  sync.Mutex
+	srv *sheets.SpreadsheetsService
+}
+
+func NewBidWarSheet(srv *sheets.Service, spreadsheetID string, sheetName string) *BidWarSheet {
+	return &BidWarSheet{
+		spreadsheetID: spreadsheetID,
+		sheetRange:    sheetRange(sheetName, "A:F"),
+		srv:           srv.Spreadsheets,
+	}
+}
+
+// Read returns every row of the sheet, including the header.
+func (s *BidWarSheet) Read() ([][]interface{}, error) {
+	vr, err := s.srv.Values.
+		Get(s.spreadsheetID, s.sheetRange).
+		MajorDimension("ROWS").
+		ValueRenderOption("UNFORMATTED_VALUE").
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("error reading bid war sheet: %v", err)
+	}
+	return vr.Values, nil
+}
+
+// Write edits the sheet. edits has the same structure as the rows returned by
+// Read; cells with a nil value are not overwritten. newRows are appended to
+// the end of the sheet.
+func (s *BidWarSheet) Write(edits [][]interface{}, newRows [][]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(edits) > 0 {
+		_, err := s.srv.Values.
+			Update(s.spreadsheetID, s.sheetRange, &sheets.ValueRange{MajorDimension: "ROWS", Values: edits}).
+			ValueInputOption("RAW").
+			Do()
+		if err != nil {
+			return fmt.Errorf("error updating bid war sheet: %v", err)
+		}
+	}
+	if len(newRows) > 0 {
+		call := s.srv.Values.Append(s.spreadsheetID, s.sheetRange, &sheets.ValueRange{Values: newRows})
+		call.InsertDataOption("INSERT_ROWS").ValueInputOption("RAW")
+		if _, err := call.Do(); err != nil {
+			return fmt.Errorf("error appending to bid war sheet: %v", err)
+		}
+	}
+	return nil
+}
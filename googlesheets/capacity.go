@@ -0,0 +1,98 @@
+package googlesheets
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// When fewer than this many empty rows are left at the bottom of the donation
+// sheet, more rows are added.
+const minFreeRows = 200
+
+// How many rows to add to the donation sheet at a time.
+const rowsToAdd = 1000
+
+// loadGrid looks up the donation sheet's ID and its current number of rows.
+func (dt *DonationTable) loadGrid() error {
+	ss, err := dt.srv.Get(dt.spreadsheetID).Do()
+	if err != nil {
+		return fmt.Errorf("error reading spreadsheet properties: %v", err)
+	}
+	for _, sh := range ss.Sheets {
+		if sh.Properties == nil || sh.Properties.Title != dt.sheetName {
+			continue
+		}
+		dt.sheetID = sh.Properties.SheetId
+		if sh.Properties.GridProperties != nil {
+			dt.rowCount = sh.Properties.GridProperties.RowCount
+		}
+		return nil
+	}
+	return fmt.Errorf("no sheet named %q in the spreadsheet", dt.sheetName)
+}
+
+// ensureFreeRows adds rows to the donation sheet if fewer than minFreeRows
+// are left below lastRow (a 1-based row number). dt.mu must be held.
+func (dt *DonationTable) ensureFreeRows(lastRow int64) error {
+	if dt.rowCount == 0 {
+		if err := dt.loadGrid(); err != nil {
+			return err
+		}
+	}
+	if dt.rowCount-lastRow >= minFreeRows {
+		return nil
+	}
+	return dt.addRows()
+}
+
+// addRows adds rowsToAdd empty rows to the bottom of the donation sheet.
+// dt.mu must be held.
+func (dt *DonationTable) addRows() error {
+	if dt.rowCount == 0 {
+		if err := dt.loadGrid(); err != nil {
+			return err
+		}
+	}
+	req := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AppendDimension: &sheets.AppendDimensionRequest{
+				SheetId:   dt.sheetID,
+				Dimension: "ROWS",
+				Length:    rowsToAdd,
+			},
+		}},
+	}
+	if _, err := dt.srv.BatchUpdate(dt.spreadsheetID, req).Do(); err != nil {
+		return fmt.Errorf("error adding rows to the donation sheet: %v", err)
+	}
+	dt.rowCount += rowsToAdd
+	log.Printf("WARNING: the donation sheet was running out of rows, so I added %d more (it now has %d)", rowsToAdd, dt.rowCount)
+	return nil
+}
+
+// isGridLimitError reports whether a Sheets API error means that a write went
+// past the last row of the sheet.
+func isGridLimitError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "exceeds grid limits")
+}
+
+// lastRowOf returns the last row number in an A1 range like
+// "'Tracker'!A1234:E1236".
+func lastRowOf(a1Range string) (int64, bool) {
+	if i := strings.LastIndex(a1Range, "!"); i >= 0 {
+		a1Range = a1Range[i+1:]
+	}
+	if i := strings.LastIndex(a1Range, ":"); i >= 0 {
+		a1Range = a1Range[i+1:]
+	}
+	digits := strings.TrimLeft(a1Range, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz$")
+	row, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return row, true
+}
@@ -0,0 +1,38 @@
+package googlesheets
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLastRowOf(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		want   int64
+		wantOK bool
+	}{
+		{"'Tracker'!A1234:E1236", 1236, true},
+		{"Tracker!A5:F5", 5, true},
+		{"'Bits & Subs'!B17", 17, true},
+		{"'Tracker'!A:E", 0, false},
+		{"", 0, false},
+	} {
+		got, ok := lastRowOf(tc.in)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("lastRowOf(%q): got (%d, %v), want (%d, %v)", tc.in, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestIsGridLimitError(t *testing.T) {
+	err := errors.New("googleapi: Error 400: Range ('Tracker'!A1001:E1001) exceeds grid limits. Max rows: 1000, max columns: 26, badRequest")
+	if !isGridLimitError(err) {
+		t.Errorf("isGridLimitError(%v) = false, want true", err)
+	}
+	if isGridLimitError(errors.New("googleapi: Error 503: The service is currently unavailable")) {
+		t.Error("isGridLimitError should be false for other errors")
+	}
+	if isGridLimitError(nil) {
+		t.Error("isGridLimitError(nil) should be false")
+	}
+}
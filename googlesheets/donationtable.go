@@ -2,55 +2,333 @@ package googlesheets
 
 import (
 	"fmt"
+	"log"
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/api/sheets/v4"
 
 	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/sanitize"
 )
 
+// Write describes one successful write to the donation table.
+type Write struct {
+	// "append" for new rows, or "update" for edits to existing rows.
+	Op string
+	// The A1 range that was written, if known.
+	Range string
+	// The values written. In an update, nil cells were left unchanged.
+	Rows [][]interface{}
+}
+
 type DonationTable struct {
+	// How many times the table has been written to. Accessed atomically.
+	writes uint64
+	// When the last successful write finished, in Unix nanoseconds, or 0 if
+	// there hasn't been one. Accessed atomically.
+	lastWrite int64
+
 	spreadsheetID string
+	sheetName     string
 	tableRange    string
+	// How to clean up bid war reasons before appending them.
+	reasonOptions sanitize.Options
+	// Called after each write, with mu held. May be nil.
+	onWrite func(Write)
+	// Whether to write values rounded to the display precision, instead of
+	// to the cent.
+	roundValues bool
+	// Whether to record each donation's source, campaign, and owner ID in
+	// extra columns.
+	recordSource   bool
+	recordCampaign bool
+	recordOwnerID  bool
+	recordTime     bool
+	recordNet      bool
 
 	// mu must be held when performing any modification to the spreadsheet.
 	mu  sync.Mutex
 	srv *sheets.SpreadsheetsService
+	// The donation sheet's ID and number of rows, used to add more rows
+	// before the sheet fills up. Guarded by mu. rowCount is 0 until it's
+	// looked up.
+	sheetID  int64
+	rowCount int64
 }
 
 func NewDonationTable(srv *sheets.Service, spreadsheetID string, sheetName string) *DonationTable {
-	// TODO(aerion): Escape this, in case the sheet name contains a single quote.
-	tableRange := fmt.Sprintf("'%s'!A:E", sheetName)
 	return &DonationTable{
 		spreadsheetID: spreadsheetID,
-		tableRange:    tableRange,
+		sheetName:     sheetName,
+		tableRange:    sheetRange(sheetName, "A:E"),
 		srv:           srv.Spreadsheets,
 	}
 }
 
+// SetReasonOptions sets how bid war reasons are cleaned up before they are
+// appended to the table.
+func (dt *DonationTable) SetReasonOptions(o sanitize.Options) {
+	dt.reasonOptions = o
+}
+
+// RecordSource adds a sixth column to the table, where each donation's source
+// (e.g., "streamlabs") is recorded. Make sure that the column is free; by
+// default, the table only uses columns A through E.
+func (dt *DonationTable) RecordSource() {
+	dt.recordSource = true
+	dt.updateRange()
+}
+
+// RecordCampaign adds a seventh column to the table, where each donation's
+// campaign is recorded. Make sure that the column is free. Column F is left
+// alone unless RecordSource is also used.
+func (dt *DonationTable) RecordCampaign() {
+	dt.recordCampaign = true
+	dt.updateRange()
+}
+
+// RecordOwnerID adds an eighth column to the table, where the donor's Twitch
+// user ID is recorded (if known), so that their donations can be found after
+// they rename themselves. Make sure that the column is free. Columns F and G
+// are left alone unless RecordSource and RecordCampaign are also used.
+func (dt *DonationTable) RecordOwnerID() {
+	dt.recordOwnerID = true
+	dt.updateRange()
+}
+
+// RecordTime adds a ninth column to the table, where the time of each
+// donation is recorded, in the bot's local time zone. Make sure that the
+// column is free. Columns F through H are left alone unless the other Record
+// options are also used.
+func (dt *DonationTable) RecordTime() {
+	dt.recordTime = true
+	dt.updateRange()
+}
+
+// RecordNet adds a tenth column to the table, where the cash that's left
+// from each donation after the payment processor's fee is recorded, so that
+// the organizers can total what actually goes to the charity. The value
+// column still has the full amount. The column is left empty for donations
+// that aren't cash. Make sure that the column is free. Columns F through I
+// are left alone unless the other Record options are also used.
+func (dt *DonationTable) RecordNet() {
+	dt.recordNet = true
+	dt.updateRange()
+}
+
+// RecordsTime reports whether the table records the time of each donation
+// (see RecordTime).
+func (dt *DonationTable) RecordsTime() bool {
+	return dt.recordTime
+}
+
+// The layout of the times in the time column, which Sheets reads as a date
+// and time.
+const timeLayout = "2006-01-02 15:04:05"
+
+// The time column, counting from column A.
+const timeColumn = 8
+
+// RowTime returns the time of the donation in a row read by GetTable, if the
+// row has one. Sheets returns the times as serial numbers (days since the
+// end of 1899), unless it didn't read the cell as a date.
+func RowTime(row []interface{}) (time.Time, bool) {
+	if len(row) <= timeColumn {
+		return time.Time{}, false
+	}
+	switch v := row[timeColumn].(type) {
+	case float64:
+		days := math.Floor(v)
+		epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local)
+		t := epoch.AddDate(0, 0, int(days)).Add(time.Duration((v - days) * float64(24*time.Hour)))
+		return t.Round(time.Second), true
+	case string:
+		t, err := time.ParseInLocation(timeLayout, v, time.Local)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// extraColumns returns how many columns the table uses past column E.
+func (dt *DonationTable) extraColumns() int {
+	switch {
+	case dt.recordNet:
+		return 5
+	case dt.recordTime:
+		return 4
+	case dt.recordOwnerID:
+		return 3
+	case dt.recordCampaign:
+		return 2
+	case dt.recordSource:
+		return 1
+	}
+	return 0
+}
+
+// updateRange widens the table range to include the extra columns.
+func (dt *DonationTable) updateRange() {
+	dt.tableRange = sheetRange(dt.sheetName, fmt.Sprintf("A:%c", 'E'+dt.extraColumns()))
+}
+
+// RoundValues makes Append write each donation's value rounded to the
+// display precision (see donation.SetDisplayPrecision), instead of to the
+// cent. The sheet's totals will then be sums of rounded values.
+func (dt *DonationTable) RoundValues() {
+	dt.roundValues = true
+}
+
+// OnWrite sets a func to call after each write to the table, e.g. to mirror
+// the writes elsewhere. Writes are reported in the order they were made.
+func (dt *DonationTable) OnWrite(f func(Write)) {
+	dt.onWrite = f
+}
+
+// Writes returns how many times the table has been written to, so that
+// callers can tell when something they read from the spreadsheet is stale.
+func (dt *DonationTable) Writes() uint64 {
+	return atomic.LoadUint64(&dt.writes)
+}
+
+// LastWrite returns when the last successful write to the table finished,
+// or the zero Time if there hasn't been one.
+func (dt *DonationTable) LastWrite() time.Time {
+	n := atomic.LoadInt64(&dt.lastWrite)
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
 // Append adds a new donation to the end of the donation table.
 func (dt *DonationTable) Append(ev donation.Event, bidwarOption string, bidwarReason string) error {
 	dt.mu.Lock()
 	defer dt.mu.Unlock()
-	call := dt.srv.Values.Append(dt.spreadsheetID, dt.tableRange, &sheets.ValueRange{
-		Values: [][]interface{}{
-			{
-				ev.Owner,
-				ev.Description(),
-				ev.Value().String(),
-				bidwarOption,
-				bidwarReason,
-			},
+	defer atomic.AddUint64(&dt.writes, 1)
+	value := ev.Value().Exact()
+	if dt.roundValues {
+		value = ev.Value().String()
+	}
+	row := []interface{}{
+		ev.Owner,
+		ev.Description(),
+		value,
+		bidwarOption,
+		dt.reasonOptions.Clean(bidwarReason),
+	}
+	// A nil cell doesn't overwrite whatever is in an unused column.
+	extra := []interface{}{nil, nil, nil, nil, nil}
+	if dt.recordSource {
+		extra[0] = string(ev.Source)
+	}
+	if dt.recordCampaign {
+		extra[1] = ev.Campaign
+	}
+	if dt.recordOwnerID {
+		extra[2] = ev.OwnerID
+	}
+	if dt.recordTime && !ev.Time.IsZero() {
+		extra[3] = ev.Time.Local().Format(timeLayout)
+	}
+	if dt.recordNet && ev.Cash > 0 {
+		extra[4] = ev.NetCash().Exact()
+	}
+	row = append(row, extra[:dt.extraColumns()]...)
+	return dt.appendValues([][]interface{}{row})
+}
+
+// AppendRows adds rows to the end of the donation table. Each row has the
+// same columns as the table.
+func (dt *DonationTable) AppendRows(rows [][]interface{}) error {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	defer atomic.AddUint64(&dt.writes, 1)
+	return dt.appendValues(rows)
+}
+
+// WriteTableAndAppend makes the edits in vr (as WriteTable does) and adds rows
+// to the end of the donation table, in a single request, so that a failure
+// can't leave only some of them written. Like WriteTable, and unlike
+// AppendRows, it writes the values as they are, without parsing them.
+func (dt *DonationTable) WriteTableAndAppend(vr *sheets.ValueRange, rows [][]interface{}) error {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	defer atomic.AddUint64(&dt.writes, 1)
+	// Find the end of the table while holding dt.mu, so that the new rows
+	// can't land on a donation that was appended after vr was read.
+	cur, err := dt.srv.Values.Get(dt.spreadsheetID, dt.tableRange).MajorDimension("ROWS").Do()
+	if err != nil {
+		return fmt.Errorf("error finding the end of the donation table: %v", err)
+	}
+	first := int64(len(cur.Values)) + 1
+	last := first + int64(len(rows)) - 1
+	if err := dt.ensureFreeRows(last); err != nil {
+		return err
+	}
+	newRange := sheetRange(dt.sheetName, fmt.Sprintf("A%d:%c%d", first, 'E'+dt.extraColumns(), last))
+	req := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data: []*sheets.ValueRange{
+			vr,
+			{Range: newRange, MajorDimension: "ROWS", Values: rows},
 		},
-	})
+	}
+	if _, err := dt.srv.Values.BatchUpdate(dt.spreadsheetID, req).Do(); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&dt.lastWrite, time.Now().UnixNano())
+	if dt.onWrite != nil {
+		dt.onWrite(Write{Op: "update", Range: vr.Range, Rows: vr.Values})
+		dt.onWrite(Write{Op: "append", Range: newRange, Rows: rows})
+	}
+	return nil
+}
+
+// appendValues appends rows to the table. If the sheet is out of rows, more
+// are added and the append is retried. Afterwards, more rows are added if the
+// sheet is close to full, so that the next append doesn't fail. dt.mu must be
+// held.
+func (dt *DonationTable) appendValues(rows [][]interface{}) error {
+	resp, err := dt.doAppend(rows)
+	if isGridLimitError(err) {
+		log.Printf("ERROR the donation sheet is out of rows: %v", err)
+		if addErr := dt.addRows(); addErr != nil {
+			return fmt.Errorf("%v (and %v)", err, addErr)
+		}
+		resp, err = dt.doAppend(rows)
+	}
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&dt.lastWrite, time.Now().UnixNano())
+	if dt.onWrite != nil {
+		w := Write{Op: "append", Rows: rows}
+		if resp != nil && resp.Updates != nil {
+			w.Range = resp.Updates.UpdatedRange
+		}
+		dt.onWrite(w)
+	}
+	if resp != nil && resp.Updates != nil {
+		if lastRow, ok := lastRowOf(resp.Updates.UpdatedRange); ok {
+			if err := dt.ensureFreeRows(lastRow); err != nil {
+				// The append itself worked, so don't fail it.
+				log.Printf("ERROR checking for free rows in the donation sheet: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (dt *DonationTable) doAppend(rows [][]interface{}) (*sheets.AppendValuesResponse, error) {
+	call := dt.srv.Values.Append(dt.spreadsheetID, dt.tableRange, &sheets.ValueRange{Values: rows})
 	// We use OVERWRITE so that formula cells next to the table are preserved.
 	// When INSERT_ROWS inserts a row into the table, those formula cells are
 	// left empty.
 	call.InsertDataOption("OVERWRITE").ValueInputOption("USER_ENTERED")
-	if _, err := call.Do(); err != nil {
-		return err
-	}
-	return nil
+	return call.Do()
 }
 
 // GetTable returns the entire donation table, including header.
@@ -68,6 +346,7 @@ func (dt *DonationTable) GetTable() (*sheets.ValueRange, error) {
 func (dt *DonationTable) WriteTable(vr *sheets.ValueRange) (int, error) {
 	dt.mu.Lock()
 	defer dt.mu.Unlock()
+	defer atomic.AddUint64(&dt.writes, 1)
 	resp, err := dt.srv.Values.
 		Update(dt.spreadsheetID, vr.Range, vr).
 		ValueInputOption("RAW").
@@ -75,5 +354,9 @@ func (dt *DonationTable) WriteTable(vr *sheets.ValueRange) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	atomic.StoreInt64(&dt.lastWrite, time.Now().UnixNano())
+	if dt.onWrite != nil {
+		dt.onWrite(Write{Op: "update", Range: vr.Range, Rows: vr.Values})
+	}
 	return int(resp.UpdatedRows), nil
 }
@@ -0,0 +1,48 @@
+package googlesheets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtraColumnsRange(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		enable func(dt *DonationTable)
+		want   string
+	}{
+		{"none", func(dt *DonationTable) {}, "'Tracker'!A:E"},
+		{"source", func(dt *DonationTable) { dt.RecordSource() }, "'Tracker'!A:F"},
+		{"owner ID", func(dt *DonationTable) { dt.RecordOwnerID() }, "'Tracker'!A:H"},
+		{"time", func(dt *DonationTable) { dt.RecordTime() }, "'Tracker'!A:I"},
+		{"source and time", func(dt *DonationTable) { dt.RecordSource(); dt.RecordTime() }, "'Tracker'!A:I"},
+		{"net", func(dt *DonationTable) { dt.RecordNet() }, "'Tracker'!A:J"},
+	} {
+		dt := &DonationTable{sheetName: "Tracker", tableRange: "'Tracker'!A:E"}
+		tc.enable(dt)
+		if dt.tableRange != tc.want {
+			t.Errorf("%s: got range %q, want %q", tc.desc, dt.tableRange, tc.want)
+		}
+	}
+}
+
+func TestRowTime(t *testing.T) {
+	want := time.Date(2021, 10, 16, 12, 30, 15, 0, time.Local)
+	for _, tc := range []struct {
+		desc string
+		row  []interface{}
+		ok   bool
+	}{
+		{"serial number", []interface{}{"aerionblue", "donation", 5.0, "", "", "", "", "", 44485.521006944444}, true},
+		{"text", []interface{}{"aerionblue", "donation", 5.0, "", "", "", "", "", "2021-10-16 12:30:15"}, true},
+		{"empty", []interface{}{"aerionblue", "donation", 5.0, "", "", "", "", "", ""}, false},
+		{"short row", []interface{}{"aerionblue", "donation", 5.0}, false},
+	} {
+		got, ok := RowTime(tc.row)
+		if ok != tc.ok {
+			t.Errorf("%s: got ok %v, want %v", tc.desc, ok, tc.ok)
+		} else if ok && !got.Equal(want) {
+			t.Errorf("%s: got %v, want %v", tc.desc, got, want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -97,3 +98,14 @@ func saveTokenToFile(path string, token *oauth2.Token) error {
 	json.NewEncoder(f).Encode(token)
 	return nil
 }
+
+// sheetRange returns the A1 notation for some cells (e.g., "A:E") of the
+// named sheet, or for the whole sheet if cells is empty. The sheet name is
+// quoted, with any single quotes in it doubled, so that any name works.
+func sheetRange(sheetName string, cells string) string {
+	r := "'" + strings.ReplaceAll(sheetName, "'", "''") + "'"
+	if cells != "" {
+		r += "!" + cells
+	}
+	return r
+}
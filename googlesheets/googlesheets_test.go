@@ -0,0 +1,19 @@
+package googlesheets
+
+import "testing"
+
+func TestSheetRange(t *testing.T) {
+	for _, tc := range []struct {
+		sheetName string
+		cells     string
+		want      string
+	}{
+		{"Donations", "A:E", "'Donations'!A:E"},
+		{"Results", "", "'Results'"},
+		{"Aerion's bid wars", "A:F", "'Aerion''s bid wars'!A:F"},
+	} {
+		if got := sheetRange(tc.sheetName, tc.cells); got != tc.want {
+			t.Errorf("sheetRange(%q, %q): got %q, want %q", tc.sheetName, tc.cells, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package googlesheets
+
+import (
+	"fmt"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// WriteResults replaces the contents of the sheet with the given name with
+// rows, adding the sheet to the spreadsheet if it isn't there yet. It's for
+// saving a copy of a contest's donations once the contest is over.
+func WriteResults(srv *sheets.Service, spreadsheetID string, sheetName string, rows [][]interface{}) error {
+	ss, err := srv.Spreadsheets.Get(spreadsheetID).Do()
+	if err != nil {
+		return fmt.Errorf("error reading spreadsheet properties: %v", err)
+	}
+	exists := false
+	for _, sh := range ss.Sheets {
+		if sh.Properties != nil && sh.Properties.Title == sheetName {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		req := &sheets.BatchUpdateSpreadsheetRequest{
+			Requests: []*sheets.Request{{
+				AddSheet: &sheets.AddSheetRequest{
+					Properties: &sheets.SheetProperties{Title: sheetName},
+				},
+			}},
+		}
+		if _, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, req).Do(); err != nil {
+			return fmt.Errorf("error adding sheet %q: %v", sheetName, err)
+		}
+	}
+	whole := sheetRange(sheetName, "")
+	if _, err := srv.Spreadsheets.Values.Clear(spreadsheetID, whole, &sheets.ClearValuesRequest{}).Do(); err != nil {
+		return fmt.Errorf("error clearing sheet %q: %v", sheetName, err)
+	}
+	_, err = srv.Spreadsheets.Values.
+		Update(spreadsheetID, whole, &sheets.ValueRange{Values: rows}).
+		ValueInputOption("RAW").
+		Do()
+	if err != nil {
+		return fmt.Errorf("error writing sheet %q: %v", sheetName, err)
+	}
+	return nil
+}
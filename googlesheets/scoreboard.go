@@ -0,0 +1,44 @@
+package googlesheets
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// Scoreboard is a sheet that shows formatted bid war standings. Unlike the
+// donation table, it's safe to share with viewers, since it doesn't include
+// any donor info. The bot overwrites the whole sheet every time it publishes.
+type Scoreboard struct {
+	spreadsheetID string
+	sheetRange    string
+
+	mu  sync.Mutex
+	srv *sheets.SpreadsheetsService
+}
+
+func NewScoreboard(srv *sheets.Service, spreadsheetID string, sheetName string) *Scoreboard {
+	return &Scoreboard{
+		spreadsheetID: spreadsheetID,
+		sheetRange:    sheetRange(sheetName, "A:Z"),
+		srv:           srv.Spreadsheets,
+	}
+}
+
+// Publish replaces the contents of the scoreboard sheet with the given rows.
+func (s *Scoreboard) Publish(rows [][]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.srv.Values.Clear(s.spreadsheetID, s.sheetRange, &sheets.ClearValuesRequest{}).Do(); err != nil {
+		return fmt.Errorf("error clearing scoreboard: %v", err)
+	}
+	_, err := s.srv.Values.
+		Update(s.spreadsheetID, s.sheetRange, &sheets.ValueRange{Values: rows}).
+		ValueInputOption("USER_ENTERED").
+		Do()
+	if err != nil {
+		return fmt.Errorf("error writing scoreboard: %v", err)
+	}
+	return nil
+}
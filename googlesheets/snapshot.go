@@ -0,0 +1,50 @@
+package googlesheets
+
+import (
+	"fmt"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// SheetValues is the contents of one sheet of a spreadsheet.
+type SheetValues struct {
+	Title string
+	Rows  [][]interface{}
+}
+
+// Snapshot reads every sheet in the spreadsheet, for backups. Formulas are
+// read as formulas, so that a backup can be pasted back in to restore them.
+func Snapshot(srv *sheets.Service, spreadsheetID string) ([]SheetValues, error) {
+	ss, err := srv.Spreadsheets.Get(spreadsheetID).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error listing sheets: %v", err)
+	}
+	var titles, ranges []string
+	for _, sh := range ss.Sheets {
+		if sh.Properties == nil {
+			continue
+		}
+		titles = append(titles, sh.Properties.Title)
+		ranges = append(ranges, sheetRange(sh.Properties.Title, ""))
+	}
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	resp, err := srv.Spreadsheets.Values.
+		BatchGet(spreadsheetID).
+		Ranges(ranges...).
+		MajorDimension("ROWS").
+		ValueRenderOption("FORMULA").
+		Do()
+	if err != nil {
+		return nil, fmt.Errorf("error reading sheets: %v", err)
+	}
+	if len(resp.ValueRanges) != len(titles) {
+		return nil, fmt.Errorf("asked for %d sheets, got %d", len(titles), len(resp.ValueRanges))
+	}
+	snap := make([]SheetValues, len(titles))
+	for i, vr := range resp.ValueRanges {
+		snap[i] = SheetValues{Title: titles[i], Rows: vr.Values}
+	}
+	return snap, nil
+}
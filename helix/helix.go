@@ -0,0 +1,218 @@
+// Package helix looks up Twitch users with the Helix API, so that donors can
+// be keyed by their user ID, which (unlike their username) never changes. It
+// also sends whispers, which need user IDs too.
+package helix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/twitchapi"
+)
+
+const usersBaseUrl = "https://api.twitch.tv/helix/users"
+
+// The most logins that one users request can look up.
+const maxLoginsPerRequest = 100
+
+// The most IDs that a Client remembers. Past this, the ones it learned first
+// are forgotten, and looked up again if they're needed.
+const maxRemembered = 10000
+
+// The timeout for API requests, unless the caller provides its own HTTP client.
+const defaultHTTPTimeout = 30 * time.Second
+
+// What a Twitch login name can look like. Anything else (e.g., a tip name
+// with spaces in it) can't be a Twitch user, so it isn't looked up.
+var loginPattern = regexp.MustCompile(`^[a-z0-9_]{1,25}$`)
+
+// Client looks up Twitch user IDs, and remembers the most recent ones for the
+// rest of the run. A nil *Client knows no IDs.
+type Client struct {
+	httpClient *http.Client
+	// The URL of the users endpoint. Only overridden in tests.
+	baseUrl string
+	// The URL of the whispers endpoint. Only overridden in tests.
+	whispersUrl string
+	creds       twitchapi.Creds
+
+	mu sync.Mutex
+	// Maps a lowercase login to its user ID, or to "" if Twitch has no such
+	// user.
+	ids map[string]string
+	// The keys of ids, in the order they were learned.
+	order []string
+	// Logins that are being looked up in the background.
+	pending map[string]bool
+	// The user ID of the access token's owner, once it's known.
+	selfID string
+}
+
+// NewClient creates a Client with the credentials in the given file. If
+// httpClient is nil, a client with a default timeout is used.
+func NewClient(credsPath string, httpClient *http.Client) (*Client, error) {
+	c, err := twitchapi.ReadCreds(credsPath, false)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return &Client{httpClient: httpClient, baseUrl: usersBaseUrl, whispersUrl: whispersBaseUrl, creds: c, ids: make(map[string]string), pending: make(map[string]bool)}, nil
+}
+
+// Remember records a user ID that was learned some other way (e.g., from the
+// tags on a chat message), so that it doesn't need to be looked up.
+func (c *Client) Remember(login string, id string) {
+	if c == nil || login == "" || id == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(strings.ToLower(login), id)
+}
+
+// store remembers a login's ID, forgetting the oldest one if there are too
+// many. c.mu must be held.
+func (c *Client) store(login string, id string) {
+	if _, ok := c.ids[login]; !ok {
+		c.order = append(c.order, login)
+	}
+	c.ids[login] = id
+	for len(c.order) > maxRemembered {
+		delete(c.ids, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// KnownUserID returns the user ID for a login if it's already known, without
+// waiting on Twitch. If it isn't known, it's looked up in the background, so
+// that it's known next time. Returns "" if the ID isn't known yet.
+func (c *Client) KnownUserID(login string) string {
+	if c == nil {
+		return ""
+	}
+	login = strings.ToLower(login)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.ids[login]; ok {
+		return id
+	}
+	if loginPattern.MatchString(login) && !c.pending[login] {
+		c.pending[login] = true
+		go func() {
+			if _, err := c.UserIDs([]string{login}); err != nil {
+				log.Printf("ERROR looking up Twitch user ID for %s: %v", login, err)
+			}
+			c.mu.Lock()
+			delete(c.pending, login)
+			c.mu.Unlock()
+		}()
+	}
+	return ""
+}
+
+// UserID returns the user ID for a login, looking it up if necessary.
+// Returns "" if there's no such user, or if the lookup failed.
+func (c *Client) UserID(login string) string {
+	if c == nil {
+		return ""
+	}
+	ids, err := c.UserIDs([]string{login})
+	if err != nil {
+		log.Printf("ERROR looking up Twitch user ID for %s: %v", login, err)
+		return ""
+	}
+	return ids[strings.ToLower(login)]
+}
+
+// UserIDs looks up the user IDs for many logins at once. The result is keyed
+// by lowercase login, and leaves out logins that aren't Twitch users.
+func (c *Client) UserIDs(logins []string) (map[string]string, error) {
+	result := make(map[string]string)
+	var missing []string
+	c.mu.Lock()
+	for _, login := range logins {
+		login = strings.ToLower(login)
+		if id, ok := c.ids[login]; ok {
+			if id != "" {
+				result[login] = id
+			}
+		} else if loginPattern.MatchString(login) {
+			missing = append(missing, login)
+		}
+	}
+	c.mu.Unlock()
+
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > maxLoginsPerRequest {
+			batch = batch[:maxLoginsPerRequest]
+		}
+		missing = missing[len(batch):]
+		found, err := c.fetch(batch)
+		if err != nil {
+			return result, err
+		}
+		c.mu.Lock()
+		for _, login := range batch {
+			// Remember the logins that Twitch doesn't know, too, so that we
+			// don't keep asking.
+			c.store(login, found[login])
+			if found[login] != "" {
+				result[login] = found[login]
+			}
+		}
+		c.mu.Unlock()
+	}
+	return result, nil
+}
+
+// fetch asks Twitch for the user IDs of the given logins. With no logins, it
+// asks for the user who owns the access token.
+func (c *Client) fetch(logins []string) (map[string]string, error) {
+	q := url.Values{}
+	for _, login := range logins {
+		q.Add("login", login)
+	}
+	req, err := http.NewRequest(http.MethodGet, c.baseUrl+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.creds.Authorize(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending users request: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading users response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("users request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	var ur struct {
+		Data []struct {
+			ID    string `json:"id"`
+			Login string `json:"login"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &ur); err != nil {
+		return nil, fmt.Errorf("error parsing users response: %v", err)
+	}
+	found := make(map[string]string)
+	for _, u := range ur.Data {
+		found[strings.ToLower(u.Login)] = u.ID
+	}
+	return found, nil
+}
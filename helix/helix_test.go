@@ -0,0 +1,108 @@
+package helix
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/aerionblue/pizzafest/twitchapi"
+)
+
+func TestUserIDs(t *testing.T) {
+	known := map[string]string{"aerionblue": "1234", "usedpizza": "5678"}
+	var requests [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Client-Id") != "client" {
+			http.Error(w, "bad auth", http.StatusUnauthorized)
+			return
+		}
+		logins := r.URL.Query()["login"]
+		requests = append(requests, logins)
+		var users []string
+		for _, login := range logins {
+			if id, ok := known[login]; ok {
+				users = append(users, fmt.Sprintf(`{"id": %q, "login": %q}`, id, login))
+			}
+		}
+		fmt.Fprintf(w, `{"data": [%s]}`, strings.Join(users, ","))
+	}))
+	defer srv.Close()
+	c := &Client{httpClient: srv.Client(), baseUrl: srv.URL, creds: twitchapi.Creds{ClientID: "client", AccessToken: "token"}, ids: make(map[string]string), pending: make(map[string]bool)}
+	c.Remember("Mizalie", "999")
+
+	got, err := c.UserIDs([]string{"AerionBlue", "usedpizza", "nobody", "Mizalie", "Cool Dude 99"})
+	if err != nil {
+		t.Fatalf("UserIDs: %v", err)
+	}
+	want := map[string]string{"aerionblue": "1234", "usedpizza": "5678", "mizalie": "999"}
+	if !cmp.Equal(got, want) {
+		t.Errorf(cmp.Diff(got, want))
+	}
+	// Known IDs and unknown users are both remembered.
+	if id := c.UserID("usedpizza"); id != "5678" {
+		t.Errorf("got ID %q for usedpizza, want 5678", id)
+	}
+	if id := c.UserID("nobody"); id != "" {
+		t.Errorf("got ID %q for nobody, want none", id)
+	}
+	if want := [][]string{{"aerionblue", "usedpizza", "nobody"}}; !cmp.Equal(requests, want) {
+		t.Errorf("wrong requests: %v", cmp.Diff(requests, want))
+	}
+
+	var nilClient *Client
+	if id := nilClient.UserID("aerionblue"); id != "" {
+		t.Errorf("nil Client returned ID %q", id)
+	}
+}
+
+func TestKnownUserID(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"data": [{"id": "1234", "login": %q}]}`, r.URL.Query().Get("login"))
+	}))
+	defer srv.Close()
+	c := &Client{httpClient: srv.Client(), baseUrl: srv.URL, ids: make(map[string]string), pending: make(map[string]bool)}
+
+	// The first call doesn't wait for Twitch.
+	if id := c.KnownUserID("AerionBlue"); id != "" {
+		t.Errorf("got ID %q before the lookup finished, want none", id)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.KnownUserID("aerionblue") == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("the background lookup never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if id := c.KnownUserID("aerionblue"); id != "1234" {
+		t.Errorf("got ID %q after the lookup, want 1234", id)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+	if id := c.KnownUserID("Cool Dude 99"); id != "" {
+		t.Errorf("got ID %q for a name that can't be a login", id)
+	}
+}
+
+func TestRememberForgetsOldest(t *testing.T) {
+	c := &Client{ids: make(map[string]string), pending: make(map[string]bool)}
+	for i := 0; i < maxRemembered+1; i++ {
+		c.Remember(fmt.Sprintf("user%d", i), fmt.Sprint(i))
+	}
+	if len(c.ids) != maxRemembered {
+		t.Errorf("remembered %d IDs, want %d", len(c.ids), maxRemembered)
+	}
+	if _, ok := c.ids["user0"]; ok {
+		t.Errorf("the oldest ID wasn't forgotten")
+	}
+	if id := c.ids[fmt.Sprintf("user%d", maxRemembered)]; id != fmt.Sprint(maxRemembered) {
+		t.Errorf("got ID %q for the newest user, want %d", id, maxRemembered)
+	}
+}
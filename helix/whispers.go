@@ -0,0 +1,81 @@
+package helix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const whispersBaseUrl = "https://api.twitch.tv/helix/whispers"
+
+// Whisper sends a whisper to a user, from the user who owns the Client's
+// access token. The token needs the user:manage:whispers scope, and Twitch
+// only lets accounts with a verified phone number send whispers.
+func (c *Client) Whisper(to string, msg string) error {
+	from, err := c.ownID()
+	if err != nil {
+		return err
+	}
+	ids, err := c.UserIDs([]string{to})
+	if err != nil {
+		return err
+	}
+	toID, ok := ids[strings.ToLower(to)]
+	if !ok {
+		return fmt.Errorf("%s isn't a Twitch user", to)
+	}
+	body, err := json.Marshal(struct {
+		Message string `json:"message"`
+	}{msg})
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	q.Set("from_user_id", from)
+	q.Set("to_user_id", toID)
+	req, err := http.NewRequest(http.MethodPost, c.whispersUrl+"?"+q.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	c.creds.Authorize(req)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending whisper request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("whisper request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	return nil
+}
+
+// ownID returns the user ID of the access token's owner, looking it up the
+// first time.
+func (c *Client) ownID() (string, error) {
+	c.mu.Lock()
+	id := c.selfID
+	c.mu.Unlock()
+	if id != "" {
+		return id, nil
+	}
+	found, err := c.fetch(nil)
+	if err != nil {
+		return "", err
+	}
+	if len(found) == 1 {
+		for _, id := range found {
+			c.mu.Lock()
+			c.selfID = id
+			c.mu.Unlock()
+			return id, nil
+		}
+	}
+	return "", errors.New("couldn't tell whose Twitch API access token this is")
+}
@@ -0,0 +1,69 @@
+package helix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/twitchapi"
+)
+
+func TestWhisper(t *testing.T) {
+	var got []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("login") {
+		case "":
+			// The token belongs to the bot.
+			fmt.Fprint(w, `{"data": [{"id": "42", "login": "pizzabot"}]}`)
+		case "aerionblue":
+			fmt.Fprint(w, `{"data": [{"id": "1234", "login": "aerionblue"}]}`)
+		default:
+			fmt.Fprint(w, `{"data": []}`)
+		}
+	})
+	mux.HandleFunc("/whispers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("to_user_id") == "1234" && body.Message == "too long" {
+			http.Error(w, "message too long", http.StatusBadRequest)
+			return
+		}
+		got = append(got, fmt.Sprintf("%s->%s: %s", r.URL.Query().Get("from_user_id"), r.URL.Query().Get("to_user_id"), body.Message))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := &Client{
+		httpClient:  srv.Client(),
+		baseUrl:     srv.URL + "/users",
+		whispersUrl: srv.URL + "/whispers",
+		creds:       twitchapi.Creds{ClientID: "client", AccessToken: "token"},
+		ids:         make(map[string]string),
+		pending:     make(map[string]bool),
+	}
+
+	if err := c.Whisper("AerionBlue", "I put your sub towards Moo Moo Meadows."); err != nil {
+		t.Errorf("whispering aerionblue: got error %v", err)
+	}
+	if err := c.Whisper("nobody", "hello"); err == nil {
+		t.Error("whispering a user who doesn't exist should fail")
+	}
+	if err := c.Whisper("aerionblue", "too long"); err == nil {
+		t.Error("a whisper that Twitch rejects should fail")
+	}
+	if want := []string{"42->1234: I put your sub towards Moo Moo Meadows."}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got whispers %q, want %q", got, want)
+	}
+}
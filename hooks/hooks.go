@@ -0,0 +1,93 @@
+// Package hooks runs custom code after each donation is recorded, so that
+// event organizers can bolt on their own behavior (lights, sound boards, etc.)
+// without changing the bot.
+//
+// There are two ways to add a hook. A Go hook implements Hook and is added
+// with Register, usually from an init func in a separate file of package main.
+// A Script hook runs an external program, which receives the donation as JSON
+// on stdin.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Hook is called after each donation is recorded.
+type Hook interface {
+	OnDonation(ev donation.Event, bid bidwar.Choice) error
+}
+
+var (
+	mu         sync.Mutex
+	registered []Hook
+)
+
+// Register adds a Hook that is built into the bot.
+func Register(h Hook) {
+	mu.Lock()
+	defer mu.Unlock()
+	registered = append(registered, h)
+}
+
+// Registered returns all the Hooks that were added with Register.
+func Registered() []Hook {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Hook(nil), registered...)
+}
+
+// scriptInput is what a Script receives on stdin. The event uses the same
+// format as everywhere else (see donation.Event's MarshalJSON). For example:
+//
+//	{
+//	  "event": {"source": "twitch_bits", "owner": "Mizalie", "bits": 444, "message": "usedU"},
+//	  "choice": "Moo",
+//	  "reason": "[chat] moo"
+//	}
+//
+// "choice" and "reason" are omitted if the donation wasn't assigned to a bid
+// war option.
+type scriptInput struct {
+	Event  donation.Event `json:"event"`
+	Choice string         `json:"choice,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+}
+
+// Script is a Hook that runs an external program for each donation.
+type Script struct {
+	command []string
+	timeout time.Duration
+}
+
+// NewScript creates a Script that runs the given command (a program and its
+// arguments). The program is killed if it runs longer than the timeout.
+func NewScript(command []string, timeout time.Duration) *Script {
+	return &Script{command: command, timeout: timeout}
+}
+
+func (s *Script) OnDonation(ev donation.Event, bid bidwar.Choice) error {
+	if len(s.command) == 0 {
+		return nil
+	}
+	input, err := json.Marshal(scriptInput{Event: ev, Choice: bid.Option.ShortCode, Reason: bid.Reason})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("hook script %s failed: %v: %s", s.command[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
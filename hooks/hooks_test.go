@@ -0,0 +1,55 @@
+package hooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestScript(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.json")
+	s := NewScript([]string{"sh", "-c", `cat > "$0"`, out}, 5*time.Second)
+	ev := donation.Event{Source: donation.SourceStreamlabs, Owner: "ShartyMcFly", Cash: donation.CentsValue(1100), Message: "team moo"}
+	bid := bidwar.Choice{Option: bidwar.Option{ShortCode: "Moo"}, Reason: "[donation msg] team moo"}
+	if err := s.OnDonation(ev, bid); err != nil {
+		t.Fatalf("error running script: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("script didn't write its input: %v", err)
+	}
+	var got scriptInput
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("script got malformed input %q: %v", data, err)
+	}
+	if got.Event.Owner != ev.Owner || got.Event.Cash != ev.Cash || got.Event.Source != ev.Source {
+		t.Errorf("got event %+v, want %+v", got.Event, ev)
+	}
+	if got.Choice != "Moo" || got.Reason != bid.Reason {
+		t.Errorf("got choice %q (%q), want %q (%q)", got.Choice, got.Reason, "Moo", bid.Reason)
+	}
+}
+
+func TestScript_Failure(t *testing.T) {
+	s := NewScript([]string{"sh", "-c", "echo oops; exit 1"}, 5*time.Second)
+	if err := s.OnDonation(donation.Event{Owner: "aerionblue"}, bidwar.Choice{}); err == nil {
+		t.Errorf("got no error from a failing script")
+	}
+}
+
+func TestScript_Timeout(t *testing.T) {
+	s := NewScript([]string{"sleep", "10"}, 50*time.Millisecond)
+	start := time.Now()
+	if err := s.OnDonation(donation.Event{Owner: "aerionblue"}, bidwar.Choice{}); err == nil {
+		t.Errorf("got no error from a script that timed out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("script wasn't killed after its timeout; took %v", elapsed)
+	}
+}
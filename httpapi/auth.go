@@ -0,0 +1,69 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Scope is the access level that an endpoint requires.
+type Scope int
+
+const (
+	// Read endpoints only report the bid wars, e.g. for overlays. They are
+	// public unless a read token is set.
+	Read Scope = iota
+	// Admin endpoints change the bot's state, or show what donors wrote.
+	// They are disabled unless an admin token is set.
+	Admin
+)
+
+// SetTokens sets the tokens that clients must present, either as an
+// "Authorization: Bearer" header or as a "token" query parameter (for
+// browser sources that can't set headers). If readToken is empty, the read
+// endpoints are public. If adminToken is empty, the admin endpoints are
+// disabled. The admin token also grants read access.
+func (s *Server) SetTokens(readToken, adminToken string) {
+	s.readToken = readToken
+	s.adminToken = adminToken
+}
+
+// Handle registers a handler for the given pattern, requiring the given scope.
+func (s *Server) Handle(pattern string, scope Scope, h http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r, scope) {
+			if scope == Admin && s.adminToken == "" {
+				http.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	})
+}
+
+func (s *Server) authorized(r *http.Request, scope Scope) bool {
+	token := requestToken(r)
+	if s.adminToken != "" && tokensEqual(token, s.adminToken) {
+		return true
+	}
+	if scope == Admin {
+		return false
+	}
+	return s.readToken == "" || tokensEqual(token, s.readToken)
+}
+
+// requestToken returns the token the client presented, or "" if none.
+func requestToken(r *http.Request) string {
+	const prefix = "bearer "
+	if h := r.Header.Get("Authorization"); len(h) > len(prefix) && strings.ToLower(h[:len(prefix)]) == prefix {
+		return strings.TrimSpace(h[len(prefix):])
+	}
+	return r.URL.Query().Get("token")
+}
+
+func tokensEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+func TestTokens(t *testing.T) {
+	s := NewServer(func() bidwar.Collection { return bidwar.Collection{} })
+	s.Handle("/admin", Admin, func(w http.ResponseWriter, r *http.Request) {})
+
+	status := func(path string, header string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if got := status("/schedule.json", ""); got != http.StatusOK {
+		t.Errorf("no tokens set: read got status %d, want 200", got)
+	}
+	if got := status("/admin", ""); got != http.StatusForbidden {
+		t.Errorf("no tokens set: admin got status %d, want 403", got)
+	}
+
+	s.SetTokens("reader", "admin")
+	for _, tc := range []struct {
+		path   string
+		header string
+		want   int
+	}{
+		{"/schedule.json", "", http.StatusUnauthorized},
+		{"/schedule.json", "Bearer wrong", http.StatusUnauthorized},
+		{"/schedule.json", "Bearer reader", http.StatusOK},
+		{"/schedule.json", "bearer admin", http.StatusOK},
+		{"/schedule.json?token=reader", "", http.StatusOK},
+		{"/admin", "Bearer reader", http.StatusUnauthorized},
+		{"/admin?token=reader", "", http.StatusUnauthorized},
+		{"/admin", "Bearer admin", http.StatusOK},
+	} {
+		if got := status(tc.path, tc.header); got != tc.want {
+			t.Errorf("%s with %q: got status %d, want %d", tc.path, tc.header, got, tc.want)
+		}
+	}
+}
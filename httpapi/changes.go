@@ -0,0 +1,42 @@
+package httpapi
+
+import (
+	"net/http"
+)
+
+// SetChanges enables the /changes endpoint, which streams the change feed as
+// JSON lines until the client disconnects. subscribe is called once per
+// request. The feed has donors' messages in it, so it needs the admin token.
+func (s *Server) SetChanges(subscribe func() (<-chan []byte, func())) {
+	s.changes = subscribe
+}
+
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if s.changes == nil || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	ch, done := s.changes()
+	defer done()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line, ok := <-ch:
+			if !ok {
+				// The client fell behind. It can reconnect and catch up from
+				// the feed file.
+				return
+			}
+			if _, err := w.Write(line); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
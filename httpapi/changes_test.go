@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+func TestChanges(t *testing.T) {
+	s := NewServer(func() bidwar.Collection { return bidwar.Collection{} })
+	s.SetTokens("reader", "admin")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/changes?token=admin", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without a feed: got status %d, want 404", rec.Code)
+	}
+
+	ch := make(chan []byte, 2)
+	s.SetChanges(func() (<-chan []byte, func()) { return ch, func() {} })
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/changes?token=reader")
+	if err != nil {
+		t.Fatalf("GET /changes: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("with the read token: got status %d, want 401", resp.StatusCode)
+	}
+	resp, err = http.Get(srv.URL + "/changes?token=admin")
+	if err != nil {
+		t.Fatalf("GET /changes: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	ch <- []byte("{\"op\":\"append\"}\n")
+	ch <- []byte("{\"op\":\"update\"}\n")
+	r := bufio.NewReader(resp.Body)
+	for _, want := range []string{"{\"op\":\"append\"}\n", "{\"op\":\"update\"}\n"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		if line != want {
+			t.Errorf("got %q, want %q", line, want)
+		}
+	}
+	// Closing the channel ends the stream.
+	close(ch)
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Error("expected the stream to end")
+	}
+}
@@ -0,0 +1,58 @@
+// Package httpapi serves bid war info over HTTP, for use by stream overlays
+// and the event website.
+package httpapi
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// Server is an HTTP handler for the bot's public endpoints.
+type Server struct {
+	mux *http.ServeMux
+	// Returns the current set of bid wars.
+	collection func() bidwar.Collection
+	// Returns a contest's current totals. May be nil; see SetTotals.
+	totals  func(bidwar.Contest) (bidwar.Totals, error)
+	widgets widgetCache
+	// Returns the grand total. May be nil; see SetGrandTotal.
+	grandTotal  func() (donation.CentsValue, error)
+	grandTotals grandTotalCache
+	// Returns the running timers. May be nil; see SetTimers.
+	timers func() []Timer
+	// Subscribes to the change feed. May be nil; see SetChanges.
+	changes func() (<-chan []byte, func())
+	// The tokens that clients must present; see SetTokens.
+	readToken  string
+	adminToken string
+}
+
+// NewServer creates a Server. The collection func is called on every request,
+// so that the responses reflect any changes made while the bot is running.
+func NewServer(collection func() bidwar.Collection) *Server {
+	s := &Server{
+		mux:        http.NewServeMux(),
+		collection: collection,
+	}
+	s.Handle("/schedule.json", Read, s.handleScheduleJSON)
+	s.Handle("/schedule.ics", Read, s.handleScheduleICal)
+	s.Handle("/widget/", Read, s.handleWidget)
+	s.Handle("/standings", Read, s.handleStandings)
+	s.Handle("/timers.json", Read, s.handleTimers)
+	s.Handle("/changes", Admin, s.handleChanges)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves HTTP requests on the given address. It only returns
+// if the server fails.
+func (s *Server) ListenAndServe(addr string) error {
+	log.Printf("serving HTTP on %s", addr)
+	return http.ListenAndServe(addr, s)
+}
@@ -0,0 +1,97 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+var /* const */ nonSlugChars = regexp.MustCompile("[^a-z0-9]+")
+
+// scheduleEntry is one contest in the JSON schedule feed.
+type scheduleEntry struct {
+	Contest  string     `json:"contest"`
+	Closed   bool       `json:"closed"`
+	OpensAt  *time.Time `json:"opensAt,omitempty"`
+	ClosesAt *time.Time `json:"closesAt,omitempty"`
+}
+
+func scheduleEntries(c bidwar.Collection) []scheduleEntry {
+	entries := make([]scheduleEntry, 0, len(c.Contests))
+	for _, con := range c.Contests {
+		e := scheduleEntry{Contest: con.Name, Closed: con.Closed}
+		if !con.OpensAt.IsZero() {
+			t := con.OpensAt
+			e.OpensAt = &t
+		}
+		if !con.ClosesAt.IsZero() {
+			t := con.ClosesAt
+			e.ClosesAt = &t
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func (s *Server) handleScheduleJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(scheduleEntries(s.collection())); err != nil {
+		log.Printf("ERROR writing schedule JSON: %v", err)
+	}
+}
+
+func (s *Server) handleScheduleICal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if _, err := w.Write(scheduleICal(s.collection(), time.Now())); err != nil {
+		log.Printf("ERROR writing schedule iCal: %v", err)
+	}
+}
+
+// scheduleICal returns an iCalendar document with one event for each contest
+// that has a close time. The event runs from the contest's open time (if
+// known) to its close time.
+func scheduleICal(c bidwar.Collection, now time.Time) []byte {
+	var lines []string
+	lines = append(lines,
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//pizzafest//bid war schedule//EN",
+	)
+	for _, con := range c.Contests {
+		if con.ClosesAt.IsZero() {
+			continue
+		}
+		start := con.OpensAt
+		if start.IsZero() {
+			start = con.ClosesAt
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%s@pizzafest", slug(con.Name)),
+			"DTSTAMP:"+now.UTC().Format(icalTimeFormat),
+			"DTSTART:"+start.UTC().Format(icalTimeFormat),
+			"DTEND:"+con.ClosesAt.UTC().Format(icalTimeFormat),
+			"SUMMARY:"+icalEscape(fmt.Sprintf("Bidding for %s closes", con.Name)),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func slug(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// icalEscape escapes a TEXT value as described in RFC 5545 section 3.3.11.
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
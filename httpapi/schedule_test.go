@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+const scheduleJSON = `{
+    "contests": [
+        {
+            "name": "Mario Kart track",
+            "opensAt": "2022-07-01T20:00:00-04:00",
+            "closesAt": "2022-07-01T21:30:00-04:00",
+            "options": []
+        },
+        {
+            "name": "Devil May Cry; the sequel",
+            "closesAt": "2022-07-02T02:00:00Z",
+            "options": []
+        },
+        {
+            "name": "Unscheduled",
+            "options": []
+        }
+    ]
+}`
+
+func TestScheduleICal(t *testing.T) {
+	c, err := bidwar.Parse([]byte(scheduleJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	got := string(scheduleICal(c, now))
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//pizzafest//bid war schedule//EN",
+		"BEGIN:VEVENT",
+		"UID:mario-kart-track@pizzafest",
+		"DTSTAMP:20220701T120000Z",
+		"DTSTART:20220702T000000Z",
+		"DTEND:20220702T013000Z",
+		"SUMMARY:Bidding for Mario Kart track closes",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:devil-may-cry-the-sequel@pizzafest",
+		"DTSTAMP:20220701T120000Z",
+		"DTSTART:20220702T020000Z",
+		"DTEND:20220702T020000Z",
+		`SUMMARY:Bidding for Devil May Cry\; the sequel closes`,
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestScheduleJSON(t *testing.T) {
+	c, err := bidwar.Parse([]byte(scheduleJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	s := NewServer(func() bidwar.Collection { return c })
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/schedule.json", nil))
+
+	want := `[{"contest":"Mario Kart track","closed":false,"opensAt":"2022-07-01T20:00:00-04:00","closesAt":"2022-07-01T21:30:00-04:00"},` +
+		`{"contest":"Devil May Cry; the sequel","closed":false,"closesAt":"2022-07-02T02:00:00Z"},` +
+		`{"contest":"Unscheduled","closed":false}]` + "\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package httpapi
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+var standingsTemplate = template.Must(template.New("standings").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="{{.RefreshSeconds}}">
+<title>Bid war standings</title>
+<style>
+body { max-width: 40em; margin: 1em auto; padding: 0 1em; font-family: sans-serif; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th { text-align: left; border-bottom: 1px solid #888; }
+td.total { text-align: right; }
+tr.closed { opacity: 0.5; }
+.updated { color: #888; font-size: small; }
+</style>
+</head>
+<body>
+<h1>Bid war standings</h1>
+{{if .HasGrandTotal}}<p>Raised so far: <strong>${{.GrandTotal}}</strong></p>
+{{end}}{{range .Contests}}<table>
+<tr><th colspan="2">{{.Contest}}{{if .Closed}} (closed){{end}}</th></tr>
+{{if .Error}}<tr><td colspan="2">Couldn't read the standings. Try again in a bit.</td></tr>
+{{end}}{{range .Rows}}<tr{{if .Closed}} class="closed"{{end}}><td>{{.Name}}</td><td class="total">{{.Total}}</td></tr>
+{{end}}</table>
+{{end}}<p class="updated">Updated {{.Updated}}. This page refreshes every {{.RefreshSeconds}} seconds.</p>
+</body>
+</html>
+`))
+
+type standingsPage struct {
+	RefreshSeconds int
+	HasGrandTotal  bool
+	GrandTotal     string
+	Contests       []standingsContest
+	Updated        string
+}
+
+type standingsContest struct {
+	widgetPage
+	// Whether the contest's totals couldn't be read.
+	Error bool
+}
+
+// grandTotalCache holds the grand total for widgetRefresh.
+type grandTotalCache struct {
+	mu      sync.Mutex
+	value   donation.CentsValue
+	fetched time.Time
+}
+
+// SetGrandTotal adds the grand total, as returned by the given func, to the
+// /standings page.
+func (s *Server) SetGrandTotal(grandTotal func() (donation.CentsValue, error)) {
+	s.grandTotal = grandTotal
+}
+
+// handleStandings serves /standings, a self-refreshing HTML page with the
+// standings of every contest and the grand total, for viewers who want more
+// detail than the chat summaries.
+func (s *Server) handleStandings(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/standings" || s.totals == nil {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now()
+	page := standingsPage{
+		RefreshSeconds: int(widgetRefresh / time.Second),
+		Updated:        now.Format("Jan 2 3:04 PM MST"),
+	}
+	if s.grandTotal != nil {
+		total, err := s.cachedGrandTotal(now)
+		if err != nil {
+			log.Printf("ERROR reading the grand total for the standings page: %v", err)
+		} else {
+			page.HasGrandTotal = true
+			page.GrandTotal = total.String()
+		}
+	}
+	for _, con := range s.collection().Contests {
+		totals, err := s.contestTotals(con, now)
+		if err != nil {
+			log.Printf("ERROR reading totals of %s for the standings page: %v", con.Name, err)
+			page.Contests = append(page.Contests, standingsContest{widgetPage: widgetPage{Contest: con.Name, Closed: con.Closed}, Error: true})
+			continue
+		}
+		page.Contests = append(page.Contests, standingsContest{widgetPage: makeWidgetPage(con, totals)})
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := standingsTemplate.Execute(w, page); err != nil {
+		log.Printf("ERROR writing the standings page: %v", err)
+	}
+}
+
+func (s *Server) cachedGrandTotal(now time.Time) (donation.CentsValue, error) {
+	s.grandTotals.mu.Lock()
+	defer s.grandTotals.mu.Unlock()
+	if !s.grandTotals.fetched.IsZero() && now.Sub(s.grandTotals.fetched) < widgetRefresh {
+		return s.grandTotals.value, nil
+	}
+	total, err := s.grandTotal()
+	if err != nil {
+		return 0, err
+	}
+	s.grandTotals.value = total
+	s.grandTotals.fetched = now
+	return total, nil
+}
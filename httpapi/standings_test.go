@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestStandings(t *testing.T) {
+	c, err := bidwar.Parse([]byte(widgetJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	s := NewServer(func() bidwar.Collection { return c })
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/standings", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without totals: got status %d, want 404", rec.Code)
+	}
+
+	s.SetTotals(func(con bidwar.Contest) (bidwar.Totals, error) {
+		return bidwar.Totals{}, nil
+	})
+	calls := 0
+	s.SetGrandTotal(func() (donation.CentsValue, error) {
+		calls++
+		return donation.CentsValue(123456), nil
+	})
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", "/standings", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200", rec.Code)
+		}
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"Raised so far: <strong>$1234.56</strong>",
+		"<th colspan=\"2\">Mario Kart track</th>",
+		"<td>Moo Moo Meadows</td><td class=\"total\">0.00</td>",
+		`<tr class="closed"><td>Neo &lt;Bowser&gt; City</td>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body doesn't contain %q:\n%s", want, body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("read the grand total %d times, want 1", calls)
+	}
+}
+
+func TestStandingsError(t *testing.T) {
+	c, err := bidwar.Parse([]byte(widgetJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	s := NewServer(func() bidwar.Collection { return c })
+	s.SetTotals(func(con bidwar.Contest) (bidwar.Totals, error) {
+		return bidwar.Totals{}, errors.New("quota exceeded")
+	})
+	s.SetGrandTotal(func() (donation.CentsValue, error) {
+		return 0, errors.New("quota exceeded")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/standings", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "Raised so far") {
+		t.Errorf("body shouldn't show a grand total:\n%s", body)
+	}
+	if !strings.Contains(body, "Couldn't read the standings") {
+		t.Errorf("body doesn't mention the error:\n%s", body)
+	}
+}
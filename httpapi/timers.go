@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Timer is a running incentive timer (see !timer).
+type Timer struct {
+	Name   string    `json:"name"`
+	EndsAt time.Time `json:"endsAt"`
+	// Filled in when the timer is served.
+	SecondsLeft int `json:"secondsLeft"`
+}
+
+// SetTimers enables the /timers.json endpoint, which lists the timers
+// returned by the timers func.
+func (s *Server) SetTimers(timers func() []Timer) {
+	s.timers = timers
+}
+
+func (s *Server) handleTimers(w http.ResponseWriter, r *http.Request) {
+	if s.timers == nil {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now()
+	timers := make([]Timer, 0)
+	for _, t := range s.timers() {
+		t.SecondsLeft = int((t.EndsAt.Sub(now) + time.Second - 1) / time.Second)
+		if t.SecondsLeft < 0 {
+			t.SecondsLeft = 0
+		}
+		timers = append(timers, t)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(timers); err != nil {
+		log.Printf("ERROR writing timers JSON: %v", err)
+	}
+}
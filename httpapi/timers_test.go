@@ -0,0 +1,35 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+func TestTimers(t *testing.T) {
+	s := NewServer(func() bidwar.Collection { return bidwar.Collection{} })
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/timers.json", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without timers: got status %d, want 404", rec.Code)
+	}
+
+	ends := time.Now().Add(90 * time.Second)
+	s.SetTimers(func() []Timer { return []Timer{{Name: "blindfold", EndsAt: ends}} })
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/timers.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	var got []Timer
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("error parsing response: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "blindfold" || !got[0].EndsAt.Equal(ends) || got[0].SecondsLeft < 89 || got[0].SecondsLeft > 90 {
+		t.Errorf("got %+v, want blindfold with 90 seconds left", got)
+	}
+}
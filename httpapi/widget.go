@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+// How often the widget page reloads itself. Standings are cached for this
+// long, so that a page embedded in front of lots of viewers doesn't use up
+// the Sheets quota.
+const widgetRefresh = 30 * time.Second
+
+var widgetTemplate = template.Must(template.New("widget").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.RefreshSeconds}}">
+<title>{{.Contest}}</title>
+<style>
+body { margin: 0; font-family: sans-serif; background: transparent; }
+table { border-collapse: collapse; width: 100%; }
+th { text-align: left; }
+td.total { text-align: right; }
+tr.closed { opacity: 0.5; }
+</style>
+</head>
+<body>
+<table>
+<tr><th colspan="2">{{.Contest}}{{if .Closed}} (closed){{end}}</th></tr>
+{{range .Rows}}<tr{{if .Closed}} class="closed"{{end}}><td>{{.Name}}</td><td class="total">{{.Total}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type widgetPage struct {
+	Contest        string
+	Closed         bool
+	RefreshSeconds int
+	Rows           []widgetRow
+}
+
+type widgetRow struct {
+	Name   string
+	Total  string
+	Closed bool
+}
+
+// widgetCache holds the standings of each contest for widgetRefresh.
+type widgetCache struct {
+	mu      sync.Mutex
+	entries map[string]widgetCacheEntry
+}
+
+type widgetCacheEntry struct {
+	totals  bidwar.Totals
+	fetched time.Time
+}
+
+// SetTotals enables the /widget/ pages, which show a contest's standings as
+// returned by the totals func.
+func (s *Server) SetTotals(totals func(bidwar.Contest) (bidwar.Totals, error)) {
+	s.totals = totals
+}
+
+// handleWidget serves /widget/{contest}, a small self-refreshing HTML page
+// with the standings of one contest, for embedding in the event website. The
+// contest can be given by name or as a slug (e.g., "mario-kart-track").
+func (s *Server) handleWidget(w http.ResponseWriter, r *http.Request) {
+	if s.totals == nil {
+		http.NotFound(w, r)
+		return
+	}
+	con, ok := findContest(s.collection(), strings.TrimPrefix(r.URL.Path, "/widget/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	totals, err := s.contestTotals(con, time.Now())
+	if err != nil {
+		log.Printf("ERROR reading totals for the %s widget: %v", con.Name, err)
+		http.Error(w, "couldn't read the standings", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := widgetTemplate.Execute(w, makeWidgetPage(con, totals)); err != nil {
+		log.Printf("ERROR writing the %s widget: %v", con.Name, err)
+	}
+}
+
+func (s *Server) contestTotals(con bidwar.Contest, now time.Time) (bidwar.Totals, error) {
+	s.widgets.mu.Lock()
+	defer s.widgets.mu.Unlock()
+	if e, ok := s.widgets.entries[con.Name]; ok && now.Sub(e.fetched) < widgetRefresh {
+		return e.totals, nil
+	}
+	totals, err := s.totals(con)
+	if err != nil {
+		return bidwar.Totals{}, err
+	}
+	if s.widgets.entries == nil {
+		s.widgets.entries = make(map[string]widgetCacheEntry)
+	}
+	s.widgets.entries[con.Name] = widgetCacheEntry{totals: totals, fetched: now}
+	return totals, nil
+}
+
+func makeWidgetPage(con bidwar.Contest, totals bidwar.Totals) widgetPage {
+	page := widgetPage{
+		Contest:        con.Name,
+		Closed:         con.Closed,
+		RefreshSeconds: int(widgetRefresh / time.Second),
+	}
+	for _, t := range totals.Standings(con) {
+		page.Rows = append(page.Rows, widgetRow{Name: t.Option.DisplayName, Total: totals.Format(t.Value), Closed: t.Option.Closed})
+	}
+	return page
+}
+
+// findContest looks up a contest by its name or its slug.
+func findContest(c bidwar.Collection, name string) (bidwar.Contest, bool) {
+	if con, ok := c.ContestByName(name); ok {
+		return con, true
+	}
+	for _, con := range c.Contests {
+		if slug(con.Name) == slug(name) {
+			return con, true
+		}
+	}
+	return bidwar.Contest{}, false
+}
@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+const widgetJSON = `{
+    "contests": [
+        {
+            "name": "Mario Kart track",
+            "options": [
+                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo"]},
+                {"displayName": "Neo <Bowser> City", "shortCode": "NBC", "aliases": ["nbc"], "closed": true}
+            ]
+        }
+    ]
+}`
+
+func TestWidget(t *testing.T) {
+	c, err := bidwar.Parse([]byte(widgetJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	s := NewServer(func() bidwar.Collection { return c })
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/widget/mario-kart-track", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("without totals: got status %d, want 404", rec.Code)
+	}
+
+	calls := 0
+	s.SetTotals(func(con bidwar.Contest) (bidwar.Totals, error) {
+		calls++
+		return bidwar.Totals{}, nil
+	})
+	for _, path := range []string{"/widget/mario-kart-track", "/widget/Mario%20Kart%20track"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", path, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			`<meta http-equiv="refresh" content="30">`,
+			"<td>Moo Moo Meadows</td><td class=\"total\">0.00</td>",
+			`<tr class="closed"><td>Neo &lt;Bowser&gt; City</td>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: body doesn't contain %q:\n%s", path, want, body)
+			}
+		}
+	}
+	if calls != 1 {
+		t.Errorf("read the totals %d times, want 1", calls)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/widget/tetris", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown contest: got status %d, want 404", rec.Code)
+	}
+}
+
+func TestWidgetError(t *testing.T) {
+	c, err := bidwar.Parse([]byte(widgetJSON))
+	if err != nil {
+		t.Fatalf("error parsing test data: %v", err)
+	}
+	s := NewServer(func() bidwar.Collection { return c })
+	s.SetTotals(func(con bidwar.Contest) (bidwar.Totals, error) {
+		return bidwar.Totals{}, errors.New("quota exceeded")
+	})
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/widget/mario-kart-track", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// hypeTrainTracker approximates Twitch's Hype Train from the subs and bits
+// that the bot sees, since the bot can't see the real one. A train starts
+// when enough different users contribute within the window, and ends when a
+// window passes without a contribution. A nil hypeTrainTracker tracks
+// nothing.
+type hypeTrainTracker struct {
+	// The points needed to reach each level, counted from the start of the
+	// train. Ascending.
+	levels []int
+	// How many different users must contribute to start a train.
+	starters int
+	window   time.Duration
+
+	mu sync.Mutex
+	// Contributions within the window, while no train is running. Oldest
+	// first.
+	recent []hypeContribution
+	// The running train, if any.
+	running bool
+	points  int
+	level   int
+	expires time.Time
+	// The final levels of the trains that have ended, until they're
+	// announced.
+	ended []int
+}
+
+type hypeContribution struct {
+	user   string
+	points int
+	at     time.Time
+}
+
+func newHypeTrainTracker(cfg HypeTrainConfig) *hypeTrainTracker {
+	if len(cfg.LevelPoints) == 0 {
+		return nil
+	}
+	return &hypeTrainTracker{
+		levels:   cfg.LevelPoints,
+		starters: cfg.StartContributors,
+		window:   time.Duration(cfg.WindowSeconds) * time.Second,
+	}
+}
+
+// validateHypeTrain checks that the Hype Train config makes sense.
+func validateHypeTrain(c HypeTrainConfig) error {
+	if c.StartContributors < 1 {
+		return fmt.Errorf("Hype Train start contributors must be positive, got %d", c.StartContributors)
+	}
+	if c.WindowSeconds <= 0 {
+		return fmt.Errorf("Hype Train window must be positive, got %d", c.WindowSeconds)
+	}
+	if c.BonusCentsPerLevel < 0 {
+		return fmt.Errorf("Hype Train bonus must not be negative, got %d", c.BonusCentsPerLevel)
+	}
+	prev := 0
+	for _, p := range c.LevelPoints {
+		if p <= prev {
+			return fmt.Errorf("Hype Train level points must be positive and increasing, got %v", c.LevelPoints)
+		}
+		prev = p
+	}
+	return nil
+}
+
+// add records a contribution of the given points. It returns whether the
+// contribution started a train, and the level that the train just reached
+// (or 0 if it didn't reach a new one).
+func (h *hypeTrainTracker) add(user string, points int, now time.Time) (bool, int) {
+	if h == nil {
+		return false, 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.endLocked(now)
+	if h.running {
+		h.points += points
+		h.expires = now.Add(h.window)
+		return false, h.levelUpLocked()
+	}
+	var recent []hypeContribution
+	for _, c := range h.recent {
+		if now.Sub(c.at) < h.window {
+			recent = append(recent, c)
+		}
+	}
+	h.recent = append(recent, hypeContribution{user: strings.ToLower(user), points: points, at: now})
+	users := make(map[string]bool)
+	total := 0
+	for _, c := range h.recent {
+		users[c.user] = true
+		total += c.points
+	}
+	if len(users) < h.starters {
+		return false, 0
+	}
+	h.recent = nil
+	h.running = true
+	h.points = total
+	h.level = 0
+	h.expires = now.Add(h.window)
+	return true, h.levelUpLocked()
+}
+
+// levelUpLocked updates the train's level from its points, and returns the
+// new level if it went up, or 0 otherwise. h.mu must be held.
+func (h *hypeTrainTracker) levelUpLocked() int {
+	level := 0
+	for _, p := range h.levels {
+		if h.points >= p {
+			level++
+		}
+	}
+	if level <= h.level {
+		return 0
+	}
+	h.level = level
+	return level
+}
+
+// endLocked ends the running train if its time is up. h.mu must be held.
+func (h *hypeTrainTracker) endLocked(now time.Time) {
+	if h.running && !now.Before(h.expires) {
+		h.running = false
+		h.ended = append(h.ended, h.level)
+	}
+}
+
+// check returns the final levels of the trains that have ended since the
+// last check.
+func (h *hypeTrainTracker) check(now time.Time) []int {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.endLocked(now)
+	ended := h.ended
+	h.ended = nil
+	return ended
+}
+
+// trackHypeTrain counts a sub or cheer towards the Hype Train, and announces
+// when a train starts or reaches a new level.
+func (b *bot) trackHypeTrain(ev donation.Event) {
+	if b.hypeTrain == nil {
+		return
+	}
+	started, level := b.hypeTrain.add(b.identities.TwitchUser(ev.Owner), ev.Value().Cents(), time.Now())
+	if started {
+		log.Printf("a Hype Train started with the %s from %s", ev.Description(), ev.Owner)
+		b.sayAs(chatDonation, ev.Channel, "A Hype Train has started! Keep the subs and bits coming!")
+	}
+	if level > 0 {
+		log.Printf("the Hype Train reached level %d", level)
+		b.sayAs(chatDonation, ev.Channel, fmt.Sprintf("The Hype Train reached level %d!", level))
+	}
+}
+
+// announceHypeTrains announces the trains that have ended, and credits their
+// bonuses. A bonus is bid war credit, which isn't part of the amount raised,
+// unless the bonus is money.
+func (b *bot) announceHypeTrains(channel string, now time.Time) {
+	for _, level := range b.hypeTrain.check(now) {
+		log.Printf("the Hype Train ended at level %d", level)
+		if level == 0 {
+			b.say(channel, "The Hype Train ended before it reached level 1.")
+			continue
+		}
+		msg := fmt.Sprintf("The Hype Train ended at level %d!", level)
+		if b.hypeTrainBonus > 0 {
+			bonus := donation.CentsValue(level * b.hypeTrainBonus.Cents())
+			ev := donation.Event{
+				Source:  donation.SourceHypeTrain,
+				Time:    now,
+				Owner:   "Hype Train",
+				Channel: channel,
+				Credit:  bonus,
+			}
+			if b.hypeTrainBonusIsMoney {
+				ev.Source = donation.SourceHypeTrainMatch
+				ev.Credit, ev.Cash = 0, bonus
+			}
+			if b.recordDonation(ev, bidwar.Choice{}) {
+				if b.hypeTrainBonusIsMoney {
+					b.note(fmt.Sprintf("Credited a $%s bonus for a level %d Hype Train.", bonus.Exact(), level))
+					msg += fmt.Sprintf(" That adds $%s to the total.", bonus)
+				} else {
+					b.note(fmt.Sprintf("Credited a %s-point bonus for a level %d Hype Train.", bonus.Exact(), level))
+					msg += fmt.Sprintf(" That's worth a %s-point bonus.", bonus)
+				}
+			}
+		}
+		b.say(channel, msg)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestHypeTrainTracker(t *testing.T) {
+	now := time.Now()
+	h := newHypeTrainTracker(HypeTrainConfig{LevelPoints: []int{1000, 2000, 3000}, StartContributors: 3, WindowSeconds: 300})
+
+	// The same user twice doesn't start a train.
+	for i, user := range []string{"aerionblue", "AerionBlue", "usedpizza"} {
+		if started, level := h.add(user, 600, now.Add(time.Duration(i)*time.Second)); started || level != 0 {
+			t.Errorf("contribution %d: got (%v, %d), want no train", i, started, level)
+		}
+	}
+	// A third user does, and the contributions so far count towards it.
+	if started, level := h.add("Mizalie", 500, now.Add(10*time.Second)); !started || level != 2 {
+		t.Errorf("got (%v, %d), want a train at level 2", started, level)
+	}
+	if started, level := h.add("Mizalie", 100, now.Add(20*time.Second)); started || level != 0 {
+		t.Errorf("got (%v, %d), want no new level", started, level)
+	}
+	if started, level := h.add("Mizalie", 800, now.Add(30*time.Second)); started || level != 3 {
+		t.Errorf("got (%v, %d), want level 3", started, level)
+	}
+	if ended := h.check(now.Add(300 * time.Second)); len(ended) != 0 {
+		t.Errorf("train ended early, at %v", ended)
+	}
+	if ended, want := h.check(now.Add(330*time.Second)), []int{3}; !reflect.DeepEqual(ended, want) {
+		t.Errorf("got ended trains %v, want %v", ended, want)
+	}
+	if ended := h.check(now.Add(400 * time.Second)); len(ended) != 0 {
+		t.Errorf("train announced twice: %v", ended)
+	}
+
+	// Contributions outside the window don't start a train.
+	later := now.Add(time.Hour)
+	h.add("aerionblue", 100, later)
+	h.add("usedpizza", 100, later.Add(200*time.Second))
+	if started, _ := h.add("Mizalie", 100, later.Add(301*time.Second)); started {
+		t.Error("contributions outside the window started a train")
+	}
+
+	var nilTracker *hypeTrainTracker
+	if started, _ := nilTracker.add("aerionblue", 600, now); started {
+		t.Error("nil tracker started a train")
+	}
+}
+
+func TestValidateHypeTrain(t *testing.T) {
+	for _, tc := range []struct {
+		cfg     HypeTrainConfig
+		wantErr bool
+	}{
+		{HypeTrainConfig{StartContributors: 3, WindowSeconds: 300}, false},
+		{HypeTrainConfig{LevelPoints: []int{1600, 3400}, StartContributors: 3, WindowSeconds: 300}, false},
+		{HypeTrainConfig{LevelPoints: []int{3400, 1600}, StartContributors: 3, WindowSeconds: 300}, true},
+		{HypeTrainConfig{LevelPoints: []int{0}, StartContributors: 3, WindowSeconds: 300}, true},
+		{HypeTrainConfig{StartContributors: 0, WindowSeconds: 300}, true},
+		{HypeTrainConfig{StartContributors: 3, WindowSeconds: 300, BonusCentsPerLevel: -1}, true},
+	} {
+		if err := validateHypeTrain(tc.cfg); (err != nil) != tc.wantErr {
+			t.Errorf("validateHypeTrain(%+v): got error %v, want error: %v", tc.cfg, err, tc.wantErr)
+		}
+	}
+}
+
+// eventRecorder keeps the donations that it records.
+type eventRecorder struct {
+	events []donation.Event
+}
+
+func (r *eventRecorder) RecordDonation(ev donation.Event, bid bidwar.Choice) error {
+	r.events = append(r.events, ev)
+	return nil
+}
+
+func TestHypeTrainBonus(t *testing.T) {
+	defer quietLogs()()
+	for _, tc := range []struct {
+		isMoney bool
+		want    donation.Event
+	}{
+		{false, donation.Event{Source: donation.SourceHypeTrain, Owner: "Hype Train", Channel: "aerionblue", Credit: 1000}},
+		{true, donation.Event{Source: donation.SourceHypeTrainMatch, Owner: "Hype Train", Channel: "aerionblue", Cash: 1000}},
+	} {
+		now := time.Now()
+		rec := &eventRecorder{}
+		b := newLoadTestBot(t, newFakeBackend(0))
+		b.dbRecorder = rec
+		b.hypeTrain = newHypeTrainTracker(HypeTrainConfig{LevelPoints: []int{100, 200}, StartContributors: 1, WindowSeconds: 60})
+		b.hypeTrainBonus = 500
+		b.hypeTrainBonusIsMoney = tc.isMoney
+		b.hypeTrain.add("aerionblue", 250, now)
+		b.announceHypeTrains("aerionblue", now.Add(2*time.Minute))
+
+		if len(rec.events) != 1 {
+			t.Fatalf("money %v: got %d recorded bonuses, want 1", tc.isMoney, len(rec.events))
+		}
+		got := rec.events[0]
+		got.Time = time.Time{}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("money %v: got %+v, want %+v", tc.isMoney, got, tc.want)
+		}
+		if got.Source.IsCredit() == tc.isMoney {
+			t.Errorf("money %v: got IsCredit %v", tc.isMoney, got.Source.IsCredit())
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package identity links the names that donors use on tip pages to their
+// Twitch usernames. A donor who tips as "CoolDude99" but chats as
+// "cooldude_99" can then use !bid to assign their tips.
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Map is a set of links from tip names to Twitch usernames. The links are
+// stored in a JSON file, as an object mapping each tip name to a username:
+//
+//	{"CoolDude99": "cooldude_99"}
+//
+// Names are compared case-insensitively.
+type Map struct {
+	path string
+
+	mu sync.RWMutex
+	// Maps a lowercase tip name to a lowercase Twitch username.
+	links map[string]string
+}
+
+// Load reads a Map from a file. A missing file is treated as an empty Map,
+// and will be created when the first link is added.
+func Load(path string) (*Map, error) {
+	m := &Map{path: path, links: make(map[string]string)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read donor links file: %v", err)
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("malformed donor links file: %v", err)
+	}
+	for tipName, user := range raw {
+		m.links[strings.ToLower(tipName)] = strings.ToLower(user)
+	}
+	return m, nil
+}
+
+// Link credits the tips from tipName to a Twitch user, and saves the Map.
+func (m *Map) Link(tipName string, twitchUser string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links[strings.ToLower(tipName)] = strings.ToLower(twitchUser)
+	return m.save()
+}
+
+// TwitchUser returns the Twitch username linked to a donor name. If the name
+// isn't linked, it is returned as is.
+func (m *Map) TwitchUser(name string) string {
+	if m == nil {
+		return name
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if user, ok := m.links[strings.ToLower(name)]; ok {
+		return user
+	}
+	return name
+}
+
+// Names returns all the names that a Twitch user donates under: the username
+// itself, and every tip name linked to it.
+func (m *Map) Names(twitchUser string) []string {
+	names := []string{twitchUser}
+	if m == nil {
+		return names
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user := strings.ToLower(twitchUser)
+	var linked []string
+	for tipName, u := range m.links {
+		if u == user && tipName != user {
+			linked = append(linked, tipName)
+		}
+	}
+	sort.Strings(linked)
+	return append(names, linked...)
+}
+
+func (m *Map) save() error {
+	data, err := json.MarshalIndent(m.links, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write donor links file: %v", err)
+	}
+	return os.Rename(tmp, m.path)
+}
@@ -0,0 +1,47 @@
+package identity
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.json")
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("error loading missing file: %v", err)
+	}
+	if got := m.TwitchUser("CoolDude99"); got != "CoolDude99" {
+		t.Errorf("unlinked name: got %q, want it unchanged", got)
+	}
+	if err := m.Link("CoolDude99", "cooldude_99"); err != nil {
+		t.Fatalf("error linking: %v", err)
+	}
+	if err := m.Link("Cool Dude (Streamlabs)", "CoolDude_99"); err != nil {
+		t.Fatalf("error linking: %v", err)
+	}
+
+	// Links should survive a reload.
+	m, err = Load(path)
+	if err != nil {
+		t.Fatalf("error reloading: %v", err)
+	}
+	if got := m.TwitchUser("COOLDUDE99"); got != "cooldude_99" {
+		t.Errorf("got %q, want cooldude_99", got)
+	}
+	want := []string{"CoolDude_99", "cool dude (streamlabs)", "cooldude99"}
+	if got := m.Names("CoolDude_99"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got names %q, want %q", got, want)
+	}
+}
+
+func TestNilMap(t *testing.T) {
+	var m *Map
+	if got := m.TwitchUser("aerionblue"); got != "aerionblue" {
+		t.Errorf("got %q, want aerionblue", got)
+	}
+	if got := m.Names("aerionblue"); !reflect.DeepEqual(got, []string{"aerionblue"}) {
+		t.Errorf("got %q, want just aerionblue", got)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/csvimport"
+	"github.com/aerionblue/pizzafest/db"
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+// The subcommand that backfills the donation table from a CSV export, e.g.:
+//
+//	pizzafest import --csv donations.csv --config_json bot.json --sheets_creds creds.json
+const importSubcommand = "import"
+
+// runImport records the donations in an exported CSV file (see package
+// csvimport) that aren't already in the donation table. Each donation goes
+// towards whatever bid war option its message names, as if it had just come
+// in.
+func runImport(args []string) {
+	fs := flag.NewFlagSet(importSubcommand, flag.ExitOnError)
+	csvPath := fs.String("csv", "", "Path to the exported donations CSV file. Required.")
+	source := fs.String("source", string(donation.SourceStreamlabs), "Where the CSV file came from: streamlabs or streamelements")
+	targetChannel := fs.String("channel", "aerionblue", "The Twitch channel the donations were made to")
+	configPath := fs.String("config_json", "", "Path to the bot config JSON file. Required.")
+	sheetsCredsPath := fs.String("sheets_creds", "", "Path to the Google Sheets OAuth client secret file. Required.")
+	sheetsTokenPath := fs.String("sheets_token", "", "Path to the Google Sheets OAuth token. If absent, you will be prompted to create a new token")
+	bidWarDataPath := fs.String("bidwar_data", "", "Path to a JSON file describing the current bid wars")
+	dryRun := fs.Bool("dry_run", false, "Whether to only log the donations that would be recorded")
+	fs.Parse(args)
+
+	if *csvPath == "" || *configPath == "" || *sheetsCredsPath == "" {
+		log.Fatalf("--csv, --config_json, and --sheets_creds flags are required")
+	}
+	src := donation.Source(*source)
+	if src != donation.SourceStreamlabs && src != donation.SourceStreamElements {
+		log.Fatalf("--source must be streamlabs or streamelements, got %q", *source)
+	}
+	cfg, err := ParseBotConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	donation.SetDisplayPrecision(cfg.Display.precision())
+
+	f, err := os.Open(*csvPath)
+	if err != nil {
+		log.Fatalf("could not open CSV file: %v", err)
+	}
+	evs, err := csvimport.Parse(f, src, *targetChannel, cfg.rates())
+	f.Close()
+	if err != nil {
+		log.Fatalf("error parsing %s: %v", *csvPath, err)
+	}
+
+	sheetsSrv, err := googlesheets.NewService(context.Background(), *sheetsCredsPath, *sheetsTokenPath)
+	if err != nil {
+		log.Fatalf("error initializing Google Sheets API: %v", err)
+	}
+	var bidwars bidwar.Collection
+	if cfg.Spreadsheet.BidWarSheetName != "" {
+		bidwars, err = readBidWarSheet(googlesheets.NewBidWarSheet(sheetsSrv, cfg.Spreadsheet.ID, cfg.Spreadsheet.BidWarSheetName))
+	} else if *bidWarDataPath != "" {
+		bidwars, err = readBidWars(*bidWarDataPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	bidwars.ReasonOptions = cfg.Reasons.options()
+
+	donationTable := googlesheets.NewDonationTable(sheetsSrv, cfg.Spreadsheet.ID, cfg.Spreadsheet.SheetName)
+	donationTable.SetReasonOptions(cfg.Reasons.options())
+	if cfg.Spreadsheet.RecordSource {
+		donationTable.RecordSource()
+	}
+	if cfg.Spreadsheet.RecordTime {
+		donationTable.RecordTime()
+	}
+	if cfg.Spreadsheet.RecordNet {
+		donationTable.RecordNet()
+	}
+	if cfg.Display.RoundSheetValues {
+		donationTable.RoundValues()
+	}
+	mirrorChanges(donationTable, ChangeFeedConfig{FilePath: cfg.ChangeFeed.FilePath})
+	vr, err := donationTable.GetTable()
+	if err != nil {
+		log.Fatalf("error reading donation table: %v", err)
+	}
+	fresh := csvimport.SkipRecorded(evs, vr.Values)
+	log.Printf("read %d donations from %s; %d are already in the donation table", len(evs), *csvPath, len(evs)-len(fresh))
+
+	recorder := db.NewGoogleSheetsClient(donationTable)
+	for i, ev := range fresh {
+		choice := importChoice(bidwars, ev)
+		desc := fmt.Sprintf("%s from %s at %s", ev.Description(), ev.Owner, ev.Time.Format("2006-01-02 15:04"))
+		if !choice.Option.IsZero() {
+			desc += " for " + choice.Option.DisplayName
+		}
+		if *dryRun {
+			log.Printf("would record %s", desc)
+			continue
+		}
+		if err := recorder.RecordDonation(ev, choice); err != nil {
+			log.Fatalf("error recording %s; %d of %d donations were imported: %v", desc, i, len(fresh), err)
+		}
+		log.Printf("recorded %s", desc)
+	}
+}
+
+// importChoice works out which bid war option an imported donation goes
+// towards. Like getChoice, but there are no pending !bid preferences to use.
+func importChoice(bidwars bidwar.Collection, ev donation.Event) bidwar.Choice {
+	if ev.Value() < minimumDonation {
+		return bidwar.Choice{}
+	}
+	choice := bidwars.ChoiceFromMessage(ev.Message, bidwar.FromDonationMessage)
+	return bidwar.Choice{Option: choice.Option, Reason: choice.Reason}
+}
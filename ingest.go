@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/eventqueue"
+	"github.com/aerionblue/pizzafest/streamelements"
+	"github.com/aerionblue/pizzafest/streamlabs"
+	"github.com/aerionblue/pizzafest/tipfile"
+	"github.com/aerionblue/pizzafest/twitcheventsub"
+)
+
+// The parts of the bot that a process can run (see --role). For big events,
+// the bot can be split into an ingest process and a record process, which
+// share an event queue file, so that a crash in one doesn't lose the events
+// that the other is holding.
+const (
+	// Everything, in one process.
+	roleAll = "all"
+	// Reads donations from chat and the donation providers, and adds them to
+	// the event queue.
+	roleIngest = "ingest"
+	// Records and announces the donations in the event queue, and handles
+	// chat commands.
+	roleRecord = "record"
+)
+
+// Kinds of event queue entries, i.e., which dispatcher handles them.
+const (
+	queuedSub        = "sub"
+	queuedBits       = "bits"
+	queuedRedemption = "redemption"
+	queuedMoney      = "money"
+	queuedEventSub   = "eventsub"
+	queuedRaid       = "raid"
+)
+
+// How often the record process checks the event queue.
+const queuePollInterval = 1 * time.Second
+
+func validateRole(role string, queuePath string, eventSubMode string) error {
+	switch role {
+	case roleAll:
+		return nil
+	case roleIngest, roleRecord:
+	default:
+		return fmt.Errorf("unknown role %q", role)
+	}
+	if queuePath == "" {
+		return fmt.Errorf("--queue_file flag is required with --role=%s", role)
+	}
+	if eventSubMode == eventSubCrossCheck {
+		return errors.New("the EventSub cross-check only works with --role=all")
+	}
+	return nil
+}
+
+// providers are the sources of donations other than chat. Any of them may be
+// nil.
+type providers struct {
+	streamElements *streamelements.DonationPoller
+	streamlabs     *streamlabs.DonationPoller
+	tipWatcher     *tipfile.Watcher
+	eventSub       *twitcheventsub.Client
+}
+
+// providerFlags are the files that set up a channel's providers: the
+// command-line flags for the first channel, or its ChannelConfig.
+type providerFlags struct {
+	streamElementsCreds string
+	streamlabsCreds     string
+	tipLogPath          string
+	eventSubCreds       string
+}
+
+// newProviders sets up a channel's providers that have flags. Only the
+// primary channel must have EventSub, if it's used. The caller must Close the
+// tip watcher, if there is one.
+func newProviders(f providerFlags, channel string, primary bool, cfg BotConfig, httpClient *http.Client) providers {
+	var p providers
+	if f.streamElementsCreds != "" {
+		var err error
+		p.streamElements, err = streamelements.NewDonationPoller(context.Background(), f.streamElementsCreds, channel, httpClient)
+		if err != nil {
+			log.Printf("(non-fatal) error initializing StreamElements polling: %v", err)
+		} else {
+			p.streamElements.SetRates(cfg.rates())
+		}
+	} else {
+		log.Print("no StreamElements token provided")
+	}
+	if f.streamlabsCreds != "" {
+		var err error
+		p.streamlabs, err = streamlabs.NewDonationPoller(context.Background(), f.streamlabsCreds, channel, httpClient)
+		if err != nil {
+			log.Printf("(non-fatal) error initializing Streamlabs polling: %v", err)
+		} else {
+			p.streamlabs.SetRates(cfg.rates())
+		}
+	} else {
+		log.Print("no Streamlabs token provided")
+	}
+	if (cfg.EventSub.Mode != eventSubOff || len(cfg.ChannelPoints.Rewards) > 0) && f.eventSubCreds == "" {
+		if primary {
+			log.Fatalf("--twitch_eventsub_creds flag is required when the EventSub mode is set or there are channel point rewards")
+		}
+		log.Printf("WARNING: #%s has no eventSubCreds; its subs and cheers come from chat, and its channel point redemptions aren't counted", channel)
+	} else if cfg.EventSub.Mode != eventSubOff || len(cfg.ChannelPoints.Rewards) > 0 {
+		var err error
+		p.eventSub, err = twitcheventsub.NewClient(f.eventSubCreds, httpClient)
+		if err != nil {
+			log.Fatalf("error initializing EventSub: %v", err)
+		}
+		p.eventSub.SetRates(cfg.rates())
+	}
+	if f.tipLogPath != "" {
+		var err error
+		p.tipWatcher, err = tipfile.NewWatcher(f.tipLogPath, channel)
+		if err != nil {
+			log.Fatalf("error creating tip file watcher: %v", err)
+		}
+	}
+	return p
+}
+
+// providerHandlers say what to do with the donations from the providers.
+type providerHandlers struct {
+	// Handles a tip. matchDonorName says whether to look for a bid in the
+	// donor's name.
+	money func(ev donation.Event, matchDonorName bool)
+	// Handles a sub, gift sub, or cheer from EventSub.
+	eventSub func(ev donation.Event)
+	// Handles a channel point redemption from EventSub.
+	redemption func(ev donation.Event)
+	// Handles a tip that was ignored (e.g., because it wasn't in US dollars).
+	ignored func(reason string, payload []byte)
+}
+
+// start hands the providers' donations to the handlers. Returns the pollers,
+// by name, so that they can be polled on demand.
+func (p providers) start(cfg BotConfig, h providerHandlers) map[string]donationPoller {
+	pollers := make(map[string]donationPoller)
+	if p.streamElements != nil {
+		p.streamElements.OnDonation(func(ev donation.Event) {
+			h.money(ev, cfg.DonorNameBids.StreamElements)
+		})
+		p.streamElements.OnIgnored(h.ignored)
+		if err := p.streamElements.Start(); err != nil {
+			log.Fatalf("StreamElements polling error: %v", err)
+		}
+		pollers["StreamElements"] = p.streamElements
+	}
+	if p.streamlabs != nil {
+		p.streamlabs.OnDonation(func(ev donation.Event) {
+			h.money(ev, cfg.DonorNameBids.Streamlabs)
+		})
+		if err := p.streamlabs.Start(); err != nil {
+			log.Fatalf("Streamlabs polling error: %v", err)
+		}
+		pollers["Streamlabs"] = p.streamlabs
+	}
+
+	if p.eventSub != nil {
+		if cfg.EventSub.Mode != eventSubOff {
+			p.eventSub.OnDonation(h.eventSub)
+		}
+		if len(cfg.ChannelPoints.Rewards) > 0 {
+			p.eventSub.OnRedemption(h.redemption)
+		}
+		if err := p.eventSub.Start(); err != nil {
+			log.Fatalf("EventSub error: %v", err)
+		}
+	}
+
+	if p.tipWatcher != nil {
+		go func() {
+			for {
+				select {
+				case ev := <-p.tipWatcher.C:
+					h.money(ev, cfg.DonorNameBids.TipFile)
+				}
+			}
+		}()
+	}
+	return pollers
+}
+
+// startProviders hands a channel's provider donations to its bot.
+func (b *bot) startProviders(p providers, cfg BotConfig) {
+	if p.streamElements != nil {
+		b.status.addIntegration("StreamElements")
+	}
+	if p.streamlabs != nil {
+		b.status.addIntegration("Streamlabs")
+	}
+	if p.tipWatcher != nil {
+		b.status.addIntegration("tip file")
+	}
+	if p.eventSub != nil {
+		b.status.addIntegration("EventSub")
+		b.useEventSub(cfg.EventSub)
+	}
+	b.pollers = p.start(cfg, providerHandlers{
+		money:      b.dispatchMoneyDonation,
+		eventSub:   b.dispatchEventSubDonation,
+		redemption: b.dispatchEventSubRedemption,
+		ignored: func(reason string, payload []byte) {
+			b.dropped.add(reason, json.RawMessage(payload))
+		},
+	})
+}
+
+// runIngest runs the ingest half of the bot: it adds the donations from chat
+// and the providers to the event queue, for the record process to handle.
+// It doesn't reply in chat. It returns when the IRC client disconnects.
+func runIngest(ircClient *twitch.Client, channel string, cfg BotConfig, p providers, queue *eventqueue.Writer) {
+	enqueue := func(e eventqueue.Entry) {
+		if err := queue.Add(e); err != nil {
+			// Log the whole entry, so that it can be recorded by hand.
+			data, _ := json.Marshal(e)
+			log.Printf("ERROR adding %s from %s to the event queue: %v: %s", e.Event.Description(), e.Event.Owner, err, data)
+		}
+	}
+	replaceChat := cfg.EventSub.Mode == eventSubReplace
+	rates := cfg.rates()
+
+	ircClient.OnUserNoticeMessage(func(m twitch.UserNoticeMessage) {
+		if !strings.EqualFold(m.Channel, channel) {
+			return
+		}
+		if ev, ok := donation.ParseSubEvent(m, rates); ok && !(replaceChat && coveredByEventSub(m.MsgID) && takenFromEventSub(ev, cfg.EventSub.SelfSubs)) {
+			enqueue(eventqueue.Entry{Kind: queuedSub, Event: ev})
+		} else if ev, ok := donation.ParseRaidEvent(m); ok && cfg.Notices.RecordRaids {
+			enqueue(eventqueue.Entry{Kind: queuedRaid, Event: ev})
+		}
+	})
+	ircClient.OnPrivateMessage(func(m twitch.PrivateMessage) {
+		if !strings.EqualFold(m.Channel, channel) {
+			return
+		}
+		if ev, ok := donation.ParseBitsEvent(m); ok {
+			if !replaceChat {
+				enqueue(eventqueue.Entry{Kind: queuedBits, Event: ev, MessageID: ev.MessageID})
+			}
+		}
+	})
+	ircClient.Join(channel)
+
+	p.start(cfg, providerHandlers{
+		money: func(ev donation.Event, matchDonorName bool) {
+			enqueue(eventqueue.Entry{Kind: queuedMoney, Event: ev, MatchDonorName: matchDonorName})
+		},
+		eventSub: func(ev donation.Event) {
+			if takenFromEventSub(ev, cfg.EventSub.SelfSubs) {
+				enqueue(eventqueue.Entry{Kind: queuedEventSub, Event: ev})
+			}
+		},
+		redemption: func(ev donation.Event) {
+			enqueue(eventqueue.Entry{Kind: queuedRedemption, Event: ev})
+		},
+		ignored: func(reason string, payload []byte) {
+			log.Printf("ignored a donation (%s): %s", reason, payload)
+		},
+	})
+
+	log.Print("connecting to IRC...")
+	for {
+		err := ircClient.Connect()
+		if err == nil || err == twitch.ErrClientDisconnected {
+			return
+		}
+		if err == twitch.ErrLoginAuthenticationFailed {
+			log.Fatal(err)
+		}
+		// The providers keep adding to the queue while we wait.
+		log.Printf("ERROR connecting to IRC (retrying in %v): %v", ircRetryDelay, err)
+		time.Sleep(ircRetryDelay)
+	}
+}
+
+// dispatchQueued handles an event from the event queue, just as if this
+// process had ingested it, but waits until it's recorded. Returns an error
+// if it couldn't be recorded or saved in the journal, so that the queue
+// keeps it.
+func (b *bot) dispatchQueued(e eventqueue.Entry) error {
+	ev := e.Event
+	ev.MessageID = e.MessageID
+	var task donationTask
+	switch e.Kind {
+	case queuedSub:
+		task = b.subTask(ev)
+	case queuedBits:
+		task = b.bitsTask(ev)
+	case queuedRedemption:
+		if reward, ok := b.channelPointReward(ev.Reward); ok {
+			task = b.redemptionTask(ev, reward)
+		}
+	case queuedMoney:
+		task = b.moneyTask(ev, e.MatchDonorName)
+	case queuedEventSub:
+		task = b.eventSubTask(ev)
+	case queuedRaid:
+		task = b.raidTask(ev)
+	default:
+		log.Printf("ERROR skipping %s from %s in the event queue: unknown kind %q", ev.Description(), ev.Owner, e.Kind)
+	}
+	if task == nil {
+		return nil
+	}
+	return task()
+}
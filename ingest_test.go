@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/db"
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/eventqueue"
+)
+
+func TestValidateRole(t *testing.T) {
+	for _, tc := range []struct {
+		role, queuePath, eventSubMode string
+		wantErr                       bool
+	}{
+		{roleAll, "", eventSubOff, false},
+		{roleAll, "", eventSubCrossCheck, false},
+		{roleIngest, "queue.jsonl", eventSubReplace, false},
+		{roleRecord, "queue.jsonl", eventSubOff, false},
+		{roleRecord, "", eventSubOff, true},
+		{roleIngest, "queue.jsonl", eventSubCrossCheck, true},
+		{"both", "queue.jsonl", eventSubOff, true},
+	} {
+		if err := validateRole(tc.role, tc.queuePath, tc.eventSubMode); (err != nil) != tc.wantErr {
+			t.Errorf("validateRole(%q, %q, %q): got error %v, want error: %v", tc.role, tc.queuePath, tc.eventSubMode, err, tc.wantErr)
+		}
+	}
+}
+
+// downRecorder fails to record anything.
+type downRecorder struct{}
+
+func (downRecorder) RecordDonation(ev donation.Event, bid bidwar.Choice) error {
+	return errors.New("sheet is down")
+}
+
+func TestDispatchQueuedKeepsUnsavedDonations(t *testing.T) {
+	defer quietLogs()()
+	b := newLoadTestBot(t, newFakeBackend(0))
+	b.dbRecorder = downRecorder{}
+	e := eventqueue.Entry{Kind: queuedMoney, Event: donation.Event{Owner: "usedpizza", Channel: "testing", Cash: donation.CentsValue(500)}}
+	if err := b.dispatchQueued(e); err == nil {
+		t.Error("with the sheet down and no journal: got no error, want the entry kept in the queue")
+	}
+
+	// Once the donation is in the journal, it's safe to move on.
+	b.journal = db.NewJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	if err := b.dispatchQueued(e); err != nil {
+		t.Errorf("with the sheet down and a journal: got error %v", err)
+	}
+
+	b.dbRecorder = newFakeBackend(0)
+	if err := b.dispatchQueued(e); err != nil {
+		t.Errorf("with the sheet up: got error %v", err)
+	}
+}
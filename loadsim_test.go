@@ -0,0 +1,307 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+	"golang.org/x/time/rate"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// The load simulation only runs when asked to, e.g.:
+//
+//	go test -run TestLoadSimulation -loadsim_duration 30s -loadsim_tips 20 -v
+var (
+	loadSimDuration       = flag.Duration("loadsim_duration", 0, "How long to run the load simulation. If zero, the simulation is skipped")
+	loadSimSubs           = flag.Float64("loadsim_subs", 5, "Simulated subs per second")
+	loadSimBits           = flag.Float64("loadsim_bits", 5, "Simulated bits donations per second")
+	loadSimTips           = flag.Float64("loadsim_tips", 5, "Simulated tips per second")
+	loadSimBids           = flag.Float64("loadsim_bids", 2, "Simulated !bid commands per second")
+	loadSimBackendLatency = flag.Duration("loadsim_backend_latency", 200*time.Millisecond, "Simulated latency of each spreadsheet call")
+)
+
+const loadSimJSON = `{
+    "contests": [
+        {
+            "name": "Mario Kart track",
+            "options": [
+                {"displayName": "Moo Moo Meadows", "shortCode": "Moo", "aliases": ["moo"]},
+                {"displayName": "Neo Bowser City", "shortCode": "NBC", "aliases": ["nbc"]}
+            ]
+        }
+    ]
+}`
+
+// fakeBackend stands in for both the donation DB and the bid war tallier. It
+// sleeps to simulate the latency of the real spreadsheet, and measures how
+// long each event took from dispatch until it was recorded.
+type fakeBackend struct {
+	latency time.Duration
+	// If non-nil, receives a value every time a donation or bid is recorded.
+	done chan struct{}
+
+	mu sync.Mutex
+	// Maps an event's owner to the time at which the event was dispatched.
+	started   map[string]time.Time
+	latencies []time.Duration
+}
+
+func newFakeBackend(latency time.Duration) *fakeBackend {
+	return &fakeBackend{latency: latency, started: make(map[string]time.Time)}
+}
+
+func (f *fakeBackend) start(owner string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started[owner] = time.Now()
+}
+
+func (f *fakeBackend) finish(owner string) {
+	f.mu.Lock()
+	if start, ok := f.started[owner]; ok {
+		f.latencies = append(f.latencies, time.Since(start))
+		delete(f.started, owner)
+	}
+	f.mu.Unlock()
+	if f.done != nil {
+		f.done <- struct{}{}
+	}
+}
+
+func (f *fakeBackend) RecordDonation(ev donation.Event, bid bidwar.Choice) error {
+	time.Sleep(f.latency)
+	f.finish(ev.Owner)
+	return nil
+}
+
+func (f *fakeBackend) GetTotals() ([]bidwar.Total, error) {
+	time.Sleep(f.latency)
+	return nil, nil
+}
+
+func (f *fakeBackend) AssignFromMessage(donor string, message string) (bidwar.UpdateStats, error) {
+	time.Sleep(f.latency)
+	f.finish(donor)
+	return bidwar.UpdateStats{}, nil
+}
+
+func (f *fakeBackend) AssignUnassigned(contest bidwar.Contest) (bidwar.UpdateStats, error) {
+	time.Sleep(f.latency)
+	return bidwar.UpdateStats{}, nil
+}
+
+func (f *fakeBackend) TotalsForContest(contest bidwar.Contest) (bidwar.Totals, error) {
+	time.Sleep(f.latency)
+	return bidwar.Totals{}, nil
+}
+
+func (f *fakeBackend) Refresh() (map[string]bidwar.Totals, error) {
+	time.Sleep(f.latency)
+	return nil, nil
+}
+
+func (f *fakeBackend) AssignToChoice(donor string, choice bidwar.Choice) (bidwar.UpdateStats, error) {
+	time.Sleep(f.latency)
+	return bidwar.UpdateStats{}, nil
+}
+
+func (f *fakeBackend) Contributors(opt bidwar.Option) ([]bidwar.Contribution, error) {
+	time.Sleep(f.latency)
+	return nil, nil
+}
+
+func (f *fakeBackend) TopContributors(opt bidwar.Option, n int) ([]bidwar.Contribution, error) {
+	time.Sleep(f.latency)
+	return nil, nil
+}
+
+func (f *fakeBackend) Allocations(donor string) ([]bidwar.Allocation, error) {
+	time.Sleep(f.latency)
+	return nil, nil
+}
+
+func (f *fakeBackend) RenameChoice(oldCode string, newCode string) (int, error) {
+	time.Sleep(f.latency)
+	return 0, nil
+}
+
+func (f *fakeBackend) AssignSplit(donor string, split bidwar.Split) ([]bidwar.UpdateStats, error) {
+	time.Sleep(f.latency)
+	f.finish(donor)
+	return nil, nil
+}
+
+func (f *fakeBackend) Reassign(donor string, rows []int, from bidwar.Choice, to bidwar.Choice) (bidwar.UpdateStats, error) {
+	time.Sleep(f.latency)
+	return bidwar.UpdateStats{}, nil
+}
+
+func (f *fakeBackend) Resolve(contest bidwar.Contest) (bidwar.Resolution, error) {
+	time.Sleep(f.latency)
+	return bidwar.Resolution{Contest: contest}, nil
+}
+
+func (f *fakeBackend) ExportResults(contest bidwar.Contest, sheetName string) (int, error) {
+	time.Sleep(f.latency)
+	return 0, nil
+}
+
+func (f *fakeBackend) SetCollection(c bidwar.Collection) {}
+
+func (f *fakeBackend) ApplyCap(donor string, opt bidwar.Option) (donation.CentsValue, error) {
+	return 0, nil
+}
+
+func newLoadTestBot(tb testing.TB, backend *fakeBackend) *bot {
+	bidwars, err := bidwar.Parse([]byte(loadSimJSON))
+	if err != nil {
+		tb.Fatalf("error parsing test data: %v", err)
+	}
+	return &bot{
+		dbRecorder:      backend,
+		bidwars:         bidwars,
+		bidwarTallier:   backend,
+		minimumDonation: minimumDonation,
+		chatLimiter:     rate.NewLimiter(rate.Every(chatCooldown), chatBucketSize),
+		pollers:         make(map[string]donationPoller),
+		communityGifts:  make(map[string]time.Time),
+		pendingBids:     make(map[string]*bidPreference),
+		leaders:         make(map[string]string),
+	}
+}
+
+// quietLogs discards log output until the returned func is called. The bot
+// logs every event, which would drown out the results.
+func quietLogs() func() {
+	log.SetOutput(ioutil.Discard)
+	return func() { log.SetOutput(os.Stderr) }
+}
+
+// loadSimSource generates one kind of event at a fixed rate.
+type loadSimSource struct {
+	name     string
+	perSec   float64
+	dispatch func(b *bot, owner string)
+}
+
+func loadSimSources() []loadSimSource {
+	return []loadSimSource{
+		{"subs", *loadSimSubs, func(b *bot, owner string) {
+			b.dispatchSubEvent(donation.Event{Owner: owner, Channel: "testing", Type: donation.Subscription, SubCount: 1, SubTier: donation.SubTier1, SubMonths: 1, SubValue: 600, Message: "moo"})
+		}},
+		{"bits", *loadSimBits, func(b *bot, owner string) {
+			b.dispatchBitsEvent(donation.Event{Owner: owner, Channel: "testing", Bits: 500, Message: "nbc please"})
+		}},
+		{"tips", *loadSimTips, func(b *bot, owner string) {
+			b.dispatchMoneyDonation(donation.Event{Owner: owner, Channel: "testing", Cash: donation.CentsValue(500), Message: "all on moo"}, false)
+		}},
+		{"bids", *loadSimBids, func(b *bot, owner string) {
+			b.dispatchBidCommand(twitch.PrivateMessage{User: twitch.User{Name: owner}, Channel: "testing", Message: "!bid nbc"})
+		}},
+	}
+}
+
+func TestLoadSimulation(t *testing.T) {
+	if *loadSimDuration == 0 {
+		t.Skip("load simulation is disabled; set -loadsim_duration to run it")
+	}
+	defer quietLogs()()
+	backend := newFakeBackend(*loadSimBackendLatency)
+	b := newLoadTestBot(t, backend)
+
+	var sent int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, src := range loadSimSources() {
+		if src.perSec <= 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(src loadSimSource) {
+			defer wg.Done()
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / src.perSec))
+			defer ticker.Stop()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					owner := fmt.Sprintf("%s%d", src.name, i)
+					backend.start(owner)
+					atomic.AddInt64(&sent, 1)
+					src.dispatch(b, owner)
+				}
+			}
+		}(src)
+	}
+	begin := time.Now()
+	time.Sleep(*loadSimDuration)
+	close(stop)
+	wg.Wait()
+
+	// Give in-flight events a chance to finish.
+	deadline := time.Now().Add(10*time.Second + 10*(*loadSimBackendLatency))
+	for time.Now().Before(deadline) {
+		backend.mu.Lock()
+		pending := len(backend.started)
+		backend.mu.Unlock()
+		if pending == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	elapsed := time.Since(begin)
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	lat := backend.latencies
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	t.Logf("sent %d events in %v; recorded %d (%.1f/s); %d never recorded",
+		sent, *loadSimDuration, len(lat), float64(len(lat))/elapsed.Seconds(), len(backend.started))
+	if len(lat) > 0 {
+		t.Logf("latency until recorded: p50 %v, p95 %v, max %v",
+			lat[len(lat)/2], lat[len(lat)*95/100], lat[len(lat)-1])
+	}
+	t.Logf("chat replies dropped by the rate limiter: %d", atomic.LoadInt64(&b.droppedChat))
+}
+
+func BenchmarkDispatchMoneyDonation(bm *testing.B) {
+	defer quietLogs()()
+	backend := newFakeBackend(0)
+	backend.done = make(chan struct{}, bm.N)
+	b := newLoadTestBot(bm, backend)
+	ev := donation.Event{Owner: "donor", Channel: "testing", Cash: donation.CentsValue(500), Message: "all on moo"}
+	bm.ResetTimer()
+	for i := 0; i < bm.N; i++ {
+		b.dispatchMoneyDonation(ev, false)
+	}
+	for i := 0; i < bm.N; i++ {
+		<-backend.done
+	}
+}
+
+func BenchmarkDispatchBitsEvent(bm *testing.B) {
+	defer quietLogs()()
+	backend := newFakeBackend(0)
+	backend.done = make(chan struct{}, bm.N)
+	b := newLoadTestBot(bm, backend)
+	ev := donation.Event{Owner: "cheerer", Channel: "testing", Bits: 500, Message: "nbc please"}
+	bm.ResetTimer()
+	for i := 0; i < bm.N; i++ {
+		b.dispatchBitsEvent(ev)
+	}
+	for i := 0; i < bm.N; i++ {
+		<-backend.done
+	}
+}
@@ -0,0 +1,120 @@
+// Package money converts between decimal dollar amounts and integer cents.
+// Amounts are parsed from their decimal text, never by multiplying a float by
+// 100, so the bot and the spreadsheet always agree to the penny.
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseCents parses a decimal dollar amount (e.g. "12.34", "-5", "$1,000.5")
+// into cents. Digits past the second decimal place are rounded half away from
+// zero.
+func ParseCents(s string) (int, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", orig)
+		}
+		return FromFloat(f), nil
+	}
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(s, "$")
+	s = strings.Replace(s, ",", "", -1)
+
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", orig)
+	}
+	if !allDigits(whole) || !allDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", orig)
+	}
+	roundUp := len(frac) > 2 && frac[2] >= '5'
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	cents, err := strconv.Atoi(whole + frac[:2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %v", orig, err)
+	}
+	if roundUp {
+		cents++
+	}
+	if negative {
+		cents = -cents
+	}
+	return cents, nil
+}
+
+func allDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromFloat converts a dollar amount to cents, rounding to the nearest cent.
+// The float is converted via its shortest decimal representation, so 1.005
+// becomes 101 cents rather than 100.
+func FromFloat(dollars float64) int {
+	cents, err := ParseCents(strconv.FormatFloat(dollars, 'f', -1, 64))
+	if err != nil {
+		// FormatFloat only fails to produce a parseable number for NaN and Inf.
+		return 0
+	}
+	return cents
+}
+
+// FormatCents formats cents as a decimal dollar amount with 2 decimal places
+// (e.g. "12.34").
+func FormatCents(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Amount is a dollar amount read from JSON, stored as cents. It accepts both
+// JSON numbers (12.34) and strings ("12.34"), since different APIs disagree.
+type Amount int
+
+func (a Amount) Cents() int {
+	return int(a)
+}
+
+func (a Amount) String() string {
+	return FormatCents(int(a))
+}
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("amount must be a number or string, got %s", b)
+		}
+		s = n.String()
+	}
+	cents, err := ParseCents(s)
+	if err != nil {
+		return err
+	}
+	*a = Amount(cents)
+	return nil
+}
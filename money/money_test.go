@@ -0,0 +1,182 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"testing/quick"
+)
+
+func TestParseCents(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"12.34", 1234, false},
+		{"5", 500, false},
+		{"5.", 500, false},
+		{".5", 50, false},
+		{"0.07", 7, false},
+		{"11.0000000000", 1100, false},
+		{"1.005", 101, false},
+		{"1.00499", 100, false},
+		{"-0.06", -6, false},
+		{"-1.005", -101, false},
+		{"$1,234.50", 123450, false},
+		{" 3.50 ", 350, false},
+		{"1.2e2", 12000, false},
+		{"", 0, true},
+		{".", 0, true},
+		{"abc", 0, true},
+		{"1.2.3", 0, true},
+		{"12a", 0, true},
+	} {
+		got, err := ParseCents(tc.in)
+		if err != nil {
+			if !tc.wantErr {
+				t.Errorf("ParseCents(%q): got error %v, want %d", tc.in, err, tc.want)
+			}
+			continue
+		}
+		if tc.wantErr {
+			t.Errorf("ParseCents(%q): got %d, want error", tc.in, got)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseCents(%q): got %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	for _, tc := range []struct {
+		in   float64
+		want int
+	}{
+		{12.34, 1234},
+		{0.1 + 0.2, 30},
+		{1.005, 101},
+		{19.99, 1999},
+		{-4.35, -435},
+	} {
+		if got := FromFloat(tc.in); got != tc.want {
+			t.Errorf("FromFloat(%v): got %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestFormatCents(t *testing.T) {
+	for _, tc := range []struct {
+		in   int
+		want string
+	}{
+		{1234, "12.34"},
+		{5, "0.05"},
+		{0, "0.00"},
+		{-6, "-0.06"},
+		{-12345, "-123.45"},
+	} {
+		if got := FormatCents(tc.in); got != tc.want {
+			t.Errorf("FormatCents(%d): got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPrecisionFormat(t *testing.T) {
+	for _, tc := range []struct {
+		p    Precision
+		in   int
+		want string
+	}{
+		{DefaultPrecision, 1234, "12.34"},
+		{DefaultPrecision, -6, "-0.06"},
+		{Precision{0, RoundHalfUp}, 1250, "13"},
+		{Precision{0, RoundHalfUp}, 1249, "12"},
+		{Precision{0, RoundHalfUp}, -1250, "-13"},
+		{Precision{0, RoundDown}, 1299, "12"},
+		{Precision{0, RoundUp}, 1201, "13"},
+		{Precision{0, RoundUp}, 1200, "12"},
+		{Precision{0, RoundDown}, -40, "0"},
+		{Precision{1, RoundHalfUp}, 1234, "12.3"},
+		{Precision{1, RoundHalfUp}, 1295, "13.0"},
+		{Precision{1, RoundUp}, 5, "0.1"},
+	} {
+		if got := tc.p.Format(tc.in); got != tc.want {
+			t.Errorf("%+v.Format(%d): got %q, want %q", tc.p, tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPrecisionValidate(t *testing.T) {
+	for _, tc := range []struct {
+		p       Precision
+		wantErr bool
+	}{
+		{DefaultPrecision, false},
+		{Precision{0, RoundDown}, false},
+		{Precision{3, RoundDown}, true},
+		{Precision{-1, RoundDown}, true},
+		{Precision{2, "SIDEWAYS"}, true},
+	} {
+		if err := tc.p.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%+v: got error %v, want error: %v", tc.p, err, tc.wantErr)
+		}
+	}
+}
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	f := func(cents int32) bool {
+		got, err := ParseCents(FormatCents(int(cents)))
+		return err == nil && got == int(cents)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFromFloatRoundTrip(t *testing.T) {
+	// Any whole number of cents survives a trip through float64 dollars, as
+	// long as it's small enough for float64 to represent exactly.
+	f := func(cents int32) bool {
+		return FromFloat(float64(cents)/100) == int(cents)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFromFloatMatchesRounding(t *testing.T) {
+	f := func(dollars float32) bool {
+		d := float64(dollars)
+		if math.Abs(d) > 1e6 {
+			return true
+		}
+		got := FromFloat(d)
+		// The result is never more than half a cent away from the input.
+		return math.Abs(float64(got)-d*100) <= 0.5+1e-6
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAmountUnmarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Amount
+	}{
+		{`12.34`, 1234},
+		{`"11.0000000000"`, 1100},
+		{`100`, 10000},
+	} {
+		var got Amount
+		if err := json.Unmarshal([]byte(tc.in), &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Unmarshal(%s): got %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package money
+
+import (
+	"fmt"
+)
+
+// Rounding is how an amount is rounded to fewer decimal places.
+type Rounding string
+
+const (
+	// Round half away from zero (e.g., 1.50 to 2, and 1.49 to 1).
+	RoundHalfUp Rounding = "HALF_UP"
+	// Round towards zero (e.g., 1.99 to 1).
+	RoundDown Rounding = "DOWN"
+	// Round away from zero (e.g., 1.01 to 2).
+	RoundUp Rounding = "UP"
+)
+
+// Precision is how many decimal places to show, and how to round to them.
+type Precision struct {
+	// 0, 1, or 2.
+	Decimals int
+	Rounding Rounding
+}
+
+// DefaultPrecision shows every cent, so nothing is rounded.
+var DefaultPrecision = Precision{Decimals: 2, Rounding: RoundHalfUp}
+
+// Validate returns an error if the Precision can't be used.
+func (p Precision) Validate() error {
+	if p.Decimals < 0 || p.Decimals > 2 {
+		return fmt.Errorf("decimal places must be 0, 1, or 2, got %d", p.Decimals)
+	}
+	switch p.Rounding {
+	case RoundHalfUp, RoundDown, RoundUp:
+		return nil
+	}
+	return fmt.Errorf("rounding must be HALF_UP, DOWN, or UP, got %q", p.Rounding)
+}
+
+// Format formats cents as a decimal dollar amount with p.Decimals decimal
+// places (e.g. "12.3" for 1234 cents with 1 decimal place).
+func (p Precision) Format(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	unit := 1
+	for i := p.Decimals; i < 2; i++ {
+		unit *= 10
+	}
+	n, rem := cents/unit, cents%unit
+	switch p.Rounding {
+	case RoundHalfUp:
+		if rem*2 >= unit {
+			n++
+		}
+	case RoundUp:
+		if rem > 0 {
+			n++
+		}
+	}
+	if n == 0 {
+		sign = ""
+	}
+	switch p.Decimals {
+	case 0:
+		return fmt.Sprintf("%s%d", sign, n)
+	case 1:
+		return fmt.Sprintf("%s%d.%d", sign, n/10, n%10)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, n/100, n%100)
+}
@@ -0,0 +1,93 @@
+// Package notes posts producer-facing notes (lead changes, big donations,
+// etc.) somewhere other than the public chat. These are meant for the hosts
+// and commentators, so they can be more detailed than chat replies.
+package notes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Poster publishes a note somewhere.
+type Poster interface {
+	Post(msg string) error
+}
+
+// FilePoster appends notes to a local text file, one timestamped line per note.
+type FilePoster struct {
+	path string
+	now  func() time.Time
+
+	mu sync.Mutex
+}
+
+func NewFilePoster(path string) *FilePoster {
+	return &FilePoster{path: path, now: time.Now}
+}
+
+func (p *FilePoster) Post(msg string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f, err := os.OpenFile(p.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open notes file: %v", err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "%s %s\n", p.now().Format("15:04:05"), msg); err != nil {
+		return fmt.Errorf("could not write to notes file: %v", err)
+	}
+	return nil
+}
+
+// DiscordPoster posts notes to a Discord channel via a webhook.
+type DiscordPoster struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func NewDiscordPoster(webhookURL string) *DiscordPoster {
+	return &DiscordPoster{webhookURL: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type discordMessage struct {
+	Content string `json:"content"`
+}
+
+func (p *DiscordPoster) Post(msg string) error {
+	body, err := json.Marshal(discordMessage{Content: msg})
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Post(p.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error posting to Discord: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Discord webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Multi returns a Poster that posts every note to all of the given Posters.
+// An error from one Poster does not stop the note from going to the others.
+func Multi(posters ...Poster) Poster {
+	return multiPoster(posters)
+}
+
+type multiPoster []Poster
+
+func (m multiPoster) Post(msg string) error {
+	var firstErr error
+	for _, p := range m {
+		if err := p.Post(msg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
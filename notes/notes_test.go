@@ -0,0 +1,50 @@
+package notes
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFilePoster(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	p := NewFilePoster(path)
+	p.now = func() time.Time { return time.Date(2022, 7, 1, 20, 15, 0, 0, time.UTC) }
+
+	for _, msg := range []string{"first note", "second note"} {
+		if err := p.Post(msg); err != nil {
+			t.Fatalf("error posting note: %v", err)
+		}
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "20:15:00 first note\n20:15:00 second note\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+type fakePoster struct {
+	msgs []string
+	err  error
+}
+
+func (f *fakePoster) Post(msg string) error {
+	f.msgs = append(f.msgs, msg)
+	return f.err
+}
+
+func TestMulti(t *testing.T) {
+	failing := &fakePoster{err: errors.New("oops")}
+	working := &fakePoster{}
+	if err := Multi(failing, working).Post("hi"); err == nil {
+		t.Errorf("got nil error, want error from failing poster")
+	}
+	if len(working.msgs) != 1 {
+		t.Errorf("note should still reach the other posters: got %v", working.msgs)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// noticeTracker decides what to say about raids and viewer milestones, and
+// remembers which incentives they've unlocked. Each incentive is unlocked at
+// most once.
+type noticeTracker struct {
+	cfg NoticesConfig
+
+	mu sync.Mutex
+	// Indexes into cfg.Incentives.
+	unlocked map[int]bool
+}
+
+func newNoticeTracker(cfg NoticesConfig) *noticeTracker {
+	return &noticeTracker{cfg: cfg, unlocked: make(map[int]bool)}
+}
+
+// unlock returns the incentives that the notice unlocks, and marks them as
+// unlocked.
+func (t *noticeTracker) unlock(n donation.Notice) []NoticeIncentive {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var incs []NoticeIncentive
+	for i, inc := range t.cfg.Incentives {
+		if t.unlocked[i] || donation.NoticeType(inc.Type) != n.Type || n.Count < inc.MinCount {
+			continue
+		}
+		t.unlocked[i] = true
+		incs = append(incs, inc)
+	}
+	return incs
+}
+
+// announcement returns what to say in chat about the notice, or "" if it
+// shouldn't be announced.
+func (t *noticeTracker) announcement(n donation.Notice) string {
+	switch {
+	case n.Type == donation.Raid && t.cfg.AnnounceRaids:
+		return fmt.Sprintf("Thanks for the raid, %s! Welcome, all %d of you!", n.User, n.Count)
+	case n.Type == donation.ViewerMilestone && t.cfg.AnnounceMilestones:
+		if n.Category == "watch-streak" {
+			return fmt.Sprintf("%s has watched %d streams in a row!", n.User, n.Count)
+		}
+		return fmt.Sprintf("%s reached a %s milestone of %d!", n.User, n.Category, n.Count)
+	}
+	return ""
+}
+
+// dispatchNotice announces a raid or viewer milestone, and any incentives
+// that it unlocks.
+func (b *bot) dispatchNotice(n donation.Notice) {
+	log.Printf("new %s by %v (count: %d)", n.Type, n.User, n.Count)
+	if b.notices == nil {
+		return
+	}
+	if msg := b.notices.announcement(n); msg != "" {
+		b.sayAs(chatDonation, n.Channel, msg)
+	}
+	for _, inc := range b.notices.unlock(n) {
+		log.Printf("the %s by %s unlocked %s", n.Type, n.User, inc.Unlocks)
+		b.note(fmt.Sprintf("The %s by %s (%d) unlocked: %s", n.Type, n.User, n.Count, inc.Unlocks))
+		b.sayAs(chatDonation, n.Channel, fmt.Sprintf("That unlocked %s!", inc.Unlocks))
+	}
+}
+
+// dispatchRaid records a raid in the donation table, if that's enabled, for
+// its configured value (which may be nothing). The raid is announced
+// separately, by dispatchNotice.
+func (b *bot) dispatchRaid(ev donation.Event) {
+	if task := b.raidTask(ev); task != nil {
+		go task()
+	}
+}
+
+func (b *bot) raidTask(ev donation.Event) donationTask {
+	if b.notices == nil || !b.notices.cfg.RecordRaids {
+		return nil
+	}
+	ev.Credit = donation.CentsValue(b.notices.cfg.RaidCents)
+	b.status.sawDonation(ev.Source, time.Now())
+	log.Printf("new raid by %v with %d viewers worth $%s", ev.Owner, ev.Raiders, ev.Value())
+	return func() error {
+		_, err := b.tryRecordDonation(ev, bidwar.Choice{})
+		return err
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestNoticeTrackerUnlock(t *testing.T) {
+	tr := newNoticeTracker(NoticesConfig{Incentives: []NoticeIncentive{
+		{Type: "raid", MinCount: 500, Unlocks: "the blindfold run"},
+		{Type: "raid", MinCount: 100, Unlocks: "a hat"},
+		{Type: "viewermilestone", MinCount: 10, Unlocks: "a shoutout"},
+	}})
+	names := func(incs []NoticeIncentive) []string {
+		var s []string
+		for _, inc := range incs {
+			s = append(s, inc.Unlocks)
+		}
+		return s
+	}
+	if got := names(tr.unlock(donation.Notice{Type: donation.Raid, Count: 50})); len(got) != 0 {
+		t.Errorf("small raid: got %q, want nothing", got)
+	}
+	if got := names(tr.unlock(donation.Notice{Type: donation.Raid, Count: 150})); len(got) != 1 || got[0] != "a hat" {
+		t.Errorf("medium raid: got %q, want [a hat]", got)
+	}
+	if got := names(tr.unlock(donation.Notice{Type: donation.Raid, Count: 600})); len(got) != 1 || got[0] != "the blindfold run" {
+		t.Errorf("big raid: got %q, want only [the blindfold run], since the hat is already unlocked", got)
+	}
+	if got := names(tr.unlock(donation.Notice{Type: donation.ViewerMilestone, Count: 10})); len(got) != 1 || got[0] != "a shoutout" {
+		t.Errorf("milestone: got %q, want [a shoutout]", got)
+	}
+}
+
+func TestNoticeTrackerAnnouncement(t *testing.T) {
+	raid := donation.Notice{Type: donation.Raid, User: "usedpizza", Count: 512}
+	streak := donation.Notice{Type: donation.ViewerMilestone, User: "aerionblue", Category: "watch-streak", Count: 10}
+	if got := newNoticeTracker(NoticesConfig{}).announcement(raid); got != "" {
+		t.Errorf("raid announcements off: got %q, want \"\"", got)
+	}
+	tr := newNoticeTracker(NoticesConfig{AnnounceRaids: true, AnnounceMilestones: true})
+	if got, want := tr.announcement(raid), "Thanks for the raid, usedpizza! Welcome, all 512 of you!"; got != want {
+		t.Errorf("raid: got %q, want %q", got, want)
+	}
+	if got, want := tr.announcement(streak), "aerionblue has watched 10 streams in a row!"; got != want {
+		t.Errorf("watch streak: got %q, want %q", got, want)
+	}
+}
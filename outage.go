@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// How long to wait before trying to connect to IRC again.
+const ircRetryDelay = 10 * time.Second
+
+// How many acknowledgments an outageTracker keeps. Any more are only counted.
+const maxOutageAcks = 1000
+
+// outageTracker notices when we lose the IRC connection, and remembers the
+// donations that we would have acknowledged in chat, so that we can catch up
+// when we reconnect. A nil outageTracker never reports an outage.
+type outageTracker struct {
+	// How long to wait for a pong before assuming that the connection is down.
+	pongTimeout time.Duration
+	// How many donations the catch-up message lists by name.
+	maxDonations int
+
+	mu sync.Mutex
+	// When we sent the ping that hasn't been answered yet. Zero if none.
+	pingSent time.Time
+	// Whether we know that we're disconnected, e.g. because the server told
+	// us to reconnect.
+	disconnected bool
+	acks         []string
+	// Acknowledgments that didn't fit in acks.
+	extra int
+}
+
+func newOutageTracker(cfg OutageConfig, pongTimeout time.Duration) *outageTracker {
+	if !cfg.CatchUp {
+		return nil
+	}
+	return &outageTracker{pongTimeout: pongTimeout, maxDonations: cfg.MaxDonations}
+}
+
+func (o *outageTracker) pinged(now time.Time) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pingSent.IsZero() {
+		o.pingSent = now
+	}
+}
+
+func (o *outageTracker) ponged() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pingSent = time.Time{}
+}
+
+// disconnect records that the connection is down until the next reconnect.
+func (o *outageTracker) disconnect() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.disconnected = true
+}
+
+// down reports whether the connection seems to be down: we were told so, or
+// the server hasn't answered a ping in time.
+func (o *outageTracker) down(now time.Time) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.isDown(now)
+}
+
+// Must hold mu.
+func (o *outageTracker) isDown(now time.Time) bool {
+	return o.disconnected || (!o.pingSent.IsZero() && now.Sub(o.pingSent) > o.pongTimeout)
+}
+
+// add remembers an acknowledgment if the connection is down. Returns whether
+// it was remembered.
+func (o *outageTracker) add(ack string, now time.Time) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.isDown(now) {
+		return false
+	}
+	if len(o.acks) < maxOutageAcks {
+		o.acks = append(o.acks, ack)
+	} else {
+		o.extra++
+	}
+	return true
+}
+
+// reconnected clears the outage and returns the catch-up message for it, or
+// "" if there is nothing to catch up on.
+func (o *outageTracker) reconnected() string {
+	if o == nil {
+		return ""
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	acks, extra := o.acks, o.extra
+	o.pingSent = time.Time{}
+	o.disconnected = false
+	o.acks = nil
+	o.extra = 0
+	return catchUpMessage(acks, extra, o.maxDonations)
+}
+
+// catchUpMessage lists the acknowledgments, up to max of them and no longer
+// than one chat message. The rest are counted.
+func catchUpMessage(acks []string, extra int, max int) string {
+	total := len(acks) + extra
+	if total == 0 {
+		return ""
+	}
+	const prefix = "While I was away: "
+	const suffix = ". Thank you!"
+	var listed []string
+	for i, ack := range acks {
+		more := ""
+		if rest := total - i - 1; rest > 0 {
+			more = fmt.Sprintf(", and %d more", rest)
+		}
+		if i >= max || len(prefix)+len(strings.Join(append(listed, ack), ", "))+len(more)+len(suffix) > maxChatLength {
+			break
+		}
+		listed = append(listed, ack)
+	}
+	msg := prefix + strings.Join(listed, ", ")
+	if rest := total - len(listed); rest > 0 {
+		if len(listed) == 0 {
+			return fmt.Sprintf("%s%d donations%s", prefix, rest, suffix)
+		}
+		msg += fmt.Sprintf(", and %d more", rest)
+	}
+	return msg + suffix
+}
+
+// queueCatchUp remembers a donation acknowledgment for the catch-up message,
+// if the IRC connection is down.
+func (b *bot) queueCatchUp(ev donation.Event, opt bidwar.Option) {
+	if opt.IsZero() {
+		return
+	}
+	ack := fmt.Sprintf("%s from %s toward %s", ev.Description(), ev.Owner, opt.DisplayName)
+	if b.outage.add(ack, time.Now()) {
+		log.Printf("IRC is down; saving acknowledgment for catch-up: %s", ack)
+	}
+}
+
+// catchUp posts the catch-up message for an outage, if there is one.
+func (b *bot) catchUp(channel string) {
+	if msg := b.outage.reconnected(); msg != "" {
+		b.sayAs(chatDonation, channel, msg)
+	}
+}
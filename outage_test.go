@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutageTracker(t *testing.T) {
+	o := newOutageTracker(OutageConfig{CatchUp: true, MaxDonations: 2}, 5*time.Second)
+	start := time.Date(2022, 11, 4, 20, 0, 0, 0, time.UTC)
+	if o.add("$5.00 donation from early toward Moo", start) {
+		t.Errorf("saved an acknowledgment while connected")
+	}
+	o.pinged(start)
+	if o.down(start.Add(3 * time.Second)) {
+		t.Errorf("down before the pong timeout")
+	}
+	o.ponged()
+	if o.down(start.Add(10 * time.Second)) {
+		t.Errorf("down after a pong")
+	}
+	o.pinged(start.Add(20 * time.Second))
+	now := start.Add(30 * time.Second)
+	if !o.down(now) {
+		t.Fatalf("not down after the pong timeout")
+	}
+	for _, ack := range []string{"$45.00 donation from X toward Moo", "500 bits from Y toward Pizza", "sub from Z toward Moo"} {
+		if !o.add(ack, now) {
+			t.Errorf("didn't save %q", ack)
+		}
+	}
+	want := "While I was away: $45.00 donation from X toward Moo, 500 bits from Y toward Pizza, and 1 more. Thank you!"
+	if got := o.reconnected(); got != want {
+		t.Errorf("got catch-up %q, want %q", got, want)
+	}
+	if o.down(now) {
+		t.Errorf("still down after reconnecting")
+	}
+	if got := o.reconnected(); got != "" {
+		t.Errorf("got a second catch-up %q", got)
+	}
+}
+
+func TestCatchUpMessageLength(t *testing.T) {
+	var acks []string
+	for i := 0; i < 50; i++ {
+		acks = append(acks, fmt.Sprintf("$100.00 donation from somebody%d toward %s", i, strings.Repeat("x", 20)))
+	}
+	got := catchUpMessage(acks, 3, 100)
+	if len(got) > maxChatLength {
+		t.Errorf("catch-up is %d characters long: %q", len(got), got)
+	}
+	if !strings.HasSuffix(got, "more. Thank you!") {
+		t.Errorf("got %q, want it to count the rest", got)
+	}
+}
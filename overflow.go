@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+const lockCommand = "!lock"
+const unlockCommand = "!unlock"
+
+// dispatchLockCommand locks in (or unlocks) a contest's winner. While a
+// contest is locked, bids for it go to its overflow option.
+func (b *bot) dispatchLockCommand(m twitch.PrivateMessage, cmd string) {
+	con, ok := b.collection().ContestByName(commandArgs(m.Message))
+	if !ok {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <contest name>", m.User.Name, cmd))
+		return
+	}
+	locked := cmd == lockCommand
+	go func() {
+		changed, err := b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+			return c.SetContestLocked(con.Name, locked)
+		})
+		var msg string
+		switch {
+		case !locked:
+			msg = fmt.Sprintf("@%s: Unlocked %s; bids count for every option again.", m.User.Name, con.Name)
+		case con.OverflowOption == "":
+			msg = fmt.Sprintf("@%s: Locked %s, but it has no overflow option, so bids still count as usual.", m.User.Name, con.Name)
+		default:
+			msg = fmt.Sprintf("@%s: Locked %s; bids for it now go to %s.", m.User.Name, con.Name, b.overflowName(con))
+		}
+		if changed && locked && !con.Locked {
+			msg += b.assignAtClose(con, m.User.Name)
+		}
+		if err != nil {
+			log.Printf("ERROR saving bid war data: %v", err)
+			msg += " (I couldn't save the change, so it will be lost if I restart.)"
+		}
+		b.say(m.Channel, msg)
+	}()
+}
+
+// checkAutoLock locks a contest that has been decided, if the contest asks
+// for that, and tells chat where further bids will go.
+func (b *bot) checkAutoLock(kind chatKind, channel string, contest bidwar.Contest, totals bidwar.Totals) {
+	if !contest.AutoLock || contest.Locked || contest.OverflowOption == "" {
+		return
+	}
+	winner, ok := totals.Decided()
+	if !ok {
+		return
+	}
+	changed, err := b.editBidWars(func(c bidwar.Collection) (bidwar.Collection, bool) {
+		if con, ok := c.ContestByName(contest.Name); !ok || con.Locked {
+			return c, false
+		}
+		return c.SetContestLocked(contest.Name, true)
+	})
+	if err != nil {
+		log.Printf("ERROR saving bid war data: %v", err)
+	}
+	if !changed {
+		return
+	}
+	log.Printf("locked %s: %s can't be caught", contest.Name, winner.ShortCode)
+	b.note(fmt.Sprintf("%s is decided: %s can't be caught. Bids now go to %s.", contest.Name, winner.DisplayName, b.overflowName(contest)))
+	b.sayAs(kind, channel, fmt.Sprintf("%s has locked in the win for %s! Any more bids for %s go to %s.", winner.DisplayName, contest.Name, contest.Name, b.overflowName(contest)))
+}
+
+// overflowName returns the display name of a contest's overflow option.
+func (b *bot) overflowName(con bidwar.Contest) string {
+	if opt, ok := b.collection().OptionByShortCode(con.OverflowOption); ok {
+		return opt.DisplayName
+	}
+	return con.OverflowOption
+}
+
+// overflowNote explains why a bid went to an overflow option, or returns ""
+// if it didn't.
+func (b *bot) overflowNote(bid bidwar.Choice) string {
+	if bid.Match.OverflowFrom == "" {
+		return ""
+	}
+	con, _ := b.collection().ContestForOption(bidwar.Option{ShortCode: bid.Match.OverflowFrom})
+	return fmt.Sprintf(" (%s is already decided, so it went to %s.)", con.Name, bid.Option.DisplayName)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+)
+
+// role is what a chat user is allowed to do. Each role can do everything that
+// the roles before it can.
+type role int
+
+const (
+	viewerRole role = iota
+	// Channel moderators, and the users in Permissions.Moderators.
+	moderatorRole
+	// The broadcaster, and the users in Permissions.Admins.
+	adminRole
+)
+
+// permissions decides who can use which chat commands. The zero value gives
+// the broadcaster and the channel moderators every privileged command.
+type permissions struct {
+	// Lowercase usernames.
+	moderators map[string]bool
+	admins     map[string]bool
+	// Commands that need adminRole instead of moderatorRole, keyed by name.
+	adminOnly map[string]bool
+}
+
+func newPermissions(cfg PermissionsConfig) permissions {
+	p := permissions{
+		moderators: make(map[string]bool),
+		admins:     make(map[string]bool),
+		adminOnly:  make(map[string]bool),
+	}
+	for _, u := range cfg.Moderators {
+		p.moderators[strings.ToLower(u)] = true
+	}
+	for _, u := range cfg.Admins {
+		p.admins[strings.ToLower(u)] = true
+	}
+	for _, name := range cfg.AdminOnly {
+		p.adminOnly[commandName(name)] = true
+	}
+	return p
+}
+
+// commandName normalizes a command name from the config, e.g. "CloseContest"
+// to "!closecontest".
+func commandName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "!") {
+		name = "!" + name
+	}
+	return name
+}
+
+// validateAdminOnly checks that every command in the list exists and is
+// privileged.
+func validateAdminOnly(names []string) error {
+	for _, name := range names {
+		c, ok := findCommand(commandName(name))
+		if !ok {
+			return fmt.Errorf("unknown command %q in adminOnly", name)
+		}
+		if c.role == viewerRole {
+			return fmt.Errorf("%s is open to everyone, so it can't be admin-only", c.name)
+		}
+	}
+	return nil
+}
+
+// findCommand returns the command with the given name or alias.
+func findCommand(name string) (command, bool) {
+	for _, c := range chatCommands() {
+		if c.matches(name) {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// roleOf returns the user's role.
+func (p permissions) roleOf(u twitch.User) role {
+	name := strings.ToLower(u.Name)
+	switch {
+	case u.Badges["broadcaster"] > 0 || p.admins[name]:
+		return adminRole
+	case u.Badges["moderator"] > 0 || p.moderators[name]:
+		return moderatorRole
+	}
+	return viewerRole
+}
+
+// required returns the role that a command needs.
+func (p permissions) required(c command) role {
+	if c.role == moderatorRole && p.adminOnly[c.name] {
+		return adminRole
+	}
+	return c.role
+}
+
+// allowed reports whether the user can use the command. Privileged commands
+// that are refused are logged.
+func (p permissions) allowed(u twitch.User, c command) bool {
+	if p.roleOf(u) >= p.required(c) {
+		return true
+	}
+	if c.role > viewerRole {
+		log.Printf("%s isn't allowed to use %s", u.Name, c.name)
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+)
+
+func TestPermissions(t *testing.T) {
+	p := newPermissions(PermissionsConfig{
+		Moderators: []string{"Producer"},
+		Admins:     []string{"aerionblue"},
+		AdminOnly:  []string{"CloseContest"},
+	})
+	closeContest, _ := findCommand(closeContestCommand)
+	refresh, _ := findCommand(refreshCommand)
+	standings, _ := findCommand(standingsCommand)
+	for _, tc := range []struct {
+		desc string
+		user twitch.User
+		c    command
+		want bool
+	}{
+		{"viewer, public command", twitch.User{Name: "viewer"}, standings, true},
+		{"viewer, mod command", twitch.User{Name: "viewer"}, refresh, false},
+		{"channel mod", twitch.User{Name: "mod", Badges: map[string]int{"moderator": 1}}, refresh, true},
+		{"allowlisted mod", twitch.User{Name: "producer"}, refresh, true},
+		{"mod, admin-only command", twitch.User{Name: "producer"}, closeContest, false},
+		{"broadcaster, admin-only command", twitch.User{Name: "usedpizza", Badges: map[string]int{"broadcaster": 1}}, closeContest, true},
+		{"allowlisted admin", twitch.User{Name: "AerionBlue"}, closeContest, true},
+	} {
+		if got := p.allowed(tc.user, tc.c); got != tc.want {
+			t.Errorf("%s: allowed = %v, want %v", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestValidateAdminOnly(t *testing.T) {
+	if err := validateAdminOnly([]string{"!closecontest", "lock"}); err != nil {
+		t.Errorf("got error %v for valid commands", err)
+	}
+	if err := validateAdminOnly([]string{"!nosuchcommand"}); err == nil {
+		t.Errorf("got no error for an unknown command")
+	}
+	if err := validateAdminOnly([]string{"!bid"}); err == nil {
+		t.Errorf("got no error for a public command")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/predictions"
+)
+
+// predictionTracker runs a Twitch Prediction for each contest that asks for
+// one: it opens the prediction when the contest opens, locks it before the
+// contest closes, and resolves it when a mod resolves the contest. A nil
+// predictionTracker does nothing.
+type predictionTracker struct {
+	client *predictions.Client
+	// How long before a contest closes to lock its prediction.
+	lockBefore time.Duration
+
+	mu sync.Mutex
+	// The predictions we started, keyed by contest name.
+	running map[string]*contestPrediction
+	// Contests we already tried to start a prediction for, so that a failure
+	// isn't retried every tick.
+	tried map[string]bool
+}
+
+type contestPrediction struct {
+	prediction predictions.Prediction
+	locked     bool
+}
+
+func newPredictionTracker(client *predictions.Client, cfg PredictionsConfig) *predictionTracker {
+	if client == nil {
+		return nil
+	}
+	return &predictionTracker{
+		client:     client,
+		lockBefore: time.Duration(cfg.LockMinutesBeforeClose) * time.Minute,
+		running:    make(map[string]*contestPrediction),
+		tried:      make(map[string]bool),
+	}
+}
+
+// How often to check whether a prediction needs to be opened or locked.
+const predictionInterval = 15 * time.Second
+
+// runPredictions opens and locks predictions as their contests open and close.
+// It never returns.
+func (b *bot) runPredictions() {
+	ticker := time.NewTicker(predictionInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		b.predictions.check(b.collection(), now)
+	}
+}
+
+// check opens and locks predictions as their contests open and close.
+func (pt *predictionTracker) check(c bidwar.Collection, now time.Time) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	for _, con := range c.Contests {
+		if !con.Prediction {
+			continue
+		}
+		if cp := pt.running[con.Name]; cp != nil {
+			if !cp.locked && (con.Closed || pt.lockDue(con, now)) {
+				pt.lock(con, cp)
+			}
+			continue
+		}
+		if con.Closed || pt.tried[con.Name] || (!con.OpensAt.IsZero() && now.Before(con.OpensAt)) {
+			continue
+		}
+		pt.tried[con.Name] = true
+		pt.start(con, now)
+	}
+}
+
+func (pt *predictionTracker) lockDue(con bidwar.Contest, now time.Time) bool {
+	return !con.ClosesAt.IsZero() && !now.Before(con.ClosesAt.Add(-pt.lockBefore))
+}
+
+// Must hold mu.
+func (pt *predictionTracker) start(con bidwar.Contest, now time.Time) {
+	var outcomes []string
+	for _, opt := range con.Options {
+		if !opt.Closed {
+			outcomes = append(outcomes, outcomeTitle(opt))
+		}
+	}
+	if len(outcomes) < predictions.MinOutcomes || len(outcomes) > predictions.MaxOutcomes {
+		log.Printf("WARNING: %s has %d open options; a prediction needs %d to %d", con.Name, len(outcomes), predictions.MinOutcomes, predictions.MaxOutcomes)
+		return
+	}
+	// Twitch locks the prediction when the window ends, so the window can't
+	// run past the lock time.
+	window := predictions.MaxWindow
+	if !con.ClosesAt.IsZero() {
+		if left := con.ClosesAt.Add(-pt.lockBefore).Sub(now); left < window {
+			window = left
+		}
+	}
+	if window < predictions.MinWindow {
+		log.Printf("WARNING: not opening a prediction for %s, since it locks in %v", con.Name, window)
+		return
+	}
+	p, err := pt.client.Create(predictionTitle(con), outcomes, window.Truncate(time.Second))
+	if err != nil {
+		log.Printf("ERROR opening prediction for %s: %v", con.Name, err)
+		return
+	}
+	log.Printf("opened prediction %s for %s", p.ID, con.Name)
+	pt.running[con.Name] = &contestPrediction{prediction: p}
+}
+
+// Must hold mu.
+func (pt *predictionTracker) lock(con bidwar.Contest, cp *contestPrediction) {
+	cp.locked = true
+	if _, err := pt.client.Lock(cp.prediction.ID); err != nil {
+		// Twitch may have locked it already, when the window ended.
+		log.Printf("WARNING: couldn't lock prediction for %s: %v", con.Name, err)
+		return
+	}
+	log.Printf("locked prediction for %s", con.Name)
+}
+
+// resolve pays out the contest's prediction to the viewers who picked the
+// winner. If there isn't exactly one winner, the prediction is canceled and
+// everyone gets their points back.
+func (pt *predictionTracker) resolve(res bidwar.Resolution) {
+	if pt == nil {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	cp := pt.running[res.Contest.Name]
+	if cp == nil {
+		return
+	}
+	delete(pt.running, res.Contest.Name)
+	var outcomeID string
+	if len(res.Winners) == 1 && len(res.Tied) == 0 {
+		outcomeID, _ = cp.prediction.OutcomeID(outcomeTitle(res.Winners[0]))
+	}
+	if outcomeID == "" {
+		if _, err := pt.client.Cancel(cp.prediction.ID); err != nil {
+			log.Printf("ERROR canceling prediction for %s: %v", res.Contest.Name, err)
+			return
+		}
+		log.Printf("canceled prediction for %s, since it didn't have a single winner", res.Contest.Name)
+		return
+	}
+	if _, err := pt.client.Resolve(cp.prediction.ID, outcomeID); err != nil {
+		log.Printf("ERROR resolving prediction for %s: %v", res.Contest.Name, err)
+		return
+	}
+	log.Printf("resolved prediction for %s", res.Contest.Name)
+}
+
+func predictionTitle(con bidwar.Contest) string {
+	if title := fmt.Sprintf("Who will win %s?", con.Name); len(title) <= predictions.MaxTitleLength {
+		return title
+	}
+	return truncate(con.Name, predictions.MaxTitleLength)
+}
+
+func outcomeTitle(opt bidwar.Option) string {
+	return truncate(opt.DisplayName, predictions.MaxOutcomeTitleLength)
+}
+
+// truncate shortens s to at most n bytes, without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,182 @@
+// Package predictions creates and settles Twitch Predictions with the Helix
+// API.
+package predictions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aerionblue/pizzafest/twitchapi"
+)
+
+const predictionsBaseUrl = "https://api.twitch.tv/helix/predictions"
+
+// The timeout for API requests, unless the caller provides its own HTTP client.
+const defaultHTTPTimeout = 30 * time.Second
+
+// Limits that Twitch puts on predictions.
+const (
+	MaxTitleLength        = 45
+	MaxOutcomeTitleLength = 25
+	MinOutcomes           = 2
+	MaxOutcomes           = 10
+	MinWindow             = 30 * time.Second
+	MaxWindow             = 30 * time.Minute
+)
+
+// Status is the state of a prediction.
+type Status string
+
+const (
+	Active   Status = "ACTIVE"
+	Locked   Status = "LOCKED"
+	Resolved Status = "RESOLVED"
+	Canceled Status = "CANCELED"
+)
+
+// Prediction is a Twitch Prediction.
+type Prediction struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Status   Status    `json:"status"`
+	Outcomes []Outcome `json:"outcomes"`
+}
+
+// Outcome is one of the choices in a Prediction.
+type Outcome struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// OutcomeID returns the ID of the outcome with the given title.
+func (p Prediction) OutcomeID(title string) (string, bool) {
+	for _, o := range p.Outcomes {
+		if o.Title == title {
+			return o.ID, true
+		}
+	}
+	return "", false
+}
+
+// Client makes prediction requests on behalf of a broadcaster.
+type Client struct {
+	httpClient *http.Client
+	// The URL of the predictions endpoint. Only overridden in tests.
+	baseUrl string
+	creds   twitchapi.Creds
+}
+
+// NewClient creates a Client with the credentials in the given file. If
+// httpClient is nil, a client with a default timeout is used.
+func NewClient(credsPath string, httpClient *http.Client) (*Client, error) {
+	c, err := twitchapi.ReadCreds(credsPath, true)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return &Client{httpClient: httpClient, baseUrl: predictionsBaseUrl, creds: c}, nil
+}
+
+// Create starts a prediction that viewers can enter for the given window,
+// after which Twitch locks it.
+func (c *Client) Create(title string, outcomes []string, window time.Duration) (Prediction, error) {
+	if len(title) > MaxTitleLength {
+		return Prediction{}, fmt.Errorf("prediction title %q is longer than %d characters", title, MaxTitleLength)
+	}
+	if len(outcomes) < MinOutcomes || len(outcomes) > MaxOutcomes {
+		return Prediction{}, fmt.Errorf("a prediction needs %d to %d outcomes, got %d", MinOutcomes, MaxOutcomes, len(outcomes))
+	}
+	if window < MinWindow || window > MaxWindow {
+		return Prediction{}, fmt.Errorf("prediction window must be between %v and %v, got %v", MinWindow, MaxWindow, window)
+	}
+	type outcomeReq struct {
+		Title string `json:"title"`
+	}
+	req := struct {
+		BroadcasterID    string       `json:"broadcaster_id"`
+		Title            string       `json:"title"`
+		Outcomes         []outcomeReq `json:"outcomes"`
+		PredictionWindow int          `json:"prediction_window"`
+	}{
+		BroadcasterID:    c.creds.BroadcasterID,
+		Title:            title,
+		PredictionWindow: int(window / time.Second),
+	}
+	for _, o := range outcomes {
+		if len(o) > MaxOutcomeTitleLength {
+			return Prediction{}, fmt.Errorf("prediction outcome %q is longer than %d characters", o, MaxOutcomeTitleLength)
+		}
+		req.Outcomes = append(req.Outcomes, outcomeReq{Title: o})
+	}
+	return c.do(http.MethodPost, req)
+}
+
+// Lock stops viewers from entering the prediction.
+func (c *Client) Lock(id string) (Prediction, error) {
+	return c.end(id, Locked, "")
+}
+
+// Resolve pays out the prediction to the viewers who picked the winning
+// outcome.
+func (c *Client) Resolve(id string, winningOutcomeID string) (Prediction, error) {
+	return c.end(id, Resolved, winningOutcomeID)
+}
+
+// Cancel refunds everyone's channel points.
+func (c *Client) Cancel(id string) (Prediction, error) {
+	return c.end(id, Canceled, "")
+}
+
+func (c *Client) end(id string, status Status, winningOutcomeID string) (Prediction, error) {
+	req := struct {
+		BroadcasterID    string `json:"broadcaster_id"`
+		ID               string `json:"id"`
+		Status           Status `json:"status"`
+		WinningOutcomeID string `json:"winning_outcome_id,omitempty"`
+	}{c.creds.BroadcasterID, id, status, winningOutcomeID}
+	return c.do(http.MethodPatch, req)
+}
+
+// do sends a request to the predictions endpoint, and returns the prediction
+// in the response.
+func (c *Client) do(method string, body interface{}) (Prediction, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return Prediction{}, err
+	}
+	req, err := http.NewRequest(method, c.baseUrl, bytes.NewReader(data))
+	if err != nil {
+		return Prediction{}, err
+	}
+	c.creds.Authorize(req)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Prediction{}, fmt.Errorf("error sending prediction request: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Prediction{}, fmt.Errorf("error reading prediction response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Prediction{}, fmt.Errorf("prediction request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	var pr struct {
+		Data []Prediction `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &pr); err != nil {
+		return Prediction{}, fmt.Errorf("error parsing prediction response: %v", err)
+	}
+	if len(pr.Data) == 0 {
+		return Prediction{}, errors.New("prediction response had no prediction in it")
+	}
+	return pr.Data[0], nil
+}
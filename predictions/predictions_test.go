@@ -0,0 +1,82 @@
+package predictions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/aerionblue/pizzafest/twitchapi"
+)
+
+func TestClient(t *testing.T) {
+	var got []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Client-Id") != "client" {
+			http.Error(w, "bad auth", http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body["method"] = r.Method
+		got = append(got, body)
+		status := "ACTIVE"
+		if s, ok := body["status"].(string); ok {
+			status = s
+		}
+		fmt.Fprintf(w, `{"data": [{"id": "p1", "title": "Who wins Pizza?", "status": %q, "outcomes": [{"id": "o1", "title": "Moo"}, {"id": "o2", "title": "NBC"}]}]}`, status)
+	}))
+	defer srv.Close()
+	c := &Client{httpClient: srv.Client(), baseUrl: srv.URL, creds: twitchapi.Creds{ClientID: "client", AccessToken: "token", BroadcasterID: "123"}}
+
+	p, err := c.Create("Who wins Pizza?", []string{"Moo", "NBC"}, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id, ok := p.OutcomeID("NBC")
+	if !ok || id != "o2" {
+		t.Errorf("OutcomeID(NBC) = %q, %v; want o2", id, ok)
+	}
+	if p, err = c.Resolve(p.ID, id); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if p.Status != Resolved {
+		t.Errorf("got status %s after resolving", p.Status)
+	}
+	want := []map[string]interface{}{
+		{
+			"method":            "POST",
+			"broadcaster_id":    "123",
+			"title":             "Who wins Pizza?",
+			"outcomes":          []interface{}{map[string]interface{}{"title": "Moo"}, map[string]interface{}{"title": "NBC"}},
+			"prediction_window": 300.0,
+		},
+		{
+			"method":             "PATCH",
+			"broadcaster_id":     "123",
+			"id":                 "p1",
+			"status":             "RESOLVED",
+			"winning_outcome_id": "o2",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("requests (-want +got):\n%s", diff)
+	}
+}
+
+func TestCreateLimits(t *testing.T) {
+	c := &Client{baseUrl: "http://unused.invalid"}
+	if _, err := c.Create("Pizza", []string{"Moo"}, time.Minute); err == nil {
+		t.Errorf("got no error for one outcome")
+	}
+	if _, err := c.Create("Pizza", []string{"Moo", "NBC"}, time.Hour); err == nil {
+		t.Errorf("got no error for a window that's too long")
+	}
+}
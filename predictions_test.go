@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+)
+
+func TestPredictionTitles(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{"Pizza", "Who will win Pizza?"},
+		{"The Extremely Long Name Of A Contest", "The Extremely Long Name Of A Contest"},
+		{"The Even More Extremely Long Name Of A Contest", "The Even More Extremely Long Name Of A Contes"},
+	} {
+		if got := predictionTitle(bidwar.Contest{Name: tc.name}); got != tc.want {
+			t.Errorf("predictionTitle(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if got, want := truncate("Pokémon Mystery Dungeon", 4), "Pok"; got != want {
+		t.Errorf("truncate split a character: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+const recapCommand = "!recap"
+
+const (
+	// How far back !recap can go. Donations older than this are forgotten.
+	maxRecapMinutes = 120
+	// The most chat messages that one !recap sends. Donors who don't fit are
+	// counted instead.
+	maxRecapMessages = 4
+	// How long to wait between recap messages, so that the recap doesn't use
+	// up the chat rate limit.
+	recapMessageInterval = 3 * time.Second
+)
+
+// recentDonations remembers the donations recorded in the last
+// maxRecapMinutes, for !recap. A nil recentDonations remembers nothing.
+type recentDonations struct {
+	mu sync.Mutex
+	// Oldest first.
+	entries []recapEntry
+}
+
+type recapEntry struct {
+	at    time.Time
+	owner string
+	desc  string
+}
+
+// add remembers a recorded donation, and forgets the ones that are too old
+// to recap.
+func (r *recentDonations) add(ev donation.Event, now time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cutoff := now.Add(-maxRecapMinutes * time.Minute)
+	i := 0
+	for i < len(r.entries) && r.entries[i].at.Before(cutoff) {
+		i++
+	}
+	r.entries = append(r.entries[i:], recapEntry{at: now, owner: ev.Owner, desc: ev.Description()})
+}
+
+// since returns the donations recorded at or after t, oldest first.
+func (r *recentDonations) since(t time.Time) []recapEntry {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []recapEntry
+	for _, e := range r.entries {
+		if !e.at.Before(t) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// recapAcks condenses the donations into one acknowledgment per donor (e.g.,
+// "usedpizza (5x gift sub, $10.00 donation)"), in the order that each donor
+// first donated.
+func recapAcks(entries []recapEntry) []string {
+	var owners []string
+	descs := make(map[string][]string)
+	for _, e := range entries {
+		key := strings.ToLower(e.owner)
+		if _, ok := descs[key]; !ok {
+			owners = append(owners, e.owner)
+		}
+		descs[key] = append(descs[key], e.desc)
+	}
+	var acks []string
+	for _, owner := range owners {
+		acks = append(acks, fmt.Sprintf("%s (%s)", owner, strings.Join(descs[strings.ToLower(owner)], ", ")))
+	}
+	return acks
+}
+
+// recapMessages batches the acknowledgments into at most max chat messages.
+// If they don't all fit, the last message counts the rest.
+func recapMessages(acks []string, prefix string, max int) []string {
+	const contPrefix = "More thanks: "
+	var msgs []string
+	msg := prefix
+	listed := 0
+	for i, ack := range acks {
+		more := ""
+		if len(msgs) == max-1 {
+			if rest := len(acks) - i - 1; rest > 0 {
+				more = fmt.Sprintf(", and %d more", rest)
+			}
+		}
+		sep := ""
+		if listed > 0 {
+			sep = ", "
+		}
+		if len(msg)+len(sep)+len(ack)+len(more) > maxChatLength && listed > 0 {
+			if len(msgs) == max-1 {
+				return append(msgs, fmt.Sprintf("%s, and %d more", msg, len(acks)-i))
+			}
+			msgs = append(msgs, msg)
+			msg, sep, listed = contPrefix, "", 0
+		}
+		msg += sep + ack
+		listed++
+	}
+	return append(msgs, msg)
+}
+
+// dispatchRecapCommand re-announces the donations recorded in the last few
+// minutes, for when the donors missed their acknowledgments (e.g., because
+// the stream went down).
+func (b *bot) dispatchRecapCommand(m twitch.PrivateMessage) {
+	minutes, err := strconv.Atoi(commandArgs(m.Message))
+	if err != nil || minutes <= 0 || minutes > maxRecapMinutes {
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s <minutes>, up to %d minutes", m.User.Name, recapCommand, maxRecapMinutes))
+		return
+	}
+	entries, err := b.recapEntries(time.Now().Add(-time.Duration(minutes) * time.Minute))
+	if err != nil {
+		log.Printf("ERROR reading the donation table for a recap: %v", err)
+		b.say(m.Channel, fmt.Sprintf("@%s: I couldn't read the recent donations, sorry.", m.User.Name))
+		return
+	}
+	acks := recapAcks(entries)
+	if len(acks) == 0 {
+		b.say(m.Channel, fmt.Sprintf("@%s: No donations were recorded in the last %d minutes.", m.User.Name, minutes))
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&b.recapping, 0, 1) {
+		b.say(m.Channel, fmt.Sprintf("@%s: I'm still posting the last recap.", m.User.Name))
+		return
+	}
+	log.Printf("%s asked for a recap of the last %d minutes (%d donors)", m.User.Name, minutes, len(acks))
+	prefix := fmt.Sprintf("Thanks for your support in the last %d minutes: ", minutes)
+	msgs := recapMessages(acks, prefix, maxRecapMessages)
+	go func() {
+		defer atomic.StoreInt32(&b.recapping, 0)
+		for i, msg := range msgs {
+			if i > 0 {
+				time.Sleep(recapMessageInterval)
+			}
+			b.say(m.Channel, msg)
+		}
+	}()
+}
+
+// recapEntries returns the donations recorded at or after t, oldest first.
+// If the donation table records times, they're read from there, so that the
+// recap covers donations from before the bot restarted.
+func (b *bot) recapEntries(t time.Time) ([]recapEntry, error) {
+	if b.donationTable == nil || !b.donationTable.RecordsTime() {
+		return b.recent.since(t), nil
+	}
+	vr, err := b.donationTable.GetTable()
+	if err != nil {
+		return nil, err
+	}
+	return recapFromTable(vr.Values, t), nil
+}
+
+// recapFromTable picks the donations recorded at or after t out of the rows
+// of the donation table. Rows that were split off from other rows, and bid
+// war credit that isn't money, are left out.
+func recapFromTable(rows [][]interface{}, t time.Time) []recapEntry {
+	var entries []recapEntry
+	for _, row := range rows {
+		at, ok := googlesheets.RowTime(row)
+		if !ok || at.Before(t) {
+			continue
+		}
+		owner, what, source := recapCell(row, 0), recapCell(row, 1), recapCell(row, 5)
+		if owner == "" || bidwar.IsSplitOff(what) || donation.Source(source).IsCredit() {
+			continue
+		}
+		entries = append(entries, recapEntry{at: at, owner: owner, desc: what})
+	}
+	return entries
+}
+
+func recapCell(row []interface{}, n int) string {
+	if n >= len(row) {
+		return ""
+	}
+	s, _ := row[n].(string)
+	return s
+}
+
+// rememberForRecap adds a recorded donation to the ones that !recap can
+// re-announce. Bid war credit that isn't money (e.g., channel point
+// redemptions) isn't a donation, so it's left out.
+func (b *bot) rememberForRecap(ev donation.Event) {
+	if ev.Source.IsCredit() {
+		return
+	}
+	b.recent.add(ev, time.Now())
+}
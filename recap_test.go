@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestRecentDonations(t *testing.T) {
+	now := time.Now()
+	r := &recentDonations{}
+	r.add(donation.Event{Owner: "old", Cash: donation.CentsValue(100)}, now.Add(-3*time.Hour))
+	r.add(donation.Event{Owner: "usedpizza", SubCount: 5, SubMonths: 1, SubTier: donation.SubTier1, Type: donation.CommunityGift}, now.Add(-20*time.Minute))
+	r.add(donation.Event{Owner: "aerionblue", Bits: 500}, now.Add(-5*time.Minute))
+	r.add(donation.Event{Owner: "UsedPizza", Cash: donation.CentsValue(1000)}, now.Add(-time.Minute))
+
+	if len(r.entries) != 3 {
+		t.Errorf("got %d entries, want the oldest one forgotten", len(r.entries))
+	}
+	got := recapAcks(r.since(now.Add(-30 * time.Minute)))
+	want := []string{"usedpizza (5x gift sub, $10.00 donation)", "aerionblue (500 bits)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := recapAcks(r.since(now.Add(-10 * time.Minute))); len(got) != 2 || got[0] != "aerionblue (500 bits)" {
+		t.Errorf("got %q for the last 10 minutes", got)
+	}
+}
+
+func TestRecapMessages(t *testing.T) {
+	var acks []string
+	for i := 0; i < 100; i++ {
+		acks = append(acks, fmt.Sprintf("donor%02d ($5.00 donation)", i))
+	}
+	msgs := recapMessages(acks, "Thanks: ", 3)
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3", len(msgs))
+	}
+	listed := 0
+	for _, msg := range msgs {
+		if len(msg) > maxChatLength {
+			t.Errorf("message is too long (%d): %q", len(msg), msg)
+		}
+		listed += strings.Count(msg, "donation)")
+	}
+	if want := fmt.Sprintf(", and %d more", 100-listed); !strings.HasSuffix(msgs[2], want) {
+		t.Errorf("last message %q should end with %q", msgs[2], want)
+	}
+
+	if got, want := recapMessages(acks[:2], "Thanks: ", 3), []string{"Thanks: donor00 ($5.00 donation), donor01 ($5.00 donation)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRecapFromTable(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	at := func(ago time.Duration) string { return now.Add(-ago).Format("2006-01-02 15:04:05") }
+	rows := [][]interface{}{
+		{"Contributor", "What", "Points", "Choice", "Message", "Source", "Campaign", "Owner ID", "Time"},
+		{"old", "$1.00 donation", 1.0, "", "", "streamlabs", "", "", at(3 * time.Hour)},
+		{"usedpizza", "5x gift sub", 25.0, "", "", "twitch_sub", "", "", at(20 * time.Minute)},
+		{"aerionblue", "500 bits", 5.0, "", "", "twitch_bits", "", "", at(5 * time.Minute)},
+		{"aerionblue", "channel points redemption", 1.0, "", "", "channel_points", "", "", at(4 * time.Minute)},
+		{"UsedPizza", "$10.00 donation", 10.0, "", "", "streamlabs", "", "", at(time.Minute)},
+		{"UsedPizza", "$10.00 donation (split)", 4.0, "", "", "streamlabs", "", "", at(time.Minute)},
+		{"nobody", "$2.00 donation", 2.0, "", "", "streamlabs"},
+	}
+	got := recapAcks(recapFromTable(rows, now.Add(-30*time.Minute)))
+	want := []string{"usedpizza (5x gift sub, $10.00 donation)", "aerionblue (500 bits)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
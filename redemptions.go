@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// validateChannelPoints checks that each reward is listed once, with a
+// sensible value.
+func validateChannelPoints(c ChannelPointsConfig) error {
+	seen := make(map[string]bool)
+	for _, r := range c.Rewards {
+		if r.ID == "" {
+			return fmt.Errorf("channel point reward ID must not be empty")
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("channel point reward %q is listed more than once", r.ID)
+		}
+		seen[r.ID] = true
+		if r.Cents < 0 {
+			return fmt.Errorf("channel point reward %q must not be worth less than 0, got %d", r.ID, r.Cents)
+		}
+	}
+	return nil
+}
+
+// channelPointReward returns the configured reward with the given ID.
+func (b *bot) channelPointReward(id string) (ChannelPointReward, bool) {
+	for _, r := range b.channelPoints.Rewards {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return ChannelPointReward{}, false
+}
+
+// dispatchEventSubRedemption handles a channel point redemption from
+// EventSub. Redemptions of rewards that aren't configured are ignored.
+func (b *bot) dispatchEventSubRedemption(ev donation.Event) {
+	if reward, ok := b.channelPointReward(ev.Reward); ok {
+		b.dispatchRedemption(ev, reward)
+	}
+}
+
+// dispatchRedemption counts a channel point redemption towards the option
+// named in its message, as a vote or for the reward's configured value.
+// Unlike a donation, a redemption that doesn't name an open option isn't
+// recorded, since it wouldn't count for anything.
+func (b *bot) dispatchRedemption(ev donation.Event, reward ChannelPointReward) {
+	go b.redemptionTask(ev, reward)()
+}
+
+func (b *bot) redemptionTask(ev donation.Event, reward ChannelPointReward) donationTask {
+	received := time.Now()
+	ev.Credit = donation.CentsValue(reward.Cents)
+	b.status.sawDonation(ev.Source, received)
+	log.Printf("new channel point redemption by %v worth $%s (reward: %s)", ev.Owner, ev.Value(), ev.Reward)
+	choice := b.collection().ChoiceFromMessage(ev.Message, bidwar.FromChatMessage)
+	return func() error {
+		if choice.Option.IsZero() {
+			switch {
+			case !choice.ClosedOption.IsZero():
+				b.reply(ev.Channel, ev.MessageID, fmt.Sprintf("@%s: %s", ev.Owner, b.closedOptionMessage(choice.ClosedOption)))
+			default:
+				if codes := b.openOptionCodes(); len(codes) > 0 {
+					b.reply(ev.Channel, ev.MessageID, fmt.Sprintf("@%s: I couldn't tell which option you wanted. These are the options: %s", ev.Owner, strings.Join(codes, ", ")))
+				}
+			}
+			return nil
+		}
+		if ok, err := b.tryRecordDonation(ev, choice); !ok {
+			return err
+		}
+		recorded := time.Now()
+		var msg string
+		if ev.Credit > 0 {
+			msg = fmt.Sprintf("@%s: +%s for %s", ev.Owner, ev.Credit, choice.Option.DisplayName)
+		} else {
+			msg = fmt.Sprintf("@%s: Your vote for %s is in!", ev.Owner, choice.Option.DisplayName)
+		}
+		replied := b.replyWithTotals(chatBid, ev.Channel, ev.MessageID, choice.Option, msg+b.overflowNote(choice)) != ""
+		b.observeLatency(ev, received, recorded, replied)
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages of handling a donation, timed from when the bot received it.
+const (
+	// The donation was written to the database.
+	StageRecorded = "recorded"
+	// The bot replied in chat with the new totals.
+	StageReplied = "replied"
+)
+
+// LatencyTracker collects how long donations take to get through each stage.
+// A nil LatencyTracker ignores everything.
+type LatencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{samples: make(map[string][]time.Duration)}
+}
+
+// Observe records that a donation reached a stage d after it was received.
+func (lt *LatencyTracker) Observe(stage string, d time.Duration) {
+	if lt == nil {
+		return
+	}
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.samples[stage] = append(lt.samples[stage], d)
+}
+
+// LatencySummary describes the latencies observed for one stage.
+type LatencySummary struct {
+	Stage string
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Summary returns a summary for each stage that has been observed, with
+// StageRecorded and StageReplied first.
+func (lt *LatencyTracker) Summary() []LatencySummary {
+	if lt == nil {
+		return nil
+	}
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	var stages []string
+	for stage := range lt.samples {
+		stages = append(stages, stage)
+	}
+	sort.Slice(stages, func(i, j int) bool {
+		ri, rj := stageRank(stages[i]), stageRank(stages[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return stages[i] < stages[j]
+	})
+	var summaries []LatencySummary
+	for _, stage := range stages {
+		ds := append([]time.Duration(nil), lt.samples[stage]...)
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		summaries = append(summaries, LatencySummary{
+			Stage: stage,
+			Count: len(ds),
+			P50:   percentile(ds, 50),
+			P90:   percentile(ds, 90),
+			P99:   percentile(ds, 99),
+			Max:   ds[len(ds)-1],
+		})
+	}
+	return summaries
+}
+
+func stageRank(stage string) int {
+	switch stage {
+	case StageRecorded:
+		return 0
+	case StageReplied:
+		return 1
+	}
+	return 2
+}
+
+// percentile returns the p-th percentile of a sorted, non-empty slice, using
+// the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func writeLatency(b *strings.Builder, summaries []LatencySummary) {
+	b.WriteString("== Latency since receipt ==\n")
+	fmt.Fprintf(b, "%-10s %6s %9s %9s %9s %9s\n", "Stage", "Count", "p50", "p90", "p99", "max")
+	for _, s := range summaries {
+		fmt.Fprintf(b, "%-10s %6d %9s %9s %9s %9s\n", s.Stage, s.Count,
+			roundMillis(s.P50), roundMillis(s.P90), roundMillis(s.P99), roundMillis(s.Max))
+	}
+}
+
+func roundMillis(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
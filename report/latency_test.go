@@ -0,0 +1,49 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestLatencySummary(t *testing.T) {
+	lt := NewLatencyTracker()
+	for i := 1; i <= 100; i++ {
+		lt.Observe(StageReplied, time.Duration(i)*time.Millisecond)
+	}
+	lt.Observe(StageRecorded, 300*time.Millisecond)
+	lt.Observe(StageRecorded, 100*time.Millisecond)
+	lt.Observe(StageRecorded, 200*time.Millisecond)
+	want := []LatencySummary{
+		{Stage: StageRecorded, Count: 3, P50: 200 * time.Millisecond, P90: 300 * time.Millisecond, P99: 300 * time.Millisecond, Max: 300 * time.Millisecond},
+		{Stage: StageReplied, Count: 100, P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond},
+	}
+	if diff := deep.Equal(lt.Summary(), want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestNilLatencyTracker(t *testing.T) {
+	var lt *LatencyTracker
+	lt.Observe(StageRecorded, time.Second)
+	if got := lt.Summary(); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
+
+func TestReportString_Latency(t *testing.T) {
+	r := Report{Latency: []LatencySummary{
+		{Stage: StageRecorded, Count: 12, P50: 210 * time.Millisecond, P90: 480 * time.Millisecond, P99: 1234567 * time.Microsecond, Max: 2 * time.Second},
+	}}
+	want := `== Donations by source ==
+Total                 0         0.00
+
+== Latency since receipt ==
+Stage       Count       p50       p90       p99       max
+recorded       12     210ms     480ms    1.235s        2s
+`
+	if got := r.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
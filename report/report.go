@@ -0,0 +1,198 @@
+// Package report summarizes an event's donations, for reconciling with the
+// providers' payout reports after the event.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/money"
+)
+
+// Columns of the donation table.
+const (
+	colWhat     = 1
+	colPoints   = 2
+	colSource   = 5
+	colCampaign = 6
+	colNet      = 9
+)
+
+// SourceTotal is the total value of the donations from one Source.
+type SourceTotal struct {
+	Source donation.Source
+	Count  int
+	Value  donation.CentsValue
+	// What's left after the payment processors' fees. Rows without a net
+	// amount (see googlesheets.DonationTable.RecordNet) count in full.
+	Net donation.CentsValue
+}
+
+// SourceTotals adds up the money in the rows of the donation table
+// (including the header) by source, largest total first. The source is read
+// from the table's source column if it's there. Otherwise, subs and bits are
+// recognized by their description, and anything else counts as unknown.
+// Bid war credit that isn't money (see donation.Source.IsCredit) is left out.
+func SourceTotals(rows [][]interface{}) []SourceTotal {
+	return totalsBySource(rows, false)
+}
+
+// CreditTotals is like SourceTotals, but only adds up the bid war credit
+// that isn't money.
+func CreditTotals(rows [][]interface{}) []SourceTotal {
+	return totalsBySource(rows, true)
+}
+
+func totalsBySource(rows [][]interface{}, credit bool) []SourceTotal {
+	var totals []SourceTotal
+	index := make(map[donation.Source]int)
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		cents, ok := cellCents(row, colPoints)
+		if !ok {
+			continue
+		}
+		src := rowSource(row)
+		if src.IsCredit() != credit {
+			continue
+		}
+		j, ok := index[src]
+		if !ok {
+			j = len(totals)
+			index[src] = j
+			totals = append(totals, SourceTotal{Source: src})
+		}
+		totals[j].Count++
+		totals[j].Value += donation.CentsValue(cents)
+		if net, ok := cellCents(row, colNet); ok {
+			totals[j].Net += donation.CentsValue(net)
+		} else {
+			totals[j].Net += donation.CentsValue(cents)
+		}
+	}
+	sort.SliceStable(totals, func(i, j int) bool { return totals[i].Value > totals[j].Value })
+	return totals
+}
+
+// FilterCampaign returns the header and the rows of the donation table that
+// were recorded during the given campaign (case-insensitive).
+func FilterCampaign(rows [][]interface{}, campaign string) [][]interface{} {
+	var filtered [][]interface{}
+	for i, row := range rows {
+		if i == 0 || strings.EqualFold(cellString(row, colCampaign), campaign) {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered
+}
+
+func rowSource(row []interface{}) donation.Source {
+	if src := cellString(row, colSource); src != "" {
+		return donation.Source(src)
+	}
+	what := cellString(row, colWhat)
+	switch {
+	case strings.HasSuffix(what, " bits"):
+		return donation.SourceTwitchBits
+	case strings.HasSuffix(what, "sub"):
+		return donation.SourceTwitchSub
+	}
+	return donation.SourceUnknown
+}
+
+func cellString(row []interface{}, n int) string {
+	if n >= len(row) {
+		return ""
+	}
+	s, _ := row[n].(string)
+	return strings.TrimSpace(s)
+}
+
+func cellCents(row []interface{}, n int) (int, bool) {
+	if n >= len(row) {
+		return 0, false
+	}
+	switch v := row[n].(type) {
+	case string:
+		cents, err := money.ParseCents(v)
+		return cents, err == nil
+	case float64:
+		return money.FromFloat(v), true
+	}
+	return 0, false
+}
+
+// DescribeSources summarizes the source totals on one line, for chat.
+func DescribeSources(totals []SourceTotal) string {
+	if len(totals) == 0 {
+		return "No donations yet."
+	}
+	var parts []string
+	var sum donation.CentsValue
+	for _, st := range totals {
+		parts = append(parts, fmt.Sprintf("%s: %s (%d)", st.Source.DisplayName(), st.Value, st.Count))
+		sum += st.Value
+	}
+	return fmt.Sprintf("%s | Total: %s", strings.Join(parts, ", "), sum)
+}
+
+// Report is the end-of-event report.
+type Report struct {
+	// If set, the report only covers this campaign's donations.
+	Campaign string
+	Sources  []SourceTotal
+	// Bid war credit that isn't money (see CreditTotals). Omitted from the
+	// report if empty.
+	Credits []SourceTotal
+	// Omitted from the report if empty.
+	Latency []LatencySummary
+}
+
+// String formats the report as plain text, one section after another.
+func (r Report) String() string {
+	var b strings.Builder
+	if r.Campaign != "" {
+		fmt.Fprintf(&b, "== Donations by source (%s) ==\n", r.Campaign)
+	} else {
+		b.WriteString("== Donations by source ==\n")
+	}
+	// The net amounts are only shown if some fees were recorded.
+	var hasFees bool
+	for _, st := range r.Sources {
+		if st.Net != st.Value {
+			hasFees = true
+		}
+	}
+	var count int
+	var sum, net donation.CentsValue
+	for _, st := range r.Sources {
+		writeSourceLine(&b, st.Source.DisplayName(), st.Count, st.Value, st.Net, hasFees)
+		count += st.Count
+		sum += st.Value
+		net += st.Net
+	}
+	writeSourceLine(&b, "Total", count, sum, net, hasFees)
+	if len(r.Credits) > 0 {
+		b.WriteString("\n== Bid war credit (not money) ==\n")
+		for _, st := range r.Credits {
+			writeSourceLine(&b, st.Source.DisplayName(), st.Count, st.Value, st.Value, false)
+		}
+	}
+	if len(r.Latency) > 0 {
+		b.WriteString("\n")
+		writeLatency(&b, r.Latency)
+	}
+	return b.String()
+}
+
+func writeSourceLine(b *strings.Builder, name string, count int, value, net donation.CentsValue, withNet bool) {
+	if withNet {
+		fmt.Fprintf(b, "%-16s %6d %12s %12s\n", name, count, value, net)
+		return
+	}
+	fmt.Fprintf(b, "%-16s %6d %12s\n", name, count, value)
+}
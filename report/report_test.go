@@ -0,0 +1,107 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+func TestSourceTotals(t *testing.T) {
+	rows := [][]interface{}{
+		{"Contributor", "What", "Points", "Choice", "Message", "Source"},
+		{"aerionblue", "sub", 5.0},
+		{"AEWC20XX", "2x tier 2 gift sub", "20.00"},
+		{"Mizalie", "444 bits", 4.44, "", "", "twitch_bits"},
+		{"ShartyMcFly", "$11.00 donation", "11.00", "Moo", "", "streamlabs"},
+		{"usedpizza", "$5.00 donation", "5.00", "", "", "streamelements"},
+		{"usedpizza", "$25.00 donation", "25.00", "", "", "streamelements", "", "", "", "23.98"},
+		{"someone", "$3.00 donation", "3.00"},
+		{"AEWC20XX", "channel point redemption", "1.00", "Moo", "", "channel_points"},
+		{"AEWC20XX", "channel point redemption", "1.00", "NBC", "", "channel_points"},
+		{"Hype Train", "8.00-point Hype Train bonus", "8.00", "", "", "hype_train"},
+		{"", "", ""},
+	}
+	want := []SourceTotal{
+		{Source: donation.SourceStreamElements, Count: 2, Value: 3000, Net: 2898},
+		{Source: donation.SourceTwitchSub, Count: 2, Value: 2500, Net: 2500},
+		{Source: donation.SourceStreamlabs, Count: 1, Value: 1100, Net: 1100},
+		{Source: donation.SourceTwitchBits, Count: 1, Value: 444, Net: 444},
+		{Source: donation.SourceUnknown, Count: 1, Value: 300, Net: 300},
+	}
+	if diff := deep.Equal(SourceTotals(rows), want); diff != nil {
+		t.Error(diff)
+	}
+	wantCredit := []SourceTotal{
+		{Source: donation.SourceHypeTrain, Count: 1, Value: 800, Net: 800},
+		{Source: donation.SourceChannelPoints, Count: 2, Value: 200, Net: 200},
+	}
+	if diff := deep.Equal(CreditTotals(rows), wantCredit); diff != nil {
+		t.Errorf("credit: %v", diff)
+	}
+}
+
+func TestFilterCampaign(t *testing.T) {
+	rows := [][]interface{}{
+		{"Contributor", "What", "Points", "Choice", "Message", "Source", "Campaign"},
+		{"aerionblue", "sub", 5.0},
+		{"Mizalie", "444 bits", 4.44, "", "", "twitch_bits", "Friday night"},
+		{"usedpizza", "$5.00 donation", "5.00", "", "", nil, "friday night "},
+		{"usedpizza", "$25.00 donation", "25.00", "", "", nil, "Saturday"},
+	}
+	want := [][]interface{}{rows[0], rows[2], rows[3]}
+	if diff := deep.Equal(FilterCampaign(rows, "Friday Night"), want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestReportString(t *testing.T) {
+	r := Report{Sources: []SourceTotal{
+		{Source: donation.SourceStreamlabs, Count: 2, Value: 3000, Net: 3000},
+		{Source: donation.SourceTwitchBits, Count: 1, Value: 444, Net: 444},
+	}}
+	want := `== Donations by source ==
+Streamlabs            2        30.00
+Twitch bits           1         4.44
+Total                 3        34.44
+`
+	if got := r.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	r.Sources[0].Net = 2882
+	want = `== Donations by source ==
+Streamlabs            2        30.00        28.82
+Twitch bits           1         4.44         4.44
+Total                 3        34.44        33.26
+`
+	if got := r.String(); got != want {
+		t.Errorf("with fees, got:\n%s\nwant:\n%s", got, want)
+	}
+
+	r.Sources[0].Net = 3000
+	r.Credits = []SourceTotal{{Source: donation.SourceChannelPoints, Count: 5, Value: 500, Net: 500}}
+	want = `== Donations by source ==
+Streamlabs            2        30.00
+Twitch bits           1         4.44
+Total                 3        34.44
+
+== Bid war credit (not money) ==
+Channel points        5         5.00
+`
+	if got := r.String(); got != want {
+		t.Errorf("with credit, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDescribeSources(t *testing.T) {
+	got := DescribeSources([]SourceTotal{
+		{Source: donation.SourceStreamlabs, Count: 2, Value: 3000},
+		{Source: donation.SourceUnknown, Count: 1, Value: 444},
+	})
+	want := "Streamlabs: 30.00 (2), Unknown: 4.44 (1) | Total: 34.44"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
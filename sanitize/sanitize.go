@@ -0,0 +1,55 @@
+// Package sanitize cleans up donor-written text before it goes into the
+// spreadsheet. Donor messages can be very long, or contain newlines and other
+// control characters that mess up the sheet's formatting.
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DefaultMaxLength is the length cap used when Options.MaxLength is zero.
+const DefaultMaxLength = 250
+
+var /* const */ urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// Options controls how text is cleaned up.
+type Options struct {
+	// The maximum length of the text, in characters (not bytes). Longer text
+	// is cut off with an ellipsis. If zero, DefaultMaxLength is used; if
+	// negative, the length isn't capped.
+	MaxLength int
+	// Whether to replace URLs with "[link]".
+	StripURLs bool
+}
+
+// Clean returns a tidied-up copy of the text. Control characters (including
+// newlines) are dropped or replaced by spaces, runs of whitespace are
+// collapsed, and the result is capped at the maximum length.
+func (o Options) Clean(s string) string {
+	if o.StripURLs {
+		s = urlPattern.ReplaceAllString(s, "[link]")
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune(' ')
+		case unicode.IsControl(r), r == unicode.ReplacementChar:
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+	s = strings.Join(strings.Fields(b.String()), " ")
+
+	max := o.MaxLength
+	if max == 0 {
+		max = DefaultMaxLength
+	}
+	if runes := []rune(s); max > 0 && len(runes) > max {
+		s = strings.TrimSpace(string(runes[:max-1])) + "…"
+	}
+	return s
+}
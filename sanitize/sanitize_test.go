@@ -0,0 +1,33 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		opts Options
+		in   string
+		want string
+	}{
+		{"leaves normal text alone", Options{}, "all on moo", "all on moo"},
+		{"replaces newlines and tabs", Options{}, "moo\nmoo\tmoo\r\n", "moo moo moo"},
+		{"drops control characters", Options{}, "m\x00o\x1bo", "moo"},
+		{"collapses whitespace", Options{}, "  all   on  moo ", "all on moo"},
+		{"keeps emoji", Options{}, "🐄🐄 moo 🐄🐄", "🐄🐄 moo 🐄🐄"},
+		{"caps length", Options{MaxLength: 8}, "all of it on moo", "all of…"},
+		{"caps length in characters", Options{MaxLength: 4}, "🐄🐄🐄🐄🐄", "🐄🐄🐄…"},
+		{"no cap", Options{MaxLength: -1}, strings.Repeat("a", 300), strings.Repeat("a", 300)},
+		{"default cap", Options{}, strings.Repeat("a", 300), strings.Repeat("a", DefaultMaxLength-1) + "…"},
+		{"keeps URLs by default", Options{}, "moo https://example.com/x", "moo https://example.com/x"},
+		{"strips URLs", Options{StripURLs: true}, "moo https://example.com/x and www.example.com", "moo [link] and [link]"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := tc.opts.Clean(tc.in); got != tc.want {
+				t.Errorf("Clean(%q): got %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
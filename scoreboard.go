@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/googlesheets"
+)
+
+// publishScoreboard writes the current standings to the scoreboard sheet
+// every interval. It never returns.
+func publishScoreboard(b *bot, sb *googlesheets.Scoreboard, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := updateScoreboard(b, sb); err != nil {
+			log.Printf("ERROR publishing scoreboard: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+func updateScoreboard(b *bot, sb *googlesheets.Scoreboard) error {
+	totals, err := b.bidwarTallier.GetTotals()
+	if err != nil {
+		return err
+	}
+	return sb.Publish(bidwar.ScoreboardRows(b.collection(), totals, time.Now()))
+}
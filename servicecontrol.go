@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aerionblue/pizzafest/control"
+)
+
+// handleControlCommands carries out the commands from a control file (or a
+// shutdown signal) in every channel until it's told to shut down. The
+// shutdown is announced in each channel, if that's enabled.
+func handleControlCommands(channels []*channel, cmds <-chan control.Command) {
+	for cmd := range cmds {
+		log.Printf("control command: %s", cmd)
+		switch cmd {
+		case control.Pause:
+			for _, c := range channels {
+				c.bot.setQuiet(true)
+			}
+		case control.Resume:
+			for _, c := range channels {
+				c.bot.setQuiet(false)
+			}
+		case control.ReloadConfig:
+			for _, c := range channels {
+				if _, err := c.bot.reloadBidWars(); err != nil {
+					log.Printf("ERROR reloading bid wars for #%s: %v", c.name, err)
+				}
+			}
+		case control.Shutdown:
+			for _, c := range channels {
+				c.bot.announceShutdown(c.name)
+			}
+			log.Print("disconnecting from IRC...")
+			// The channels all share one IRC client.
+			if err := channels[0].bot.ircClient.Disconnect(); err != nil {
+				log.Printf("ERROR disconnecting from IRC: %v", err)
+			}
+			return
+		}
+	}
+}
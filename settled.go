@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/db"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// settledDonors remembers which donors are known to have no unassigned
+// donations, so that a cheer with a bid doesn't read the whole donation
+// table to look for some. A donor is unknown again once an unassigned
+// donation of theirs is recorded, and everyone is after the table is edited
+// by hand (see !refresh) or names are linked. A nil settledDonors knows
+// nothing.
+//
+// A donation can be recorded while the table is being read, so a donor is
+// only settled if they weren't unsettled since the read began: take a
+// generation before reading, and pass it to settle.
+type settledDonors struct {
+	mu sync.Mutex
+	// Counts the unsettles and resets.
+	gen uint64
+	// Keyed by lowercase username.
+	settled map[string]bool
+	// The generation of each donor's last unsettle, keyed by lowercase
+	// username.
+	unsettledAt map[string]uint64
+	// The generation of the last reset.
+	resetAt uint64
+}
+
+func newSettledDonors() *settledDonors {
+	return &settledDonors{settled: make(map[string]bool), unsettledAt: make(map[string]uint64)}
+}
+
+// generation returns the current generation, to pass to settle once the
+// table has been read.
+func (s *settledDonors) generation() uint64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gen
+}
+
+// settle records that the user had no unassigned donations as of the given
+// generation. It does nothing if the user was unsettled since then.
+func (s *settledDonors) settle(user string, since uint64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user = strings.ToLower(user)
+	if s.resetAt > since || s.unsettledAt[user] > since {
+		return
+	}
+	s.settled[user] = true
+}
+
+// unsettle records that the user may have unassigned donations.
+func (s *settledDonors) unsettle(user string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user = strings.ToLower(user)
+	s.gen++
+	delete(s.settled, user)
+	s.unsettledAt[user] = s.gen
+}
+
+// isSettled reports whether the user is known to have no unassigned
+// donations.
+func (s *settledDonors) isSettled(user string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settled[strings.ToLower(user)]
+}
+
+// reset forgets what's known about everyone.
+func (s *settledDonors) reset() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	s.resetAt = s.gen
+	s.settled = make(map[string]bool)
+	s.unsettledAt = make(map[string]uint64)
+}
+
+// unsettlingRecorder records donations with rec and unsettles the donors of
+// unassigned ones, for the paths that record without going through
+// tryRecordDonation (e.g. the retry journal).
+type unsettlingRecorder struct {
+	rec db.Recorder
+	b   *bot
+}
+
+func (r unsettlingRecorder) RecordDonation(ev donation.Event, bid bidwar.Choice) error {
+	if bid.Option.IsZero() {
+		// Unsettle first: even a failed write may have added the row.
+		r.b.settled.unsettle(r.b.identities.TwitchUser(ev.Owner))
+	}
+	return r.rec.RecordDonation(ev, bid)
+}
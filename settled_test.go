@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aerionblue/pizzafest/bidwar"
+	"github.com/aerionblue/pizzafest/db"
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// earlierTallier counts the reads for a cheerer's unassigned donations.
+type earlierTallier struct {
+	*fakeBackend
+	reads int
+	// Runs during each read, if set.
+	during func()
+}
+
+func (e *earlierTallier) AssignToChoice(donor string, choice bidwar.Choice) (bidwar.UpdateStats, error) {
+	e.reads++
+	if e.during != nil {
+		e.during()
+	}
+	return bidwar.UpdateStats{Choice: choice, Count: 2, TotalValue: 500}, nil
+}
+
+func TestAssignEarlierDonations(t *testing.T) {
+	defer quietLogs()()
+	tallier := &earlierTallier{fakeBackend: newFakeBackend(0)}
+	b := newLoadTestBot(t, tallier.fakeBackend)
+	b.bidwarTallier = tallier
+	b.settled = newSettledDonors()
+	opt, ok := b.collection().FindOption("moo")
+	if !ok {
+		t.Fatal("no option for moo")
+	}
+	bid := bidwar.Choice{Option: opt}
+	cheer := donation.Event{Owner: "aerionblue", Bits: 100}
+
+	if got, want := b.assignEarlierDonations(cheer, bid), (bidwar.UpdateStats{Choice: bid, Count: 2, TotalValue: 500}); !reflect.DeepEqual(got, want) {
+		t.Errorf("first cheer: got %+v, want %+v", got, want)
+	}
+	if got := b.assignEarlierDonations(cheer, bid); got.Count != 0 {
+		t.Errorf("second cheer: got %d earlier donations, want 0", got.Count)
+	}
+	if tallier.reads != 1 {
+		t.Errorf("after two cheers: read the table %d times, want 1", tallier.reads)
+	}
+	if got := b.assignEarlierDonations(donation.Event{Owner: "usedpizza", Bits: 100}, bid); got.Count != 2 {
+		t.Errorf("another donor's cheer: got %d earlier donations, want 2", got.Count)
+	}
+
+	// An unassigned donation means there's something to look for again.
+	if _, err := b.tryRecordDonation(donation.Event{Owner: "AerionBlue", Cash: 500}, bidwar.Choice{}); err != nil {
+		t.Fatalf("recording an unassigned donation: %v", err)
+	}
+	if got := b.assignEarlierDonations(cheer, bid); got.Count != 2 {
+		t.Errorf("cheer after an unassigned donation: got %d earlier donations, want 2", got.Count)
+	}
+	if tallier.reads != 3 {
+		t.Errorf("read the table %d times, want 3", tallier.reads)
+	}
+
+	// So does a refresh, since the table may have been edited by hand.
+	b.settled.reset()
+	b.assignEarlierDonations(cheer, bid)
+	if tallier.reads != 4 {
+		t.Errorf("after a refresh: read the table %d times, want 4", tallier.reads)
+	}
+}
+
+func TestSettleAfterUnsettle(t *testing.T) {
+	defer quietLogs()()
+	tallier := &earlierTallier{fakeBackend: newFakeBackend(0)}
+	b := newLoadTestBot(t, tallier.fakeBackend)
+	b.bidwarTallier = tallier
+	b.settled = newSettledDonors()
+	opt, ok := b.collection().FindOption("moo")
+	if !ok {
+		t.Fatal("no option for moo")
+	}
+	bid := bidwar.Choice{Option: opt}
+	cheer := donation.Event{Owner: "aerionblue", Bits: 100}
+
+	// A donation recorded during the read may not have been seen by it.
+	tallier.during = func() { b.settled.unsettle("AerionBlue") }
+	b.assignEarlierDonations(cheer, bid)
+	tallier.during = nil
+	b.assignEarlierDonations(cheer, bid)
+	if tallier.reads != 2 {
+		t.Errorf("after an unsettle during the read: read the table %d times, want 2", tallier.reads)
+	}
+}
+
+func TestReplayUnsettles(t *testing.T) {
+	defer quietLogs()()
+	tallier := &earlierTallier{fakeBackend: newFakeBackend(0)}
+	b := newLoadTestBot(t, tallier.fakeBackend)
+	b.bidwarTallier = tallier
+	b.settled = newSettledDonors()
+	b.settled.settle("aerionblue", b.settled.generation())
+
+	j := db.NewJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	if err := j.Add(donation.Event{Owner: "AerionBlue", Cash: 500}, bidwar.Choice{}, errors.New("sheet down")); err != nil {
+		t.Fatalf("adding to the journal: %v", err)
+	}
+	if _, err := j.Replay(unsettlingRecorder{rec: b.dbRecorder, b: b}); err != nil {
+		t.Fatalf("replaying the journal: %v", err)
+	}
+	if b.settled.isSettled("aerionblue") {
+		t.Error("after replaying an unassigned donation: donor is still settled")
+	}
+}
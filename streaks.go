@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// How long we remember donations for hourly streaks. Longer streaks are still
+// recognized, but as 24 hours.
+const streakMemory = 24 * time.Hour
+
+// streakTracker remembers when each donor donated, so that we can thank the
+// donors who keep coming back. A nil streakTracker recognizes nothing.
+type streakTracker struct {
+	// Donation counts within window that get recognized.
+	repeats []int
+	window  time.Duration
+	// Recognize donors who have donated in at least this many consecutive
+	// clock hours. 0 means never.
+	hours int
+	// The file that the donation times are kept in, if any.
+	path string
+
+	mu sync.Mutex
+	// Keyed by lowercase username. Oldest first.
+	donations map[string][]time.Time
+}
+
+// newStreakTracker creates a streakTracker, or returns nil if streaks are
+// disabled. If path isn't empty, the donation times are read from it, and
+// kept there from then on.
+func newStreakTracker(cfg StreaksConfig, path string) (*streakTracker, error) {
+	if len(cfg.RepeatCounts) == 0 && cfg.ConsecutiveHours == 0 {
+		return nil, nil
+	}
+	s := &streakTracker{
+		repeats:   cfg.RepeatCounts,
+		window:    time.Duration(cfg.WindowHours) * time.Hour,
+		hours:     cfg.ConsecutiveHours,
+		path:      path,
+		donations: make(map[string][]time.Time),
+	}
+	if path == "" {
+		return s, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read streaks file: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.donations); err != nil {
+		return nil, fmt.Errorf("could not parse streaks file: %v", err)
+	}
+	return s, nil
+}
+
+// memory returns how long donations are remembered.
+func (s *streakTracker) memory() time.Duration {
+	if s.window < streakMemory {
+		return streakMemory
+	}
+	return s.window
+}
+
+// save replaces the contents of the streaks file, if there is one, with the
+// donations that are still remembered. It forgets the donors who have none
+// left. s.mu must be held.
+func (s *streakTracker) save(now time.Time) error {
+	for key, times := range s.donations {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= s.memory() {
+			delete(s.donations, key)
+		}
+	}
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.donations)
+	if err != nil {
+		return err
+	}
+	// Write to a temp file first, so we don't lose the streaks if we crash.
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write streaks file: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// add records a donation by the given user, and returns a message recognizing
+// them if the donation completes a streak, or "" otherwise.
+func (s *streakTracker) add(user string, displayName string, now time.Time) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(user)
+	keep := s.memory()
+	var times []time.Time
+	firstThisHour := true
+	for _, t := range s.donations[key] {
+		if now.Sub(t) >= keep {
+			continue
+		}
+		times = append(times, t)
+		if t.Truncate(time.Hour).Equal(now.Truncate(time.Hour)) {
+			firstThisHour = false
+		}
+	}
+	times = append(times, now)
+	s.donations[key] = times
+	if err := s.save(now); err != nil {
+		log.Printf("ERROR saving streaks: %v", err)
+	}
+
+	count := 0
+	for _, t := range times {
+		if now.Sub(t) < s.window {
+			count++
+		}
+	}
+	for _, n := range s.repeats {
+		if count == n {
+			return fmt.Sprintf("That's the %s donation tonight from %s!", ordinal(count), displayName)
+		}
+	}
+	if s.hours > 0 && firstThisHour {
+		if streak := hourlyStreak(times, now); streak >= s.hours {
+			return fmt.Sprintf("%s has donated every hour for %d hours straight!", displayName, streak)
+		}
+	}
+	return ""
+}
+
+// hourlyStreak counts the consecutive clock hours, ending with now's, in
+// which there was at least one donation.
+func hourlyStreak(times []time.Time, now time.Time) int {
+	hours := make(map[time.Time]bool)
+	for _, t := range times {
+		hours[t.Truncate(time.Hour)] = true
+	}
+	streak := 0
+	for h := now.Truncate(time.Hour); hours[h]; h = h.Add(-time.Hour) {
+		streak++
+	}
+	return streak
+}
+
+// ordinal formats n as "1st", "2nd", etc.
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// recognizeStreak thanks a donor in chat if their donation completes a
+// streak. Donations below the minimum don't count.
+func (b *bot) recognizeStreak(ev donation.Event) {
+	if b.streaks == nil || ev.Value() < b.minimumDonation {
+		return
+	}
+	if msg := b.streaks.add(b.identities.TwitchUser(ev.Owner), ev.Owner, time.Now()); msg != "" {
+		b.sayAs(chatDonation, ev.Channel, msg)
+	}
+}
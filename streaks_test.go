@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStreakTracker(t *testing.T) {
+	s, err := newStreakTracker(StreaksConfig{RepeatCounts: []int{3}, WindowHours: 12, ConsecutiveHours: 2}, "")
+	if err != nil {
+		t.Fatalf("newStreakTracker: %v", err)
+	}
+	start := time.Date(2022, 11, 4, 20, 10, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		user  string
+		after time.Duration
+		want  string
+	}{
+		{"aerionblue", 0, ""},
+		{"usedpizza", 0, ""},
+		// Same hour, so no hourly streak yet.
+		{"AerionBlue", 10 * time.Minute, ""},
+		{"aerionblue", 60 * time.Minute, "That's the 3rd donation tonight from aerionblue!"},
+		{"aerionblue", 70 * time.Minute, ""},
+		{"aerionblue", 120 * time.Minute, "aerionblue has donated every hour for 3 hours straight!"},
+		// usedpizza skipped an hour.
+		{"usedpizza", 120 * time.Minute, ""},
+		// The first donations have aged out of the window.
+		{"aerionblue", 15 * time.Hour, ""},
+	} {
+		if got := s.add(tc.user, tc.user, start.Add(tc.after)); got != tc.want {
+			t.Errorf("%s after %v: got %q, want %q", tc.user, tc.after, got, tc.want)
+		}
+	}
+
+	if s, _ := newStreakTracker(StreaksConfig{WindowHours: 12}, ""); s != nil {
+		t.Error("expected a nil tracker when streaks are disabled")
+	}
+}
+
+func TestStreaksSurviveRestart(t *testing.T) {
+	cfg := StreaksConfig{RepeatCounts: []int{3}, WindowHours: 12}
+	path := filepath.Join(t.TempDir(), "streaks.json")
+	start := time.Date(2022, 11, 4, 20, 10, 0, 0, time.UTC)
+	s, err := newStreakTracker(cfg, path)
+	if err != nil {
+		t.Fatalf("newStreakTracker: %v", err)
+	}
+	s.add("usedpizza", "usedpizza", start)
+	s.add("aerionblue", "aerionblue", start.Add(25*time.Hour))
+	s.add("aerionblue", "aerionblue", start.Add(26*time.Hour))
+
+	s, err = newStreakTracker(cfg, path)
+	if err != nil {
+		t.Fatalf("newStreakTracker after a restart: %v", err)
+	}
+	if _, ok := s.donations["usedpizza"]; ok {
+		t.Error("a donor whose donations aged out should be forgotten")
+	}
+	if got, want := s.add("aerionblue", "aerionblue", start.Add(27*time.Hour)), "That's the 3rd donation tonight from aerionblue!"; got != want {
+		t.Errorf("after a restart: got %q, want %q", got, want)
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 102: "102nd"} {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package streamelements
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/aerionblue/pizzafest/money"
 )
 
 // donationData describes a member of the activity feed response. The response
@@ -11,12 +13,14 @@ type seActivity struct {
 	DonationID string       `json:"_id"`
 	CreatedAt  donationTime `json:"createdAt"` // ISO 8601 date
 	Data       donationData `json:"data"`
+	// The activity as it appeared in the response.
+	raw json.RawMessage
 }
 
 type donationData struct {
-	Dollars  float64 `json:"amount"` // The decimal dollar amount.
-	Currency string  `json:"currency"`
-	Donator  string  `json:"username"`
+	Amount   money.Amount `json:"amount"` // The decimal amount, in Currency.
+	Currency string       `json:"currency"`
+	Donator  string       `json:"username"`
 	Message  string
 }
 
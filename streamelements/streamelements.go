@@ -14,12 +14,26 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aerionblue/pizzafest/donation"
 )
 
-const pollInterval = 30 * time.Second
+// Defaults and limits for the polling parameters in the credentials file.
+const (
+	defaultPollInterval = 30 * time.Second
+	minPollInterval     = 5 * time.Second
+	defaultPageSize     = 10
+	maxPageSize         = 100
+)
+
+// The timeout for API requests, unless the caller provides its own HTTP client.
+const defaultHTTPTimeout = 30 * time.Second
+
+// maxPagesPerPoll bounds how many pages we fetch in a single poll, in case the
+// API misbehaves and never returns a short page.
+const maxPagesPerPoll = 20
 
 const (
 	activityFeedUrlTemplate = "https://api.streamelements.com/kappa/v2/activities/%s"
@@ -40,29 +54,59 @@ type DonationPoller struct {
 	seChannelID string
 	ticker      *time.Ticker
 	stop        chan interface{}
+	// Held while polling, so that scheduled and on-demand polls don't overlap.
+	pollMu sync.Mutex
+	// The client used for all API requests.
+	httpClient *http.Client
+	// How many activities to request at a time.
+	pageSize int
+	// The URL of the channel's activity feed. Only overridden in tests.
+	activityFeedUrl string
 
 	// The JWT token for the StreamElements account.
 	authToken string
 	// The creation time of the last donation that was read.
 	lastDonationTime time.Time
+	// The IDs of the activities we've read, with their creation times. The
+	// time bounds on requests are inclusive (and only have 1-second
+	// precision), so we may see the same activity more than once. Only the
+	// activities from the second of lastDonationTime can come back, so the
+	// older ones are forgotten.
+	seenIDs          map[string]time.Time
 	donationCallback func(donation.Event)
+	// The IDs of the activities that were skipped, with their creation times,
+	// so that each one is only reported to ignoredCallback once. Like
+	// seenIDs, the ones that can't come back are forgotten.
+	ignoredIDs      map[string]time.Time
+	ignoredCallback func(reason string, payload []byte)
+	// Converts other currencies and sets the fees.
+	rates donation.Rates
 }
 
 // NewDonationPoller creates a DonationPoller that calls the provided callback once for each donation.
-func NewDonationPoller(ctx context.Context, credsPath string, twitchChannel string) (*DonationPoller, error) {
+// If httpClient is nil, a client with a default timeout is used.
+func NewDonationPoller(ctx context.Context, credsPath string, twitchChannel string, httpClient *http.Client) (*DonationPoller, error) {
 	creds, err := parseCreds(credsPath)
 	if err != nil {
 		return nil, err
 	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
 	d := &DonationPoller{
 		// We could query StreamElements for the Twitch channel associated with the
 		// account, but it's not necessarily the same as the channel we are
 		// operating in (especially when testing).
-		twitchChannel: twitchChannel,
-		seChannelID:   creds.ChannelID,
-		ticker:        time.NewTicker(pollInterval),
-		stop:          make(chan interface{}),
-		authToken:     creds.AuthToken,
+		twitchChannel:   twitchChannel,
+		seChannelID:     creds.ChannelID,
+		ticker:          time.NewTicker(creds.pollInterval()),
+		stop:            make(chan interface{}),
+		httpClient:      httpClient,
+		pageSize:        creds.pageSize(),
+		activityFeedUrl: fmt.Sprintf(activityFeedUrlTemplate, creds.ChannelID),
+		authToken:       creds.AuthToken,
+		seenIDs:         make(map[string]time.Time),
+		ignoredIDs:      make(map[string]time.Time),
 	}
 	return d, nil
 }
@@ -71,6 +115,30 @@ func (d *DonationPoller) OnDonation(cb func(donation.Event)) {
 	d.donationCallback = cb
 }
 
+// SetRates sets the exchange rates and fee schedules for the donations. It
+// must be called before Start.
+func (d *DonationPoller) SetRates(r donation.Rates) {
+	d.rates = r
+}
+
+// OnIgnored sets a callback for the donations that the poller skips (e.g.,
+// because they aren't in US dollars). It gets the reason and the activity as
+// JSON. Each skipped donation is reported once.
+func (d *DonationPoller) OnIgnored(cb func(reason string, payload []byte)) {
+	d.ignoredCallback = cb
+}
+
+// ignore reports a skipped activity, unless it was already reported.
+func (d *DonationPoller) ignore(a seActivity, reason string) {
+	if _, ok := d.ignoredIDs[a.DonationID]; ok {
+		return
+	}
+	d.ignoredIDs[a.DonationID] = a.Time()
+	if d.ignoredCallback != nil {
+		d.ignoredCallback(reason, a.raw)
+	}
+}
+
 // Start starts polling for donations.
 func (d *DonationPoller) Start() error {
 	if d.donationCallback == nil {
@@ -86,12 +154,14 @@ func (d *DonationPoller) Start() error {
 	// Fetch 1 donation. This assumes that the StreamElements API returns the
 	// newest events first. The documentation doesn't actually say that it does
 	// this, but honestly, it doesn't say a lot of things.
-	evs, lastTime, err := d.doDonationRequest(1)
+	// Donations from before we started don't count as ignored.
+	evs, times, ids, err := d.doDonationRequest(1, time.Now(), func(a seActivity, reason string) { d.ignoredIDs[a.DonationID] = a.Time() })
 	if err != nil {
 		return err
 	}
-	d.lastDonationTime = lastTime
 	if len(evs) != 0 {
+		d.lastDonationTime = times[len(times)-1]
+		d.seenIDs[ids[len(ids)-1]] = times[len(times)-1]
 		log.Printf("the last known donation is for $%s from %s", evs[0].Value(), evs[0].Owner)
 	}
 	go func() {
@@ -100,7 +170,9 @@ func (d *DonationPoller) Start() error {
 			case <-d.stop:
 				return
 			case <-d.ticker.C:
-				d.poll()
+				if _, err := d.poll(); err != nil {
+					log.Printf("donation poll failed: %v", err)
+				}
 			}
 		}
 	}()
@@ -117,16 +189,88 @@ func (d *DonationPoller) Stop() {
 	}
 }
 
-func (d *DonationPoller) poll() {
-	evs, lastTime, err := d.doDonationRequest(10)
+// PollNow polls for new donations immediately, outside of the regular
+// schedule. It returns the number of new donations found.
+func (d *DonationPoller) PollNow() (int, error) {
+	return d.poll()
+}
+
+func (d *DonationPoller) poll() (int, error) {
+	d.pollMu.Lock()
+	defer d.pollMu.Unlock()
+	evs, err := d.fetchNewDonations()
 	if err != nil {
-		log.Printf("donation poll failed: %v", err)
-		return
+		return 0, err
 	}
-	d.lastDonationTime = lastTime
 	for _, ev := range evs {
 		d.donationCallback(ev)
 	}
+	return len(evs), nil
+}
+
+// fetchNewDonations fetches all donations since the last one we saw, in
+// chronological order. The API returns the newest activities first, so if
+// more than a page's worth of donations arrived since the last poll, we page
+// backwards until we reach a donation we saw in an earlier poll (or give up
+// after maxPagesPerPoll pages).
+func (d *DonationPoller) fetchNewDonations() ([]donation.Event, error) {
+	var allEvs []donation.Event
+	newestTime := d.lastDonationTime
+	// Pages can overlap, since the time bounds are inclusive.
+	newIDs := make(map[string]time.Time)
+	before := time.Now()
+	for page := 0; ; page++ {
+		if page == maxPagesPerPoll {
+			log.Printf("WARNING: stopped after fetching %d pages of StreamElements donations; older donations were skipped", page)
+			break
+		}
+		evs, times, ids, err := d.doDonationRequest(d.pageSize, before, d.ignore)
+		if err != nil {
+			return nil, err
+		}
+		caughtUp := false
+		var pageEvs []donation.Event
+		for i, ev := range evs {
+			if _, ok := d.seenIDs[ids[i]]; ok {
+				caughtUp = true
+				continue
+			}
+			if _, ok := newIDs[ids[i]]; ok {
+				continue
+			}
+			pageEvs = append(pageEvs, ev)
+			newIDs[ids[i]] = times[i]
+			if times[i].After(newestTime) {
+				newestTime = times[i]
+			}
+		}
+		// Each page is older than the one before it.
+		allEvs = append(pageEvs, allEvs...)
+		if caughtUp || len(evs) < d.pageSize || len(pageEvs) == 0 {
+			break
+		}
+		before = times[0]
+		log.Printf("more than %d new StreamElements donations; fetching the next page", d.pageSize)
+	}
+	for id, t := range newIDs {
+		d.seenIDs[id] = t
+	}
+	d.lastDonationTime = newestTime
+	d.forgetOldIDs()
+	return allEvs, nil
+}
+
+// forgetOldIDs forgets the seen and skipped activities from before the
+// second of the last donation, which later requests can't return.
+func (d *DonationPoller) forgetOldIDs() {
+	cutoff := d.lastDonationTime.Truncate(time.Second)
+	for _, ids := range []map[string]time.Time{d.seenIDs, d.ignoredIDs} {
+		for id, t := range ids {
+			if t.Before(cutoff) {
+				delete(ids, id)
+			}
+		}
+	}
 }
 
 func (d *DonationPoller) createAPIRequest(url string) (*http.Request, error) {
@@ -140,7 +284,7 @@ func (d *DonationPoller) createAPIRequest(url string) (*http.Request, error) {
 }
 
 func (d *DonationPoller) getActivityFeedUrl() (*url.URL, error) {
-	return url.Parse(fmt.Sprintf(activityFeedUrlTemplate, d.seChannelID))
+	return url.Parse(d.activityFeedUrl)
 }
 
 // doUserRequest fetches the username of the StreamElements account.
@@ -150,7 +294,7 @@ func (d *DonationPoller) doUserRequest() (string, error) {
 		return "", err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := d.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error fetching StreamElements user info: %v", err)
 	}
@@ -166,21 +310,22 @@ func (d *DonationPoller) doUserRequest() (string, error) {
 	return username, nil
 }
 
-// doDonationRequest fetches donations from StreamElements. It returns the parsed
-// donations in chronological order, and the time of the most recent donation.
-func (d *DonationPoller) doDonationRequest(limit int) ([]donation.Event, time.Time, error) {
+// doDonationRequest fetches the newest donations from StreamElements made
+// between the last known donation and the given time. It returns the parsed
+// donations in chronological order, and corresponding lists of the times at
+// which they were made and their activity IDs. Skipped donations are passed to
+// ignore.
+func (d *DonationPoller) doDonationRequest(limit int, before time.Time, ignore func(seActivity, string)) ([]donation.Event, []time.Time, []string, error) {
 	u, err := d.getActivityFeedUrl()
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, nil, nil, err
 	}
 	q := u.Query()
 	q.Set("origin", apiOrigin)
 	q.Set("limit", strconv.Itoa(limit))
-	// TODO(aerion): Adding +1s here should be fine, but theoretically we
-	// could miss an event. Consider just tracking all the IDs we've seen so
-	// far during this session.
-	q.Set("after", d.lastDonationTime.Add(1*time.Second).Format(time.RFC3339))
-	q.Set("before", time.Now().Format(time.RFC3339))
+	q.Set("after", d.lastDonationTime.Format(time.RFC3339))
+	// Round up, so that we don't skip anything from the same second.
+	q.Set("before", before.Truncate(time.Second).Add(time.Second).Format(time.RFC3339))
 	// All these bounds are required parameters even if you're only asking for tips.
 	q.Set("mincheer", "0")
 	q.Set("minhost", "0")
@@ -190,26 +335,23 @@ func (d *DonationPoller) doDonationRequest(limit int) ([]donation.Event, time.Ti
 	u.RawQuery = q.Encode()
 	req, err := d.createAPIRequest(u.String())
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, nil, nil, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := d.httpClient.Do(req)
 	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("error polling StreamElements: %v", err)
+		return nil, nil, nil, fmt.Errorf("error polling StreamElements: %v", err)
 	}
 	defer resp.Body.Close()
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("error reading StreamElements response: %v", err)
+		return nil, nil, nil, fmt.Errorf("error reading StreamElements response: %v", err)
 	}
-	evs, times, err := parseDonationResponse(raw, d.twitchChannel)
+	evs, times, ids, err := parseDonationResponse(raw, d.twitchChannel, d.rates, ignore)
 	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("error parsing StreamElements response: %v", err)
+		return nil, nil, nil, fmt.Errorf("error parsing StreamElements response: %v", err)
 	}
-	if len(evs) == 0 {
-		return nil, d.lastDonationTime, nil
-	}
-	return evs, times[len(times)-1], nil
+	return evs, times, ids, nil
 }
 
 type userResponse struct {
@@ -226,41 +368,80 @@ func parseUserResponse(raw []byte) (string, error) {
 }
 
 // parseDonationResponse parses the JSON response, returning a list of events
-// in chronological order and a corresponding list of times at which the donations were made.
-func parseDonationResponse(raw []byte, twitchChannel string) ([]donation.Event, []time.Time, error) {
+// in chronological order and corresponding lists of the times at which the
+// donations were made and their activity IDs. Donations that are skipped are
+// passed to ignore, if it isn't nil.
+func parseDonationResponse(raw []byte, twitchChannel string, rates donation.Rates, ignore func(seActivity, string)) ([]donation.Event, []time.Time, []string, error) {
 	// TODO(aerion): Give this function a DonationPoller receiver instead of
 	// passing the Twitch channel by argument.
-	var activities []seActivity
-	err := json.Unmarshal(raw, &activities)
-	if err != nil {
-		return nil, nil, err
+	var raws []json.RawMessage
+	if err := json.Unmarshal(raw, &raws); err != nil {
+		return nil, nil, nil, err
+	}
+	activities := make([]seActivity, len(raws))
+	for i, r := range raws {
+		if err := json.Unmarshal(r, &activities[i]); err != nil {
+			return nil, nil, nil, err
+		}
+		activities[i].raw = r
 	}
 	if len(activities) == 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 	sort.Sort(byCreationTime(activities))
 	var evs []donation.Event
 	var times []time.Time
+	var ids []string
 	for i := 0; i < len(activities); i++ {
 		a := activities[i]
-		if a.Data.Currency != "USD" {
-			log.Printf("ignoring Unamerican donation of %.2f %s", a.Data.Dollars, a.Data.Currency)
-			continue
-		}
-		evs = append(evs, donation.Event{
+		ev := donation.Event{
+			Source:  donation.SourceStreamElements,
+			ID:      a.DonationID,
+			Time:    a.Time(),
 			Owner:   a.Data.Donator,
 			Channel: twitchChannel,
-			Cash:    donation.CentsValue(int(a.Data.Dollars * 100)),
 			Message: a.Data.Message,
-		})
+		}
+		if !ev.SetAmount(a.Data.Amount.Cents(), a.Data.Currency, rates) {
+			log.Printf("WARNING: ignoring donation of %s %s, which has no exchange rate", a.Data.Amount, a.Data.Currency)
+			if ignore != nil {
+				ignore(a, "no exchange rate")
+			}
+			continue
+		}
+		// The activity feed doesn't report the processor's fee, so it can
+		// only come from the configured schedule.
+		ev.ApplyFeeSchedule(rates)
+		evs = append(evs, ev)
 		times = append(times, a.Time())
+		ids = append(ids, a.DonationID)
 	}
-	return evs, times, nil
+	return evs, times, ids, nil
 }
 
+// seCreds is the StreamElements credentials file. Besides the channel and
+// token, it can optionally set the polling parameters.
 type seCreds struct {
 	ChannelID string `json:"channelId"`
 	AuthToken string `json:"jwtToken"`
+	// How often to poll for new donations. Defaults to 30 seconds.
+	PollIntervalSeconds int `json:"pollIntervalSeconds"`
+	// How many activities to request at a time. Defaults to 10.
+	PageSize int `json:"pageSize"`
+}
+
+func (c seCreds) pollInterval() time.Duration {
+	if c.PollIntervalSeconds == 0 {
+		return defaultPollInterval
+	}
+	return time.Duration(c.PollIntervalSeconds) * time.Second
+}
+
+func (c seCreds) pageSize() int {
+	if c.PageSize == 0 {
+		return defaultPageSize
+	}
+	return c.PageSize
 }
 
 func parseCreds(path string) (seCreds, error) {
@@ -278,5 +459,11 @@ func parseCreds(path string) (seCreds, error) {
 	if creds.AuthToken == "" {
 		return seCreds{}, errors.New("auth token missing from StreamElements credentials file")
 	}
+	if creds.pollInterval() < minPollInterval {
+		return seCreds{}, fmt.Errorf("StreamElements poll interval must be at least %v", minPollInterval)
+	}
+	if creds.pageSize() < 1 || creds.pageSize() > maxPageSize {
+		return seCreds{}, fmt.Errorf("StreamElements page size must be between 1 and %d", maxPageSize)
+	}
 	return creds, nil
 }
@@ -2,6 +2,9 @@ package streamelements
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -32,6 +35,7 @@ func TestParseDonationResponse(t *testing.T) {
 		name      string
 		jsonResp  string
 		wantTimes []time.Time
+		wantIDs   []string
 		wantEvs   []donation.Event
 	}{
 		{
@@ -39,25 +43,28 @@ func TestParseDonationResponse(t *testing.T) {
 			`[]`,
 			nil,
 			nil,
+			nil,
 		},
 		{
 			"one donation",
 			makeJsonResp(donationJson1),
 			[]time.Time{time1},
-			[]donation.Event{{Owner: "test1", Channel: "testing", Cash: donation.CentsValue(1234), Message: "team mid"}},
+			[]string{"d1"},
+			[]donation.Event{{Source: donation.SourceStreamElements, ID: "d1", Time: time1, Owner: "test1", Channel: "testing", Cash: donation.CentsValue(1234), Message: "team mid"}},
 		},
 		{
 			"two donations",
 			makeJsonResp(donationJson2, donationJson1),
 			[]time.Time{time1, time2},
+			[]string{"d1", "d2"},
 			[]donation.Event{
-				{Owner: "test1", Channel: "testing", Cash: donation.CentsValue(1234), Message: "team mid"},
-				{Owner: "test2", Channel: "testing", Cash: donation.CentsValue(10000), Message: "team left"},
+				{Source: donation.SourceStreamElements, ID: "d1", Time: time1, Owner: "test1", Channel: "testing", Cash: donation.CentsValue(1234), Message: "team mid"},
+				{Source: donation.SourceStreamElements, ID: "d2", Time: time2, Owner: "test2", Channel: "testing", Cash: donation.CentsValue(10000), Message: "team left"},
 			},
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			evs, times, err := parseDonationResponse([]byte(tc.jsonResp), "testing")
+			evs, times, ids, err := parseDonationResponse([]byte(tc.jsonResp), "testing", donation.Rates{}, nil)
 			if err != nil {
 				t.Errorf("error parsing json: %v", err)
 			}
@@ -65,7 +72,10 @@ func TestParseDonationResponse(t *testing.T) {
 				t.Errorf(cmp.Diff(evs, tc.wantEvs))
 			}
 			if !cmp.Equal(times, tc.wantTimes) {
-				t.Errorf("wrong last donation ID: got %v, want %v", times, tc.wantTimes)
+				t.Errorf("wrong donation times: got %v, want %v", times, tc.wantTimes)
+			}
+			if !cmp.Equal(ids, tc.wantIDs) {
+				t.Errorf("wrong donation IDs: got %v, want %v", ids, tc.wantIDs)
 			}
 		})
 	}
@@ -74,3 +84,93 @@ func TestParseDonationResponse(t *testing.T) {
 func makeJsonResp(donations ...string) string {
 	return fmt.Sprintf(`[%s]`, strings.Join(donations, ","))
 }
+
+// fakeActivityServer serves tips d1 through dn, made one second apart,
+// honoring the limit, after, and before parameters. Like the real API, the
+// time bounds are inclusive.
+func fakeActivityServer(base time.Time, n int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		after, _ := time.Parse(time.RFC3339, q.Get("after"))
+		before, _ := time.Parse(time.RFC3339, q.Get("before"))
+		var activities []string
+		for i := n; i > 0 && len(activities) < limit; i-- {
+			t := base.Add(time.Duration(i) * time.Second)
+			if t.Before(after.Truncate(time.Second)) || t.After(before) {
+				continue
+			}
+			activities = append(activities, fmt.Sprintf(`{"_id":"d%d","type":"tip","createdAt":"%s","data":{"amount":1,"currency":"USD","username":"donor%d","message":""}}`, i, t.Format(time.RFC3339Nano), i))
+		}
+		fmt.Fprint(w, makeJsonResp(activities...))
+	}))
+}
+
+func TestFetchNewDonations(t *testing.T) {
+	base := time.Date(2024, 7, 31, 8, 0, 0, 500000000, time.UTC)
+	srv := fakeActivityServer(base, 25)
+	defer srv.Close()
+	d := &DonationPoller{
+		twitchChannel:    "testing",
+		httpClient:       srv.Client(),
+		pageSize:         10,
+		activityFeedUrl:  srv.URL,
+		lastDonationTime: base.Add(3 * time.Second),
+		seenIDs:          map[string]time.Time{"d2": base.Add(2 * time.Second), "d3": base.Add(3 * time.Second)},
+		ignoredIDs:       map[string]time.Time{"x1": base, "x30": base.Add(30 * time.Second)},
+	}
+	evs, err := d.fetchNewDonations()
+	if err != nil {
+		t.Fatalf("error fetching donations: %v", err)
+	}
+	var gotOwners, wantOwners []string
+	for _, ev := range evs {
+		gotOwners = append(gotOwners, ev.Owner)
+	}
+	for i := 4; i <= 25; i++ {
+		wantOwners = append(wantOwners, fmt.Sprintf("donor%d", i))
+	}
+	if !cmp.Equal(gotOwners, wantOwners) {
+		t.Errorf(cmp.Diff(gotOwners, wantOwners))
+	}
+	if want := base.Add(25 * time.Second); !d.lastDonationTime.Equal(want) {
+		t.Errorf("wrong last donation time: got %v, want %v", d.lastDonationTime, want)
+	}
+	if _, ok := d.seenIDs["d25"]; !ok {
+		t.Errorf("d25 should be marked as seen")
+	}
+	if len(d.seenIDs) != 1 {
+		t.Errorf("got %d seen IDs, want only the last donation's", len(d.seenIDs))
+	}
+	if _, ok := d.ignoredIDs["x1"]; ok || len(d.ignoredIDs) != 1 {
+		t.Errorf("got skipped IDs %v, want only x30", d.ignoredIDs)
+	}
+}
+
+func TestIgnoredDonations(t *testing.T) {
+	const cadJson = `{"_id":"d3","type":"tip","createdAt":"2024-07-31T08:07:14.524Z","data":{"amount":5,"currency":"CAD","username":"test3","message":"team mid"}}`
+	var reasons []string
+	var payloads []string
+	d := &DonationPoller{
+		ignoredIDs: make(map[string]time.Time),
+		ignoredCallback: func(reason string, payload []byte) {
+			reasons = append(reasons, reason)
+			payloads = append(payloads, string(payload))
+		},
+	}
+	for i := 0; i < 2; i++ {
+		evs, _, _, err := parseDonationResponse([]byte(makeJsonResp(donationJson1, cadJson)), "testing", donation.Rates{}, d.ignore)
+		if err != nil {
+			t.Fatalf("error parsing json: %v", err)
+		}
+		if len(evs) != 1 || evs[0].Owner != "test1" {
+			t.Errorf("got %v, want only the USD donation", evs)
+		}
+	}
+	if want := []string{"no exchange rate"}; !cmp.Equal(reasons, want) {
+		t.Errorf("wrong reasons: got %q, want %q", reasons, want)
+	}
+	if want := []string{cadJson}; !cmp.Equal(payloads, want) {
+		t.Errorf("wrong payloads: got %q, want %q", payloads, want)
+	}
+}
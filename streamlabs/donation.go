@@ -3,6 +3,8 @@ package streamlabs
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/aerionblue/pizzafest/money"
 )
 
 // donationResponse is the response to the GET /donations request.
@@ -12,8 +14,9 @@ type donationResponse struct {
 
 type donationData struct {
 	DonationID int          `json:"donation_id"`
-	CreatedAt  donationTime `json:"created_at"`    // Seconds since the epoch.
-	Dollars    float64      `json:"amount,string"` // The decimal dollar amount.
+	CreatedAt  donationTime `json:"created_at"` // Seconds since the epoch.
+	Amount     money.Amount `json:"amount"`     // The decimal amount, in Currency.
+	Currency   string       `json:"currency"`
 	Donator    string       `json:"name"`
 	Message    string
 }
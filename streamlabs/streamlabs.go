@@ -12,12 +12,28 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/money"
 )
 
-const pollInterval = 30 * time.Second
+// Defaults and limits for the polling parameters in the credentials file.
+const (
+	defaultPollInterval = 30 * time.Second
+	minPollInterval     = 5 * time.Second
+	defaultPageSize     = 10
+	maxPageSize         = 100
+)
+
+// The timeout for API requests, unless the caller provides its own HTTP client.
+const defaultHTTPTimeout = 30 * time.Second
+
+// maxPagesPerPoll bounds how many pages we fetch in a single poll, in case the
+// API misbehaves and never returns a short page.
+const maxPagesPerPoll = 20
+
 const donationBaseUrl = "https://streamlabs.com/api/v1.0/donations"
 const userInfoBaseUrl = "https://streamlabs.com/api/v1.0/user"
 
@@ -26,26 +42,43 @@ type DonationPoller struct {
 	twitchChannel string
 	ticker        *time.Ticker
 	stop          chan interface{}
+	// Held while polling, so that scheduled and on-demand polls don't overlap.
+	pollMu sync.Mutex
+	// The client used for all API requests.
+	httpClient *http.Client
+	// How many donations to request at a time.
+	pageSize int
+	// The URL of the donations endpoint. Only overridden in tests.
+	donationUrl string
 
 	accessToken      string
 	lastDonationID   int
 	donationCallback func(donation.Event)
+	// Converts other currencies and sets the fees.
+	rates donation.Rates
 }
 
 // NewDonationPoller creates a DonationPoller that calls the provided callback once for each donation.
-func NewDonationPoller(ctx context.Context, credsPath string, twitchChannel string) (*DonationPoller, error) {
-	accessToken, err := parseCreds(credsPath)
+// If httpClient is nil, a client with a default timeout is used.
+func NewDonationPoller(ctx context.Context, credsPath string, twitchChannel string, httpClient *http.Client) (*DonationPoller, error) {
+	creds, err := parseCreds(credsPath)
 	if err != nil {
 		return nil, err
 	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
 	d := &DonationPoller{
 		// We could query Streamlabs for the Twitch channel associated with the
 		// account, but it's not necessarily the same as the channel we are
 		// operating in (especially when testing).
 		twitchChannel: twitchChannel,
-		ticker:        time.NewTicker(pollInterval),
+		ticker:        time.NewTicker(creds.pollInterval()),
 		stop:          make(chan interface{}),
-		accessToken:   accessToken,
+		httpClient:    httpClient,
+		pageSize:      creds.pageSize(),
+		donationUrl:   donationBaseUrl,
+		accessToken:   creds.AccessToken,
 	}
 	return d, nil
 }
@@ -54,6 +87,12 @@ func (d *DonationPoller) OnDonation(cb func(donation.Event)) {
 	d.donationCallback = cb
 }
 
+// SetRates sets the exchange rates and fee schedules for the donations. It
+// must be called before Start.
+func (d *DonationPoller) SetRates(r donation.Rates) {
+	d.rates = r
+}
+
 // Start starts polling for donations.
 func (d *DonationPoller) Start() error {
 	if d.donationCallback == nil {
@@ -65,11 +104,13 @@ func (d *DonationPoller) Start() error {
 	} else if username == "" {
 		return errors.New("could not find Streamlabs username")
 	}
-	evs, lastID, err := d.doDonationRequest(1, 0)
+	evs, ids, err := d.doDonationRequest(1, 0, 0)
 	if err != nil {
 		return err
 	}
-	d.lastDonationID = lastID
+	if len(ids) != 0 {
+		d.lastDonationID = ids[len(ids)-1]
+	}
 	log.Printf("starting Streamlabs polling for %s", username)
 	if len(evs) != 0 {
 		log.Printf("the last known donation is for $%s from %s", evs[0].Value(), evs[0].Owner)
@@ -80,7 +121,9 @@ func (d *DonationPoller) Start() error {
 			case <-d.stop:
 				return
 			case <-d.ticker.C:
-				d.poll()
+				if _, err := d.poll(); err != nil {
+					log.Printf("donation poll failed: %v", err)
+				}
 			}
 		}
 	}()
@@ -97,16 +140,72 @@ func (d *DonationPoller) Stop() {
 	}
 }
 
-func (d *DonationPoller) poll() {
-	evs, lastID, err := d.doDonationRequest(10, d.lastDonationID)
+// PollNow polls for new donations immediately, outside of the regular
+// schedule. It returns the number of new donations found.
+func (d *DonationPoller) PollNow() (int, error) {
+	return d.poll()
+}
+
+func (d *DonationPoller) poll() (int, error) {
+	d.pollMu.Lock()
+	defer d.pollMu.Unlock()
+	evs, lastID, err := d.fetchNewDonations()
 	if err != nil {
-		log.Printf("donation poll failed: %v", err)
-		return
+		return 0, err
 	}
 	d.lastDonationID = lastID
+	n := 0
 	for _, ev := range evs {
+		if ev.Cash == 0 && ev.Currency != "" {
+			log.Printf("WARNING: ignoring donation of %s %s from %s, which has no exchange rate", money.FormatCents(ev.OriginalCents), ev.Currency, ev.Owner)
+			continue
+		}
 		d.donationCallback(ev)
+		n++
+	}
+	return n, nil
+}
+
+// fetchNewDonations fetches all donations since the last one we saw, in
+// chronological order, along with the ID of the newest one. The API returns
+// the newest donations first, so if more than a page's worth of donations
+// arrived since the last poll, we page backwards until we reach the last
+// donation we saw (or give up after maxPagesPerPoll pages).
+func (d *DonationPoller) fetchNewDonations() ([]donation.Event, int, error) {
+	var allEvs []donation.Event
+	newestID := d.lastDonationID
+	before := 0
+	for page := 0; ; page++ {
+		if page == maxPagesPerPoll {
+			log.Printf("WARNING: stopped after fetching %d pages of Streamlabs donations; older donations were skipped", page)
+			break
+		}
+		evs, ids, err := d.doDonationRequest(d.pageSize, d.lastDonationID, before)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if before == 0 {
+			newestID = ids[len(ids)-1]
+		}
+		// The "after" parameter should exclude anything we've seen already,
+		// but don't rely on it.
+		caughtUp := false
+		for len(ids) > 0 && ids[0] <= d.lastDonationID {
+			caughtUp = true
+			evs, ids = evs[1:], ids[1:]
+		}
+		// Each page is older than the one before it.
+		allEvs = append(evs, allEvs...)
+		if caughtUp || len(ids) < d.pageSize {
+			break
+		}
+		before = ids[0]
+		log.Printf("more than %d new Streamlabs donations; fetching the next page", d.pageSize)
 	}
+	return allEvs, newestID, nil
 }
 
 // doUserRequest fetches the username of the Streamlabs account.
@@ -119,7 +218,7 @@ func (d *DonationPoller) doUserRequest() (string, error) {
 	q.Set("access_token", d.accessToken)
 	u.RawQuery = q.Encode()
 
-	resp, err := http.Get(u.String())
+	resp, err := d.httpClient.Get(u.String())
 	if err != nil {
 		return "", fmt.Errorf("error fetching Streamlabs user info: %v", err)
 	}
@@ -135,39 +234,39 @@ func (d *DonationPoller) doUserRequest() (string, error) {
 	return username, nil
 }
 
-// doDonationRequest fetches donations from Streamlabs. It returns the parsed
-// donations in chronological order, and the ID of the most recent donation.
-func (d *DonationPoller) doDonationRequest(limit int, lastID int) ([]donation.Event, int, error) {
-	u, err := url.Parse(donationBaseUrl)
+// doDonationRequest fetches the newest donations from Streamlabs with IDs
+// after lastID (and before beforeID, if it's nonzero). It returns the parsed
+// donations in chronological order, and a corresponding list of IDs.
+func (d *DonationPoller) doDonationRequest(limit int, lastID int, beforeID int) ([]donation.Event, []int, error) {
+	u, err := url.Parse(d.donationUrl)
 	if err != nil {
 		panic(err)
 	}
 	q := u.Query()
 	q.Set("access_token", d.accessToken)
 	q.Set("limit", strconv.Itoa(limit))
-	q.Set("currency", "USD")
 	if lastID != 0 {
 		q.Set("after", strconv.Itoa(lastID))
 	}
+	if beforeID != 0 {
+		q.Set("before", strconv.Itoa(beforeID))
+	}
 	u.RawQuery = q.Encode()
 
-	resp, err := http.Get(u.String())
+	resp, err := d.httpClient.Get(u.String())
 	if err != nil {
-		return nil, 0, fmt.Errorf("error polling Streamlabs: %v", err)
+		return nil, nil, fmt.Errorf("error polling Streamlabs: %v", err)
 	}
 	defer resp.Body.Close()
 	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error reading Streamlabs response: %v", err)
+		return nil, nil, fmt.Errorf("error reading Streamlabs response: %v", err)
 	}
-	evs, ids, err := parseDonationResponse(raw, d.twitchChannel)
+	evs, ids, err := parseDonationResponse(raw, d.twitchChannel, d.rates)
 	if err != nil {
-		return nil, 0, fmt.Errorf("error parsing Streamlabs response: %v", err)
+		return nil, nil, fmt.Errorf("error parsing Streamlabs response: %v", err)
 	}
-	if len(evs) == 0 {
-		return nil, lastID, nil
-	}
-	return evs, ids[len(ids)-1], nil
+	return evs, ids, nil
 }
 
 type userResponse struct {
@@ -188,7 +287,7 @@ func parseUserResponse(raw []byte) (string, error) {
 
 // parseDonationResponse parses the JSON response, returning a list of events
 // in chronological order and a corresponding list of donation IDs.
-func parseDonationResponse(raw []byte, twitchChannel string) ([]donation.Event, []int, error) {
+func parseDonationResponse(raw []byte, twitchChannel string, rates donation.Rates) ([]donation.Event, []int, error) {
 	// TODO(aerion): Give this function a DonationPoller receiver instead of
 	// passing the Twitch channel by argument.
 	var dr donationResponse
@@ -204,33 +303,71 @@ func parseDonationResponse(raw []byte, twitchChannel string) ([]donation.Event,
 	var ids []int
 	for i := len(dr.Donations) - 1; i >= 0; i = i - 1 {
 		d := dr.Donations[i]
-		evs = append(evs, donation.Event{
+		ev := donation.Event{
+			Source:  donation.SourceStreamlabs,
+			ID:      strconv.Itoa(d.DonationID),
+			Time:    time.Time(d.CreatedAt),
 			Owner:   d.Donator,
 			Channel: twitchChannel,
-			Cash:    donation.CentsValue(int(d.Dollars * 100)),
 			Message: d.Message,
-		})
+		}
+		if !ev.SetAmount(d.Amount.Cents(), d.Currency, rates) {
+			// Keep it, so that the paging still works, but it's worth
+			// nothing until it can be converted.
+			ev.Currency = d.Currency
+			ev.OriginalCents = d.Amount.Cents()
+		}
+		// The donations API doesn't report the processor's fee, so it can
+		// only come from the configured schedule.
+		ev.ApplyFeeSchedule(rates)
+		evs = append(evs, ev)
 		ids = append(ids, d.DonationID)
 	}
 	return evs, ids, nil
 }
 
-type tokens struct {
+// slCreds is the Streamlabs credentials file. Besides the OAuth tokens, it
+// can optionally set the polling parameters.
+type slCreds struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
+	// How often to poll for new donations. Defaults to 30 seconds.
+	PollIntervalSeconds int `json:"pollIntervalSeconds"`
+	// How many donations to request at a time. Defaults to 10.
+	PageSize int `json:"pageSize"`
 }
 
-func parseCreds(path string) (string, error) {
+func (c slCreds) pollInterval() time.Duration {
+	if c.PollIntervalSeconds == 0 {
+		return defaultPollInterval
+	}
+	return time.Duration(c.PollIntervalSeconds) * time.Second
+}
+
+func (c slCreds) pageSize() int {
+	if c.PageSize == 0 {
+		return defaultPageSize
+	}
+	return c.PageSize
+}
+
+func parseCreds(path string) (slCreds, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return "", fmt.Errorf("couldn't read Streamlabs credentials file: %v", err)
+		return slCreds{}, fmt.Errorf("couldn't read Streamlabs credentials file: %v", err)
+	}
+	var c slCreds
+	if err := json.Unmarshal(data, &c); err != nil {
+		return slCreds{}, fmt.Errorf("couldn't parse Streamlabs credentials: %v", err)
+	}
+	if c.AccessToken == "" {
+		return slCreds{}, errors.New("access token missing from Streamlabs credentials file")
 	}
-	var t tokens
-	if err := json.Unmarshal(data, &t); err != nil {
-		return "", fmt.Errorf("couldn't parse Streamlabs credentials: %v", err)
+	if c.pollInterval() < minPollInterval {
+		return slCreds{}, fmt.Errorf("Streamlabs poll interval must be at least %v", minPollInterval)
 	}
-	if t.AccessToken == "" {
-		return "", errors.New("access token missing from Streamlabs credentials file")
+	if c.pageSize() < 1 || c.pageSize() > maxPageSize {
+		return slCreds{}, fmt.Errorf("Streamlabs page size must be between 1 and %d", maxPageSize)
 	}
-	return t.AccessToken, nil
+	return c, nil
 }
@@ -2,8 +2,12 @@ package streamlabs
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
@@ -14,6 +18,7 @@ const donationJson1 = `{"amount": "11.0000000000","created_at": 1616710000,"curr
 const donationJson2 = `{"amount": "100.0000000000","created_at": 1616720000,"currency": "USD","donation_id": 2000,"message": "team left","name": "Konagami"}`
 
 func TestParseDonationResponse(t *testing.T) {
+	rates := donation.Rates{ExchangeRates: map[string]float64{"GBP": 1.25}}
 	for _, tc := range []struct {
 		name     string
 		jsonResp string
@@ -30,20 +35,32 @@ func TestParseDonationResponse(t *testing.T) {
 			"one donation",
 			makeJsonResp(donationJson1),
 			[]int{1000},
-			[]donation.Event{{Owner: "ShartyMcFly", Channel: "testing", Cash: donation.CentsValue(1100), Message: "team mid"}},
+			[]donation.Event{{Source: donation.SourceStreamlabs, ID: "1000", Time: time.Unix(1616710000, 0), Owner: "ShartyMcFly", Channel: "testing", Cash: donation.CentsValue(1100), Message: "team mid"}},
 		},
 		{
 			"two donations",
 			makeJsonResp(donationJson2, donationJson1),
 			[]int{1000, 2000},
 			[]donation.Event{
-				{Owner: "ShartyMcFly", Channel: "testing", Cash: donation.CentsValue(1100), Message: "team mid"},
-				{Owner: "Konagami", Channel: "testing", Cash: donation.CentsValue(10000), Message: "team left"},
+				{Source: donation.SourceStreamlabs, ID: "1000", Time: time.Unix(1616710000, 0), Owner: "ShartyMcFly", Channel: "testing", Cash: donation.CentsValue(1100), Message: "team mid"},
+				{Source: donation.SourceStreamlabs, ID: "2000", Time: time.Unix(1616720000, 0), Owner: "Konagami", Channel: "testing", Cash: donation.CentsValue(10000), Message: "team left"},
+			},
+		},
+		{
+			"other currencies",
+			makeJsonResp(
+				`{"amount": "9.9900000000","created_at": 1616740000,"currency": "XYZ","donation_id": 4000,"message": "","name": "Mystery"}`,
+				`{"amount": "8.0000000000","created_at": 1616730000,"currency": "GBP","donation_id": 3000,"message": "tea","name": "Earl"}`),
+			[]int{3000, 4000},
+			[]donation.Event{
+				{Source: donation.SourceStreamlabs, ID: "3000", Time: time.Unix(1616730000, 0), Owner: "Earl", Channel: "testing", Cash: donation.CentsValue(1000), Currency: "GBP", OriginalCents: 800, Message: "tea"},
+				// Worth nothing, since there's no exchange rate.
+				{Source: donation.SourceStreamlabs, ID: "4000", Time: time.Unix(1616740000, 0), Owner: "Mystery", Channel: "testing", Currency: "XYZ", OriginalCents: 999},
 			},
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			evs, ids, err := parseDonationResponse([]byte(tc.jsonResp), "testing")
+			evs, ids, err := parseDonationResponse([]byte(tc.jsonResp), "testing", rates)
 			if err != nil {
 				t.Errorf("error parsing json: %v", err)
 			}
@@ -60,3 +77,51 @@ func TestParseDonationResponse(t *testing.T) {
 func makeJsonResp(donations ...string) string {
 	return fmt.Sprintf(`{"data": [%s]}`, strings.Join(donations, ","))
 }
+
+// fakeDonationServer serves the donations with IDs 1 through n, honoring the
+// limit, after, and before parameters the way the real API does.
+func fakeDonationServer(n int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		after, _ := strconv.Atoi(q.Get("after"))
+		before, _ := strconv.Atoi(q.Get("before"))
+		var donations []string
+		for id := n; id > after && len(donations) < limit; id-- {
+			if before != 0 && id >= before {
+				continue
+			}
+			donations = append(donations, fmt.Sprintf(`{"amount": "1.00","donation_id": %d,"message": "","name": "donor%d"}`, id, id))
+		}
+		fmt.Fprint(w, makeJsonResp(donations...))
+	}))
+}
+
+func TestFetchNewDonations(t *testing.T) {
+	srv := fakeDonationServer(25)
+	defer srv.Close()
+	d := &DonationPoller{
+		twitchChannel:  "testing",
+		httpClient:     srv.Client(),
+		pageSize:       10,
+		donationUrl:    srv.URL,
+		lastDonationID: 3,
+	}
+	evs, newestID, err := d.fetchNewDonations()
+	if err != nil {
+		t.Fatalf("error fetching donations: %v", err)
+	}
+	var gotOwners, wantOwners []string
+	for _, ev := range evs {
+		gotOwners = append(gotOwners, ev.Owner)
+	}
+	for id := 4; id <= 25; id++ {
+		wantOwners = append(wantOwners, fmt.Sprintf("donor%d", id))
+	}
+	if !cmp.Equal(gotOwners, wantOwners) {
+		t.Errorf(cmp.Diff(gotOwners, wantOwners))
+	}
+	if newestID != 25 {
+		t.Errorf("wrong newest donation ID: got %d, want 25", newestID)
+	}
+}
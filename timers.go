@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	twitch "github.com/gempir/go-twitch-irc/v2"
+
+	"github.com/aerionblue/pizzafest/httpapi"
+)
+
+const timerCommand = "!timer"
+const timeLeftCommand = "!timeleft"
+
+// The longest timer that !timer will start.
+const maxTimerDuration = 24 * time.Hour
+
+// incentiveTimer counts down an incentive that lasts a while, e.g. "the
+// streamer plays blindfolded for 30 minutes".
+type incentiveTimer struct {
+	name    string
+	started time.Time
+	ends    time.Time
+}
+
+// timerWarning is a chat warning that a timer is about to run out.
+type timerWarning struct {
+	timer incentiveTimer
+	left  time.Duration
+}
+
+// timerTracker keeps the running timers, in the order they were started.
+type timerTracker struct {
+	mu     sync.Mutex
+	timers []incentiveTimer
+}
+
+// start starts a timer, replacing any running timer with the same name
+// (case-insensitive). Returns whether a timer was replaced.
+func (t *timerTracker) start(name string, d time.Duration, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	replaced := t.remove(name)
+	t.timers = append(t.timers, incentiveTimer{name: name, started: now, ends: now.Add(d)})
+	return replaced
+}
+
+// stop cancels the named timer. Returns whether it was running.
+func (t *timerTracker) stop(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.remove(name)
+}
+
+// remove removes the named timer. t.mu must be held.
+func (t *timerTracker) remove(name string) bool {
+	for i, tm := range t.timers {
+		if strings.EqualFold(tm.name, name) {
+			t.timers = append(t.timers[:i], t.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the running timers.
+func (t *timerTracker) list() []incentiveTimer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]incentiveTimer(nil), t.timers...)
+}
+
+// check returns the warnings that came due after since, and removes and
+// returns the timers that have run out. Timers aren't warned about if they
+// were started with less time left than the warning.
+func (t *timerTracker) check(since time.Time, now time.Time, warnings []time.Duration) ([]timerWarning, []incentiveTimer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var warns []timerWarning
+	var done []incentiveTimer
+	running := t.timers[:0]
+	for _, tm := range t.timers {
+		if !now.Before(tm.ends) {
+			done = append(done, tm)
+			continue
+		}
+		running = append(running, tm)
+		for _, w := range warnings {
+			at := tm.ends.Add(-w)
+			if at.After(since) && at.After(tm.started) && !at.After(now) {
+				warns = append(warns, timerWarning{timer: tm, left: w})
+			}
+		}
+	}
+	t.timers = running
+	return warns, done
+}
+
+// dispatchTimerCommand starts or stops an incentive timer, e.g.
+// "!timer start 30m blindfold" or "!timer stop blindfold".
+func (b *bot) dispatchTimerCommand(m twitch.PrivateMessage) {
+	args := strings.Fields(commandArgs(m.Message))
+	if len(args) == 0 {
+		b.dispatchTimeLeftCommand(m)
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "start":
+		if len(args) < 3 {
+			b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s start <duration> <name>, e.g. %s start 30m blindfold", m.User.Name, timerCommand, timerCommand))
+			return
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil || d <= 0 || d > maxTimerDuration {
+			b.say(m.Channel, fmt.Sprintf("@%s: %q isn't a duration I can count down. Try something like 30m or 1h30m.", m.User.Name, args[1]))
+			return
+		}
+		name := strings.Join(args[2:], " ")
+		if b.timers.start(name, d, time.Now()) {
+			log.Printf("%s restarted the %s timer (%v)", m.User.Name, name, d)
+		} else {
+			log.Printf("%s started the %s timer (%v)", m.User.Name, name, d)
+		}
+		b.say(m.Channel, fmt.Sprintf("The %s timer has started: %s to go!", name, describeTimeLeft(d)))
+	case "stop":
+		name := strings.Join(args[1:], " ")
+		if !b.timers.stop(name) {
+			b.say(m.Channel, fmt.Sprintf("@%s: There's no timer named %q.", m.User.Name, name))
+			return
+		}
+		log.Printf("%s stopped the %s timer", m.User.Name, name)
+		b.say(m.Channel, fmt.Sprintf("@%s: Stopped the %s timer.", m.User.Name, name))
+	default:
+		b.say(m.Channel, fmt.Sprintf("@%s: Usage: %s start <duration> <name>|stop <name>", m.User.Name, timerCommand))
+	}
+}
+
+// dispatchTimeLeftCommand tells chat how long each running timer has left.
+func (b *bot) dispatchTimeLeftCommand(m twitch.PrivateMessage) {
+	timers := b.timers.list()
+	if len(timers) == 0 {
+		b.say(m.Channel, fmt.Sprintf("@%s: No timers are running.", m.User.Name))
+		return
+	}
+	now := time.Now()
+	var parts []string
+	for _, tm := range timers {
+		parts = append(parts, fmt.Sprintf("%s: %s left", tm.name, describeTimeLeft(tm.ends.Sub(now))))
+	}
+	b.say(m.Channel, fmt.Sprintf("@%s: %s", m.User.Name, strings.Join(parts, "; ")))
+}
+
+// announceTimers warns chat before each timer runs out, and announces the
+// timers that have run out.
+func (b *bot) announceTimers(channel string, since time.Time, now time.Time, warnings []time.Duration) {
+	warns, done := b.timers.check(since, now, warnings)
+	for _, w := range warns {
+		b.say(channel, fmt.Sprintf("%s left on the %s timer!", describeMinutes(w.left), w.timer.name))
+	}
+	for _, tm := range done {
+		log.Printf("the %s timer ran out", tm.name)
+		b.say(channel, fmt.Sprintf("Time's up for %s!", tm.name))
+	}
+}
+
+// apiTimers lists the running timers for the HTTP API.
+func (b *bot) apiTimers() []httpapi.Timer {
+	var timers []httpapi.Timer
+	for _, tm := range b.timers.list() {
+		timers = append(timers, httpapi.Timer{Name: tm.name, EndsAt: tm.ends})
+	}
+	return timers
+}
+
+// describeTimeLeft rounds a duration up to the second and formats it like
+// "1h05m30s" or "4m20s".
+func describeTimeLeft(d time.Duration) string {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 0 {
+		secs = 0
+	}
+	h, m, s := secs/3600, secs/60%60, secs%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm%02ds", h, m, s)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, s)
+	}
+	return fmt.Sprintf("%ds", s)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimerTracker(t *testing.T) {
+	now := time.Now()
+	var tt timerTracker
+	if tt.start("blindfold", 30*time.Minute, now) {
+		t.Error("first start: got replaced, want a new timer")
+	}
+	tt.start("Hat", 3*time.Minute, now)
+	if !tt.start("BLINDFOLD", 12*time.Minute, now) {
+		t.Error("second start: want the blindfold timer replaced")
+	}
+	if got := tt.list(); len(got) != 2 || got[0].name != "Hat" || got[1].name != "BLINDFOLD" {
+		t.Errorf("list: got %+v, want Hat then BLINDFOLD", got)
+	}
+
+	warnings := []time.Duration{10 * time.Minute, 5 * time.Minute, time.Minute}
+	warns, done := tt.check(now, now.Add(2*time.Minute+15*time.Second), warnings)
+	if len(warns) != 2 || warns[0].timer.name != "Hat" || warns[0].left != time.Minute || warns[1].timer.name != "BLINDFOLD" || warns[1].left != 10*time.Minute {
+		t.Errorf("warnings: got %+v, want Hat at 1m and BLINDFOLD at 10m", warns)
+	}
+	if len(done) != 0 {
+		t.Errorf("done: got %+v, want none", done)
+	}
+
+	warns, done = tt.check(now.Add(2*time.Minute+15*time.Second), now.Add(3*time.Minute), warnings)
+	if len(warns) != 0 {
+		t.Errorf("warnings: got %+v, want none", warns)
+	}
+	if len(done) != 1 || done[0].name != "Hat" {
+		t.Errorf("done: got %+v, want Hat", done)
+	}
+	if got := tt.list(); len(got) != 1 {
+		t.Errorf("list after Hat ran out: got %+v, want only BLINDFOLD", got)
+	}
+
+	if !tt.stop("blindfold") || tt.stop("blindfold") {
+		t.Error("stop: want the first stop to succeed and the second to fail")
+	}
+}
+
+func TestDescribeTimeLeft(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{90*time.Minute + 30*time.Second, "1h30m30s"},
+		{4*time.Minute + 19*time.Second + time.Millisecond, "4m20s"},
+		{5 * time.Second, "5s"},
+		{-time.Second, "0s"},
+	} {
+		if got := describeTimeLeft(tc.d); got != tc.want {
+			t.Errorf("describeTimeLeft(%v): got %q, want %q", tc.d, got, tc.want)
+		}
+	}
+}
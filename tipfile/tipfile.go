@@ -82,6 +82,9 @@ func NewWatcher(path string, twitchChannel string) (*Watcher, error) {
 				}
 				for _, ev := range newEvents {
 					d := donation.Event{
+						Source:  donation.SourceTipFile,
+						ID:      ev.ID,
+						Time:    time.Now(),
 						Owner:   ev.Username,
 						Channel: twitchChannel,
 						Cash:    donation.CentsValue(ev.Cents),
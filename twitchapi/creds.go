@@ -0,0 +1,48 @@
+// Package twitchapi reads the credentials file for the Twitch Helix API. The
+// same file is shared by every package that calls Helix.
+package twitchapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// Creds is the Twitch API credentials file. The access token must be a user
+// token with the scopes for whatever it's used for. For Predictions and
+// EventSub, it's the broadcaster's token, with channel:manage:predictions or
+// the EventSub scopes (see twitcheventsub.Client). For whispers, it's the
+// bot account's token, with user:manage:whispers.
+type Creds struct {
+	ClientID      string `json:"clientId"`
+	AccessToken   string `json:"accessToken"`
+	BroadcasterID string `json:"broadcasterId"`
+}
+
+// ReadCreds reads a credentials file. The client ID and access token are
+// always required; the broadcaster ID is required if needBroadcaster is set.
+func ReadCreds(path string, needBroadcaster bool) (Creds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Creds{}, fmt.Errorf("couldn't read Twitch API credentials file: %v", err)
+	}
+	var c Creds
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Creds{}, fmt.Errorf("couldn't parse Twitch API credentials: %v", err)
+	}
+	if c.ClientID == "" || c.AccessToken == "" {
+		return Creds{}, errors.New("Twitch API credentials file needs clientId and accessToken")
+	}
+	if needBroadcaster && c.BroadcasterID == "" {
+		return Creds{}, errors.New("Twitch API credentials file needs broadcasterId")
+	}
+	return c, nil
+}
+
+// Authorize adds the headers that Helix needs to a request.
+func (c Creds) Authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	req.Header.Set("Client-Id", c.ClientID)
+}
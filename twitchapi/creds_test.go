@@ -0,0 +1,61 @@
+package twitchapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCreds(t *testing.T) {
+	for _, tc := range []struct {
+		desc            string
+		contents        string
+		needBroadcaster bool
+		want            Creds
+		wantErr         bool
+	}{
+		{
+			desc:     "all fields",
+			contents: `{"clientId": "client", "accessToken": "token", "broadcasterId": "123"}`,
+			want:     Creds{ClientID: "client", AccessToken: "token", BroadcasterID: "123"},
+		},
+		{
+			desc:     "no broadcaster, not needed",
+			contents: `{"clientId": "client", "accessToken": "token"}`,
+			want:     Creds{ClientID: "client", AccessToken: "token"},
+		},
+		{
+			desc:            "no broadcaster, needed",
+			contents:        `{"clientId": "client", "accessToken": "token"}`,
+			needBroadcaster: true,
+			wantErr:         true,
+		},
+		{
+			desc:     "no token",
+			contents: `{"clientId": "client", "broadcasterId": "123"}`,
+			wantErr:  true,
+		},
+		{
+			desc:     "not JSON",
+			contents: `clientId=client`,
+			wantErr:  true,
+		},
+	} {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		if err := os.WriteFile(path, []byte(tc.contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadCreds(path, tc.needBroadcaster)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %+v, want error", tc.desc, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: got error %v", tc.desc, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.desc, got, tc.want)
+		}
+	}
+}
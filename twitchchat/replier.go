@@ -0,0 +1,135 @@
+package twitchchat
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+const defaultAddress = "irc.chat.twitch.tv:6697"
+
+// Replier sends threaded replies to Twitch chat, i.e. PRIVMSGs with a
+// reply-parent-msg-id tag, which go-twitch-irc v2 can't send. It keeps its own
+// connection to Twitch chat, which it only ever writes to (apart from
+// answering PINGs), and reconnects when that connection breaks.
+type Replier struct {
+	// The address of the Twitch IRC server. Defaults to Twitch's TLS port.
+	Address string
+	// Whether to connect with TLS. Defaults to true.
+	TLS bool
+
+	creds Creds
+
+	mu   sync.Mutex
+	conn net.Conn
+	// The channels that conn has joined.
+	joined map[string]bool
+}
+
+// NewReplier creates a Replier that logs in with the given credentials. It
+// doesn't connect until the first reply.
+func NewReplier(creds Creds) *Replier {
+	return &Replier{Address: defaultAddress, TLS: true, creds: creds}
+}
+
+// Reply sends text to the channel as a reply to the chat message with the
+// given ID. If the connection was broken, it reconnects and tries once more.
+func (r *Replier) Reply(channel string, parentMsgID string, text string) error {
+	channel = strings.ToLower(strings.TrimPrefix(channel, "#"))
+	line := fmt.Sprintf("@reply-parent-msg-id=%s PRIVMSG #%s :%s", escapeTagValue(parentMsgID), channel, sanitizeLine(text))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.send(channel, line)
+	if err != nil && r.conn != nil {
+		r.disconnect()
+		err = r.send(channel, line)
+	}
+	if err != nil {
+		r.disconnect()
+		return fmt.Errorf("error sending threaded reply: %v", err)
+	}
+	return nil
+}
+
+// send writes the line to the channel, connecting and joining first if
+// needed. r.mu must be held.
+func (r *Replier) send(channel string, line string) error {
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return err
+		}
+	}
+	if !r.joined[channel] {
+		if _, err := io.WriteString(r.conn, "JOIN #"+channel+"\r\n"); err != nil {
+			return err
+		}
+		r.joined[channel] = true
+	}
+	_, err := io.WriteString(r.conn, line+"\r\n")
+	return err
+}
+
+// connect dials the server and logs in. r.mu must be held.
+func (r *Replier) connect() error {
+	var conn net.Conn
+	var err error
+	if r.TLS {
+		conn, err = tls.Dial("tcp", r.Address, nil)
+	} else {
+		conn, err = net.Dial("tcp", r.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't connect to Twitch chat: %v", err)
+	}
+	login := "PASS " + r.creds.OAuthToken + "\r\nNICK " + r.creds.Username + "\r\n"
+	if _, err := io.WriteString(conn, login); err != nil {
+		conn.Close()
+		return fmt.Errorf("couldn't log in to Twitch chat: %v", err)
+	}
+	r.conn = conn
+	r.joined = make(map[string]bool)
+	go r.read(conn)
+	return nil
+}
+
+// disconnect closes the connection, if there is one. r.mu must be held.
+func (r *Replier) disconnect() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// read answers the server's PINGs until the connection breaks. Everything
+// else the server sends is ignored.
+func (r *Replier) read(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "PING") {
+			r.mu.Lock()
+			if r.conn == conn {
+				io.WriteString(conn, "PONG"+strings.TrimPrefix(line, "PING")+"\r\n")
+			}
+			r.mu.Unlock()
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == conn {
+		r.disconnect()
+	}
+}
+
+// escapeTagValue escapes a value for an IRCv3 message tag.
+func escapeTagValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`).Replace(v)
+}
+
+// sanitizeLine keeps a message on one IRC line.
+func sanitizeLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,51 @@
+package twitchchat
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestReplier(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer ln.Close()
+	lines := make(chan []string)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(lines)
+			return
+		}
+		defer conn.Close()
+		var got []string
+		scanner := bufio.NewScanner(conn)
+		for len(got) < 5 && scanner.Scan() {
+			got = append(got, scanner.Text())
+		}
+		lines <- got
+	}()
+
+	r := NewReplier(Creds{Username: "pizzafest", OAuthToken: "oauth:secret"})
+	r.Address = ln.Addr().String()
+	r.TLS = false
+	if err := r.Reply("#AerionBlue", "abc-123", "+5.00 for Moo Moo Meadows"); err != nil {
+		t.Fatalf("first reply: got error %v", err)
+	}
+	if err := r.Reply("aerionblue", "def 456", "two\r\nlines"); err != nil {
+		t.Fatalf("second reply: got error %v", err)
+	}
+	want := []string{
+		"PASS oauth:secret",
+		"NICK pizzafest",
+		"JOIN #aerionblue",
+		"@reply-parent-msg-id=abc-123 PRIVMSG #aerionblue :+5.00 for Moo Moo Meadows",
+		`@reply-parent-msg-id=def\s456 PRIVMSG #aerionblue :two  lines`,
+	}
+	if got := <-lines; !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+package twitcheventsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aerionblue/pizzafest/donation"
+)
+
+// The subscription types that we listen for.
+const (
+	typeSubscribe  = "channel.subscribe"
+	typeGift       = "channel.subscription.gift"
+	typeCheer      = "channel.cheer"
+	typeRedemption = "channel.channel_points_custom_reward_redemption.add"
+)
+
+// Who gets credit for anonymous gifts and cheers. These match the names
+// that Twitch uses in chat.
+const (
+	anonymousGifter  = "AnAnonymousGifter"
+	anonymousCheerer = "AnAnonymousCheerer"
+)
+
+// message is a message from the EventSub WebSocket server.
+type message struct {
+	Metadata struct {
+		MessageID        string    `json:"message_id"`
+		MessageType      string    `json:"message_type"`
+		MessageTimestamp time.Time `json:"message_timestamp"`
+		SubscriptionType string    `json:"subscription_type"`
+	} `json:"metadata"`
+	Payload struct {
+		Session *struct {
+			ID                      string `json:"id"`
+			KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+			ReconnectURL            string `json:"reconnect_url"`
+		} `json:"session"`
+		Subscription *struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"subscription"`
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+// The fields of the event payloads that we use. Fields that Twitch leaves
+// null (e.g., the user on anonymous events) are left empty.
+type subscribeEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	Tier                 string `json:"tier"`
+	IsGift               bool   `json:"is_gift"`
+}
+
+type giftEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	Total                int    `json:"total"`
+	Tier                 string `json:"tier"`
+	IsAnonymous          bool   `json:"is_anonymous"`
+}
+
+type cheerEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	IsAnonymous          bool   `json:"is_anonymous"`
+	Message              string `json:"message"`
+	Bits                 int    `json:"bits"`
+}
+
+type redemptionEvent struct {
+	UserID               string `json:"user_id"`
+	UserLogin            string `json:"user_login"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	UserInput            string `json:"user_input"`
+	Reward               struct {
+		ID string `json:"id"`
+	} `json:"reward"`
+}
+
+// parseEvent converts the event in a notification into a donation Event,
+// whose subs are valued by the given Rates. Returns false (and no error) for
+// events that shouldn't count, like a gifted sub, whose gift is counted by
+// the gifter's event instead.
+func parseEvent(subType string, data []byte, at time.Time, rates donation.Rates) (donation.Event, bool, error) {
+	switch subType {
+	case typeSubscribe:
+		var e subscribeEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return donation.Event{}, false, fmt.Errorf("error parsing %s event: %v", subType, err)
+		}
+		if e.IsGift {
+			return donation.Event{}, false, nil
+		}
+		ev := donation.Event{
+			Source: donation.SourceTwitchSub, Time: at,
+			Owner: e.UserLogin, OwnerID: e.UserID, Channel: e.BroadcasterUserLogin,
+			Type: donation.Subscription, SubTier: parseTier(e.Tier), SubCount: 1, SubMonths: 1,
+		}
+		ev.SetSubValue(rates.SubValue(ev))
+		return ev, true, nil
+	case typeGift:
+		var e giftEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return donation.Event{}, false, fmt.Errorf("error parsing %s event: %v", subType, err)
+		}
+		owner, ownerID := e.UserLogin, e.UserID
+		if e.IsAnonymous || owner == "" {
+			owner, ownerID = anonymousGifter, ""
+		}
+		ev := donation.Event{
+			Source: donation.SourceTwitchSub, Time: at,
+			Owner: owner, OwnerID: ownerID, Channel: e.BroadcasterUserLogin,
+			Type: donation.GiftSubscription, SubTier: parseTier(e.Tier), SubCount: e.Total, SubMonths: 1,
+		}
+		if e.Total > 1 {
+			ev.Type = donation.CommunityGift
+		}
+		ev.SetSubValue(rates.SubValue(ev))
+		return ev, true, nil
+	case typeCheer:
+		var e cheerEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return donation.Event{}, false, fmt.Errorf("error parsing %s event: %v", subType, err)
+		}
+		owner, ownerID := e.UserLogin, e.UserID
+		if e.IsAnonymous || owner == "" {
+			owner, ownerID = anonymousCheerer, ""
+		}
+		return donation.Event{
+			Source: donation.SourceTwitchBits, Time: at,
+			Owner: owner, OwnerID: ownerID, Channel: e.BroadcasterUserLogin,
+			Bits: e.Bits, Message: e.Message,
+		}, true, nil
+	case typeRedemption:
+		var e redemptionEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return donation.Event{}, false, fmt.Errorf("error parsing %s event: %v", subType, err)
+		}
+		return donation.Event{
+			Source: donation.SourceChannelPoints, Time: at,
+			Owner: e.UserLogin, OwnerID: e.UserID, Channel: e.BroadcasterUserLogin,
+			Reward: e.Reward.ID, Message: e.UserInput,
+		}, true, nil
+	}
+	return donation.Event{}, false, fmt.Errorf("unexpected subscription type %q", subType)
+}
+
+// parseTier reads EventSub's tier ("1000", "2000", or "3000"). EventSub
+// reports Prime subs as tier "1000", with nothing else to tell them apart, so
+// a Prime sub comes out as tier 1. A caller that values Prime subs
+// differently needs chat's msg-param-sub-plan tag to know which is which.
+func parseTier(tier string) donation.SubTier {
+	switch strings.TrimSpace(tier) {
+	case "2000":
+		return donation.SubTier2
+	case "3000":
+		return donation.SubTier3
+	}
+	return donation.SubTier1
+}
@@ -0,0 +1,302 @@
+// Package twitcheventsub receives subs, gift subs, cheers, and channel point
+// redemptions from Twitch EventSub over a WebSocket. It's an alternative to
+// reading them from the tags of chat messages, with structured payloads and
+// fewer surprises, but it can't tell Prime subs from tier 1 subs.
+package twitcheventsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/twitchapi"
+)
+
+const (
+	websocketUrl     = "wss://eventsub.wss.twitch.tv/ws"
+	subscriptionsUrl = "https://api.twitch.tv/helix/eventsub/subscriptions"
+)
+
+// The timeout for API requests, unless the caller provides its own HTTP client.
+const defaultHTTPTimeout = 30 * time.Second
+
+// How long to wait before reconnecting after the connection fails.
+const reconnectDelay = 10 * time.Second
+
+// How much longer than the keepalive timeout to wait for a message before
+// giving up on the connection.
+const keepaliveSlack = 5 * time.Second
+
+// How long to remember message IDs, to ignore redeliveries.
+const seenMessageTTL = 10 * time.Minute
+
+// Client receives donation and redemption events from EventSub on behalf of a
+// broadcaster. It only subscribes to the kinds of events that have a
+// callback.
+type Client struct {
+	httpClient *http.Client
+	creds      twitchapi.Creds
+	// Only overridden in tests.
+	websocketUrl     string
+	subscriptionsUrl string
+
+	donationCallback   func(donation.Event)
+	redemptionCallback func(donation.Event)
+	// Values the subs.
+	rates donation.Rates
+
+	mu sync.Mutex
+	// Maps the IDs of the notifications we've handled to when we got them.
+	seen map[string]time.Time
+}
+
+// NewClient creates a Client with the credentials in the given file. If
+// httpClient is nil, a client with a default timeout is used.
+func NewClient(credsPath string, httpClient *http.Client) (*Client, error) {
+	c, err := twitchapi.ReadCreds(credsPath, true)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return &Client{
+		httpClient:       httpClient,
+		creds:            c,
+		websocketUrl:     websocketUrl,
+		subscriptionsUrl: subscriptionsUrl,
+		seen:             make(map[string]time.Time),
+	}, nil
+}
+
+// OnDonation sets the callback for subs, gift subs, and cheers. The access
+// token needs the channel:read:subscriptions and bits:read scopes.
+func (c *Client) OnDonation(cb func(donation.Event)) {
+	c.donationCallback = cb
+}
+
+// SetRates sets what the subs are worth. It must be called before Start.
+func (c *Client) SetRates(r donation.Rates) {
+	c.rates = r
+}
+
+// OnRedemption sets the callback for channel point redemptions. Their Events
+// are worth nothing until their Credit is set. The access token needs the
+// channel:read:redemptions scope.
+func (c *Client) OnRedemption(cb func(donation.Event)) {
+	c.redemptionCallback = cb
+}
+
+// subscriptionTypes returns the event types that have callbacks.
+func (c *Client) subscriptionTypes() []string {
+	var types []string
+	if c.donationCallback != nil {
+		types = append(types, typeSubscribe, typeGift, typeCheer)
+	}
+	if c.redemptionCallback != nil {
+		types = append(types, typeRedemption)
+	}
+	return types
+}
+
+// Start connects to EventSub and delivers events until the program exits. If
+// the connection fails, it reconnects and subscribes again. Only the first
+// connection's errors are returned.
+func (c *Client) Start() error {
+	if c.donationCallback == nil && c.redemptionCallback == nil {
+		panic("a callback must be provided to OnDonation or OnRedemption before calling Start")
+	}
+	ws, err := c.connect(c.websocketUrl, true)
+	if err != nil {
+		return err
+	}
+	log.Printf("listening for EventSub events for broadcaster %s", c.creds.BroadcasterID)
+	go func() {
+		for {
+			err := c.listen(ws)
+			log.Printf("ERROR EventSub connection lost (reconnecting in %v): %v", reconnectDelay, err)
+			for {
+				time.Sleep(reconnectDelay)
+				if ws, err = c.connect(c.websocketUrl, true); err == nil {
+					break
+				}
+				log.Printf("ERROR reconnecting to EventSub (retrying in %v): %v", reconnectDelay, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// session is an open EventSub connection.
+type session struct {
+	conn             *websocket.Conn
+	keepaliveTimeout time.Duration
+}
+
+// connect opens a connection and waits for the welcome message. If
+// subscribe is set, it subscribes to the events on the new session.
+// (A session that replaces an old one, after Twitch asks us to reconnect,
+// keeps the old one's subscriptions.)
+func (c *Client) connect(url string, subscribe bool) (session, error) {
+	conn, err := websocket.Dial(url, "", "http://localhost/")
+	if err != nil {
+		return session{}, fmt.Errorf("error connecting to EventSub: %v", err)
+	}
+	msg, err := receive(conn, time.Now().Add(defaultHTTPTimeout))
+	if err != nil {
+		conn.Close()
+		return session{}, fmt.Errorf("error waiting for EventSub welcome: %v", err)
+	}
+	if msg.Metadata.MessageType != "session_welcome" || msg.Payload.Session == nil {
+		conn.Close()
+		return session{}, fmt.Errorf("expected an EventSub welcome, got a %q message", msg.Metadata.MessageType)
+	}
+	s := session{conn: conn, keepaliveTimeout: time.Duration(msg.Payload.Session.KeepaliveTimeoutSeconds) * time.Second}
+	if subscribe {
+		for _, t := range c.subscriptionTypes() {
+			if err := c.subscribe(t, msg.Payload.Session.ID); err != nil {
+				conn.Close()
+				return session{}, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// listen handles messages on the session until it fails.
+func (c *Client) listen(s session) error {
+	for {
+		msg, err := receive(s.conn, time.Now().Add(s.keepaliveTimeout+keepaliveSlack))
+		if err != nil {
+			s.conn.Close()
+			return err
+		}
+		switch msg.Metadata.MessageType {
+		case "session_keepalive":
+		case "notification":
+			c.handleNotification(msg)
+		case "session_reconnect":
+			if msg.Payload.Session == nil || msg.Payload.Session.ReconnectURL == "" {
+				s.conn.Close()
+				return errors.New("EventSub asked us to reconnect without saying where")
+			}
+			next, err := c.connect(msg.Payload.Session.ReconnectURL, false)
+			s.conn.Close()
+			if err != nil {
+				return err
+			}
+			log.Print("moved to a new EventSub connection")
+			s = next
+		case "revocation":
+			if sub := msg.Payload.Subscription; sub != nil {
+				log.Printf("ERROR EventSub revoked our %s subscription: %s", sub.Type, sub.Status)
+			}
+		default:
+			log.Printf("WARNING: unexpected EventSub message type %q", msg.Metadata.MessageType)
+		}
+	}
+}
+
+// handleNotification delivers the event in a notification, unless it's a
+// redelivery of one that we already handled.
+func (c *Client) handleNotification(msg message) {
+	if c.markSeen(msg.Metadata.MessageID, time.Now()) {
+		return
+	}
+	ev, ok, err := parseEvent(msg.Metadata.SubscriptionType, msg.Payload.Event, msg.Metadata.MessageTimestamp, c.rates)
+	if err != nil {
+		log.Printf("ERROR %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	ev.ID = msg.Metadata.MessageID
+	if ev.Source == donation.SourceChannelPoints {
+		if c.redemptionCallback != nil {
+			c.redemptionCallback(ev)
+		}
+	} else if c.donationCallback != nil {
+		c.donationCallback(ev)
+	}
+}
+
+// markSeen records a notification's ID, and returns whether it was already
+// seen.
+func (c *Client) markSeen(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for old, t := range c.seen {
+		if now.Sub(t) > seenMessageTTL {
+			delete(c.seen, old)
+		}
+	}
+	if _, ok := c.seen[id]; ok {
+		return true
+	}
+	c.seen[id] = now
+	return false
+}
+
+func receive(conn *websocket.Conn, deadline time.Time) (message, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return message{}, err
+	}
+	var data []byte
+	if err := websocket.Message.Receive(conn, &data); err != nil {
+		return message{}, err
+	}
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return message{}, fmt.Errorf("error parsing EventSub message: %v", err)
+	}
+	return msg, nil
+}
+
+// subscribe creates a subscription to the given event type on a session.
+func (c *Client) subscribe(subType string, sessionID string) error {
+	type transport struct {
+		Method    string `json:"method"`
+		SessionID string `json:"session_id"`
+	}
+	req := struct {
+		Type      string            `json:"type"`
+		Version   string            `json:"version"`
+		Condition map[string]string `json:"condition"`
+		Transport transport         `json:"transport"`
+	}{
+		Type:      subType,
+		Version:   "1",
+		Condition: map[string]string{"broadcaster_user_id": c.creds.BroadcasterID},
+		Transport: transport{Method: "websocket", SessionID: sessionID},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, c.subscriptionsUrl, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	c.creds.Authorize(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("error subscribing to %s: %v", subType, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("subscribing to %s failed with status %d: %s", subType, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	return nil
+}
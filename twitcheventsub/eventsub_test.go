@@ -0,0 +1,196 @@
+package twitcheventsub
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/websocket"
+
+	"github.com/aerionblue/pizzafest/donation"
+	"github.com/aerionblue/pizzafest/twitchapi"
+)
+
+func TestParseEvent(t *testing.T) {
+	at := time.Date(2024, 7, 31, 8, 0, 0, 0, time.UTC)
+	rates := donation.Rates{Tiers: map[donation.SubTier]donation.CentsValue{donation.SubTier2: 1000}}
+	for _, tc := range []struct {
+		desc    string
+		subType string
+		json    string
+		want    donation.Event
+		wantOK  bool
+	}{
+		{
+			"sub",
+			typeSubscribe,
+			`{"user_id":"1234","user_login":"aerionblue","broadcaster_user_login":"testing","tier":"2000","is_gift":false}`,
+			donation.Event{Source: donation.SourceTwitchSub, Time: at, Owner: "aerionblue", OwnerID: "1234", Channel: "testing", Type: donation.Subscription, SubTier: donation.SubTier2, SubCount: 1, SubMonths: 1, SubValue: 1000},
+			true,
+		},
+		{
+			"gifted sub",
+			typeSubscribe,
+			`{"user_login":"aerionblue","broadcaster_user_login":"testing","tier":"1000","is_gift":true}`,
+			donation.Event{},
+			false,
+		},
+		{
+			"community gift",
+			typeGift,
+			`{"user_login":"usedpizza","broadcaster_user_login":"testing","total":5,"tier":"1000","is_anonymous":false}`,
+			donation.Event{Source: donation.SourceTwitchSub, Time: at, Owner: "usedpizza", Channel: "testing", Type: donation.CommunityGift, SubTier: donation.SubTier1, SubCount: 5, SubMonths: 1, SubValue: 600},
+			true,
+		},
+		{
+			"anonymous gift",
+			typeGift,
+			`{"user_id":"274598607","user_login":null,"broadcaster_user_login":"testing","total":1,"tier":"3000","is_anonymous":true}`,
+			donation.Event{Source: donation.SourceTwitchSub, Time: at, Owner: anonymousGifter, Channel: "testing", Type: donation.GiftSubscription, SubTier: donation.SubTier3, SubCount: 1, SubMonths: 1, SubValue: 2500},
+			true,
+		},
+		{
+			"cheer",
+			typeCheer,
+			`{"user_login":"Mizalie","broadcaster_user_login":"testing","is_anonymous":false,"message":"Cheer444 moo","bits":444}`,
+			donation.Event{Source: donation.SourceTwitchBits, Time: at, Owner: "Mizalie", Channel: "testing", Bits: 444, Message: "Cheer444 moo"},
+			true,
+		},
+		{
+			"redemption",
+			typeRedemption,
+			`{"id":"r1","user_id":"1234","user_login":"aerionblue","broadcaster_user_login":"testing","user_input":"moo please","reward":{"id":"abc-123","title":"Vote","cost":500}}`,
+			donation.Event{Source: donation.SourceChannelPoints, Time: at, Owner: "aerionblue", OwnerID: "1234", Channel: "testing", Reward: "abc-123", Message: "moo please"},
+			true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			ev, ok, err := parseEvent(tc.subType, []byte(tc.json), at, rates)
+			if err != nil {
+				t.Fatalf("error parsing event: %v", err)
+			}
+			if ok != tc.wantOK {
+				t.Errorf("got ok %v, want %v", ok, tc.wantOK)
+			}
+			if !cmp.Equal(ev, tc.want) {
+				t.Error(cmp.Diff(tc.want, ev))
+			}
+		})
+	}
+	if _, _, err := parseEvent("channel.follow", []byte(`{}`), at, rates); err == nil {
+		t.Error("expected an error for an unknown subscription type")
+	}
+}
+
+// notification formats a notification message for a cheer.
+func notification(id string, bits int) string {
+	return fmt.Sprintf(`{"metadata":{"message_id":%q,"message_type":"notification","message_timestamp":"2024-07-31T08:00:00Z","subscription_type":"channel.cheer"},`+
+		`"payload":{"subscription":{"type":"channel.cheer","status":"enabled"},"event":{"user_login":"aerionblue","broadcaster_user_login":"testing","bits":%d}}}`, id, bits)
+}
+
+func TestClient(t *testing.T) {
+	var mu sync.Mutex
+	var subscribed []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		subscribed = append(subscribed, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer api.Close()
+	ws := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		for _, msg := range []string{
+			`{"metadata":{"message_type":"session_welcome"},"payload":{"session":{"id":"s1","keepalive_timeout_seconds":10}}}`,
+			notification("n1", 100),
+			`{"metadata":{"message_type":"session_keepalive"},"payload":{}}`,
+			// A redelivery, which should be ignored.
+			notification("n1", 100),
+			notification("n2", 250),
+		} {
+			websocket.Message.Send(conn, msg)
+		}
+		// Hold the connection open until the client goes away.
+		var data []byte
+		websocket.Message.Receive(conn, &data)
+	}))
+	defer ws.Close()
+
+	evs := make(chan donation.Event, 10)
+	c := &Client{
+		httpClient:       api.Client(),
+		creds:            twitchapi.Creds{ClientID: "id", AccessToken: "token", BroadcasterID: "123"},
+		websocketUrl:     "ws" + strings.TrimPrefix(ws.URL, "http"),
+		subscriptionsUrl: api.URL,
+		seen:             make(map[string]time.Time),
+	}
+	c.OnDonation(func(ev donation.Event) { evs <- ev })
+	if err := c.Start(); err != nil {
+		t.Fatalf("error starting: %v", err)
+	}
+	var gotBits []int
+	for len(gotBits) < 2 {
+		select {
+		case ev := <-evs:
+			gotBits = append(gotBits, ev.Bits)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events; got %v", gotBits)
+		}
+	}
+	if want := []int{100, 250}; !cmp.Equal(gotBits, want) {
+		t.Errorf("got bits %v, want %v", gotBits, want)
+	}
+	select {
+	case ev := <-evs:
+		t.Errorf("got an unexpected event: %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(subscribed) != 3 {
+		t.Fatalf("got %d subscriptions, want 3: %v", len(subscribed), subscribed)
+	}
+	for i, want := range []string{typeSubscribe, typeGift, typeCheer} {
+		if !strings.Contains(subscribed[i], fmt.Sprintf(`"type":%q`, want)) || !strings.Contains(subscribed[i], `"session_id":"s1"`) {
+			t.Errorf("subscription %d: got %s, want %s on session s1", i, subscribed[i], want)
+		}
+	}
+}
+
+func TestRedemptionCallback(t *testing.T) {
+	c := &Client{seen: make(map[string]time.Time)}
+	var donations, redemptions []donation.Event
+	c.OnRedemption(func(ev donation.Event) { redemptions = append(redemptions, ev) })
+	if got, want := c.subscriptionTypes(), []string{typeRedemption}; !cmp.Equal(got, want) {
+		t.Errorf("with only a redemption callback, got subscriptions %v, want %v", got, want)
+	}
+	var msg message
+	msg.Metadata.MessageID = "n1"
+	msg.Metadata.SubscriptionType = typeRedemption
+	msg.Payload.Event = []byte(`{"user_login":"aerionblue","broadcaster_user_login":"testing","user_input":"moo","reward":{"id":"abc-123"}}`)
+	c.handleNotification(msg)
+	// A cheer with no callback for it is dropped.
+	msg.Metadata.MessageID = "n2"
+	msg.Metadata.SubscriptionType = typeCheer
+	msg.Payload.Event = []byte(`{"user_login":"aerionblue","broadcaster_user_login":"testing","bits":100}`)
+	c.handleNotification(msg)
+	if len(redemptions) != 1 || redemptions[0].Reward != "abc-123" || redemptions[0].ID != "n1" {
+		t.Errorf("got redemptions %+v, want the one for abc-123", redemptions)
+	}
+
+	c.OnDonation(func(ev donation.Event) { donations = append(donations, ev) })
+	if got, want := c.subscriptionTypes(), []string{typeSubscribe, typeGift, typeCheer, typeRedemption}; !cmp.Equal(got, want) {
+		t.Errorf("with both callbacks, got subscriptions %v, want %v", got, want)
+	}
+	msg.Metadata.MessageID = "n3"
+	c.handleNotification(msg)
+	if len(donations) != 1 || donations[0].Bits != 100 {
+		t.Errorf("got donations %+v, want the 100-bit cheer", donations)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Twitch's whisper limits, which are separate from the chat limits: 3
+// whispers per second and 100 per minute, to a limited number of different
+// users per day.
+const (
+	whisperPerSecond     = 3
+	whisperPerMinute     = 100
+	whisperRecipientsTTL = 24 * time.Hour
+)
+
+// whisperLimiter keeps the bot's whispers within Twitch's limits. A nil
+// whisperLimiter allows everything.
+type whisperLimiter struct {
+	perSecond *rate.Limiter
+	perMinute *rate.Limiter
+	// The most different users to whisper per day. If negative, there's no
+	// limit.
+	maxRecipients int
+
+	mu sync.Mutex
+	// The lowercase usernames whispered since resetAt.
+	recipients map[string]bool
+	resetAt    time.Time
+}
+
+func newWhisperLimiter(maxRecipients int) *whisperLimiter {
+	return &whisperLimiter{
+		perSecond:     rate.NewLimiter(whisperPerSecond, whisperPerSecond),
+		perMinute:     rate.NewLimiter(rate.Every(time.Minute/whisperPerMinute), whisperPerMinute),
+		maxRecipients: maxRecipients,
+		recipients:    make(map[string]bool),
+	}
+}
+
+// allow reports whether the bot may whisper the user now, and if so, counts
+// the whisper.
+func (l *whisperLimiter) allow(user string, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.resetAt) >= whisperRecipientsTTL {
+		l.recipients = make(map[string]bool)
+		l.resetAt = now
+	}
+	key := strings.ToLower(user)
+	if l.maxRecipients >= 0 && !l.recipients[key] && len(l.recipients) >= l.maxRecipients {
+		return false
+	}
+	// Check both limits before taking from either, so that a whisper that
+	// isn't sent doesn't use up any of the budget.
+	if l.perMinute.TokensAt(now) < 1 || l.perSecond.TokensAt(now) < 1 {
+		return false
+	}
+	l.perMinute.AllowN(now, 1)
+	l.perSecond.AllowN(now, 1)
+	l.recipients[key] = true
+	return true
+}
+
+// whisperer sends whispers. *helix.Client is one.
+type whisperer interface {
+	Whisper(to string, msg string) error
+}
+
+// whisper whispers a message to the user, if the bot can whisper and the
+// whisper limits allow it. Returns whether the whisper is being sent. It's
+// sent in the background; if Twitch doesn't take it, fallback (if not nil)
+// is called instead.
+func (b *bot) whisper(user string, msg string, fallback func()) bool {
+	if b.whisperer == nil {
+		return false
+	}
+	if !b.whispers.allow(user, time.Now()) {
+		log.Printf("[whisper limit; not sent to %v] %v", user, msg)
+		return false
+	}
+	log.Printf("[whisper -> %v] %v", user, msg)
+	if !b.ircRepliesEnabled {
+		return true
+	}
+	go func() {
+		if err := b.whisperer.Whisper(user, msg); err != nil {
+			log.Printf("ERROR whispering %s: %v", user, err)
+			if fallback != nil {
+				fallback()
+			}
+		}
+	}()
+	return true
+}
+
+// whisperAck whispers a personal acknowledgement (a message that starts with
+// an @mention) to the user it mentions, if whispered acknowledgements are on.
+// Returns whether it's being whispered; if not, the caller should say it in
+// chat. If the whisper fails, it's said in chat after all.
+func (b *bot) whisperAck(kind chatKind, channel string, msg string) bool {
+	if !b.whisperAcks {
+		return false
+	}
+	user, ok := mentionedUser(msg)
+	if !ok {
+		return false
+	}
+	return b.whisper(user, withoutMention(msg), func() { b.sayAs(kind, channel, msg) })
+}
+
+// replyPersonal sends a reply that only matters to the user it mentions. It
+// is whispered if whispered acknowledgements are on, and said in chat
+// otherwise.
+func (b *bot) replyPersonal(kind chatKind, channel string, parentID string, msg string) {
+	if b.whisperAck(kind, channel, msg) {
+		return
+	}
+	b.replyAs(kind, channel, parentID, msg)
+}
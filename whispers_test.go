@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWhisperLimiterRecipients(t *testing.T) {
+	now := time.Now()
+	l := newWhisperLimiter(2)
+	for i, user := range []string{"aerionblue", "usedpizza"} {
+		if !l.allow(user, now.Add(time.Duration(i)*time.Second)) {
+			t.Errorf("whisper to %s should be allowed", user)
+		}
+	}
+	if l.allow("paulbox", now.Add(2*time.Second)) {
+		t.Error("whisper to a third user should be over the recipient limit")
+	}
+	if !l.allow("AerionBlue", now.Add(3*time.Second)) {
+		t.Error("whisper to a user who was already whispered should be allowed")
+	}
+	if !l.allow("paulbox", now.Add(whisperRecipientsTTL)) {
+		t.Error("the recipient limit should reset after a day")
+	}
+}
+
+func TestWhisperLimiterRate(t *testing.T) {
+	now := time.Now()
+	l := newWhisperLimiter(-1)
+	for i := 0; i < whisperPerSecond; i++ {
+		if !l.allow("aerionblue", now) {
+			t.Fatalf("whisper %d should be allowed", i+1)
+		}
+	}
+	if l.allow("aerionblue", now) {
+		t.Error("whisper past the per-second limit should not be allowed")
+	}
+	if !l.allow("aerionblue", now.Add(time.Second)) {
+		t.Error("whisper a second later should be allowed")
+	}
+	// Whispers held back by the per-second limit don't count against the
+	// per-minute limit.
+	for i := 0; i < whisperPerMinute; i++ {
+		l.allow("aerionblue", now.Add(time.Second))
+	}
+	if got, want := l.perMinute.TokensAt(now.Add(time.Second)), float64(whisperPerMinute-2*whisperPerSecond); got < want {
+		t.Errorf("got %v whispers left this minute, want at least %v", got, want)
+	}
+}
+
+func TestWhisperAck(t *testing.T) {
+	b := &bot{whispers: newWhisperLimiter(40)}
+	b.whisperAcks = true
+	if b.whisperAck(chatDonation, "testing", "@aerionblue: I put your sub towards Moo Moo Meadows.") {
+		t.Error("acknowledgements should not be whispered without a way to whisper")
+	}
+	b.whisperAcks = false
+	b.whisperer = fakeWhisperer{}
+	if b.whisperAck(chatDonation, "testing", "@aerionblue: I put your sub towards Moo Moo Meadows.") {
+		t.Error("acknowledgements should not be whispered unless turned on")
+	}
+	b.whisperAcks = true
+	if !b.whisperAck(chatDonation, "testing", "@aerionblue: I put your sub towards Moo Moo Meadows.") {
+		t.Error("acknowledgement should be whispered")
+	}
+	if b.whisperAck(chatDonation, "testing", "$5.00 donation from Bob put towards Moo Moo Meadows.") {
+		t.Error("a message that doesn't mention a user should not be whispered")
+	}
+}
+
+// fakeWhisperer reports each whisper on sent (if it isn't nil), and then
+// fails with err.
+type fakeWhisperer struct {
+	sent chan string
+	err  error
+}
+
+func (w fakeWhisperer) Whisper(to string, msg string) error {
+	if w.sent != nil {
+		w.sent <- to + ": " + msg
+	}
+	return w.err
+}
+
+func TestWhisperFallback(t *testing.T) {
+	sent := make(chan string, 1)
+	fellBack := make(chan bool, 1)
+	fallback := func() { fellBack <- true }
+	b := &bot{whispers: newWhisperLimiter(40), whisperer: fakeWhisperer{sent: sent}, ircRepliesEnabled: true}
+
+	if !b.whisper("aerionblue", "hello", fallback) {
+		t.Fatal("whisper should be sent")
+	}
+	if got, want := <-sent, "aerionblue: hello"; got != want {
+		t.Errorf("got whisper %q, want %q", got, want)
+	}
+	select {
+	case <-fellBack:
+		t.Error("a whisper that went through should not fall back")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.whisperer = fakeWhisperer{sent: sent, err: errors.New("the bot's phone number isn't verified")}
+	if !b.whisper("aerionblue", "hello again", fallback) {
+		t.Fatal("whisper should be attempted")
+	}
+	<-sent
+	select {
+	case <-fellBack:
+	case <-time.After(5 * time.Second):
+		t.Error("a failed whisper should fall back")
+	}
+}